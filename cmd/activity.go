@@ -0,0 +1,198 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"slices"
+	"strconv"
+
+	"github.com/knadh/listmonk/internal/auth"
+	"github.com/knadh/listmonk/models"
+	"github.com/labstack/echo/v4"
+)
+
+// GetSubscriberActivity handles the retrieval of a subscriber's activity timeline.
+func (a *App) GetSubscriberActivity(c echo.Context) error {
+	user := auth.GetUser(c)
+
+	// Check if the user has access to at least one of the lists on the subscriber.
+	id := getID(c)
+	if err := a.hasSubPerm(user, []int{id}); err != nil {
+		return err
+	}
+
+	order := c.QueryParam("order")
+	if order != "asc" {
+		order = "desc"
+	}
+
+	pg := a.pg.NewFromURL(c.Request().URL.Query())
+	out, total, err := a.core.GetSubscriberActivity(id, c.QueryParams()["type"], order, pg.Offset, pg.Limit)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, okResp{models.PageResults{
+		Results: out,
+		Total:   total,
+		Page:    pg.Page,
+		PerPage: pg.PerPage,
+	}})
+}
+
+// GetUnsubscriptions handles the retrieval of the unsubscriptions made by
+// subscribers with their sources for compliance reporting.
+func (a *App) GetUnsubscriptions(c echo.Context) error {
+	var (
+		source = c.QueryParam("source")
+		from   = c.QueryParam("from")
+		to     = c.QueryParam("to")
+	)
+	switch source {
+	case "", models.UnsubSourceHeader, models.UnsubSourcePage, models.UnsubSourceReply:
+	default:
+		return echo.NewHTTPError(http.StatusBadRequest, a.i18n.Ts("globals.messages.invalidFields", "name", "source"))
+	}
+	if (from != "" && !strHasLen(from, 10, 30)) || (to != "" && !strHasLen(to, 10, 30)) {
+		return echo.NewHTTPError(http.StatusBadRequest, a.i18n.T("analytics.invalidDates"))
+	}
+	campID, _ := strconv.Atoi(c.QueryParam("campaign_id"))
+
+	pg := a.pg.NewFromURL(c.Request().URL.Query())
+	out, total, err := a.core.QueryUnsubscriptions(source, campID, from, to, pg.Offset, pg.Limit)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, okResp{models.PageResults{
+		Results: out,
+		Total:   total,
+		Page:    pg.Page,
+		PerPage: pg.PerPage,
+	}})
+}
+
+// recordActivity records an entry in the activity timeline of the given
+// subscribers (by ID or UUID). The change is attributed to the logged in
+// user, or to the subscriber on public pages. Errors are only logged as
+// the change itself has already been made.
+func (a *App) recordActivity(c echo.Context, subIDs []int, subUUIDs []string, typ string, data models.JSON) {
+	actor, userID := activityActor(c)
+	if err := a.core.RecordSubscriberActivity(subIDs, subUUIDs, typ, data, actor, userID); err != nil {
+		a.log.Printf("error recording subscriber activity: %v", err)
+	}
+}
+
+// recordActivityByQuery records an entry in the activity timeline of the
+// subscribers matching a query.
+func (a *App) recordActivityByQuery(c echo.Context, req subQueryReq, listIDs []int, typ string, data models.JSON) {
+	actor, userID := activityActor(c)
+	if err := a.core.RecordSubscriberActivityByQuery(req.Search, req.Query, listIDs, req.SubscriptionStatus,
+		typ, data, actor, userID); err != nil {
+		a.log.Printf("error recording subscriber activity: %v", err)
+	}
+}
+
+// recordSubscriberChanges records the changes to a subscriber's profile and
+// subscriptions in the activity timeline.
+func (a *App) recordSubscriberChanges(c echo.Context, old, cur models.Subscriber) {
+	changes, added, removed := subscriberChanges(old, cur)
+	if len(changes) > 0 {
+		a.recordActivity(c, []int{cur.ID}, nil, models.ActivityProfileUpdated, models.JSON{"changes": changes})
+	}
+	if len(added) > 0 {
+		a.recordActivity(c, []int{cur.ID}, nil, models.ActivitySubscribed, models.JSON{"list_ids": added})
+	}
+	if len(removed) > 0 {
+		a.recordActivity(c, []int{cur.ID}, nil, models.ActivitySubscriptionRemoved, models.JSON{"list_ids": removed})
+	}
+}
+
+// activityActor returns the actor and the user ID (if any) of a request.
+func activityActor(c echo.Context) (string, int) {
+	if u, ok := c.Get(auth.UserHTTPCtxKey).(auth.User); ok {
+		return models.ActivityActorUser, u.ID
+	}
+
+	return models.ActivityActorSubscriber, 0
+}
+
+// subscriberChanges returns the changes to a subscriber's profile and
+// subscriptions as {"field": {"old": x, "new": y}} and the IDs of the lists
+// that were added and removed.
+func subscriberChanges(old, cur models.Subscriber) (models.JSON, []int, []int) {
+	changes := models.JSON{}
+	if old.Email != cur.Email {
+		changes["email"] = change(old.Email, cur.Email)
+	}
+	if old.Name != cur.Name {
+		changes["name"] = change(old.Name, cur.Name)
+	}
+	if old.Status != cur.Status {
+		changes["status"] = change(old.Status, cur.Status)
+	}
+
+	// Compare attributes key by key after a round trip through JSON so that
+	// numbers etc. are compared consistently.
+	oldA, curA := normalizeJSON(old.Attribs), normalizeJSON(cur.Attribs)
+	attribs := models.JSON{}
+	for k, v := range curA {
+		if ov, ok := oldA[k]; !ok || !reflect.DeepEqual(ov, v) {
+			attribs[k] = change(oldA[k], v)
+		}
+	}
+	for k, v := range oldA {
+		if _, ok := curA[k]; !ok {
+			attribs[k] = change(v, nil)
+		}
+	}
+	if len(attribs) > 0 {
+		changes["attribs"] = attribs
+	}
+
+	oldLists, curLists := subscriberListIDs(old), subscriberListIDs(cur)
+	var added, removed []int
+	for _, id := range curLists {
+		if !slices.Contains(oldLists, id) {
+			added = append(added, id)
+		}
+	}
+	for _, id := range oldLists {
+		if !slices.Contains(curLists, id) {
+			removed = append(removed, id)
+		}
+	}
+
+	return changes, added, removed
+}
+
+// subscriberListIDs returns the IDs of the lists a subscriber is in.
+func subscriberListIDs(sub models.Subscriber) []int {
+	var lists []struct {
+		ID int `json:"id"`
+	}
+	if len(sub.Lists) > 0 {
+		_ = sub.Lists.Unmarshal(&lists)
+	}
+
+	out := make([]int, 0, len(lists))
+	for _, l := range lists {
+		out = append(out, l.ID)
+	}
+
+	return out
+}
+
+func change(old, cur any) models.JSON {
+	return models.JSON{"old": old, "new": cur}
+}
+
+func normalizeJSON(j models.JSON) map[string]any {
+	out := map[string]any{}
+	if b, err := json.Marshal(j); err == nil {
+		_ = json.Unmarshal(b, &out)
+	}
+
+	return out
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/knadh/listmonk/models"
+	"github.com/labstack/echo/v4"
+	"github.com/lib/pq"
+)
+
+// reAttribName is the allowed format of attribute names, which are used as
+// JSON keys in subscriber attributes and queries (subscribers.attribs->>'name').
+var reAttribName = regexp.MustCompile(`^[a-zA-Z0-9_\-]+$`)
+
+// GetAttribSchema handles retrieval of the subscriber attribute schema.
+func (a *App) GetAttribSchema(c echo.Context) error {
+	out, err := a.core.GetAttribSchema()
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, okResp{out})
+}
+
+// CreateAttribField handles the addition of an attribute to the subscriber
+// attribute schema.
+func (a *App) CreateAttribField(c echo.Context) error {
+	var o models.AttribField
+	if err := c.Bind(&o); err != nil {
+		return err
+	}
+
+	if err := a.validateAttribField(&o); err != nil {
+		return err
+	}
+
+	out, err := a.core.CreateAttribField(o)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, okResp{out})
+}
+
+// UpdateAttribField handles the modification of an attribute in the subscriber
+// attribute schema.
+func (a *App) UpdateAttribField(c echo.Context) error {
+	var o models.AttribField
+	if err := c.Bind(&o); err != nil {
+		return err
+	}
+
+	if err := a.validateAttribField(&o); err != nil {
+		return err
+	}
+
+	out, err := a.core.UpdateAttribField(getID(c), o)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, okResp{out})
+}
+
+// DeleteAttribField handles the removal of an attribute from the subscriber
+// attribute schema.
+func (a *App) DeleteAttribField(c echo.Context) error {
+	if err := a.core.DeleteAttribField(getID(c)); err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, okResp{true})
+}
+
+// validateAttribField validates an attribute in the schema.
+func (a *App) validateAttribField(o *models.AttribField) error {
+	o.Name = strings.TrimSpace(o.Name)
+	if !strHasLen(o.Name, 1, stdInputMaxLen) || !reAttribName.MatchString(o.Name) {
+		return echo.NewHTTPError(http.StatusBadRequest, a.i18n.Ts("globals.messages.invalidFields", "name", "name"))
+	}
+
+	switch o.Type {
+	case models.AttribTypeString, models.AttribTypeList:
+	case models.AttribTypeNumber, models.AttribTypeBool, models.AttribTypeDate, models.AttribTypeObject:
+		if len(o.Enum) > 0 {
+			return echo.NewHTTPError(http.StatusBadRequest, a.i18n.Ts("globals.messages.invalidFields", "name", "enum"))
+		}
+	default:
+		return echo.NewHTTPError(http.StatusBadRequest, a.i18n.Ts("globals.messages.invalidFields", "name", "type"))
+	}
+
+	if !strHasLen(o.Description, 0, stdInputMaxLen) {
+		return echo.NewHTTPError(http.StatusBadRequest, a.i18n.Ts("globals.messages.invalidFields", "name", "description"))
+	}
+
+	enum := make(pq.StringArray, 0, len(o.Enum))
+	seen := make(map[string]struct{}, len(o.Enum))
+	for _, v := range o.Enum {
+		v = strings.TrimSpace(v)
+		if _, ok := seen[v]; ok || !strHasLen(v, 1, stdInputMaxLen) {
+			return echo.NewHTTPError(http.StatusBadRequest, a.i18n.Ts("globals.messages.invalidFields", "name", "enum"))
+		}
+		seen[v] = struct{}{}
+		enum = append(enum, v)
+	}
+	o.Enum = enum
+
+	// Required attributes have to be public as subscribers who sign up on the
+	// public subscription form would not be able to set them otherwise.
+	if o.Required && !o.Public {
+		return echo.NewHTTPError(http.StatusBadRequest, a.i18n.T("attribs.requiredNotPublic"))
+	}
+
+	return nil
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/knadh/listmonk/internal/auth"
+	"github.com/knadh/listmonk/internal/utils"
+	"github.com/knadh/listmonk/models"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	maxEventNameLen = 200
+	maxIngestEvents = 1000
+)
+
+// IngestEvents handles the recording of one or more subscriber events. The body
+// can either be a single event object or an array of event objects.
+func (a *App) IngestEvents(c echo.Context) error {
+	body, err := io.ReadAll(io.LimitReader(c.Request().Body, 10*1024*1024))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, a.i18n.T("globals.messages.invalidData"))
+	}
+
+	var reqs []models.SubscriberEventReq
+	if b := bytes.TrimSpace(body); len(b) > 0 && b[0] == '[' {
+		err = json.Unmarshal(b, &reqs)
+	} else {
+		var r models.SubscriberEventReq
+		err = json.Unmarshal(b, &r)
+		reqs = []models.SubscriberEventReq{r}
+	}
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, a.i18n.Ts("globals.messages.invalidFields", "name", err.Error()))
+	}
+
+	if len(reqs) == 0 || len(reqs) > maxIngestEvents {
+		return echo.NewHTTPError(http.StatusBadRequest, a.i18n.Ts("globals.messages.invalidFields", "name", "events"))
+	}
+
+	// Validate all the events before recording any.
+	for n, r := range reqs {
+		if r.SubscriberID < 1 && r.SubscriberEmail == "" {
+			return echo.NewHTTPError(http.StatusBadRequest,
+				a.i18n.Ts("globals.messages.missingFields", "name", fmt.Sprintf("[%d] subscriber_id / subscriber_email", n)))
+		}
+
+		if !strHasLen(r.Event, 1, maxEventNameLen) {
+			return echo.NewHTTPError(http.StatusBadRequest,
+				a.i18n.Ts("globals.messages.invalidFields", "name", fmt.Sprintf("[%d] event", n)))
+		}
+
+		if d := bytes.TrimSpace(r.Data); len(d) > 0 && !bytes.Equal(d, []byte("null")) && d[0] != '{' {
+			return echo.NewHTTPError(http.StatusBadRequest,
+				a.i18n.Ts("globals.messages.invalidFields", "name", fmt.Sprintf("[%d] data", n)))
+		}
+	}
+
+	for n, r := range reqs {
+		if bytes.Equal(bytes.TrimSpace(r.Data), []byte("null")) {
+			reqs[n].Data = nil
+		}
+	}
+
+	out, err := a.core.InsertSubscriberEvents(reqs)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, okResp{out})
+}
+
+// GetSubscriberEvents handles the retrieval of a subscriber's events.
+func (a *App) GetSubscriberEvents(c echo.Context) error {
+	user := auth.GetUser(c)
+
+	// Check if the user has access to at least one of the lists on the subscriber.
+	id := getID(c)
+	if err := a.hasSubPerm(user, []int{id}); err != nil {
+		return err
+	}
+
+	pg := a.pg.NewFromURL(c.Request().URL.Query())
+	out, err := a.core.GetSubscriberEvents(id, c.QueryParam("event"), pg.Offset, pg.Limit)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, okResp{out})
+}
+
+// GetAutomations handles retrieval of automations.
+func (a *App) GetAutomations(c echo.Context) error {
+	out, err := a.core.GetAutomations()
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, okResp{out})
+}
+
+// GetAutomation handles retrieval of an automation.
+func (a *App) GetAutomation(c echo.Context) error {
+	out, err := a.core.GetAutomation(getID(c))
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, okResp{out})
+}
+
+// CreateAutomation handles automation creation.
+func (a *App) CreateAutomation(c echo.Context) error {
+	var o models.Automation
+	if err := c.Bind(&o); err != nil {
+		return err
+	}
+
+	if err := a.validateAutomation(&o); err != nil {
+		return err
+	}
+
+	out, err := a.core.CreateAutomation(o)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, okResp{out})
+}
+
+// UpdateAutomation handles automation modification.
+func (a *App) UpdateAutomation(c echo.Context) error {
+	var o models.Automation
+	if err := c.Bind(&o); err != nil {
+		return err
+	}
+
+	if err := a.validateAutomation(&o); err != nil {
+		return err
+	}
+
+	out, err := a.core.UpdateAutomation(getID(c), o)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, okResp{out})
+}
+
+// DeleteAutomation handles automation deletion.
+func (a *App) DeleteAutomation(c echo.Context) error {
+	if err := a.core.DeleteAutomation(getID(c)); err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, okResp{true})
+}
+
+// validateAutomation validates automation fields and fills in defaults.
+func (a *App) validateAutomation(o *models.Automation) error {
+	if !strHasLen(o.Name, 1, stdInputMaxLen) {
+		return echo.NewHTTPError(http.StatusBadRequest, a.i18n.Ts("globals.messages.invalidFields", "name", "name"))
+	}
+
+	if !strHasLen(o.Event, 1, maxEventNameLen) {
+		return echo.NewHTTPError(http.StatusBadRequest, a.i18n.Ts("globals.messages.invalidFields", "name", "event"))
+	}
+
+	// The filter is matched against event data with JSONB containment and has to be an object.
+	if len(o.Filter) == 0 || string(o.Filter) == "null" {
+		o.Filter = []byte("{}")
+	} else if f := map[string]any{}; json.Unmarshal(o.Filter, &f) != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, a.i18n.Ts("globals.messages.invalidFields", "name", "filter"))
+	}
+
+	if o.Delay == "" {
+		o.Delay = "0"
+	} else if !utils.ValidateInterval(o.Delay) {
+		return echo.NewHTTPError(http.StatusBadRequest, a.i18n.Ts("globals.messages.invalidFields", "name", "delay"))
+	}
+
+	if !o.TemplateID.Valid {
+		return echo.NewHTTPError(http.StatusBadRequest, a.i18n.Ts("globals.messages.missingFields", "name", "template_id"))
+	}
+	tpl, err := a.core.GetTemplate(o.TemplateID.Int, true)
+	if err != nil {
+		return err
+	}
+	if tpl.Type != models.TemplateTypeTx {
+		return echo.NewHTTPError(http.StatusBadRequest, a.i18n.T("templates.txTemplateOnly"))
+	}
+
+	if o.Messenger == "" {
+		o.Messenger = emailMsgr
+	}
+	if !a.manager.HasMessenger(o.Messenger) {
+		return echo.NewHTTPError(http.StatusBadRequest, a.i18n.Ts("campaigns.fieldInvalidMessenger", "name", o.Messenger))
+	}
+
+	return nil
+}
@@ -2,11 +2,17 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
+	"log"
 	"net/http"
 	"strconv"
 	"time"
 
+	"github.com/knadh/listmonk/internal/bounce/webhooks"
+	"github.com/knadh/listmonk/internal/core"
+	"github.com/knadh/listmonk/internal/events"
 	"github.com/knadh/listmonk/models"
 	"github.com/labstack/echo/v4"
 )
@@ -55,6 +61,31 @@ func (a *App) GetBounces(c echo.Context) error {
 	return c.JSON(http.StatusOK, okResp{out})
 }
 
+// GetBounceAnalytics handles retrieval of bounces aggregated by recipient domain,
+// campaign or messenger in a date range.
+func (a *App) GetBounceAnalytics(c echo.Context) error {
+	var (
+		by   = c.QueryParam("by")
+		from = c.QueryParam("from")
+		to   = c.QueryParam("to")
+	)
+	if by == "" {
+		by = "domain"
+	}
+
+	limit, _ := strconv.Atoi(c.QueryParam("limit"))
+	if limit < 1 || limit > 1000 {
+		limit = 50
+	}
+
+	out, err := a.core.GetBounceAnalytics(by, from, to, limit)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, okResp{out})
+}
+
 // GetSubscriberBounces retrieves a subscriber's bounce records.
 func (a *App) GetSubscriberBounces(c echo.Context) error {
 	// Query and fetch bounces from the DB.
@@ -115,6 +146,21 @@ func (a *App) BlocklistBouncedSubscribers(c echo.Context) error {
 
 // BounceWebhook renders the HTML preview of a template.
 func (a *App) BounceWebhook(c echo.Context) error {
+	// The native webhook is "bounce" in the IP allowlist.
+	service := c.Param("service")
+	name := service
+	if name == "" {
+		name = "bounce"
+	}
+
+	// Forwarded headers are only trusted when the request comes from a loopback or
+	// private network address (reverse proxy) so that the allowlist can't be spoofed.
+	ip := webhookIPExtractor(c.Request())
+	if !a.bounce.IsAllowedIP(name, ip) {
+		a.rejectWebhook(name, ip, "IP not in allowlist")
+		return echo.NewHTTPError(http.StatusForbidden, a.i18n.T("bounces.webhookRejected"))
+	}
+
 	// Read the request body instead of using c.Bind() to read to save the entire raw request as meta.
 	rawReq, err := io.ReadAll(c.Request().Body)
 	if err != nil {
@@ -123,13 +169,20 @@ func (a *App) BounceWebhook(c echo.Context) error {
 	}
 
 	var (
-		service = c.Param("service")
-
 		bounces []models.Bounce
+		events  []models.DeliveryEvent
 	)
 	switch true {
 	// Native internal webhook.
 	case service == "":
+		// Verify the HMAC signature if a secret is set.
+		if a.bounce.Native != nil {
+			h := c.Request().Header
+			if err := a.bounce.Native.Verify(h.Get("X-Listmonk-Timestamp"), h.Get("X-Listmonk-Nonce"), h.Get("X-Listmonk-Signature"), rawReq); err != nil {
+				return a.webhookError(name, ip, err)
+			}
+		}
+
 		var b models.Bounce
 		if err := json.Unmarshal(rawReq, &b); err != nil {
 			return echo.NewHTTPError(http.StatusBadRequest, a.i18n.Ts("globals.messages.invalidData")+":"+err.Error())
@@ -158,18 +211,17 @@ func (a *App) BounceWebhook(c echo.Context) error {
 		// start getting bounce notifications.
 		case "SubscriptionConfirmation", "UnsubscribeConfirmation":
 			if err := a.bounce.SES.ProcessSubscription(rawReq); err != nil {
-				a.log.Printf("error processing SNS (SES) subscription: %v", err)
-				return echo.NewHTTPError(http.StatusBadRequest, a.i18n.T("globals.messages.invalidData"))
+				return a.webhookError(name, ip, err)
 			}
 
 		// Bounce notification.
 		case "Notification":
-			b, err := a.bounce.SES.ProcessBounce(rawReq)
+			bs, evs, err := a.bounce.SES.ProcessBounce(rawReq)
 			if err != nil {
-				a.log.Printf("error processing SES notification: %v", err)
-				return echo.NewHTTPError(http.StatusBadRequest, a.i18n.T("globals.messages.invalidData"))
+				return a.webhookError(name, ip, err)
 			}
-			bounces = append(bounces, b)
+			bounces = append(bounces, bs...)
+			events = append(events, evs...)
 
 		default:
 			return echo.NewHTTPError(http.StatusBadRequest, a.i18n.T("globals.messages.invalidData"))
@@ -182,26 +234,22 @@ func (a *App) BounceWebhook(c echo.Context) error {
 			ts  = c.Request().Header.Get("X-Twilio-Email-Event-Webhook-Timestamp")
 		)
 
-		// Sendgrid sends multiple bounces.
-		bs, err := a.bounce.Sendgrid.ProcessBounce(sig, ts, rawReq)
+		// Sendgrid sends multiple events.
+		bs, evs, err := a.bounce.Sendgrid.ProcessBounce(sig, ts, rawReq)
 		if err != nil {
-			a.log.Printf("error processing sendgrid notification: %v", err)
-			return echo.NewHTTPError(http.StatusBadRequest, a.i18n.T("globals.messages.invalidData"))
+			return a.webhookError(name, ip, err)
 		}
 		bounces = append(bounces, bs...)
+		events = append(events, evs...)
 
 	// Postmark.
 	case service == "postmark" && a.cfg.BouncePostmarkEnabled:
-		bs, err := a.bounce.Postmark.ProcessBounce(rawReq, c)
+		bs, evs, err := a.bounce.Postmark.ProcessBounce(rawReq, c)
 		if err != nil {
-			a.log.Printf("error processing postmark notification: %v", err)
-			if _, ok := err.(*echo.HTTPError); ok {
-				return err
-			}
-
-			return echo.NewHTTPError(http.StatusBadRequest, a.i18n.T("globals.messages.invalidData"))
+			return a.webhookError(name, ip, err)
 		}
 		bounces = append(bounces, bs...)
+		events = append(events, evs...)
 
 	// ForwardEmail.
 	case service == "forwardemail" && a.cfg.BounceForwardemailEnabled:
@@ -220,6 +268,55 @@ func (a *App) BounceWebhook(c echo.Context) error {
 		}
 		bounces = append(bounces, bs...)
 
+	// Mailgun.
+	case service == "mailgun" && a.cfg.BounceMailgunEnabled && a.bounce.Mailgun != nil:
+		bs, evs, err := a.bounce.Mailgun.ProcessBounce(rawReq)
+		if err != nil {
+			return a.webhookError(name, ip, err)
+		}
+		bounces = append(bounces, bs...)
+		events = append(events, evs...)
+
+	// SparkPost.
+	case service == "sparkpost" && a.cfg.BounceSparkPostEnabled && a.bounce.SparkPost != nil:
+		bs, evs, err := a.bounce.SparkPost.ProcessBounce(rawReq, c)
+		if err != nil {
+			return a.webhookError(name, ip, err)
+		}
+		bounces = append(bounces, bs...)
+		events = append(events, evs...)
+
+	// Brevo (Sendinblue).
+	case service == "brevo" && a.cfg.BounceBrevoEnabled && a.bounce.Brevo != nil:
+		bs, evs, err := a.bounce.Brevo.ProcessBounce(rawReq, c)
+		if err != nil {
+			return a.webhookError(name, ip, err)
+		}
+		bounces = append(bounces, bs...)
+		events = append(events, evs...)
+
+	// Mailjet.
+	case service == "mailjet" && a.cfg.BounceMailjetEnabled && a.bounce.Mailjet != nil:
+		bs, evs, err := a.bounce.Mailjet.ProcessBounce(rawReq, c)
+		if err != nil {
+			return a.webhookError(name, ip, err)
+		}
+		bounces = append(bounces, bs...)
+		events = append(events, evs...)
+
+	// Resend.
+	case service == "resend" && a.cfg.BounceResendEnabled && a.bounce.Resend != nil:
+		var (
+			h = c.Request().Header
+		)
+
+		bs, evs, err := a.bounce.Resend.ProcessBounce(h.Get("Svix-Id"), h.Get("Svix-Timestamp"), h.Get("Svix-Signature"), rawReq)
+		if err != nil {
+			return a.webhookError(name, ip, err)
+		}
+		bounces = append(bounces, bs...)
+		events = append(events, evs...)
+
 	default:
 		return echo.NewHTTPError(http.StatusBadRequest, a.i18n.Ts("bounces.unknownService"))
 	}
@@ -231,9 +328,48 @@ func (a *App) BounceWebhook(c echo.Context) error {
 		}
 	}
 
+	// Record delivery events (deliveries, opens, clicks).
+	for _, e := range events {
+		if err := a.bounce.RecordEvent(e); err != nil {
+			a.log.Printf("error recording delivery event: %v", err)
+		}
+	}
+
 	return c.JSON(http.StatusOK, okResp{true})
 }
 
+// webhookIPExtractor returns the IP of a webhook request.
+var webhookIPExtractor = echo.ExtractIPFromXFFHeader()
+
+// webhookError handles an error from processing a webhook request. Requests that
+// fail authentication are rejected and other errors are invalid data.
+func (a *App) webhookError(service, ip string, err error) error {
+	var authErr *webhooks.AuthError
+	if errors.As(err, &authErr) {
+		a.rejectWebhook(service, ip, err.Error())
+		return echo.NewHTTPError(http.StatusUnauthorized, a.i18n.T("bounces.webhookRejected"))
+	}
+
+	a.log.Printf("error processing %s notification: %v", service, err)
+	return echo.NewHTTPError(http.StatusBadRequest, a.i18n.T("globals.messages.invalidData"))
+}
+
+// rejectWebhook logs a rejected webhook request and publishes it to the event stream.
+func (a *App) rejectWebhook(service, ip, reason string) {
+	msg := fmt.Sprintf("rejected %s webhook request from %s: %s", service, ip, reason)
+	a.log.Println(msg)
+
+	a.events.Publish(events.Event{
+		Type:    events.TypeWebhookRejected,
+		Message: msg,
+		Data: map[string]string{
+			"service": service,
+			"ip":      ip,
+			"reason":  reason,
+		},
+	})
+}
+
 func (a *App) validateBounceFields(b models.Bounce) (models.Bounce, error) {
 	if b.Email == "" && b.SubscriberUUID == "" {
 		return b, echo.NewHTTPError(http.StatusBadRequest, a.i18n.Ts("globals.messages.invalidFields", "name", "email / subscriber_uuid"))
@@ -257,3 +393,18 @@ func (a *App) validateBounceFields(b models.Bounce) (models.Bounce, error) {
 
 	return b, nil
 }
+
+// runBounceDecay periodically deletes soft bounce records that are older than
+// the given number of days. Older soft bounces stop counting towards the bounce actions.
+func runBounceDecay(co *core.Core, days int, lo *log.Logger) {
+	for {
+		n, err := co.DecayBounces(time.Now().AddDate(0, 0, -days))
+		if err != nil {
+			lo.Printf("error deleting old bounces: %v", err)
+		} else if n > 0 {
+			lo.Printf("deleted %d soft bounces older than %d days", n, days)
+		}
+
+		time.Sleep(time.Hour)
+	}
+}
@@ -288,6 +288,11 @@ func (a *App) CreateCampaign(c echo.Context) error {
 		return err
 	}
 
+	// Record the initial content as the first revision. The campaign has been
+	// created regardless. Errors are logged.
+	user := auth.GetUser(c)
+	_ = a.core.SaveCampaignRevision(out.ID, user.ID, user.Username)
+
 	return c.JSON(http.StatusOK, okResp{out})
 }
 
@@ -331,6 +336,11 @@ func (a *App) UpdateCampaign(c echo.Context) error {
 		return err
 	}
 
+	// Record the changes as a revision. The campaign has been updated
+	// regardless. Errors are logged.
+	user := auth.GetUser(c)
+	_ = a.core.SaveCampaignRevision(id, user.ID, user.Username)
+
 	return c.JSON(http.StatusOK, okResp{out})
 }
 
@@ -410,8 +420,17 @@ func (a *App) DeleteCampaign(c echo.Context) error {
 		return err
 	}
 
-	// Delete the campaign from the DB.
-	if err := a.core.DeleteCampaign(id); err != nil {
+	// Move the campaign to the trash, or delete it if the trash is disabled.
+	var err error
+	if a.cfg.TrashRetentionDays > 0 {
+		if err = a.core.TrashCampaign(id); err == nil {
+			// A trashed campaign is paused. Stop it in flight if it's running.
+			a.manager.StopCampaign(id)
+		}
+	} else {
+		err = a.core.DeleteCampaign(id)
+	}
+	if err != nil {
 		return err
 	}
 
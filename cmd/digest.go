@@ -0,0 +1,121 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/knadh/listmonk/internal/auth"
+	"github.com/knadh/listmonk/internal/digest"
+	"github.com/knadh/listmonk/models"
+	"github.com/labstack/echo/v4"
+)
+
+// GetCampaignFeed returns the feed of a digest campaign.
+func (a *App) GetCampaignFeed(c echo.Context) error {
+	id := getID(c)
+
+	// Check if the user has access to the campaign.
+	if err := a.checkCampaignPerm(auth.PermTypeGet, id, c); err != nil {
+		return err
+	}
+
+	out, err := a.core.GetCampaignFeed(id)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, okResp{out})
+}
+
+// UpdateCampaignFeed creates or updates the feed of a digest campaign.
+func (a *App) UpdateCampaignFeed(c echo.Context) error {
+	id := getID(c)
+
+	// Check if the user has access to the campaign.
+	if err := a.checkCampaignPerm(auth.PermTypeManage, id, c); err != nil {
+		return err
+	}
+
+	camp, err := a.core.GetCampaign(id, "", "")
+	if err != nil {
+		return err
+	}
+	if camp.Type != models.CampaignTypeDigest {
+		return echo.NewHTTPError(http.StatusBadRequest, a.i18n.Ts("globals.messages.invalidFields", "name", "type"))
+	}
+
+	var o models.CampaignFeed
+	if err := c.Bind(&o); err != nil {
+		return err
+	}
+	o.CampaignID = id
+
+	// Validate.
+	if u, err := url.Parse(o.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, a.i18n.T("campaigns.feedInvalidURL"))
+	}
+	if o.PollInterval == "" {
+		o.PollInterval = "1h"
+	}
+	if d, err := time.ParseDuration(o.PollInterval); err != nil || d < time.Minute {
+		return echo.NewHTTPError(http.StatusBadRequest, a.i18n.T("campaigns.feedInvalidInterval"))
+	}
+	if o.MaxItems < 1 {
+		o.MaxItems = 10
+	}
+
+	out, err := a.core.UpsertCampaignFeed(o)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, okResp{out})
+}
+
+// DeleteCampaignFeed deletes the feed of a digest campaign.
+func (a *App) DeleteCampaignFeed(c echo.Context) error {
+	id := getID(c)
+
+	// Check if the user has access to the campaign.
+	if err := a.checkCampaignPerm(auth.PermTypeManage, id, c); err != nil {
+		return err
+	}
+
+	if err := a.core.DeleteCampaignFeed(id); err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, okResp{true})
+}
+
+// RunCampaignFeed checks the feed of a digest campaign immediately and if
+// there are new items, creates and starts a campaign from them.
+func (a *App) RunCampaignFeed(c echo.Context) error {
+	id := getID(c)
+
+	// Check if the user has access to the campaign.
+	if err := a.checkCampaignPerm(auth.PermTypeManage, id, c); err != nil {
+		return err
+	}
+
+	newID, err := a.digest.Process(id)
+	if err != nil {
+		if err == digest.ErrNoNewItems {
+			return echo.NewHTTPError(http.StatusBadRequest, a.i18n.T("campaigns.feedNoNewItems"))
+		}
+
+		// HTTP errors from core are passed as-is.
+		if _, ok := err.(*echo.HTTPError); ok {
+			return err
+		}
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	out, err := a.core.GetCampaign(newID, "", "")
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, okResp{out})
+}
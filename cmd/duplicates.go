@@ -0,0 +1,130 @@
+package main
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/knadh/listmonk/internal/auth"
+	"github.com/knadh/listmonk/models"
+	"github.com/labstack/echo/v4"
+)
+
+// FindDuplicateSubscribers handles the retrieval of groups of subscribers whose
+// e-mails are the same after normalization by the rules in the query params.
+func (a *App) FindDuplicateSubscribers(c echo.Context) error {
+	rules, err := a.getDuplicateRules(c)
+	if err != nil {
+		return err
+	}
+
+	pg := a.pg.NewFromURL(c.Request().URL.Query())
+	out, total, err := a.core.FindDuplicateSubscribers(rules, pg.Offset, pg.Limit)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, okResp{models.PageResults{
+		Results: out,
+		Total:   total,
+		Page:    pg.Page,
+		PerPage: pg.PerPage,
+	}})
+}
+
+// MergeSubscribers handles the merging of duplicate subscribers into a subscriber.
+func (a *App) MergeSubscribers(c echo.Context) error {
+	var req struct {
+		IDs []int `json:"ids"`
+	}
+	if err := c.Bind(&req); err != nil {
+		return err
+	}
+
+	id := getID(c)
+	subIDs := make([]int, 0, len(req.IDs))
+	for _, sid := range req.IDs {
+		if sid < 1 || sid == id {
+			return echo.NewHTTPError(http.StatusBadRequest, a.i18n.Ts("globals.messages.invalidFields", "name", "ids"))
+		}
+		if !slices.Contains(subIDs, sid) {
+			subIDs = append(subIDs, sid)
+		}
+	}
+	if len(subIDs) == 0 {
+		return echo.NewHTTPError(http.StatusBadRequest, a.i18n.T("subscribers.errorNoIDs"))
+	}
+
+	// Check if the user has access to the subscribers.
+	user := auth.GetUser(c)
+	if err := a.hasSubPerm(user, append([]int{id}, subIDs...)); err != nil {
+		return err
+	}
+
+	out, err := a.core.MergeSubscribers(id, subIDs, user.ID)
+	if err != nil {
+		return err
+	}
+	a.recordActivity(c, []int{id}, nil, models.ActivityMerged, models.JSON{"subscriber_ids": subIDs})
+
+	return c.JSON(http.StatusOK, okResp{out})
+}
+
+// GetSubscriberMerges handles the retrieval of subscriber merge records.
+func (a *App) GetSubscriberMerges(c echo.Context) error {
+	subID, _ := strconv.Atoi(c.QueryParam("subscriber_id"))
+
+	pg := a.pg.NewFromURL(c.Request().URL.Query())
+	out, total, err := a.core.QuerySubscriberMerges(subID, pg.Offset, pg.Limit)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, okResp{models.PageResults{
+		Results: out,
+		Total:   total,
+		Page:    pg.Page,
+		PerPage: pg.PerPage,
+	}})
+}
+
+// getDuplicateRules returns the e-mail normalization rules from the query
+// params. The case, +tag and Gmail rules are on unless turned off.
+func (a *App) getDuplicateRules(c echo.Context) (models.DuplicateRules, error) {
+	out := models.DuplicateRules{
+		IgnoreCase:     true,
+		PlusAddressing: true,
+		GmailDots:      true,
+		DomainAliases:  map[string]string{},
+	}
+
+	for name, v := range map[string]*bool{
+		"ignore_case":     &out.IgnoreCase,
+		"plus_addressing": &out.PlusAddressing,
+		"gmail_dots":      &out.GmailDots,
+	} {
+		s := c.QueryParam(name)
+		if s == "" {
+			continue
+		}
+
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return out, echo.NewHTTPError(http.StatusBadRequest, a.i18n.Ts("globals.messages.invalidFields", "name", name))
+		}
+		*v = b
+	}
+
+	// Domain aliases are in the form alias:domain, eg: example.net:example.com.
+	for _, s := range c.QueryParams()["domain_alias"] {
+		from, to, ok := strings.Cut(s, ":")
+		from, to = strings.ToLower(strings.TrimSpace(from)), strings.ToLower(strings.TrimSpace(to))
+		if !ok || from == "" || to == "" {
+			return out, echo.NewHTTPError(http.StatusBadRequest, a.i18n.Ts("globals.messages.invalidFields", "name", "domain_alias"))
+		}
+		out.DomainAliases[from] = to
+	}
+
+	return out, nil
+}
@@ -108,6 +108,7 @@ func initHTTPHandlers(e *echo.Echo, a *App) {
 		g.GET("/api/settings", pm(a.GetSettings, "settings:get"))
 		g.PUT("/api/settings", pm(a.UpdateSettings, "settings:manage"))
 		g.POST("/api/settings/smtp/test", pm(a.TestSMTPSettings, "settings:manage"))
+		g.POST("/api/settings/smtp/check-dns", pm(a.CheckSMTPDNS, "settings:manage"))
 		g.POST("/api/admin/reload", pm(a.ReloadApp, "settings:manage"))
 		g.GET("/api/logs", pm(a.GetLogs, "settings:get"))
 		g.GET("/api/events", pm(a.EventStream, "settings:get"))
@@ -115,8 +116,18 @@ func initHTTPHandlers(e *echo.Echo, a *App) {
 
 		g.GET("/api/subscribers", pm(a.QuerySubscribers, "subscribers:get_all", "subscribers:get"))
 		g.GET("/api/subscribers/:id", pm(hasID(a.GetSubscriber), "subscribers:get_all", "subscribers:get"))
+		g.GET("/api/subscribers/attribs", pm(a.GetAttribSchema, "subscribers:get_all", "subscribers:get"))
+		g.POST("/api/subscribers/attribs", pm(a.CreateAttribField, "subscribers:manage"))
+		g.PUT("/api/subscribers/attribs/:id", pm(hasID(a.UpdateAttribField), "subscribers:manage"))
+		g.DELETE("/api/subscribers/attribs/:id", pm(hasID(a.DeleteAttribField), "subscribers:manage"))
+		g.GET("/api/subscribers/duplicates", pm(a.FindDuplicateSubscribers, "subscribers:get_all"))
+		g.GET("/api/subscribers/merges", pm(a.GetSubscriberMerges, "subscribers:get_all"))
+		g.GET("/api/subscribers/unsubscriptions", pm(a.GetUnsubscriptions, "subscribers:get_all"))
+		g.POST("/api/subscribers/:id/merge", pm(hasID(a.MergeSubscribers), "subscribers:manage"))
 		g.GET("/api/subscribers/:id/export", pm(hasID(a.ExportSubscriberData), "subscribers:get_all", "subscribers:get"))
 		g.GET("/api/subscribers/:id/bounces", pm(hasID(a.GetSubscriberBounces), "bounces:get"))
+		g.GET("/api/subscribers/:id/events", pm(hasID(a.GetSubscriberEvents), "subscribers:get_all", "subscribers:get"))
+		g.GET("/api/subscribers/:id/activity", pm(hasID(a.GetSubscriberActivity), "subscribers:get_all", "subscribers:get"))
 		g.DELETE("/api/subscribers/:id/bounces", pm(hasID(a.DeleteSubscriberBounces), "bounces:manage"))
 		g.POST("/api/subscribers", pm(a.CreateSubscriber, "subscribers:manage"))
 		g.PUT("/api/subscribers/:id", pm(hasID(a.UpdateSubscriber), "subscribers:manage"))
@@ -129,6 +140,7 @@ func initHTTPHandlers(e *echo.Echo, a *App) {
 		g.DELETE("/api/subscribers", pm(a.DeleteSubscribers, "subscribers:manage"))
 
 		g.GET("/api/bounces", pm(a.GetBounces, "bounces:get"))
+		g.GET("/api/bounces/analytics", pm(a.GetBounceAnalytics, "bounces:get"))
 		g.PUT("/api/bounces/blocklist", pm(a.BlocklistBouncedSubscribers, "bounces:manage"))
 		g.GET("/api/bounces/:id", pm(hasID(a.GetBounce), "bounces:get"))
 		g.DELETE("/api/bounces", pm(a.DeleteBounces, "bounces:manage"))
@@ -144,8 +156,27 @@ func initHTTPHandlers(e *echo.Echo, a *App) {
 
 		g.GET("/api/import/subscribers", pm(a.GetImportSubscribers, "subscribers:import"))
 		g.GET("/api/import/subscribers/logs", pm(a.GetImportSubscriberStats, "subscribers:import"))
+		g.GET("/api/import/subscribers/errors", pm(a.GetImportSubscriberErrors, "subscribers:import"))
 		g.POST("/api/import/subscribers", pm(a.ImportSubscribers, "subscribers:import"))
 		g.DELETE("/api/import/subscribers", pm(a.StopImportSubscribers, "subscribers:import"))
+		g.GET("/api/import/jobs", pm(a.GetImportJobs, "subscribers:import", "subscribers:import_all"))
+		g.GET("/api/import/jobs/:id", pm(hasID(a.GetImportJob), "subscribers:import", "subscribers:import_all"))
+		g.GET("/api/import/jobs/:id/logs", pm(hasID(a.GetImportJobLogs), "subscribers:import", "subscribers:import_all"))
+		g.GET("/api/import/jobs/:id/errors", pm(hasID(a.GetImportJobErrors), "subscribers:import", "subscribers:import_all"))
+		g.POST("/api/import/jobs/:id/stop", pm(hasID(a.StopImportJob), "subscribers:import", "subscribers:import_all"))
+		g.DELETE("/api/import/jobs/:id", pm(hasID(a.DeleteImportJob), "subscribers:import", "subscribers:import_all"))
+		g.GET("/api/import/presets", pm(a.GetImportPresets, "subscribers:import"))
+		g.POST("/api/import/presets", pm(a.CreateImportPreset, "subscribers:import"))
+		g.PUT("/api/import/presets/:id", pm(hasID(a.UpdateImportPreset), "subscribers:import"))
+		g.DELETE("/api/import/presets/:id", pm(hasID(a.DeleteImportPreset), "subscribers:import"))
+
+		g.GET("/api/sync/sources", pm(a.GetSyncSources, "subscribers:sync"))
+		g.GET("/api/sync/sources/:id", pm(hasID(a.GetSyncSource), "subscribers:sync"))
+		g.POST("/api/sync/sources", pm(a.CreateSyncSource, "subscribers:sync"))
+		g.PUT("/api/sync/sources/:id", pm(hasID(a.UpdateSyncSource), "subscribers:sync"))
+		g.DELETE("/api/sync/sources/:id", pm(hasID(a.DeleteSyncSource), "subscribers:sync"))
+		g.POST("/api/sync/sources/:id/run", pm(hasID(a.RunSyncSource), "subscribers:sync"))
+		g.GET("/api/sync/sources/:id/runs", pm(hasID(a.GetSyncRuns), "subscribers:sync"))
 
 		// Individual list permissions are applied directly within handleGetLists.
 		g.GET("/api/lists", a.GetLists)
@@ -169,6 +200,29 @@ func initHTTPHandlers(e *echo.Echo, a *App) {
 		g.PUT("/api/campaigns/:id/status", pm(hasID(a.UpdateCampaignStatus), "campaigns:manage_all", "campaigns:manage"))
 		g.PUT("/api/campaigns/:id/archive", pm(hasID(a.UpdateCampaignArchive), "campaigns:manage_all", "campaigns:manage"))
 		g.DELETE("/api/campaigns/:id", pm(hasID(a.DeleteCampaign), "campaigns:manage_all", "campaigns:manage"))
+		g.GET("/api/campaigns/:id/feed", pm(hasID(a.GetCampaignFeed), "campaigns:get_all", "campaigns:get"))
+		g.PUT("/api/campaigns/:id/feed", pm(hasID(a.UpdateCampaignFeed), "campaigns:manage_all", "campaigns:manage"))
+		g.DELETE("/api/campaigns/:id/feed", pm(hasID(a.DeleteCampaignFeed), "campaigns:manage_all", "campaigns:manage"))
+		g.POST("/api/campaigns/:id/feed/run", pm(hasID(a.RunCampaignFeed), "campaigns:manage_all", "campaigns:manage"))
+		g.GET("/api/campaigns/:id/revisions", pm(hasID(a.GetCampaignRevisions), "campaigns:get_all", "campaigns:get"))
+		g.GET("/api/campaigns/:id/revisions/:version", pm(hasID(a.GetCampaignRevision), "campaigns:get_all", "campaigns:get"))
+		g.GET("/api/campaigns/:id/revisions/:version/diff", pm(hasID(a.DiffCampaignRevision), "campaigns:get_all", "campaigns:get"))
+		g.POST("/api/campaigns/:id/revisions/:version/restore", pm(hasID(a.RestoreCampaignRevision), "campaigns:manage_all", "campaigns:manage"))
+
+		g.GET("/api/sequences", pm(a.GetSequences, "sequences:get"))
+		g.GET("/api/sequences/:id", pm(hasID(a.GetSequence), "sequences:get"))
+		g.POST("/api/sequences", pm(a.CreateSequence, "sequences:manage"))
+		g.PUT("/api/sequences/:id", pm(hasID(a.UpdateSequence), "sequences:manage"))
+		g.DELETE("/api/sequences/:id", pm(hasID(a.DeleteSequence), "sequences:manage"))
+		g.POST("/api/sequences/:id/subscribers", pm(hasID(a.AddSequenceSubscribers), "sequences:manage"))
+		g.DELETE("/api/sequences/:id/subscribers", pm(hasID(a.DeleteSequenceSubscribers), "sequences:manage"))
+
+		g.POST("/api/events/ingest", pm(a.IngestEvents, "events:ingest"))
+		g.GET("/api/automations", pm(a.GetAutomations, "automations:get"))
+		g.GET("/api/automations/:id", pm(hasID(a.GetAutomation), "automations:get"))
+		g.POST("/api/automations", pm(a.CreateAutomation, "automations:manage"))
+		g.PUT("/api/automations/:id", pm(hasID(a.UpdateAutomation), "automations:manage"))
+		g.DELETE("/api/automations/:id", pm(hasID(a.DeleteAutomation), "automations:manage"))
 
 		g.GET("/api/media", pm(a.GetAllMedia, "media:get"))
 		g.GET("/api/media/:id", pm(hasID(a.GetMedia), "media:get"))
@@ -187,6 +241,12 @@ func initHTTPHandlers(e *echo.Echo, a *App) {
 		g.DELETE("/api/maintenance/subscribers/:type", pm(a.GCSubscribers, "settings:maintain"))
 		g.DELETE("/api/maintenance/analytics/:type", pm(a.GCCampaignAnalytics, "settings:maintain"))
 		g.DELETE("/api/maintenance/subscriptions/unconfirmed", pm(a.GCSubscriptions, "settings:maintain"))
+		g.DELETE("/api/maintenance/trash", pm(a.GCTrash, "settings:maintain"))
+
+		// Permissions for the trash are checked in the handlers as they depend on the type.
+		g.GET("/api/trash/:type", a.GetTrash)
+		g.PUT("/api/trash/:type/restore", a.RestoreTrash)
+		g.DELETE("/api/trash/:type", a.PurgeTrash)
 
 		g.POST("/api/tx", pm(a.SendTxMessage, "tx:send"))
 
@@ -241,6 +301,7 @@ func initHTTPHandlers(e *echo.Echo, a *App) {
 
 		// Public APIs.
 		g.GET("/api/public/lists", a.GetPublicLists)
+		g.GET("/api/public/attribs", a.GetPublicAttribs)
 		g.POST("/api/public/subscription", a.PublicSubscription)
 		g.GET("/api/public/captcha/altcha", a.AltchaChallenge)
 		if a.cfg.EnablePublicArchive {
@@ -253,6 +314,7 @@ func initHTTPHandlers(e *echo.Echo, a *App) {
 		g.POST("/subscription/form", a.SubscriptionForm)
 		g.GET("/subscription/:campUUID/:subUUID", noIndex(a.hasUUID(a.hasSub(a.SubscriptionPage), "campUUID", "subUUID")))
 		g.POST("/subscription/:campUUID/:subUUID", a.hasUUID(a.hasSub(a.SubscriptionPrefs), "campUUID", "subUUID"))
+		g.POST("/subscription/unsubscribe/:campUUID/:subUUID", a.OneClickUnsubscribe)
 		g.GET("/subscription/optin/:subUUID", noIndex(a.hasUUID(a.hasSub(a.OptinPage), "subUUID")))
 		g.POST("/subscription/optin/:subUUID", a.hasUUID(a.hasSub(a.OptinPage), "subUUID"))
 		g.POST("/subscription/export/:subUUID", a.hasUUID(a.hasSub(a.SelfExportSubscriberData), "subUUID"))
@@ -4,22 +4,23 @@ import (
 	"encoding/json"
 	"io"
 	"net/http"
+	"net/url"
 	"os"
-	"strings"
+	"path"
 
+	"github.com/knadh/listmonk/internal/auth"
 	"github.com/knadh/listmonk/internal/subimporter"
 	"github.com/knadh/listmonk/models"
 	"github.com/labstack/echo/v4"
 )
 
-// ImportSubscribers handles the uploading and bulk importing of
-// a ZIP file of one or more CSV files.
-func (a *App) ImportSubscribers(c echo.Context) error {
-	// Is an import already running?
-	if a.importer.GetStats().Status == subimporter.StatusImporting {
-		return echo.NewHTTPError(http.StatusBadRequest, a.i18n.T("import.alreadyRunning"))
-	}
+// importErrorsFilename is the name of the downloadable CSV of rejected rows.
+const importErrorsFilename = "import-errors.csv"
 
+// ImportSubscribers handles the uploading of a CSV or JSONL file, or a ZIP
+// of one, and queues it as an import job. Instead of an upload, the file can
+// also be fetched from a URL or the media store when the job runs.
+func (a *App) ImportSubscribers(c echo.Context) error {
 	// Unmarshal the JSON params.
 	var opt subimporter.SessionOpt
 	if err := json.Unmarshal([]byte(c.FormValue("params")), &opt); err != nil {
@@ -28,7 +29,13 @@ func (a *App) ImportSubscribers(c echo.Context) error {
 	}
 
 	// Validate mode.
-	if opt.Mode != subimporter.ModeSubscribe && opt.Mode != subimporter.ModeBlocklist {
+	switch opt.Mode {
+	case subimporter.ModeSubscribe, subimporter.ModeBlocklist, subimporter.ModeDelete, subimporter.ModeUpdate:
+	case subimporter.ModeUnsubscribe:
+		if len(opt.ListIDs) == 0 {
+			return echo.NewHTTPError(http.StatusBadRequest, a.i18n.T("import.invalidLists"))
+		}
+	default:
 		return echo.NewHTTPError(http.StatusBadRequest, a.i18n.T("import.invalidMode"))
 	}
 
@@ -37,7 +44,7 @@ func (a *App) ImportSubscribers(c echo.Context) error {
 		switch opt.Mode {
 		case subimporter.ModeSubscribe:
 			opt.SubStatus = models.SubscriptionStatusUnconfirmed
-		case subimporter.ModeBlocklist:
+		default:
 			opt.SubStatus = models.SubscriptionStatusUnsubscribed
 		}
 	}
@@ -48,10 +55,41 @@ func (a *App) ImportSubscribers(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusBadRequest, a.i18n.T("import.invalidSubStatus"))
 	}
 
+	// The delimiter only applies to CSVs.
+	if opt.Delim == "" {
+		opt.Delim = ","
+	}
 	if len(opt.Delim) != 1 {
 		return echo.NewHTTPError(http.StatusBadRequest, a.i18n.T("import.invalidDelim"))
 	}
 
+	switch opt.Format {
+	case "", subimporter.FormatCSV, subimporter.FormatJSON, subimporter.FormatJSONL:
+	case "ndjson":
+		opt.Format = subimporter.FormatJSONL
+	default:
+		return echo.NewHTTPError(http.StatusBadRequest, a.i18n.Ts("globals.messages.invalidFields", "name", "format"))
+	}
+
+	// Use the saved mapping of a preset.
+	if len(opt.Mapping) == 0 && opt.PresetID > 0 {
+		p, err := a.core.GetImportPreset(opt.PresetID)
+		if err != nil {
+			return err
+		}
+		opt.Mapping = p.Mapping
+	}
+	if len(opt.Mapping) > 0 {
+		if err := subimporter.ValidateMapping(opt.Mapping); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, a.i18n.Ts("import.invalidParams", "error", err.Error()))
+		}
+	}
+
+	// Remote file. It's fetched when the job runs.
+	if opt.URL != "" || opt.MediaKey != "" {
+		return a.importRemoteFile(opt, c)
+	}
+
 	// Open the HTTP file.
 	file, err := c.FormFile("file")
 	if err != nil {
@@ -65,7 +103,7 @@ func (a *App) ImportSubscribers(c echo.Context) error {
 	}
 	defer src.Close()
 
-	// Copy it to a temp location.
+	// Copy it to a temp location where it stays until the job is processed.
 	out, err := os.CreateTemp("", "listmonk")
 	if err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError,
@@ -74,55 +112,353 @@ func (a *App) ImportSubscribers(c echo.Context) error {
 	defer out.Close()
 
 	if _, err = io.Copy(out, src); err != nil {
+		os.Remove(out.Name())
 		return echo.NewHTTPError(http.StatusInternalServerError,
 			a.i18n.Ts("import.errorCopyingFile", "error", err.Error()))
 	}
 
-	// Start the importer session.
+	// Queue the import job.
 	opt.Filename = file.Filename
-	sess, err := a.importer.NewSession(opt)
+	params, err := json.Marshal(opt)
 	if err != nil {
+		os.Remove(out.Name())
 		return echo.NewHTTPError(http.StatusInternalServerError,
 			a.i18n.Ts("import.errorStarting", "error", err.Error()))
 	}
-	go sess.Start()
 
-	if strings.HasSuffix(strings.ToLower(file.Filename), ".csv") {
-		go sess.LoadCSV(out.Name(), rune(opt.Delim[0]))
-	} else {
-		// Only 1 CSV from the ZIP is considered. If multiple files have
-		// to be processed, counting the net number of lines (to track progress),
-		// keeping the global import state (failed / successful) etc. across
-		// multiple files becomes complex. Instead, it's just easier for the
-		// end user to concat multiple CSVs (if there are multiple in the first)
-		// place and upload as one in the first place.
-		dir, files, err := sess.ExtractZIP(out.Name(), 1)
-		if err != nil {
-			return echo.NewHTTPError(http.StatusInternalServerError,
-				a.i18n.Ts("import.errorProcessingZIP", "error", err.Error()))
+	user := auth.GetUser(c)
+	job, err := a.core.CreateImportJob(user.ID, file.Filename, params, out.Name())
+	if err != nil {
+		os.Remove(out.Name())
+		return err
+	}
+	a.importer.Queue()
+
+	return c.JSON(http.StatusOK, okResp{job})
+}
+
+// importRemoteFile queues an import job for a file at an HTTP(S) URL or an
+// object in the media store.
+func (a *App) importRemoteFile(opt subimporter.SessionOpt, c echo.Context) error {
+	if opt.URL != "" && opt.MediaKey != "" {
+		return echo.NewHTTPError(http.StatusBadRequest, a.i18n.Ts("globals.messages.invalidFields", "name", "url"))
+	}
+
+	if opt.URL != "" {
+		u, err := url.Parse(opt.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return echo.NewHTTPError(http.StatusBadRequest, a.i18n.Ts("globals.messages.invalidFields", "name", "url"))
 		}
+		opt.Filename = path.Base(u.Path)
+	} else {
+		opt.Filename = path.Base(opt.MediaKey)
+	}
+
+	// Without an extension, the format can't be derived.
+	if opt.Format == "" && path.Ext(opt.Filename) == "" {
+		opt.Format = subimporter.FormatCSV
+	}
+
+	params, err := json.Marshal(opt)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError,
+			a.i18n.Ts("import.errorStarting", "error", err.Error()))
+	}
 
-		go sess.LoadCSV(dir+"/"+files[0], rune(opt.Delim[0]))
+	user := auth.GetUser(c)
+	job, err := a.core.CreateImportJob(user.ID, opt.Filename, params, "")
+	if err != nil {
+		return err
 	}
+	a.importer.Queue()
 
-	return c.JSON(http.StatusOK, okResp{a.importer.GetStats()})
+	return c.JSON(http.StatusOK, okResp{job})
 }
 
-// GetImportSubscribers returns import statistics.
+// GetImportSubscribers returns the stats of the latest import job
+// that hasn't been cleared.
 func (a *App) GetImportSubscribers(c echo.Context) error {
-	s := a.importer.GetStats()
-	return c.JSON(http.StatusOK, okResp{s})
+	job, err := a.core.GetImportJob(0, importUserID(c))
+	if err != nil {
+		if e, ok := err.(*echo.HTTPError); ok && e.Code == http.StatusNotFound {
+			return c.JSON(http.StatusOK, okResp{subimporter.Status{Status: subimporter.StatusNone}})
+		}
+		return err
+	}
+
+	return c.JSON(http.StatusOK, okResp{a.withLiveImportStats(job)})
 }
 
-// GetImportSubscriberStats returns import statistics.
+// GetImportSubscriberStats returns the logs of the latest import job
+// that hasn't been cleared.
 func (a *App) GetImportSubscriberStats(c echo.Context) error {
-	return c.JSON(http.StatusOK, okResp{string(a.importer.GetLogs())})
+	job, err := a.core.GetImportJob(0, importUserID(c))
+	if err != nil {
+		if e, ok := err.(*echo.HTTPError); ok && e.Code == http.StatusNotFound {
+			return c.JSON(http.StatusOK, okResp{""})
+		}
+		return err
+	}
+
+	return c.JSON(http.StatusOK, okResp{a.importLogs(job)})
 }
 
-// StopImportSubscribers sends a stop signal to the importer.
-// If there's an ongoing import, it'll be stopped, and if an import
-// is finished, it's state is cleared.
+// GetImportSubscriberErrors returns the CSV of rows rejected in the latest
+// import job with the reasons.
+func (a *App) GetImportSubscriberErrors(c echo.Context) error {
+	userID := importUserID(c)
+	job, err := a.core.GetImportJob(0, userID)
+	if err != nil {
+		return err
+	}
+
+	return a.sendImportErrors(job.ID, userID, c)
+}
+
+// StopImportSubscribers stops the latest import job if it's running or
+// queued. If it's finished, it's cleared from the import page.
 func (a *App) StopImportSubscribers(c echo.Context) error {
-	a.importer.Stop()
-	return c.JSON(http.StatusOK, okResp{a.importer.GetStats()})
+	userID := importUserID(c)
+	job, err := a.core.GetImportJob(0, userID)
+	if err != nil {
+		if e, ok := err.(*echo.HTTPError); ok && e.Code == http.StatusNotFound {
+			return c.JSON(http.StatusOK, okResp{subimporter.Status{Status: subimporter.StatusNone}})
+		}
+		return err
+	}
+
+	switch job.Status {
+	case subimporter.StatusQueued, subimporter.StatusImporting, subimporter.StatusStopping:
+		if err := a.stopImportJob(job, userID); err != nil {
+			return err
+		}
+	default:
+		if err := a.core.ClearImportJobs(userID); err != nil {
+			return err
+		}
+	}
+
+	job, err = a.core.GetImportJob(job.ID, userID)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, okResp{a.withLiveImportStats(job)})
+}
+
+// GetImportJobs returns import jobs, latest first.
+func (a *App) GetImportJobs(c echo.Context) error {
+	var (
+		status = c.FormValue("status")
+		pg     = a.pg.NewFromURL(c.Request().URL.Query())
+	)
+
+	res, total, err := a.core.QueryImportJobs(importUserID(c), status, pg.Offset, pg.Limit)
+	if err != nil {
+		return err
+	}
+
+	// No results.
+	if len(res) == 0 {
+		return c.JSON(http.StatusOK, okResp{models.PageResults{Results: []models.ImportJob{}}})
+	}
+
+	for n, j := range res {
+		res[n] = a.withLiveImportStats(j)
+	}
+
+	out := models.PageResults{
+		Results: res,
+		Total:   total,
+		Page:    pg.Page,
+		PerPage: pg.PerPage,
+	}
+
+	return c.JSON(http.StatusOK, okResp{out})
+}
+
+// GetImportJob returns an import job.
+func (a *App) GetImportJob(c echo.Context) error {
+	job, err := a.core.GetImportJob(getID(c), importUserID(c))
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, okResp{a.withLiveImportStats(job)})
+}
+
+// GetImportJobLogs returns the logs of an import job.
+func (a *App) GetImportJobLogs(c echo.Context) error {
+	job, err := a.core.GetImportJob(getID(c), importUserID(c))
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, okResp{a.importLogs(job)})
+}
+
+// GetImportJobErrors returns the CSV of rows rejected in an import job
+// with the reasons.
+func (a *App) GetImportJobErrors(c echo.Context) error {
+	return a.sendImportErrors(getID(c), importUserID(c), c)
+}
+
+// StopImportJob stops a running import job or cancels a queued one.
+func (a *App) StopImportJob(c echo.Context) error {
+	userID := importUserID(c)
+	job, err := a.core.GetImportJob(getID(c), userID)
+	if err != nil {
+		return err
+	}
+
+	if err := a.stopImportJob(job, userID); err != nil {
+		return err
+	}
+
+	job, err = a.core.GetImportJob(job.ID, userID)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, okResp{a.withLiveImportStats(job)})
+}
+
+// DeleteImportJob deletes a finished import job from the history.
+func (a *App) DeleteImportJob(c echo.Context) error {
+	if err := a.core.DeleteImportJob(getID(c), importUserID(c)); err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, okResp{true})
+}
+
+// GetImportPresets returns all saved column mapping presets.
+func (a *App) GetImportPresets(c echo.Context) error {
+	out, err := a.core.GetImportPresets()
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, okResp{out})
+}
+
+// CreateImportPreset handles the creation of a column mapping preset.
+func (a *App) CreateImportPreset(c echo.Context) error {
+	var o models.ImportPreset
+	if err := c.Bind(&o); err != nil {
+		return err
+	}
+
+	if err := a.validateImportPreset(o); err != nil {
+		return err
+	}
+
+	out, err := a.core.CreateImportPreset(o)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, okResp{out})
+}
+
+// UpdateImportPreset handles the modification of a column mapping preset.
+func (a *App) UpdateImportPreset(c echo.Context) error {
+	var o models.ImportPreset
+	if err := c.Bind(&o); err != nil {
+		return err
+	}
+
+	if err := a.validateImportPreset(o); err != nil {
+		return err
+	}
+
+	out, err := a.core.UpdateImportPreset(getID(c), o)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, okResp{out})
+}
+
+// DeleteImportPreset handles the deletion of a column mapping preset.
+func (a *App) DeleteImportPreset(c echo.Context) error {
+	if err := a.core.DeleteImportPreset(getID(c)); err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, okResp{true})
+}
+
+// validateImportPreset validates a column mapping preset.
+func (a *App) validateImportPreset(o models.ImportPreset) error {
+	if !strHasLen(o.Name, 1, stdInputMaxLen) {
+		return echo.NewHTTPError(http.StatusBadRequest, a.i18n.Ts("globals.messages.invalidFields", "name", "name"))
+	}
+
+	if err := subimporter.ValidateMapping(o.Mapping); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, a.i18n.Ts("globals.messages.invalidFields", "name", "mapping: "+err.Error()))
+	}
+
+	return nil
+}
+
+// stopImportJob sends a stop signal to a running import job, or cancels
+// it if it's still queued.
+func (a *App) stopImportJob(job models.ImportJob, userID int) error {
+	if a.importer.Stop(job.ID) {
+		return nil
+	}
+
+	path, err := a.core.CancelImportJob(job.ID, userID)
+	if err != nil {
+		return err
+	}
+	os.Remove(path)
+
+	return nil
+}
+
+// sendImportErrors sends the CSV of rows rejected in an import job as
+// a file download.
+func (a *App) sendImportErrors(jobID, userID int, c echo.Context) error {
+	b, err := a.core.GetImportJobErrors(jobID, userID)
+	if err != nil {
+		return err
+	}
+
+	c.Response().Header().Set(echo.HeaderContentDisposition, `attachment; filename="`+importErrorsFilename+`"`)
+	return c.Blob(http.StatusOK, "text/csv", b)
+}
+
+// withLiveImportStats overlays the stats of a running import job, which are
+// only periodically saved to the DB, on the job.
+func (a *App) withLiveImportStats(job models.ImportJob) models.ImportJob {
+	if s, ok := a.importer.GetStats(job.ID); ok {
+		job.Status = s.Status
+		job.Total = s.Total
+		job.Imported = s.Imported
+		job.Rejected = s.Rejected
+	}
+
+	return job
+}
+
+// importLogs returns the logs of an import job, live if it's running.
+func (a *App) importLogs(job models.ImportJob) string {
+	if l, ok := a.importer.GetLogs(job.ID); ok {
+		return l
+	}
+
+	return job.Log
+}
+
+// importUserID returns the ID of the user whose import jobs the request
+// is restricted to, or 0 if the user can access the import jobs of all users.
+func importUserID(c echo.Context) int {
+	user := auth.GetUser(c)
+	if user.HasPerm(auth.PermSubscribersImportAll) {
+		return 0
+	}
+
+	return user.ID
 }
@@ -11,6 +11,8 @@ import (
 	"log"
 	"maps"
 	"net/http"
+	"net/textproto"
+	"net/url"
 	"os"
 	"path"
 	"path/filepath"
@@ -32,11 +34,15 @@ import (
 	"github.com/knadh/koanf/providers/posflag"
 	"github.com/knadh/koanf/v2"
 	"github.com/knadh/listmonk/internal/auth"
+	"github.com/knadh/listmonk/internal/automation"
 	"github.com/knadh/listmonk/internal/bounce"
 	"github.com/knadh/listmonk/internal/bounce/mailbox"
+	"github.com/knadh/listmonk/internal/bounce/webhooks"
 	"github.com/knadh/listmonk/internal/captcha"
 	"github.com/knadh/listmonk/internal/core"
+	"github.com/knadh/listmonk/internal/digest"
 	"github.com/knadh/listmonk/internal/i18n"
+	"github.com/knadh/listmonk/internal/inbound"
 	"github.com/knadh/listmonk/internal/manager"
 	"github.com/knadh/listmonk/internal/media"
 	"github.com/knadh/listmonk/internal/media/providers/filesystem"
@@ -44,7 +50,9 @@ import (
 	"github.com/knadh/listmonk/internal/messenger/email"
 	"github.com/knadh/listmonk/internal/messenger/postback"
 	"github.com/knadh/listmonk/internal/notifs"
+	"github.com/knadh/listmonk/internal/sequence"
 	"github.com/knadh/listmonk/internal/subimporter"
+	"github.com/knadh/listmonk/internal/subsync"
 	"github.com/knadh/listmonk/models"
 	"github.com/knadh/stuffbin"
 	"github.com/labstack/echo/v4"
@@ -65,6 +73,7 @@ type UrlConfig struct {
 	FaviconURL   string `koanf:"favicon_url"`
 	LoginURL     string `koanf:"login_url"`
 	UnsubURL     string
+	OneClickURL  string
 	LinkTrackURL string
 	ViewTrackURL string
 	OptinURL     string
@@ -82,6 +91,7 @@ type Config struct {
 	EnablePublicArchiveRSSContent bool     `koanf:"enable_public_archive_rss_content"`
 	Lang                          string   `koanf:"lang"`
 	DBBatchSize                   int      `koanf:"batch_size"`
+	TrashRetentionDays            int      `koanf:"trash_retention_days"`
 	Privacy                       struct {
 		IndividualTracking bool            `koanf:"individual_tracking"`
 		AllowPreferences   bool            `koanf:"allow_preferences"`
@@ -90,6 +100,7 @@ type Config struct {
 		AllowWipe          bool            `koanf:"allow_wipe"`
 		RecordOptinIP      bool            `koanf:"record_optin_ip"`
 		UnsubHeader        bool            `koanf:"unsubscribe_header"`
+		UnsubMailto        string          `koanf:"unsubscribe_mailto"`
 		Exportable         map[string]bool `koanf:"-"`
 		DomainBlocklist    []string        `koanf:"-"`
 		DomainAllowlist    []string        `koanf:"-"`
@@ -141,6 +152,11 @@ type Config struct {
 	BounceSendgridEnabled     bool
 	BouncePostmarkEnabled     bool
 	BounceForwardemailEnabled bool
+	BounceMailgunEnabled      bool
+	BounceSparkPostEnabled    bool
+	BounceBrevoEnabled        bool
+	BounceMailjetEnabled      bool
+	BounceResendEnabled       bool
 
 	PermissionsRaw json.RawMessage
 	Permissions    map[string]struct{}
@@ -417,6 +433,10 @@ func initUrlConfig(ko *koanf.Koanf) *UrlConfig {
 		// url.com/subscription/{campaign_uuid}/{subscriber_uuid}
 		UnsubURL: fmt.Sprintf("%s/subscription/%%s/%%s", root),
 
+		// RFC 8058 one-click unsubscribe URL in the List-Unsubscribe header.
+		// url.com/subscription/unsubscribe/{campaign_uuid}/{subscriber_uuid}
+		OneClickURL: fmt.Sprintf("%s/subscription/unsubscribe/%%s/%%s", root),
+
 		// url.com/subscription/optin/{subscriber_uuid}
 		OptinURL: fmt.Sprintf("%s/subscription/optin/%%s?%%s", root),
 
@@ -464,6 +484,11 @@ func initConstConfig(ko *koanf.Koanf) *Config {
 	c.BounceSendgridEnabled = ko.Bool("bounce.sendgrid_enabled")
 	c.BouncePostmarkEnabled = ko.Bool("bounce.postmark.enabled")
 	c.BounceForwardemailEnabled = ko.Bool("bounce.forwardemail.enabled")
+	c.BounceMailgunEnabled = ko.Bool("bounce.mailgun.enabled")
+	c.BounceSparkPostEnabled = ko.Bool("bounce.sparkpost.enabled")
+	c.BounceBrevoEnabled = ko.Bool("bounce.brevo.enabled")
+	c.BounceMailjetEnabled = ko.Bool("bounce.mailjet.enabled")
+	c.BounceResendEnabled = ko.Bool("bounce.resend.enabled")
 	c.HasLegacyUser = ko.Exists("app.admin_username") || ko.Exists("app.admin_password")
 
 	b := md5.Sum([]byte(time.Now().String()))
@@ -516,6 +541,9 @@ func initCore(fnNotify func(sub models.Subscriber, listIDs []int) (int, error),
 		Constants: core.Constants{
 			SendOptinConfirmation: ko.Bool("app.send_optin_confirmation"),
 			CacheSlowQueries:      ko.Bool("app.cache_slow_queries"),
+			IndividualTracking:    ko.Bool("privacy.individual_tracking"),
+			RootURL:               ko.String("app.root_url"),
+			SoftBounceEscalation:  ko.Int("bounce.soft_escalation"),
 		},
 		Queries: queries,
 		DB:      db,
@@ -540,6 +568,28 @@ func initCampaignManager(msgrs []manager.Messenger, q *models.Queries, u *UrlCon
 		lo.Println("running in passive mode. won't process campaigns.")
 	}
 
+	// With inbound processing, campaign e-mails get a Message-Id that replies can be matched with.
+	msgIDDomain := ""
+	if ko.Bool("inbound.enabled") {
+		if r, err := url.Parse(u.RootURL); err == nil {
+			msgIDDomain = r.Hostname()
+		}
+	}
+
+	// Campaign e-mails are tagged with the campaign UUID for webhook providers
+	// that don't echo the e-mail's headers back.
+	tagHdrs := map[string]string{}
+	if ko.Bool("bounce.webhooks_enabled") {
+		for name, hdrs := range webhooks.TagHeaders {
+			if !ko.Bool("bounce." + name + ".enabled") {
+				continue
+			}
+			for k, v := range hdrs {
+				tagHdrs[k] = v
+			}
+		}
+	}
+
 	mgr := manager.New(manager.Config{
 		BatchSize:             ko.Int("app.batch_size"),
 		Concurrency:           ko.Int("app.concurrency"),
@@ -555,11 +605,15 @@ func initCampaignManager(msgrs []manager.Messenger, q *models.Queries, u *UrlCon
 		ArchiveURL:            u.ArchiveURL,
 		RootURL:               u.RootURL,
 		UnsubHeader:           ko.Bool("privacy.unsubscribe_header"),
+		OneClickURL:           u.OneClickURL,
+		UnsubMailto:           ko.String("privacy.unsubscribe_mailto"),
 		SlidingWindow:         ko.Bool("app.message_sliding_window"),
 		SlidingWindowDuration: ko.Duration("app.message_sliding_window_duration"),
 		SlidingWindowRate:     ko.Int("app.message_sliding_window_rate"),
 		ScanInterval:          time.Second * 5,
 		ScanCampaigns:         !ko.Bool("passive"),
+		MessageIDDomain:       msgIDDomain,
+		TagHeaders:            tagHdrs,
 	}, newManagerStore(q, co, md), i, lo)
 
 	// Attach all messengers to the campaign manager.
@@ -587,15 +641,22 @@ func initTxTemplates(m *manager.Manager, co *core.Core) {
 	}
 }
 
-// initImporter initializes the bulk subscriber importer.
-func initImporter(q *models.Queries, db *sqlx.DB, core *core.Core, i *i18n.I18n, ko *koanf.Koanf) *subimporter.Importer {
+// initImporter initializes the bulk subscriber importer that processes
+// the import jobs queued in the DB.
+func initImporter(q *models.Queries, db *sqlx.DB, core *core.Core, ms media.Store, i *i18n.I18n, ko *koanf.Koanf) *subimporter.Importer {
 	return subimporter.New(
 		subimporter.Options{
 			DomainBlocklist:    ko.Strings("privacy.domain_blocklist"),
 			DomainAllowlist:    ko.Strings("privacy.domain_allowlist"),
 			UpsertStmt:         q.UpsertSubscriber.Stmt,
 			BlocklistStmt:      q.UpsertBlocklistSubscriber.Stmt,
+			UnsubscribeStmt:    q.ImportUnsubscribeSubscriber.Stmt,
+			DeleteStmt:         q.ImportDeleteSubscriber.Stmt,
+			UpdateStmt:         q.ImportUpdateSubscriber.Stmt,
 			UpdateListDateStmt: q.UpdateListsDate.Stmt,
+			Concurrency:        ko.Int("app.import_concurrency"),
+			OpenMedia:          ms.Open,
+			AttribSchema:       core.CachedAttribSchema,
 
 			// Hook for triggering admin notifications and refreshing stats materialized
 			// views after a successful import.
@@ -607,11 +668,12 @@ func initImporter(q *models.Queries, db *sqlx.DB, core *core.Core, i *i18n.I18n,
 				notifs.NotifySystem(subject, notifs.TplImport, data, nil)
 				return nil
 			},
-		}, db.DB, i)
+		}, core, db.DB, i, lo)
 }
 
 // initSMTPMessenger initializes the combined and individual SMTP messengers.
-func initSMTPMessengers() []manager.Messenger {
+// The warm-up send counts of servers are kept in the DB via core.
+func initSMTPMessengers(co *core.Core) []manager.Messenger {
 	var (
 		servers = []email.Server{}
 		out     = []manager.Messenger{}
@@ -635,7 +697,7 @@ func initSMTPMessengers() []manager.Messenger {
 		// If the server has a name, initialize it as a standalone e-mail messenger
 		// allowing campaigns to select individual SMTPs. In the UI and config, it'll appear as `email / $name`.
 		if s.Name != "" {
-			msgr, err := email.New(s.Name, s)
+			msgr, err := email.New(s.Name, co, s)
 			if err != nil {
 				lo.Fatalf("error initializing e-mail messenger: %v", err)
 			}
@@ -644,7 +706,7 @@ func initSMTPMessengers() []manager.Messenger {
 	}
 
 	// Initialize the 'email' messenger with all SMTP servers.
-	msgr, err := email.New(email.MessengerName, servers...)
+	msgr, err := email.New(email.MessengerName, co, servers...)
 	if err != nil {
 		lo.Fatalf("error initializing e-mail messenger: %v", err)
 	}
@@ -762,9 +824,73 @@ func initNotifs(fs stuffbin.FileSystem, i *i18n.I18n, em *email.Emailer, u *UrlC
 	}, tpls, em, lo)
 }
 
+// initDigest initializes the poller that checks digest campaign feeds
+// and creates campaigns from new feed items.
+func initDigest(co *core.Core, lo *log.Logger) *digest.Digest {
+	return digest.New(digest.Opt{
+		Interval: time.Minute,
+		Timeout:  30 * time.Second,
+	}, co, lo)
+}
+
+// initSubSync initializes the runner that syncs subscribers from external
+// sources by queuing import jobs.
+func initSubSync(co *core.Core, im *subimporter.Importer, lo *log.Logger) *subsync.Sync {
+	return subsync.New(subsync.Opt{
+		Interval: time.Minute,
+		Timeout:  5 * time.Minute,
+		QueueImport: func(userID int, name string, opt subimporter.SessionOpt, path string) (int, error) {
+			b, err := json.Marshal(opt)
+			if err != nil {
+				return 0, err
+			}
+
+			job, err := co.CreateImportJob(userID, name, b, path)
+			if err != nil {
+				return 0, err
+			}
+			im.Queue()
+
+			return job.ID, nil
+		},
+	}, co, lo)
+}
+
+// initSequenceRunner initializes the runner that sends drip sequence messages.
+func initSequenceRunner(co *core.Core, tx *txSender, lo *log.Logger) *sequence.Runner {
+	return sequence.New(sequence.Opt{
+		Interval:  time.Minute,
+		BatchSize: 1000,
+		SendCB: func(m models.SequenceMessage) (bool, error) {
+			return tx.send(m.SubscriberID, m.TemplateID, m.Subject, m.FromEmail, m.Messenger, nil)
+		},
+	}, co, lo)
+}
+
+// initAutomationRunner initializes the runner that sends messages queued
+// by event-triggered automations.
+func initAutomationRunner(co *core.Core, tx *txSender, lo *log.Logger) *automation.Runner {
+	return automation.New(automation.Opt{
+		Interval:  time.Minute,
+		BatchSize: 1000,
+		SendCB: func(j models.AutomationJob) (bool, error) {
+			// Event data is available in the template as .Tx.Data.
+			data := map[string]any{}
+			if len(j.Data) > 0 {
+				if err := j.Data.Unmarshal(&data); err != nil {
+					lo.Printf("error reading automation event data: %v", err)
+				}
+			}
+			data["event"] = j.Event
+
+			return tx.send(j.SubscriberID, j.TemplateID, j.Subject, j.FromEmail, j.Messenger, data)
+		},
+	}, co, lo)
+}
+
 // initBounceManager initializes the bounce manager that scans mailboxes and listens to webhooks
 // for incoming bounce events.
-func initBounceManager(cb func(models.Bounce) error, stmt *sqlx.Stmt, lo *log.Logger, ko *koanf.Koanf) *bounce.Manager {
+func initBounceManager(cb func(models.Bounce) error, eventCB func(models.DeliveryEvent) error, stmt *sqlx.Stmt, lo *log.Logger, ko *koanf.Koanf) *bounce.Manager {
 	opt := bounce.Opt{
 		WebhooksEnabled: ko.Bool("bounce.webhooks_enabled"),
 		SESEnabled:      ko.Bool("bounce.ses_enabled"),
@@ -786,7 +912,56 @@ func initBounceManager(cb func(models.Bounce) error, stmt *sqlx.Stmt, lo *log.Lo
 			ko.Bool("bounce.forwardemail.enabled"),
 			ko.String("bounce.forwardemail.key"),
 		},
+		Mailgun: struct {
+			Enabled bool
+			Key     string
+		}{
+			ko.Bool("bounce.mailgun.enabled"),
+			ko.String("bounce.mailgun.key"),
+		},
+		SparkPost: struct {
+			Enabled  bool
+			Username string
+			Password string
+		}{
+			ko.Bool("bounce.sparkpost.enabled"),
+			ko.String("bounce.sparkpost.username"),
+			ko.String("bounce.sparkpost.password"),
+		},
+		Brevo: struct {
+			Enabled  bool
+			Username string
+			Password string
+		}{
+			ko.Bool("bounce.brevo.enabled"),
+			ko.String("bounce.brevo.username"),
+			ko.String("bounce.brevo.password"),
+		},
+		Mailjet: struct {
+			Enabled  bool
+			Username string
+			Password string
+		}{
+			ko.Bool("bounce.mailjet.enabled"),
+			ko.String("bounce.mailjet.username"),
+			ko.String("bounce.mailjet.password"),
+		},
+		Resend: struct {
+			Enabled bool
+			Key     string
+		}{
+			ko.Bool("bounce.resend.enabled"),
+			ko.String("bounce.resend.key"),
+		},
 		RecordBounceCB: cb,
+		RecordEventCB:  eventCB,
+	}
+
+	if err := ko.UnmarshalWithConf("bounce.webhook_events", &opt.Events, koanf.UnmarshalConf{Tag: "json"}); err != nil {
+		lo.Fatalf("error reading bounce webhook events config: %v", err)
+	}
+	if err := ko.UnmarshalWithConf("bounce.webhook_security", &opt.Security, koanf.UnmarshalConf{Tag: "json"}); err != nil {
+		lo.Fatalf("error reading bounce webhook security config: %v", err)
 	}
 
 	// For now, only one mailbox is supported.
@@ -815,6 +990,75 @@ func initBounceManager(cb func(models.Bounce) error, stmt *sqlx.Stmt, lo *log.Lo
 	return b
 }
 
+// initInbound initializes the processor that scans the inbound mailbox for
+// replies to campaigns and unsubscribe requests.
+func initInbound(co *core.Core, msgr *email.Emailer, lo *log.Logger, ko *koanf.Koanf) *inbound.Inbound {
+	var box mailbox.Opt
+	if err := ko.UnmarshalWithConf("inbound.mailbox", &box, koanf.UnmarshalConf{Tag: "json"}); err != nil {
+		lo.Fatalf("error reading inbound mailbox config: %v", err)
+	}
+
+	fromEmail := ko.String("app.from_email")
+	in, err := inbound.New(inbound.Opt{
+		MailboxType:    ko.String("inbound.mailbox.type"),
+		Mailbox:        box,
+		Unsubscribe:    ko.Bool("inbound.unsubscribe"),
+		ForwardEmail:   ko.String("inbound.forward_email"),
+		ForwardWebhook: ko.String("inbound.forward_webhook"),
+		Timeout:        30 * time.Second,
+		RecordReplyCB:  co.RecordReply,
+
+		// Unsubscribe the sender and record it in the activity timeline.
+		UnsubscribeCB: func(m models.InboundMessage) error {
+			subID, listIDs, err := co.UnsubscribeByEmail(m.Email, m.SubscriberUUID, m.CampaignUUID)
+			if err != nil || subID == 0 || len(listIDs) == 0 {
+				return err
+			}
+
+			source := models.UnsubSourceReply
+			if m.ListUnsubscribe {
+				source = models.UnsubSourceHeader
+			}
+
+			return co.RecordSubscriberActivity([]int{subID}, nil, models.ActivityUnsubscribed,
+				models.JSON{"list_ids": listIDs, "campaign_uuid": m.CampaignUUID, "source": source}, models.ActivityActorSubscriber, 0)
+		},
+
+		// Forward the reply with the original e-mail attached. Replying to the
+		// forwarded e-mail replies to the sender.
+		ForwardEmailCB: func(to string, m models.InboundMessage) error {
+			if msgr == nil {
+				return errors.New("no e-mail messenger")
+			}
+
+			body := fmt.Sprintf("From: %s <%s>\nDate: %s\nSubject: %s\nCampaign: %s\nSubscriber: %s\n\n%s",
+				m.Name, m.Email, m.Date.Format(time.RFC1123Z), m.Subject, m.CampaignUUID, m.SubscriberUUID, m.Body)
+
+			h := textproto.MIMEHeader{}
+			h.Set("Reply-To", m.Email)
+
+			return msgr.Push(models.Message{
+				From:        fromEmail,
+				To:          []string{to},
+				Subject:     "Fwd: " + m.Subject,
+				ContentType: "plain",
+				Body:        []byte(body),
+				Headers:     h,
+				Attachments: []models.Attachment{{
+					Name:    "reply.eml",
+					Header:  manager.MakeAttachmentHeader("reply.eml", "base64", "application/octet-stream"),
+					Content: m.Raw,
+				}},
+			})
+		},
+	}, lo)
+	if err != nil {
+		lo.Fatalf("error initializing inbound processor: %v", err)
+	}
+
+	return in
+}
+
 // initAbout initializes the app's /about API endpoint with the app and system info.
 func initAbout(q *models.Queries, db *sqlx.DB) about {
 	var (
@@ -164,8 +164,14 @@ func (a *App) DeleteLists(c echo.Context) error {
 		return err
 	}
 
-	// Delete the lists from the DB.
-	if err := a.core.DeleteLists(ids); err != nil {
+	// Move the lists to the trash, or delete them if the trash is disabled.
+	var err error
+	if a.cfg.TrashRetentionDays > 0 {
+		err = a.core.TrashLists(ids)
+	} else {
+		err = a.core.DeleteLists(ids)
+	}
+	if err != nil {
 		return err
 	}
 
@@ -20,12 +20,14 @@ import (
 	"github.com/knadh/listmonk/internal/buflog"
 	"github.com/knadh/listmonk/internal/captcha"
 	"github.com/knadh/listmonk/internal/core"
+	"github.com/knadh/listmonk/internal/digest"
 	"github.com/knadh/listmonk/internal/events"
 	"github.com/knadh/listmonk/internal/i18n"
 	"github.com/knadh/listmonk/internal/manager"
 	"github.com/knadh/listmonk/internal/media"
 	"github.com/knadh/listmonk/internal/messenger/email"
 	"github.com/knadh/listmonk/internal/subimporter"
+	"github.com/knadh/listmonk/internal/subsync"
 	"github.com/knadh/listmonk/models"
 	"github.com/knadh/paginator"
 	"github.com/knadh/stuffbin"
@@ -46,6 +48,8 @@ type App struct {
 	auth       *auth.Auth
 	media      media.Store
 	bounce     *bounce.Manager
+	digest     *digest.Digest
+	subSync    *subsync.Sync
 	captcha    *captcha.Captcha
 	i18n       *i18n.I18n
 	pg         *paginator.Paginator
@@ -182,19 +186,19 @@ func main() {
 		// Initialize the media store.
 		media = initMediaStore(ko)
 
-		fbOptinNotify = makeOptinNotifyHook(ko.Bool("privacy.unsubscribe_header"), urlCfg, queries, i18n)
+		fbOptinNotify = makeOptinNotifyHook(ko.Bool("privacy.unsubscribe_header"), ko.String("privacy.unsubscribe_mailto"), urlCfg, queries, i18n)
 
 		// Crud core.
 		core = initCore(fbOptinNotify, queries, db, i18n, ko)
 
 		// Initialize all messengers, SMTP and postback.
-		msgrs = append(initSMTPMessengers(), initPostbackMessengers(ko)...)
+		msgrs = append(initSMTPMessengers(core), initPostbackMessengers(ko)...)
 
 		// Campaign manager.
 		mgr = initCampaignManager(msgrs, queries, urlCfg, core, media, i18n, ko)
 
 		// Bulk importer.
-		importer = initImporter(queries, db, core, i18n, ko)
+		importer = initImporter(queries, db, core, media, i18n, ko)
 
 		// Initialize the auth manager.
 		hasUsers, auth = initAuth(core, db.DB, ko)
@@ -210,7 +214,7 @@ func main() {
 	// Initialize the bounce manager that processes bounces from webhooks and
 	// POP3 mailbox scanning.
 	if ko.Bool("bounce.enabled") {
-		bounce = initBounceManager(core.RecordBounce, queries.RecordBounce, lo, ko)
+		bounce = initBounceManager(core.RecordBounce, core.RecordDeliveryEvent, queries.RecordBounce, lo, ko)
 	}
 
 	// Assign the default `email` messenger to the app.
@@ -232,6 +236,36 @@ func main() {
 		go bounce.Run()
 	}
 
+	// Start processing queued subscriber import jobs.
+	go importer.Run()
+
+	// Start purging subscribers, lists and campaigns that have expired in the trash.
+	go runTrashPurge(core, ko.Int("app.trash_retention_days"), lo)
+
+	// Start deleting bounce records older than the decay period.
+	if d := ko.Int("bounce.decay_days"); d > 0 {
+		go runBounceDecay(core, d, lo)
+	}
+
+	// Start processing replies and unsubscribe requests on the inbound mailbox.
+	if ko.Bool("inbound.enabled") {
+		go initInbound(core, emailMsgr, lo, ko).Run()
+	}
+
+	// Start the digest campaign feed poller.
+	dg := initDigest(core, lo)
+	go dg.Run()
+
+	// Start the scheduled subscriber sync.
+	ss := initSubSync(core, importer, lo)
+	go ss.Run()
+
+	// Start the drip sequence and event automation runners that send
+	// tx templates in the background.
+	txs := &txSender{core: core, manager: mgr, fromEmail: ko.String("app.from_email"), log: lo}
+	go initSequenceRunner(core, txs, lo).Run()
+	go initAutomationRunner(core, txs, lo).Run()
+
 	// Start cronjobs.
 	if ko.Bool("app.cache_slow_queries") {
 		initCron(core)
@@ -257,6 +291,8 @@ func main() {
 		auth:       auth,
 		media:      media,
 		bounce:     bounce,
+		digest:     dg,
+		subSync:    ss,
 		captcha:    initCaptcha(),
 		i18n:       i18n,
 		log:        lo,
@@ -4,6 +4,7 @@ import (
 	"net/http"
 	"time"
 
+	"github.com/knadh/listmonk/models"
 	"github.com/labstack/echo/v4"
 )
 
@@ -81,3 +82,25 @@ func (a *App) GCCampaignAnalytics(c echo.Context) error {
 
 	return c.JSON(http.StatusOK, okResp{true})
 }
+
+// GCTrash permanently deletes subscribers, lists and campaigns that were
+// trashed before a given date.
+func (a *App) GCTrash(c echo.Context) error {
+	t, err := time.Parse(time.RFC3339, c.FormValue("before_date"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, a.i18n.T("globals.messages.invalidData"))
+	}
+
+	total := 0
+	for _, typ := range []string{models.TrashCampaigns, models.TrashLists, models.TrashSubscribers} {
+		n, err := a.core.PurgeTrash(typ, nil, true, t)
+		if err != nil {
+			return err
+		}
+		total += n
+	}
+
+	return c.JSON(http.StatusOK, okResp{struct {
+		Count int `json:"count"`
+	}{total}})
+}
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"time"
+
 	"github.com/gofrs/uuid/v5"
 	"github.com/knadh/listmonk/internal/core"
 	"github.com/knadh/listmonk/internal/manager"
@@ -38,8 +40,17 @@ func newManagerStore(q *models.Queries, c *core.Core, m media.Store) *store {
 // of campaigns that are being processed and updates them in the DB.
 func (s *store) NextCampaigns(currentIDs []int64, sentCounts []int64) ([]*models.Campaign, error) {
 	var out []*models.Campaign
-	err := s.queries.NextCampaigns.Select(&out, pq.Int64Array(currentIDs), pq.Int64Array(sentCounts))
-	return out, err
+	if err := s.queries.NextCampaigns.Select(&out, pq.Int64Array(currentIDs), pq.Int64Array(sentCounts)); err != nil {
+		return nil, err
+	}
+
+	// Freeze the revision of the content that's being sent. Errors are logged
+	// and don't hold up sending the campaign (or the others).
+	for _, c := range out {
+		_ = s.core.FreezeCampaignRevision(c.ID)
+	}
+
+	return out, nil
 }
 
 // NextSubscribers retrieves a subset of subscribers of a given campaign.
@@ -79,6 +90,19 @@ func (s *store) UpdateCampaignStatus(campID int, status string) error {
 	return err
 }
 
+// PauseCampaign pauses a running campaign until the given time, after which
+// it's automatically resumed from the given checkpoint.
+func (s *store) PauseCampaign(campID int, lastSubID int, until time.Time) error {
+	_, err := s.queries.PauseCampaignUntil.Exec(campID, until, lastSubID)
+	return err
+}
+
+// ResumeCampaigns resumes paused campaigns whose resume time is up.
+func (s *store) ResumeCampaigns() error {
+	_, err := s.queries.ResumeCampaigns.Exec()
+	return err
+}
+
 // UpdateCampaignCounts updates a campaign's status.
 func (s *store) UpdateCampaignCounts(campID int, toSend int, sent int, lastSubID int) error {
 	_, err := s.queries.UpdateCampaignCounts.Exec(campID, toSend, sent, lastSubID)
@@ -9,6 +9,7 @@ import (
 	"image/png"
 	"io"
 	"net/http"
+	"net/url"
 	"strconv"
 	"strings"
 
@@ -90,6 +91,7 @@ type msgTpl struct {
 type subFormTpl struct {
 	publicTpl
 	Lists   []models.List
+	Attribs []models.AttribField
 	Captcha struct {
 		Enabled    bool
 		Provider   string
@@ -143,6 +145,24 @@ func (a *App) GetPublicLists(c echo.Context) error {
 	return c.JSON(http.StatusOK, out)
 }
 
+// GetPublicAttribs returns the public attributes in the subscriber attribute
+// schema that can be submitted with a subscription.
+func (a *App) GetPublicAttribs(c echo.Context) error {
+	sc, err := a.core.CachedAttribSchema()
+	if err != nil {
+		return err
+	}
+
+	out := make([]models.AttribField, 0, len(sc))
+	for _, f := range sc {
+		if f.Public {
+			out = append(out, f)
+		}
+	}
+
+	return c.JSON(http.StatusOK, out)
+}
+
 // ViewCampaignMessage renders the HTML view of a campaign message.
 // This is the view the {{ MessageURL }} template tag links to in e-mail campaigns.
 func (a *App) ViewCampaignMessage(c echo.Context) error {
@@ -247,10 +267,63 @@ func (a *App) SubscriptionPage(c echo.Context) error {
 	return c.Render(http.StatusOK, "subscription", out)
 }
 
+// OneClickUnsubscribe handles RFC 8058 one-click unsubscriptions that e-mail
+// clients POST to the URL in the List-Unsubscribe header. The subscriber is
+// only unsubscribed from the campaign's lists, or on the opt-in e-mail (which has
+// no campaign), from the lists that are still unconfirmed. As it's not a page
+// that's shown to the subscriber, no HTML is rendered.
+func (a *App) OneClickUnsubscribe(c echo.Context) error {
+	var (
+		campUUID = c.Param("campUUID")
+		subUUID  = c.Param("subUUID")
+	)
+	if !reUUID.MatchString(campUUID) || !reUUID.MatchString(subUUID) {
+		return echo.NewHTTPError(http.StatusBadRequest, a.i18n.T("globals.messages.invalidUUID"))
+	}
+
+	// The request body should be List-Unsubscribe=One-Click (RFC 8058 3.2).
+	if c.FormValue("List-Unsubscribe") != "One-Click" {
+		return echo.NewHTTPError(http.StatusBadRequest, a.i18n.T("globals.messages.invalidData"))
+	}
+
+	if _, err := a.core.GetSubscriber(0, subUUID, ""); err != nil {
+		return err
+	}
+
+	var (
+		listIDs []int
+		data    = models.JSON{"source": models.UnsubSourceHeader}
+		err     error
+	)
+	if campUUID == dummyUUID {
+		listIDs, err = a.core.UnsubscribeUnconfirmed(subUUID)
+	} else {
+		listIDs, err = a.core.UnsubscribeByCampaign(subUUID, campUUID, false)
+		data["campaign_uuid"] = campUUID
+	}
+	if err != nil {
+		return err
+	}
+
+	// Nothing to record if the subscriber had already unsubscribed.
+	if len(listIDs) > 0 {
+		data["list_ids"] = listIDs
+		a.recordActivity(c, nil, []string{subUUID}, models.ActivityUnsubscribed, data)
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
 // SubscriptionPrefs renders the subscription management page and
 // s unsubscriptions. This is the view that {{ UnsubscribeURL }} in
 // campaigns link to.
 func (a *App) SubscriptionPrefs(c echo.Context) error {
+	// One-click unsubscribe requests to the List-Unsubscribe URL in e-mails
+	// that were sent before the dedicated one-click URL.
+	if c.FormValue("List-Unsubscribe") == "One-Click" {
+		return a.OneClickUnsubscribe(c)
+	}
+
 	// Read the form.
 	var req struct {
 		Name      string   `form:"name" json:"name"`
@@ -270,11 +343,21 @@ func (a *App) SubscriptionPrefs(c echo.Context) error {
 		blocklist = a.cfg.Privacy.AllowBlocklist && req.Blocklist
 	)
 	if !req.Manage || blocklist {
-		if err := a.core.UnsubscribeByCampaign(subUUID, campUUID, blocklist); err != nil {
+		listIDs, err := a.core.UnsubscribeByCampaign(subUUID, campUUID, blocklist)
+		if err != nil {
 			return c.Render(http.StatusInternalServerError, tplMessage,
 				makeMsgTpl(a.i18n.T("public.errorTitle"), "", a.i18n.T("public.errorProcessingRequest")))
 		}
 
+		typ := models.ActivityUnsubscribed
+		if blocklist {
+			typ = models.ActivityBlocklisted
+		}
+		if blocklist || len(listIDs) > 0 {
+			a.recordActivity(c, nil, []string{subUUID}, typ,
+				models.JSON{"list_ids": listIDs, "campaign_uuid": campUUID, "source": models.UnsubSourcePage})
+		}
+
 		return c.Render(http.StatusOK, tplMessage,
 			makeMsgTpl(a.i18n.T("public.unsubbedTitle"), "", a.i18n.T("public.unsubbedInfo")))
 	}
@@ -299,13 +382,21 @@ func (a *App) SubscriptionPrefs(c echo.Context) error {
 			makeMsgTpl(a.i18n.T("public.errorTitle"), "", a.i18n.Ts("globals.messages.pFound",
 				"name", a.i18n.T("globals.terms.subscriber"))))
 	}
+	oldName := sub.Name
 	sub.Name = req.Name
 
-	// Update the subscriber properties in the DB.
-	if _, err := a.core.UpdateSubscriber(sub.ID, sub); err != nil {
+	// Update the subscriber properties in the DB. The attributes aren't
+	// changed and are retained as-is.
+	upd := sub
+	upd.Attribs = nil
+	if _, err := a.core.UpdateSubscriber(sub.ID, upd); err != nil {
 		return c.Render(http.StatusInternalServerError, tplMessage,
 			makeMsgTpl(a.i18n.T("public.errorTitle"), "", a.i18n.T("public.errorProcessingRequest")))
 	}
+	if oldName != sub.Name {
+		a.recordActivity(c, []int{sub.ID}, nil, models.ActivityProfileUpdated,
+			models.JSON{"changes": models.JSON{"name": change(oldName, sub.Name)}})
+	}
 
 	// Get the subscriber's lists and whatever is not sent in the request (unchecked),
 	// unsubscribe them.
@@ -321,13 +412,19 @@ func (a *App) SubscriptionPrefs(c echo.Context) error {
 	}
 
 	// Filter the lists in the request against the subscriptions in the DB.
-	unsubUUIDs := make([]string, 0, len(req.ListUUIDs))
+	var (
+		unsubUUIDs = make([]string, 0, len(req.ListUUIDs))
+		unsubIDs   = make([]int, 0, len(req.ListUUIDs))
+	)
 	for _, s := range subs {
 		if s.Type == models.ListTypePrivate {
 			continue
 		}
 		if _, ok := reqUUIDs[s.UUID]; !ok {
 			unsubUUIDs = append(unsubUUIDs, s.UUID)
+			if s.SubscriptionStatus.String != models.SubscriptionStatusUnsubscribed {
+				unsubIDs = append(unsubIDs, s.ID)
+			}
 		}
 	}
 
@@ -337,6 +434,9 @@ func (a *App) SubscriptionPrefs(c echo.Context) error {
 			makeMsgTpl(a.i18n.T("public.errorTitle"), "", a.i18n.T("public.errorProcessingRequest")))
 
 	}
+	if len(unsubIDs) > 0 {
+		a.recordActivity(c, []int{sub.ID}, nil, models.ActivityUnsubscribed, models.JSON{"list_ids": unsubIDs})
+	}
 
 	return c.Render(http.StatusOK, tplMessage,
 		makeMsgTpl(a.i18n.T("globals.messages.done"), "", a.i18n.T("public.prefsSaved")))
@@ -396,6 +496,16 @@ func (a *App) OptinPage(c echo.Context) error {
 				makeMsgTpl(a.i18n.T("public.errorTitle"), "", a.i18n.Ts("public.errorProcessingRequest")))
 		}
 
+		listIDs := make([]int, 0, len(lists))
+		for _, l := range lists {
+			listIDs = append(listIDs, l.ID)
+		}
+		data := models.JSON{"list_ids": listIDs}
+		if ip, ok := meta["optin_ip"]; ok {
+			data["optin_ip"] = ip
+		}
+		a.recordActivity(c, nil, []string{subUUID}, models.ActivityOptinConfirmed, data)
+
 		return c.Render(http.StatusOK, tplMessage,
 			makeMsgTpl(a.i18n.T("public.subConfirmedTitle"), "", a.i18n.Ts("public.subConfirmed")))
 	}
@@ -429,9 +539,21 @@ func (a *App) SubscriptionFormPage(c echo.Context) error {
 			makeMsgTpl(a.i18n.T("public.errorTitle"), "", a.i18n.Ts("public.noListsAvailable")))
 	}
 
+	// Public attributes in the schema are rendered as fields in the form.
+	sc, err := a.core.CachedAttribSchema()
+	if err != nil {
+		return c.Render(http.StatusInternalServerError, tplMessage,
+			makeMsgTpl(a.i18n.T("public.errorTitle"), "", fmt.Sprintf("%s", err.(*echo.HTTPError).Message)))
+	}
+
 	out := subFormTpl{}
 	out.Title = a.i18n.T("public.sub")
 	out.Lists = lists
+	for _, f := range sc {
+		if f.Public {
+			out.Attribs = append(out.Attribs, f)
+		}
+	}
 
 	// Captcha configuration for template rendering.
 	if a.cfg.Security.Captcha.Altcha.Enabled {
@@ -693,11 +815,19 @@ func (a *App) processSubForm(c echo.Context) (bool, error) {
 		Name          string   `form:"name" json:"name"`
 		Email         string   `form:"email" json:"email"`
 		FormListUUIDs []string `form:"l" json:"list_uuids"`
+
+		// Attributes are posted as attribs.<name> fields in HTML forms.
+		Attribs models.JSON `json:"attribs"`
 	}
 	if err := c.Bind(&req); err != nil {
 		return false, err
 	}
 
+	attribs, err := a.getPublicAttribs(c, req.Attribs)
+	if err != nil {
+		return false, err
+	}
+
 	if len(req.FormListUUIDs) == 0 {
 		return false, echo.NewHTTPError(http.StatusBadRequest, a.i18n.T("public.noListsSelected"))
 	}
@@ -736,12 +866,14 @@ func (a *App) processSubForm(c echo.Context) (bool, error) {
 	}
 
 	// Insert the subscriber into the DB.
-	_, hasOptin, err := a.core.InsertSubscriber(models.Subscriber{
-		Name:   req.Name,
-		Email:  req.Email,
-		Status: models.SubscriberStatusEnabled,
+	sub, hasOptin, err := a.core.InsertSubscriber(models.Subscriber{
+		Name:    req.Name,
+		Email:   req.Email,
+		Attribs: attribs,
+		Status:  models.SubscriberStatusEnabled,
 	}, nil, listUUIDs, false, true)
 	if err == nil {
+		a.recordActivity(c, []int{sub.ID}, nil, models.ActivityCreated, models.JSON{"list_ids": subscriberListIDs(sub)})
 		return hasOptin, nil
 	}
 
@@ -756,9 +888,12 @@ func (a *App) processSubForm(c echo.Context) (bool, error) {
 			return false, err
 		}
 
-		// Update the subscriber's subscriptions in the DB.
-		_, hasOptin, err := a.core.UpdateSubscriberWithLists(sub.ID, sub, nil, listUUIDs, false, false, true)
+		// Update the subscriber's subscriptions in the DB, retaining the attributes.
+		upd := sub
+		upd.Attribs = nil
+		out, hasOptin, err := a.core.UpdateSubscriberWithLists(sub.ID, upd, nil, listUUIDs, false, false, true)
 		if err == nil {
+			a.recordSubscriberChanges(c, sub, out)
 			return hasOptin, nil
 		}
 		lastErr = err
@@ -770,3 +905,55 @@ func (a *App) processSubForm(c echo.Context) (bool, error) {
 	}
 	return false, echo.NewHTTPError(http.StatusInternalServerError, a.i18n.T("public.errorProcessingRequest"))
 }
+
+// getPublicAttribs returns the attributes in a public subscription request,
+// either from the JSON body or attribs.<name> form fields. Only attributes that
+// are marked public in the attribute schema are accepted and the rest are ignored.
+func (a *App) getPublicAttribs(c echo.Context, body models.JSON) (models.JSON, error) {
+	sc, err := a.core.CachedAttribSchema()
+	if err != nil {
+		return nil, err
+	}
+
+	isJSON := strings.HasPrefix(c.Request().Header.Get(echo.HeaderContentType), echo.MIMEApplicationJSON)
+
+	var form url.Values
+	if !isJSON {
+		if form, err = c.FormParams(); err != nil {
+			return nil, err
+		}
+	}
+
+	out := models.JSON{}
+	for _, f := range sc {
+		if !f.Public {
+			continue
+		}
+
+		// JSON values are validated as-is.
+		if isJSON {
+			for k, v := range body {
+				if strings.EqualFold(k, f.Name) {
+					out[f.Name] = v
+				}
+			}
+			continue
+		}
+
+		vals, ok := form["attribs."+f.Name]
+		if !ok {
+			continue
+		}
+
+		v, err := f.ParseValue(vals)
+		if err != nil {
+			return nil, echo.NewHTTPError(http.StatusBadRequest,
+				a.i18n.Ts("subscribers.invalidAttribs", "error", err.Error()))
+		}
+		if v != nil {
+			out[f.Name] = v
+		}
+	}
+
+	return out, nil
+}
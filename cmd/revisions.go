@@ -0,0 +1,169 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"slices"
+	"strconv"
+
+	"github.com/knadh/listmonk/internal/auth"
+	"github.com/knadh/listmonk/internal/utils"
+	"github.com/labstack/echo/v4"
+)
+
+// Campaign revision fields that are diffed line by line.
+var revisionTextFields = []string{"body", "body_source", "altbody"}
+
+// revisionDiff represents the change in a single field between two revisions.
+type revisionDiff struct {
+	Field string `json:"field"`
+	From  any    `json:"from"`
+	To    any    `json:"to"`
+
+	// Lines is a line diff of text fields (body etc.).
+	Lines []string `json:"lines,omitempty"`
+}
+
+// GetCampaignRevisions returns a campaign's revisions.
+func (a *App) GetCampaignRevisions(c echo.Context) error {
+	id := getID(c)
+
+	// Check if the user has access to the campaign.
+	if err := a.checkCampaignPerm(auth.PermTypeGet, id, c); err != nil {
+		return err
+	}
+
+	out, err := a.core.GetCampaignRevisions(id)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, okResp{out})
+}
+
+// GetCampaignRevision returns a campaign revision with its content.
+func (a *App) GetCampaignRevision(c echo.Context) error {
+	id := getID(c)
+
+	// Check if the user has access to the campaign.
+	if err := a.checkCampaignPerm(auth.PermTypeGet, id, c); err != nil {
+		return err
+	}
+
+	version, err := strconv.Atoi(c.Param("version"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, a.i18n.Ts("globals.messages.invalidFields", "name", "version"))
+	}
+
+	out, err := a.core.GetCampaignRevision(id, version)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, okResp{out})
+}
+
+// DiffCampaignRevision returns the fields that differ between a campaign
+// revision and another revision (?against=), which defaults to the previous one.
+func (a *App) DiffCampaignRevision(c echo.Context) error {
+	id := getID(c)
+
+	// Check if the user has access to the campaign.
+	if err := a.checkCampaignPerm(auth.PermTypeGet, id, c); err != nil {
+		return err
+	}
+
+	version, err := strconv.Atoi(c.Param("version"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, a.i18n.Ts("globals.messages.invalidFields", "name", "version"))
+	}
+
+	against := version - 1
+	if v := c.QueryParam("against"); v != "" {
+		if against, err = strconv.Atoi(v); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, a.i18n.Ts("globals.messages.invalidFields", "name", "against"))
+		}
+	}
+
+	to, err := a.core.GetCampaignRevision(id, version)
+	if err != nil {
+		return err
+	}
+
+	// The first revision is diffed against nothing.
+	var fromData, toData map[string]any
+	if against > 0 {
+		from, err := a.core.GetCampaignRevision(id, against)
+		if err != nil {
+			return err
+		}
+		if err := json.Unmarshal(from.Data, &fromData); err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, a.i18n.T("globals.messages.invalidData"))
+		}
+	}
+	if err := json.Unmarshal(to.Data, &toData); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, a.i18n.T("globals.messages.invalidData"))
+	}
+
+	// Collect the fields across both revisions.
+	fields := make([]string, 0, len(toData))
+	for k := range toData {
+		fields = append(fields, k)
+	}
+	for k := range fromData {
+		if _, ok := toData[k]; !ok {
+			fields = append(fields, k)
+		}
+	}
+	slices.Sort(fields)
+
+	out := []revisionDiff{}
+	for _, f := range fields {
+		if reflect.DeepEqual(fromData[f], toData[f]) {
+			continue
+		}
+
+		d := revisionDiff{Field: f, From: fromData[f], To: toData[f]}
+		if slices.Contains(revisionTextFields, f) {
+			prev, _ := fromData[f].(string)
+			cur, _ := toData[f].(string)
+			d.Lines = utils.DiffLines(prev, cur)
+		}
+		out = append(out, d)
+	}
+
+	return c.JSON(http.StatusOK, okResp{out})
+}
+
+// RestoreCampaignRevision restores a campaign's content to a revision.
+func (a *App) RestoreCampaignRevision(c echo.Context) error {
+	id := getID(c)
+
+	// Check if the user has access to the campaign.
+	if err := a.checkCampaignPerm(auth.PermTypeManage, id, c); err != nil {
+		return err
+	}
+
+	version, err := strconv.Atoi(c.Param("version"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, a.i18n.Ts("globals.messages.invalidFields", "name", "version"))
+	}
+
+	cm, err := a.core.GetCampaign(id, "", "")
+	if err != nil {
+		return err
+	}
+
+	if !canEditCampaign(cm.Status) {
+		return echo.NewHTTPError(http.StatusBadRequest, a.i18n.T("campaigns.cantUpdate"))
+	}
+
+	user := auth.GetUser(c)
+	out, err := a.core.RestoreCampaignRevision(id, version, user.ID, user.Username)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, okResp{out})
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/knadh/listmonk/internal/utils"
+	"github.com/knadh/listmonk/models"
+	"github.com/labstack/echo/v4"
+)
+
+// seqSubsReq represents a request to add or remove subscribers from a sequence.
+type seqSubsReq struct {
+	SubscriberIDs []int `json:"subscriber_ids"`
+}
+
+// GetSequences handles retrieval of sequences.
+func (a *App) GetSequences(c echo.Context) error {
+	out, err := a.core.GetSequences()
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, okResp{out})
+}
+
+// GetSequence handles retrieval of a sequence.
+func (a *App) GetSequence(c echo.Context) error {
+	out, err := a.core.GetSequence(getID(c))
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, okResp{out})
+}
+
+// CreateSequence handles sequence creation.
+func (a *App) CreateSequence(c echo.Context) error {
+	var o models.Sequence
+	if err := c.Bind(&o); err != nil {
+		return err
+	}
+
+	if err := a.validateSequence(&o); err != nil {
+		return err
+	}
+
+	out, err := a.core.CreateSequence(o)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, okResp{out})
+}
+
+// UpdateSequence handles sequence modification.
+func (a *App) UpdateSequence(c echo.Context) error {
+	var o models.Sequence
+	if err := c.Bind(&o); err != nil {
+		return err
+	}
+
+	if err := a.validateSequence(&o); err != nil {
+		return err
+	}
+
+	out, err := a.core.UpdateSequence(getID(c), o)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, okResp{out})
+}
+
+// DeleteSequence handles sequence deletion.
+func (a *App) DeleteSequence(c echo.Context) error {
+	if err := a.core.DeleteSequence(getID(c)); err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, okResp{true})
+}
+
+// AddSequenceSubscribers handles adding subscribers to a sequence.
+func (a *App) AddSequenceSubscribers(c echo.Context) error {
+	var req seqSubsReq
+	if err := c.Bind(&req); err != nil {
+		return err
+	}
+
+	if len(req.SubscriberIDs) == 0 {
+		return echo.NewHTTPError(http.StatusBadRequest, a.i18n.Ts("globals.messages.missingFields", "name", "subscriber_ids"))
+	}
+
+	id := getID(c)
+	if _, err := a.core.GetSequence(id); err != nil {
+		return err
+	}
+
+	if err := a.core.AddSequenceSubscribers(id, req.SubscriberIDs); err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, okResp{true})
+}
+
+// DeleteSequenceSubscribers handles removing subscribers from a sequence.
+func (a *App) DeleteSequenceSubscribers(c echo.Context) error {
+	var req seqSubsReq
+	if err := c.Bind(&req); err != nil {
+		return err
+	}
+
+	if len(req.SubscriberIDs) == 0 {
+		return echo.NewHTTPError(http.StatusBadRequest, a.i18n.Ts("globals.messages.missingFields", "name", "subscriber_ids"))
+	}
+
+	if err := a.core.ExitSequenceSubscribers(getID(c), req.SubscriberIDs); err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, okResp{true})
+}
+
+// validateSequence validates sequence fields and fills in defaults.
+func (a *App) validateSequence(o *models.Sequence) error {
+	if !strHasLen(o.Name, 1, stdInputMaxLen) {
+		return echo.NewHTTPError(http.StatusBadRequest, a.i18n.Ts("globals.messages.invalidFields", "name", "name"))
+	}
+
+	if o.Messenger == "" {
+		o.Messenger = emailMsgr
+	}
+	if !a.manager.HasMessenger(o.Messenger) {
+		return echo.NewHTTPError(http.StatusBadRequest, a.i18n.Ts("campaigns.fieldInvalidMessenger", "name", o.Messenger))
+	}
+
+	for n, s := range o.Steps {
+		if !s.TemplateID.Valid {
+			return echo.NewHTTPError(http.StatusBadRequest,
+				a.i18n.Ts("globals.messages.missingFields", "name", fmt.Sprintf("steps[%d].template_id", n)))
+		}
+
+		tpl, err := a.core.GetTemplate(s.TemplateID.Int, true)
+		if err != nil {
+			return err
+		}
+		if tpl.Type != models.TemplateTypeTx {
+			return echo.NewHTTPError(http.StatusBadRequest, a.i18n.T("templates.txTemplateOnly"))
+		}
+
+		if s.Delay == "" {
+			o.Steps[n].Delay = "0"
+		} else if !utils.ValidateInterval(s.Delay) {
+			return echo.NewHTTPError(http.StatusBadRequest,
+				a.i18n.Ts("globals.messages.invalidFields", "name", fmt.Sprintf("steps[%d].delay", n)))
+		}
+	}
+
+	return nil
+}
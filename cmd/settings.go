@@ -2,11 +2,15 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"io"
+	"net"
 	"net/http"
+	"net/mail"
 	"net/url"
 	"regexp"
 	"runtime"
+	"slices"
 	"strings"
 	"syscall"
 	"time"
@@ -19,6 +23,10 @@ import (
 	"github.com/knadh/koanf/providers/rawbytes"
 	"github.com/knadh/koanf/v2"
 	"github.com/knadh/listmonk/internal/auth"
+	"github.com/knadh/listmonk/internal/bounce"
+	"github.com/knadh/listmonk/internal/bounce/mailbox"
+	"github.com/knadh/listmonk/internal/bounce/webhooks"
+	"github.com/knadh/listmonk/internal/mailauth"
 	"github.com/knadh/listmonk/internal/messenger/email"
 	"github.com/knadh/listmonk/internal/notifs"
 	"github.com/knadh/listmonk/models"
@@ -63,6 +71,11 @@ func (a *App) GetSettings(c echo.Context) error {
 	// Empty out passwords.
 	for i := range s.SMTP {
 		s.SMTP[i].Password = strings.Repeat(pwdMask, utf8.RuneCountInString(s.SMTP[i].Password))
+		for j, k := range s.SMTP[i].DKIM.Keys {
+			if k.PrivateKey != "" {
+				s.SMTP[i].DKIM.Keys[j].PrivateKey = strings.Repeat(pwdMask, 10)
+			}
+		}
 	}
 	for i := range s.BounceBoxes {
 		s.BounceBoxes[i].Password = strings.Repeat(pwdMask, utf8.RuneCountInString(s.BounceBoxes[i].Password))
@@ -75,6 +88,13 @@ func (a *App) GetSettings(c echo.Context) error {
 	s.SendgridKey = strings.Repeat(pwdMask, utf8.RuneCountInString(s.SendgridKey))
 	s.BouncePostmark.Password = strings.Repeat(pwdMask, utf8.RuneCountInString(s.BouncePostmark.Password))
 	s.BounceForwardEmail.Key = strings.Repeat(pwdMask, utf8.RuneCountInString(s.BounceForwardEmail.Key))
+	s.BounceMailgun.Key = strings.Repeat(pwdMask, utf8.RuneCountInString(s.BounceMailgun.Key))
+	s.BounceSparkPost.Password = strings.Repeat(pwdMask, utf8.RuneCountInString(s.BounceSparkPost.Password))
+	s.BounceBrevo.Password = strings.Repeat(pwdMask, utf8.RuneCountInString(s.BounceBrevo.Password))
+	s.BounceMailjet.Password = strings.Repeat(pwdMask, utf8.RuneCountInString(s.BounceMailjet.Password))
+	s.BounceResend.Key = strings.Repeat(pwdMask, utf8.RuneCountInString(s.BounceResend.Key))
+	s.BounceWebhookSecurity.HMACSecret = strings.Repeat(pwdMask, utf8.RuneCountInString(s.BounceWebhookSecurity.HMACSecret))
+	s.InboundMailbox.Password = strings.Repeat(pwdMask, utf8.RuneCountInString(s.InboundMailbox.Password))
 	s.SecurityCaptcha.HCaptcha.Secret = strings.Repeat(pwdMask, utf8.RuneCountInString(s.SecurityCaptcha.HCaptcha.Secret))
 	s.OIDC.ClientSecret = strings.Repeat(pwdMask, utf8.RuneCountInString(s.OIDC.ClientSecret))
 
@@ -131,6 +151,19 @@ func (a *App) UpdateSettings(c echo.Context) error {
 			set.SMTP[i].UUID = uuid.Must(uuid.NewV4()).String()
 		}
 
+		// Validate the warm-up plan.
+		if s.Warmup.Enabled {
+			if _, err := time.Parse("2006-01-02", s.Warmup.StartDate); err != nil {
+				return echo.NewHTTPError(http.StatusBadRequest,
+					a.i18n.Ts("globals.messages.invalidFields", "name", "warmup.start_date"))
+			}
+
+			if len(s.Warmup.Schedule) == 0 || slices.ContainsFunc(s.Warmup.Schedule, func(n int) bool { return n < 1 }) {
+				return echo.NewHTTPError(http.StatusBadRequest,
+					a.i18n.Ts("globals.messages.invalidFields", "name", "warmup.schedule"))
+			}
+		}
+
 		// Ensure the HOST is trimmed of any whitespace.
 		// This is a common mistake when copy-pasting SMTP settings.
 		set.SMTP[i].Host = strings.TrimSpace(s.Host)
@@ -144,6 +177,24 @@ func (a *App) UpdateSettings(c echo.Context) error {
 				}
 			}
 		}
+
+		// DKIM keys. Existing private keys are copied by matching the domain and selector.
+		for j, k := range s.DKIM.Keys {
+			k.Domain = strings.ToLower(strings.TrimSpace(k.Domain))
+			k.Selector = strings.ToLower(strings.TrimSpace(k.Selector))
+			if k.PrivateKey == "" {
+				k.PrivateKey = getDKIMKey(cur, s.UUID, k.Domain, k.Selector)
+			}
+
+			set.SMTP[i].DKIM.Keys[j] = k
+
+			if s.DKIM.Enabled {
+				if _, err := mailauth.NewSigner(k.Domain, k.Selector, k.PrivateKey); err != nil {
+					return echo.NewHTTPError(http.StatusBadRequest,
+						a.i18n.Ts("globals.messages.invalidFields", "name", "dkim.keys")+": "+err.Error())
+				}
+			}
+		}
 	}
 	if !has {
 		return echo.NewHTTPError(http.StatusBadRequest, a.i18n.T("settings.errorNoSMTP"))
@@ -170,6 +221,24 @@ func (a *App) UpdateSettings(c echo.Context) error {
 			return echo.NewHTTPError(http.StatusBadRequest, a.i18n.T("settings.bounces.invalidScanInterval"))
 		}
 
+		if s.Type == "imap" {
+			set.BounceBoxes[i].Folder = strings.TrimSpace(s.Folder)
+			if set.BounceBoxes[i].Folder == "" {
+				set.BounceBoxes[i].Folder = "INBOX"
+			}
+			if s.Mode != mailbox.ModeIdle {
+				set.BounceBoxes[i].Mode = mailbox.ModePoll
+			}
+			if s.ProcessedAction != mailbox.ActionMove {
+				set.BounceBoxes[i].ProcessedAction = mailbox.ActionFlag
+			}
+
+			set.BounceBoxes[i].ProcessedFolder = strings.TrimSpace(s.ProcessedFolder)
+			if set.BounceBoxes[i].ProcessedAction == mailbox.ActionMove && set.BounceBoxes[i].ProcessedFolder == "" {
+				return echo.NewHTTPError(http.StatusBadRequest, a.i18n.Ts("globals.messages.invalidFields", "name", "processed_folder"))
+			}
+		}
+
 		// If there's no password coming in from the frontend, copy the existing
 		// password by matching the UUID.
 		if s.Password == "" {
@@ -221,6 +290,24 @@ func (a *App) UpdateSettings(c echo.Context) error {
 	if set.BounceForwardEmail.Key == "" {
 		set.BounceForwardEmail.Key = cur.BounceForwardEmail.Key
 	}
+	if set.BounceMailgun.Key == "" {
+		set.BounceMailgun.Key = cur.BounceMailgun.Key
+	}
+	if set.BounceSparkPost.Password == "" {
+		set.BounceSparkPost.Password = cur.BounceSparkPost.Password
+	}
+	if set.BounceBrevo.Password == "" {
+		set.BounceBrevo.Password = cur.BounceBrevo.Password
+	}
+	if set.BounceMailjet.Password == "" {
+		set.BounceMailjet.Password = cur.BounceMailjet.Password
+	}
+	if set.BounceResend.Key == "" {
+		set.BounceResend.Key = cur.BounceResend.Key
+	}
+	if set.BounceWebhookSecurity.HMACSecret == "" {
+		set.BounceWebhookSecurity.HMACSecret = cur.BounceWebhookSecurity.HMACSecret
+	}
 	if set.SecurityCaptcha.HCaptcha.Secret == "" {
 		set.SecurityCaptcha.HCaptcha.Secret = cur.SecurityCaptcha.HCaptcha.Secret
 	}
@@ -285,14 +372,97 @@ func (a *App) UpdateSettings(c echo.Context) error {
 		}
 	}
 
+	if set.AppImportConcurrency < 1 {
+		set.AppImportConcurrency = 1
+	}
+	if set.AppTrashRetentionDays < 0 {
+		set.AppTrashRetentionDays = 0
+	}
+	for typ, b := range set.BounceActions {
+		if b.WindowDays < 0 {
+			b.WindowDays = 0
+			set.BounceActions[typ] = b
+		}
+	}
+	if set.BounceSoftEscalation < 0 {
+		set.BounceSoftEscalation = 0
+	}
+	if set.BounceDecayDays < 0 {
+		set.BounceDecayDays = 0
+	}
+	if set.BounceWebhookSecurity.MaxAge < 1 {
+		set.BounceWebhookSecurity.MaxAge = int(webhooks.DefaultMaxAge.Seconds())
+	}
+
+	// Inbound mailbox.
+	ib := &set.InboundMailbox
+	ib.Host = strings.TrimSpace(ib.Host)
+	if set.InboundEnabled {
+		if ib.Type != "pop" && ib.Type != "imap" {
+			return echo.NewHTTPError(http.StatusBadRequest, a.i18n.Ts("globals.messages.invalidFields", "name", "inbound type"))
+		}
+		if d, _ := time.ParseDuration(ib.ScanInterval); d.Minutes() < 1 {
+			return echo.NewHTTPError(http.StatusBadRequest, a.i18n.T("settings.bounces.invalidScanInterval"))
+		}
+	}
+	if ib.Type == "imap" {
+		ib.Folder = strings.TrimSpace(ib.Folder)
+		if ib.Folder == "" {
+			ib.Folder = "INBOX"
+		}
+		if ib.Mode != mailbox.ModeIdle {
+			ib.Mode = mailbox.ModePoll
+		}
+		if ib.ProcessedAction != mailbox.ActionMove {
+			ib.ProcessedAction = mailbox.ActionFlag
+		}
+
+		ib.ProcessedFolder = strings.TrimSpace(ib.ProcessedFolder)
+		if set.InboundEnabled && ib.ProcessedAction == mailbox.ActionMove && ib.ProcessedFolder == "" {
+			return echo.NewHTTPError(http.StatusBadRequest, a.i18n.Ts("globals.messages.invalidFields", "name", "processed_folder"))
+		}
+	}
+	if ib.Password == "" {
+		ib.Password = cur.InboundMailbox.Password
+	}
+
+	// Inbound reply forwarding.
+	if set.InboundForwardEmail = strings.TrimSpace(set.InboundForwardEmail); set.InboundForwardEmail != "" {
+		em, err := a.importer.SanitizeEmail(set.InboundForwardEmail)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, a.i18n.Ts("globals.messages.invalidFields", "name", "forward_email"))
+		}
+		set.InboundForwardEmail = em
+	}
+	if set.InboundForwardWebhook = strings.TrimSpace(set.InboundForwardWebhook); set.InboundForwardWebhook != "" {
+		u, err := url.Parse(set.InboundForwardWebhook)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return echo.NewHTTPError(http.StatusBadRequest, a.i18n.Ts("globals.messages.invalidFields", "name", "forward_webhook"))
+		}
+	}
+
+	// List-Unsubscribe mailto address.
+	if set.PrivacyUnsubMailto = strings.TrimSpace(set.PrivacyUnsubMailto); set.PrivacyUnsubMailto != "" {
+		em, err := a.importer.SanitizeEmail(set.PrivacyUnsubMailto)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, a.i18n.Ts("globals.messages.invalidFields", "name", "unsubscribe_mailto"))
+		}
+		set.PrivacyUnsubMailto = em
+	}
+
+	// Validate the webhook IP allowlists.
+	if _, err := bounce.ParseAllowlist(set.BounceWebhookSecurity.Allowlist); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, a.i18n.Ts("globals.messages.invalidData")+": "+err.Error())
+	}
+
 	// Update the settings in the DB.
 	if err := a.core.UpdateSettings(set); err != nil {
 		return err
 	}
 
-	// If there are any active campaigns, don't do an auto reload and
+	// If there are any active campaigns or imports, don't do an auto reload and
 	// warn the user on the frontend.
-	if a.manager.HasRunningCampaigns() {
+	if a.manager.HasRunningCampaigns() || a.importer.HasRunningImports() {
 		a.Lock()
 		a.needsRestart = true
 		a.Unlock()
@@ -338,6 +508,10 @@ func (a *App) TestSMTPSettings(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusBadRequest, a.i18n.Ts("globals.messages.internalError"))
 	}
 
+	if err := a.fillDKIMKeys(req.UUID, req.DKIM.Keys); err != nil {
+		return err
+	}
+
 	to := ko.String("email")
 	if to == "" {
 		return echo.NewHTTPError(http.StatusBadRequest, a.i18n.Ts("globals.messages.missingFields", "name", "email"))
@@ -347,7 +521,7 @@ func (a *App) TestSMTPSettings(c echo.Context) error {
 	req.MaxConns = 1
 	req.IdleTimeout = time.Second * 2
 	req.PoolWaitTimeout = time.Second * 2
-	msgr, err := email.New("", req)
+	msgr, err := email.New("", nil, req)
 	if err != nil {
 		return echo.NewHTTPError(http.StatusBadRequest,
 			a.i18n.Ts("globals.messages.errorCreating", "name", "SMTP", "error", err.Error()))
@@ -372,6 +546,103 @@ func (a *App) TestSMTPSettings(c echo.Context) error {
 	return c.JSON(http.StatusOK, okResp{a.bufLog.Lines()})
 }
 
+// CheckSMTPDNS checks the SPF, DKIM and DMARC DNS records of the From domain
+// and whether they align for an SMTP server and its DKIM keys.
+func (a *App) CheckSMTPDNS(c echo.Context) error {
+	var req struct {
+		UUID         string              `json:"uuid"`
+		EmailHeaders []map[string]string `json:"email_headers"`
+		DKIM         email.DKIM          `json:"dkim"`
+		FromEmail    string              `json:"from_email"`
+	}
+	if err := c.Bind(&req); err != nil {
+		return err
+	}
+
+	if req.FromEmail == "" {
+		req.FromEmail = a.cfg.FromEmail
+	}
+	from, err := mail.ParseAddress(req.FromEmail)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			a.i18n.Ts("globals.messages.invalidFields", "name", "from_email"))
+	}
+
+	opt := mailauth.CheckOpt{}
+	_, opt.FromDomain, _ = strings.Cut(from.Address, "@")
+
+	// SPF applies to the envelope sender, which is the Return-Path if it's set.
+	for _, h := range req.EmailHeaders {
+		for k, v := range h {
+			if !strings.EqualFold(k, "Return-Path") {
+				continue
+			}
+			if addr, err := mail.ParseAddress(v); err == nil {
+				_, opt.EnvelopeDomain, _ = strings.Cut(addr.Address, "@")
+			}
+		}
+	}
+
+	if req.DKIM.Enabled {
+		if err := a.fillDKIMKeys(req.UUID, req.DKIM.Keys); err != nil {
+			return err
+		}
+
+		for _, k := range req.DKIM.Keys {
+			s, err := mailauth.NewSigner(k.Domain, k.Selector, k.PrivateKey)
+			if err != nil {
+				return echo.NewHTTPError(http.StatusBadRequest,
+					a.i18n.Ts("globals.messages.invalidFields", "name", "dkim.keys")+": "+err.Error())
+			}
+			opt.Signers = append(opt.Signers, s)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request().Context(), time.Second*10)
+	defer cancel()
+
+	return c.JSON(http.StatusOK, okResp{mailauth.Check(ctx, net.DefaultResolver, opt)})
+}
+
+// fillDKIMKeys fills in the stored private keys of an SMTP server's DKIM keys
+// that are empty or masked as the frontend doesn't have them.
+func (a *App) fillDKIMKeys(uuid string, keys []email.DKIMKey) error {
+	var cur *models.Settings
+	for i, k := range keys {
+		if k.PrivateKey != "" && !strings.Contains(k.PrivateKey, pwdMask) {
+			continue
+		}
+
+		if cur == nil {
+			s, err := a.core.GetSettings()
+			if err != nil {
+				return err
+			}
+			cur = &s
+		}
+		keys[i].PrivateKey = getDKIMKey(*cur, uuid, k.Domain, k.Selector)
+	}
+
+	return nil
+}
+
+// getDKIMKey returns the stored private key of an SMTP server's DKIM domain and selector.
+func getDKIMKey(set models.Settings, uuid, domain, selector string) string {
+	for _, s := range set.SMTP {
+		if s.UUID != uuid {
+			continue
+		}
+
+		for _, k := range s.DKIM.Keys {
+			if strings.EqualFold(k.Domain, strings.TrimSpace(domain)) && strings.EqualFold(k.Selector, strings.TrimSpace(selector)) {
+				return k.PrivateKey
+			}
+		}
+	}
+
+	return ""
+}
+
 func (a *App) GetAboutInfo(c echo.Context) error {
 	var mem runtime.MemStats
 	runtime.ReadMemStats(&mem)
@@ -12,6 +12,7 @@ import (
 
 	"github.com/knadh/listmonk/internal/auth"
 	"github.com/knadh/listmonk/internal/i18n"
+	"github.com/knadh/listmonk/internal/manager"
 	"github.com/knadh/listmonk/internal/notifs"
 	"github.com/knadh/listmonk/internal/subimporter"
 	"github.com/knadh/listmonk/models"
@@ -221,6 +222,7 @@ func (a *App) CreateSubscriber(c echo.Context) error {
 	if err != nil {
 		return err
 	}
+	a.recordActivity(c, []int{sub.ID}, nil, models.ActivityCreated, models.JSON{"list_ids": subscriberListIDs(sub)})
 
 	return c.JSON(http.StatusOK, okResp{sub})
 }
@@ -254,12 +256,19 @@ func (a *App) UpdateSubscriber(c echo.Context) error {
 	// Filter lists against the current user's permitted lists.
 	listIDs := user.FilterListsByPerm(auth.PermTypeManage, req.Lists)
 
-	// Update the subscriber in the DB.
+	// Get the subscriber as it is for recording the changes.
 	id := getID(c)
+	old, err := a.core.GetSubscriber(id, "", "")
+	if err != nil {
+		return err
+	}
+
+	// Update the subscriber in the DB.
 	out, _, err := a.core.UpdateSubscriberWithLists(id, req.Subscriber, listIDs, nil, req.PreconfirmSubs, true, false)
 	if err != nil {
 		return err
 	}
+	a.recordSubscriberChanges(c, old, out)
 
 	return c.JSON(http.StatusOK, okResp{out})
 }
@@ -288,6 +297,7 @@ func (a *App) BlocklistSubscriber(c echo.Context) error {
 	if err := a.core.BlocklistSubscribers([]int{id}); err != nil {
 		return err
 	}
+	a.recordActivity(c, []int{id}, nil, models.ActivityBlocklisted, nil)
 
 	return c.JSON(http.StatusOK, okResp{true})
 }
@@ -308,6 +318,7 @@ func (a *App) BlocklistSubscribers(c echo.Context) error {
 	if err := a.core.BlocklistSubscribers(req.SubscriberIDs); err != nil {
 		return err
 	}
+	a.recordActivity(c, req.SubscriberIDs, nil, models.ActivityBlocklisted, nil)
 
 	return c.JSON(http.StatusOK, okResp{true})
 }
@@ -357,14 +368,22 @@ func (a *App) ManageSubscriberLists(c echo.Context) error {
 	}
 
 	// Run the action in the DB.
-	var err error
+	var (
+		err  error
+		typ  string
+		data = models.JSON{"list_ids": listIDs}
+	)
 	switch req.Action {
 	case "add":
 		err = a.core.AddSubscriptions(subIDs, listIDs, req.Status)
+		typ = models.ActivitySubscribed
+		data["status"] = req.Status
 	case "remove":
 		err = a.core.DeleteSubscriptions(subIDs, listIDs)
+		typ = models.ActivitySubscriptionRemoved
 	case "unsubscribe":
 		err = a.core.UnsubscribeLists(subIDs, listIDs, nil)
+		typ = models.ActivityUnsubscribed
 	default:
 		return echo.NewHTTPError(http.StatusBadRequest, a.i18n.T("subscribers.invalidAction"))
 	}
@@ -372,15 +391,16 @@ func (a *App) ManageSubscriberLists(c echo.Context) error {
 	if err != nil {
 		return err
 	}
+	a.recordActivity(c, subIDs, nil, typ, data)
 
 	return c.JSON(http.StatusOK, okResp{true})
 }
 
 // DeleteSubscriber handles deletion of a single subscriber.
 func (a *App) DeleteSubscriber(c echo.Context) error {
-	// Delete the subscribers from the DB.
+	// Move the subscriber to the trash, or delete it if the trash is disabled.
 	id := getID(c)
-	if err := a.core.DeleteSubscribers([]int{id}, nil); err != nil {
+	if err := a.deleteSubscribers([]int{id}); err != nil {
 		return err
 	}
 
@@ -400,8 +420,8 @@ func (a *App) DeleteSubscribers(c echo.Context) error {
 			a.i18n.Ts("globals.messages.errorInvalidIDs", "error", "ids"))
 	}
 
-	// Delete the subscribers from the DB.
-	if err := a.core.DeleteSubscribers(ids, nil); err != nil {
+	// Move the subscribers to the trash, or delete them if the trash is disabled.
+	if err := a.deleteSubscribers(ids); err != nil {
 		return err
 	}
 
@@ -437,8 +457,14 @@ func (a *App) DeleteSubscribersByQuery(c echo.Context) error {
 		}
 	}
 
-	// Delete the subscribers from the DB.
-	if err := a.core.DeleteSubscribersByQuery(req.Search, req.Query, req.ListIDs, req.SubscriptionStatus); err != nil {
+	// Move the subscribers to the trash, or delete them if the trash is disabled.
+	var err error
+	if a.cfg.TrashRetentionDays > 0 {
+		err = a.core.TrashSubscribersByQuery(req.Search, req.Query, req.ListIDs, req.SubscriptionStatus)
+	} else {
+		err = a.core.DeleteSubscribersByQuery(req.Search, req.Query, req.ListIDs, req.SubscriptionStatus)
+	}
+	if err != nil {
 		return err
 	}
 
@@ -473,6 +499,10 @@ func (a *App) BlocklistSubscribersByQuery(c echo.Context) error {
 		}
 	}
 
+	// Record the activity before blocklisting as the subscribers may no longer
+	// match the query after.
+	a.recordActivityByQuery(c, req, req.ListIDs, models.ActivityBlocklisted, nil)
+
 	// Update the subscribers in the DB.
 	if err := a.core.BlocklistSubscribersByQuery(req.Search, req.Query, req.ListIDs, req.SubscriptionStatus); err != nil {
 		return err
@@ -511,6 +541,19 @@ func (a *App) ManageSubscriberListsByQuery(c echo.Context) error {
 	sourceListIDs := user.FilterListsByPerm(auth.PermTypeGet|auth.PermTypeManage, req.ListIDs)
 	targetListIDs := user.FilterListsByPerm(auth.PermTypeGet|auth.PermTypeManage, req.TargetListIDs)
 
+	// Record the activity before running the action as the subscribers may no
+	// longer match the query after.
+	data := models.JSON{"list_ids": targetListIDs}
+	switch req.Action {
+	case "add":
+		data["status"] = req.Status
+		a.recordActivityByQuery(c, req, sourceListIDs, models.ActivitySubscribed, data)
+	case "remove":
+		a.recordActivityByQuery(c, req, sourceListIDs, models.ActivitySubscriptionRemoved, data)
+	case "unsubscribe":
+		a.recordActivityByQuery(c, req, sourceListIDs, models.ActivityUnsubscribed, data)
+	}
+
 	// Run the action in the DB.
 	var err error
 	switch req.Action {
@@ -671,7 +714,7 @@ func formatSQLExp(q string) string {
 // makeOptinNotifyHook returns an enclosed callback that sends optin confirmation e-mails.
 // This is plugged into the 'core' package to send optin confirmations when a new subscriber is
 // created via `core.CreateSubscriber()`.
-func makeOptinNotifyHook(unsubHeader bool, u *UrlConfig, q *models.Queries, i *i18n.I18n) func(sub models.Subscriber, listIDs []int) (int, error) {
+func makeOptinNotifyHook(unsubHeader bool, unsubMailto string, u *UrlConfig, q *models.Queries, i *i18n.I18n) func(sub models.Subscriber, listIDs []int) (int, error) {
 	return func(sub models.Subscriber, listIDs []int) (int, error) {
 		// Fetch double opt-in lists from the given list IDs.
 		// Get the list of subscription lists where the subscriber hasn't confirmed.
@@ -704,9 +747,8 @@ func makeOptinNotifyHook(unsubHeader bool, u *UrlConfig, q *models.Queries, i *i
 
 		// Attach List-Unsubscribe headers?
 		if unsubHeader {
-			unsubURL := fmt.Sprintf(u.UnsubURL, dummyUUID, sub.UUID)
 			hdr.Set("List-Unsubscribe-Post", "List-Unsubscribe=One-Click")
-			hdr.Set("List-Unsubscribe", `<`+unsubURL+`>`)
+			hdr.Set("List-Unsubscribe", manager.ListUnsubscribe(u.OneClickURL, unsubMailto, dummyUUID, sub.UUID))
 		}
 
 		// Send the e-mail.
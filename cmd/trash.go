@@ -0,0 +1,151 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/knadh/listmonk/internal/auth"
+	"github.com/knadh/listmonk/internal/core"
+	"github.com/knadh/listmonk/models"
+	"github.com/labstack/echo/v4"
+)
+
+// trashPerms are the permissions required to view and manage (restore, purge)
+// each type of trashed item.
+var trashPerms = map[string][2]string{
+	models.TrashSubscribers: {auth.PermSubscribersGetAll, auth.PermSubscribersManage},
+	models.TrashLists:       {auth.PermListGetAll, auth.PermListManageAll},
+	models.TrashCampaigns:   {auth.PermCampaignsGetAll, auth.PermCampaignsManageAll},
+}
+
+// GetTrash handles the retrieval of trashed subscribers, lists or campaigns.
+func (a *App) GetTrash(c echo.Context) error {
+	typ, err := a.getTrashType(c, false)
+	if err != nil {
+		return err
+	}
+
+	pg := a.pg.NewFromURL(c.Request().URL.Query())
+	out, total, err := a.core.QueryTrash(typ, pg.Offset, pg.Limit)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, okResp{models.PageResults{
+		Results: out,
+		Total:   total,
+		Page:    pg.Page,
+		PerPage: pg.PerPage,
+	}})
+}
+
+// RestoreTrash handles the restoration of trashed subscribers, lists or campaigns.
+func (a *App) RestoreTrash(c echo.Context) error {
+	typ, err := a.getTrashType(c, true)
+	if err != nil {
+		return err
+	}
+
+	var req struct {
+		IDs []int `json:"ids"`
+	}
+	if err := c.Bind(&req); err != nil {
+		return err
+	}
+	if len(req.IDs) == 0 {
+		return echo.NewHTTPError(http.StatusBadRequest, a.i18n.T("subscribers.errorNoIDs"))
+	}
+
+	n, err := a.core.RestoreTrash(typ, req.IDs)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, okResp{struct {
+		Count int `json:"count"`
+	}{n}})
+}
+
+// PurgeTrash handles the permanent deletion of trashed subscribers, lists or
+// campaigns, either the given IDs or all of them.
+func (a *App) PurgeTrash(c echo.Context) error {
+	typ, err := a.getTrashType(c, true)
+	if err != nil {
+		return err
+	}
+
+	all, _ := strconv.ParseBool(c.QueryParam("all"))
+	ids, err := parseStringIDs(c.Request().URL.Query()["id"])
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			a.i18n.Ts("globals.messages.errorInvalidIDs", "error", err.Error()))
+	}
+	if !all && len(ids) == 0 {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			a.i18n.Ts("globals.messages.errorInvalidIDs", "error", "ids"))
+	}
+
+	n, err := a.core.PurgeTrash(typ, ids, all, time.Now())
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, okResp{struct {
+		Count int `json:"count"`
+	}{n}})
+}
+
+// getTrashType returns the type of trashed items in the URI after checking
+// that the user has the permission to view or manage them.
+func (a *App) getTrashType(c echo.Context, manage bool) (string, error) {
+	typ := c.Param("type")
+	perms, ok := trashPerms[typ]
+	if !ok {
+		return "", echo.NewHTTPError(http.StatusBadRequest, a.i18n.Ts("globals.messages.invalidFields", "name", "type"))
+	}
+
+	perm := perms[0]
+	if manage {
+		perm = perms[1]
+	}
+
+	user := auth.GetUser(c)
+	if !user.HasPerm(perm) {
+		return "", echo.NewHTTPError(http.StatusForbidden, a.i18n.Ts("globals.messages.permissionDenied", "name", perm))
+	}
+
+	return typ, nil
+}
+
+// deleteSubscribers moves subscribers to the trash, or deletes them if the
+// trash is disabled.
+func (a *App) deleteSubscribers(ids []int) error {
+	if a.cfg.TrashRetentionDays > 0 {
+		return a.core.TrashSubscribers(ids, nil)
+	}
+
+	return a.core.DeleteSubscribers(ids, nil)
+}
+
+// runTrashPurge periodically deletes the subscribers, lists and campaigns
+// that have been in the trash for longer than the retention period.
+func runTrashPurge(co *core.Core, retentionDays int, lo *log.Logger) {
+	for {
+		before := time.Now().AddDate(0, 0, -retentionDays)
+		for _, typ := range []string{models.TrashCampaigns, models.TrashLists, models.TrashSubscribers} {
+			n, err := co.PurgeTrash(typ, nil, true, before)
+			if err != nil {
+				lo.Printf("error purging trashed %s: %v", typ, err)
+				continue
+			}
+
+			if n > 0 {
+				lo.Printf("purged %d trashed %s", n, typ)
+			}
+		}
+
+		time.Sleep(time.Hour)
+	}
+}
@@ -4,13 +4,16 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"log"
 	"net/http"
 	"net/textproto"
 	"strings"
 
+	"github.com/knadh/listmonk/internal/core"
 	"github.com/knadh/listmonk/internal/manager"
 	"github.com/knadh/listmonk/models"
 	"github.com/labstack/echo/v4"
+	null "gopkg.in/volatiletech/null.v6"
 )
 
 // isBroadcastMessenger determines if a messenger is a broadcast type (sends once per channel)
@@ -155,6 +158,39 @@ func (a *App) SendTxMessage(c echo.Context) error {
 		}
 	}
 
+	// Subscribers who are blocklisted, or have hard bounced or complained, aren't
+	// sent e-mails unless the message overrides suppression. Other messengers,
+	// eg: SMS and webhooks, aren't affected.
+	sup := map[int]bool{}
+	if !m.IgnoreSuppression && len(subscribers) > 0 {
+		ids := make([]int, 0, len(subscribers))
+		for _, s := range subscribers {
+			ids = append(ids, s.ID)
+		}
+
+		res, err := a.core.GetSuppressedSubscribers(ids)
+		if err != nil {
+			return err
+		}
+		sup = res
+	}
+
+	// IDs of subscribers who were skipped for suppression and who were sent
+	// messages, if any, on other messengers.
+	var (
+		skipped = map[int]bool{}
+		sent    = map[int]bool{}
+	)
+	isSuppressed := func(sub models.Subscriber, messenger string) bool {
+		if messenger != emailMsgr || !sup[sub.ID] {
+			sent[sub.ID] = true
+			return false
+		}
+
+		skipped[sub.ID] = true
+		return true
+	}
+
 	// Enhanced multi-channel support with channels API
 	if len(m.Channels) > 0 {
 		// Separate broadcast channels from subscriber-based channels
@@ -241,6 +277,10 @@ func (a *App) SendTxMessage(c echo.Context) error {
 		// Process subscriber-based channels (email, etc.) - existing per-subscriber logic
 		for _, sub := range subscribers {
 			for _, channel := range subscriberChannels {
+				if isSuppressed(sub, channel.Channel) {
+					continue
+				}
+
 				// Get template for this specific channel
 				channelTpl, err := a.manager.GetTpl(channel.TemplateID)
 				if err != nil {
@@ -318,6 +358,10 @@ func (a *App) SendTxMessage(c echo.Context) error {
 
 			// Send to all specified messengers
 			for _, messenger := range messengers {
+				if isSuppressed(sub, messenger) {
+					continue
+				}
+
 				// Prepare the final message for this messenger
 				msg := models.Message{}
 				msg.Subscriber = sub
@@ -353,6 +397,34 @@ func (a *App) SendTxMessage(c echo.Context) error {
 		}
 	}
 
+	// Subscribers who were only skipped for suppression aren't sent anything.
+	// Suppressed subscribers in lists are skipped silently.
+	subIDs := make([]int, 0, len(subscribers))
+	for _, s := range subscribers {
+		if !skipped[s.ID] || sent[s.ID] {
+			subIDs = append(subIDs, s.ID)
+			continue
+		}
+
+		if len(m.ListIDs) == 0 && len(m.ListNames) == 0 {
+			notFound = append(notFound, fmt.Sprintf("Subscriber (%s) is suppressed", s.Email))
+		}
+	}
+
+	// Record the message in the subscribers' activity timelines.
+	if len(subIDs) > 0 {
+
+		data := models.JSON{"template_id": m.TemplateID}
+		if len(m.Channels) > 0 {
+			channels := make([]string, 0, len(m.Channels))
+			for _, ch := range m.Channels {
+				channels = append(channels, ch.Channel)
+			}
+			data["channels"] = channels
+		}
+		a.recordActivity(c, subIDs, nil, models.ActivityTxSent, data)
+	}
+
 	if len(notFound) > 0 {
 		return echo.NewHTTPError(http.StatusBadRequest, strings.Join(notFound, "; "))
 	}
@@ -432,3 +504,76 @@ func (a *App) validateTxMessage(m models.TxMessage) (models.TxMessage, error) {
 
 	return m, nil
 }
+
+// txSender renders tx templates for subscribers and pushes them out.
+// It's used by background senders such as sequences and automations.
+type txSender struct {
+	core      *core.Core
+	manager   *manager.Manager
+	fromEmail string
+	log       *log.Logger
+}
+
+// send renders the given tx template for a subscriber and pushes the message.
+// It returns false without an error if the message was skipped because the
+// template doesn't exist or couldn't be rendered.
+func (t *txSender) send(subID int, tplID null.Int, subject, fromEmail, messenger string, data map[string]any) (bool, error) {
+	if !tplID.Valid {
+		return false, nil
+	}
+
+	tpl, err := t.manager.GetTpl(tplID.Int)
+	if err != nil {
+		t.log.Printf("skipping message to subscriber %d: %v", subID, err)
+		return false, nil
+	}
+
+	sub, err := t.core.GetSubscriber(subID, "", "")
+	if err != nil {
+		return false, err
+	}
+
+	// Don't send to blocklisted, hard bounced or complained subscribers.
+	sup, err := t.core.GetSuppressedSubscribers([]int{subID})
+	if err != nil {
+		return false, err
+	}
+	if sup[subID] {
+		t.log.Printf("skipping message to suppressed subscriber %d", subID)
+		return false, nil
+	}
+
+	m := models.TxMessage{
+		TemplateID: tplID.Int,
+		Subject:    subject,
+		FromEmail:  fromEmail,
+		Messenger:  messenger,
+		Data:       data,
+	}
+	if m.FromEmail == "" {
+		m.FromEmail = t.fromEmail
+	}
+	if m.Messenger == "" {
+		m.Messenger = emailMsgr
+	}
+	if err := m.Render(sub, tpl); err != nil {
+		t.log.Printf("skipping message to subscriber %d: error rendering template %d: %v", subID, tplID.Int, err)
+		return false, nil
+	}
+
+	msg := models.Message{
+		Subscriber:  sub,
+		To:          []string{sub.Email},
+		From:        m.FromEmail,
+		Subject:     m.Subject,
+		ContentType: models.CampaignContentTypeHTML,
+		Messenger:   m.Messenger,
+		Body:        m.Body,
+		Data:        m.Data,
+	}
+	if err := t.manager.PushMessage(msg); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
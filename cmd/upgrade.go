@@ -43,6 +43,7 @@ var migList = []migFunc{
 	{"v5.0.0", migrations.V5_0_0},
 	{"v5.1.0", migrations.V5_1_0},
 	{"v5.2.0", migrations.V5_2_0},
+	{"v5.3.0", migrations.V5_3_0},
 }
 
 // upgrade upgrades the database to the current version by running SQL migration files
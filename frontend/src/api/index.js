@@ -422,6 +422,12 @@ export const testSMTP = async (data) => http.post(
   { loading: models.settings, disableToast: true },
 );
 
+export const checkSMTPDNS = async (data) => http.post(
+  '/api/settings/smtp/check-dns',
+  data,
+  { loading: models.settings, camelCase: false },
+);
+
 export const getLogs = async () => http.get(
   '/api/logs',
   { loading: models.logs, camelCase: false },
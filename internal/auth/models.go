@@ -50,13 +50,20 @@ const (
 	PermSubscribersGetAll     = "subscribers:get_all"
 	PermSubscribersManage     = "subscribers:manage"
 	PermSubscribersImport     = "subscribers:import"
+	PermSubscribersImportAll  = "subscribers:import_all"
 	PermSubscribersSqlQuery   = "subscribers:sql_query"
+	PermSubscribersSync       = "subscribers:sync"
 	PermTxSend                = "tx:send"
 	PermCampaignsGet          = "campaigns:get"
 	PermCampaignsGetAll       = "campaigns:get_all"
 	PermCampaignsGetAnalytics = "campaigns:get_analytics"
 	PermCampaignsManage       = "campaigns:manage"
 	PermCampaignsManageAll    = "campaigns:manage_all"
+	PermSequencesGet          = "sequences:get"
+	PermSequencesManage       = "sequences:manage"
+	PermAutomationsGet        = "automations:get"
+	PermAutomationsManage     = "automations:manage"
+	PermEventsIngest          = "events:ingest"
 	PermBouncesGet            = "bounces:get"
 	PermBouncesManage         = "bounces:manage"
 	PermWebhooksPostBounce    = "webhooks:post_bounce"
@@ -0,0 +1,83 @@
+// Package automation sends the messages queued by event-triggered automations.
+// When an event is ingested, the automations whose event name and filter match
+// it queue a message for the subscriber that is sent once the automation's
+// delay has elapsed.
+package automation
+
+import (
+	"log"
+	"time"
+
+	"github.com/knadh/listmonk/internal/batch"
+	"github.com/knadh/listmonk/models"
+)
+
+// Store represents the DB store for automations.
+type Store interface {
+	NextAutomationJobs(limit int) ([]models.AutomationJob, error)
+	DeleteAutomationJob(id int64, sent bool) error
+}
+
+// Opt represents automation runner options.
+type Opt struct {
+	// How often queued messages are processed.
+	Interval time.Duration
+
+	// Max number of messages to process in one run.
+	BatchSize int
+
+	// SendCB sends an automation message. It should return (false, nil) if the
+	// message was skipped (eg: missing template) and an error if sending failed,
+	// in which case the message is retried on the next run.
+	SendCB func(models.AutomationJob) (bool, error)
+}
+
+// Runner processes queued automation messages.
+type Runner struct {
+	opt   Opt
+	store Store
+	log   *log.Logger
+}
+
+// New returns a new instance of Runner.
+func New(opt Opt, s Store, lo *log.Logger) *Runner {
+	if opt.Interval == 0 {
+		opt.Interval = time.Minute
+	}
+	if opt.BatchSize < 1 {
+		opt.BatchSize = 1000
+	}
+
+	return &Runner{
+		opt:   opt,
+		store: s,
+		log:   lo,
+	}
+}
+
+// Run is a blocking function that periodically sends due automation messages.
+func (r *Runner) Run() {
+	t := time.NewTicker(r.opt.Interval)
+	defer t.Stop()
+
+	for range t.C {
+		if err := r.Process(); err != nil {
+			r.log.Printf("error processing automations: %v", err)
+		}
+	}
+}
+
+// Process sends the automation messages that are due.
+func (r *Runner) Process() error {
+	return batch.Process(batch.Opt[models.AutomationJob]{
+		Size: r.opt.BatchSize,
+		Next: r.store.NextAutomationJobs,
+		Send: r.opt.SendCB,
+		Done: func(j models.AutomationJob, sent bool) error {
+			return r.store.DeleteAutomationJob(j.ID, sent)
+		},
+		OnError: func(j models.AutomationJob, err error) {
+			r.log.Printf("error sending automation %d message to subscriber %d: %v", j.AutomationID, j.SubscriberID, err)
+		},
+	})
+}
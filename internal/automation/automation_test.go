@@ -0,0 +1,66 @@
+package automation
+
+import (
+	"io"
+	"log"
+	"testing"
+
+	"github.com/knadh/listmonk/models"
+)
+
+// store is an in-memory Store with a queue of due jobs.
+type store struct {
+	queue []models.AutomationJob
+	done  map[int64]bool
+}
+
+func (s *store) NextAutomationJobs(limit int) ([]models.AutomationJob, error) {
+	if limit > len(s.queue) {
+		limit = len(s.queue)
+	}
+	return append([]models.AutomationJob{}, s.queue[:limit]...), nil
+}
+
+func (s *store) DeleteAutomationJob(id int64, sent bool) error {
+	for i, j := range s.queue {
+		if j.ID == id {
+			s.queue = append(s.queue[:i], s.queue[i+1:]...)
+			break
+		}
+	}
+	s.done[id] = sent
+	return nil
+}
+
+func TestProcess(t *testing.T) {
+	lo := log.New(io.Discard, "", 0)
+
+	// The same subscriber can have several jobs of an automation queued,
+	// eg: an event ingested twice. Each job is sent and deleted by its ID.
+	st := &store{
+		queue: []models.AutomationJob{
+			{ID: 10, AutomationID: 1, SubscriberID: 1},
+			{ID: 11, AutomationID: 1, SubscriberID: 1},
+			{ID: 12, AutomationID: 2, SubscriberID: 1},
+		},
+		done: map[int64]bool{},
+	}
+
+	var sent []int64
+	r := New(Opt{BatchSize: 2, SendCB: func(j models.AutomationJob) (bool, error) {
+		sent = append(sent, j.ID)
+		return j.AutomationID == 1, nil
+	}}, st, lo)
+	if err := r.Process(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(sent) != 3 || len(st.queue) != 0 {
+		t.Fatalf("expected 3 jobs sent and none left, got %v and %d", sent, len(st.queue))
+	}
+	for id, want := range map[int64]bool{10: true, 11: true, 12: false} {
+		if got, ok := st.done[id]; !ok || got != want {
+			t.Errorf("job %d: expected done with sent=%v, got %v (%v)", id, want, got, ok)
+		}
+	}
+}
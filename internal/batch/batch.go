@@ -0,0 +1,53 @@
+// Package batch processes queued items, eg: scheduled sequence and automation
+// messages, in batches.
+package batch
+
+// Opt represents the callbacks that fetch, send and mark queued items as done.
+type Opt[T any] struct {
+	// Max number of items to fetch at a time.
+	Size int
+
+	// Next returns the next batch of up to limit items that are due.
+	Next func(limit int) ([]T, error)
+
+	// Send sends an item. It should return (false, nil) if the item was
+	// skipped and an error if sending failed, in which case the item is left
+	// in the queue to be retried on the next run.
+	Send func(T) (bool, error)
+
+	// Done marks a sent or skipped item as done so that it's not fetched again.
+	Done func(item T, sent bool) error
+
+	// OnError is called with the items that failed to send.
+	OnError func(T, error)
+}
+
+// Process sends the queued items batch by batch until there are no more.
+// It stops if none of the items in a batch could be sent as the same batch
+// would otherwise be fetched again.
+func Process[T any](o Opt[T]) error {
+	for {
+		items, err := o.Next(o.Size)
+		if err != nil {
+			return err
+		}
+
+		nDone := 0
+		for _, it := range items {
+			sent, err := o.Send(it)
+			if err != nil {
+				o.OnError(it, err)
+				continue
+			}
+
+			if err := o.Done(it, sent); err != nil {
+				return err
+			}
+			nDone++
+		}
+
+		if len(items) < o.Size || nDone == 0 {
+			return nil
+		}
+	}
+}
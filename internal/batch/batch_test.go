@@ -0,0 +1,130 @@
+package batch
+
+import (
+	"errors"
+	"testing"
+)
+
+// queue is an in-memory queue of item IDs. Done items are removed from it.
+type queue struct {
+	items   []int
+	done    map[int]bool
+	failed  []int
+	fetches int
+}
+
+func (q *queue) next(limit int) ([]int, error) {
+	q.fetches++
+	if limit > len(q.items) {
+		limit = len(q.items)
+	}
+	return append([]int{}, q.items[:limit]...), nil
+}
+
+func (q *queue) markDone(id int, sent bool) error {
+	for i, v := range q.items {
+		if v == id {
+			q.items = append(q.items[:i], q.items[i+1:]...)
+			break
+		}
+	}
+	q.done[id] = sent
+	return nil
+}
+
+func TestProcess(t *testing.T) {
+	cases := []struct {
+		name    string
+		num     int
+		size    int
+		send    func(int) (bool, error)
+		done    int
+		sent    int
+		left    int
+		failed  int
+		fetches int
+	}{
+		{
+			name: "all sent in batches", num: 25, size: 10,
+			send: func(int) (bool, error) { return true, nil },
+			done: 25, sent: 25, fetches: 3,
+		},
+		{
+			name: "exact batches", num: 20, size: 10,
+			send: func(int) (bool, error) { return true, nil },
+			done: 20, sent: 20, fetches: 3,
+		},
+		{
+			name: "skipped items are done", num: 5, size: 10,
+			send: func(id int) (bool, error) { return id%2 == 0, nil },
+			done: 5, sent: 2, fetches: 1,
+		},
+		{
+			// Failed items stay in the queue to be retried on the next run
+			// and a batch of failures doesn't loop.
+			name: "failures are retried later", num: 15, size: 5,
+			send: func(id int) (bool, error) {
+				if id > 5 {
+					return false, errors.New("send failed")
+				}
+				return true, nil
+			},
+			done: 5, sent: 5, left: 10, failed: 5, fetches: 2,
+		},
+		{
+			name: "empty", size: 10,
+			send:    func(int) (bool, error) { return true, nil },
+			fetches: 1,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			q := &queue{done: map[int]bool{}}
+			for i := 1; i <= c.num; i++ {
+				q.items = append(q.items, i)
+			}
+
+			err := Process(Opt[int]{
+				Size:    c.size,
+				Next:    q.next,
+				Send:    c.send,
+				Done:    q.markDone,
+				OnError: func(id int, _ error) { q.failed = append(q.failed, id) },
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			sent := 0
+			for _, s := range q.done {
+				if s {
+					sent++
+				}
+			}
+			if len(q.done) != c.done || sent != c.sent || len(q.items) != c.left {
+				t.Errorf("expected %d done, %d sent, %d left; got %d, %d, %d",
+					c.done, c.sent, c.left, len(q.done), sent, len(q.items))
+			}
+			if len(q.failed) != c.failed {
+				t.Errorf("expected %d failures, got %d", c.failed, len(q.failed))
+			}
+			if q.fetches != c.fetches {
+				t.Errorf("expected %d fetches, got %d", c.fetches, q.fetches)
+			}
+		})
+	}
+
+	// Errors from the store stop processing.
+	q := &queue{items: []int{1, 2}, done: map[int]bool{}}
+	err := Process(Opt[int]{
+		Size:    10,
+		Next:    q.next,
+		Send:    func(int) (bool, error) { return true, nil },
+		Done:    func(int, bool) error { return errors.New("db error") },
+		OnError: func(int, error) {},
+	})
+	if err == nil || len(q.items) != 2 {
+		t.Errorf("expected the store error to stop processing, got %v", err)
+	}
+}
@@ -2,7 +2,10 @@ package bounce
 
 import (
 	"errors"
+	"fmt"
 	"log"
+	"net"
+	"strings"
 	"time"
 
 	"github.com/jmoiron/sqlx"
@@ -17,6 +20,12 @@ type Mailbox interface {
 	Scan(limit int, ch chan models.Bounce) error
 }
 
+// MailboxWaiter is a Mailbox that can wait for new messages, eg: with IMAP IDLE,
+// instead of the scanner sleeping for the scan interval.
+type MailboxWaiter interface {
+	Wait(d time.Duration) error
+}
+
 // Opt represents bounce processing options.
 type Opt struct {
 	MailboxEnabled  bool        `json:"mailbox_enabled"`
@@ -35,18 +44,75 @@ type Opt struct {
 		Enabled bool
 		Key     string
 	}
+	Mailgun struct {
+		Enabled bool
+		Key     string
+	}
+	SparkPost struct {
+		Enabled  bool
+		Username string
+		Password string
+	}
+	Brevo struct {
+		Enabled  bool
+		Username string
+		Password string
+	}
+	Mailjet struct {
+		Enabled  bool
+		Username string
+		Password string
+	}
+	Resend struct {
+		Enabled bool
+		Key     string
+	}
+
+	// Delivery events (delivered, open, click) reported by webhooks that are
+	// recorded. Events of other types are dropped.
+	Events struct {
+		Delivered bool `json:"delivered"`
+		Opens     bool `json:"opens"`
+		Clicks    bool `json:"clicks"`
+	}
+
+	// Security checks for incoming webhook requests.
+	Security struct {
+		// HMACSecret, if set, is used to verify the signature of payloads posted
+		// to the native bounce webhook.
+		HMACSecret string `json:"hmac_secret"`
+
+		// MaxAge is the max age in seconds of signed webhook requests, beyond which
+		// they are rejected. Nonces are remembered for this duration to reject replays.
+		// If it's not set, webhooks.DefaultMaxAge is used.
+		MaxAge int `json:"max_age"`
+
+		// Allowlist is a map of service names (bounce for the native webhook) to comma
+		// separated IPs and CIDRs that are allowed to post to the service's webhook.
+		// Services with no entries accept requests from any IP.
+		Allowlist map[string]string `json:"allowlist"`
+	}
 
 	RecordBounceCB func(models.Bounce) error
+	RecordEventCB  func(models.DeliveryEvent) error
 }
 
 // Manager handles e-mail bounces.
 type Manager struct {
 	queue        chan models.Bounce
+	events       chan models.DeliveryEvent
 	mailbox      Mailbox
 	SES          *webhooks.SES
 	Sendgrid     *webhooks.Sendgrid
 	Postmark     *webhooks.Postmark
 	Forwardemail *webhooks.Forwardemail
+	Mailgun      *webhooks.Mailgun
+	SparkPost    *webhooks.SparkPost
+	Brevo        *webhooks.Brevo
+	Mailjet      *webhooks.Mailjet
+	Resend       *webhooks.Resend
+	Native       *webhooks.Native
+	allowlist    map[string][]*net.IPNet
 	queries      *Queries
 	opt          Opt
 	log          *log.Logger
@@ -64,20 +130,35 @@ func New(opt Opt, q *Queries, lo *log.Logger) (*Manager, error) {
 		opt:     opt,
 		queries: q,
 		queue:   make(chan models.Bounce, 1000),
+		events:  make(chan models.DeliveryEvent, 1000),
 		log:     lo,
 	}
 
+	allow, err := ParseAllowlist(opt.Security.Allowlist)
+	if err != nil {
+		return nil, err
+	}
+	m.allowlist = allow
+
 	// Is there a mailbox?
 	if opt.MailboxEnabled {
 		switch opt.MailboxType {
 		case "pop":
 			m.mailbox = mailbox.NewPOP(opt.Mailbox)
+		case "imap":
+			m.mailbox = mailbox.NewIMAP(opt.Mailbox)
 		default:
 			return nil, errors.New("unknown bounce mailbox type")
 		}
 	}
 
 	if opt.WebhooksEnabled {
+		replay := webhooks.NewReplayGuard(time.Duration(opt.Security.MaxAge) * time.Second)
+
+		if opt.Security.HMACSecret != "" {
+			m.Native = webhooks.NewNative(opt.Security.HMACSecret, replay)
+		}
+
 		if opt.SESEnabled {
 			m.SES = webhooks.NewSES()
 		}
@@ -99,6 +180,51 @@ func New(opt Opt, q *Queries, lo *log.Logger) (*Manager, error) {
 			fe := webhooks.NewForwardemail([]byte(opt.ForwardEmail.Key))
 			m.Forwardemail = fe
 		}
+
+		if opt.Mailgun.Enabled {
+			mg, err := webhooks.NewMailgun([]byte(opt.Mailgun.Key), replay)
+			if err != nil {
+				lo.Printf("error initializing mailgun webhooks: %v", err)
+			} else {
+				m.Mailgun = mg
+			}
+		}
+
+		if opt.SparkPost.Enabled {
+			sp, err := webhooks.NewSparkPost(opt.SparkPost.Username, opt.SparkPost.Password)
+			if err != nil {
+				lo.Printf("error initializing sparkpost webhooks: %v", err)
+			} else {
+				m.SparkPost = sp
+			}
+		}
+
+		if opt.Brevo.Enabled {
+			br, err := webhooks.NewBrevo(opt.Brevo.Username, opt.Brevo.Password)
+			if err != nil {
+				lo.Printf("error initializing brevo webhooks: %v", err)
+			} else {
+				m.Brevo = br
+			}
+		}
+
+		if opt.Mailjet.Enabled {
+			mj, err := webhooks.NewMailjet(opt.Mailjet.Username, opt.Mailjet.Password)
+			if err != nil {
+				lo.Printf("error initializing mailjet webhooks: %v", err)
+			} else {
+				m.Mailjet = mj
+			}
+		}
+
+		if opt.Resend.Enabled {
+			rs, err := webhooks.NewResend(opt.Resend.Key, replay)
+			if err != nil {
+				lo.Printf("error initializing resend webhooks: %v", err)
+			} else {
+				m.Resend = rs
+			}
+		}
 	}
 
 	return m, nil
@@ -111,13 +237,25 @@ func (m *Manager) Run() {
 		go m.runMailboxScanner()
 	}
 
-	for b := range m.queue {
-		if b.CreatedAt.IsZero() {
-			b.CreatedAt = time.Now()
-		}
+	for {
+		select {
+		case b := <-m.queue:
+			if b.CreatedAt.IsZero() {
+				b.CreatedAt = time.Now()
+			}
 
-		if err := m.opt.RecordBounceCB(b); err != nil {
-			continue
+			if err := m.opt.RecordBounceCB(b); err != nil {
+				continue
+			}
+
+		case e := <-m.events:
+			if e.CreatedAt.IsZero() {
+				e.CreatedAt = time.Now()
+			}
+
+			if err := m.opt.RecordEventCB(e); err != nil {
+				continue
+			}
 		}
 	}
 }
@@ -129,7 +267,16 @@ func (m *Manager) runMailboxScanner() {
 			m.log.Printf("error scanning bounce mailbox: %v", err)
 		}
 
-		time.Sleep(m.opt.Mailbox.ScanInterval)
+		w, ok := m.mailbox.(MailboxWaiter)
+		if !ok {
+			time.Sleep(m.opt.Mailbox.ScanInterval)
+			continue
+		}
+
+		if err := w.Wait(m.opt.Mailbox.ScanInterval); err != nil {
+			m.log.Printf("error waiting for bounce mailbox: %v", err)
+			time.Sleep(m.opt.Mailbox.ScanInterval)
+		}
 	}
 }
 
@@ -138,3 +285,89 @@ func (m *Manager) Record(b models.Bounce) error {
 	m.queue <- b
 	return nil
 }
+
+// RecordEvent records a delivery event (delivered, open, click) reported by a webhook
+// given the subscriber's email or UUID. Events of types that are not enabled are ignored.
+func (m *Manager) RecordEvent(e models.DeliveryEvent) error {
+	switch e.Type {
+	case models.DeliveryEventDelivered:
+		if !m.opt.Events.Delivered {
+			return nil
+		}
+	case models.DeliveryEventOpen:
+		if !m.opt.Events.Opens {
+			return nil
+		}
+	case models.DeliveryEventClick:
+		if !m.opt.Events.Clicks {
+			return nil
+		}
+	default:
+		return nil
+	}
+
+	m.events <- e
+	return nil
+}
+
+// IsAllowedIP checks whether the given IP is allowed to post to the given
+// service's webhook. Services without an allowlist accept all IPs.
+func (m *Manager) IsAllowedIP(service, ip string) bool {
+	nets, ok := m.allowlist[service]
+	if !ok {
+		return true
+	}
+
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return false
+	}
+
+	for _, n := range nets {
+		if n.Contains(addr) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ParseAllowlist parses a map of services and their comma separated IPs and CIDRs.
+func ParseAllowlist(list map[string]string) (map[string][]*net.IPNet, error) {
+	out := make(map[string][]*net.IPNet, len(list))
+	for service, ips := range list {
+		var nets []*net.IPNet
+		for _, s := range strings.Split(ips, ",") {
+			s = strings.TrimSpace(s)
+			if s == "" {
+				continue
+			}
+
+			// Single IPs are converted to /32 or /128 CIDRs.
+			if !strings.Contains(s, "/") {
+				ip := net.ParseIP(s)
+				if ip == nil {
+					return nil, fmt.Errorf("invalid IP '%s' in %s webhook allowlist", s, service)
+				}
+
+				bits := 128
+				if ip.To4() != nil {
+					bits = 32
+				}
+				s = fmt.Sprintf("%s/%d", s, bits)
+			}
+
+			_, n, err := net.ParseCIDR(s)
+			if err != nil {
+				return nil, fmt.Errorf("invalid CIDR '%s' in %s webhook allowlist: %v", s, service, err)
+			}
+			nets = append(nets, n)
+		}
+
+		if len(nets) > 0 {
+			out[service] = nets
+		}
+	}
+
+	return out, nil
+}
@@ -0,0 +1,275 @@
+package mailbox
+
+import (
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/emersion/go-imap"
+	"github.com/emersion/go-imap/client"
+	"github.com/emersion/go-sasl"
+	"github.com/knadh/listmonk/models"
+)
+
+const (
+	// ModePoll scans the mailbox at every scan interval.
+	ModePoll = "poll"
+	// ModeIdle waits for new messages with IMAP IDLE and scans the mailbox as soon
+	// as they arrive, or at every scan interval.
+	ModeIdle = "idle"
+
+	// ActionFlag marks processed messages as seen. Only unseen messages are scanned.
+	ActionFlag = "flag"
+	// ActionMove moves processed messages to the processed folder.
+	ActionMove = "move"
+)
+
+// IMAP represents an IMAP mailbox.
+type IMAP struct {
+	opt Opt
+}
+
+// NewIMAP returns a new instance of the IMAP mailbox client.
+func NewIMAP(opt Opt) *IMAP {
+	if opt.Folder == "" {
+		opt.Folder = "INBOX"
+	}
+	if opt.Mode == "" {
+		opt.Mode = ModePoll
+	}
+	if opt.ProcessedAction == "" || (opt.ProcessedAction == ActionMove && opt.ProcessedFolder == "") {
+		opt.ProcessedAction = ActionFlag
+	}
+
+	return &IMAP{opt: opt}
+}
+
+// Scan scans the folder and pushes the downloaded bounces into the given channel.
+// The messages that are downloaded are moved to the processed folder or flagged
+// as seen. If limit > 0, only that many messages are downloaded.
+func (m *IMAP) Scan(limit int, ch chan models.Bounce) error {
+	return m.Process(limit, func(b []byte) error {
+		// Parse the message. E-mails that are not bounces, eg: auto-replies, and e-mails
+		// that can't be parsed are skipped, but are still moved or flagged.
+		bn, ok, err := parseBounce(b, m.opt.Host)
+		if err != nil || !ok {
+			return nil
+		}
+
+		select {
+		case ch <- bn:
+		default:
+		}
+
+		return nil
+	})
+}
+
+// Process downloads the messages in the folder and passes their raw bytes to the
+// given function. The messages that are downloaded are moved to the processed folder
+// or flagged as seen, even if fn returns an error. If limit > 0, only that many
+// messages are downloaded.
+func (m *IMAP) Process(limit int, fn func(b []byte) error) error {
+	c, err := m.connect()
+	if err != nil {
+		return err
+	}
+	defer c.Logout()
+
+	// Flagged (seen) messages have already been processed.
+	crit := imap.NewSearchCriteria()
+	if m.opt.ProcessedAction == ActionFlag {
+		crit.WithoutFlags = []string{imap.SeenFlag}
+	}
+
+	uids, err := c.UidSearch(crit)
+	if err != nil {
+		return err
+	}
+
+	// No messages.
+	if len(uids) == 0 {
+		return nil
+	}
+
+	if limit > 0 && len(uids) > limit {
+		uids = uids[:limit]
+	}
+
+	set := new(imap.SeqSet)
+	set.AddNum(uids...)
+
+	// Download the messages without implicitly setting the seen flag.
+	var (
+		sec  = &imap.BodySectionName{Peek: true}
+		msgs = make(chan *imap.Message, 10)
+		done = make(chan error, 1)
+	)
+	go func() {
+		done <- c.UidFetch(set, []imap.FetchItem{imap.FetchUid, sec.FetchItem()}, msgs)
+	}()
+
+	processed := new(imap.SeqSet)
+	for msg := range msgs {
+		r := msg.GetBody(sec)
+		if r == nil {
+			continue
+		}
+
+		b, err := io.ReadAll(r)
+		if err != nil {
+			continue
+		}
+		processed.AddNum(msg.Uid)
+
+		_ = fn(b)
+	}
+	if err := <-done; err != nil {
+		return err
+	}
+
+	if processed.Empty() {
+		return nil
+	}
+
+	// Move or flag the downloaded messages.
+	if m.opt.ProcessedAction == ActionMove {
+		return c.UidMove(processed, m.opt.ProcessedFolder)
+	}
+
+	return c.UidStore(processed, imap.FormatFlagsOp(imap.AddFlags, true), []interface{}{imap.SeenFlag}, nil)
+}
+
+// Wait blocks until new messages arrive in the folder, or the given duration elapses.
+// In the idle mode, IMAP IDLE is used to get notified of new messages. In the poll mode,
+// it simply sleeps.
+func (m *IMAP) Wait(d time.Duration) error {
+	if m.opt.Mode != ModeIdle {
+		time.Sleep(d)
+		return nil
+	}
+
+	c, err := m.connect()
+	if err != nil {
+		return err
+	}
+	defer c.Logout()
+
+	// The client blocks on unconsumed updates, so the channel is buffered
+	// for the updates that may arrive after IDLE is stopped.
+	updates := make(chan client.Update, 100)
+	c.Updates = updates
+
+	var (
+		stop  = make(chan struct{})
+		done  = make(chan error, 1)
+		timer = time.NewTimer(d)
+	)
+	defer timer.Stop()
+
+	go func() {
+		done <- c.Idle(stop, nil)
+	}()
+
+	for {
+		select {
+		case u := <-updates:
+			// A mailbox update is sent when new messages arrive (EXISTS).
+			if _, ok := u.(*client.MailboxUpdate); !ok {
+				continue
+			}
+			close(stop)
+			return <-done
+
+		case <-timer.C:
+			close(stop)
+			return <-done
+
+		case err := <-done:
+			return err
+		}
+	}
+}
+
+// connect connects and logs in to the server, and selects the folder.
+func (m *IMAP) connect() (*client.Client, error) {
+	var (
+		addr = fmt.Sprintf("%s:%d", m.opt.Host, m.opt.Port)
+		c    *client.Client
+		err  error
+	)
+	tlsCfg := &tls.Config{
+		ServerName:         m.opt.Host,
+		InsecureSkipVerify: m.opt.TLSSkipVerify,
+	}
+	if m.opt.TLSEnabled {
+		c, err = client.DialTLS(addr, tlsCfg)
+	} else {
+		c, err = client.Dial(addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+	c.Timeout = time.Minute
+
+	// Upgrade the plain connection with STARTTLS before authenticating.
+	if !m.opt.TLSEnabled && m.opt.StartTLS {
+		if ok, _ := c.SupportStartTLS(); !ok {
+			c.Logout()
+			return nil, errors.New("IMAP STARTTLS extension not found")
+		}
+		if err := c.StartTLS(tlsCfg); err != nil {
+			c.Logout()
+			return nil, err
+		}
+	}
+
+	// Authenticate.
+	switch m.opt.AuthProtocol {
+	case "none":
+	case "plain":
+		err = c.Authenticate(sasl.NewPlainClient("", m.opt.Username, m.opt.Password))
+	case "cram":
+		err = c.Authenticate(&cramMD5{username: m.opt.Username, secret: m.opt.Password})
+	default:
+		err = c.Login(m.opt.Username, m.opt.Password)
+	}
+	if err != nil {
+		c.Logout()
+		return nil, err
+	}
+
+	if m.opt.ProcessedAction == ActionMove {
+		// Create the processed folder if it doesn't exist. The error is ignored
+		// as most servers return one if it already exists.
+		_ = c.Create(m.opt.ProcessedFolder)
+	}
+
+	if _, err := c.Select(m.opt.Folder, false); err != nil {
+		c.Logout()
+		return nil, fmt.Errorf("error selecting folder '%s': %v", m.opt.Folder, err)
+	}
+
+	return c, nil
+}
+
+// cramMD5 implements the CRAM-MD5 (RFC 2195) SASL mechanism.
+type cramMD5 struct {
+	username, secret string
+}
+
+func (a *cramMD5) Start() (string, []byte, error) {
+	return "CRAM-MD5", nil, nil
+}
+
+func (a *cramMD5) Next(challenge []byte) ([]byte, error) {
+	h := hmac.New(md5.New, []byte(a.secret))
+	h.Write(challenge)
+
+	return []byte(strings.TrimSpace(a.username) + " " + fmt.Sprintf("%x", h.Sum(nil))), nil
+}
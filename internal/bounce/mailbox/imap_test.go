@@ -0,0 +1,241 @@
+package mailbox
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/emersion/go-imap"
+	"github.com/emersion/go-imap/backend"
+	"github.com/emersion/go-imap/backend/memory"
+	"github.com/emersion/go-imap/server"
+	"github.com/knadh/listmonk/models"
+)
+
+// moveBackend adds MOVE to the memory backend's mailboxes, which the server
+// advertises but the memory backend doesn't implement.
+type moveBackend struct{ backend.Backend }
+type moveUser struct{ backend.User }
+type moveMailbox struct{ backend.Mailbox }
+
+func (b moveBackend) Login(ci *imap.ConnInfo, user, pass string) (backend.User, error) {
+	u, err := b.Backend.Login(ci, user, pass)
+	if err != nil {
+		return nil, err
+	}
+	return moveUser{u}, nil
+}
+
+func (u moveUser) GetMailbox(name string) (backend.Mailbox, error) {
+	m, err := u.User.GetMailbox(name)
+	if err != nil {
+		return nil, err
+	}
+	return moveMailbox{m}, nil
+}
+
+func (m moveMailbox) MoveMessages(uid bool, set *imap.SeqSet, dest string) error {
+	if err := m.CopyMessages(uid, set, dest); err != nil {
+		return err
+	}
+	if err := m.UpdateMessagesFlags(uid, set, imap.AddFlags, []string{imap.DeletedFlag}); err != nil {
+		return err
+	}
+	return m.Expunge()
+}
+
+// newIMAPServer starts an IMAP server on a loopback port with the memory
+// backend's user (username, password) and the given messages in its INBOX,
+// along with the one seen message that the backend has. If tlsCfg is set,
+// STARTTLS is required to log in.
+func newIMAPServer(t *testing.T, tlsCfg *tls.Config, files ...string) (string, int) {
+	t.Helper()
+
+	be := memory.New()
+	u, err := be.Login(nil, "username", "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	box, err := u.GetMailbox("INBOX")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range files {
+		b, err := os.ReadFile(filepath.Join("testdata", f))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := box.CreateMessage(nil, time.Now(), bytes.NewBuffer(b)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s := server.New(moveBackend{be})
+	s.AllowInsecureAuth = tlsCfg == nil
+	s.TLSConfig = tlsCfg
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.Serve(l)
+	t.Cleanup(func() { s.Close() })
+
+	addr := l.Addr().(*net.TCPAddr)
+	return addr.IP.String(), addr.Port
+}
+
+// scan scans the mailbox and returns the bounces.
+func scan(t *testing.T, m *IMAP, limit int) []models.Bounce {
+	t.Helper()
+
+	ch := make(chan models.Bounce, 10)
+	if err := m.Scan(limit, ch); err != nil {
+		t.Fatal(err)
+	}
+	close(ch)
+
+	var out []models.Bounce
+	for b := range ch {
+		out = append(out, b)
+	}
+	return out
+}
+
+// count returns the number of unseen messages in a folder.
+func count(t *testing.T, opt Opt, folder string) int {
+	t.Helper()
+
+	opt.Folder = folder
+	opt.ProcessedAction = ""
+	n := 0
+	if err := NewIMAP(opt).Process(0, func([]byte) error { n++; return nil }); err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestIMAPScan(t *testing.T) {
+	files := []string{"dsn-hard.eml", "arf.eml", "autoreply.eml", "dsn-delayed.eml"}
+
+	t.Run("flag", func(t *testing.T) {
+		host, port := newIMAPServer(t, nil, files...)
+		m := NewIMAP(Opt{Host: host, Port: port, Username: "username", Password: "password"})
+
+		// Only the DSN failure and the ARF complaint are bounces, and the
+		// message that's already seen isn't scanned.
+		bs := scan(t, m, 0)
+		if len(bs) != 2 || bs[0].Type != models.BounceTypeHard || bs[1].Type != models.BounceTypeComplaint {
+			t.Fatalf("unexpected bounces: %+v", bs)
+		}
+		if bs[0].Email != "nobody@example.net" || bs[0].CampaignUUID != testCampUUID || bs[0].Source != host {
+			t.Errorf("unexpected bounce: %+v", bs[0])
+		}
+
+		// All the downloaded messages, including the ones that aren't bounces,
+		// are flagged as seen and aren't scanned again.
+		if bs := scan(t, m, 0); len(bs) != 0 {
+			t.Errorf("expected no bounces on rescan, got %d", len(bs))
+		}
+	})
+
+	t.Run("limit", func(t *testing.T) {
+		host, port := newIMAPServer(t, nil, files...)
+		opt := Opt{Host: host, Port: port, Username: "username", Password: "password"}
+		m := NewIMAP(opt)
+
+		if bs := scan(t, m, 1); len(bs) != 1 || bs[0].Type != models.BounceTypeHard {
+			t.Fatalf("unexpected bounces: %+v", bs)
+		}
+		if n := count(t, opt, "INBOX"); n != 3 {
+			t.Errorf("expected 3 unseen messages, got %d", n)
+		}
+	})
+
+	t.Run("move", func(t *testing.T) {
+		host, port := newIMAPServer(t, nil, files...)
+		opt := Opt{
+			Host: host, Port: port, Username: "username", Password: "password",
+			ProcessedAction: ActionMove, ProcessedFolder: "Processed",
+		}
+
+		// All the messages in the folder, seen or not, are scanned and moved
+		// to the processed folder, which is created. The seen message has no
+		// report and isn't an auto-reply, so it's a bounce too.
+		if bs := scan(t, NewIMAP(opt), 0); len(bs) != 3 {
+			t.Fatalf("expected 3 bounces, got %d", len(bs))
+		}
+		if n := count(t, opt, "INBOX"); n != 0 {
+			t.Errorf("expected an empty INBOX, got %d messages", n)
+		}
+		// The seen message is still seen in the processed folder.
+		if n := count(t, opt, "Processed"); n != len(files) {
+			t.Errorf("expected %d unseen processed messages, got %d", len(files), n)
+		}
+	})
+
+	t.Run("bad login", func(t *testing.T) {
+		host, port := newIMAPServer(t, nil)
+		m := NewIMAP(Opt{Host: host, Port: port, Username: "username", Password: "wrong"})
+		if err := m.Scan(0, make(chan models.Bounce, 1)); err == nil {
+			t.Error("expected login error")
+		}
+	})
+}
+
+func TestIMAPStartTLS(t *testing.T) {
+	host, port := newIMAPServer(t, &tls.Config{Certificates: []tls.Certificate{testCert(t)}}, "dsn-hard.eml")
+	opt := Opt{Host: host, Port: port, Username: "username", Password: "password", TLSSkipVerify: true}
+
+	// The server doesn't allow logging in on a plain connection.
+	if err := NewIMAP(opt).Scan(0, make(chan models.Bounce, 1)); err == nil {
+		t.Fatal("expected login error without STARTTLS")
+	}
+
+	opt.StartTLS = true
+	if bs := scan(t, NewIMAP(opt), 0); len(bs) != 1 {
+		t.Fatalf("expected 1 bounce, got %d", len(bs))
+	}
+
+	// The certificate is verified unless skipped.
+	opt.TLSSkipVerify = false
+	if err := NewIMAP(opt).Scan(0, make(chan models.Bounce, 1)); err == nil {
+		t.Error("expected certificate error")
+	}
+}
+
+// testCert returns a self-signed certificate for 127.0.0.1.
+func testCert(t *testing.T) tls.Certificate {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "127.0.0.1"},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tpl, tpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
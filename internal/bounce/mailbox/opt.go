@@ -21,8 +21,18 @@ type Opt struct {
 	// Folder is the name of the IMAP folder to scan for e-mails.
 	Folder string `json:"folder"`
 
-	// Optional TLS settings.
+	// Mode is the IMAP scan mode, poll or idle.
+	Mode string `json:"mode"`
+
+	// ProcessedAction is what's done to processed IMAP messages, flag (as seen) or
+	// move (to ProcessedFolder).
+	ProcessedAction string `json:"processed_action"`
+	ProcessedFolder string `json:"processed_folder"`
+
+	// Optional TLS settings. StartTLS upgrades a plain IMAP connection with
+	// STARTTLS when TLSEnabled is off.
 	TLSEnabled    bool `json:"tls_enabled"`
+	StartTLS      bool `json:"start_tls"`
 	TLSSkipVerify bool `json:"tls_skip_verify"`
 
 	ScanInterval time.Duration `json:"scan_interval"`
@@ -0,0 +1,399 @@
+package mailbox
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime"
+	"net/textproto"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/emersion/go-message"
+	"github.com/knadh/listmonk/models"
+)
+
+// maxPartSize is the maximum number of bytes read from a bounce e-mail's part.
+const maxPartSize = 64 * 1024
+
+// report represents the delivery status (RFC 3464) or feedback (RFC 5965, ARF)
+// report in a bounce e-mail.
+type report struct {
+	Type           string
+	Action         string
+	Status         string
+	DiagnosticCode string
+	FeedbackType   string
+	Recipient      string
+}
+
+type bounceHeaders struct {
+	Header string
+	Regexp *regexp.Regexp
+}
+
+var (
+	// List of header to look for in the e-mail body, regexp to fall back to if the header is empty.
+	headerLookups = []bounceHeaders{
+		{models.EmailHeaderCampaignUUID, regexp.MustCompile(`(?m)(?:^` + models.EmailHeaderCampaignUUID + `:\s+?)([a-z0-9\-]{36})`)},
+		{models.EmailHeaderSubscriberUUID, regexp.MustCompile(`(?m)(?:^` + models.EmailHeaderSubscriberUUID + `:\s+?)([a-z0-9\-]{36})`)},
+		{models.EmailHeaderDate, regexp.MustCompile(`(?m)(?:^` + models.EmailHeaderDate + `:\s+?)([\w,\,\ ,:,+,-]*(?:\(?:\w*\))?)`)},
+		{models.EmailHeaderFrom, regexp.MustCompile(`(?m)(?:^` + models.EmailHeaderFrom + `:\s+?)(.*)`)},
+		{models.EmailHeaderSubject, regexp.MustCompile(`(?m)(?:^` + models.EmailHeaderSubject + `:\s+?)(.*)`)},
+		{models.EmailHeaderMessageId, regexp.MustCompile(`(?m)(?:^` + models.EmailHeaderMessageId + `:\s+?)(.*)`)},
+		{models.EmailHeaderDeliveredTo, regexp.MustCompile(`(?m)(?:^` + models.EmailHeaderDeliveredTo + `:\s+?)(.*)`)},
+	}
+
+	reHdrReceived = regexp.MustCompile(`(?m)(?:^` + models.EmailHeaderReceived + `:\s+?)(.*)`)
+
+	// Enhanced status code (RFC 3463), eg: 5.1.1.
+	reEnhancedCode = regexp.MustCompile(`(?:^|[^\d.])([245]\.\d{1,3}\.\d{1,3})(?:[^\d.]|$)`)
+
+	// Basic SMTP reply code, eg: 550 or 550-.
+	reBasicCode = regexp.MustCompile(`(?:^|[^\d.])([245]\d\d)[ \-]`)
+
+	// Enhanced and basic failure codes in the free text of non-DSN bounces.
+	reTextEnhancedCode = regexp.MustCompile(`(?:^|[^\d.])([45]\.\d{1,3}\.\d{1,3})(?:[^\d.]|$)`)
+	reTextBasicCode    = regexp.MustCompile(`(?:^|[^\d.])([45]\d\d)[ \-]`)
+
+	// Diagnostic text that indicates a temporary failure even with a 5.x.x code.
+	reSoftText = regexp.MustCompile(`(?i)mailbox (is )?full|over ?quota|quota exceeded|exceeded (the )?(storage|quota)|insufficient (system )?storage|out of storage|try again later`)
+
+	reAutoReplySubject = regexp.MustCompile(`(?i)^\s*(auto(matic)?[ \-]?(reply|response|antwort)|auto:|out of (the )?office|vacation|away from (the )?office|abwesenheit)`)
+	reMailerDaemon     = regexp.MustCompile(`(?i)mailer-daemon|postmaster`)
+)
+
+// Enhanced 5.x.x status codes that are temporary in practice.
+// 5.2.2: mailbox full, 5.2.3: message too long for the mailbox, 5.3.4: message too big for the system.
+var softCodes = map[string]bool{
+	"5.2.2": true,
+	"5.2.3": true,
+	"5.3.4": true,
+}
+
+// parseBounce parses a raw bounce e-mail into a bounce. The bounce type is derived
+// from the DSN or ARF report in the e-mail, or from the status codes in its text if
+// there's no report. ok is false if the e-mail is not a failure that should be recorded,
+// eg: auto-replies, delay warnings and successful delivery notifications.
+func parseBounce(raw []byte, source string) (models.Bounce, bool, error) {
+	m, err := message.Read(bytes.NewReader(raw))
+	if err != nil && !message.IsUnknownCharset(err) && !message.IsUnknownEncoding(err) {
+		return models.Bounce{}, false, err
+	}
+
+	var (
+		// h is the last part of the e-mail (or the e-mail itself if it's not multipart)
+		// and orig is the original (bounced) e-mail's header, if it's attached.
+		h    = m.Header
+		orig *message.Header
+
+		rep    *report
+		hasRep bool
+		text   strings.Builder
+	)
+
+	ct, _, _ := m.Header.ContentType()
+	isMulti := strings.HasPrefix(strings.ToLower(ct), "multipart/")
+
+	if err := m.Walk(func(path []int, e *message.Entity, err error) error {
+		if e == nil {
+			return nil
+		}
+		if len(path) == 1 {
+			h = e.Header
+		}
+
+		typ, _, _ := e.Header.ContentType()
+		switch strings.ToLower(typ) {
+		case "message/delivery-status", "message/global-delivery-status":
+			if r, ok := parseDSN(e.Body); ok {
+				rep, hasRep = r, true
+			}
+
+		case "message/feedback-report":
+			if r, ok := parseARF(e.Body); ok {
+				rep, hasRep = r, true
+			}
+
+		case "message/rfc822", "message/global", "text/rfc822-headers", "message/rfc822-headers":
+			if om, err := message.Read(io.LimitReader(e.Body, maxPartSize)); om != nil && (err == nil || message.IsUnknownCharset(err)) {
+				orig = &om.Header
+			}
+
+		case "text/plain", "":
+			// Collect the human readable text for the fallback lookup of status codes.
+			// The e-mail itself is only walked here if it's not multipart.
+			if isMulti && len(path) == 0 {
+				return nil
+			}
+			if text.Len() < maxPartSize {
+				b, _ := io.ReadAll(io.LimitReader(e.Body, maxPartSize))
+				text.Write(b)
+				text.WriteString("\n")
+			}
+		}
+
+		return nil
+	}); err != nil && !message.IsUnknownCharset(err) && !message.IsUnknownEncoding(err) {
+		return models.Bounce{}, false, err
+	}
+
+	// No DSN or ARF report. Ignore auto-replies and look for status codes in the text.
+	if !hasRep {
+		if IsAutoReply(m.Header) {
+			return models.Bounce{}, false, nil
+		}
+
+		t := text.String()
+		code := ""
+		if c := reTextEnhancedCode.FindStringSubmatch(t); c != nil {
+			code = c[1]
+		} else if c := reTextBasicCode.FindStringSubmatch(t); c != nil {
+			code = c[1]
+		}
+
+		rep = &report{Status: code, Type: classify("failed", code, t)}
+	}
+
+	if rep.Type == "" {
+		return models.Bounce{}, false, nil
+	}
+
+	// Lookup headers in the original e-mail and the e-mail. If a header isn't found,
+	// fall back to regexp lookups.
+	hdr := make(map[string]string, len(headerLookups))
+	for _, l := range headerLookups {
+		v := ""
+		if orig != nil {
+			v = orig.Get(l.Header)
+		}
+		if v == "" {
+			v = h.Get(l.Header)
+		}
+
+		// Not in the header. Try regexp.
+		if v == "" {
+			if m := l.Regexp.FindAllSubmatch(raw, -1); m != nil {
+				v = string(m[len(m)-1][1])
+			}
+		}
+
+		hdr[l.Header] = strings.TrimSpace(v)
+	}
+
+	// Received is a []string header.
+	msgReceived := h.Map()[models.EmailHeaderReceived]
+	if len(msgReceived) == 0 {
+		if u := reHdrReceived.FindAllSubmatch(raw, -1); u != nil {
+			for i := 0; i < len(u); i++ {
+				msgReceived = append(msgReceived, string(u[i][1]))
+			}
+		}
+	}
+
+	date, _ := time.Parse("Mon, 02 Jan 2006 15:04:05 -0700", hdr[models.EmailHeaderDate])
+	if date.IsZero() {
+		date = time.Now()
+	}
+
+	// Additional bounce e-mail metadata.
+	meta, _ := json.Marshal(struct {
+		From           string   `json:"from"`
+		Subject        string   `json:"subject"`
+		MessageID      string   `json:"message_id"`
+		DeliveredTo    string   `json:"delivered_to"`
+		Received       []string `json:"received"`
+		Action         string   `json:"action,omitempty"`
+		Status         string   `json:"status,omitempty"`
+		DiagnosticCode string   `json:"diagnostic_code,omitempty"`
+		FeedbackType   string   `json:"feedback_type,omitempty"`
+		Recipient      string   `json:"recipient,omitempty"`
+	}{
+		From:           hdr[models.EmailHeaderFrom],
+		Subject:        hdr[models.EmailHeaderSubject],
+		MessageID:      hdr[models.EmailHeaderMessageId],
+		DeliveredTo:    hdr[models.EmailHeaderDeliveredTo],
+		Received:       msgReceived,
+		Action:         rep.Action,
+		Status:         rep.Status,
+		DiagnosticCode: rep.DiagnosticCode,
+		FeedbackType:   rep.FeedbackType,
+		Recipient:      rep.Recipient,
+	})
+
+	return models.Bounce{
+		Type:           rep.Type,
+		Email:          rep.Recipient,
+		CampaignUUID:   hdr[models.EmailHeaderCampaignUUID],
+		SubscriberUUID: hdr[models.EmailHeaderSubscriberUUID],
+		Source:         source,
+		CreatedAt:      date,
+		Meta:           meta,
+	}, true, nil
+}
+
+// parseDSN parses the per-recipient fields of a message/delivery-status report.
+// The first failed recipient is picked, or the first recipient if none failed.
+func parseDSN(r io.Reader) (*report, bool) {
+	var out *report
+	for _, f := range readFieldGroups(r) {
+		action := strings.ToLower(strings.TrimSpace(f.Get("Action")))
+		status := f.Get("Status")
+		if action == "" && status == "" {
+			// Per-message fields.
+			continue
+		}
+
+		rep := &report{
+			Action:         action,
+			DiagnosticCode: fieldValue(f.Get("Diagnostic-Code")),
+			Recipient:      cleanAddr(fieldValue(f.Get("Final-Recipient"))),
+		}
+		if rep.Recipient == "" {
+			rep.Recipient = cleanAddr(fieldValue(f.Get("Original-Recipient")))
+		}
+
+		// The status code is in the Status field, or in the diagnostic text.
+		if c := reEnhancedCode.FindStringSubmatch(status); c != nil {
+			rep.Status = c[1]
+		} else if c := reEnhancedCode.FindStringSubmatch(rep.DiagnosticCode); c != nil {
+			rep.Status = c[1]
+		} else if c := reBasicCode.FindStringSubmatch(rep.DiagnosticCode + " "); c != nil {
+			rep.Status = c[1]
+		}
+		rep.Type = classify(action, rep.Status, rep.DiagnosticCode)
+
+		if out == nil || (out.Action != "failed" && action == "failed") {
+			out = rep
+		}
+	}
+
+	return out, out != nil
+}
+
+// parseARF parses a message/feedback-report (abuse complaint) report.
+func parseARF(r io.Reader) (*report, bool) {
+	groups := readFieldGroups(r)
+	if len(groups) == 0 {
+		return nil, false
+	}
+
+	f := groups[0]
+	rep := &report{
+		Type:         models.BounceTypeComplaint,
+		FeedbackType: strings.ToLower(strings.TrimSpace(f.Get("Feedback-Type"))),
+		Recipient:    cleanAddr(f.Get("Original-Rcpt-To")),
+	}
+	if rep.Recipient == "" {
+		rep.Recipient = cleanAddr(f.Get("Removal-Recipient"))
+	}
+
+	// A not-spam report is the opposite of a complaint.
+	if rep.FeedbackType == "not-spam" {
+		rep.Type = ""
+	}
+
+	return rep, true
+}
+
+// classify returns the bounce type for a DSN action and status code (enhanced or basic).
+// An empty type is returned for actions and codes that are not failures.
+func classify(action, code, diag string) string {
+	switch action {
+	case "delivered", "relayed", "expanded", "delayed":
+		return ""
+	}
+
+	switch {
+	case code == "":
+		// Failed without a code.
+	case code[0] == '2':
+		return ""
+	case code[0] == '4':
+		return models.BounceTypeSoft
+	case softCodes[code]:
+		return models.BounceTypeSoft
+	}
+
+	if reSoftText.MatchString(diag) {
+		return models.BounceTypeSoft
+	}
+
+	return models.BounceTypeHard
+}
+
+// IsAutoReply checks whether an e-mail is an auto-reply (RFC 3834), eg: a vacation
+// or out of office response, and not a bounce from a mail server.
+func IsAutoReply(h message.Header) bool {
+	if reMailerDaemon.MatchString(h.Get("From")) {
+		return false
+	}
+
+	if v := strings.ToLower(strings.TrimSpace(h.Get("Auto-Submitted"))); v != "" && v != "no" {
+		return true
+	}
+	if h.Get("X-Autoreply") != "" || h.Get("X-Autorespond") != "" {
+		return true
+	}
+	if strings.EqualFold(strings.TrimSpace(h.Get("Precedence")), "auto_reply") {
+		return true
+	}
+
+	subj, err := h.Text("Subject")
+	if err != nil {
+		subj = h.Get("Subject")
+	}
+
+	return reAutoReplySubject.MatchString(subj)
+}
+
+// readFieldGroups reads the blank line separated groups of header-style fields
+// in a DSN or ARF report.
+func readFieldGroups(r io.Reader) []textproto.MIMEHeader {
+	b, _ := io.ReadAll(io.LimitReader(r, maxPartSize))
+	s := strings.ReplaceAll(string(b), "\r\n", "\n")
+
+	var out []textproto.MIMEHeader
+	for _, g := range strings.Split(s, "\n\n") {
+		g = strings.TrimSpace(g)
+		if g == "" {
+			continue
+		}
+
+		f, err := textproto.NewReader(bufio.NewReader(strings.NewReader(g + "\n\n"))).ReadMIMEHeader()
+		if err != nil && len(f) == 0 {
+			continue
+		}
+		out = append(out, f)
+	}
+
+	return out
+}
+
+// fieldValue returns the value of a typed DSN field without the type, eg:
+// user@example.com in "rfc822; user@example.com".
+func fieldValue(v string) string {
+	if _, after, ok := strings.Cut(v, ";"); ok {
+		v = after
+	}
+
+	// Decode RFC 2047 encoded words, if any.
+	if d, err := new(mime.WordDecoder).DecodeHeader(v); err == nil {
+		v = d
+	}
+
+	return strings.TrimSpace(v)
+}
+
+// cleanAddr lowercases an e-mail address in a report field and strips the
+// angle brackets around it, eg: <user@example.com>.
+func cleanAddr(v string) string {
+	v = strings.TrimSpace(v)
+	if strings.HasPrefix(v, "<") && strings.HasSuffix(v, ">") {
+		v = strings.TrimSpace(v[1 : len(v)-1])
+	}
+
+	return strings.ToLower(v)
+}
@@ -0,0 +1,218 @@
+package mailbox
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/emersion/go-message"
+	"github.com/knadh/listmonk/models"
+)
+
+const (
+	testCampUUID = "0c4d2e6f-1a2b-4c3d-8e9f-0a1b2c3d4e5f"
+	testSubUUID  = "9f8e7d6c-5b4a-4392-8172-6a5b4c3d2e1f"
+)
+
+func TestParseBounce(t *testing.T) {
+	cases := []struct {
+		file     string
+		ok       bool
+		typ      string
+		email    string
+		status   string
+		feedback string
+		campUUID string
+		subUUID  string
+	}{
+		// Postfix DSN (RFC 3464) with the original headers attached.
+		{file: "dsn-hard.eml", ok: true, typ: models.BounceTypeHard, email: "nobody@example.net",
+			status: "5.1.1", campUUID: testCampUUID, subUUID: testSubUUID},
+
+		// The failed recipient is picked over a delayed one, and 5.2.2 is soft.
+		{file: "dsn-multi.eml", ok: true, typ: models.BounceTypeSoft, email: "full@example.org",
+			status: "5.2.2", campUUID: testCampUUID},
+
+		// Delay warnings aren't failures, even with a code in the text.
+		{file: "dsn-delayed.eml"},
+
+		// ARF (RFC 5965) abuse report.
+		{file: "arf.eml", ok: true, typ: models.BounceTypeComplaint, email: "user@example.com",
+			feedback: "abuse", campUUID: testCampUUID, subUUID: testSubUUID},
+		{file: "arf-not-spam.eml"},
+
+		// Non-standard bounces without a report.
+		{file: "qmail.eml", ok: true, typ: models.BounceTypeHard, status: "550",
+			campUUID: testCampUUID, subUUID: testSubUUID},
+		{file: "exchange-quota.eml", ok: true, typ: models.BounceTypeSoft, status: "5.2.0"},
+
+		// Auto-replies aren't bounces.
+		{file: "autoreply.eml"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.file, func(t *testing.T) {
+			raw, err := os.ReadFile(filepath.Join("testdata", c.file))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			b, ok, err := parseBounce(raw, "pop")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ok != c.ok {
+				t.Fatalf("expected ok=%v, got %v: %+v", c.ok, ok, b)
+			}
+			if !ok {
+				return
+			}
+
+			var meta struct {
+				Status       string `json:"status"`
+				FeedbackType string `json:"feedback_type"`
+			}
+			if err := json.Unmarshal(b.Meta, &meta); err != nil {
+				t.Fatal(err)
+			}
+
+			if b.Type != c.typ || b.Email != c.email || b.Source != "pop" {
+				t.Errorf("expected %s %q, got %s %q", c.typ, c.email, b.Type, b.Email)
+			}
+			if meta.Status != c.status || meta.FeedbackType != c.feedback {
+				t.Errorf("expected status %q feedback %q, got %q %q", c.status, c.feedback, meta.Status, meta.FeedbackType)
+			}
+			if b.CampaignUUID != c.campUUID || b.SubscriberUUID != c.subUUID {
+				t.Errorf("expected UUIDs %q %q, got %q %q", c.campUUID, c.subUUID, b.CampaignUUID, b.SubscriberUUID)
+			}
+		})
+	}
+}
+
+func TestParseDSN(t *testing.T) {
+	cases := []struct {
+		name   string
+		in     string
+		ok     bool
+		action string
+		status string
+		rcpt   string
+		typ    string
+	}{
+		{
+			name: "status field",
+			in: "Reporting-MTA: dns; mx.example.com\n\n" +
+				"Final-Recipient: rfc822; A@example.com\nAction: failed\nStatus: 5.1.1 (user unknown)\n",
+			ok: true, action: "failed", status: "5.1.1", rcpt: "a@example.com", typ: models.BounceTypeHard,
+		},
+		{
+			name: "code in diagnostic",
+			in: "Original-Recipient: rfc822;b@example.com\nAction: failed\nStatus: \n" +
+				"Diagnostic-Code: smtp; 452 4.2.2 Mailbox full\n",
+			ok: true, action: "failed", status: "4.2.2", rcpt: "b@example.com", typ: models.BounceTypeSoft,
+		},
+		{
+			name: "basic code in diagnostic",
+			in:   "Final-Recipient: rfc822; c@example.com\r\nAction: Failed\r\nDiagnostic-Code: smtp; 550 Rejected\r\n",
+			ok:   true, action: "failed", status: "550", rcpt: "c@example.com", typ: models.BounceTypeHard,
+		},
+		{
+			name: "bracketed recipient",
+			in:   "Final-Recipient: rfc822; <F@example.com>\nAction: failed\nStatus: 5.1.1\n",
+			ok:   true, action: "failed", status: "5.1.1", rcpt: "f@example.com", typ: models.BounceTypeHard,
+		},
+		{
+			name: "encoded recipient",
+			in:   "Final-Recipient: utf-8; =?utf-8?q?d=C3=A9@example.com?=\nAction: failed\nStatus: 5.1.1\n",
+			ok:   true, action: "failed", status: "5.1.1", rcpt: "dé@example.com", typ: models.BounceTypeHard,
+		},
+		{
+			name: "delivered",
+			in:   "Final-Recipient: rfc822; e@example.com\nAction: delivered\nStatus: 2.0.0\n",
+			ok:   true, action: "delivered", status: "2.0.0", rcpt: "e@example.com",
+		},
+		{name: "per-message fields only", in: "Reporting-MTA: dns; mx.example.com\nArrival-Date: now\n"},
+		{name: "empty", in: ""},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			r, ok := parseDSN(strings.NewReader(c.in))
+			if ok != c.ok {
+				t.Fatalf("expected ok=%v, got %v", c.ok, ok)
+			}
+			if !ok {
+				return
+			}
+			if r.Action != c.action || r.Status != c.status || r.Recipient != c.rcpt || r.Type != c.typ {
+				t.Errorf("expected %s %s %s %q, got %s %s %s %q",
+					c.action, c.status, c.rcpt, c.typ, r.Action, r.Status, r.Recipient, r.Type)
+			}
+		})
+	}
+}
+
+func TestClassify(t *testing.T) {
+	cases := []struct {
+		action string
+		code   string
+		diag   string
+		want   string
+	}{
+		{"failed", "5.1.1", "user unknown", models.BounceTypeHard},
+		{"failed", "550", "", models.BounceTypeHard},
+		{"failed", "", "", models.BounceTypeHard},
+		{"failed", "4.4.1", "", models.BounceTypeSoft},
+		{"failed", "421", "", models.BounceTypeSoft},
+		{"failed", "5.2.2", "", models.BounceTypeSoft},
+		{"failed", "5.3.4", "", models.BounceTypeSoft},
+		{"failed", "5.0.0", "Mailbox is full", models.BounceTypeSoft},
+		{"failed", "550", "552 Quota exceeded", models.BounceTypeSoft},
+		{"failed", "2.0.0", "", ""},
+		{"delayed", "4.4.7", "", ""},
+		{"delivered", "5.1.1", "", ""},
+		{"relayed", "", "", ""},
+		{"expanded", "", "", ""},
+	}
+
+	for _, c := range cases {
+		if got := classify(c.action, c.code, c.diag); got != c.want {
+			t.Errorf("%s %s %q: expected %q, got %q", c.action, c.code, c.diag, c.want, got)
+		}
+	}
+}
+
+func TestIsAutoReply(t *testing.T) {
+	cases := []struct {
+		hdr  map[string]string
+		want bool
+	}{
+		{map[string]string{"Auto-Submitted": "auto-replied"}, true},
+		{map[string]string{"Auto-Submitted": "no", "Subject": "Hello"}, false},
+		{map[string]string{"X-Autoreply": "yes"}, true},
+		{map[string]string{"X-Autorespond": "yes"}, true},
+		{map[string]string{"Precedence": "auto_reply"}, true},
+		{map[string]string{"Precedence": "bulk"}, false},
+		{map[string]string{"Subject": "Out of Office: Newsletter"}, true},
+		{map[string]string{"Subject": "Automatic reply: Newsletter"}, true},
+		{map[string]string{"Subject": "Auto-Response"}, true},
+		{map[string]string{"Subject": "=?utf-8?q?Abwesenheitsnotiz?="}, true},
+		{map[string]string{"Subject": "Re: Vacation plans"}, false},
+
+		// Bounces from mail servers are marked as auto-replied too.
+		{map[string]string{"From": "MAILER-DAEMON@example.com", "Auto-Submitted": "auto-replied"}, false},
+		{map[string]string{"From": "postmaster@example.com", "Subject": "Auto: failure"}, false},
+	}
+
+	for _, c := range cases {
+		var h message.Header
+		for k, v := range c.hdr {
+			h.Set(k, v)
+		}
+		if got := IsAutoReply(h); got != c.want {
+			t.Errorf("%v: expected %v, got %v", c.hdr, c.want, got)
+		}
+	}
+}
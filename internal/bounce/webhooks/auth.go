@@ -0,0 +1,65 @@
+package webhooks
+
+import (
+	"crypto/subtle"
+	"errors"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+)
+
+// ErrInvalidSignature is returned when the signature of a webhook request doesn't match.
+var ErrInvalidSignature = errors.New("invalid signature")
+
+// AuthError is returned by the providers when a webhook request can't be
+// authenticated, eg: an invalid signature or credentials, or a replayed request.
+type AuthError struct {
+	Err error
+}
+
+func (e *AuthError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *AuthError) Unwrap() error {
+	return e.Err
+}
+
+// errNoCredentials is returned when a provider that's authenticated with HTTP
+// basic auth is set up without a username and password.
+var errNoCredentials = errors.New("webhook username and password are required")
+
+// newBasicAuth returns a handler that checks the HTTP basic auth credentials in
+// a webhook request and returns an AuthError if they don't match. If no credentials
+// are configured, all requests are denied.
+func newBasicAuth(username, password string) echo.HandlerFunc {
+	h := middleware.BasicAuth(makeBasicAuthHandler(username, password))(func(c echo.Context) error {
+		return nil
+	})
+
+	return func(c echo.Context) error {
+		if err := h(c); err != nil {
+			return &AuthError{errors.New("invalid basic auth credentials")}
+		}
+		return nil
+	}
+}
+
+func makeBasicAuthHandler(cfgUser, cfgPassword string) func(username, password string, c echo.Context) (bool, error) {
+	var (
+		u = []byte(cfgUser)
+		p = []byte(cfgPassword)
+	)
+
+	return func(username, password string, c echo.Context) (bool, error) {
+		if len(u) == 0 || len(p) == 0 {
+			return false, nil
+		}
+
+		if subtle.ConstantTimeCompare([]byte(username), u) == 1 && subtle.ConstantTimeCompare([]byte(password), p) == 1 {
+			return true, nil
+		}
+
+		return false, nil
+	}
+}
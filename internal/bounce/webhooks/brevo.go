@@ -0,0 +1,109 @@
+package webhooks
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/knadh/listmonk/models"
+	"github.com/labstack/echo/v4"
+)
+
+// reCampUUID matches a campaign UUID passed in a provider's custom ID field.
+var reCampUUID = regexp.MustCompile(`^[a-z0-9]{8}-[a-z0-9]{4}-[a-z0-9]{4}-[a-z0-9]{4}-[a-z0-9]{12}$`)
+
+type brevoNotif struct {
+	Event     string `json:"event"`
+	Email     string `json:"email"`
+	Timestamp int64  `json:"ts_event"`
+	Reason    string `json:"reason"`
+	Link      string `json:"link"`
+
+	// Custom X-Mailin-custom header value, if any.
+	Custom string `json:"X-Mailin-custom"`
+}
+
+// Brevo handles Brevo (formerly Sendinblue) transactional webhook notifications
+// (bounce, spam, delivered, opened and click events). Brevo doesn't sign webhooks. Requests are authenticated
+// with HTTP basic auth.
+type Brevo struct {
+	authHandler echo.HandlerFunc
+}
+
+// NewBrevo returns a new Brevo instance. The basic auth username and password are required.
+func NewBrevo(username, password string) (*Brevo, error) {
+	if username == "" || password == "" {
+		return nil, errNoCredentials
+	}
+
+	return &Brevo{
+		authHandler: newBasicAuth(username, password),
+	}, nil
+}
+
+// ProcessBounce processes a Brevo webhook notification and returns one bounce
+// or delivery event.
+func (p *Brevo) ProcessBounce(b []byte, c echo.Context) ([]models.Bounce, []models.DeliveryEvent, error) {
+	// Do basicauth.
+	if err := p.authHandler(c); err != nil {
+		return nil, nil, err
+	}
+
+	var n brevoNotif
+	if err := json.Unmarshal(b, &n); err != nil {
+		return nil, nil, fmt.Errorf("error unmarshalling Brevo notification: %v", err)
+	}
+
+	tstamp := time.Now()
+	if n.Timestamp > 0 {
+		tstamp = time.Unix(n.Timestamp, 0)
+	}
+
+	// The campaign UUID can be passed in the X-Mailin-custom header.
+	campUUID := ""
+	if reCampUUID.MatchString(n.Custom) {
+		campUUID = n.Custom
+	}
+
+	var typ string
+	switch n.Event {
+	case "hard_bounce", "invalid_email":
+		typ = models.BounceTypeHard
+	case "soft_bounce", "blocked":
+		typ = models.BounceTypeSoft
+	case "spam", "complaint":
+		typ = models.BounceTypeComplaint
+	case "delivered", "opened", "click":
+		// unique_opened is not picked up as it's always sent along with opened.
+		e := models.DeliveryEvent{
+			Type:         models.DeliveryEventDelivered,
+			Email:        strings.ToLower(n.Email),
+			CampaignUUID: campUUID,
+			Source:       "brevo",
+			Meta:         json.RawMessage(b),
+			CreatedAt:    tstamp,
+		}
+		if n.Event == "opened" {
+			e.Type = models.DeliveryEventOpen
+		} else if n.Event == "click" {
+			e.Type = models.DeliveryEventClick
+			e.URL = n.Link
+		}
+
+		return nil, []models.DeliveryEvent{e}, nil
+	default:
+		// Ignore other events.
+		return nil, nil, nil
+	}
+
+	return []models.Bounce{{
+		Email:        strings.ToLower(n.Email),
+		CampaignUUID: campUUID,
+		Type:         typ,
+		Source:       "brevo",
+		Meta:         json.RawMessage(b),
+		CreatedAt:    tstamp,
+	}}, nil, nil
+}
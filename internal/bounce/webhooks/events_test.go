@@ -0,0 +1,312 @@
+package webhooks
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/knadh/listmonk/models"
+)
+
+// sesCertPath is the path of the (fake) SNS signing certificate in tests.
+const sesCertPath = "/SimpleNotificationService-test.pem"
+
+// newTestSES returns an SES instance with a cached self-signed certificate and a
+// function that returns signed SNS notifications of SES messages.
+func newTestSES(t *testing.T) (*SES, func(msg string) []byte) {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tpl := &x509.Certificate{SerialNumber: big.NewInt(1), NotBefore: time.Now(), NotAfter: time.Now().Add(time.Hour)}
+	der, err := x509.CreateCertificate(rand.Reader, tpl, tpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := NewSES()
+	s.certs[sesCertPath] = cert
+
+	return s, func(msg string) []byte {
+		n := sesNotif{
+			Type:           "Notification",
+			MessageId:      "1",
+			Message:        msg,
+			Timestamp:      "2024-01-01T00:00:00.000Z",
+			TopicArn:       "arn:aws:sns:us-east-1:1:ses",
+			SigningCertURL: "https://sns.us-east-1.amazonaws.com" + sesCertPath,
+		}
+		h := sha1.Sum(s.buildSignature(n))
+		sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA1, h[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		n.Signature = base64.StdEncoding.EncodeToString(sig)
+
+		b, _ := json.Marshal(n)
+		return b
+	}
+}
+
+func TestSES(t *testing.T) {
+	s, sign := newTestSES(t)
+
+	mail := `"mail": {"timestamp": "2024-01-01T00:00:00.000Z", "destination": ["A@example.com"],
+		"headers": [{"name": "X-Listmonk-Campaign", "value": "` + testCampUUID + `"}]}`
+
+	cases := []struct {
+		name  string
+		msg   string
+		typ   string
+		event string
+		url   string
+	}{
+		{name: "permanent bounce", msg: `{"notificationType": "Bounce", "bounce": {"bounceType": "Permanent"}, ` + mail + `}`,
+			typ: models.BounceTypeHard},
+		{name: "transient bounce", msg: `{"notificationType": "Bounce", "bounce": {"bounceType": "Transient"}, ` + mail + `}`,
+			typ: models.BounceTypeSoft},
+		{name: "mailbox full", msg: `{"eventType": "Bounce", "bounce": {"bounceType": "Transient", "bouncedRecipients": [{"status": "5.4.4"}]}, ` + mail + `}`,
+			typ: models.BounceTypeHard},
+		{name: "complaint", msg: `{"eventType": "Complaint", ` + mail + `}`, typ: models.BounceTypeComplaint},
+		{name: "delivery", msg: `{"eventType": "Delivery", "delivery": {"timestamp": "2024-01-02T00:00:00.000Z"}, ` + mail + `}`,
+			event: models.DeliveryEventDelivered},
+		{name: "open", msg: `{"eventType": "Open", ` + mail + `}`, event: models.DeliveryEventOpen},
+		{name: "click", msg: `{"eventType": "Click", "click": {"link": "https://example.com"}, ` + mail + `}`,
+			event: models.DeliveryEventClick, url: "https://example.com"},
+		{name: "ignored", msg: `{"eventType": "Send", ` + mail + `}`},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			bs, evs, err := s.ProcessBounce(sign(c.msg))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			switch {
+			case c.typ != "":
+				if len(bs) != 1 || bs[0].Type != c.typ || bs[0].Email != "a@example.com" || bs[0].CampaignUUID != testCampUUID {
+					t.Errorf("expected a %s bounce, got %+v", c.typ, bs)
+				}
+			case c.event != "":
+				if len(evs) != 1 || evs[0].Type != c.event || evs[0].URL != c.url || evs[0].CampaignUUID != testCampUUID {
+					t.Errorf("expected a %s event, got %+v", c.event, evs)
+				}
+			default:
+				if len(bs) != 0 || len(evs) != 0 {
+					t.Errorf("expected nothing, got %+v %+v", bs, evs)
+				}
+			}
+		})
+	}
+
+	// The delivery time is the event's time and not the e-mail's.
+	_, evs, _ := s.ProcessBounce(sign(cases[4].msg))
+	if len(evs) != 1 || evs[0].CreatedAt.Day() != 2 {
+		t.Errorf("unexpected delivery time: %+v", evs)
+	}
+
+	// Tampered notifications are rejected.
+	b := sign(cases[0].msg)
+	var n sesNotif
+	json.Unmarshal(b, &n)
+	n.Message = cases[3].msg
+	b, _ = json.Marshal(n)
+	if _, _, err := s.ProcessBounce(b); !isAuthErr(err) {
+		t.Errorf("expected auth error, got %v", err)
+	}
+}
+
+func TestSendgrid(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := NewSendgrid(base64.StdEncoding.EncodeToString(pub))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body := []byte(`[
+		{"email": "A@example.com", "timestamp": 1700000000, "event": "bounce", "bounce_classification": "invalid", "XListmonkCampaign": "` + testCampUUID + `"},
+		{"email": "b@example.com", "event": "bounce", "bounce_classification": "technical"},
+		{"email": "c@example.com", "event": "spamreport"},
+		{"email": "d@example.com", "event": "delivered"},
+		{"email": "e@example.com", "event": "open", "XListmonkCampaign": "` + testCampUUID + `"},
+		{"email": "f@example.com", "event": "click", "url": "https://example.com"},
+		{"email": "g@example.com", "event": "processed"}
+	]`)
+	ts := "1700000000"
+	h := sha256.Sum256(append([]byte(ts), body...))
+	sig, err := ecdsa.SignASN1(rand.Reader, key, h[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bs, evs, err := s.ProcessBounce(base64.StdEncoding.EncodeToString(sig), ts, body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wantBounces := []string{models.BounceTypeHard, models.BounceTypeSoft, models.BounceTypeComplaint}
+	if len(bs) != len(wantBounces) {
+		t.Fatalf("expected %d bounces, got %+v", len(wantBounces), bs)
+	}
+	for i, typ := range wantBounces {
+		if bs[i].Type != typ {
+			t.Errorf("bounce %d: expected %s, got %s", i, typ, bs[i].Type)
+		}
+	}
+	if bs[0].Email != "a@example.com" || bs[0].CampaignUUID != testCampUUID || bs[0].CreatedAt.Unix() != 1700000000 {
+		t.Errorf("unexpected bounce: %+v", bs[0])
+	}
+
+	wantEvents := []string{models.DeliveryEventDelivered, models.DeliveryEventOpen, models.DeliveryEventClick}
+	if len(evs) != len(wantEvents) {
+		t.Fatalf("expected %d events, got %+v", len(wantEvents), evs)
+	}
+	for i, typ := range wantEvents {
+		if evs[i].Type != typ {
+			t.Errorf("event %d: expected %s, got %s", i, typ, evs[i].Type)
+		}
+	}
+	if evs[1].CampaignUUID != testCampUUID || evs[2].URL != "https://example.com" {
+		t.Errorf("unexpected events: %+v", evs)
+	}
+
+	// The signature covers the timestamp.
+	if _, _, err := s.ProcessBounce(base64.StdEncoding.EncodeToString(sig), "1700000001", body); !isAuthErr(err) {
+		t.Errorf("expected auth error, got %v", err)
+	}
+}
+
+func TestPostmark(t *testing.T) {
+	p := NewPostmark("u", "p")
+
+	cases := []struct {
+		body  string
+		typ   string
+		event string
+		ok    bool
+	}{
+		{`{"RecordType": "Bounce", "Type": "HardBounce", "Email": "A@example.com"}`, models.BounceTypeHard, "", true},
+		{`{"RecordType": "Bounce", "Type": "Transient", "Email": "a@example.com"}`, models.BounceTypeSoft, "", true},
+		{`{"RecordType": "SpamComplaint", "Type": "SpamComplaint", "Email": "a@example.com"}`, models.BounceTypeComplaint, "", true},
+		{`{"RecordType": "Bounce", "Type": "Unknown", "Email": "a@example.com"}`, "", "", false},
+		{`{"RecordType": "Delivery", "Recipient": "A@example.com"}`, "", models.DeliveryEventDelivered, true},
+		{`{"RecordType": "Open", "Recipient": "a@example.com"}`, "", models.DeliveryEventOpen, true},
+		{`{"RecordType": "Click", "Recipient": "a@example.com", "OriginalLink": "https://example.com"}`, "", models.DeliveryEventClick, true},
+		{`{"RecordType": "SubscriptionChange"}`, "", "", true},
+	}
+
+	for _, c := range cases {
+		bs, evs, err := p.ProcessBounce([]byte(c.body), newCtx("u", "p"))
+		if (err == nil) != c.ok {
+			t.Errorf("%s: expected ok=%v, got %v", c.body, c.ok, err)
+			continue
+		}
+
+		switch {
+		case c.typ != "":
+			if len(bs) != 1 || bs[0].Type != c.typ || bs[0].Email != "a@example.com" {
+				t.Errorf("%s: unexpected bounces: %+v", c.body, bs)
+			}
+		case c.event != "":
+			if len(evs) != 1 || evs[0].Type != c.event || evs[0].Email != "a@example.com" {
+				t.Errorf("%s: unexpected events: %+v", c.body, evs)
+			}
+		default:
+			if len(bs) != 0 || len(evs) != 0 {
+				t.Errorf("%s: expected nothing, got %+v %+v", c.body, bs, evs)
+			}
+		}
+	}
+}
+
+func TestResend(t *testing.T) {
+	secret := []byte("secret")
+	r, err := NewResend("whsec_"+base64.StdEncoding.EncodeToString(secret), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sign := func(id, ts, body string) string {
+		mac := hmac.New(sha256.New, secret)
+		mac.Write([]byte(id + "." + ts + "." + body))
+		return "v1,bad v1," + base64.StdEncoding.EncodeToString(mac.Sum(nil))
+	}
+
+	hdrs := `"headers": [{"name": "x-listmonk-campaign", "value": "` + testCampUUID + `"}]`
+	cases := []struct {
+		body  string
+		typ   string
+		event string
+	}{
+		{`{"type": "email.bounced", "data": {"to": ["A@example.com", "b@example.com"], "bounce": {"type": "Permanent"}, ` + hdrs + `}}`,
+			models.BounceTypeHard, ""},
+		{`{"type": "email.bounced", "data": {"to": ["a@example.com", "b@example.com"], "bounce": {"type": "Transient"}, ` + hdrs + `}}`,
+			models.BounceTypeSoft, ""},
+		{`{"type": "email.complained", "data": {"to": ["a@example.com", "b@example.com"], ` + hdrs + `}}`,
+			models.BounceTypeComplaint, ""},
+		{`{"type": "email.opened", "data": {"to": ["a@example.com", "b@example.com"], ` + hdrs + `}}`,
+			"", models.DeliveryEventOpen},
+		{`{"type": "email.sent", "data": {"to": ["a@example.com"]}}`, "", ""},
+	}
+
+	ts := strconv.FormatInt(time.Now().Unix(), 10)
+	for i, c := range cases {
+		id := "msg_" + strconv.Itoa(i)
+		bs, evs, err := r.ProcessBounce(id, ts, sign(id, ts, c.body), []byte(c.body))
+		if err != nil {
+			t.Fatalf("%s: %v", c.body, err)
+		}
+
+		// There's one bounce or event per recipient.
+		switch {
+		case c.typ != "":
+			if len(bs) != 2 || bs[0].Type != c.typ || bs[0].Email != "a@example.com" || bs[1].CampaignUUID != testCampUUID {
+				t.Errorf("%s: unexpected bounces: %+v", c.body, bs)
+			}
+		case c.event != "":
+			if len(evs) != 2 || evs[0].Type != c.event || evs[1].Email != "b@example.com" || evs[1].CampaignUUID != testCampUUID {
+				t.Errorf("%s: unexpected events: %+v", c.body, evs)
+			}
+		default:
+			if len(bs) != 0 || len(evs) != 0 {
+				t.Errorf("%s: expected nothing, got %+v %+v", c.body, bs, evs)
+			}
+		}
+	}
+
+	// Invalid signatures and stale timestamps fail authentication.
+	body := cases[0].body
+	if _, _, err := r.ProcessBounce("x", ts, sign("y", ts, body), []byte(body)); !isAuthErr(err) {
+		t.Errorf("expected auth error, got %v", err)
+	}
+	old := strconv.FormatInt(time.Now().Add(-time.Hour).Unix(), 10)
+	if _, _, err := r.ProcessBounce("x", old, sign("x", old, body), []byte(body)); !isAuthErr(err) {
+		t.Errorf("expected auth error, got %v", err)
+	}
+}
@@ -0,0 +1,137 @@
+package webhooks
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/knadh/listmonk/models"
+)
+
+type mailgunNotif struct {
+	Signature struct {
+		Timestamp string `json:"timestamp"`
+		Token     string `json:"token"`
+		Signature string `json:"signature"`
+	} `json:"signature"`
+
+	EventData struct {
+		Event         string         `json:"event"`
+		Severity      string         `json:"severity"`
+		Reason        string         `json:"reason"`
+		Recipient     string         `json:"recipient"`
+		URL           string         `json:"url"`
+		Timestamp     float64        `json:"timestamp"`
+		UserVariables map[string]any `json:"user-variables"`
+	} `json:"event-data"`
+}
+
+// Mailgun handles Mailgun webhook notifications (failed, complained, delivered,
+// opened and clicked events).
+type Mailgun struct {
+	signingKey []byte
+	replay     *ReplayGuard
+}
+
+// NewMailgun returns a new Mailgun instance. key is the HTTP webhook signing key and is required.
+// The signature's timestamp and token are checked for replays with the (optional) replay guard.
+func NewMailgun(key []byte, replay *ReplayGuard) (*Mailgun, error) {
+	if len(key) == 0 {
+		return nil, errors.New("mailgun webhook signing key is required")
+	}
+
+	return &Mailgun{signingKey: key, replay: replay}, nil
+}
+
+// ProcessBounce processes a Mailgun webhook notification and returns one bounce
+// or delivery event.
+func (m *Mailgun) ProcessBounce(b []byte) ([]models.Bounce, []models.DeliveryEvent, error) {
+	var n mailgunNotif
+	if err := json.Unmarshal(b, &n); err != nil {
+		return nil, nil, fmt.Errorf("error unmarshalling Mailgun notification: %v", err)
+	}
+
+	// The signature is the hex HMAC-SHA256 of timestamp + token.
+	sig, err := hex.DecodeString(n.Signature.Signature)
+	if err != nil {
+		return nil, nil, &AuthError{fmt.Errorf("invalid signature encoding: %v", err)}
+	}
+
+	mac := hmac.New(sha256.New, m.signingKey)
+	mac.Write([]byte(n.Signature.Timestamp + n.Signature.Token))
+	if !hmac.Equal(mac.Sum(nil), sig) {
+		return nil, nil, &AuthError{ErrInvalidSignature}
+	}
+
+	ts, err := strconv.ParseInt(n.Signature.Timestamp, 10, 64)
+	if err != nil {
+		return nil, nil, &AuthError{fmt.Errorf("invalid timestamp: %v", err)}
+	}
+	if err := m.replay.Check(time.Unix(ts, 0), n.Signature.Token); err != nil {
+		return nil, nil, err
+	}
+
+	sec, frac := math.Modf(n.EventData.Timestamp)
+	tstamp := time.Unix(int64(sec), int64(frac*1e9))
+	if n.EventData.Timestamp == 0 {
+		tstamp = time.Now()
+	}
+
+	var (
+		email    = strings.ToLower(n.EventData.Recipient)
+		campUUID = getString(n.EventData.UserVariables, models.EmailHeaderCampaignUUID)
+		typ      string
+	)
+	switch n.EventData.Event {
+	case "failed":
+		typ = models.BounceTypeSoft
+		if n.EventData.Severity == "permanent" {
+			typ = models.BounceTypeHard
+		}
+	case "complained":
+		typ = models.BounceTypeComplaint
+	case "delivered", "opened", "clicked":
+		e := models.DeliveryEvent{
+			Type:         models.DeliveryEventDelivered,
+			Email:        email,
+			CampaignUUID: campUUID,
+			Source:       "mailgun",
+			Meta:         json.RawMessage(b),
+			CreatedAt:    tstamp,
+		}
+		if n.EventData.Event == "opened" {
+			e.Type = models.DeliveryEventOpen
+		} else if n.EventData.Event == "clicked" {
+			e.Type = models.DeliveryEventClick
+			e.URL = n.EventData.URL
+		}
+
+		return nil, []models.DeliveryEvent{e}, nil
+	default:
+		// Ignore other events.
+		return nil, nil, nil
+	}
+
+	return []models.Bounce{{
+		Email:        email,
+		CampaignUUID: campUUID,
+		Type:         typ,
+		Source:       "mailgun",
+		Meta:         json.RawMessage(b),
+		CreatedAt:    tstamp,
+	}}, nil, nil
+}
+
+// getString returns the string value of a key in a map of arbitrary custom
+// variables (metadata) that providers echo back in webhooks.
+func getString(m map[string]any, key string) string {
+	v, _ := m[key].(string)
+	return v
+}
@@ -0,0 +1,126 @@
+package webhooks
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/knadh/listmonk/models"
+	"github.com/labstack/echo/v4"
+)
+
+type mailjetNotif struct {
+	Event      string `json:"event"`
+	Time       int64  `json:"time"`
+	Email      string `json:"email"`
+	HardBounce bool   `json:"hard_bounce"`
+	CustomID   string `json:"CustomID"`
+	URL        string `json:"url"`
+}
+
+// Mailjet handles Mailjet event webhook notifications (bounce, blocked, spam, sent,
+// open and click events).
+// Mailjet doesn't sign webhooks. Requests are authenticated with HTTP basic auth.
+type Mailjet struct {
+	authHandler echo.HandlerFunc
+}
+
+// NewMailjet returns a new Mailjet instance. The basic auth username and password are required.
+func NewMailjet(username, password string) (*Mailjet, error) {
+	if username == "" || password == "" {
+		return nil, errNoCredentials
+	}
+
+	return &Mailjet{
+		authHandler: newBasicAuth(username, password),
+	}, nil
+}
+
+// ProcessBounce processes Mailjet notifications and returns zero or more bounces
+// and delivery events.
+// Mailjet sends either one event or a batch of events (array) per request.
+func (m *Mailjet) ProcessBounce(b []byte, c echo.Context) ([]models.Bounce, []models.DeliveryEvent, error) {
+	// Do basicauth.
+	if err := m.authHandler(c); err != nil {
+		return nil, nil, err
+	}
+
+	var events []json.RawMessage
+	if b = bytes.TrimSpace(b); len(b) > 0 && b[0] == '[' {
+		if err := json.Unmarshal(b, &events); err != nil {
+			return nil, nil, fmt.Errorf("error unmarshalling Mailjet notification: %v", err)
+		}
+	} else {
+		events = []json.RawMessage{b}
+	}
+
+	var (
+		bounces = make([]models.Bounce, 0, len(events))
+		evs     = make([]models.DeliveryEvent, 0, len(events))
+	)
+	for _, ev := range events {
+		var n mailjetNotif
+		if err := json.Unmarshal(ev, &n); err != nil {
+			return nil, nil, fmt.Errorf("error unmarshalling Mailjet notification: %v", err)
+		}
+
+		tstamp := time.Now()
+		if n.Time > 0 {
+			tstamp = time.Unix(n.Time, 0)
+		}
+
+		// The campaign UUID can be passed as the CustomID (X-MJ-CustomID header).
+		campUUID := ""
+		if reCampUUID.MatchString(n.CustomID) {
+			campUUID = n.CustomID
+		}
+
+		var typ string
+		switch n.Event {
+		case "bounce":
+			typ = models.BounceTypeSoft
+			if n.HardBounce {
+				typ = models.BounceTypeHard
+			}
+		case "blocked":
+			typ = models.BounceTypeSoft
+		case "spam":
+			typ = models.BounceTypeComplaint
+		case "sent", "open", "click":
+			// Mailjet's "sent" event is sent when the message is accepted by the recipient's server.
+			e := models.DeliveryEvent{
+				Type:         models.DeliveryEventDelivered,
+				Email:        strings.ToLower(n.Email),
+				CampaignUUID: campUUID,
+				Source:       "mailjet",
+				Meta:         ev,
+				CreatedAt:    tstamp,
+			}
+			if n.Event == "open" {
+				e.Type = models.DeliveryEventOpen
+			} else if n.Event == "click" {
+				e.Type = models.DeliveryEventClick
+				e.URL = n.URL
+			}
+
+			evs = append(evs, e)
+			continue
+		default:
+			// Ignore other events.
+			continue
+		}
+
+		bounces = append(bounces, models.Bounce{
+			Email:        strings.ToLower(n.Email),
+			CampaignUUID: campUUID,
+			Type:         typ,
+			Source:       "mailjet",
+			Meta:         ev,
+			CreatedAt:    tstamp,
+		})
+	}
+
+	return bounces, evs, nil
+}
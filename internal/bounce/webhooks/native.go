@@ -0,0 +1,124 @@
+package webhooks
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var (
+	ErrReplayTimestamp = errors.New("timestamp out of range")
+	ErrReplayNonce     = errors.New("nonce already used")
+)
+
+// DefaultMaxAge is the max age of signed webhook requests if none is set.
+const DefaultMaxAge = time.Minute * 5
+
+// ReplayGuard rejects webhook requests whose timestamp is older than the max age,
+// or whose nonce has already been seen within the max age.
+type ReplayGuard struct {
+	maxAge time.Duration
+
+	seen    map[string]time.Time
+	lastGC  time.Time
+	maxSeen int
+	sync.Mutex
+}
+
+// NewReplayGuard returns a new ReplayGuard. If maxAge isn't set, DefaultMaxAge is used.
+func NewReplayGuard(maxAge time.Duration) *ReplayGuard {
+	if maxAge <= 0 {
+		maxAge = DefaultMaxAge
+	}
+
+	return &ReplayGuard{
+		maxAge:  maxAge,
+		seen:    make(map[string]time.Time),
+		lastGC:  time.Now(),
+		maxSeen: 100000,
+	}
+}
+
+// Check checks the timestamp and the nonce (optional) of a request. The nonce
+// is remembered for the max age.
+func (r *ReplayGuard) Check(ts time.Time, nonce string) error {
+	if r == nil {
+		return nil
+	}
+
+	now := time.Now()
+	if d := now.Sub(ts); d > r.maxAge || d < -r.maxAge {
+		return &AuthError{ErrReplayTimestamp}
+	}
+
+	if nonce == "" {
+		return nil
+	}
+
+	r.Lock()
+	defer r.Unlock()
+
+	// Forget the nonces that have expired.
+	if now.Sub(r.lastGC) > r.maxAge || len(r.seen) >= r.maxSeen {
+		for n, t := range r.seen {
+			if now.Sub(t) > r.maxAge {
+				delete(r.seen, n)
+			}
+		}
+		r.lastGC = now
+	}
+
+	if _, ok := r.seen[nonce]; ok {
+		return &AuthError{ErrReplayNonce}
+	}
+	r.seen[nonce] = now
+
+	return nil
+}
+
+// Native verifies the HMAC signature of bounce payloads posted to the
+// native bounce webhook.
+type Native struct {
+	secret []byte
+	replay *ReplayGuard
+}
+
+// NewNative returns a new Native instance. secret is the HMAC signing secret.
+func NewNative(secret string, replay *ReplayGuard) *Native {
+	return &Native{secret: []byte(secret), replay: replay}
+}
+
+// Verify verifies a native bounce webhook request. The signature is the hex
+// HMAC-SHA256 of "timestamp.nonce.body", where timestamp is the Unix timestamp (seconds)
+// of the request and nonce is a unique, random string for every request.
+func (n *Native) Verify(timestamp, nonce, sig string, b []byte) error {
+	if timestamp == "" || nonce == "" || sig == "" {
+		return &AuthError{errors.New("missing signature, timestamp or nonce")}
+	}
+
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return &AuthError{fmt.Errorf("invalid timestamp: %v", err)}
+	}
+
+	got, err := hex.DecodeString(sig)
+	if err != nil {
+		return &AuthError{fmt.Errorf("invalid signature encoding: %v", err)}
+	}
+
+	mac := hmac.New(sha256.New, n.secret)
+	mac.Write([]byte(timestamp + "." + nonce + "."))
+	mac.Write(b)
+	if !hmac.Equal(mac.Sum(nil), got) {
+		return &AuthError{ErrInvalidSignature}
+	}
+
+	// Only check for replays once the signature is known to be valid so that
+	// invalid requests can't fill up the nonces.
+	return n.replay.Check(time.Unix(ts, 0), nonce)
+}
@@ -0,0 +1,94 @@
+package webhooks
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestReplayGuard(t *testing.T) {
+	// The max age defaults to DefaultMaxAge.
+	if r := NewReplayGuard(0); r.maxAge != DefaultMaxAge {
+		t.Fatalf("expected default max age, got %v", r.maxAge)
+	}
+
+	r := NewReplayGuard(time.Minute)
+	now := time.Now()
+
+	cases := []struct {
+		name  string
+		ts    time.Time
+		nonce string
+		err   error
+	}{
+		{"fresh", now, "a", nil},
+		{"no nonce", now, "", nil},
+		{"other nonce", now.Add(-30 * time.Second), "b", nil},
+		{"replayed nonce", now, "a", ErrReplayNonce},
+		{"old", now.Add(-2 * time.Minute), "c", ErrReplayTimestamp},
+		{"future", now.Add(2 * time.Minute), "d", ErrReplayTimestamp},
+	}
+
+	for _, c := range cases {
+		err := r.Check(c.ts, c.nonce)
+		if !errors.Is(err, c.err) {
+			t.Errorf("%s: expected %v, got %v", c.name, c.err, err)
+		}
+		if err != nil && !isAuthErr(err) {
+			t.Errorf("%s: expected auth error, got %v", c.name, err)
+		}
+	}
+
+	// Expired nonces are forgotten.
+	r.lastGC = now.Add(-2 * time.Minute)
+	r.seen["a"] = now.Add(-2 * time.Minute)
+	if err := r.Check(now, "a"); err != nil {
+		t.Errorf("expected expired nonce to be accepted, got %v", err)
+	}
+}
+
+func TestNative(t *testing.T) {
+	n := NewNative("secret", NewReplayGuard(time.Minute))
+	body := []byte(`{"email": "a@example.com"}`)
+
+	sign := func(secret, ts, nonce string) string {
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write([]byte(ts + "." + nonce + "."))
+		mac.Write(body)
+		return hex.EncodeToString(mac.Sum(nil))
+	}
+
+	var (
+		now = strconv.FormatInt(time.Now().Unix(), 10)
+		old = strconv.FormatInt(time.Now().Add(-time.Hour).Unix(), 10)
+	)
+	cases := []struct {
+		name      string
+		ts, nonce string
+		sig       string
+		ok        bool
+	}{
+		{"valid", now, "n1", sign("secret", now, "n1"), true},
+		{"replayed", now, "n1", sign("secret", now, "n1"), false},
+		{"wrong secret", now, "n2", sign("other", now, "n2"), false},
+		{"signed with another nonce", now, "n3", sign("secret", now, "n2"), false},
+		{"old", old, "n4", sign("secret", old, "n4"), false},
+		{"bad encoding", now, "n5", "xyz", false},
+		{"no nonce", now, "", sign("secret", now, ""), false},
+		{"bad timestamp", "now", "n6", sign("secret", "now", "n6"), false},
+	}
+
+	for _, c := range cases {
+		err := n.Verify(c.ts, c.nonce, c.sig, body)
+		if (err == nil) != c.ok {
+			t.Errorf("%s: expected ok=%v, got %v", c.name, c.ok, err)
+		}
+		if err != nil && !isAuthErr(err) {
+			t.Errorf("%s: expected auth error, got %v", c.name, err)
+		}
+	}
+}
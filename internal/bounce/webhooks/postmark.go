@@ -1,7 +1,6 @@
 package webhooks
 
 import (
-	"crypto/subtle"
 	"encoding/json"
 	"fmt"
 	"strings"
@@ -9,7 +8,6 @@ import (
 
 	"github.com/knadh/listmonk/models"
 	"github.com/labstack/echo/v4"
-	"github.com/labstack/echo/v4/middleware"
 )
 
 type postmarkNotif struct {
@@ -33,6 +31,12 @@ type postmarkNotif struct {
 	CanActivate   bool              `json:"CanActivate"`
 	Subject       string            `json:"Subject"`
 	Content       string            `json:"Content"`
+
+	// Delivery, Open and Click records.
+	Recipient    string    `json:"Recipient"`
+	DeliveredAt  time.Time `json:"DeliveredAt"`
+	ReceivedAt   time.Time `json:"ReceivedAt"`
+	OriginalLink string    `json:"OriginalLink"`
 }
 
 // Postmark handles webhook notifications (mainly bounce notifications).
@@ -40,29 +44,65 @@ type Postmark struct {
 	authHandler echo.HandlerFunc
 }
 
+// NewPostmark returns a new Postmark instance. If the username and password are
+// both empty, requests aren't authenticated, as in earlier versions.
 func NewPostmark(username, password string) *Postmark {
+	if username == "" && password == "" {
+		return &Postmark{}
+	}
+
 	return &Postmark{
-		authHandler: middleware.BasicAuth(makePostmarkAuthHandler(username, password))(func(c echo.Context) error {
-			return nil
-		}),
+		authHandler: newBasicAuth(username, password),
 	}
 }
 
-// ProcessBounce processes Postmark bounce notifications and returns one object.
-func (p *Postmark) ProcessBounce(b []byte, c echo.Context) ([]models.Bounce, error) {
+// ProcessBounce processes Postmark bounce notifications and returns one object, either
+// a bounce (Bounce and SpamComplaint records) or a delivery event (Delivery, Open and Click records).
+func (p *Postmark) ProcessBounce(b []byte, c echo.Context) ([]models.Bounce, []models.DeliveryEvent, error) {
 	// Do basicauth.
-	if err := p.authHandler(c); err != nil {
-		return nil, err
+	if p.authHandler != nil {
+		if err := p.authHandler(c); err != nil {
+			return nil, nil, err
+		}
 	}
 
 	var n postmarkNotif
 	if err := json.Unmarshal(b, &n); err != nil {
-		return nil, fmt.Errorf("error unmarshalling postmark notification: %v", err)
+		return nil, nil, fmt.Errorf("error unmarshalling postmark notification: %v", err)
 	}
 
-	// Ignore irrelevant messages.
-	if n.RecordType != "Bounce" && n.RecordType != "SpamComplaint" {
-		return nil, nil
+	// Look for the campaign ID in headers.
+	campUUID := ""
+	if v, ok := n.Metadata["X-Listmonk-Campaign"]; ok {
+		campUUID = v
+	}
+
+	switch n.RecordType {
+	case "Bounce", "SpamComplaint":
+	case "Delivery", "Open", "Click":
+		e := models.DeliveryEvent{
+			Email:        strings.ToLower(n.Recipient),
+			CampaignUUID: campUUID,
+			Source:       "postmark",
+			Meta:         json.RawMessage(b),
+			CreatedAt:    n.ReceivedAt,
+		}
+
+		switch n.RecordType {
+		case "Delivery":
+			e.Type = models.DeliveryEventDelivered
+			e.CreatedAt = n.DeliveredAt
+		case "Open":
+			e.Type = models.DeliveryEventOpen
+		case "Click":
+			e.Type = models.DeliveryEventClick
+			e.URL = n.OriginalLink
+		}
+
+		return nil, []models.DeliveryEvent{e}, nil
+	default:
+		// Ignore irrelevant messages.
+		return nil, nil, nil
 	}
 
 	supportedBounceType := true
@@ -79,13 +119,7 @@ func (p *Postmark) ProcessBounce(b []byte, c echo.Context) ([]models.Bounce, err
 	}
 
 	if !supportedBounceType {
-		return nil, fmt.Errorf("unsupported bounce type: %v", n.Type)
-	}
-
-	// Look for the campaign ID in headers.
-	campUUID := ""
-	if v, ok := n.Metadata["X-Listmonk-Campaign"]; ok {
-		campUUID = v
+		return nil, nil, fmt.Errorf("unsupported bounce type: %v", n.Type)
 	}
 
 	return []models.Bounce{{
@@ -95,24 +129,5 @@ func (p *Postmark) ProcessBounce(b []byte, c echo.Context) ([]models.Bounce, err
 		Source:       "postmark",
 		Meta:         json.RawMessage(b),
 		CreatedAt:    n.BouncedAt,
-	}}, nil
-}
-
-func makePostmarkAuthHandler(cfgUser, cfgPassword string) func(username, password string, c echo.Context) (bool, error) {
-	var (
-		u = []byte(cfgUser)
-		p = []byte(cfgPassword)
-	)
-
-	return func(username, password string, c echo.Context) (bool, error) {
-		if len(u) == 0 || len(p) == 0 {
-			return true, nil
-		}
-
-		if subtle.ConstantTimeCompare([]byte(username), u) == 1 && subtle.ConstantTimeCompare([]byte(password), p) == 1 {
-			return true, nil
-		}
-
-		return false, nil
-	}
+	}}, nil, nil
 }
@@ -0,0 +1,164 @@
+package webhooks
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/knadh/listmonk/models"
+)
+
+// resendMaxAge is the maximum age of a Resend webhook's timestamp.
+const resendMaxAge = time.Minute * 5
+
+type resendNotif struct {
+	Type      string    `json:"type"`
+	CreatedAt time.Time `json:"created_at"`
+	Data      struct {
+		To      []string `json:"to"`
+		Headers []struct {
+			Name  string `json:"name"`
+			Value string `json:"value"`
+		} `json:"headers"`
+		Bounce struct {
+			Type string `json:"type"`
+		} `json:"bounce"`
+		Click struct {
+			Link string `json:"link"`
+		} `json:"click"`
+	} `json:"data"`
+}
+
+// Resend handles Resend webhook notifications (bounced, complained, delivered,
+// opened and clicked events), which are signed with Svix.
+type Resend struct {
+	secret []byte
+	replay *ReplayGuard
+}
+
+// NewResend returns a new Resend instance. key is the webhook signing secret (whsec_...).
+// Message IDs are checked for replays with the (optional) replay guard.
+func NewResend(key string, replay *ReplayGuard) (*Resend, error) {
+	secret, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(key, "whsec_"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid resend signing secret: %v", err)
+	}
+
+	return &Resend{secret: secret, replay: replay}, nil
+}
+
+// ProcessBounce processes a Resend webhook notification and returns zero or more bounces
+// or delivery events, one for each recipient.
+func (r *Resend) ProcessBounce(id, timestamp, sig string, b []byte) ([]models.Bounce, []models.DeliveryEvent, error) {
+	if err := r.verifyNotif(id, timestamp, sig, b); err != nil {
+		return nil, nil, err
+	}
+
+	var n resendNotif
+	if err := json.Unmarshal(b, &n); err != nil {
+		return nil, nil, fmt.Errorf("error unmarshalling Resend notification: %v", err)
+	}
+
+	var typ, evType string
+	switch n.Type {
+	case "email.bounced":
+		typ = models.BounceTypeSoft
+		if n.Data.Bounce.Type == "Permanent" {
+			typ = models.BounceTypeHard
+		}
+	case "email.complained":
+		typ = models.BounceTypeComplaint
+	case "email.delivered":
+		evType = models.DeliveryEventDelivered
+	case "email.opened":
+		evType = models.DeliveryEventOpen
+	case "email.clicked":
+		evType = models.DeliveryEventClick
+	default:
+		// Ignore other events.
+		return nil, nil, nil
+	}
+
+	campUUID := ""
+	for _, h := range n.Data.Headers {
+		if strings.EqualFold(h.Name, models.EmailHeaderCampaignUUID) {
+			campUUID = h.Value
+		}
+	}
+
+	tstamp := n.CreatedAt
+	if tstamp.IsZero() {
+		tstamp = time.Now()
+	}
+
+	if evType != "" {
+		evs := make([]models.DeliveryEvent, 0, len(n.Data.To))
+		for _, to := range n.Data.To {
+			evs = append(evs, models.DeliveryEvent{
+				Type:         evType,
+				Email:        strings.ToLower(to),
+				CampaignUUID: campUUID,
+				URL:          n.Data.Click.Link,
+				Source:       "resend",
+				Meta:         json.RawMessage(b),
+				CreatedAt:    tstamp,
+			})
+		}
+
+		return nil, evs, nil
+	}
+
+	out := make([]models.Bounce, 0, len(n.Data.To))
+	for _, to := range n.Data.To {
+		out = append(out, models.Bounce{
+			Email:        strings.ToLower(to),
+			CampaignUUID: campUUID,
+			Type:         typ,
+			Source:       "resend",
+			Meta:         json.RawMessage(b),
+			CreatedAt:    tstamp,
+		})
+	}
+
+	return out, nil, nil
+}
+
+// verifyNotif verifies the Svix signature of a notification. The signature header
+// is a space separated list of versioned signatures, eg: "v1,base64sig v1,base64sig",
+// of the base64 HMAC-SHA256 of "id.timestamp.body".
+func (r *Resend) verifyNotif(id, timestamp, sig string, b []byte) error {
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return &AuthError{fmt.Errorf("invalid timestamp: %v", err)}
+	}
+	if d := time.Since(time.Unix(ts, 0)); d > resendMaxAge || d < -resendMaxAge {
+		return &AuthError{ErrReplayTimestamp}
+	}
+
+	mac := hmac.New(sha256.New, r.secret)
+	mac.Write([]byte(id + "." + timestamp + "."))
+	mac.Write(b)
+	expected := mac.Sum(nil)
+
+	for _, s := range strings.Fields(sig) {
+		ver, val, ok := strings.Cut(s, ",")
+		if !ok || ver != "v1" {
+			continue
+		}
+
+		got, err := base64.StdEncoding.DecodeString(val)
+		if err != nil {
+			continue
+		}
+		if hmac.Equal(expected, got) {
+			return r.replay.Check(time.Unix(ts, 0), id)
+		}
+	}
+
+	return &AuthError{ErrInvalidSignature}
+}
@@ -7,7 +7,6 @@ import (
 	"encoding/asn1"
 	"encoding/base64"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"math/big"
 	"strings"
@@ -21,6 +20,7 @@ type sendgridNotif struct {
 	Timestamp            int64  `json:"timestamp"`
 	Event                string `json:"event"`
 	BounceClassification string `json:"bounce_classification"`
+	URL                  string `json:"url"`
 
 	// SendGrid flattens all X-headers and adds them to the bounce
 	// event notification.
@@ -49,48 +49,81 @@ func NewSendgrid(key string) (*Sendgrid, error) {
 	return &Sendgrid{pubKey: pubKey.(*ecdsa.PublicKey)}, nil
 }
 
-// ProcessBounce processes Sendgrid bounce notifications and returns one or more Bounce objects.
-func (s *Sendgrid) ProcessBounce(sig, timestamp string, b []byte) ([]models.Bounce, error) {
+// ProcessBounce processes Sendgrid event notifications and returns the bounces (bounce and
+// spamreport events) and delivery events (delivered, open and click events) in them.
+func (s *Sendgrid) ProcessBounce(sig, timestamp string, b []byte) ([]models.Bounce, []models.DeliveryEvent, error) {
 	if err := s.verifyNotif(sig, timestamp, b); err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	var notifs []sendgridNotif
-	if err := json.Unmarshal(b, &notifs); err != nil {
-		return nil, fmt.Errorf("error unmarshalling Sendgrid notification: %v", err)
+	// Each event's raw JSON is saved as the meta.
+	var events []json.RawMessage
+	if err := json.Unmarshal(b, &events); err != nil {
+		return nil, nil, fmt.Errorf("error unmarshalling Sendgrid notification: %v", err)
 	}
 
-	out := make([]models.Bounce, 0, len(notifs))
-	for _, n := range notifs {
-		if n.Event != "bounce" {
-			continue
-		}
-
-		typ := models.BounceTypeHard
-		if n.BounceClassification == "technical" || n.BounceClassification == "content" {
-			typ = models.BounceTypeSoft
+	var (
+		bounces = make([]models.Bounce, 0, len(events))
+		evs     = make([]models.DeliveryEvent, 0, len(events))
+	)
+	for _, ev := range events {
+		var n sendgridNotif
+		if err := json.Unmarshal(ev, &n); err != nil {
+			return nil, nil, fmt.Errorf("error unmarshalling Sendgrid notification: %v", err)
 		}
 
-		tstamp := time.Unix(n.Timestamp, 0)
-		bn := models.Bounce{
-			CampaignUUID: n.CampaignUUID,
-			Email:        strings.ToLower(n.Email),
-			Type:         typ,
-			Meta:         json.RawMessage(b),
-			Source:       "sendgrid",
-			CreatedAt:    tstamp,
+		var (
+			email  = strings.ToLower(n.Email)
+			tstamp = time.Unix(n.Timestamp, 0)
+		)
+
+		switch n.Event {
+		case "bounce", "spamreport":
+			typ := models.BounceTypeHard
+			if n.BounceClassification == "technical" || n.BounceClassification == "content" {
+				typ = models.BounceTypeSoft
+			}
+			if n.Event == "spamreport" {
+				typ = models.BounceTypeComplaint
+			}
+
+			bounces = append(bounces, models.Bounce{
+				CampaignUUID: n.CampaignUUID,
+				Email:        email,
+				Type:         typ,
+				Meta:         ev,
+				Source:       "sendgrid",
+				CreatedAt:    tstamp,
+			})
+
+		case "delivered", "open", "click":
+			typ := models.DeliveryEventDelivered
+			if n.Event == "open" {
+				typ = models.DeliveryEventOpen
+			} else if n.Event == "click" {
+				typ = models.DeliveryEventClick
+			}
+
+			evs = append(evs, models.DeliveryEvent{
+				Type:         typ,
+				CampaignUUID: n.CampaignUUID,
+				Email:        email,
+				URL:          n.URL,
+				Meta:         ev,
+				Source:       "sendgrid",
+				CreatedAt:    tstamp,
+			})
 		}
-		out = append(out, bn)
 	}
 
-	return out, nil
+	return bounces, evs, nil
 }
 
 // verifyNotif verifies the signature on a notification payload.
 func (s *Sendgrid) verifyNotif(sig, timestamp string, b []byte) error {
 	sigB, err := base64.StdEncoding.DecodeString(sig)
 	if err != nil {
-		return err
+		return &AuthError{fmt.Errorf("invalid signature encoding: %v", err)}
 	}
 
 	ecdsaSig := struct {
@@ -99,7 +132,7 @@ func (s *Sendgrid) verifyNotif(sig, timestamp string, b []byte) error {
 	}{}
 
 	if _, err := asn1.Unmarshal(sigB, &ecdsaSig); err != nil {
-		return fmt.Errorf("error asn1 unmarshal of signature: %v", err)
+		return &AuthError{fmt.Errorf("error asn1 unmarshal of signature: %v", err)}
 	}
 
 	h := sha256.New()
@@ -108,7 +141,7 @@ func (s *Sendgrid) verifyNotif(sig, timestamp string, b []byte) error {
 	hash := h.Sum(nil)
 
 	if !ecdsa.Verify(s.pubKey, hash, ecdsaSig.R, ecdsaSig.S) {
-		return errors.New("invalid signature")
+		return &AuthError{ErrInvalidSignature}
 	}
 
 	return nil
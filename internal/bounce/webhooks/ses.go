@@ -54,6 +54,16 @@ type sesMail struct {
 			Status string `json:"status"`
 		} `json:"bouncedRecipients"`
 	} `json:"bounce"`
+	Delivery struct {
+		Timestamp sesTimestamp `json:"timestamp"`
+	} `json:"delivery"`
+	Open struct {
+		Timestamp sesTimestamp `json:"timestamp"`
+	} `json:"open"`
+	Click struct {
+		Timestamp sesTimestamp `json:"timestamp"`
+		Link      string       `json:"link"`
+	} `json:"click"`
 	Mail struct {
 		Timestamp        sesTimestamp        `json:"timestamp"`
 		HeadersTruncated bool                `json:"headersTruncated"`
@@ -104,45 +114,38 @@ func (s *SES) ProcessSubscription(b []byte) error {
 	return nil
 }
 
-// ProcessBounce processes an SES bounce notification and returns a Bounce object.
-func (s *SES) ProcessBounce(b []byte) (models.Bounce, error) {
-	var (
-		bounce models.Bounce
-		n      sesNotif
-	)
+// ProcessBounce processes an SES notification and returns the bounce (Bounce and
+// Complaint types) or the delivery event (Delivery, Open and Click types) in it.
+// Other notification types are ignored.
+func (s *SES) ProcessBounce(b []byte) ([]models.Bounce, []models.DeliveryEvent, error) {
+	var n sesNotif
 	if err := json.Unmarshal(b, &n); err != nil {
-		return bounce, fmt.Errorf("error unmarshalling SES notification: %v", err)
+		return nil, nil, fmt.Errorf("error unmarshalling SES notification: %v", err)
 	}
 	if err := s.verifyNotif(n); err != nil {
-		return bounce, err
+		return nil, nil, err
 	}
 
 	var m sesMail
 	if err := json.Unmarshal([]byte(n.Message), &m); err != nil {
-		return bounce, fmt.Errorf("error unmarshalling SES notification: %v", err)
+		return nil, nil, fmt.Errorf("error unmarshalling SES notification: %v", err)
 	}
 
-	if (m.EventType != "" && m.EventType != "Bounce") ||
-		(m.NotifType != "" && (m.NotifType != "Bounce" && m.NotifType != "Complaint")) {
-		return bounce, errors.New("notification type is not bounce")
+	// Event publishing (configuration sets) uses eventType and
+	// identity notifications use notificationType.
+	notifType := m.EventType
+	if notifType == "" {
+		notifType = m.NotifType
 	}
 
-	if len(m.Mail.Destination) == 0 {
-		return bounce, errors.New("no destination e-mails found in SES notification")
+	switch notifType {
+	case "Bounce", "Complaint", "Delivery", "Open", "Click":
+	default:
+		return nil, nil, nil
 	}
 
-	typ := models.BounceTypeSoft
-	if m.Bounce.BounceType == "Permanent" {
-		typ = models.BounceTypeHard
-	}
-	if m.Bounce.BounceType == "Transient" && len(m.Bounce.BouncedRecipients) > 0 {
-		// "Invalid domain" bounce.
-		if m.Bounce.BouncedRecipients[0].Status == "5.4.4" {
-			typ = models.BounceTypeHard
-		}
-	}
-	if m.NotifType == "Complaint" {
-		typ = models.BounceTypeComplaint
+	if len(m.Mail.Destination) == 0 {
+		return nil, nil, errors.New("no destination e-mails found in SES notification")
 	}
 
 	// Look for the campaign ID in headers.
@@ -162,14 +165,66 @@ func (s *SES) ProcessBounce(b []byte) (models.Bounce, error) {
 		}
 	}
 
-	return models.Bounce{
-		Email:        strings.ToLower(m.Mail.Destination[0]),
+	var (
+		email  = strings.ToLower(m.Mail.Destination[0])
+		meta   = json.RawMessage(n.Message)
+		tstamp = time.Time(m.Mail.Timestamp)
+	)
+
+	// Delivery events.
+	if notifType == "Delivery" || notifType == "Open" || notifType == "Click" {
+		e := models.DeliveryEvent{
+			Email:        email,
+			CampaignUUID: campUUID,
+			Source:       "ses",
+			Meta:         meta,
+			CreatedAt:    tstamp,
+		}
+
+		switch notifType {
+		case "Delivery":
+			e.Type = models.DeliveryEventDelivered
+			if t := time.Time(m.Delivery.Timestamp); !t.IsZero() {
+				e.CreatedAt = t
+			}
+		case "Open":
+			e.Type = models.DeliveryEventOpen
+			if t := time.Time(m.Open.Timestamp); !t.IsZero() {
+				e.CreatedAt = t
+			}
+		case "Click":
+			e.Type = models.DeliveryEventClick
+			e.URL = m.Click.Link
+			if t := time.Time(m.Click.Timestamp); !t.IsZero() {
+				e.CreatedAt = t
+			}
+		}
+
+		return nil, []models.DeliveryEvent{e}, nil
+	}
+
+	typ := models.BounceTypeSoft
+	if m.Bounce.BounceType == "Permanent" {
+		typ = models.BounceTypeHard
+	}
+	if m.Bounce.BounceType == "Transient" && len(m.Bounce.BouncedRecipients) > 0 {
+		// "Invalid domain" bounce.
+		if m.Bounce.BouncedRecipients[0].Status == "5.4.4" {
+			typ = models.BounceTypeHard
+		}
+	}
+	if notifType == "Complaint" {
+		typ = models.BounceTypeComplaint
+	}
+
+	return []models.Bounce{{
+		Email:        email,
 		CampaignUUID: campUUID,
 		Type:         typ,
 		Source:       "ses",
-		Meta:         json.RawMessage(n.Message),
-		CreatedAt:    time.Time(m.Mail.Timestamp),
-	}, nil
+		Meta:         meta,
+		CreatedAt:    tstamp,
+	}}, nil, nil
 }
 
 func (s *SES) buildSignature(n sesNotif) []byte {
@@ -205,10 +260,14 @@ func (s *SES) verifyNotif(n sesNotif) error {
 
 	sign, err := base64.StdEncoding.DecodeString(n.Signature)
 	if err != nil {
-		return err
+		return &AuthError{fmt.Errorf("invalid signature encoding: %v", err)}
 	}
 
-	return cert.CheckSignature(x509.SHA1WithRSA, s.buildSignature(n), sign)
+	if err := cert.CheckSignature(x509.SHA1WithRSA, s.buildSignature(n), sign); err != nil {
+		return &AuthError{err}
+	}
+
+	return nil
 }
 
 // getCert takes the SNS certificate URL and fetches it and caches it for the first time,
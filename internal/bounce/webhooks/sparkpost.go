@@ -0,0 +1,143 @@
+package webhooks
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/knadh/listmonk/models"
+	"github.com/labstack/echo/v4"
+)
+
+type sparkpostEvent struct {
+	Type          string         `json:"type"`
+	BounceClass   string         `json:"bounce_class"`
+	RcptTo        string         `json:"rcpt_to"`
+	Timestamp     string         `json:"timestamp"`
+	RcptMeta      map[string]any `json:"rcpt_meta"`
+	TargetLinkURL string         `json:"target_link_url"`
+}
+
+type sparkpostNotif struct {
+	Msys struct {
+		MessageEvent *sparkpostEvent `json:"message_event"`
+		TrackEvent   *sparkpostEvent `json:"track_event"`
+	} `json:"msys"`
+}
+
+// SparkPost bounce classes that are permanent failures.
+// 10: invalid recipient, 25: admin failure, 30: generic bounce with no RCPT, 90: unsubscribe.
+// All other classes, including the block (5x) and undetermined (1) ones, are soft.
+var sparkpostHardClasses = map[string]bool{
+	"10": true,
+	"25": true,
+	"30": true,
+	"90": true,
+}
+
+// SparkPost handles SparkPost webhook notifications (bounce, spam complaint, delivery,
+// open and click events).
+// SparkPost doesn't sign webhooks. Requests are authenticated with HTTP basic auth.
+type SparkPost struct {
+	authHandler echo.HandlerFunc
+}
+
+// NewSparkPost returns a new SparkPost instance. The basic auth username and password are required.
+func NewSparkPost(username, password string) (*SparkPost, error) {
+	if username == "" || password == "" {
+		return nil, errNoCredentials
+	}
+
+	return &SparkPost{
+		authHandler: newBasicAuth(username, password),
+	}, nil
+}
+
+// ProcessBounce processes a batch of SparkPost events and returns zero or more bounces
+// and delivery events.
+func (s *SparkPost) ProcessBounce(b []byte, c echo.Context) ([]models.Bounce, []models.DeliveryEvent, error) {
+	// Do basicauth.
+	if err := s.authHandler(c); err != nil {
+		return nil, nil, err
+	}
+
+	// Each event's raw JSON is saved as the meta.
+	var events []json.RawMessage
+	if err := json.Unmarshal(b, &events); err != nil {
+		return nil, nil, fmt.Errorf("error unmarshalling SparkPost notification: %v", err)
+	}
+
+	var (
+		bounces = make([]models.Bounce, 0, len(events))
+		evs     = make([]models.DeliveryEvent, 0, len(events))
+	)
+	for _, ev := range events {
+		var n sparkpostNotif
+		if err := json.Unmarshal(ev, &n); err != nil {
+			return nil, nil, fmt.Errorf("error unmarshalling SparkPost notification: %v", err)
+		}
+
+		// Opens and clicks are track events. Everything else is a message event.
+		e := n.Msys.MessageEvent
+		if e == nil {
+			e = n.Msys.TrackEvent
+		}
+		if e == nil {
+			continue
+		}
+
+		tstamp := time.Now()
+		if ts, err := strconv.ParseInt(e.Timestamp, 10, 64); err == nil {
+			tstamp = time.Unix(ts, 0)
+		}
+
+		var (
+			email    = strings.ToLower(e.RcptTo)
+			campUUID = getString(e.RcptMeta, models.EmailHeaderCampaignUUID)
+			typ      string
+		)
+		switch e.Type {
+		case "bounce", "out_of_band":
+			typ = models.BounceTypeSoft
+			if sparkpostHardClasses[e.BounceClass] {
+				typ = models.BounceTypeHard
+			}
+		case "spam_complaint":
+			typ = models.BounceTypeComplaint
+		case "delivery", "open", "click":
+			d := models.DeliveryEvent{
+				Type:         models.DeliveryEventDelivered,
+				Email:        email,
+				CampaignUUID: campUUID,
+				Source:       "sparkpost",
+				Meta:         ev,
+				CreatedAt:    tstamp,
+			}
+			if e.Type == "open" {
+				d.Type = models.DeliveryEventOpen
+			} else if e.Type == "click" {
+				d.Type = models.DeliveryEventClick
+				d.URL = e.TargetLinkURL
+			}
+
+			evs = append(evs, d)
+			continue
+		default:
+			// Ignore other events.
+			continue
+		}
+
+		bounces = append(bounces, models.Bounce{
+			Email:        email,
+			CampaignUUID: campUUID,
+			Type:         typ,
+			Source:       "sparkpost",
+			Meta:         ev,
+			CreatedAt:    tstamp,
+		})
+	}
+
+	return bounces, evs, nil
+}
@@ -0,0 +1,13 @@
+package webhooks
+
+import "github.com/knadh/listmonk/models"
+
+// TagHeaders are the headers, with the campaign UUID as %s, that tag outgoing
+// campaign e-mails for providers that only echo custom variables back in their
+// webhook events and not the e-mail's own headers.
+var TagHeaders = map[string]map[string]string{
+	"mailgun":   {"X-Mailgun-Variables": `{"` + models.EmailHeaderCampaignUUID + `": "%s"}`},
+	"sparkpost": {"X-MSYS-API": `{"metadata": {"` + models.EmailHeaderCampaignUUID + `": "%s"}}`},
+	"brevo":     {"X-Mailin-custom": "%s"},
+	"mailjet":   {"X-MJ-CustomID": "%s"},
+}
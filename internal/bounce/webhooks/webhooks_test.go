@@ -0,0 +1,315 @@
+package webhooks
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/knadh/listmonk/models"
+	"github.com/labstack/echo/v4"
+)
+
+const testCampUUID = "0c4d2e6f-1a2b-4c3d-8e9f-0a1b2c3d4e5f"
+
+// newCtx returns an echo context for a webhook request with the given basic auth
+// credentials. If user is empty, no Authorization header is set.
+func newCtx(user, pass string) echo.Context {
+	req := httptest.NewRequest(http.MethodPost, "/webhooks/service/x", nil)
+	if user != "" {
+		req.SetBasicAuth(user, pass)
+	}
+	return echo.New().NewContext(req, httptest.NewRecorder())
+}
+
+// isAuthErr returns true if err is an AuthError.
+func isAuthErr(err error) bool {
+	var e *AuthError
+	return errors.As(err, &e)
+}
+
+func TestBasicAuth(t *testing.T) {
+	cases := []struct {
+		name       string
+		cfgUser    string
+		cfgPass    string
+		user, pass string
+		ok         bool
+	}{
+		{"valid", "u", "p", "u", "p", true},
+		{"wrong password", "u", "p", "u", "x", false},
+		{"wrong user", "u", "p", "x", "p", false},
+		{"no credentials sent", "u", "p", "", "", false},
+		{"none configured", "", "", "u", "p", false},
+		{"no password configured", "u", "", "u", "", false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := newBasicAuth(c.cfgUser, c.cfgPass)(newCtx(c.user, c.pass))
+			if c.ok && err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			if !c.ok && !isAuthErr(err) {
+				t.Fatalf("expected auth error, got %v", err)
+			}
+		})
+	}
+}
+
+func TestBasicAuthProviders(t *testing.T) {
+	if _, err := NewSparkPost("", ""); err == nil {
+		t.Error("expected SparkPost error without credentials")
+	}
+	if _, err := NewBrevo("u", ""); err == nil {
+		t.Error("expected Brevo error without a password")
+	}
+	if _, err := NewMailjet("", "p"); err == nil {
+		t.Error("expected Mailjet error without a username")
+	}
+
+	// Postmark allows unauthenticated requests if no credentials are set.
+	if _, _, err := NewPostmark("", "").ProcessBounce([]byte(`{"RecordType": "Open"}`), newCtx("", "")); err != nil {
+		t.Errorf("expected no Postmark error without credentials, got %v", err)
+	}
+	if _, _, err := NewPostmark("u", "p").ProcessBounce([]byte(`{}`), newCtx("u", "x")); !isAuthErr(err) {
+		t.Errorf("expected Postmark auth error, got %v", err)
+	}
+}
+
+// mailgunBody returns a signed Mailgun webhook notification.
+func mailgunBody(key, token string, ts time.Time, event string) []byte {
+	t := strconv.FormatInt(ts.Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write([]byte(t + token))
+
+	return []byte(fmt.Sprintf(`{
+		"signature": {"timestamp": %q, "token": %q, "signature": %q},
+		"event-data": {"event": %q, "severity": "permanent", "recipient": "User@Example.com",
+			"url": "https://example.com", "timestamp": 1700000000.5,
+			"user-variables": {"X-Listmonk-Campaign": %q}}
+	}`, t, token, hex.EncodeToString(mac.Sum(nil)), event, testCampUUID))
+}
+
+func TestMailgun(t *testing.T) {
+	if _, err := NewMailgun(nil, nil); err == nil {
+		t.Fatal("expected error without a signing key")
+	}
+
+	m, err := NewMailgun([]byte("key"), NewReplayGuard(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	cases := []struct {
+		name    string
+		body    []byte
+		typ     string
+		event   string
+		ok      bool
+		authErr bool
+	}{
+		{name: "hard bounce", body: mailgunBody("key", "t1", now, "failed"), typ: models.BounceTypeHard, ok: true},
+		{name: "complaint", body: mailgunBody("key", "t2", now, "complained"), typ: models.BounceTypeComplaint, ok: true},
+		{name: "open", body: mailgunBody("key", "t3", now, "opened"), event: models.DeliveryEventOpen, ok: true},
+		{name: "click", body: mailgunBody("key", "t4", now, "clicked"), event: models.DeliveryEventClick, ok: true},
+		{name: "ignored", body: mailgunBody("key", "t5", now, "accepted"), ok: true},
+		{name: "wrong key", body: mailgunBody("other", "t6", now, "failed"), authErr: true},
+		{name: "replayed token", body: mailgunBody("key", "t1", now, "failed"), authErr: true},
+		{name: "old timestamp", body: mailgunBody("key", "t7", now.Add(-time.Hour), "failed"), authErr: true},
+		{name: "bad JSON", body: []byte("{")},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			bs, evs, err := m.ProcessBounce(c.body)
+			if (err == nil) != c.ok {
+				t.Fatalf("expected ok=%v, got %v", c.ok, err)
+			}
+			if isAuthErr(err) != c.authErr {
+				t.Fatalf("expected auth error=%v, got %v", c.authErr, err)
+			}
+			if !c.ok {
+				return
+			}
+
+			switch {
+			case c.typ != "":
+				if len(bs) != 1 || len(evs) != 0 || bs[0].Type != c.typ {
+					t.Fatalf("expected one %s bounce, got %+v %+v", c.typ, bs, evs)
+				}
+				if bs[0].Email != "user@example.com" || bs[0].CampaignUUID != testCampUUID || bs[0].CreatedAt.Unix() != 1700000000 {
+					t.Errorf("unexpected bounce: %+v", bs[0])
+				}
+			case c.event != "":
+				if len(bs) != 0 || len(evs) != 1 || evs[0].Type != c.event || evs[0].CampaignUUID != testCampUUID {
+					t.Fatalf("expected one %s event, got %+v %+v", c.event, bs, evs)
+				}
+			default:
+				if len(bs) != 0 || len(evs) != 0 {
+					t.Fatalf("expected nothing, got %+v %+v", bs, evs)
+				}
+			}
+		})
+	}
+}
+
+func TestSparkPost(t *testing.T) {
+	s, err := NewSparkPost("u", "p")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body := []byte(`[
+		{"msys": {"message_event": {"type": "bounce", "bounce_class": "10", "rcpt_to": "A@example.com",
+			"timestamp": "1700000000", "rcpt_meta": {"X-Listmonk-Campaign": "` + testCampUUID + `"}}}},
+		{"msys": {"message_event": {"type": "bounce", "bounce_class": "21", "rcpt_to": "b@example.com"}}},
+		{"msys": {"message_event": {"type": "spam_complaint", "rcpt_to": "c@example.com"}}},
+		{"msys": {"track_event": {"type": "click", "rcpt_to": "d@example.com", "target_link_url": "https://example.com",
+			"rcpt_meta": {"X-Listmonk-Campaign": "` + testCampUUID + `"}}}},
+		{"msys": {"message_event": {"type": "injection", "rcpt_to": "e@example.com"}}},
+		{"msys": {}}
+	]`)
+
+	if _, _, err := s.ProcessBounce(body, newCtx("u", "x")); !isAuthErr(err) {
+		t.Fatalf("expected auth error, got %v", err)
+	}
+
+	bs, evs, err := s.ProcessBounce(body, newCtx("u", "p"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{models.BounceTypeHard, models.BounceTypeSoft, models.BounceTypeComplaint}
+	if len(bs) != len(want) {
+		t.Fatalf("expected %d bounces, got %d", len(want), len(bs))
+	}
+	for i, typ := range want {
+		if bs[i].Type != typ || bs[i].Source != "sparkpost" {
+			t.Errorf("bounce %d: expected %s, got %+v", i, typ, bs[i])
+		}
+	}
+	if bs[0].Email != "a@example.com" || bs[0].CampaignUUID != testCampUUID || bs[0].CreatedAt.Unix() != 1700000000 {
+		t.Errorf("unexpected bounce: %+v", bs[0])
+	}
+	if len(evs) != 1 || evs[0].Type != models.DeliveryEventClick || evs[0].URL != "https://example.com" || evs[0].CampaignUUID != testCampUUID {
+		t.Errorf("unexpected events: %+v", evs)
+	}
+
+	if _, _, err := s.ProcessBounce([]byte(`{}`), newCtx("u", "p")); err == nil {
+		t.Error("expected error for a non-array body")
+	}
+}
+
+func TestBrevo(t *testing.T) {
+	b, err := NewBrevo("u", "p")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		body     string
+		typ      string
+		event    string
+		campUUID string
+	}{
+		{`{"event": "hard_bounce", "email": "A@example.com", "X-Mailin-custom": "` + testCampUUID + `"}`, models.BounceTypeHard, "", testCampUUID},
+		{`{"event": "blocked", "email": "a@example.com", "X-Mailin-custom": "not-a-uuid"}`, models.BounceTypeSoft, "", ""},
+		{`{"event": "spam", "email": "a@example.com"}`, models.BounceTypeComplaint, "", ""},
+		{`{"event": "opened", "email": "a@example.com", "X-Mailin-custom": "` + testCampUUID + `"}`, "", models.DeliveryEventOpen, testCampUUID},
+		{`{"event": "unique_opened", "email": "a@example.com"}`, "", "", ""},
+	}
+
+	for _, c := range cases {
+		bs, evs, err := b.ProcessBounce([]byte(c.body), newCtx("u", "p"))
+		if err != nil {
+			t.Fatalf("%s: %v", c.body, err)
+		}
+
+		switch {
+		case c.typ != "":
+			if len(bs) != 1 || bs[0].Type != c.typ || bs[0].Email != "a@example.com" || bs[0].CampaignUUID != c.campUUID {
+				t.Errorf("%s: unexpected bounces: %+v", c.body, bs)
+			}
+		case c.event != "":
+			if len(evs) != 1 || evs[0].Type != c.event || evs[0].CampaignUUID != c.campUUID {
+				t.Errorf("%s: unexpected events: %+v", c.body, evs)
+			}
+		default:
+			if len(bs) != 0 || len(evs) != 0 {
+				t.Errorf("%s: expected nothing, got %+v %+v", c.body, bs, evs)
+			}
+		}
+	}
+}
+
+func TestMailjet(t *testing.T) {
+	m, err := NewMailjet("u", "p")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Mailjet sends single events or batches.
+	for _, body := range []string{
+		`{"event": "bounce", "email": "A@example.com", "hard_bounce": true, "time": 1700000000, "CustomID": "` + testCampUUID + `"}`,
+		`[{"event": "bounce", "email": "a@example.com", "hard_bounce": true, "time": 1700000000, "CustomID": "` + testCampUUID + `"},
+		  {"event": "open", "email": "a@example.com"}, {"event": "unsub", "email": "a@example.com"}]`,
+	} {
+		bs, evs, err := m.ProcessBounce([]byte(body), newCtx("u", "p"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(bs) != 1 || bs[0].Type != models.BounceTypeHard || bs[0].Email != "a@example.com" ||
+			bs[0].CampaignUUID != testCampUUID || bs[0].CreatedAt.Unix() != 1700000000 {
+			t.Errorf("unexpected bounces: %+v", bs)
+		}
+		if strings.HasPrefix(body, "[") && (len(evs) != 1 || evs[0].Type != models.DeliveryEventOpen) {
+			t.Errorf("unexpected events: %+v", evs)
+		}
+	}
+}
+
+// TestTagHeaders checks that the headers set on outgoing e-mails are in the form
+// that the providers echo back.
+func TestTagHeaders(t *testing.T) {
+	for name, hdrs := range TagHeaders {
+		for hdr, v := range hdrs {
+			v = fmt.Sprintf(v, testCampUUID)
+
+			var got string
+			switch hdr {
+			case "X-Mailgun-Variables":
+				var vars map[string]any
+				if err := json.Unmarshal([]byte(v), &vars); err != nil {
+					t.Fatalf("%s: %v", name, err)
+				}
+				got = getString(vars, models.EmailHeaderCampaignUUID)
+			case "X-MSYS-API":
+				var api struct {
+					Metadata map[string]any `json:"metadata"`
+				}
+				if err := json.Unmarshal([]byte(v), &api); err != nil {
+					t.Fatalf("%s: %v", name, err)
+				}
+				got = getString(api.Metadata, models.EmailHeaderCampaignUUID)
+			default:
+				if reCampUUID.MatchString(v) {
+					got = v
+				}
+			}
+
+			if got != testCampUUID {
+				t.Errorf("%s: %s doesn't carry the campaign UUID: %q", name, hdr, v)
+			}
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package core
+
+import (
+	"net/http"
+
+	"github.com/knadh/listmonk/models"
+	"github.com/labstack/echo/v4"
+	"github.com/lib/pq"
+)
+
+// GetSubscriberActivity retrieves the paginated activity timeline of a subscriber,
+// optionally filtered by activity types, and the total number of entries.
+func (c *Core) GetSubscriberActivity(subID int, types []string, order string, offset, limit int) ([]models.SubscriberActivity, int, error) {
+	if types == nil {
+		types = []string{}
+	}
+
+	out := []models.SubscriberActivity{}
+	if err := c.q.GetSubscriberActivity.Select(&out, subID, pq.StringArray(types), offset, limit, order); err != nil {
+		c.log.Printf("error fetching subscriber activity: %v", err)
+		return nil, 0, echo.NewHTTPError(http.StatusInternalServerError,
+			c.i18n.Ts("globals.messages.errorFetching", "name", "{globals.terms.activity}", "error", pqErrMsg(err)))
+	}
+
+	total := 0
+	if len(out) > 0 {
+		total = out[0].Total
+	}
+
+	return out, total, nil
+}
+
+// QueryUnsubscriptions retrieves the paginated unsubscriptions made by subscribers,
+// optionally filtered by source, campaign and date range, and the total number of entries.
+func (c *Core) QueryUnsubscriptions(source string, campID int, fromDate, toDate string, offset, limit int) ([]models.Unsubscription, int, error) {
+	out := []models.Unsubscription{}
+	if err := c.q.QueryUnsubscriptions.Select(&out, source, campID, fromDate, toDate, offset, limit); err != nil {
+		c.log.Printf("error fetching unsubscriptions: %v", err)
+		return nil, 0, echo.NewHTTPError(http.StatusInternalServerError,
+			c.i18n.Ts("globals.messages.errorFetching", "name", "{globals.terms.activity}", "error", pqErrMsg(err)))
+	}
+
+	total := 0
+	if len(out) > 0 {
+		total = out[0].Total
+	}
+
+	return out, total, nil
+}
+
+// RecordSubscriberActivity records an entry in the activity timeline of the
+// given subscribers. userID is the user who made the change, if any.
+func (c *Core) RecordSubscriberActivity(subIDs []int, subUUIDs []string, typ string, data models.JSON, actor string, userID int) error {
+	if subIDs == nil {
+		subIDs = []int{}
+	}
+	if subUUIDs == nil {
+		subUUIDs = []string{}
+	}
+	if data == nil {
+		data = models.JSON{}
+	}
+
+	_, err := c.q.RecordSubscriberActivity.Exec(pq.Array(subIDs), pq.StringArray(subUUIDs), typ, data, actor, userID)
+	return err
+}
+
+// RecordSubscriberActivityByQuery records an entry in the activity timeline of
+// subscribers matching a given arbitrary query expression.
+func (c *Core) RecordSubscriberActivityByQuery(searchStr, queryExp string, listIDs []int, subStatus string,
+	typ string, data models.JSON, actor string, userID int) error {
+	if data == nil {
+		data = models.JSON{}
+	}
+
+	return c.q.ExecSubQueryTpl(searchStr, sanitizeSQLExp(queryExp), c.q.RecordSubscriberActivityByQuery,
+		listIDs, c.db, subStatus, typ, data, actor, userID)
+}
@@ -0,0 +1,142 @@
+package core
+
+import (
+	"net/http"
+
+	"github.com/knadh/listmonk/models"
+	"github.com/labstack/echo/v4"
+	"github.com/lib/pq"
+)
+
+// GetAttribSchema retrieves the subscriber attribute schema.
+func (c *Core) GetAttribSchema() (models.AttribSchema, error) {
+	out := models.AttribSchema{}
+	if err := c.q.GetAttribSchema.Select(&out, 0); err != nil {
+		c.log.Printf("error fetching attribute schema: %v", err)
+		return nil, echo.NewHTTPError(http.StatusInternalServerError,
+			c.i18n.Ts("globals.messages.errorFetching", "name", "{globals.terms.attribute}", "error", pqErrMsg(err)))
+	}
+
+	return out, nil
+}
+
+// GetAttribField retrieves an attribute in the subscriber attribute schema.
+func (c *Core) GetAttribField(id int) (models.AttribField, error) {
+	var out []models.AttribField
+	if err := c.q.GetAttribSchema.Select(&out, id); err != nil {
+		c.log.Printf("error fetching attribute schema: %v", err)
+		return models.AttribField{}, echo.NewHTTPError(http.StatusInternalServerError,
+			c.i18n.Ts("globals.messages.errorFetching", "name", "{globals.terms.attribute}", "error", pqErrMsg(err)))
+	}
+
+	if len(out) == 0 {
+		return models.AttribField{}, echo.NewHTTPError(http.StatusBadRequest,
+			c.i18n.Ts("globals.messages.notFound", "name", "{globals.terms.attribute}"))
+	}
+
+	return out[0], nil
+}
+
+// CreateAttribField adds an attribute to the subscriber attribute schema.
+func (c *Core) CreateAttribField(o models.AttribField) (models.AttribField, error) {
+	var newID int
+	if err := c.q.CreateAttribField.Get(&newID, o.Name, o.Type, o.Description, o.Enum, o.Required, o.Public); err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Constraint == "idx_attrib_schema_name" {
+			return models.AttribField{}, echo.NewHTTPError(http.StatusBadRequest,
+				c.i18n.Ts("attribs.duplicateName", "name", o.Name))
+		}
+
+		c.log.Printf("error creating attribute: %v", err)
+		return models.AttribField{}, echo.NewHTTPError(http.StatusInternalServerError,
+			c.i18n.Ts("globals.messages.errorCreating", "name", "{globals.terms.attribute}", "error", pqErrMsg(err)))
+	}
+	c.resetAttribSchema()
+
+	return c.GetAttribField(newID)
+}
+
+// UpdateAttribField updates an attribute in the subscriber attribute schema.
+func (c *Core) UpdateAttribField(id int, o models.AttribField) (models.AttribField, error) {
+	res, err := c.q.UpdateAttribField.Exec(id, o.Name, o.Type, o.Description, o.Enum, o.Required, o.Public)
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Constraint == "idx_attrib_schema_name" {
+			return models.AttribField{}, echo.NewHTTPError(http.StatusBadRequest,
+				c.i18n.Ts("attribs.duplicateName", "name", o.Name))
+		}
+
+		c.log.Printf("error updating attribute: %v", err)
+		return models.AttribField{}, echo.NewHTTPError(http.StatusInternalServerError,
+			c.i18n.Ts("globals.messages.errorUpdating", "name", "{globals.terms.attribute}", "error", pqErrMsg(err)))
+	}
+
+	if n, _ := res.RowsAffected(); n == 0 {
+		return models.AttribField{}, echo.NewHTTPError(http.StatusBadRequest,
+			c.i18n.Ts("globals.messages.notFound", "name", "{globals.terms.attribute}"))
+	}
+	c.resetAttribSchema()
+
+	return c.GetAttribField(id)
+}
+
+// DeleteAttribField removes an attribute from the subscriber attribute schema.
+// Existing values of the attribute in subscribers are retained.
+func (c *Core) DeleteAttribField(id int) error {
+	if _, err := c.q.DeleteAttribField.Exec(id); err != nil {
+		c.log.Printf("error deleting attribute: %v", err)
+		return echo.NewHTTPError(http.StatusInternalServerError,
+			c.i18n.Ts("globals.messages.errorDeleting", "name", "{globals.terms.attribute}", "error", pqErrMsg(err)))
+	}
+	c.resetAttribSchema()
+
+	return nil
+}
+
+// CachedAttribSchema returns the subscriber attribute schema, which is loaded
+// from the DB on first use and cached until it's modified.
+func (c *Core) CachedAttribSchema() (models.AttribSchema, error) {
+	c.attribSchemaLock.RLock()
+	if c.attribSchemaOK {
+		out := c.attribSchema
+		c.attribSchemaLock.RUnlock()
+		return out, nil
+	}
+	c.attribSchemaLock.RUnlock()
+
+	out, err := c.GetAttribSchema()
+	if err != nil {
+		return nil, err
+	}
+
+	c.attribSchemaLock.Lock()
+	c.attribSchema = out
+	c.attribSchemaOK = true
+	c.attribSchemaLock.Unlock()
+
+	return out, nil
+}
+
+// ValidateAttribs validates subscriber attributes against the attribute schema
+// and returns them with their keys normalized to the schema's names. If partial
+// is true, required attributes are not checked.
+func (c *Core) ValidateAttribs(attribs models.JSON, partial bool) (models.JSON, error) {
+	sc, err := c.CachedAttribSchema()
+	if err != nil {
+		return nil, err
+	}
+
+	out, err := sc.Validate(attribs, partial)
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusBadRequest,
+			c.i18n.Ts("subscribers.invalidAttribs", "error", err.Error()))
+	}
+
+	return out, nil
+}
+
+// resetAttribSchema clears the cached attribute schema so that it's
+// reloaded on next use.
+func (c *Core) resetAttribSchema() {
+	c.attribSchemaLock.Lock()
+	c.attribSchemaOK = false
+	c.attribSchemaLock.Unlock()
+}
@@ -0,0 +1,155 @@
+package core
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+
+	"github.com/knadh/listmonk/models"
+	"github.com/labstack/echo/v4"
+)
+
+// InsertSubscriberEvents records events against subscribers identified by
+// ID or e-mail and queues the automations that match them. The events are
+// recorded in a single transaction and if any of the subscribers doesn't
+// exist, none are.
+func (c *Core) InsertSubscriberEvents(evs []models.SubscriberEventReq) ([]int64, error) {
+	tx, err := c.db.Beginx()
+	if err != nil {
+		c.log.Printf("error recording subscriber events: %v", err)
+		return nil, echo.NewHTTPError(http.StatusInternalServerError,
+			c.i18n.Ts("globals.messages.errorCreating", "name", "{globals.terms.event}", "error", pqErrMsg(err)))
+	}
+	defer tx.Rollback()
+
+	stmt := tx.Stmtx(c.q.InsertSubscriberEvent)
+	out := make([]int64, 0, len(evs))
+	for _, e := range evs {
+		data := []byte(e.Data)
+		if len(data) == 0 {
+			data = []byte("{}")
+		}
+
+		var id int64
+		if err := stmt.Get(&id, e.SubscriberID, e.SubscriberEmail, e.Event, data); err != nil {
+			if err == sql.ErrNoRows {
+				return nil, echo.NewHTTPError(http.StatusBadRequest,
+					c.i18n.Ts("globals.messages.notFound", "name",
+						fmt.Sprintf("{globals.terms.subscriber} (%d: %s)", e.SubscriberID, e.SubscriberEmail)))
+			}
+
+			c.log.Printf("error recording subscriber event: %v", err)
+			return nil, echo.NewHTTPError(http.StatusInternalServerError,
+				c.i18n.Ts("globals.messages.errorCreating", "name", "{globals.terms.event}", "error", pqErrMsg(err)))
+		}
+		out = append(out, id)
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.log.Printf("error recording subscriber events: %v", err)
+		return nil, echo.NewHTTPError(http.StatusInternalServerError,
+			c.i18n.Ts("globals.messages.errorCreating", "name", "{globals.terms.event}", "error", pqErrMsg(err)))
+	}
+
+	return out, nil
+}
+
+// GetSubscriberEvents retrieves a subscriber's events, optionally filtered by name.
+func (c *Core) GetSubscriberEvents(subID int, name string, offset, limit int) ([]models.SubscriberEvent, error) {
+	out := []models.SubscriberEvent{}
+	if err := c.q.GetSubscriberEvents.Select(&out, subID, name, offset, limit); err != nil {
+		c.log.Printf("error fetching subscriber events: %v", err)
+		return nil, echo.NewHTTPError(http.StatusInternalServerError,
+			c.i18n.Ts("globals.messages.errorFetching", "name", "{globals.terms.event}", "error", pqErrMsg(err)))
+	}
+
+	return out, nil
+}
+
+// GetAutomations retrieves all automations.
+func (c *Core) GetAutomations() ([]models.Automation, error) {
+	out := []models.Automation{}
+	if err := c.q.GetAutomations.Select(&out, 0); err != nil {
+		c.log.Printf("error fetching automations: %v", err)
+		return nil, echo.NewHTTPError(http.StatusInternalServerError,
+			c.i18n.Ts("globals.messages.errorFetching", "name", "{globals.terms.automation}", "error", pqErrMsg(err)))
+	}
+
+	return out, nil
+}
+
+// GetAutomation retrieves an automation.
+func (c *Core) GetAutomation(id int) (models.Automation, error) {
+	var out []models.Automation
+	if err := c.q.GetAutomations.Select(&out, id); err != nil {
+		c.log.Printf("error fetching automations: %v", err)
+		return models.Automation{}, echo.NewHTTPError(http.StatusInternalServerError,
+			c.i18n.Ts("globals.messages.errorFetching", "name", "{globals.terms.automation}", "error", pqErrMsg(err)))
+	}
+
+	if len(out) == 0 {
+		return models.Automation{}, echo.NewHTTPError(http.StatusBadRequest,
+			c.i18n.Ts("globals.messages.notFound", "name", "{globals.terms.automation}"))
+	}
+
+	return out[0], nil
+}
+
+// CreateAutomation creates a new automation.
+func (c *Core) CreateAutomation(o models.Automation) (models.Automation, error) {
+	var newID int
+	if err := c.q.CreateAutomation.Get(&newID, o.Name, o.Event, o.Filter, o.Delay, o.TemplateID,
+		o.Subject, o.FromEmail, o.Messenger, o.Enabled); err != nil {
+		c.log.Printf("error creating automation: %v", err)
+		return models.Automation{}, echo.NewHTTPError(http.StatusInternalServerError,
+			c.i18n.Ts("globals.messages.errorCreating", "name", "{globals.terms.automation}", "error", pqErrMsg(err)))
+	}
+
+	return c.GetAutomation(newID)
+}
+
+// UpdateAutomation updates an automation.
+func (c *Core) UpdateAutomation(id int, o models.Automation) (models.Automation, error) {
+	res, err := c.q.UpdateAutomation.Exec(id, o.Name, o.Event, o.Filter, o.Delay, o.TemplateID,
+		o.Subject, o.FromEmail, o.Messenger, o.Enabled)
+	if err != nil {
+		c.log.Printf("error updating automation: %v", err)
+		return models.Automation{}, echo.NewHTTPError(http.StatusInternalServerError,
+			c.i18n.Ts("globals.messages.errorUpdating", "name", "{globals.terms.automation}", "error", pqErrMsg(err)))
+	}
+
+	if n, _ := res.RowsAffected(); n == 0 {
+		return models.Automation{}, echo.NewHTTPError(http.StatusBadRequest,
+			c.i18n.Ts("globals.messages.notFound", "name", "{globals.terms.automation}"))
+	}
+
+	return c.GetAutomation(id)
+}
+
+// DeleteAutomation deletes an automation and its queued messages.
+func (c *Core) DeleteAutomation(id int) error {
+	if _, err := c.q.DeleteAutomation.Exec(id); err != nil {
+		c.log.Printf("error deleting automation: %v", err)
+		return echo.NewHTTPError(http.StatusInternalServerError,
+			c.i18n.Ts("globals.messages.errorDeleting", "name", "{globals.terms.automation}", "error", pqErrMsg(err)))
+	}
+
+	return nil
+}
+
+// NextAutomationJobs returns up to limit queued automation messages that are due.
+func (c *Core) NextAutomationJobs(limit int) ([]models.AutomationJob, error) {
+	out := []models.AutomationJob{}
+	if err := c.q.NextAutomationJobs.Select(&out, limit); err != nil {
+		return nil, err
+	}
+
+	return out, nil
+}
+
+// DeleteAutomationJob deletes a processed automation message. sent indicates
+// whether the message was actually sent or skipped.
+func (c *Core) DeleteAutomationJob(id int64, sent bool) error {
+	_, err := c.q.DeleteAutomationJob.Exec(id, sent)
+	return err
+}
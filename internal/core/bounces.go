@@ -1,9 +1,14 @@
 package core
 
 import (
+	"encoding/json"
+	"math"
 	"net/http"
 	"strings"
+	"time"
 
+	"github.com/gofrs/uuid/v5"
+	"github.com/jmoiron/sqlx"
 	"github.com/knadh/listmonk/models"
 	"github.com/labstack/echo/v4"
 	"github.com/lib/pq"
@@ -58,6 +63,18 @@ func (c *Core) GetBounce(id int) (models.Bounce, error) {
 
 // RecordBounce records a new bounce.
 func (c *Core) RecordBounce(b models.Bounce) error {
+	// Escalate a soft bounce to a hard bounce if the subscriber has soft bounced
+	// on each of the last N campaigns sent to them.
+	if b.Type == models.BounceTypeSoft && b.CampaignUUID != "" && c.consts.SoftBounceEscalation > 0 {
+		var escalate bool
+		if err := c.q.GetSoftBounceStreak.Get(&escalate, b.SubscriberUUID, b.Email, b.CampaignUUID, c.consts.SoftBounceEscalation); err != nil {
+			c.log.Printf("error checking soft bounces: %v", err)
+		} else if escalate {
+			c.log.Printf("escalating soft bounce (%s / %s) to hard after %d campaigns", b.SubscriberUUID, b.Email, c.consts.SoftBounceEscalation)
+			b.Type = models.BounceTypeHard
+		}
+	}
+
 	action, ok := c.consts.BounceActions[b.Type]
 	if !ok {
 		return echo.NewHTTPError(http.StatusBadRequest, c.i18n.Ts("globals.messages.invalidData")+": "+b.Type)
@@ -71,7 +88,8 @@ func (c *Core) RecordBounce(b models.Bounce) error {
 		b.Meta,
 		b.CreatedAt,
 		action.Count,
-		action.Action)
+		action.Action,
+		action.WindowDays)
 
 	if err != nil {
 		// Ignore the error if it complained of no subscriber.
@@ -86,6 +104,62 @@ func (c *Core) RecordBounce(b models.Bounce) error {
 	return err
 }
 
+// RecordDeliveryEvent records a delivery, open or click event reported by a bounce webhook.
+// Opens and clicks are merged into the campaign's views and link clicks.
+func (c *Core) RecordDeliveryEvent(e models.DeliveryEvent) error {
+	var err error
+	switch e.Type {
+	case models.DeliveryEventDelivered:
+		if len(e.Meta) == 0 {
+			e.Meta = json.RawMessage("{}")
+		}
+
+		_, err = c.q.RecordDelivery.Exec(e.SubscriberUUID, e.Email, e.CampaignUUID, e.Source, e.Meta, e.CreatedAt)
+
+	case models.DeliveryEventOpen:
+		// Opens and clicks can only be attributed to campaigns. Without individual tracking,
+		// the provider's opens can't be told apart from the tracking pixel's anonymous views
+		// and are skipped.
+		if e.CampaignUUID == "" || !c.consts.IndividualTracking {
+			return nil
+		}
+
+		_, err = c.q.RecordWebhookView.Exec(e.SubscriberUUID, e.Email, e.CampaignUUID, e.CreatedAt)
+
+	case models.DeliveryEventClick:
+		if e.CampaignUUID == "" || e.URL == "" {
+			return nil
+		}
+
+		// Clicks on listmonk's own tracked links are already recorded by the link redirect.
+		if strings.HasPrefix(e.URL, c.consts.RootURL+"/link/") {
+			return nil
+		}
+
+		uu, uErr := uuid.NewV4()
+		if uErr != nil {
+			return uErr
+		}
+
+		_, err = c.q.RecordWebhookClick.Exec(e.SubscriberUUID, e.Email, e.CampaignUUID, c.consts.IndividualTracking, e.CreatedAt, e.URL, uu)
+
+	default:
+		return echo.NewHTTPError(http.StatusBadRequest, c.i18n.Ts("globals.messages.invalidData")+": "+e.Type)
+	}
+
+	if err != nil {
+		// Ignore the error if it complained of no subscriber.
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Column == "subscriber_id" {
+			c.log.Printf("delivered subscriber (%s / %s) not found", e.SubscriberUUID, e.Email)
+			return nil
+		}
+
+		c.log.Printf("error recording %s event: %v", e.Type, err)
+	}
+
+	return err
+}
+
 // BlocklistBouncedSubscribers blocklists all bounced subscribers.
 func (c *Core) BlocklistBouncedSubscribers() error {
 	if _, err := c.q.BlocklistBouncedSubscribers.Exec(); err != nil {
@@ -110,3 +184,70 @@ func (c *Core) DeleteBounces(ids []int, all bool) error {
 	}
 	return nil
 }
+
+// DecayBounces deletes soft bounce records that are older than the given timestamp
+// and returns the number of records deleted.
+func (c *Core) DecayBounces(before time.Time) (int64, error) {
+	res, err := c.q.DecayBounces.Exec(before)
+	if err != nil {
+		c.log.Printf("error deleting old bounces: %v", err)
+		return 0, echo.NewHTTPError(http.StatusInternalServerError,
+			c.i18n.Ts("globals.messages.errorDeleting", "name", "{globals.terms.bounces}", "error", pqErrMsg(err)))
+	}
+
+	n, _ := res.RowsAffected()
+	return n, nil
+}
+
+// GetBounceAnalytics returns bounces aggregated by recipient domain, campaign
+// or messenger in the given date range.
+func (c *Core) GetBounceAnalytics(by, fromDate, toDate string, limit int) ([]models.BounceStat, error) {
+	var stmt *sqlx.Stmt
+	switch by {
+	case "domain":
+		stmt = c.q.GetBounceAnalyticsDomains
+	case "campaign":
+		stmt = c.q.GetBounceAnalyticsCampaigns
+	case "messenger":
+		stmt = c.q.GetBounceAnalyticsMessengers
+	default:
+		return nil, echo.NewHTTPError(http.StatusBadRequest, c.i18n.Ts("globals.messages.invalidFields", "name", "by"))
+	}
+
+	if !strHasLen(fromDate, 10, 30) || !strHasLen(toDate, 10, 30) {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, c.i18n.T("analytics.invalidDates"))
+	}
+
+	out := []models.BounceStat{}
+	if err := stmt.Select(&out, fromDate, toDate, limit); err != nil {
+		c.log.Printf("error fetching bounce analytics: %v", err)
+		return nil, echo.NewHTTPError(http.StatusInternalServerError,
+			c.i18n.Ts("globals.messages.errorFetching", "name", "{globals.terms.analytics}", "error", pqErrMsg(err)))
+	}
+
+	for i, s := range out {
+		if s.Total > 0 {
+			out[i].Rate = math.Round(float64(s.Subscribers)/float64(s.Total)*10000) / 100
+		}
+	}
+
+	return out, nil
+}
+
+// GetSuppressedSubscribers returns the IDs of the given subscribers that are blocklisted,
+// or have hard bounced or complained, and should not be sent tx messages.
+func (c *Core) GetSuppressedSubscribers(ids []int) (map[int]bool, error) {
+	var res []int
+	if err := c.q.GetSuppressedSubscribers.Select(&res, pq.Array(ids)); err != nil {
+		c.log.Printf("error fetching suppressed subscribers: %v", err)
+		return nil, echo.NewHTTPError(http.StatusInternalServerError,
+			c.i18n.Ts("globals.messages.errorFetching", "name", "{globals.terms.subscribers}", "error", pqErrMsg(err)))
+	}
+
+	out := make(map[int]bool, len(res))
+	for _, id := range res {
+		out[id] = true
+	}
+
+	return out, nil
+}
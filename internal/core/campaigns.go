@@ -252,6 +252,13 @@ func (c *Core) UpdateCampaignStatus(id int, status string) (models.Campaign, err
 	}
 
 	errMsg := ""
+
+	// Digest campaigns are never sent directly. They spawn regular campaigns
+	// whenever their feed has new items.
+	if cm.Type == models.CampaignTypeDigest && (status == models.CampaignStatusRunning || status == models.CampaignStatusScheduled) {
+		return models.Campaign{}, echo.NewHTTPError(http.StatusBadRequest, c.i18n.T("campaigns.digestCantStart"))
+	}
+
 	switch status {
 	case models.CampaignStatusDraft:
 		if cm.Status != models.CampaignStatusScheduled {
@@ -11,6 +11,7 @@ import (
 	"net/http"
 	"regexp"
 	"strings"
+	"sync"
 
 	"github.com/jmoiron/sqlx"
 	"github.com/knadh/listmonk/internal/i18n"
@@ -37,16 +38,25 @@ type Core struct {
 	db     *sqlx.DB
 	q      *models.Queries
 	log    *log.Logger
+
+	// Cached subscriber attribute schema. Loaded on first use.
+	attribSchema     models.AttribSchema
+	attribSchemaOK   bool
+	attribSchemaLock sync.RWMutex
 }
 
 // Constants represents constant config.
 type Constants struct {
 	SendOptinConfirmation bool
 	BounceActions         map[string]struct {
-		Count  int
-		Action string
+		Count      int
+		Action     string
+		WindowDays int `koanf:"window_days"`
 	}
-	CacheSlowQueries bool
+	SoftBounceEscalation int
+	CacheSlowQueries     bool
+	IndividualTracking   bool
+	RootURL              string
 }
 
 // Hooks contains external function hooks that are required by the core package.
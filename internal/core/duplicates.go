@@ -0,0 +1,192 @@
+package core
+
+import (
+	"database/sql"
+	"encoding/json"
+	"hash/maphash"
+	"net/http"
+	"sort"
+
+	"github.com/jmoiron/sqlx/types"
+	"github.com/knadh/listmonk/models"
+	"github.com/labstack/echo/v4"
+	"github.com/lib/pq"
+)
+
+// FindDuplicateSubscribers retrieves paginated groups of subscribers whose
+// e-mails are the same after normalization by the given rules, and the total
+// number of groups.
+func (c *Core) FindDuplicateSubscribers(r models.DuplicateRules, offset, limit int) ([]models.DuplicateGroup, int, error) {
+	// The normalized e-mails are counted by their hashes first so that only
+	// the e-mails of likely duplicates are held in memory.
+	var (
+		seed   = maphash.MakeSeed()
+		counts = map[uint64]int{}
+	)
+	if err := c.scanSubscriberEmails(func(id int, email string) {
+		counts[maphash.String(seed, r.Key(email))]++
+	}); err != nil {
+		c.log.Printf("error finding duplicate subscribers: %v", err)
+		return nil, 0, echo.NewHTTPError(http.StatusInternalServerError,
+			c.i18n.Ts("globals.messages.errorFetching", "name", "{globals.terms.subscribers}", "error", pqErrMsg(err)))
+	}
+
+	groups := map[string][]int{}
+	if err := c.scanSubscriberEmails(func(id int, email string) {
+		if k := r.Key(email); counts[maphash.String(seed, k)] > 1 {
+			groups[k] = append(groups[k], id)
+		}
+	}); err != nil {
+		c.log.Printf("error finding duplicate subscribers: %v", err)
+		return nil, 0, echo.NewHTTPError(http.StatusInternalServerError,
+			c.i18n.Ts("globals.messages.errorFetching", "name", "{globals.terms.subscribers}", "error", pqErrMsg(err)))
+	}
+
+	keys := make([]string, 0, len(groups))
+	for k, ids := range groups {
+		if len(ids) > 1 {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	total := len(keys)
+	keys = keys[min(offset, total):]
+	if limit > 0 && limit < len(keys) {
+		keys = keys[:limit]
+	}
+
+	// Get the subscribers in the groups on the page.
+	var ids []int
+	for _, k := range keys {
+		ids = append(ids, groups[k]...)
+	}
+
+	var subs []struct {
+		ID   int             `db:"id"`
+		Data json.RawMessage `db:"data"`
+	}
+	if err := c.q.GetDuplicateSubscribers.Select(&subs, pq.Array(ids)); err != nil {
+		c.log.Printf("error finding duplicate subscribers: %v", err)
+		return nil, 0, echo.NewHTTPError(http.StatusInternalServerError,
+			c.i18n.Ts("globals.messages.errorFetching", "name", "{globals.terms.subscribers}", "error", pqErrMsg(err)))
+	}
+	data := make(map[int]json.RawMessage, len(subs))
+	for _, s := range subs {
+		data[s.ID] = s.Data
+	}
+
+	out := make([]models.DuplicateGroup, 0, len(keys))
+	for _, k := range keys {
+		g := make([]json.RawMessage, 0, len(groups[k]))
+		for _, id := range groups[k] {
+			if d, ok := data[id]; ok {
+				g = append(g, d)
+			}
+		}
+
+		b, err := json.Marshal(g)
+		if err != nil {
+			return nil, 0, echo.NewHTTPError(http.StatusInternalServerError,
+				c.i18n.Ts("globals.messages.errorFetching", "name", "{globals.terms.subscribers}", "error", err.Error()))
+		}
+		out = append(out, models.DuplicateGroup{Key: k, Subscribers: b})
+	}
+
+	return out, total, nil
+}
+
+// scanSubscriberEmails calls fn with the ID and e-mail of every subscriber,
+// in the order of their IDs.
+func (c *Core) scanSubscriberEmails(fn func(id int, email string)) error {
+	rows, err := c.q.GetSubscriberEmails.Query()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			id    int
+			email string
+		)
+		if err := rows.Scan(&id, &email); err != nil {
+			return err
+		}
+		fn(id, email)
+	}
+
+	return rows.Err()
+}
+
+// MergeSubscribers merges the attributes, subscriptions, views, clicks, bounces,
+// events, activity, sequences and automation jobs of the given subscribers into
+// a subscriber, deletes them, and records the merge. userID is the user who merged the subscribers.
+func (c *Core) MergeSubscribers(id int, subIDs []int, userID int) (models.Subscriber, error) {
+	tx, err := c.db.Beginx()
+	if err != nil {
+		c.log.Printf("error merging subscribers: %v", err)
+		return models.Subscriber{}, echo.NewHTTPError(http.StatusInternalServerError,
+			c.i18n.Ts("subscribers.errorMerging", "error", pqErrMsg(err)))
+	}
+	defer tx.Rollback()
+
+	// Merge the data and get a snapshot of the merged subscribers.
+	var merged types.JSONText
+	if err := tx.Stmtx(c.q.MergeSubscribers).Get(&merged, id, pq.Array(subIDs)); err != nil {
+		if err == sql.ErrNoRows {
+			return models.Subscriber{}, echo.NewHTTPError(http.StatusBadRequest,
+				c.i18n.Ts("globals.messages.notFound", "name", "{globals.terms.subscriber}"))
+		}
+
+		c.log.Printf("error merging subscribers: %v", err)
+		return models.Subscriber{}, echo.NewHTTPError(http.StatusInternalServerError,
+			c.i18n.Ts("subscribers.errorMerging", "error", pqErrMsg(err)))
+	}
+
+	// Ensure that all the subscribers to merge exist.
+	var subs []any
+	if err := merged.Unmarshal(&subs); err != nil || len(subs) != len(subIDs) {
+		return models.Subscriber{}, echo.NewHTTPError(http.StatusBadRequest,
+			c.i18n.Ts("globals.messages.notFound", "name", "{globals.terms.subscribers}"))
+	}
+
+	if _, err := tx.Stmtx(c.q.DeleteSubscribers).Exec(pq.Array(subIDs), pq.StringArray{}); err != nil {
+		c.log.Printf("error deleting merged subscribers: %v", err)
+		return models.Subscriber{}, echo.NewHTTPError(http.StatusInternalServerError,
+			c.i18n.Ts("subscribers.errorMerging", "error", pqErrMsg(err)))
+	}
+
+	var mergeID int
+	if err := tx.Stmtx(c.q.InsertSubscriberMerge).Get(&mergeID, id, merged, userID); err != nil {
+		c.log.Printf("error recording subscriber merge: %v", err)
+		return models.Subscriber{}, echo.NewHTTPError(http.StatusInternalServerError,
+			c.i18n.Ts("subscribers.errorMerging", "error", pqErrMsg(err)))
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.log.Printf("error merging subscribers: %v", err)
+		return models.Subscriber{}, echo.NewHTTPError(http.StatusInternalServerError,
+			c.i18n.Ts("subscribers.errorMerging", "error", pqErrMsg(err)))
+	}
+
+	return c.GetSubscriber(id, "", "")
+}
+
+// QuerySubscriberMerges retrieves the paginated merge records of a subscriber,
+// or all of them if subID is 0, and the total number of records.
+func (c *Core) QuerySubscriberMerges(subID, offset, limit int) ([]models.SubscriberMerge, int, error) {
+	out := []models.SubscriberMerge{}
+	if err := c.q.QuerySubscriberMerges.Select(&out, subID, offset, limit); err != nil {
+		c.log.Printf("error fetching subscriber merges: %v", err)
+		return nil, 0, echo.NewHTTPError(http.StatusInternalServerError,
+			c.i18n.Ts("globals.messages.errorFetching", "name", "{globals.terms.subscribers}", "error", pqErrMsg(err)))
+	}
+
+	total := 0
+	if len(out) > 0 {
+		total = out[0].Total
+	}
+
+	return out, total, nil
+}
@@ -0,0 +1,100 @@
+package core
+
+import (
+	"database/sql"
+	"net/http"
+	"time"
+
+	"github.com/gofrs/uuid/v5"
+	"github.com/knadh/listmonk/models"
+	"github.com/labstack/echo/v4"
+)
+
+// GetCampaignFeed retrieves the feed of a digest campaign.
+func (c *Core) GetCampaignFeed(campID int) (models.CampaignFeed, error) {
+	var out models.CampaignFeed
+	if err := c.q.GetCampaignFeed.Get(&out, campID); err != nil {
+		if err == sql.ErrNoRows {
+			return out, echo.NewHTTPError(http.StatusBadRequest,
+				c.i18n.Ts("globals.messages.notFound", "name", "{globals.terms.feed}"))
+		}
+
+		c.log.Printf("error fetching campaign feed: %v", err)
+		return out, echo.NewHTTPError(http.StatusInternalServerError,
+			c.i18n.Ts("globals.messages.errorFetching", "name", "{globals.terms.feed}", "error", pqErrMsg(err)))
+	}
+
+	return out, nil
+}
+
+// GetCampaignFeeds retrieves all enabled digest campaign feeds.
+func (c *Core) GetCampaignFeeds() ([]models.CampaignFeed, error) {
+	out := []models.CampaignFeed{}
+	if err := c.q.GetCampaignFeeds.Select(&out); err != nil {
+		c.log.Printf("error fetching campaign feeds: %v", err)
+		return nil, echo.NewHTTPError(http.StatusInternalServerError,
+			c.i18n.Ts("globals.messages.errorFetching", "name", "{globals.terms.feed}", "error", pqErrMsg(err)))
+	}
+
+	return out, nil
+}
+
+// UpsertCampaignFeed creates or updates the feed of a digest campaign.
+func (c *Core) UpsertCampaignFeed(o models.CampaignFeed) (models.CampaignFeed, error) {
+	var out models.CampaignFeed
+	if err := c.q.UpsertCampaignFeed.Get(&out, o.CampaignID, o.URL, o.PollInterval, o.MaxItems, o.Enabled); err != nil {
+		c.log.Printf("error updating campaign feed: %v", err)
+		return out, echo.NewHTTPError(http.StatusInternalServerError,
+			c.i18n.Ts("globals.messages.errorUpdating", "name", "{globals.terms.feed}", "error", pqErrMsg(err)))
+	}
+
+	return out, nil
+}
+
+// DeleteCampaignFeed deletes the feed of a digest campaign.
+func (c *Core) DeleteCampaignFeed(campID int) error {
+	if _, err := c.q.DeleteCampaignFeed.Exec(campID); err != nil {
+		c.log.Printf("error deleting campaign feed: %v", err)
+		return echo.NewHTTPError(http.StatusInternalServerError,
+			c.i18n.Ts("globals.messages.errorDeleting", "name", "{globals.terms.feed}", "error", pqErrMsg(err)))
+	}
+
+	return nil
+}
+
+// UpdateCampaignFeedChecked records that a digest campaign's feed was checked
+// without picking up new items.
+func (c *Core) UpdateCampaignFeedChecked(campID int) error {
+	if _, err := c.q.UpdateCampaignFeedChecked.Exec(campID); err != nil {
+		c.log.Printf("error updating campaign feed: %v", err)
+		return echo.NewHTTPError(http.StatusInternalServerError,
+			c.i18n.Ts("globals.messages.errorUpdating", "name", "{globals.terms.feed}", "error", pqErrMsg(err)))
+	}
+
+	return nil
+}
+
+// CreateFeedCampaign creates a regular campaign from the given digest campaign
+// with the given feed items and starts it. The feed check and lastItemAt, the
+// newest item, are recorded along with it.
+func (c *Core) CreateFeedCampaign(digestID int, name string, items models.FeedItems, lastItemAt time.Time) (int, error) {
+	uu, err := uuid.NewV4()
+	if err != nil {
+		c.log.Printf("error generating UUID: %v", err)
+		return 0, echo.NewHTTPError(http.StatusInternalServerError, c.i18n.Ts("globals.messages.errorUUID", "error", err.Error()))
+	}
+
+	var newID int
+	if err := c.q.CreateFeedCampaign.Get(&newID, digestID, uu, name, items, lastItemAt); err != nil {
+		if err == sql.ErrNoRows {
+			return 0, echo.NewHTTPError(http.StatusBadRequest,
+				c.i18n.Ts("globals.messages.notFound", "name", "{globals.terms.campaign}"))
+		}
+
+		c.log.Printf("error creating feed campaign: %v", err)
+		return 0, echo.NewHTTPError(http.StatusInternalServerError,
+			c.i18n.Ts("globals.messages.errorCreating", "name", "{globals.terms.campaign}", "error", pqErrMsg(err)))
+	}
+
+	return newID, nil
+}
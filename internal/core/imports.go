@@ -0,0 +1,228 @@
+package core
+
+import (
+	"database/sql"
+	"net/http"
+
+	"github.com/knadh/listmonk/models"
+	"github.com/labstack/echo/v4"
+)
+
+// CreateImportJob queues a new subscriber import job for the given user.
+func (c *Core) CreateImportJob(userID int, name string, params []byte, filePath string) (models.ImportJob, error) {
+	var id int
+	if err := c.q.CreateImportJob.Get(&id, userID, name, params, filePath); err != nil {
+		c.log.Printf("error creating import job: %v", err)
+		return models.ImportJob{}, echo.NewHTTPError(http.StatusInternalServerError,
+			c.i18n.Ts("globals.messages.errorCreating", "name", "{globals.terms.import}", "error", pqErrMsg(err)))
+	}
+
+	return c.GetImportJob(id, 0)
+}
+
+// QueryImportJobs retrieves paginated import jobs, latest first, optionally
+// restricted to a user (userID > 0) and a status. It also returns the total
+// number of jobs.
+func (c *Core) QueryImportJobs(userID int, status string, offset, limit int) ([]models.ImportJob, int, error) {
+	out := []models.ImportJob{}
+	if err := c.q.QueryImportJobs.Select(&out, userID, status, offset, limit); err != nil {
+		c.log.Printf("error fetching import jobs: %v", err)
+		return nil, 0, echo.NewHTTPError(http.StatusInternalServerError,
+			c.i18n.Ts("globals.messages.errorFetching", "name", "{globals.terms.import}", "error", pqErrMsg(err)))
+	}
+
+	total := 0
+	if len(out) > 0 {
+		total = out[0].TotalJobs
+	}
+
+	return out, total, nil
+}
+
+// GetImportJob retrieves an import job, optionally restricted to a user (userID > 0).
+// If id is 0, the latest job that hasn't been cleared is returned.
+func (c *Core) GetImportJob(id, userID int) (models.ImportJob, error) {
+	var out models.ImportJob
+	if err := c.q.GetImportJob.Get(&out, id, userID); err != nil {
+		if err == sql.ErrNoRows {
+			return out, echo.NewHTTPError(http.StatusNotFound,
+				c.i18n.Ts("globals.messages.notFound", "name", "{globals.terms.import}"))
+		}
+
+		c.log.Printf("error fetching import job: %v", err)
+		return out, echo.NewHTTPError(http.StatusInternalServerError,
+			c.i18n.Ts("globals.messages.errorFetching", "name", "{globals.terms.import}", "error", pqErrMsg(err)))
+	}
+
+	return out, nil
+}
+
+// GetImportJobErrors retrieves the CSV of rows rejected in an import job.
+func (c *Core) GetImportJobErrors(id, userID int) ([]byte, error) {
+	var out []byte
+	if err := c.q.GetImportJobErrors.Get(&out, id, userID); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, echo.NewHTTPError(http.StatusNotFound,
+				c.i18n.Ts("globals.messages.notFound", "name", "{globals.terms.import}"))
+		}
+
+		c.log.Printf("error fetching import job errors: %v", err)
+		return nil, echo.NewHTTPError(http.StatusInternalServerError,
+			c.i18n.Ts("globals.messages.errorFetching", "name", "{globals.terms.import}", "error", pqErrMsg(err)))
+	}
+
+	return out, nil
+}
+
+// CancelImportJob cancels a queued import job that hasn't started yet and
+// returns the path of its uploaded file.
+func (c *Core) CancelImportJob(id, userID int) (string, error) {
+	var path string
+	if err := c.q.CancelImportJob.Get(&path, id, userID); err != nil {
+		if err == sql.ErrNoRows {
+			return "", echo.NewHTTPError(http.StatusBadRequest,
+				c.i18n.Ts("globals.messages.notFound", "name", "{globals.terms.import}"))
+		}
+
+		c.log.Printf("error cancelling import job: %v", err)
+		return "", echo.NewHTTPError(http.StatusInternalServerError,
+			c.i18n.Ts("globals.messages.errorUpdating", "name", "{globals.terms.import}", "error", pqErrMsg(err)))
+	}
+
+	return path, nil
+}
+
+// ClearImportJobs dismisses the finished import jobs of a user (or all users
+// if userID is 0) from the import page. They remain in the job history.
+func (c *Core) ClearImportJobs(userID int) error {
+	if _, err := c.q.ClearImportJobs.Exec(userID); err != nil {
+		c.log.Printf("error clearing import jobs: %v", err)
+		return echo.NewHTTPError(http.StatusInternalServerError,
+			c.i18n.Ts("globals.messages.errorUpdating", "name", "{globals.terms.import}", "error", pqErrMsg(err)))
+	}
+
+	return nil
+}
+
+// DeleteImportJob deletes a finished import job.
+func (c *Core) DeleteImportJob(id, userID int) error {
+	res, err := c.q.DeleteImportJob.Exec(id, userID)
+	if err != nil {
+		c.log.Printf("error deleting import job: %v", err)
+		return echo.NewHTTPError(http.StatusInternalServerError,
+			c.i18n.Ts("globals.messages.errorDeleting", "name", "{globals.terms.import}", "error", pqErrMsg(err)))
+	}
+
+	if n, _ := res.RowsAffected(); n == 0 {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			c.i18n.Ts("globals.messages.notFound", "name", "{globals.terms.import}"))
+	}
+
+	return nil
+}
+
+// NextImportJob claims the oldest queued import job for processing.
+// nil is returned if there are no queued jobs.
+func (c *Core) NextImportJob() (*models.ImportJob, error) {
+	var out models.ImportJob
+	if err := c.q.NextImportJob.Get(&out); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &out, nil
+}
+
+// UpdateImportJob updates the status, stats, and logs of an import job.
+func (c *Core) UpdateImportJob(id int, status string, total, imported, rejected int, log string) error {
+	_, err := c.q.UpdateImportJob.Exec(id, status, total, imported, rejected, log)
+	return err
+}
+
+// SaveImportJobErrors saves the CSV of rows rejected in an import job.
+func (c *Core) SaveImportJobErrors(id int, b []byte) error {
+	_, err := c.q.UpdateImportJobErrors.Exec(id, b)
+	return err
+}
+
+// FailInterruptedImportJobs marks import jobs that were running when the app was
+// stopped as failed and returns the paths of their uploaded files.
+func (c *Core) FailInterruptedImportJobs() ([]string, error) {
+	var out []string
+	if err := c.q.FailInterruptedImportJobs.Select(&out); err != nil {
+		return nil, err
+	}
+
+	return out, nil
+}
+
+// GetImportPresets retrieves all import presets.
+func (c *Core) GetImportPresets() ([]models.ImportPreset, error) {
+	out := []models.ImportPreset{}
+	if err := c.q.GetImportPresets.Select(&out, 0); err != nil {
+		c.log.Printf("error fetching import presets: %v", err)
+		return nil, echo.NewHTTPError(http.StatusInternalServerError,
+			c.i18n.Ts("globals.messages.errorFetching", "name", "{globals.terms.importPreset}", "error", pqErrMsg(err)))
+	}
+
+	return out, nil
+}
+
+// GetImportPreset retrieves an import preset.
+func (c *Core) GetImportPreset(id int) (models.ImportPreset, error) {
+	var out []models.ImportPreset
+	if err := c.q.GetImportPresets.Select(&out, id); err != nil {
+		c.log.Printf("error fetching import presets: %v", err)
+		return models.ImportPreset{}, echo.NewHTTPError(http.StatusInternalServerError,
+			c.i18n.Ts("globals.messages.errorFetching", "name", "{globals.terms.importPreset}", "error", pqErrMsg(err)))
+	}
+
+	if len(out) == 0 {
+		return models.ImportPreset{}, echo.NewHTTPError(http.StatusBadRequest,
+			c.i18n.Ts("globals.messages.notFound", "name", "{globals.terms.importPreset}"))
+	}
+
+	return out[0], nil
+}
+
+// CreateImportPreset creates a new import preset.
+func (c *Core) CreateImportPreset(o models.ImportPreset) (models.ImportPreset, error) {
+	var newID int
+	if err := c.q.CreateImportPreset.Get(&newID, o.Name, o.Mapping); err != nil {
+		c.log.Printf("error creating import preset: %v", err)
+		return models.ImportPreset{}, echo.NewHTTPError(http.StatusInternalServerError,
+			c.i18n.Ts("globals.messages.errorCreating", "name", "{globals.terms.importPreset}", "error", pqErrMsg(err)))
+	}
+
+	return c.GetImportPreset(newID)
+}
+
+// UpdateImportPreset updates an import preset.
+func (c *Core) UpdateImportPreset(id int, o models.ImportPreset) (models.ImportPreset, error) {
+	res, err := c.q.UpdateImportPreset.Exec(id, o.Name, o.Mapping)
+	if err != nil {
+		c.log.Printf("error updating import preset: %v", err)
+		return models.ImportPreset{}, echo.NewHTTPError(http.StatusInternalServerError,
+			c.i18n.Ts("globals.messages.errorUpdating", "name", "{globals.terms.importPreset}", "error", pqErrMsg(err)))
+	}
+
+	if n, _ := res.RowsAffected(); n == 0 {
+		return models.ImportPreset{}, echo.NewHTTPError(http.StatusBadRequest,
+			c.i18n.Ts("globals.messages.notFound", "name", "{globals.terms.importPreset}"))
+	}
+
+	return c.GetImportPreset(id)
+}
+
+// DeleteImportPreset deletes an import preset.
+func (c *Core) DeleteImportPreset(id int) error {
+	if _, err := c.q.DeleteImportPreset.Exec(id); err != nil {
+		c.log.Printf("error deleting import preset: %v", err)
+		return echo.NewHTTPError(http.StatusInternalServerError,
+			c.i18n.Ts("globals.messages.errorDeleting", "name", "{globals.terms.importPreset}", "error", pqErrMsg(err)))
+	}
+
+	return nil
+}
@@ -0,0 +1,64 @@
+package core
+
+import (
+	"net/http"
+	"unicode/utf8"
+
+	"github.com/knadh/listmonk/models"
+	"github.com/labstack/echo/v4"
+)
+
+// maxReplyExcerpt is the max number of characters of a reply's body that's
+// recorded in the activity timeline.
+const maxReplyExcerpt = 1000
+
+// RecordReply records a reply e-mail in the activity timeline of the subscriber
+// who sent it, by the subscriber UUID in the e-mail, or the sender's e-mail.
+func (c *Core) RecordReply(m models.InboundMessage) error {
+	excerpt := m.Body
+	if utf8.RuneCountInString(excerpt) > maxReplyExcerpt {
+		excerpt = string([]rune(excerpt)[:maxReplyExcerpt]) + "…"
+	}
+
+	data := models.JSON{
+		"campaign_uuid": m.CampaignUUID,
+		"from":          m.Email,
+		"subject":       m.Subject,
+		"message_id":    m.MessageID,
+		"excerpt":       excerpt,
+	}
+
+	if _, err := c.q.RecordReply.Exec(m.SubscriberUUID, m.Email, data, m.CampaignUUID); err != nil {
+		c.log.Printf("error recording reply: %v", err)
+		return echo.NewHTTPError(http.StatusInternalServerError,
+			c.i18n.Ts("globals.messages.errorCreating", "name", "{globals.terms.activity}", "error", pqErrMsg(err)))
+	}
+
+	return nil
+}
+
+// UnsubscribeByEmail unsubscribes a subscriber by e-mail from all the lists in a campaign,
+// or from all lists if campUUID is empty. If subUUID is given, it should belong to the e-mail.
+// It returns the subscriber's ID and the lists that were unsubscribed from.
+func (c *Core) UnsubscribeByEmail(email, subUUID, campUUID string) (int, []int, error) {
+	var res []struct {
+		SubscriberID int `db:"subscriber_id"`
+		ListID       int `db:"list_id"`
+	}
+	if err := c.q.UnsubscribeByEmail.Select(&res, email, subUUID, campUUID); err != nil {
+		c.log.Printf("error unsubscribing: %v", err)
+		return 0, nil, echo.NewHTTPError(http.StatusInternalServerError,
+			c.i18n.Ts("globals.messages.errorUpdating", "name", "{globals.terms.subscribers}", "error", pqErrMsg(err)))
+	}
+
+	if len(res) == 0 {
+		return 0, nil, nil
+	}
+
+	listIDs := make([]int, 0, len(res))
+	for _, r := range res {
+		listIDs = append(listIDs, r.ListID)
+	}
+
+	return res[0].SubscriberID, listIDs, nil
+}
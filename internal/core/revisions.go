@@ -0,0 +1,111 @@
+package core
+
+import (
+	"database/sql"
+	"net/http"
+
+	"github.com/knadh/listmonk/models"
+	"github.com/labstack/echo/v4"
+	"github.com/lib/pq"
+)
+
+// SaveCampaignRevision records the campaign's current content as a new revision
+// by the given user. Nothing is recorded if the content hasn't changed since the
+// last revision.
+func (c *Core) SaveCampaignRevision(campID, userID int, userName string) error {
+	if err := c.insertCampaignRevision(campID, userID, userName); err != nil {
+		c.log.Printf("error recording campaign revision: %v", err)
+		return echo.NewHTTPError(http.StatusInternalServerError,
+			c.i18n.Ts("globals.messages.errorCreating", "name", "{globals.terms.revision}", "error", pqErrMsg(err)))
+	}
+
+	return nil
+}
+
+// FreezeCampaignRevision marks the campaign's latest revision as the one that
+// was sent. If the content has changed since the last revision (or there are no
+// revisions), a revision is recorded first.
+func (c *Core) FreezeCampaignRevision(campID int) error {
+	if err := c.insertCampaignRevision(campID, 0, ""); err != nil {
+		c.log.Printf("error freezing revision of campaign %d: %v", campID, err)
+		return err
+	}
+
+	if _, err := c.q.FreezeCampaignRevision.Exec(campID); err != nil {
+		c.log.Printf("error freezing revision of campaign %d: %v", campID, err)
+		return err
+	}
+
+	return nil
+}
+
+// insertCampaignRevision records the campaign's current content as a new revision.
+// Concurrent inserts can pick the same version number, in which case the loser
+// is retried against the revision that was just recorded.
+func (c *Core) insertCampaignRevision(campID, userID int, userName string) error {
+	var err error
+	for i := 0; i < 3; i++ {
+		var id int
+		err = c.q.InsertCampaignRevision.Get(&id, campID, userID, userName)
+		if err == nil || err == sql.ErrNoRows {
+			return nil
+		}
+
+		if pqErr, ok := err.(*pq.Error); !ok || pqErr.Constraint != "campaign_revisions_campaign_id_version_key" {
+			return err
+		}
+	}
+
+	return err
+}
+
+// GetCampaignRevisions retrieves a campaign's revisions (without the content
+// snapshots), latest first.
+func (c *Core) GetCampaignRevisions(campID int) ([]models.CampaignRevision, error) {
+	out := []models.CampaignRevision{}
+	if err := c.q.GetCampaignRevisions.Select(&out, campID); err != nil {
+		c.log.Printf("error fetching campaign revisions: %v", err)
+		return nil, echo.NewHTTPError(http.StatusInternalServerError,
+			c.i18n.Ts("globals.messages.errorFetching", "name", "{globals.terms.revision}", "error", pqErrMsg(err)))
+	}
+
+	return out, nil
+}
+
+// GetCampaignRevision retrieves a campaign revision along with its content snapshot.
+func (c *Core) GetCampaignRevision(campID, version int) (models.CampaignRevision, error) {
+	var out models.CampaignRevision
+	if err := c.q.GetCampaignRevision.Get(&out, campID, version); err != nil {
+		if err == sql.ErrNoRows {
+			return out, echo.NewHTTPError(http.StatusBadRequest,
+				c.i18n.Ts("globals.messages.notFound", "name", "{globals.terms.revision}"))
+		}
+
+		c.log.Printf("error fetching campaign revision: %v", err)
+		return out, echo.NewHTTPError(http.StatusInternalServerError,
+			c.i18n.Ts("globals.messages.errorFetching", "name", "{globals.terms.revision}", "error", pqErrMsg(err)))
+	}
+
+	return out, nil
+}
+
+// RestoreCampaignRevision overwrites a campaign's content with a revision and
+// records the restored content as a new revision by the given user.
+func (c *Core) RestoreCampaignRevision(campID, version, userID int, userName string) (models.Campaign, error) {
+	res, err := c.q.RestoreCampaignRevision.Exec(campID, version)
+	if err != nil {
+		c.log.Printf("error restoring campaign revision: %v", err)
+		return models.Campaign{}, echo.NewHTTPError(http.StatusInternalServerError,
+			c.i18n.Ts("globals.messages.errorUpdating", "name", "{globals.terms.campaign}", "error", pqErrMsg(err)))
+	}
+
+	if n, _ := res.RowsAffected(); n == 0 {
+		return models.Campaign{}, echo.NewHTTPError(http.StatusBadRequest,
+			c.i18n.Ts("globals.messages.notFound", "name", "{globals.terms.revision}"))
+	}
+
+	// The content has been restored regardless. Errors are logged.
+	_ = c.SaveCampaignRevision(campID, userID, userName)
+
+	return c.GetCampaign(campID, "", "")
+}
@@ -0,0 +1,189 @@
+package core
+
+import (
+	"net/http"
+
+	"github.com/knadh/listmonk/models"
+	"github.com/labstack/echo/v4"
+	"github.com/lib/pq"
+)
+
+// GetSequences retrieves all sequences with their steps.
+func (c *Core) GetSequences() ([]models.Sequence, error) {
+	return c.getSequences(0)
+}
+
+// GetSequence retrieves a sequence with its steps.
+func (c *Core) GetSequence(id int) (models.Sequence, error) {
+	out, err := c.getSequences(id)
+	if err != nil {
+		return models.Sequence{}, err
+	}
+
+	if len(out) == 0 {
+		return models.Sequence{}, echo.NewHTTPError(http.StatusBadRequest,
+			c.i18n.Ts("globals.messages.notFound", "name", "{globals.terms.sequence}"))
+	}
+
+	return out[0], nil
+}
+
+func (c *Core) getSequences(id int) ([]models.Sequence, error) {
+	out := []models.Sequence{}
+	if err := c.q.GetSequences.Select(&out, id); err != nil {
+		c.log.Printf("error fetching sequences: %v", err)
+		return nil, echo.NewHTTPError(http.StatusInternalServerError,
+			c.i18n.Ts("globals.messages.errorFetching", "name", "{globals.terms.sequence}", "error", pqErrMsg(err)))
+	}
+
+	for i := range out {
+		steps := []models.SequenceStep{}
+		if err := c.q.GetSequenceSteps.Select(&steps, out[i].ID); err != nil {
+			c.log.Printf("error fetching sequence steps: %v", err)
+			return nil, echo.NewHTTPError(http.StatusInternalServerError,
+				c.i18n.Ts("globals.messages.errorFetching", "name", "{globals.terms.sequence}", "error", pqErrMsg(err)))
+		}
+		out[i].Steps = steps
+	}
+
+	return out, nil
+}
+
+// CreateSequence creates a new sequence with its steps.
+func (c *Core) CreateSequence(o models.Sequence) (models.Sequence, error) {
+	var newID int
+	if err := c.q.CreateSequence.Get(&newID, o.Name, o.ListID, o.FromEmail, o.Messenger, o.Enabled); err != nil {
+		c.log.Printf("error creating sequence: %v", err)
+		return models.Sequence{}, echo.NewHTTPError(http.StatusInternalServerError,
+			c.i18n.Ts("globals.messages.errorCreating", "name", "{globals.terms.sequence}", "error", pqErrMsg(err)))
+	}
+
+	if err := c.setSequenceSteps(newID, o.Steps); err != nil {
+		return models.Sequence{}, err
+	}
+
+	return c.GetSequence(newID)
+}
+
+// UpdateSequence updates a sequence and its steps. Steps are matched by their
+// position so that the stats of existing steps are retained.
+func (c *Core) UpdateSequence(id int, o models.Sequence) (models.Sequence, error) {
+	res, err := c.q.UpdateSequence.Exec(id, o.Name, o.ListID, o.FromEmail, o.Messenger, o.Enabled)
+	if err != nil {
+		c.log.Printf("error updating sequence: %v", err)
+		return models.Sequence{}, echo.NewHTTPError(http.StatusInternalServerError,
+			c.i18n.Ts("globals.messages.errorUpdating", "name", "{globals.terms.sequence}", "error", pqErrMsg(err)))
+	}
+
+	if n, _ := res.RowsAffected(); n == 0 {
+		return models.Sequence{}, echo.NewHTTPError(http.StatusBadRequest,
+			c.i18n.Ts("globals.messages.notFound", "name", "{globals.terms.sequence}"))
+	}
+
+	if err := c.setSequenceSteps(id, o.Steps); err != nil {
+		return models.Sequence{}, err
+	}
+
+	return c.GetSequence(id)
+}
+
+// setSequenceSteps replaces the steps of a sequence in the given order.
+func (c *Core) setSequenceSteps(id int, steps []models.SequenceStep) error {
+	tx, err := c.db.Beginx()
+	if err != nil {
+		c.log.Printf("error updating sequence steps: %v", err)
+		return echo.NewHTTPError(http.StatusInternalServerError,
+			c.i18n.Ts("globals.messages.errorUpdating", "name", "{globals.terms.sequence}", "error", pqErrMsg(err)))
+	}
+	defer tx.Rollback()
+
+	for n, s := range steps {
+		if _, err := tx.Stmtx(c.q.UpsertSequenceStep).Exec(id, n+1, s.Delay, s.TemplateID, s.Subject); err != nil {
+			c.log.Printf("error updating sequence step: %v", err)
+			return echo.NewHTTPError(http.StatusBadRequest,
+				c.i18n.Ts("globals.messages.errorUpdating", "name", "{globals.terms.sequence}", "error", pqErrMsg(err)))
+		}
+	}
+
+	if _, err := tx.Stmtx(c.q.DeleteSequenceStepsAfter).Exec(id, len(steps)); err != nil {
+		c.log.Printf("error deleting sequence steps: %v", err)
+		return echo.NewHTTPError(http.StatusInternalServerError,
+			c.i18n.Ts("globals.messages.errorUpdating", "name", "{globals.terms.sequence}", "error", pqErrMsg(err)))
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.log.Printf("error updating sequence steps: %v", err)
+		return echo.NewHTTPError(http.StatusInternalServerError,
+			c.i18n.Ts("globals.messages.errorUpdating", "name", "{globals.terms.sequence}", "error", pqErrMsg(err)))
+	}
+
+	return nil
+}
+
+// DeleteSequence deletes a sequence.
+func (c *Core) DeleteSequence(id int) error {
+	if _, err := c.q.DeleteSequence.Exec(id); err != nil {
+		c.log.Printf("error deleting sequence: %v", err)
+		return echo.NewHTTPError(http.StatusInternalServerError,
+			c.i18n.Ts("globals.messages.errorDeleting", "name", "{globals.terms.sequence}", "error", pqErrMsg(err)))
+	}
+
+	return nil
+}
+
+// AddSequenceSubscribers adds the given subscribers to a sequence.
+func (c *Core) AddSequenceSubscribers(id int, subIDs []int) error {
+	if _, err := c.q.AddSequenceSubscribers.Exec(id, pq.Array(subIDs)); err != nil {
+		c.log.Printf("error adding subscribers to sequence: %v", err)
+		return echo.NewHTTPError(http.StatusInternalServerError,
+			c.i18n.Ts("globals.messages.errorUpdating", "name", "{globals.terms.sequence}", "error", pqErrMsg(err)))
+	}
+
+	return nil
+}
+
+// ExitSequenceSubscribers removes the given subscribers from a sequence.
+func (c *Core) ExitSequenceSubscribers(id int, subIDs []int) error {
+	if _, err := c.q.ExitSequenceSubscribers.Exec(id, pq.Array(subIDs)); err != nil {
+		c.log.Printf("error removing subscribers from sequence: %v", err)
+		return echo.NewHTTPError(http.StatusInternalServerError,
+			c.i18n.Ts("globals.messages.errorUpdating", "name", "{globals.terms.sequence}", "error", pqErrMsg(err)))
+	}
+
+	return nil
+}
+
+// SyncSequenceSubscribers adds subscribers who have subscribed to sequence lists
+// to the sequences, and exits the ones who have unsubscribed or are done.
+func (c *Core) SyncSequenceSubscribers() error {
+	if _, err := c.q.EnrollSequenceSubscribers.Exec(); err != nil {
+		return err
+	}
+
+	if _, err := c.q.ExitUnsubscribedSequenceSubscribers.Exec(); err != nil {
+		return err
+	}
+
+	if _, err := c.q.FinishSequenceSubscribers.Exec(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// NextSequenceMessages returns up to limit sequence steps that are due to be sent.
+func (c *Core) NextSequenceMessages(limit int) ([]models.SequenceMessage, error) {
+	out := []models.SequenceMessage{}
+	if err := c.q.NextSequenceMessages.Select(&out, limit); err != nil {
+		return nil, err
+	}
+
+	return out, nil
+}
+
+// AdvanceSequenceSubscriber records that the given step was processed for a subscriber.
+// sent indicates whether a message was actually sent or the step was skipped.
+func (c *Core) AdvanceSequenceSubscriber(seqID, subID, position int, sent bool) error {
+	_, err := c.q.AdvanceSequenceSubscriber.Exec(seqID, subID, position, sent)
+	return err
+}
@@ -1,8 +1,10 @@
 package core
 
 import (
+	"database/sql"
 	"encoding/json"
 	"net/http"
+	"time"
 
 	"github.com/jmoiron/sqlx/types"
 	"github.com/knadh/listmonk/models"
@@ -48,3 +50,23 @@ func (c *Core) UpdateSettings(s models.Settings) error {
 
 	return nil
 }
+
+// IncrSMTPWarmupCount increments an SMTP server's send count for the given day
+// if it's below limit. It returns false if the limit has been reached.
+func (c *Core) IncrSMTPWarmupCount(server string, day time.Time, limit int) (bool, error) {
+	var n int
+	if err := c.q.IncrSMTPWarmupCount.Get(&n, server, day.Format("2006-01-02"), limit); err != nil {
+		if err == sql.ErrNoRows {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return true, nil
+}
+
+// DecrSMTPWarmupCount decrements an SMTP server's send count for the given day.
+func (c *Core) DecrSMTPWarmupCount(server string, day time.Time) error {
+	_, err := c.q.DecrSMTPWarmupCount.Exec(server, day.Format("2006-01-02"))
+	return err
+}
@@ -271,6 +271,13 @@ func (c *Core) ExportSubscribers(searchStr, query string, subIDs, listIDs []int,
 // it was a new subscriber, and the second bool indicates if the subscriber was sent an optin confirmation.
 // bool = optinSent?
 func (c *Core) InsertSubscriber(sub models.Subscriber, listIDs []int, listUUIDs []string, preconfirm, assertOptin bool) (models.Subscriber, bool, error) {
+	// Validate the attributes against the schema.
+	attribs, err := c.ValidateAttribs(sub.Attribs, false)
+	if err != nil {
+		return models.Subscriber{}, false, err
+	}
+	sub.Attribs = attribs
+
 	uu, err := uuid.NewV4()
 	if err != nil {
 		c.log.Printf("error generating UUID: %v", err)
@@ -304,6 +311,11 @@ func (c *Core) InsertSubscriber(sub models.Subscriber, listIDs []int, listUUIDs
 		pq.Array(listIDs),
 		pq.Array(listUUIDs),
 		subStatus); err != nil {
+		// No rows are returned if the e-mail belongs to an existing subscriber.
+		if err == sql.ErrNoRows {
+			return models.Subscriber{}, false, echo.NewHTTPError(http.StatusConflict, c.i18n.T("subscribers.emailExists"))
+		}
+
 		if pqErr, ok := err.(*pq.Error); ok && pqErr.Constraint == "subscribers_email_key" {
 			return models.Subscriber{}, false, echo.NewHTTPError(http.StatusConflict, c.i18n.T("subscribers.emailExists"))
 		} else {
@@ -337,23 +349,16 @@ func (c *Core) InsertSubscriber(sub models.Subscriber, listIDs []int, listUUIDs
 
 // UpdateSubscriber updates a subscriber's properties.
 func (c *Core) UpdateSubscriber(id int, sub models.Subscriber) (models.Subscriber, error) {
-	// Format raw JSON attributes.
-	attribs := []byte("{}")
-	if len(sub.Attribs) > 0 {
-		if b, err := json.Marshal(sub.Attribs); err != nil {
-			return models.Subscriber{}, echo.NewHTTPError(http.StatusInternalServerError,
-				c.i18n.Ts("globals.messages.errorUpdating",
-					"name", "{globals.terms.subscriber}", "error", err.Error()))
-		} else {
-			attribs = b
-		}
+	attribs, err := c.prepareUpdateAttribs(sub.Attribs)
+	if err != nil {
+		return models.Subscriber{}, err
 	}
 
-	_, err := c.q.UpdateSubscriber.Exec(id,
+	_, err = c.q.UpdateSubscriber.Exec(id,
 		sub.Email,
 		strings.TrimSpace(sub.Name),
 		sub.Status,
-		json.RawMessage(attribs),
+		attribs,
 	)
 	if err != nil {
 		c.log.Printf("error updating subscriber: %v", err)
@@ -369,6 +374,30 @@ func (c *Core) UpdateSubscriber(id int, sub models.Subscriber) (models.Subscribe
 	return out, nil
 }
 
+// prepareUpdateAttribs validates and encodes the attributes of a subscriber
+// that's being updated. As they replace the existing attributes, required
+// attributes are enforced. nil attributes are returned as an empty string,
+// which retains the existing ones.
+func (c *Core) prepareUpdateAttribs(attribs models.JSON) (string, error) {
+	if attribs == nil {
+		return "", nil
+	}
+
+	a, err := c.ValidateAttribs(attribs, false)
+	if err != nil {
+		return "", err
+	}
+
+	b, err := json.Marshal(a)
+	if err != nil {
+		return "", echo.NewHTTPError(http.StatusInternalServerError,
+			c.i18n.Ts("globals.messages.errorUpdating",
+				"name", "{globals.terms.subscriber}", "error", err.Error()))
+	}
+
+	return string(b), nil
+}
+
 // UpdateSubscriberWithLists updates a subscriber's properties.
 // If deleteLists is set to true, all existing subscriptions are deleted and only
 // the ones provided are added or retained.
@@ -378,23 +407,16 @@ func (c *Core) UpdateSubscriberWithLists(id int, sub models.Subscriber, listIDs
 		subStatus = models.SubscriptionStatusConfirmed
 	}
 
-	// Format raw JSON attributes.
-	attribs := []byte("{}")
-	if len(sub.Attribs) > 0 {
-		if b, err := json.Marshal(sub.Attribs); err != nil {
-			return models.Subscriber{}, false, echo.NewHTTPError(http.StatusInternalServerError,
-				c.i18n.Ts("globals.messages.errorUpdating",
-					"name", "{globals.terms.subscriber}", "error", err.Error()))
-		} else {
-			attribs = b
-		}
+	attribs, err := c.prepareUpdateAttribs(sub.Attribs)
+	if err != nil {
+		return models.Subscriber{}, false, err
 	}
 
-	_, err := c.q.UpdateSubscriberWithLists.Exec(id,
+	_, err = c.q.UpdateSubscriberWithLists.Exec(id,
 		sub.Email,
 		strings.TrimSpace(sub.Name),
 		sub.Status,
-		json.RawMessage(attribs),
+		attribs,
 		pq.Array(listIDs),
 		pq.Array(listUUIDs),
 		subStatus,
@@ -476,14 +498,29 @@ func (c *Core) DeleteSubscribersByQuery(searchStr, queryExp string, listIDs []in
 }
 
 // UnsubscribeByCampaign unsubscribes a given subscriber from lists in a given campaign.
-func (c *Core) UnsubscribeByCampaign(subUUID, campUUID string, blocklist bool) error {
-	if _, err := c.q.UnsubscribeByCampaign.Exec(campUUID, subUUID, blocklist); err != nil {
+// It returns the IDs of the lists that were unsubscribed from.
+func (c *Core) UnsubscribeByCampaign(subUUID, campUUID string, blocklist bool) ([]int, error) {
+	listIDs := []int{}
+	if err := c.q.UnsubscribeByCampaign.Select(&listIDs, campUUID, subUUID, blocklist); err != nil {
 		c.log.Printf("error unsubscribing: %v", err)
-		return echo.NewHTTPError(http.StatusInternalServerError,
+		return nil, echo.NewHTTPError(http.StatusInternalServerError,
 			c.i18n.Ts("globals.messages.errorUpdating", "name", "{globals.terms.subscribers}", "error", pqErrMsg(err)))
 	}
 
-	return nil
+	return listIDs, nil
+}
+
+// UnsubscribeUnconfirmed unsubscribes a subscriber from the lists whose opt-in
+// subscriptions are unconfirmed and returns the IDs of those lists.
+func (c *Core) UnsubscribeUnconfirmed(subUUID string) ([]int, error) {
+	listIDs := []int{}
+	if err := c.q.UnsubscribeUnconfirmed.Select(&listIDs, subUUID); err != nil {
+		c.log.Printf("error unsubscribing: %v", err)
+		return nil, echo.NewHTTPError(http.StatusInternalServerError,
+			c.i18n.Ts("globals.messages.errorUpdating", "name", "{globals.terms.subscribers}", "error", pqErrMsg(err)))
+	}
+
+	return listIDs, nil
 }
 
 // ConfirmOptionSubscription confirms a subscriber's optin subscription.
@@ -0,0 +1,132 @@
+package core
+
+import (
+	"net/http"
+
+	"github.com/knadh/listmonk/models"
+	"github.com/labstack/echo/v4"
+)
+
+// GetSyncSources retrieves all subscriber sync sources.
+func (c *Core) GetSyncSources() ([]models.SyncSource, error) {
+	out := []models.SyncSource{}
+	if err := c.q.GetSyncSources.Select(&out, 0); err != nil {
+		c.log.Printf("error fetching sync sources: %v", err)
+		return nil, echo.NewHTTPError(http.StatusInternalServerError,
+			c.i18n.Ts("globals.messages.errorFetching", "name", "{globals.terms.syncSource}", "error", pqErrMsg(err)))
+	}
+
+	return out, nil
+}
+
+// GetSyncSource retrieves a subscriber sync source.
+func (c *Core) GetSyncSource(id int) (models.SyncSource, error) {
+	var out []models.SyncSource
+	if err := c.q.GetSyncSources.Select(&out, id); err != nil {
+		c.log.Printf("error fetching sync sources: %v", err)
+		return models.SyncSource{}, echo.NewHTTPError(http.StatusInternalServerError,
+			c.i18n.Ts("globals.messages.errorFetching", "name", "{globals.terms.syncSource}", "error", pqErrMsg(err)))
+	}
+
+	if len(out) == 0 {
+		return models.SyncSource{}, echo.NewHTTPError(http.StatusBadRequest,
+			c.i18n.Ts("globals.messages.notFound", "name", "{globals.terms.syncSource}"))
+	}
+
+	return out[0], nil
+}
+
+// CreateSyncSource creates a new subscriber sync source.
+func (c *Core) CreateSyncSource(o models.SyncSource) (models.SyncSource, error) {
+	var newID int
+	if err := c.q.CreateSyncSource.Get(&newID, o.Name, o.Type, o.URL, o.Query, o.Headers, o.Schedule, o.Mapping,
+		o.ListID, o.SubStatus, o.Overwrite, o.UnsubscribeMissing, o.Enabled, o.UserID.Int); err != nil {
+		c.log.Printf("error creating sync source: %v", err)
+		return models.SyncSource{}, echo.NewHTTPError(http.StatusInternalServerError,
+			c.i18n.Ts("globals.messages.errorCreating", "name", "{globals.terms.syncSource}", "error", pqErrMsg(err)))
+	}
+
+	return c.GetSyncSource(newID)
+}
+
+// UpdateSyncSource updates a subscriber sync source.
+func (c *Core) UpdateSyncSource(id int, o models.SyncSource) (models.SyncSource, error) {
+	res, err := c.q.UpdateSyncSource.Exec(id, o.Name, o.Type, o.URL, o.Query, o.Headers, o.Schedule, o.Mapping,
+		o.ListID, o.SubStatus, o.Overwrite, o.UnsubscribeMissing, o.Enabled)
+	if err != nil {
+		c.log.Printf("error updating sync source: %v", err)
+		return models.SyncSource{}, echo.NewHTTPError(http.StatusInternalServerError,
+			c.i18n.Ts("globals.messages.errorUpdating", "name", "{globals.terms.syncSource}", "error", pqErrMsg(err)))
+	}
+
+	if n, _ := res.RowsAffected(); n == 0 {
+		return models.SyncSource{}, echo.NewHTTPError(http.StatusBadRequest,
+			c.i18n.Ts("globals.messages.notFound", "name", "{globals.terms.syncSource}"))
+	}
+
+	return c.GetSyncSource(id)
+}
+
+// DeleteSyncSource deletes a subscriber sync source and its run history.
+func (c *Core) DeleteSyncSource(id int) error {
+	if _, err := c.q.DeleteSyncSource.Exec(id); err != nil {
+		c.log.Printf("error deleting sync source: %v", err)
+		return echo.NewHTTPError(http.StatusInternalServerError,
+			c.i18n.Ts("globals.messages.errorDeleting", "name", "{globals.terms.syncSource}", "error", pqErrMsg(err)))
+	}
+
+	return nil
+}
+
+// QuerySyncRuns retrieves the paginated runs of a sync source, latest first,
+// and the total number of runs.
+func (c *Core) QuerySyncRuns(sourceID, offset, limit int) ([]models.SyncRun, int, error) {
+	out := []models.SyncRun{}
+	if err := c.q.QuerySyncRuns.Select(&out, sourceID, offset, limit); err != nil {
+		c.log.Printf("error fetching sync runs: %v", err)
+		return nil, 0, echo.NewHTTPError(http.StatusInternalServerError,
+			c.i18n.Ts("globals.messages.errorFetching", "name", "{globals.terms.syncSource}", "error", pqErrMsg(err)))
+	}
+
+	total := 0
+	if len(out) > 0 {
+		total = out[0].TotalRuns
+	}
+
+	return out, total, nil
+}
+
+// GetListSubscriberEmails retrieves the (lowercased) e-mails of all
+// subscribers in a list who haven't unsubscribed from it.
+func (c *Core) GetListSubscriberEmails(listID int) ([]string, error) {
+	var out []string
+	if err := c.q.GetListSubscriberEmails.Select(&out, listID); err != nil {
+		return nil, err
+	}
+
+	return out, nil
+}
+
+// CreateSyncRun records the start of a run of a sync source.
+func (c *Core) CreateSyncRun(sourceID int) (int, error) {
+	var id int
+	if err := c.q.CreateSyncRun.Get(&id, sourceID); err != nil {
+		return 0, err
+	}
+
+	return id, nil
+}
+
+// UpdateSyncRun records the outcome of a sync run.
+func (c *Core) UpdateSyncRun(r models.SyncRun) error {
+	_, err := c.q.UpdateSyncRun.Exec(r.ID, r.Status, r.Total, r.Skipped, r.Added, r.Updated, r.Removed,
+		r.ImportJobIDs, r.Error)
+	return err
+}
+
+// FailInterruptedSyncRuns marks sync runs that were running when the app was
+// stopped as failed.
+func (c *Core) FailInterruptedSyncRuns() error {
+	_, err := c.q.FailInterruptedSyncRuns.Exec()
+	return err
+}
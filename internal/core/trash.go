@@ -0,0 +1,118 @@
+package core
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/knadh/listmonk/models"
+	"github.com/labstack/echo/v4"
+	"github.com/lib/pq"
+)
+
+// TrashSubscribers moves the given subscribers to the trash.
+func (c *Core) TrashSubscribers(subIDs []int, subUUIDs []string) error {
+	if subIDs == nil {
+		subIDs = []int{}
+	}
+	if subUUIDs == nil {
+		subUUIDs = []string{}
+	}
+
+	if _, err := c.q.TrashSubscribers.Exec(pq.Array(subIDs), pq.Array(subUUIDs)); err != nil {
+		c.log.Printf("error trashing subscribers: %v", err)
+		return echo.NewHTTPError(http.StatusInternalServerError,
+			c.i18n.Ts("globals.messages.errorDeleting", "name", "{globals.terms.subscribers}", "error", pqErrMsg(err)))
+	}
+
+	return nil
+}
+
+// TrashSubscribersByQuery moves subscribers matching a given arbitrary query expression
+// to the trash.
+func (c *Core) TrashSubscribersByQuery(searchStr, queryExp string, listIDs []int, subStatus string) error {
+	err := c.q.ExecSubQueryTpl(searchStr, sanitizeSQLExp(queryExp), c.q.TrashSubscribersByQuery, listIDs, c.db, subStatus)
+	if err != nil {
+		c.log.Printf("error trashing subscribers: %v", err)
+		return echo.NewHTTPError(http.StatusInternalServerError,
+			c.i18n.Ts("globals.messages.errorDeleting", "name", "{globals.terms.subscribers}", "error", pqErrMsg(err)))
+	}
+
+	return nil
+}
+
+// TrashLists moves the given lists to the trash.
+func (c *Core) TrashLists(ids []int) error {
+	if _, err := c.q.TrashLists.Exec(pq.Array(ids)); err != nil {
+		c.log.Printf("error trashing lists: %v", err)
+		return echo.NewHTTPError(http.StatusInternalServerError,
+			c.i18n.Ts("globals.messages.errorDeleting", "name", "{globals.terms.list}", "error", pqErrMsg(err)))
+	}
+
+	return nil
+}
+
+// TrashCampaign moves a campaign to the trash. A running or scheduled campaign is paused.
+func (c *Core) TrashCampaign(id int) error {
+	res, err := c.q.TrashCampaign.Exec(id)
+	if err != nil {
+		c.log.Printf("error trashing campaign: %v", err)
+		return echo.NewHTTPError(http.StatusInternalServerError,
+			c.i18n.Ts("globals.messages.errorDeleting", "name", "{globals.terms.campaign}", "error", pqErrMsg(err)))
+	}
+
+	if n, _ := res.RowsAffected(); n == 0 {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			c.i18n.Ts("globals.messages.notFound", "name", "{globals.terms.campaign}"))
+	}
+
+	return nil
+}
+
+// QueryTrash retrieves the paginated trashed subscribers, lists or campaigns (typ),
+// and the total number of them.
+func (c *Core) QueryTrash(typ string, offset, limit int) ([]models.TrashItem, int, error) {
+	out := []models.TrashItem{}
+	if err := c.q.QueryTrash.Select(&out, typ, offset, limit); err != nil {
+		c.log.Printf("error fetching trash: %v", err)
+		return nil, 0, echo.NewHTTPError(http.StatusInternalServerError,
+			c.i18n.Ts("globals.messages.errorFetching", "name", "{globals.terms."+typ+"}", "error", pqErrMsg(err)))
+	}
+
+	total := 0
+	if len(out) > 0 {
+		total = out[0].Total
+	}
+
+	return out, total, nil
+}
+
+// RestoreTrash restores trashed subscribers, lists or campaigns (typ) and returns
+// the number of items restored.
+func (c *Core) RestoreTrash(typ string, ids []int) (int, error) {
+	var n int
+	if err := c.q.RestoreTrash.Get(&n, typ, pq.Array(ids)); err != nil {
+		c.log.Printf("error restoring %s: %v", typ, err)
+		return 0, echo.NewHTTPError(http.StatusInternalServerError,
+			c.i18n.Ts("globals.messages.errorUpdating", "name", "{globals.terms."+typ+"}", "error", pqErrMsg(err)))
+	}
+
+	return n, nil
+}
+
+// PurgeTrash permanently deletes trashed subscribers, lists or campaigns (typ) by ID,
+// or all of them if all is true, that were trashed before the given time, and returns
+// the number of items deleted.
+func (c *Core) PurgeTrash(typ string, ids []int, all bool, before time.Time) (int, error) {
+	if ids == nil {
+		ids = []int{}
+	}
+
+	var n int
+	if err := c.q.PurgeTrash.Get(&n, typ, pq.Array(ids), all, before); err != nil {
+		c.log.Printf("error purging %s: %v", typ, err)
+		return 0, echo.NewHTTPError(http.StatusInternalServerError,
+			c.i18n.Ts("globals.messages.errorDeleting", "name", "{globals.terms."+typ+"}", "error", pqErrMsg(err)))
+	}
+
+	return n, nil
+}
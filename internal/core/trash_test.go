@@ -0,0 +1,206 @@
+package core
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/knadh/listmonk/internal/i18n"
+	"github.com/knadh/listmonk/models"
+	"github.com/labstack/echo/v4"
+)
+
+// fakeDB is a database/sql driver that records the statements executed on it,
+// which are query names, with their args, and returns the given results.
+type fakeDB struct {
+	calls [][]driver.Value
+
+	// Results by statement name: the rows affected for Exec or the
+	// single value for Query, or an error.
+	res map[string]any
+}
+
+type fakeConn struct{ db *fakeDB }
+type fakeStmt struct {
+	db   *fakeDB
+	name string
+}
+type fakeRows struct {
+	val  driver.Value
+	done bool
+}
+
+func (d *fakeDB) Open(string) (driver.Conn, error)             { return &fakeConn{d}, nil }
+func (d *fakeDB) Connect(context.Context) (driver.Conn, error) { return &fakeConn{d}, nil }
+func (d *fakeDB) Driver() driver.Driver                        { return d }
+
+func (c *fakeConn) Prepare(q string) (driver.Stmt, error) { return &fakeStmt{c.db, q}, nil }
+func (c *fakeConn) Close() error                          { return nil }
+func (c *fakeConn) Begin() (driver.Tx, error)             { return nil, errors.New("not supported") }
+
+func (s *fakeStmt) Close() error  { return nil }
+func (s *fakeStmt) NumInput() int { return -1 }
+
+func (s *fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	s.db.calls = append(s.db.calls, append([]driver.Value{s.name}, args...))
+	switch r := s.db.res[s.name].(type) {
+	case error:
+		return nil, r
+	case int:
+		return driver.RowsAffected(r), nil
+	}
+	return driver.RowsAffected(0), nil
+}
+
+func (s *fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	s.db.calls = append(s.db.calls, append([]driver.Value{s.name}, args...))
+	switch r := s.db.res[s.name].(type) {
+	case error:
+		return nil, r
+	case int:
+		return &fakeRows{val: int64(r)}, nil
+	}
+	return &fakeRows{val: int64(0)}, nil
+}
+
+func (r *fakeRows) Columns() []string { return []string{"count"} }
+func (r *fakeRows) Close() error      { return nil }
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.done {
+		return io.EOF
+	}
+	r.done = true
+	dest[0] = r.val
+	return nil
+}
+
+// newTestCore returns a Core with the trash queries on a fakeDB.
+func newTestCore(t *testing.T, res map[string]any) (*Core, *fakeDB) {
+	t.Helper()
+
+	d := &fakeDB{res: res}
+	db := sqlx.NewDb(sql.OpenDB(d), "postgres")
+
+	q := &models.Queries{}
+	for name, stmt := range map[string]**sqlx.Stmt{
+		"trash-subscribers": &q.TrashSubscribers,
+		"trash-lists":       &q.TrashLists,
+		"trash-campaign":    &q.TrashCampaign,
+		"restore-trash":     &q.RestoreTrash,
+		"purge-trash":       &q.PurgeTrash,
+	} {
+		s, err := db.Preparex(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		*stmt = s
+	}
+
+	i, err := i18n.New([]byte(`{"_.code": "en", "_.name": "English"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return New(&Opt{I18n: i, DB: db, Queries: q, Log: log.New(io.Discard, "", 0)}, &Hooks{}), d
+}
+
+func TestTrash(t *testing.T) {
+	before := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	t.Run("trash", func(t *testing.T) {
+		co, d := newTestCore(t, map[string]any{"trash-campaign": 1})
+
+		// Arrays aren't sent as NULL as they wouldn't match anything.
+		if err := co.TrashSubscribers([]int{1, 2}, nil); err != nil {
+			t.Fatal(err)
+		}
+		if err := co.TrashSubscribers(nil, []string{"a"}); err != nil {
+			t.Fatal(err)
+		}
+		if err := co.TrashLists([]int{3}); err != nil {
+			t.Fatal(err)
+		}
+		if err := co.TrashCampaign(4); err != nil {
+			t.Fatal(err)
+		}
+
+		exp := [][]driver.Value{
+			{"trash-subscribers", "{1,2}", "{}"},
+			{"trash-subscribers", "{}", `{"a"}`},
+			{"trash-lists", "{3}"},
+			{"trash-campaign", int64(4)},
+		}
+		if !reflect.DeepEqual(d.calls, exp) {
+			t.Errorf("expected %v, got %v", exp, d.calls)
+		}
+	})
+
+	t.Run("trash missing campaign", func(t *testing.T) {
+		co, _ := newTestCore(t, map[string]any{"trash-campaign": 0})
+
+		// Campaigns that don't exist or are already in the trash.
+		err := co.TrashCampaign(4)
+		if e, ok := err.(*echo.HTTPError); !ok || e.Code != http.StatusBadRequest {
+			t.Errorf("expected bad request, got %v", err)
+		}
+	})
+
+	t.Run("restore", func(t *testing.T) {
+		co, d := newTestCore(t, map[string]any{"restore-trash": 2})
+
+		n, err := co.RestoreTrash(models.TrashLists, []int{1, 2, 3})
+		if err != nil || n != 2 {
+			t.Fatalf("expected 2 restored, got %d: %v", n, err)
+		}
+
+		exp := [][]driver.Value{{"restore-trash", models.TrashLists, "{1,2,3}"}}
+		if !reflect.DeepEqual(d.calls, exp) {
+			t.Errorf("expected %v, got %v", exp, d.calls)
+		}
+	})
+
+	t.Run("purge", func(t *testing.T) {
+		co, d := newTestCore(t, map[string]any{"purge-trash": 5})
+
+		n, err := co.PurgeTrash(models.TrashCampaigns, []int{7}, false, before)
+		if err != nil || n != 5 {
+			t.Fatalf("expected 5 purged, got %d: %v", n, err)
+		}
+
+		// Purging everything, eg: by the retention period, without IDs.
+		if _, err := co.PurgeTrash(models.TrashSubscribers, nil, true, before); err != nil {
+			t.Fatal(err)
+		}
+
+		exp := [][]driver.Value{
+			{"purge-trash", models.TrashCampaigns, "{7}", false, before},
+			{"purge-trash", models.TrashSubscribers, "{}", true, before},
+		}
+		if !reflect.DeepEqual(d.calls, exp) {
+			t.Errorf("expected %v, got %v", exp, d.calls)
+		}
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		dbErr := errors.New("db error")
+		co, _ := newTestCore(t, map[string]any{"restore-trash": dbErr, "purge-trash": dbErr, "trash-campaign": dbErr})
+
+		for name, fn := range map[string]func() error{
+			"restore": func() error { _, err := co.RestoreTrash(models.TrashSubscribers, []int{1}); return err },
+			"purge":   func() error { _, err := co.PurgeTrash(models.TrashSubscribers, nil, true, before); return err },
+			"trash":   func() error { return co.TrashCampaign(1) },
+		} {
+			if e, ok := fn().(*echo.HTTPError); !ok || e.Code != http.StatusInternalServerError {
+				t.Errorf("%s: expected internal error, got %v", name, e)
+			}
+		}
+	})
+}
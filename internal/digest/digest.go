@@ -0,0 +1,192 @@
+// Package digest implements RSS/Atom/JSON feed driven "digest" campaigns.
+// A digest campaign is never sent by itself. Its feed is polled periodically
+// and when there are new items, a regular campaign is created from it
+// with the new items (available in the template as .Items) and started.
+package digest
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/knadh/listmonk/internal/utils"
+	"github.com/knadh/listmonk/models"
+)
+
+// Store represents the DB store for digest campaigns.
+type Store interface {
+	GetCampaignFeeds() ([]models.CampaignFeed, error)
+	GetCampaignFeed(campID int) (models.CampaignFeed, error)
+	GetCampaign(id int, uuid, archiveSlug string) (models.Campaign, error)
+	CreateFeedCampaign(digestID int, name string, items models.FeedItems, lastItemAt time.Time) (int, error)
+	UpdateCampaignFeedChecked(campID int) error
+}
+
+// Opt represents digest options.
+type Opt struct {
+	// How often feeds are checked for being due for polling.
+	Interval time.Duration
+
+	// HTTP timeout for fetching feeds. Feeds are only fetched from public addresses.
+	Timeout time.Duration
+}
+
+// Digest polls digest campaign feeds and creates campaigns from new items.
+type Digest struct {
+	opt    Opt
+	store  Store
+	client *http.Client
+	log    *log.Logger
+
+	// mu serializes Process so that the background poller and manual runs
+	// don't create campaigns for the same items.
+	mu sync.Mutex
+}
+
+// ErrNoNewItems is returned by Process when a feed has no new items.
+var ErrNoNewItems = errors.New("no new items in feed")
+
+// New returns a new instance of Digest.
+func New(opt Opt, s Store, lo *log.Logger) *Digest {
+	if opt.Interval == 0 {
+		opt.Interval = time.Minute
+	}
+	if opt.Timeout == 0 {
+		opt.Timeout = 30 * time.Second
+	}
+
+	return &Digest{
+		opt:    opt,
+		store:  s,
+		client: utils.NewPublicHTTPClient(opt.Timeout),
+		log:    lo,
+	}
+}
+
+// Run is a blocking function that periodically checks digest campaign
+// feeds that are due and processes them.
+func (d *Digest) Run() {
+	t := time.NewTicker(d.opt.Interval)
+	defer t.Stop()
+
+	for range t.C {
+		fds, err := d.store.GetCampaignFeeds()
+		if err != nil {
+			d.log.Printf("error fetching digest feeds: %v", err)
+			continue
+		}
+
+		now := time.Now()
+		for _, f := range fds {
+			if !isDue(f, now) {
+				continue
+			}
+
+			id, err := d.Process(f.CampaignID)
+			if err != nil {
+				if err != ErrNoNewItems {
+					d.log.Printf("error processing digest feed for campaign %d: %v", f.CampaignID, err)
+				}
+				continue
+			}
+
+			d.log.Printf("created campaign %d from digest campaign %d", id, f.CampaignID)
+		}
+	}
+}
+
+// Process fetches the feed of a digest campaign and if there are new items,
+// creates and starts a campaign from the digest campaign. It returns the ID of
+// the new campaign, or ErrNoNewItems.
+func (d *Digest) Process(campID int) (int, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	// Get the feed afresh as a run that was holding the lock may have just
+	// picked up the new items.
+	f, err := d.store.GetCampaignFeed(campID)
+	if err != nil {
+		return 0, err
+	}
+
+	// Broken feeds are retried in the next poll interval and not on every tick.
+	items, err := Fetch(f.URL, d.client)
+	if err != nil {
+		if err := d.store.UpdateCampaignFeedChecked(f.CampaignID); err != nil {
+			d.log.Printf("error updating digest feed for campaign %d: %v", f.CampaignID, err)
+		}
+		return 0, err
+	}
+
+	// Items newer than the last item that was sent out are new. If nothing has
+	// been sent yet, only items published after the feed was added are new.
+	var since time.Time
+	if f.LastItemAt.Valid {
+		since = f.LastItemAt.Time
+	} else if f.CreatedAt.Valid {
+		since = f.CreatedAt.Time
+	}
+
+	items = newItems(items, since, f.MaxItems)
+	if len(items) == 0 {
+		if err := d.store.UpdateCampaignFeedChecked(f.CampaignID); err != nil {
+			return 0, err
+		}
+		return 0, ErrNoNewItems
+	}
+
+	camp, err := d.store.GetCampaign(f.CampaignID, "", "")
+	if err != nil {
+		return 0, err
+	}
+
+	// Items are sorted newest first. The campaign and the feed's newest item are
+	// recorded together so that a failure can't send the items out twice.
+	name := camp.Name + " (" + time.Now().Format("2006-01-02 15:04") + ")"
+	id, err := d.store.CreateFeedCampaign(f.CampaignID, name, items, items[0].PublishedAt)
+	if err != nil {
+		return 0, err
+	}
+
+	return id, nil
+}
+
+// newItems returns up to max items (newest first) that were published after since.
+// Items without a publish date are skipped as there's no way to tell
+// whether they've been sent already.
+func newItems(items models.FeedItems, since time.Time, max int) models.FeedItems {
+	out := make(models.FeedItems, 0, len(items))
+	for _, i := range items {
+		if i.PublishedAt.IsZero() || !i.PublishedAt.After(since) {
+			continue
+		}
+		out = append(out, i)
+	}
+
+	sort.SliceStable(out, func(a, b int) bool {
+		return out[a].PublishedAt.After(out[b].PublishedAt)
+	})
+
+	if max > 0 && len(out) > max {
+		out = out[:max]
+	}
+
+	return out
+}
+
+// isDue checks whether a feed is due to be polled.
+func isDue(f models.CampaignFeed, now time.Time) bool {
+	if !f.LastCheckedAt.Valid {
+		return true
+	}
+
+	d, err := time.ParseDuration(f.PollInterval)
+	if err != nil || d <= 0 {
+		d = time.Hour
+	}
+
+	return now.Sub(f.LastCheckedAt.Time) >= d
+}
@@ -0,0 +1,188 @@
+package digest
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/knadh/listmonk/internal/utils"
+	"github.com/knadh/listmonk/models"
+	null "gopkg.in/volatiletech/null.v6"
+)
+
+// store is an in-memory Store.
+type store struct {
+	mu     sync.Mutex
+	feed   models.CampaignFeed
+	camps  []models.FeedItems
+	checks int
+}
+
+func (s *store) GetCampaignFeeds() ([]models.CampaignFeed, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return []models.CampaignFeed{s.feed}, nil
+}
+
+func (s *store) GetCampaignFeed(campID int) (models.CampaignFeed, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if campID != s.feed.CampaignID {
+		return models.CampaignFeed{}, fmt.Errorf("feed %d not found", campID)
+	}
+	return s.feed, nil
+}
+
+func (s *store) GetCampaign(id int, uuid, archiveSlug string) (models.Campaign, error) {
+	return models.Campaign{Name: "Digest"}, nil
+}
+
+func (s *store) CreateFeedCampaign(digestID int, name string, items models.FeedItems, lastItemAt time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.camps = append(s.camps, items)
+
+	id := 100 + len(s.camps)
+	s.feed.LastCheckedAt = null.TimeFrom(time.Now())
+	s.feed.LastItemAt = null.TimeFrom(lastItemAt)
+	s.feed.LastCampaignID = null.IntFrom(id)
+	return id, nil
+}
+
+func (s *store) UpdateCampaignFeedChecked(campID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.checks++
+	s.feed.LastCheckedAt = null.TimeFrom(time.Now())
+	return nil
+}
+
+func TestProcess(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, rssSample)
+	}))
+	defer srv.Close()
+
+	st := &store{feed: models.CampaignFeed{
+		CampaignID: 1,
+		URL:        srv.URL,
+		MaxItems:   10,
+		CreatedAt:  null.TimeFrom(time.Date(2006, 1, 1, 0, 0, 0, 0, time.UTC)),
+	}}
+	d := New(Opt{}, st, log.New(io.Discard, "", 0))
+
+	// The feed client refuses loopback addresses. Failed fetches are recorded
+	// as checks so that the feed isn't fetched again until it's due.
+	if _, err := d.Process(1); !errors.Is(err, utils.ErrPrivateAddr) {
+		t.Fatalf("expected private address error, got %v", err)
+	}
+	if st.checks != 1 || isDue(st.feed, time.Now()) {
+		t.Fatalf("expected the failed fetch to be recorded: %+v", st.feed)
+	}
+	d.client = srv.Client()
+
+	// Concurrent runs (eg: the poller and a manual run) should create
+	// only one campaign for the same items.
+	var (
+		wg  sync.WaitGroup
+		mu  sync.Mutex
+		ids []int
+	)
+	for range 5 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			id, err := d.Process(1)
+			if err != nil && err != ErrNoNewItems {
+				t.Error(err)
+				return
+			}
+			if err == nil {
+				mu.Lock()
+				ids = append(ids, id)
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if len(ids) != 1 || len(st.camps) != 1 {
+		t.Fatalf("expected 1 campaign, got %d", len(st.camps))
+	}
+
+	// Only the item with a date is picked up.
+	if len(st.camps[0]) != 1 || st.camps[0][0].GUID != "1" {
+		t.Errorf("unexpected items: %+v", st.camps[0])
+	}
+	if !st.feed.LastItemAt.Valid || st.feed.LastCampaignID.Int != ids[0] {
+		t.Errorf("feed not updated: %+v", st.feed)
+	}
+
+	if _, err := d.Process(2); err == nil {
+		t.Error("expected error for unknown feed")
+	}
+}
+
+func TestNewItems(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2024, 1, d, 0, 0, 0, 0, time.UTC) }
+	items := models.FeedItems{
+		{GUID: "a", PublishedAt: day(1)},
+		{GUID: "b", PublishedAt: day(3)},
+		{GUID: "c"},
+		{GUID: "d", PublishedAt: day(2)},
+		{GUID: "e", PublishedAt: day(4)},
+	}
+
+	cases := []struct {
+		since time.Time
+		max   int
+		want  string
+	}{
+		{time.Time{}, 0, "ebda"},
+		{day(1), 0, "ebd"},
+		{day(2), 0, "eb"},
+		{day(1), 2, "eb"},
+		{day(4), 0, ""},
+	}
+
+	for _, c := range cases {
+		got := ""
+		for _, i := range newItems(items, c.since, c.max) {
+			got += i.GUID
+		}
+		if got != c.want {
+			t.Errorf("since %v max %d: expected %q, got %q", c.since, c.max, c.want, got)
+		}
+	}
+}
+
+func TestIsDue(t *testing.T) {
+	now := time.Now()
+	cases := []struct {
+		checked  null.Time
+		interval string
+		want     bool
+	}{
+		{null.Time{}, "1h", true},
+		{null.TimeFrom(now.Add(-30 * time.Minute)), "1h", false},
+		{null.TimeFrom(now.Add(-time.Hour)), "1h", true},
+		{null.TimeFrom(now.Add(-10 * time.Minute)), "5m", true},
+
+		// Invalid intervals default to an hour.
+		{null.TimeFrom(now.Add(-30 * time.Minute)), "bad", false},
+		{null.TimeFrom(now.Add(-2 * time.Hour)), "", true},
+	}
+
+	for _, c := range cases {
+		f := models.CampaignFeed{LastCheckedAt: c.checked, PollInterval: c.interval}
+		if got := isDue(f, now); got != c.want {
+			t.Errorf("checked %v interval %q: expected %v, got %v", c.checked.Time, c.interval, c.want, got)
+		}
+	}
+}
@@ -0,0 +1,208 @@
+package digest
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/feeds"
+	"github.com/knadh/listmonk/models"
+)
+
+const maxFeedSize = 10 * 1024 * 1024
+
+// Date formats seen in the wild in RSS pubDate and Atom fields.
+var dateFormats = []string{
+	time.RFC1123Z,
+	time.RFC1123,
+	time.RFC3339,
+	time.RFC3339Nano,
+	time.RFC822Z,
+	time.RFC822,
+	"Mon, 2 Jan 2006 15:04:05 -0700",
+	"Mon, 2 Jan 2006 15:04:05 MST",
+	"2 Jan 2006 15:04:05 -0700",
+	"2006-01-02T15:04:05",
+	"2006-01-02",
+}
+
+// rssFeed is the minimal RSS 2.0 structure needed to read items.
+// gorilla/feeds' RssItem can't decode the namespaced content:encoded element.
+type rssFeed struct {
+	XMLName xml.Name `xml:"rss"`
+	Channel struct {
+		Items []struct {
+			Title       string `xml:"title"`
+			Link        string `xml:"link"`
+			Description string `xml:"description"`
+			Content     string `xml:"http://purl.org/rss/1.0/modules/content/ encoded"`
+			Author      string `xml:"author"`
+			Creator     string `xml:"http://purl.org/dc/elements/1.1/ creator"`
+			GUID        string `xml:"guid"`
+			PubDate     string `xml:"pubDate"`
+		} `xml:"item"`
+	} `xml:"channel"`
+}
+
+// atomFeed is the minimal Atom structure needed to read entries.
+type atomFeed struct {
+	XMLName xml.Name `xml:"feed"`
+	Entries []struct {
+		Title     string `xml:"title"`
+		ID        string `xml:"id"`
+		Updated   string `xml:"updated"`
+		Published string `xml:"published"`
+		Summary   string `xml:"summary"`
+		Content   string `xml:"content"`
+		Author    struct {
+			Name string `xml:"name"`
+		} `xml:"author"`
+		Links []struct {
+			Href string `xml:"href,attr"`
+			Rel  string `xml:"rel,attr"`
+		} `xml:"link"`
+	} `xml:"entry"`
+}
+
+// Fetch fetches the feed at the given URL and returns its items.
+func Fetch(url string, client *http.Client) (models.FeedItems, error) {
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("feed returned status %d", resp.StatusCode)
+	}
+
+	b, err := io.ReadAll(io.LimitReader(resp.Body, maxFeedSize))
+	if err != nil {
+		return nil, err
+	}
+
+	return Parse(b)
+}
+
+// Parse parses an RSS, Atom or JSON feed and returns its items.
+func Parse(b []byte) (models.FeedItems, error) {
+	b = bytes.TrimSpace(b)
+	if len(b) == 0 {
+		return nil, errors.New("empty feed")
+	}
+
+	// JSON feed.
+	if b[0] == '{' {
+		var f feeds.JSONFeed
+		if err := json.Unmarshal(b, &f); err != nil {
+			return nil, fmt.Errorf("error parsing JSON feed: %v", err)
+		}
+
+		out := make(models.FeedItems, 0, len(f.Items))
+		for _, i := range f.Items {
+			it := models.FeedItem{
+				Title:       i.Title,
+				URL:         i.Url,
+				Description: i.Summary,
+				Content:     i.ContentHTML,
+				GUID:        i.Id,
+			}
+			if it.Content == "" {
+				it.Content = i.ContentText
+			}
+			if i.PublishedDate != nil {
+				it.PublishedAt = *i.PublishedDate
+			} else if i.ModifiedDate != nil {
+				it.PublishedAt = *i.ModifiedDate
+			}
+			if i.Author != nil {
+				it.Author = i.Author.Name
+			} else if len(i.Authors) > 0 && i.Authors[0] != nil {
+				it.Author = i.Authors[0].Name
+			}
+			out = append(out, it)
+		}
+
+		return out, nil
+	}
+
+	// Atom.
+	if bytes.Contains(b, []byte("<feed")) {
+		var f atomFeed
+		if err := xml.Unmarshal(b, &f); err != nil {
+			return nil, fmt.Errorf("error parsing Atom feed: %v", err)
+		}
+
+		out := make(models.FeedItems, 0, len(f.Entries))
+		for _, e := range f.Entries {
+			it := models.FeedItem{
+				Title:       strings.TrimSpace(e.Title),
+				Description: strings.TrimSpace(e.Summary),
+				Content:     strings.TrimSpace(e.Content),
+				Author:      e.Author.Name,
+				GUID:        e.ID,
+				PublishedAt: parseDate(e.Published),
+			}
+			if it.PublishedAt.IsZero() {
+				it.PublishedAt = parseDate(e.Updated)
+			}
+			for _, l := range e.Links {
+				if l.Rel == "" || l.Rel == "alternate" {
+					it.URL = l.Href
+					break
+				}
+			}
+			out = append(out, it)
+		}
+
+		return out, nil
+	}
+
+	// RSS.
+	var f rssFeed
+	if err := xml.Unmarshal(b, &f); err != nil {
+		return nil, fmt.Errorf("error parsing RSS feed: %v", err)
+	}
+
+	out := make(models.FeedItems, 0, len(f.Channel.Items))
+	for _, i := range f.Channel.Items {
+		it := models.FeedItem{
+			Title:       strings.TrimSpace(i.Title),
+			URL:         strings.TrimSpace(i.Link),
+			Description: strings.TrimSpace(i.Description),
+			Content:     strings.TrimSpace(i.Content),
+			Author:      i.Author,
+			GUID:        strings.TrimSpace(i.GUID),
+			PublishedAt: parseDate(i.PubDate),
+		}
+		if it.Author == "" {
+			it.Author = i.Creator
+		}
+		out = append(out, it)
+	}
+
+	return out, nil
+}
+
+// parseDate attempts to parse a feed date string in the known formats.
+// It returns a zero time if the date can't be parsed.
+func parseDate(s string) time.Time {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return time.Time{}
+	}
+
+	for _, f := range dateFormats {
+		if t, err := time.Parse(f, s); err == nil {
+			return t
+		}
+	}
+
+	return time.Time{}
+}
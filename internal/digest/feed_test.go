@@ -0,0 +1,131 @@
+package digest
+
+import (
+	"testing"
+	"time"
+)
+
+const rssSample = `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0" xmlns:content="http://purl.org/rss/1.0/modules/content/" xmlns:dc="http://purl.org/dc/elements/1.1/">
+<channel>
+	<title>Blog</title>
+	<item>
+		<title> First post </title>
+		<link>https://example.com/1</link>
+		<description>Summary 1</description>
+		<content:encoded><![CDATA[<p>Content 1</p>]]></content:encoded>
+		<dc:creator>Jane</dc:creator>
+		<guid>1</guid>
+		<pubDate>Mon, 02 Jan 2006 15:04:05 +0000</pubDate>
+	</item>
+	<item>
+		<title>Second post</title>
+		<link>https://example.com/2</link>
+		<author>joe@example.com</author>
+		<pubDate>not a date</pubDate>
+	</item>
+</channel>
+</rss>`
+
+const atomSample = `<?xml version="1.0" encoding="utf-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom">
+	<title>Blog</title>
+	<entry>
+		<title>Atom post</title>
+		<id>urn:uuid:1</id>
+		<link rel="self" href="https://example.com/self"/>
+		<link href="https://example.com/atom"/>
+		<updated>2006-01-03T10:00:00Z</updated>
+		<summary>Atom summary</summary>
+		<author><name>Jane</name></author>
+	</entry>
+</feed>`
+
+const jsonSample = `{
+	"version": "https://jsonfeed.org/version/1.1",
+	"title": "Blog",
+	"items": [
+		{
+			"id": "j1",
+			"url": "https://example.com/j1",
+			"title": "JSON post",
+			"content_text": "Text",
+			"date_published": "2006-01-04T10:00:00Z",
+			"authors": [{"name": "Jane"}]
+		}
+	]
+}`
+
+func TestParse(t *testing.T) {
+	cases := []struct {
+		name    string
+		in      string
+		num     int
+		title   string
+		url     string
+		content string
+		author  string
+		date    time.Time
+	}{
+		{"rss", rssSample, 2, "First post", "https://example.com/1", "<p>Content 1</p>", "Jane",
+			time.Date(2006, 1, 2, 15, 4, 5, 0, time.UTC)},
+		{"atom", atomSample, 1, "Atom post", "https://example.com/atom", "", "Jane",
+			time.Date(2006, 1, 3, 10, 0, 0, 0, time.UTC)},
+		{"json", jsonSample, 1, "JSON post", "https://example.com/j1", "Text", "Jane",
+			time.Date(2006, 1, 4, 10, 0, 0, 0, time.UTC)},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			items, err := Parse([]byte(c.in))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(items) != c.num {
+				t.Fatalf("expected %d items, got %d", c.num, len(items))
+			}
+
+			i := items[0]
+			if i.Title != c.title || i.URL != c.url || i.Content != c.content || i.Author != c.author {
+				t.Errorf("unexpected item: %+v", i)
+			}
+			if !i.PublishedAt.Equal(c.date) {
+				t.Errorf("expected date %v, got %v", c.date, i.PublishedAt)
+			}
+		})
+	}
+
+	// Unparseable dates are zero.
+	items, _ := Parse([]byte(rssSample))
+	if !items[1].PublishedAt.IsZero() || items[1].Author != "joe@example.com" {
+		t.Errorf("unexpected item: %+v", items[1])
+	}
+
+	for _, in := range []string{"", "  ", "{bad json", "<rss><channel>"} {
+		if _, err := Parse([]byte(in)); err == nil {
+			t.Errorf("expected error for %q", in)
+		}
+	}
+}
+
+func TestParseDate(t *testing.T) {
+	want := time.Date(2024, 3, 5, 10, 30, 0, 0, time.UTC)
+	for _, in := range []string{
+		"Tue, 05 Mar 2024 10:30:00 +0000",
+		"Tue, 5 Mar 2024 10:30:00 +0000",
+		"2024-03-05T10:30:00Z",
+		"5 Mar 2024 10:30:00 +0000",
+		"2024-03-05T10:30:00",
+	} {
+		if got := parseDate(in); !got.Equal(want) {
+			t.Errorf("%q: expected %v, got %v", in, want, got)
+		}
+	}
+
+	if got := parseDate("2024-03-05"); !got.Equal(time.Date(2024, 3, 5, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected date: %v", got)
+	}
+	if !parseDate("yesterday").IsZero() || !parseDate("").IsZero() {
+		t.Error("expected zero time for invalid dates")
+	}
+}
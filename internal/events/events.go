@@ -11,7 +11,8 @@ import (
 )
 
 const (
-	TypeError = "error"
+	TypeError           = "error"
+	TypeWebhookRejected = "webhook_rejected"
 )
 
 // Event represents a single event in the system.
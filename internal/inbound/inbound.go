@@ -0,0 +1,173 @@
+// Package inbound processes e-mails received on an inbound mailbox, such as
+// replies to campaigns and unsubscribe requests sent by subscribers. Replies are
+// recorded in the subscribers' activity timelines and optionally forwarded to
+// an e-mail address or a webhook.
+package inbound
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/knadh/listmonk/internal/bounce/mailbox"
+	"github.com/knadh/listmonk/models"
+)
+
+// Mailbox represents a POP/IMAP mailbox client that can download messages
+// and pass them to a given function.
+type Mailbox interface {
+	Process(limit int, fn func(b []byte) error) error
+}
+
+// MailboxWaiter is a Mailbox that can wait for new messages, eg: with IMAP IDLE,
+// instead of the scanner sleeping for the scan interval.
+type MailboxWaiter interface {
+	Wait(d time.Duration) error
+}
+
+// Opt represents inbound processing options.
+type Opt struct {
+	MailboxType string
+	Mailbox     mailbox.Opt
+
+	// Unsubscribe the senders of "unsubscribe" replies and List-Unsubscribe
+	// mailto requests. If false, they're treated as regular replies.
+	Unsubscribe bool
+
+	// Replies are forwarded to the e-mail address and POSTed as JSON to the
+	// webhook URL, if they're set.
+	ForwardEmail   string
+	ForwardWebhook string
+	Timeout        time.Duration
+
+	RecordReplyCB  func(models.InboundMessage) error
+	UnsubscribeCB  func(models.InboundMessage) error
+	ForwardEmailCB func(to string, m models.InboundMessage) error
+}
+
+// Inbound processes e-mails received on the inbound mailbox.
+type Inbound struct {
+	opt     Opt
+	mailbox Mailbox
+	client  *http.Client
+	log     *log.Logger
+}
+
+// New returns a new instance of Inbound.
+func New(opt Opt, lo *log.Logger) (*Inbound, error) {
+	if opt.Timeout == 0 {
+		opt.Timeout = 30 * time.Second
+	}
+
+	in := &Inbound{
+		opt:    opt,
+		client: &http.Client{Timeout: opt.Timeout},
+		log:    lo,
+	}
+
+	switch opt.MailboxType {
+	case "pop":
+		in.mailbox = mailbox.NewPOP(opt.Mailbox)
+	case "imap":
+		in.mailbox = mailbox.NewIMAP(opt.Mailbox)
+	default:
+		return nil, errors.New("unknown inbound mailbox type")
+	}
+
+	return in, nil
+}
+
+// Run is a blocking function that scans the mailbox at the scan interval, or as
+// soon as new messages arrive in the IMAP IDLE mode, and processes the messages.
+func (in *Inbound) Run() {
+	for {
+		if err := in.mailbox.Process(1000, in.process); err != nil {
+			in.log.Printf("error scanning inbound mailbox: %v", err)
+		}
+
+		w, ok := in.mailbox.(MailboxWaiter)
+		if !ok {
+			time.Sleep(in.opt.Mailbox.ScanInterval)
+			continue
+		}
+
+		if err := w.Wait(in.opt.Mailbox.ScanInterval); err != nil {
+			in.log.Printf("error waiting for inbound mailbox: %v", err)
+			time.Sleep(in.opt.Mailbox.ScanInterval)
+		}
+	}
+}
+
+// process processes a single raw e-mail. Errors are only logged so that a bad
+// e-mail doesn't block the processing (and deletion) of the others.
+func (in *Inbound) process(b []byte) error {
+	m, ok, err := parseMessage(b)
+	if err != nil {
+		in.log.Printf("error parsing inbound e-mail: %v", err)
+		return nil
+	}
+	if !ok {
+		return nil
+	}
+
+	if m.Type == models.InboundTypeUnsubscribe {
+		if in.opt.Unsubscribe {
+			if err := in.opt.UnsubscribeCB(m); err != nil {
+				in.log.Printf("error processing unsubscribe request from %s: %v", m.Email, err)
+			}
+			return nil
+		}
+
+		m.Type = models.InboundTypeReply
+	}
+
+	if err := in.opt.RecordReplyCB(m); err != nil {
+		in.log.Printf("error recording reply from %s: %v", m.Email, err)
+	}
+
+	if in.opt.ForwardEmail != "" {
+		if err := in.opt.ForwardEmailCB(in.opt.ForwardEmail, m); err != nil {
+			in.log.Printf("error forwarding reply from %s: %v", m.Email, err)
+		}
+	}
+
+	if in.opt.ForwardWebhook != "" {
+		if err := in.postWebhook(m); err != nil {
+			in.log.Printf("error posting reply from %s to webhook: %v", m.Email, err)
+		}
+	}
+
+	return nil
+}
+
+// postWebhook POSTs a reply as JSON to the forward webhook.
+func (in *Inbound) postWebhook(m models.InboundMessage) error {
+	b, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, in.opt.ForwardWebhook, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := in.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+
+	return nil
+}
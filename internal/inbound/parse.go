@@ -0,0 +1,197 @@
+package inbound
+
+import (
+	"bytes"
+	"html"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/emersion/go-message"
+	"github.com/emersion/go-message/mail"
+	"github.com/knadh/listmonk/internal/bounce/mailbox"
+	"github.com/knadh/listmonk/models"
+)
+
+// maxBodySize is the maximum number of bytes read from an e-mail's body.
+const maxBodySize = 64 * 1024
+
+var (
+	// Message-Id of campaign e-mails (without the angle brackets) that replies refer to
+	// in their In-Reply-To and References headers: campUUID.subUUID.nanoseconds@domain.
+	reMessageID = regexp.MustCompile(`^([a-f0-9\-]{36})\.([a-f0-9\-]{36})\.\d+@`)
+
+	// Campaign and subscriber headers quoted in the body of a reply or a forward.
+	reCampUUID = regexp.MustCompile(`(?m)^[>\s]*` + models.EmailHeaderCampaignUUID + `:\s*([a-f0-9\-]{36})`)
+	reSubUUID  = regexp.MustCompile(`(?m)^[>\s]*` + models.EmailHeaderSubscriberUUID + `:\s*([a-f0-9\-]{36})`)
+
+	// Unsubscribe requests have one of the keywords at the beginning of the subject
+	// (after reply prefixes) or the first line of the body.
+	reUnsub = regexp.MustCompile(`(?i)^\s*((re|aw|sv|fwd?)\s*:\s*)*(unsubscribe|remove me|opt[ \-]?out)\b`)
+
+	reUUID         = regexp.MustCompile(`[a-f0-9]{8}-[a-f0-9]{4}-[a-f0-9]{4}-[a-f0-9]{4}-[a-f0-9]{12}`)
+	reMailerDaemon = regexp.MustCompile(`(?i)^(mailer-daemon|postmaster)@`)
+	reHTMLTags     = regexp.MustCompile(`(?is)<(style|script)[^>]*>.*?</(style|script)>|<[^>]+>`)
+)
+
+// parseMessage parses a raw e-mail into an inbound message. ok is false if the e-mail
+// should be ignored, eg: auto-replies, bounces and e-mails without a sender.
+//
+// The campaign and subscriber are looked up in the e-mail's X-Listmonk-* headers,
+// the Message-Id of the campaign e-mail that's replied to, the headers of the original
+// e-mail if it's attached or quoted in the body, and in the case of List-Unsubscribe
+// mailto requests, in the subject ("unsubscribe campUUID subUUID").
+func parseMessage(raw []byte) (models.InboundMessage, bool, error) {
+	e, err := message.Read(bytes.NewReader(raw))
+	if err != nil && !message.IsUnknownCharset(err) && !message.IsUnknownEncoding(err) {
+		return models.InboundMessage{}, false, err
+	}
+
+	h := mail.Header{Header: e.Header}
+
+	from, err := h.AddressList("From")
+	if err != nil || len(from) == 0 || reMailerDaemon.MatchString(from[0].Address) {
+		return models.InboundMessage{}, false, nil
+	}
+
+	// Auto-replies (eg: out of office) and delivery reports are not replies.
+	ct, _, _ := e.Header.ContentType()
+	if mailbox.IsAutoReply(e.Header) || strings.EqualFold(ct, "multipart/report") {
+		return models.InboundMessage{}, false, nil
+	}
+
+	subject, err := h.Subject()
+	if err != nil {
+		subject = h.Get("Subject")
+	}
+	msgID, _ := h.MessageID()
+
+	date, err := h.Date()
+	if err != nil || date.IsZero() {
+		date = time.Now()
+	}
+
+	m := models.InboundMessage{
+		Type:           models.InboundTypeReply,
+		Email:          strings.ToLower(strings.TrimSpace(from[0].Address)),
+		Name:           from[0].Name,
+		Subject:        strings.TrimSpace(subject),
+		MessageID:      msgID,
+		Date:           date,
+		CampaignUUID:   strings.ToLower(h.Get(models.EmailHeaderCampaignUUID)),
+		SubscriberUUID: strings.ToLower(h.Get(models.EmailHeaderSubscriberUUID)),
+		Raw:            raw,
+	}
+
+	// Read the body and the headers of the original e-mail, if it's attached.
+	var (
+		text, htm string
+		orig      *message.Header
+	)
+	if err := e.Walk(func(path []int, p *message.Entity, err error) error {
+		if p == nil {
+			return nil
+		}
+
+		typ, _, _ := p.Header.ContentType()
+		switch strings.ToLower(typ) {
+		case "text/plain", "":
+			if text == "" && !isAttachment(p.Header) {
+				b, _ := io.ReadAll(io.LimitReader(p.Body, maxBodySize))
+				text = string(b)
+			}
+		case "text/html":
+			if htm == "" && !isAttachment(p.Header) {
+				b, _ := io.ReadAll(io.LimitReader(p.Body, maxBodySize))
+				htm = string(b)
+			}
+		case "message/rfc822", "message/global", "text/rfc822-headers":
+			if om, err := message.Read(io.LimitReader(p.Body, maxBodySize)); om != nil && (err == nil || message.IsUnknownCharset(err)) {
+				orig = &om.Header
+			}
+		}
+
+		return nil
+	}); err != nil && !message.IsUnknownCharset(err) && !message.IsUnknownEncoding(err) {
+		return models.InboundMessage{}, false, err
+	}
+
+	if text == "" && htm != "" {
+		text = htmlToText(htm)
+	}
+	m.Body = strings.TrimSpace(strings.ReplaceAll(text, "\r\n", "\n"))
+
+	// The Message-Id of the campaign e-mail that's replied to.
+	if m.CampaignUUID == "" || m.SubscriberUUID == "" {
+		for _, k := range []string{"In-Reply-To", "References"} {
+			ids, _ := h.MsgIDList(k)
+			for _, id := range ids {
+				if u := reMessageID.FindStringSubmatch(strings.ToLower(id)); u != nil {
+					m.CampaignUUID, m.SubscriberUUID = u[1], u[2]
+					break
+				}
+			}
+			if m.CampaignUUID != "" {
+				break
+			}
+		}
+	}
+
+	// The attached original e-mail.
+	if orig != nil {
+		if m.CampaignUUID == "" {
+			m.CampaignUUID = strings.ToLower(orig.Get(models.EmailHeaderCampaignUUID))
+		}
+		if m.SubscriberUUID == "" {
+			m.SubscriberUUID = strings.ToLower(orig.Get(models.EmailHeaderSubscriberUUID))
+		}
+	}
+
+	// Headers quoted in the body.
+	if m.CampaignUUID == "" {
+		if u := reCampUUID.FindStringSubmatch(m.Body); u != nil {
+			m.CampaignUUID = u[1]
+		}
+	}
+	if m.SubscriberUUID == "" {
+		if u := reSubUUID.FindStringSubmatch(m.Body); u != nil {
+			m.SubscriberUUID = u[1]
+		}
+	}
+
+	// Unsubscribe request?
+	firstLine, _, _ := strings.Cut(m.Body, "\n")
+	if reUnsub.MatchString(m.Subject) || reUnsub.MatchString(firstLine) {
+		m.Type = models.InboundTypeUnsubscribe
+
+		// List-Unsubscribe mailto requests have the UUIDs in the subject.
+		if u := reUUID.FindAllString(strings.ToLower(m.Subject), 2); len(u) == 2 {
+			m.CampaignUUID, m.SubscriberUUID = u[0], u[1]
+			m.ListUnsubscribe = true
+		}
+	}
+
+	if !reUUID.MatchString(m.CampaignUUID) {
+		m.CampaignUUID = ""
+	}
+	if !reUUID.MatchString(m.SubscriberUUID) {
+		m.SubscriberUUID = ""
+	}
+
+	return m, true, nil
+}
+
+// isAttachment checks whether a part is an attachment and not the body.
+func isAttachment(h message.Header) bool {
+	disp, _, _ := h.ContentDisposition()
+	return strings.EqualFold(disp, "attachment")
+}
+
+// htmlToText returns the text in an HTML body.
+func htmlToText(s string) string {
+	s = strings.NewReplacer("<br>", "\n", "<br/>", "\n", "<br />", "\n", "</p>", "\n", "</div>", "\n").Replace(s)
+	s = reHTMLTags.ReplaceAllString(s, "")
+
+	return html.UnescapeString(s)
+}
@@ -0,0 +1,227 @@
+package inbound
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/knadh/listmonk/internal/manager"
+	"github.com/knadh/listmonk/models"
+)
+
+const (
+	testCampUUID = "0c4d2e6f-1a2b-4c3d-8e9f-0a1b2c3d4e5f"
+	testSubUUID  = "9f8e7d6c-5b4a-4392-8172-6a5b4c3d2e1f"
+)
+
+// msg returns a raw e-mail with the given headers (one per line) and body.
+func msg(hdrs, body string) []byte {
+	return []byte(strings.ReplaceAll(strings.TrimSpace(hdrs), "\n", "\r\n") + "\r\n\r\n" + body)
+}
+
+func TestParseMessage(t *testing.T) {
+	cases := []struct {
+		name     string
+		raw      []byte
+		ok       bool
+		typ      string
+		email    string
+		campUUID string
+		subUUID  string
+		body     string
+	}{
+		{
+			name: "reply by message ID",
+			raw: msg(`From: "Jane Doe" <Jane@Example.com>
+Subject: Re: Newsletter
+In-Reply-To: <`+testCampUUID+`.`+testSubUUID+`.1700000000000000000@listmonk.example.com>`,
+				"Thanks!\r\n\r\n> quoted"),
+			ok: true, typ: models.InboundTypeReply, email: "jane@example.com",
+			campUUID: testCampUUID, subUUID: testSubUUID, body: "Thanks!\n\n> quoted",
+		},
+		{
+			name: "reply by references",
+			raw: msg(`From: jane@example.com
+Subject: Re: Newsletter
+References: <other@example.com> <`+testCampUUID+`.`+testSubUUID+`.1@listmonk.example.com>`, "Hi"),
+			ok: true, typ: models.InboundTypeReply, email: "jane@example.com",
+			campUUID: testCampUUID, subUUID: testSubUUID, body: "Hi",
+		},
+		{
+			name: "listmonk headers",
+			raw: msg(`From: jane@example.com
+Subject: Hello
+X-Listmonk-Campaign: `+strings.ToUpper(testCampUUID)+`
+X-Listmonk-Subscriber: `+testSubUUID, "Hi"),
+			ok: true, typ: models.InboundTypeReply, email: "jane@example.com",
+			campUUID: testCampUUID, subUUID: testSubUUID, body: "Hi",
+		},
+		{
+			name: "headers quoted in a forward",
+			raw: msg(`From: jane@example.com
+Subject: Fwd: Newsletter`, "See below.\r\n\r\n> X-Listmonk-Campaign: "+testCampUUID+"\r\n> X-Listmonk-Subscriber: "+testSubUUID+"\r\n"),
+			ok: true, typ: models.InboundTypeReply, email: "jane@example.com",
+			campUUID: testCampUUID, subUUID: testSubUUID, body: "See below.\n\n> X-Listmonk-Campaign: " + testCampUUID + "\n> X-Listmonk-Subscriber: " + testSubUUID,
+		},
+		{
+			name: "attached original",
+			raw: msg(`From: jane@example.com
+Subject: Fwd: Newsletter
+Content-Type: multipart/mixed; boundary=b`, "--b\r\nContent-Type: text/plain\r\n\r\nFYI\r\n"+
+				"--b\r\nContent-Type: message/rfc822\r\nContent-Disposition: attachment\r\n\r\n"+
+				"X-Listmonk-Campaign: "+testCampUUID+"\r\nX-Listmonk-Subscriber: "+testSubUUID+"\r\nSubject: Newsletter\r\n\r\nHello\r\n--b--\r\n"),
+			ok: true, typ: models.InboundTypeReply, email: "jane@example.com",
+			campUUID: testCampUUID, subUUID: testSubUUID, body: "FYI",
+		},
+		{
+			name: "HTML only",
+			raw: msg(`From: jane@example.com
+Subject: Re: Newsletter
+Content-Type: text/html`, "<style>p{}</style><p>Thanks &amp; bye</p><div>Jane</div>"),
+			ok: true, typ: models.InboundTypeReply, email: "jane@example.com", body: "Thanks & bye\nJane",
+		},
+		{
+			name: "invalid UUIDs are dropped",
+			raw: msg(`From: jane@example.com
+Subject: Hello
+X-Listmonk-Campaign: xyz`, "Hi"),
+			ok: true, typ: models.InboundTypeReply, email: "jane@example.com", body: "Hi",
+		},
+		{
+			name: "unsubscribe subject",
+			raw: msg(`From: jane@example.com
+Subject: RE: Unsubscribe
+In-Reply-To: <`+testCampUUID+`.`+testSubUUID+`.1@listmonk.example.com>`, "Please"),
+			ok: true, typ: models.InboundTypeUnsubscribe, email: "jane@example.com",
+			campUUID: testCampUUID, subUUID: testSubUUID, body: "Please",
+		},
+		{
+			name: "unsubscribe body",
+			raw: msg(`From: jane@example.com
+Subject: Re: Newsletter`, "opt-out please\r\n\r\n> Unsubscribe here"),
+			ok: true, typ: models.InboundTypeUnsubscribe, email: "jane@example.com", body: "opt-out please\n\n> Unsubscribe here",
+		},
+		{
+			name: "unsubscribe in a quote isn't a request",
+			raw: msg(`From: jane@example.com
+Subject: Re: Newsletter`, "Great issue!\r\n> unsubscribe"),
+			ok: true, typ: models.InboundTypeReply, email: "jane@example.com", body: "Great issue!\n> unsubscribe",
+		},
+		{
+			name: "words starting with a keyword",
+			raw: msg(`From: jane@example.com
+Subject: Unsubscribed lists`, "Hi"),
+			ok: true, typ: models.InboundTypeReply, email: "jane@example.com", body: "Hi",
+		},
+		{
+			name: "auto-reply",
+			raw: msg(`From: jane@example.com
+Subject: Out of office
+Auto-Submitted: auto-replied`, "Away"),
+		},
+		{
+			name: "mailer daemon",
+			raw: msg(`From: MAILER-DAEMON@example.com
+Subject: Undelivered Mail`, "Failed"),
+		},
+		{
+			name: "delivery report",
+			raw: msg(`From: reports@example.com
+Subject: Delivery Status Notification
+Content-Type: multipart/report; report-type=delivery-status; boundary=b`, "--b\r\n\r\nFailed\r\n--b--\r\n"),
+		},
+		{
+			name: "no sender",
+			raw:  msg(`Subject: Hello`, "Hi"),
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			m, ok, err := parseMessage(c.raw)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ok != c.ok {
+				t.Fatalf("expected ok=%v, got %v: %+v", c.ok, ok, m)
+			}
+			if !ok {
+				return
+			}
+
+			if m.Type != c.typ || m.Email != c.email || m.Body != c.body {
+				t.Errorf("expected %s %s %q, got %s %s %q", c.typ, c.email, c.body, m.Type, m.Email, m.Body)
+			}
+			if m.CampaignUUID != c.campUUID || m.SubscriberUUID != c.subUUID {
+				t.Errorf("expected UUIDs %q %q, got %q %q", c.campUUID, c.subUUID, m.CampaignUUID, m.SubscriberUUID)
+			}
+			if m.ListUnsubscribe {
+				t.Error("unexpected List-Unsubscribe request")
+			}
+		})
+	}
+}
+
+func TestHTMLToText(t *testing.T) {
+	cases := []struct {
+		in, want string
+	}{
+		{"<p>a</p><p>b</p>", "a\nb\n"},
+		{"a<br>b<br/>c<br />d", "a\nb\nc\nd"},
+		{"<script>x()</script><b>bold</b> &lt;tag&gt;", "bold <tag>"},
+		{"<STYLE type='text/css'>\np {}\n</STYLE>text", "text"},
+	}
+
+	for _, c := range cases {
+		if got := htmlToText(c.in); got != c.want {
+			t.Errorf("%q: expected %q, got %q", c.in, c.want, got)
+		}
+	}
+}
+
+// TestListUnsubscribe checks that the mailto requests sent by e-mail clients from the
+// List-Unsubscribe header of campaign e-mails are unsubscribe requests for the campaign.
+func TestListUnsubscribe(t *testing.T) {
+	oneClick := "https://listmonk.example.com/subscription/unsubscribe/%s/%s"
+
+	cases := []struct {
+		name     string
+		campUUID string
+	}{
+		{"campaign", testCampUUID},
+		{"opt-in", "00000000-0000-0000-0000-000000000000"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			hdr := manager.ListUnsubscribe(oneClick, "unsub@example.com", c.campUUID, testSubUUID)
+
+			want := "<https://listmonk.example.com/subscription/unsubscribe/" + c.campUUID + "/" + testSubUUID + ">, " +
+				"<mailto:unsub@example.com?subject=unsubscribe%20" + c.campUUID + "%20" + testSubUUID + ">"
+			if hdr != want {
+				t.Fatalf("expected %s, got %s", want, hdr)
+			}
+
+			// The e-mail client sends the mailto URI's subject.
+			_, mailto, _ := strings.Cut(hdr, "<mailto:")
+			u, err := url.Parse("mailto:" + strings.TrimSuffix(mailto, ">"))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			m, ok, err := parseMessage(msg("From: jane@example.com\nTo: "+u.Opaque+"\nSubject: "+u.Query().Get("subject"), ""))
+			if err != nil || !ok {
+				t.Fatalf("expected a message, got %v %v", ok, err)
+			}
+			if m.Type != models.InboundTypeUnsubscribe || !m.ListUnsubscribe ||
+				m.CampaignUUID != c.campUUID || m.SubscriberUUID != testSubUUID {
+				t.Errorf("unexpected message: %+v", m)
+			}
+		})
+	}
+
+	// Without a mailto address, there's only the one-click URL.
+	if hdr := manager.ListUnsubscribe(oneClick, "", testCampUUID, testSubUUID); strings.Contains(hdr, "mailto") {
+		t.Errorf("unexpected mailto: %s", hdr)
+	}
+}
@@ -0,0 +1,304 @@
+package mailauth
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+const (
+	StatusPass = "pass"
+	StatusWarn = "warn"
+	StatusFail = "fail"
+)
+
+// Resolver looks up DNS TXT records. *net.Resolver satisfies it.
+type Resolver interface {
+	LookupTXT(ctx context.Context, name string) ([]string, error)
+}
+
+// Result is the result of checking a DNS record.
+type Result struct {
+	Status string `json:"status"`
+
+	// Name of the DNS record and its value, if it was found.
+	Name   string `json:"name"`
+	Record string `json:"record"`
+
+	// Domain is the domain the record authenticates (SPF, DKIM) and Aligned
+	// indicates whether it aligns with the From domain as required by DMARC.
+	Domain  string `json:"domain,omitempty"`
+	Aligned bool   `json:"aligned"`
+
+	Message string `json:"message"`
+}
+
+// Report is the result of checking the SPF, DKIM and DMARC records of a From domain.
+type Report struct {
+	FromDomain string   `json:"from_domain"`
+	SPF        Result   `json:"spf"`
+	DKIM       []Result `json:"dkim"`
+	DMARC      Result   `json:"dmarc"`
+
+	// Aligned indicates whether messages can pass DMARC, that is,
+	// either SPF or DKIM is valid and aligned with the From domain.
+	Aligned bool `json:"aligned"`
+}
+
+// CheckOpt represents the sending domains to check.
+type CheckOpt struct {
+	FromDomain string
+
+	// EnvelopeDomain is the domain of the envelope sender (Return-Path)
+	// that SPF applies to. Defaults to FromDomain.
+	EnvelopeDomain string
+
+	// Signers are the DKIM keys that messages are signed with.
+	Signers []*Signer
+}
+
+// Check looks up and checks the SPF, DKIM and DMARC records of the sending domains
+// and whether they align with the From domain.
+func Check(ctx context.Context, r Resolver, o CheckOpt) Report {
+	from := strings.ToLower(strings.TrimSuffix(o.FromDomain, "."))
+	env := strings.ToLower(strings.TrimSuffix(o.EnvelopeDomain, "."))
+	if env == "" {
+		env = from
+	}
+
+	out := Report{FromDomain: from, DKIM: []Result{}}
+
+	// DMARC is checked first as it has the alignment modes.
+	dmarc, tags := checkDMARC(ctx, r, from)
+	out.SPF = checkSPF(ctx, r, env)
+	out.SPF.Aligned = out.SPF.Status != StatusFail && aligned(env, from, tags["aspf"])
+	out.Aligned = out.SPF.Aligned
+
+	for _, s := range o.Signers {
+		res := checkDKIM(ctx, r, s)
+		res.Aligned = res.Status != StatusFail && aligned(s.Domain, from, tags["adkim"])
+
+		// Messages aren't signed with keys of unrelated domains.
+		if res.Status != StatusFail && !aligned(s.Domain, from, "r") {
+			res.Status, res.Message = StatusWarn, "DKIM key's domain doesn't align with the From domain. Messages from "+from+" aren't signed with it."
+		}
+		if res.Aligned {
+			out.Aligned = true
+		}
+		out.DKIM = append(out.DKIM, res)
+	}
+
+	if dmarc.Status != StatusFail && !out.Aligned {
+		dmarc.Status = StatusFail
+		dmarc.Message = "Neither SPF nor DKIM align with the From domain. Messages will fail DMARC."
+	}
+	out.DMARC = dmarc
+
+	return out
+}
+
+// checkSPF checks the SPF record of a domain.
+func checkSPF(ctx context.Context, r Resolver, domain string) Result {
+	res := Result{Name: domain, Domain: domain}
+
+	recs, err := lookup(ctx, r, domain, "v=spf1")
+	if err != nil {
+		res.Status, res.Message = StatusFail, "DNS lookup failed: "+err.Error()
+		return res
+	}
+
+	switch len(recs) {
+	case 0:
+		res.Status, res.Message = StatusFail, "No SPF record found."
+		return res
+	case 1:
+		res.Record = recs[0]
+	default:
+		res.Record = strings.Join(recs, "\n")
+		res.Status, res.Message = StatusFail, "Multiple SPF records found. There should be only one."
+		return res
+	}
+
+	// The "all" mechanism or a redirect decides what happens to unlisted servers.
+	var all string
+	for _, t := range strings.Fields(strings.ToLower(res.Record))[1:] {
+		if strings.HasPrefix(t, "redirect=") && all == "" {
+			all = "redirect"
+		}
+		if strings.TrimLeft(t, "+-~?") == "all" {
+			all = t
+		}
+	}
+
+	switch all {
+	case "-all", "~all", "redirect":
+		res.Status, res.Message = StatusPass, "SPF record found. It should list the SMTP servers that messages are sent through."
+	case "?all":
+		res.Status, res.Message = StatusWarn, "SPF record has a neutral ?all policy. Use ~all or -all."
+	case "":
+		res.Status, res.Message = StatusWarn, "SPF record has no 'all' mechanism. Add ~all or -all at the end."
+	default:
+		res.Status, res.Message = StatusFail, "SPF record allows any server to send mail (+all)."
+	}
+
+	return res
+}
+
+// checkDKIM checks that the DKIM record of a key exists and has its public key.
+func checkDKIM(ctx context.Context, r Resolver, s *Signer) Result {
+	res := Result{Name: s.DNSName(), Domain: s.Domain}
+
+	recs, err := lookup(ctx, r, s.DNSName(), "")
+	if err != nil {
+		res.Status, res.Message = StatusFail, "DNS lookup failed: "+err.Error()
+		return res
+	}
+
+	// Ignore records that aren't DKIM keys, eg: CNAME'd provider records.
+	var rec string
+	for _, v := range recs {
+		if _, ok := parseTags(v)["p"]; ok {
+			rec = v
+			break
+		}
+	}
+	if rec == "" {
+		res.Status, res.Message = StatusFail, "No DKIM record found. Publish: "+s.DNSRecord()
+		return res
+	}
+	res.Record = rec
+
+	tags := parseTags(rec)
+	k := tags["k"]
+	if k == "" {
+		k = "rsa"
+	}
+
+	switch {
+	case tags["p"] == "":
+		res.Status, res.Message = StatusFail, "DKIM key has been revoked (empty p=)."
+	case !strings.EqualFold(k, s.KeyType()):
+		res.Status, res.Message = StatusFail, "DKIM record's key type (k="+k+") doesn't match the private key ("+s.KeyType()+")."
+	case strings.ReplaceAll(tags["p"], " ", "") != s.PublicKey():
+		res.Status, res.Message = StatusFail, "DKIM record's public key doesn't match the private key. Publish: "+s.DNSRecord()
+	case strings.Contains(tags["t"], "y"):
+		res.Status, res.Message = StatusWarn, "DKIM key is in testing mode (t=y)."
+	default:
+		res.Status, res.Message = StatusPass, "DKIM record matches the private key."
+	}
+
+	return res
+}
+
+// checkDMARC checks the DMARC record of the From domain, or its organizational
+// domain, and returns its tags.
+func checkDMARC(ctx context.Context, r Resolver, from string) (Result, map[string]string) {
+	res := Result{Name: "_dmarc." + from}
+
+	recs, err := lookup(ctx, r, res.Name, "v=DMARC1")
+	if err == nil && len(recs) == 0 {
+		if org := OrgDomain(from); org != from {
+			res.Name = "_dmarc." + org
+			recs, err = lookup(ctx, r, res.Name, "v=DMARC1")
+		}
+	}
+
+	if err != nil {
+		res.Status, res.Message = StatusFail, "DNS lookup failed: "+err.Error()
+		return res, map[string]string{}
+	}
+
+	switch len(recs) {
+	case 0:
+		res.Status, res.Message = StatusFail, "No DMARC record found. Bulk senders are required to have one, eg: v=DMARC1; p=none; rua=mailto:dmarc@"+OrgDomain(from)
+		return res, map[string]string{}
+	case 1:
+		res.Record = recs[0]
+	default:
+		res.Record = strings.Join(recs, "\n")
+		res.Status, res.Message = StatusFail, "Multiple DMARC records found. There should be only one."
+		return res, map[string]string{}
+	}
+
+	tags := parseTags(res.Record)
+	switch strings.ToLower(tags["p"]) {
+	case "reject", "quarantine":
+		res.Status, res.Message = StatusPass, "DMARC policy is p="+tags["p"]+"."
+	case "none":
+		res.Status, res.Message = StatusWarn, "DMARC policy is p=none. Messages that fail DMARC are only reported."
+	default:
+		res.Status, res.Message = StatusFail, "DMARC record has no valid policy (p=)."
+	}
+
+	return res, tags
+}
+
+// lookup returns the TXT records of a name that start with prefix (case-insensitive).
+// A name that doesn't exist has no records.
+func lookup(ctx context.Context, r Resolver, name, prefix string) ([]string, error) {
+	recs, err := r.LookupTXT(ctx, name)
+	if err != nil {
+		var dErr *net.DNSError
+		if errors.As(err, &dErr) && dErr.IsNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var out []string
+	for _, v := range recs {
+		v = strings.TrimSpace(v)
+		if prefix == "" {
+			out = append(out, v)
+			continue
+		}
+
+		// The version tag should be followed by a space, semicolon or nothing.
+		if len(v) >= len(prefix) && strings.EqualFold(v[:len(prefix)], prefix) &&
+			(len(v) == len(prefix) || v[len(prefix)] == ' ' || v[len(prefix)] == ';') {
+			out = append(out, v)
+		}
+	}
+
+	return out, nil
+}
+
+// parseTags parses a "tag=value; tag=value" DKIM/DMARC record.
+func parseTags(rec string) map[string]string {
+	out := map[string]string{}
+	for _, t := range strings.Split(rec, ";") {
+		k, v, ok := strings.Cut(t, "=")
+		if !ok {
+			continue
+		}
+		out[strings.ToLower(strings.TrimSpace(k))] = strings.TrimSpace(v)
+	}
+
+	return out
+}
+
+// aligned checks whether a domain aligns with the From domain in the DMARC
+// alignment mode: "s" (strict) requires the same domain, and "r" (relaxed, default)
+// the same organizational domain.
+func aligned(domain, from, mode string) bool {
+	domain, from = strings.ToLower(domain), strings.ToLower(from)
+	if strings.EqualFold(mode, "s") {
+		return domain == from
+	}
+
+	return OrgDomain(domain) == OrgDomain(from)
+}
+
+// OrgDomain returns the organizational domain of a domain, eg: example.co.uk for
+// mail.example.co.uk.
+func OrgDomain(domain string) string {
+	d, err := publicsuffix.EffectiveTLDPlusOne(domain)
+	if err != nil {
+		return domain
+	}
+
+	return d
+}
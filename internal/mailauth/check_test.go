@@ -0,0 +1,221 @@
+package mailauth
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+)
+
+// resolver is a fake Resolver with TXT records by name. Names that aren't in it
+// don't exist and names with a nil value fail to resolve.
+type resolver map[string][]string
+
+func (r resolver) LookupTXT(_ context.Context, name string) ([]string, error) {
+	recs, ok := r[name]
+	if !ok {
+		return nil, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+	}
+	if recs == nil {
+		return nil, errors.New("server misbehaving")
+	}
+	return recs, nil
+}
+
+func TestCheckSPF(t *testing.T) {
+	cases := []struct {
+		name   string
+		recs   []string
+		status string
+	}{
+		{"fail", []string{"v=spf1 include:_spf.example.net -all"}, StatusPass},
+		{"softfail", []string{"v=spf1 ip4:192.0.2.1 ~all"}, StatusPass},
+		{"redirect", []string{"v=spf1 redirect=_spf.example.net"}, StatusPass},
+		{"redirect and all", []string{"v=spf1 redirect=_spf.example.net ?all"}, StatusWarn},
+		{"neutral", []string{"v=spf1 a mx ?all"}, StatusWarn},
+		{"no all", []string{"v=spf1 a mx"}, StatusWarn},
+		{"pass all", []string{"v=spf1 +all"}, StatusFail},
+		{"implicit pass all", []string{"v=spf1 all"}, StatusFail},
+		{"other records", []string{"google-site-verification=xyz", "v=spf10", "V=SPF1 -all"}, StatusPass},
+		{"multiple", []string{"v=spf1 -all", "v=spf1 ~all"}, StatusFail},
+		{"none", []string{"google-site-verification=xyz"}, StatusFail},
+		{"not found", []string{}, StatusFail},
+		{"lookup error", nil, StatusFail},
+	}
+
+	for _, c := range cases {
+		r := resolver{}
+		if c.name != "not found" {
+			r["example.com"] = c.recs
+		}
+
+		if res := checkSPF(context.Background(), r, "example.com"); res.Status != c.status {
+			t.Errorf("%s: expected %s, got %s: %s", c.name, c.status, res.Status, res.Message)
+		}
+	}
+}
+
+func TestCheckDKIM(t *testing.T) {
+	s := newSigner(t, "example.com", AlgoEd25519)
+
+	cases := []struct {
+		name   string
+		recs   []string
+		status string
+	}{
+		{"match", []string{s.DNSRecord()}, StatusPass},
+		{"split key", []string{"v=DKIM1; k=ed25519; p=" + s.PublicKey()[:10] + " " + s.PublicKey()[10:]}, StatusPass},
+		{"other records", []string{"xyz", s.DNSRecord()}, StatusPass},
+		{"testing", []string{s.DNSRecord() + "; t=y"}, StatusWarn},
+		{"revoked", []string{"v=DKIM1; k=ed25519; p="}, StatusFail},
+		{"key type", []string{"v=DKIM1; p=" + s.PublicKey()}, StatusFail},
+		{"other key", []string{newSigner(t, "example.com", AlgoEd25519).DNSRecord()}, StatusFail},
+		{"none", []string{"xyz"}, StatusFail},
+		{"lookup error", nil, StatusFail},
+	}
+
+	for _, c := range cases {
+		r := resolver{s.DNSName(): c.recs}
+		if res := checkDKIM(context.Background(), r, s); res.Status != c.status {
+			t.Errorf("%s: expected %s, got %s: %s", c.name, c.status, res.Status, res.Message)
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+	var (
+		key    = newSigner(t, "example.com", AlgoEd25519)
+		subKey = newSigner(t, "mail.example.com", AlgoEd25519)
+		other  = newSigner(t, "example.net", AlgoEd25519)
+
+		records = resolver{
+			"example.com":                    {"v=spf1 -all"},
+			"bounces.example.com":            {"v=spf1 -all"},
+			"open.example.com":               {"v=spf1 +all"},
+			"_dmarc.example.com":             {"v=DMARC1; p=reject"},
+			"_dmarc.strict.example.com":      {"v=DMARC1; p=quarantine; aspf=s; adkim=s"},
+			"_dmarc.none.example.com":        {"v=DMARC1; p=none"},
+			"_dmarc.nopolicy.example.com":    {"v=DMARC1; rua=mailto:a@example.com"},
+			key.DNSName():                    {key.DNSRecord()},
+			subKey.DNSName():                 {subKey.DNSRecord()},
+			other.DNSName():                  {other.DNSRecord()},
+			"revoked._domainkey.example.com": {"v=DKIM1; p="},
+		}
+	)
+
+	revoked := *key
+	revoked.Selector = "revoked"
+
+	cases := []struct {
+		name    string
+		opt     CheckOpt
+		dmarc   string
+		dmarcAt string
+		spf     bool
+		dkim    []bool
+		aligned bool
+	}{
+		{
+			name:  "spf and dkim",
+			opt:   CheckOpt{FromDomain: "Example.com.", Signers: []*Signer{key}},
+			dmarc: StatusPass, dmarcAt: "_dmarc.example.com", spf: true, dkim: []bool{true}, aligned: true,
+		},
+		{
+			name:  "org domain dmarc, relaxed",
+			opt:   CheckOpt{FromDomain: "news.example.com", EnvelopeDomain: "bounces.example.com", Signers: []*Signer{key, subKey}},
+			dmarc: StatusPass, dmarcAt: "_dmarc.example.com", spf: true, dkim: []bool{true, true}, aligned: true,
+		},
+		{
+			name:  "strict",
+			opt:   CheckOpt{FromDomain: "strict.example.com", EnvelopeDomain: "bounces.example.com", Signers: []*Signer{key}},
+			dmarc: StatusFail, dmarcAt: "_dmarc.strict.example.com", spf: false, dkim: []bool{false}, aligned: false,
+		},
+		{
+			name:  "pass all spf doesn't align",
+			opt:   CheckOpt{FromDomain: "example.com", EnvelopeDomain: "open.example.com"},
+			dmarc: StatusFail, dmarcAt: "_dmarc.example.com", spf: false, aligned: false,
+		},
+		{
+			name:  "dkim aligns without spf",
+			opt:   CheckOpt{FromDomain: "example.com", EnvelopeDomain: "open.example.com", Signers: []*Signer{key}},
+			dmarc: StatusPass, dmarcAt: "_dmarc.example.com", spf: false, dkim: []bool{true}, aligned: true,
+		},
+		{
+			name:  "revoked key",
+			opt:   CheckOpt{FromDomain: "example.com", EnvelopeDomain: "open.example.com", Signers: []*Signer{&revoked}},
+			dmarc: StatusFail, dmarcAt: "_dmarc.example.com", spf: false, dkim: []bool{false}, aligned: false,
+		},
+		{
+			name:  "unrelated key",
+			opt:   CheckOpt{FromDomain: "example.com", Signers: []*Signer{other}},
+			dmarc: StatusPass, dmarcAt: "_dmarc.example.com", spf: true, dkim: []bool{false}, aligned: true,
+		},
+		{
+			name:  "p=none",
+			opt:   CheckOpt{FromDomain: "none.example.com", EnvelopeDomain: "example.com"},
+			dmarc: StatusWarn, dmarcAt: "_dmarc.none.example.com", spf: true, aligned: true,
+		},
+		{
+			name:  "no policy",
+			opt:   CheckOpt{FromDomain: "nopolicy.example.com", EnvelopeDomain: "example.com"},
+			dmarc: StatusFail, dmarcAt: "_dmarc.nopolicy.example.com", spf: true, aligned: true,
+		},
+		{
+			name:  "no dmarc",
+			opt:   CheckOpt{FromDomain: "example.net", Signers: []*Signer{other}},
+			dmarc: StatusFail, dmarcAt: "_dmarc.example.net", spf: false, dkim: []bool{true}, aligned: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			rep := Check(context.Background(), records, c.opt)
+
+			if rep.DMARC.Status != c.dmarc || rep.DMARC.Name != c.dmarcAt {
+				t.Errorf("expected DMARC %s at %s, got %s at %s: %s", c.dmarc, c.dmarcAt, rep.DMARC.Status, rep.DMARC.Name, rep.DMARC.Message)
+			}
+			if rep.SPF.Aligned != c.spf {
+				t.Errorf("expected SPF aligned=%v, got %+v", c.spf, rep.SPF)
+			}
+			if len(rep.DKIM) != len(c.dkim) {
+				t.Fatalf("expected %d DKIM results, got %d", len(c.dkim), len(rep.DKIM))
+			}
+			for i, a := range c.dkim {
+				if rep.DKIM[i].Aligned != a {
+					t.Errorf("expected DKIM %d aligned=%v, got %+v", i, a, rep.DKIM[i])
+				}
+			}
+			if rep.Aligned != c.aligned {
+				t.Errorf("expected aligned=%v, got %v", c.aligned, rep.Aligned)
+			}
+		})
+	}
+
+	// Keys of unrelated domains are flagged as messages aren't signed with them.
+	rep := Check(context.Background(), records, CheckOpt{FromDomain: "example.com", Signers: []*Signer{other}})
+	if rep.DKIM[0].Status != StatusWarn {
+		t.Errorf("expected unrelated key warning, got %+v", rep.DKIM[0])
+	}
+}
+
+func TestAligned(t *testing.T) {
+	cases := []struct {
+		domain, from, mode string
+		ok                 bool
+	}{
+		{"example.com", "example.com", "s", true},
+		{"Example.com", "example.COM", "s", true},
+		{"mail.example.com", "example.com", "s", false},
+		{"mail.example.com", "example.com", "r", true},
+		{"mail.example.com", "news.example.com", "", true},
+		{"example.net", "example.com", "r", false},
+		{"a.example.co.uk", "b.example.co.uk", "r", true},
+		{"example.co.uk", "other.co.uk", "r", false},
+	}
+
+	for _, c := range cases {
+		if ok := aligned(c.domain, c.from, c.mode); ok != c.ok {
+			t.Errorf("%s %s %s: expected %v, got %v", c.domain, c.from, c.mode, c.ok, ok)
+		}
+	}
+}
@@ -0,0 +1,309 @@
+// Package mailauth signs e-mails with DKIM keys and checks the SPF, DKIM and
+// DMARC DNS records of sending domains.
+package mailauth
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	AlgoRSA     = "rsa-sha256"
+	AlgoEd25519 = "ed25519-sha256"
+)
+
+// signHeaders are the headers that are signed, if they're present in a message.
+// List-Unsubscribe and List-Unsubscribe-Post are to be signed for one-click
+// unsubscriptions (RFC 8058).
+var signHeaders = []string{
+	"From", "Reply-To", "Sender", "Subject", "Date", "To", "Cc", "Message-Id",
+	"In-Reply-To", "References", "Mime-Version", "Content-Type", "Content-Transfer-Encoding",
+	"List-Id", "List-Unsubscribe", "List-Unsubscribe-Post", "Feedback-Id",
+}
+
+// Signer signs e-mails with a DKIM key (RFC 6376) using the relaxed/relaxed
+// canonicalization. RSA (rsa-sha256) and Ed25519 (ed25519-sha256, RFC 8463)
+// keys are supported.
+type Signer struct {
+	Domain   string
+	Selector string
+
+	key  crypto.Signer
+	algo string
+}
+
+// NewSigner returns a new Signer for the domain and selector with a PEM encoded
+// private key (PKCS#1 RSA or PKCS#8 RSA/Ed25519).
+func NewSigner(domain, selector, privateKey string) (*Signer, error) {
+	domain = strings.ToLower(strings.TrimSpace(domain))
+	selector = strings.ToLower(strings.TrimSpace(selector))
+	if domain == "" || selector == "" {
+		return nil, errors.New("DKIM domain and selector are required")
+	}
+
+	b, _ := pem.Decode([]byte(strings.TrimSpace(privateKey)))
+	if b == nil {
+		return nil, errors.New("invalid DKIM private key: no PEM data found")
+	}
+
+	var (
+		key any
+		err error
+	)
+	switch b.Type {
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(b.Bytes)
+	case "PRIVATE KEY":
+		key, err = x509.ParsePKCS8PrivateKey(b.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported DKIM private key type: %s", b.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid DKIM private key: %v", err)
+	}
+
+	s := &Signer{Domain: domain, Selector: selector}
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		// RFC 8301: keys smaller than 1024 bits must not be used.
+		if k.N.BitLen() < 1024 {
+			return nil, errors.New("DKIM RSA keys should be at least 1024 bits")
+		}
+		s.key, s.algo = k, AlgoRSA
+	case ed25519.PrivateKey:
+		s.key, s.algo = k, AlgoEd25519
+	default:
+		return nil, errors.New("DKIM private key should be RSA or Ed25519")
+	}
+
+	return s, nil
+}
+
+// Algo returns the signing algorithm of the key.
+func (s *Signer) Algo() string {
+	return s.algo
+}
+
+// KeyType returns the key type as it appears in the DNS record (k=).
+func (s *Signer) KeyType() string {
+	if s.algo == AlgoEd25519 {
+		return "ed25519"
+	}
+	return "rsa"
+}
+
+// PublicKey returns the base64 encoded public key as it appears in the DNS record (p=).
+func (s *Signer) PublicKey() string {
+	switch k := s.key.Public().(type) {
+	case ed25519.PublicKey:
+		return base64.StdEncoding.EncodeToString(k)
+	default:
+		b, _ := x509.MarshalPKIXPublicKey(k)
+		return base64.StdEncoding.EncodeToString(b)
+	}
+}
+
+// DNSName returns the name of the key's DNS TXT record.
+func (s *Signer) DNSName() string {
+	return s.Selector + "._domainkey." + s.Domain
+}
+
+// DNSRecord returns the value of the key's DNS TXT record.
+func (s *Signer) DNSRecord() string {
+	return "v=DKIM1; k=" + s.KeyType() + "; p=" + s.PublicKey()
+}
+
+// Sign signs a raw e-mail and returns it with the DKIM-Signature header prepended.
+// Line endings are normalized to CRLF as the message is sent that way.
+func (s *Signer) Sign(msg []byte) ([]byte, error) {
+	msg = toCRLF(msg)
+
+	hdr, body := msg, []byte{}
+	if i := bytes.Index(msg, []byte("\r\n\r\n")); i >= 0 {
+		hdr, body = msg[:i+2], msg[i+4:]
+	}
+
+	bh := sha256.Sum256(canonBody(body))
+
+	// The signed headers, bottom-up if a header appears more than once.
+	var (
+		fields = parseHeader(hdr)
+		names  []string
+		signed []byte
+	)
+	for _, h := range signHeaders {
+		for i := len(fields) - 1; i >= 0; i-- {
+			if strings.EqualFold(fields[i].name, h) {
+				names = append(names, strings.ToLower(h))
+				signed = append(signed, canonHeader(fields[i].raw)...)
+				break
+			}
+		}
+	}
+	if len(names) == 0 || names[0] != "from" {
+		return nil, errors.New("message has no From header")
+	}
+
+	sig := "DKIM-Signature: v=1; a=" + s.algo + "; c=relaxed/relaxed;\r\n" +
+		" d=" + s.Domain + "; s=" + s.Selector + "; t=" + strconv.FormatInt(time.Now().Unix(), 10) + ";\r\n" +
+		" h=" + strings.Join(names, ":") + ";\r\n" +
+		" bh=" + base64.StdEncoding.EncodeToString(bh[:]) + ";\r\n" +
+		" b="
+
+	// The DKIM-Signature header with an empty b= is the last header that's hashed,
+	// without the trailing CRLF.
+	signed = append(signed, bytes.TrimSuffix(canonHeader([]byte(sig)), []byte("\r\n"))...)
+	hash := sha256.Sum256(signed)
+
+	var (
+		b   []byte
+		err error
+	)
+	switch s.algo {
+	case AlgoEd25519:
+		// RFC 8463: the SHA-256 hash of the headers is signed with PureEdDSA.
+		b, err = s.key.Sign(rand.Reader, hash[:], crypto.Hash(0))
+	default:
+		b, err = s.key.Sign(rand.Reader, hash[:], crypto.SHA256)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]byte, 0, len(sig)+len(msg)+512)
+	out = append(out, sig...)
+
+	// Fold the signature as lines shouldn't exceed 78 characters.
+	b64 := base64.StdEncoding.EncodeToString(b)
+	for len(b64) > 72 {
+		out = append(out, b64[:72]...)
+		out = append(out, "\r\n  "...)
+		b64 = b64[72:]
+	}
+	out = append(out, b64...)
+	out = append(out, "\r\n"...)
+	out = append(out, msg...)
+
+	return out, nil
+}
+
+type headerField struct {
+	name string
+	raw  []byte
+}
+
+// parseHeader splits a raw header block into fields, with the folded lines
+// of a field kept together.
+func parseHeader(hdr []byte) []headerField {
+	var out []headerField
+	for _, line := range bytes.SplitAfter(hdr, []byte("\r\n")) {
+		if len(line) == 0 {
+			continue
+		}
+
+		// Continuation of a folded header.
+		if (line[0] == ' ' || line[0] == '\t') && len(out) > 0 {
+			out[len(out)-1].raw = append(out[len(out)-1].raw, line...)
+			continue
+		}
+
+		name, _, ok := bytes.Cut(line, []byte(":"))
+		if !ok {
+			continue
+		}
+		out = append(out, headerField{name: string(bytes.TrimSpace(name)), raw: append([]byte{}, line...)})
+	}
+
+	return out
+}
+
+// canonHeader canonicalizes a header field with the "relaxed" algorithm: the name
+// is lowercased, the value unfolded, whitespace runs reduced to a single space
+// and the whitespace around the colon and at the end removed.
+func canonHeader(raw []byte) []byte {
+	name, val, _ := bytes.Cut(raw, []byte(":"))
+
+	val = bytes.ReplaceAll(val, []byte("\r\n"), nil)
+	val = bytes.TrimSpace(collapseWSP(val))
+
+	out := make([]byte, 0, len(name)+len(val)+3)
+	out = append(out, bytes.ToLower(bytes.TrimSpace(name))...)
+	out = append(out, ':')
+	out = append(out, val...)
+	out = append(out, "\r\n"...)
+
+	return out
+}
+
+// canonBody canonicalizes a body with the "relaxed" algorithm: whitespace runs are
+// reduced to a single space, whitespace at the end of lines and empty lines at
+// the end of the body are removed.
+func canonBody(body []byte) []byte {
+	lines := bytes.Split(body, []byte("\r\n"))
+
+	out := make([]byte, 0, len(body))
+	empty := 0
+	for _, l := range lines {
+		l = bytes.TrimRight(collapseWSP(l), " ")
+		if len(l) == 0 {
+			empty++
+			continue
+		}
+
+		for ; empty > 0; empty-- {
+			out = append(out, "\r\n"...)
+		}
+		out = append(out, l...)
+		out = append(out, "\r\n"...)
+	}
+
+	return out
+}
+
+// collapseWSP reduces runs of spaces and tabs to a single space.
+func collapseWSP(b []byte) []byte {
+	out := make([]byte, 0, len(b))
+	wsp := false
+	for _, c := range b {
+		if c == ' ' || c == '\t' {
+			if !wsp {
+				out = append(out, ' ')
+			}
+			wsp = true
+			continue
+		}
+		wsp = false
+		out = append(out, c)
+	}
+
+	return out
+}
+
+// toCRLF converts bare LF line endings to CRLF.
+func toCRLF(b []byte) []byte {
+	if !bytes.Contains(b, []byte("\n")) || bytes.Count(b, []byte("\n")) == bytes.Count(b, []byte("\r\n")) {
+		return b
+	}
+
+	out := make([]byte, 0, len(b)+bytes.Count(b, []byte("\n")))
+	for i, c := range b {
+		if c == '\n' && (i == 0 || b[i-1] != '\r') {
+			out = append(out, '\r')
+		}
+		out = append(out, c)
+	}
+
+	return out
+}
@@ -0,0 +1,301 @@
+package mailauth
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// newKey returns a PEM encoded PKCS#8 private key of the algorithm.
+func newKey(t *testing.T, algo string, bits int) string {
+	t.Helper()
+
+	var (
+		key any
+		err error
+	)
+	switch algo {
+	case AlgoEd25519:
+		_, key, err = ed25519.GenerateKey(rand.Reader)
+	case AlgoRSA:
+		key, err = rsa.GenerateKey(rand.Reader, bits)
+	default:
+		key, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: b}))
+}
+
+// newSigner returns a Signer with a new key of the algorithm.
+func newSigner(t *testing.T, domain, algo string) *Signer {
+	t.Helper()
+
+	s, err := NewSigner(domain, "test", newKey(t, algo, 1024))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestNewSigner(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 1024)
+	pkcs1 := string(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(rsaKey)}))
+
+	cases := []struct {
+		name     string
+		domain   string
+		selector string
+		key      string
+		algo     string
+	}{
+		{"pkcs1 rsa", " Example.com ", "Sel", pkcs1, AlgoRSA},
+		{"pkcs8 rsa", "example.com", "sel", newKey(t, AlgoRSA, 1024), AlgoRSA},
+		{"ed25519", "example.com", "sel", newKey(t, AlgoEd25519, 0), AlgoEd25519},
+		{"small rsa key", "example.com", "sel", newKey(t, AlgoRSA, 512), ""},
+		{"ecdsa key", "example.com", "sel", newKey(t, "ecdsa", 0), ""},
+		{"no domain", "", "sel", pkcs1, ""},
+		{"no selector", "example.com", " ", pkcs1, ""},
+		{"not PEM", "example.com", "sel", "xyz", ""},
+		{"certificate", "example.com", "sel", "-----BEGIN CERTIFICATE-----\nAAAA\n-----END CERTIFICATE-----", ""},
+	}
+
+	for _, c := range cases {
+		s, err := NewSigner(c.domain, c.selector, c.key)
+		if c.algo == "" {
+			if err == nil {
+				t.Errorf("%s: expected error", c.name)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%s: %v", c.name, err)
+			continue
+		}
+		if s.Algo() != c.algo || s.DNSName() != "sel._domainkey.example.com" {
+			t.Errorf("%s: unexpected signer %s %s", c.name, s.Algo(), s.DNSName())
+		}
+	}
+}
+
+func TestCanonHeader(t *testing.T) {
+	cases := []struct {
+		in, want string
+	}{
+		// RFC 6376 3.4.5.
+		{"A: X\r\n", "a:X\r\n"},
+		{"B : Y\t\r\n\tZ  \r\n", "b:Y Z\r\n"},
+
+		{"Subject:  Hello   World \r\n", "subject:Hello World\r\n"},
+		{"Subject: Hello\r\n World\r\n", "subject:Hello World\r\n"},
+		{"To: a@example.com,\r\n  b@example.com,\r\n\tc@example.com\r\n", "to:a@example.com, b@example.com, c@example.com\r\n"},
+		{"X-Empty:\r\n", "x-empty:\r\n"},
+		{"X-Colons: a: b\r\n", "x-colons:a: b\r\n"},
+	}
+
+	for _, c := range cases {
+		if got := string(canonHeader([]byte(c.in))); got != c.want {
+			t.Errorf("%q: expected %q, got %q", c.in, c.want, got)
+		}
+	}
+}
+
+func TestCanonBody(t *testing.T) {
+	cases := []struct {
+		name, in, want string
+	}{
+		// RFC 6376 3.4.5.
+		{"rfc example", " C \r\nD \t E\r\n\r\n\r\n", " C\r\nD E\r\n"},
+
+		{"empty", "", ""},
+		{"blank lines", "\r\n\r\n", ""},
+		{"whitespace lines", " \r\n\t\r\n", ""},
+		{"trailing blank lines", "a\r\n\r\n\r\n", "a\r\n"},
+		{"no trailing CRLF", "a", "a\r\n"},
+		{"inner blank lines", "a\r\n\r\n\r\nb\r\n", "a\r\n\r\n\r\nb\r\n"},
+		{"inner whitespace line", "a\r\n \t \r\nb", "a\r\n\r\nb\r\n"},
+	}
+
+	for _, c := range cases {
+		if got := string(canonBody([]byte(c.in))); got != c.want {
+			t.Errorf("%s: expected %q, got %q", c.name, c.want, got)
+		}
+	}
+
+	// RFC 6376 3.4.4: the hash of an empty body.
+	bh := sha256.Sum256(canonBody(nil))
+	if b := base64.StdEncoding.EncodeToString(bh[:]); b != "47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU=" {
+		t.Errorf("unexpected empty body hash: %s", b)
+	}
+}
+
+// verify verifies the DKIM-Signature at the top of a signed message with the
+// public key in the signer's DNS record.
+func verify(s *Signer, msg []byte) error {
+	i := bytes.Index(msg, []byte("\r\n\r\n"))
+	if i < 0 {
+		return errors.New("no header")
+	}
+	hdr, body := msg[:i+2], msg[i+4:]
+
+	fields := parseHeader(hdr)
+	if len(fields) == 0 || !strings.EqualFold(fields[0].name, "DKIM-Signature") {
+		return errors.New("no DKIM-Signature")
+	}
+	sigField, fields := fields[0].raw, fields[1:]
+
+	_, val, _ := bytes.Cut(sigField, []byte(":"))
+	tags := parseTags(strings.Join(strings.Fields(string(val)), ""))
+	if tags["a"] != s.Algo() || tags["c"] != "relaxed/relaxed" || tags["d"] != s.Domain || tags["s"] != s.Selector {
+		return errors.New("unexpected tags")
+	}
+
+	bh := sha256.Sum256(canonBody(body))
+	if base64.StdEncoding.EncodeToString(bh[:]) != tags["bh"] {
+		return errors.New("body hash mismatch")
+	}
+
+	// The signed headers, bottom-up for repeated names.
+	var (
+		signed []byte
+		used   = map[int]bool{}
+	)
+	for _, h := range strings.Split(tags["h"], ":") {
+		for j := len(fields) - 1; j >= 0; j-- {
+			if !used[j] && strings.EqualFold(fields[j].name, h) {
+				used[j] = true
+				signed = append(signed, canonHeader(fields[j].raw)...)
+				break
+			}
+		}
+	}
+
+	// The signature header with the value of b= removed.
+	n := bytes.LastIndex(sigField, []byte("b="))
+	signed = append(signed, bytes.TrimSuffix(canonHeader(append(sigField[:n+2:n+2], "\r\n"...)), []byte("\r\n"))...)
+	hash := sha256.Sum256(signed)
+
+	sig, err := base64.StdEncoding.DecodeString(tags["b"])
+	if err != nil {
+		return err
+	}
+	pub, err := base64.StdEncoding.DecodeString(parseTags(s.DNSRecord())["p"])
+	if err != nil {
+		return err
+	}
+
+	if s.KeyType() == "ed25519" {
+		if !ed25519.Verify(ed25519.PublicKey(pub), hash[:], sig) {
+			return errors.New("invalid signature")
+		}
+		return nil
+	}
+
+	k, err := x509.ParsePKIXPublicKey(pub)
+	if err != nil {
+		return err
+	}
+	return rsa.VerifyPKCS1v15(k.(*rsa.PublicKey), crypto.SHA256, hash[:], sig)
+}
+
+func TestSign(t *testing.T) {
+	msg := "From: \"Jane\" <jane@example.com>\n" +
+		"To: john@example.net\n" +
+		"Subject: Hello\n" +
+		"  World\n" +
+		"X-Unsigned: yes\n" +
+		"Received: by example.com\n" +
+		"Subject: Second\n" +
+		"\n" +
+		"Hi  there \n\n\n"
+
+	for _, algo := range []string{AlgoRSA, AlgoEd25519} {
+		t.Run(algo, func(t *testing.T) {
+			s := newSigner(t, "example.com", algo)
+
+			signed, err := s.Sign([]byte(msg))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := verify(s, signed); err != nil {
+				t.Fatal(err)
+			}
+
+			// Lines are folded and the message follows the signature as-is, with CRLF.
+			for _, l := range strings.Split(string(signed), "\r\n") {
+				if len(l) > 78 {
+					t.Errorf("line too long: %q", l)
+				}
+			}
+			if !bytes.HasSuffix(signed, []byte(strings.ReplaceAll(msg, "\n", "\r\n"))) {
+				t.Error("message modified")
+			}
+
+			// Only the last Subject, and not the unknown headers, is signed.
+			_, val, _ := bytes.Cut(parseHeader(signed)[0].raw, []byte(":"))
+			if h := parseTags(strings.Join(strings.Fields(string(val)), ""))["h"]; h != "from:subject:to" {
+				t.Errorf("unexpected signed headers: %s", h)
+			}
+
+			// Changes that relaxed canonicalization tolerates.
+			relaxed := bytes.Replace(signed, []byte("Subject: Second"), []byte("subject:   Second "), 1)
+			relaxed = append(relaxed, "\r\n\r\n"...)
+			if err := verify(s, relaxed); err != nil {
+				t.Errorf("relaxed changes: %v", err)
+			}
+
+			// Changes that break the signature.
+			for _, b := range [][]byte{
+				bytes.Replace(signed, []byte("Second"), []byte("Third"), 1),
+				bytes.Replace(signed, []byte("Hi  there"), []byte("Ho  there"), 1),
+				bytes.Replace(signed, []byte("john@"), []byte("jim@"), 1),
+			} {
+				if err := verify(s, b); err == nil {
+					t.Error("expected verification to fail")
+				}
+			}
+
+			// Another key's signature doesn't verify.
+			if err := verify(newSigner(t, "example.com", algo), signed); err == nil {
+				t.Error("expected verification with another key to fail")
+			}
+		})
+	}
+
+	t.Run("empty body", func(t *testing.T) {
+		s := newSigner(t, "example.com", AlgoEd25519)
+		for _, m := range []string{"From: jane@example.com\r\n\r\n", "From: jane@example.com\r\n"} {
+			signed, err := s.Sign([]byte(m))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Contains(signed, []byte("bh=47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU=")) {
+				t.Errorf("%q: unexpected body hash", m)
+			}
+		}
+	})
+
+	t.Run("no from", func(t *testing.T) {
+		if _, err := newSigner(t, "example.com", AlgoEd25519).Sign([]byte("Subject: Hi\r\n\r\nHi")); err == nil {
+			t.Error("expected error")
+		}
+	})
+}
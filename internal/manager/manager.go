@@ -38,6 +38,8 @@ type Store interface {
 	GetCampaign(campID int) (*models.Campaign, error)
 	GetAttachment(mediaID int) (models.Attachment, error)
 	UpdateCampaignStatus(campID int, status string) error
+	PauseCampaign(campID int, lastSubID int, until time.Time) error
+	ResumeCampaigns() error
 	UpdateCampaignCounts(campID int, toSend int, sent int, lastSubID int) error
 	CreateLink(url string) (string, error)
 	BlocklistSubscriber(id int64) error
@@ -132,6 +134,20 @@ type Config struct {
 	RootURL               string
 	UnsubHeader           bool
 
+	// RFC 8058 one-click unsubscribe URL and the (optional) mailto address
+	// in the List-Unsubscribe header.
+	OneClickURL string
+	UnsubMailto string
+
+	// If set, campaign e-mails are sent with a Message-Id on this domain that has the
+	// campaign and subscriber UUIDs, eg: <campUUID.subUUID.nanoseconds@domain>.
+	// Replies carry it in their In-Reply-To and References headers.
+	MessageIDDomain string
+
+	// Headers (with the campaign UUID as %s) that tag campaign e-mails for the
+	// bounce webhook providers that are enabled.
+	TagHeaders map[string]string
+
 	// Interval to scan the DB for active campaign checkpoints.
 	ScanInterval time.Duration
 
@@ -406,6 +422,11 @@ func (m *Manager) scanCampaigns(tick time.Duration) {
 
 	// Periodically scan the data source for campaigns to process.
 	for range t.C {
+		// Resume campaigns that were paused on hitting a daily sending limit.
+		if err := m.store.ResumeCampaigns(); err != nil {
+			m.log.Printf("error resuming campaigns: %v", err)
+		}
+
 		ids, counts := m.getCurrentCampaigns()
 		campaigns, err := m.store.NextCampaigns(ids, counts)
 		if err != nil {
@@ -477,10 +498,19 @@ func (m *Manager) worker() {
 			h.Set(models.EmailHeaderCampaignUUID, msg.Campaign.UUID)
 			h.Set(models.EmailHeaderSubscriberUUID, msg.Subscriber.UUID)
 
+			if m.cfg.MessageIDDomain != "" {
+				h.Set(models.EmailHeaderMessageId, fmt.Sprintf("<%s.%s.%d@%s>",
+					msg.Campaign.UUID, msg.Subscriber.UUID, time.Now().UnixNano(), m.cfg.MessageIDDomain))
+			}
+
 			// Attach List-Unsubscribe headers?
 			if m.cfg.UnsubHeader {
 				h.Set("List-Unsubscribe-Post", "List-Unsubscribe=One-Click")
-				h.Set("List-Unsubscribe", `<`+msg.unsubURL+`>`)
+				h.Set("List-Unsubscribe", ListUnsubscribe(m.cfg.OneClickURL, m.cfg.UnsubMailto, msg.Campaign.UUID, msg.Subscriber.UUID))
+			}
+
+			for hdr, val := range m.cfg.TagHeaders {
+				h.Set(hdr, fmt.Sprintf(val, msg.Campaign.UUID))
 			}
 
 			// Attach any custom headers.
@@ -506,7 +536,10 @@ func (m *Manager) worker() {
 				// Mark the message as done.
 				msg.pipe.wg.Done()
 
-				if err != nil {
+				if errors.Is(err, models.ErrDailyLimit) {
+					// The messenger has reached its daily limit. Pause the campaign until the next day.
+					msg.pipe.OnDailyLimit(msg.Subscriber.ID)
+				} else if err != nil {
 					// Call the error callback, which keeps track of the error count
 					// and stops the campaign if the error count exceeds the threshold.
 					msg.pipe.OnError()
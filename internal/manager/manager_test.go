@@ -0,0 +1,274 @@
+package manager
+
+import (
+	"io"
+	"log"
+	"math/rand"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/knadh/listmonk/models"
+)
+
+// store is an in-memory Store with a single campaign that emulates the
+// checkpointing of the campaign queries.
+type store struct {
+	mu sync.Mutex
+
+	camp     models.Campaign
+	numSubs  int
+	lastID   int
+	resumeAt time.Time
+
+	// Paused campaigns are resumed when the day is up.
+	dayUp bool
+}
+
+func (s *store) NextCampaigns(currentIDs []int64, sentCounts []int64) ([]*models.Campaign, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, id := range currentIDs {
+		if int(id) == s.camp.ID {
+			s.camp.Sent += int(sentCounts[i])
+			return nil, nil
+		}
+	}
+	if s.camp.Status != models.CampaignStatusRunning {
+		return nil, nil
+	}
+
+	c := s.camp
+	return []*models.Campaign{&c}, nil
+}
+
+func (s *store) NextSubscribers(campID, limit int) ([]models.Subscriber, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.camp.Status != models.CampaignStatusRunning {
+		return nil, nil
+	}
+
+	var out []models.Subscriber
+	for id := s.lastID + 1; id <= s.numSubs && len(out) < limit; id++ {
+		out = append(out, models.Subscriber{Base: models.Base{ID: id}, Email: "sub@example.com"})
+	}
+	if len(out) > 0 {
+		s.lastID = out[len(out)-1].ID
+	}
+	return out, nil
+}
+
+func (s *store) GetCampaign(campID int) (*models.Campaign, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c := s.camp
+	return &c, nil
+}
+
+func (s *store) UpdateCampaignStatus(campID int, status string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.camp.Status = status
+	return nil
+}
+
+func (s *store) PauseCampaign(campID int, lastSubID int, until time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.lastID = lastSubID
+	if s.camp.Status == models.CampaignStatusRunning {
+		s.camp.Status = models.CampaignStatusPaused
+		s.resumeAt = until
+	}
+	return nil
+}
+
+func (s *store) ResumeCampaigns() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.camp.Status == models.CampaignStatusPaused && !s.resumeAt.IsZero() && s.dayUp {
+		s.camp.Status = models.CampaignStatusRunning
+		s.resumeAt = time.Time{}
+		s.dayUp = false
+	}
+	return nil
+}
+
+func (s *store) UpdateCampaignCounts(campID int, toSend int, sent int, lastSubID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.camp.Sent += sent
+	switch {
+	case lastSubID > 0:
+		s.lastID = lastSubID
+	case lastSubID == 0:
+		s.lastID = s.camp.ToSend
+	}
+	return nil
+}
+
+func (s *store) get() (models.Campaign, int, time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.camp, s.lastID, s.resumeAt
+}
+
+func (s *store) GetAttachment(mediaID int) (models.Attachment, error) {
+	return models.Attachment{}, nil
+}
+func (s *store) CreateLink(url string) (string, error) { return "", nil }
+func (s *store) BlocklistSubscriber(id int64) error    { return nil }
+func (s *store) DeleteSubscriber(id int64) error       { return nil }
+
+// messenger is a Messenger with a daily sending limit that records the
+// number of messages sent to each subscriber.
+type messenger struct {
+	mu    sync.Mutex
+	limit int
+	today int
+	sent  map[int]int
+}
+
+func (m *messenger) Name() string { return "email" }
+
+func (m *messenger) Push(msg models.Message) error {
+	// Let concurrent messages race for the last slots.
+	time.Sleep(time.Duration(rand.Intn(500)) * time.Microsecond)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.today >= m.limit {
+		return models.ErrDailyLimit
+	}
+	m.today++
+	m.sent[msg.Subscriber.ID]++
+	return nil
+}
+
+func (m *messenger) nextDay() {
+	m.mu.Lock()
+	m.today = 0
+	m.mu.Unlock()
+}
+
+func (m *messenger) get() map[int]int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	out := map[int]int{}
+	for k, v := range m.sent {
+		out[k] = v
+	}
+	return out
+}
+
+func (m *messenger) Flush() error { return nil }
+func (m *messenger) Close() error { return nil }
+
+func TestDailyLimit(t *testing.T) {
+	const (
+		numSubs = 100
+		limit   = 30
+	)
+
+	st := &store{
+		camp: models.Campaign{
+			Base:        models.Base{ID: 1},
+			Name:        "test",
+			Status:      models.CampaignStatusRunning,
+			Messenger:   "email",
+			ContentType: models.CampaignContentTypePlain,
+			Body:        "Hi",
+		},
+		numSubs: numSubs,
+	}
+	st.camp.ToSend = numSubs
+	msgr := &messenger{limit: limit, sent: map[int]int{}}
+
+	m := New(Config{
+		BatchSize:     7,
+		Concurrency:   4,
+		MessageRate:   1000,
+		ScanInterval:  10 * time.Millisecond,
+		ScanCampaigns: true,
+	}, st, nil, log.New(io.Discard, "", 0))
+	m.fnNotify = func(string, any) error { return nil }
+	if err := m.AddMessenger(msgr); err != nil {
+		t.Fatal(err)
+	}
+	go m.Run()
+	defer m.Close()
+
+	// wait waits for the campaign to finish or to be paused on running into the limit.
+	wait := func() models.Campaign {
+		t.Helper()
+		for start := time.Now(); time.Since(start) < 5*time.Second; time.Sleep(5 * time.Millisecond) {
+			c, _, _ := st.get()
+			if (c.Status == models.CampaignStatusPaused || c.Status == models.CampaignStatusFinished) && !m.HasRunningCampaigns() {
+				return c
+			}
+		}
+		c, _, _ := st.get()
+		t.Fatalf("expected campaign to be paused or finished, got %s", c.Status)
+		return c
+	}
+
+	days := 1
+	for ; wait().Status == models.CampaignStatusPaused; days++ {
+		if days > numSubs/limit+1 {
+			t.Fatalf("campaign didn't finish in %d days", days)
+		}
+
+		// The campaign resumes the next day from the first subscriber that wasn't sent to.
+		_, lastID, resumeAt := st.get()
+		if now := time.Now(); resumeAt.Before(now) || resumeAt.After(now.AddDate(0, 0, 1)) || resumeAt.Hour() != 0 {
+			t.Fatalf("day %d: unexpected resume time %s", days, resumeAt)
+		}
+
+		sent := msgr.get()
+		for id := 1; id <= lastID; id++ {
+			if sent[id] == 0 {
+				t.Fatalf("day %d: subscriber %d before the checkpoint %d wasn't sent to", days, id, lastID)
+			}
+		}
+		if lastID == numSubs {
+			t.Fatalf("day %d: checkpoint is past the last subscriber", days)
+		}
+
+		msgr.nextDay()
+		st.mu.Lock()
+		st.dayUp = true
+		st.mu.Unlock()
+
+		for c, _, _ := st.get(); c.Status == models.CampaignStatusPaused; c, _, _ = st.get() {
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+
+	sent := msgr.get()
+	total := 0
+	for id := 1; id <= numSubs; id++ {
+		if sent[id] == 0 {
+			t.Errorf("subscriber %d wasn't sent to", id)
+		}
+		total += sent[id]
+	}
+
+	// Messages to subscribers after the checkpoint that went out concurrently
+	// can be sent again, but there are only as many as there are workers.
+	if total-numSubs > 3*numSubs/limit {
+		t.Errorf("expected few duplicate messages, got %d", total-numSubs)
+	}
+	if c, _, _ := st.get(); c.Sent != total {
+		t.Errorf("expected sent count %d, got %d", total, c.Sent)
+	}
+}
@@ -3,6 +3,7 @@ package manager
 import (
 	"bytes"
 	"fmt"
+	"net/url"
 
 	"github.com/knadh/listmonk/models"
 )
@@ -82,3 +83,21 @@ func (m *CampaignMessage) AltBody() []byte {
 	copy(out, m.altBody)
 	return out
 }
+
+// Items returns the feed items of a campaign created from a digest campaign.
+// It is exposed in templates as {{ range .Items }}.
+func (m *CampaignMessage) Items() models.FeedItems {
+	return m.Campaign.FeedItems
+}
+
+// ListUnsubscribe returns the value of the List-Unsubscribe header with the RFC 8058
+// one-click URL and, if mailto is set, a mailto URI whose subject has the campaign
+// and subscriber UUIDs (processed by inbound e-mail processing).
+func ListUnsubscribe(oneClickURL, mailto, campUUID, subUUID string) string {
+	out := "<" + fmt.Sprintf(oneClickURL, campUUID, subUUID) + ">"
+	if mailto != "" {
+		out += ", <mailto:" + mailto + "?subject=" + url.PathEscape("unsubscribe "+campUUID+" "+subUUID) + ">"
+	}
+
+	return out
+}
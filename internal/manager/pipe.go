@@ -19,6 +19,10 @@ type pipe struct {
 	errors     atomic.Uint64
 	stopped    atomic.Bool
 	withErrors atomic.Bool
+	limited    atomic.Bool
+
+	// Lowest subscriber ID that wasn't sent to on hitting the daily limit.
+	limitID atomic.Uint64
 
 	m *Manager
 }
@@ -151,6 +155,29 @@ func (p *pipe) OnError() {
 	p.m.log.Printf("error count exceeded %d. pausing campaign %s", p.m.cfg.MaxSendErrors, p.camp.Name)
 }
 
+// OnDailyLimit stops the campaign when its messenger has reached its daily
+// sending limit so that it's paused and resumed the next day from subID,
+// the subscriber that wasn't sent to.
+func (p *pipe) OnDailyLimit(subID int) {
+	// Concurrent messages to lower IDs can also run into the limit after
+	// a higher ID took the last slot.
+	id := uint64(subID)
+	for {
+		cur := p.limitID.Load()
+		if (cur != 0 && cur <= id) || p.limitID.CompareAndSwap(cur, id) {
+			break
+		}
+	}
+
+	if p.stopped.Load() {
+		return
+	}
+
+	p.limited.Store(true)
+	p.Stop(false)
+	p.m.log.Printf("daily sending limit reached. pausing campaign %s", p.camp.Name)
+}
+
 // Stop "marks" a campaign as stopped. It doesn't actually stop the processing
 // of messages. That happens when every queued message in the campaign is processed,
 // marking .wg, the waitgroup counter as done. That triggers cleanup().
@@ -192,11 +219,35 @@ func (p *pipe) cleanup() {
 		p.m.pipesMut.Unlock()
 	}()
 
-	// Update campaign's 'sent count.
-	if err := p.m.store.UpdateCampaignCounts(p.camp.ID, 0, int(p.sent.Load()), int(p.lastID.Load())); err != nil {
+	// Update campaign's 'sent count. On hitting the daily limit, the existing
+	// checkpoint is kept (-1) and rewound when the campaign is paused below.
+	lastID := int(p.lastID.Load())
+	if p.limited.Load() {
+		lastID = -1
+	}
+	if err := p.m.store.UpdateCampaignCounts(p.camp.ID, 0, int(p.sent.Load()), lastID); err != nil {
 		p.m.log.Printf("error updating campaign counts (%s): %v", p.camp.Name, err)
 	}
 
+	// The campaign ran into the messenger's daily sending limit. Pause it
+	// until the next day when it's automatically resumed from the first
+	// subscriber that wasn't sent to. Messages to higher IDs that went out
+	// concurrently in the meantime are sent again then.
+	if p.limited.Load() {
+		var (
+			now   = time.Now()
+			until = time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, time.Local)
+		)
+		if err := p.m.store.PauseCampaign(p.camp.ID, int(p.limitID.Load())-1, until); err != nil {
+			p.m.log.Printf("error pausing campaign (%s): %v", p.camp.Name, err)
+		} else {
+			p.m.log.Printf("set campaign (%s) to %s until %s", p.camp.Name, models.CampaignStatusPaused, until.Format(time.RFC822Z))
+		}
+
+		_ = p.m.sendNotif(p.camp, models.CampaignStatusPaused, "Daily sending limit reached. Resumes at "+until.Format(time.RFC822Z))
+		return
+	}
+
 	// The campaign was auto-paused due to errors.
 	if p.withErrors.Load() {
 		if err := p.m.store.UpdateCampaignStatus(p.camp.ID, models.CampaignStatusPaused); err != nil {
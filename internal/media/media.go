@@ -29,4 +29,7 @@ type Store interface {
 	Delete(string) error
 	GetURL(string) string
 	GetBlob(string) ([]byte, error)
+
+	// Open opens the object with the given key (path) for streaming.
+	Open(string) (io.ReadCloser, error)
 }
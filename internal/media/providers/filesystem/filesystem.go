@@ -59,6 +59,12 @@ func (c *Client) GetBlob(url string) ([]byte, error) {
 	return b, err
 }
 
+// Open opens a file in the upload directory. The path can't point outside
+// the upload directory.
+func (c *Client) Open(name string) (io.ReadCloser, error) {
+	return os.Open(filepath.Join(getDir(c.opts.UploadPath), filepath.Clean("/"+name)))
+}
+
 // Delete accepts a filename and removes it from disk.
 func (c *Client) Delete(file string) error {
 	dir := getDir(c.opts.UploadPath)
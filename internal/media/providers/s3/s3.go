@@ -134,6 +134,15 @@ func (c *Client) GetBlob(uurl string) ([]byte, error) {
 	return b, nil
 }
 
+// Open accepts the key of an object in the bucket path and returns a stream
+// of it from S3.
+func (c *Client) Open(key string) (io.ReadCloser, error) {
+	return c.s3.FileDownload(simples3.DownloadInput{
+		Bucket:    c.opts.Bucket,
+		ObjectKey: c.makeBucketPath(strings.TrimPrefix(key, "/")),
+	})
+}
+
 // Delete accepts the filename of the object and deletes from S3.
 func (c *Client) Delete(name string) error {
 	err := c.s3.FileDelete(simples3.DeleteInput{
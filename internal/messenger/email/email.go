@@ -3,11 +3,15 @@ package email
 import (
 	"crypto/tls"
 	"fmt"
+	"log"
 	"math/rand"
+	"net/mail"
 	"net/smtp"
 	"net/textproto"
 	"strings"
+	"time"
 
+	"github.com/knadh/listmonk/internal/mailauth"
 	"github.com/knadh/listmonk/models"
 	"github.com/knadh/smtppool/v2"
 )
@@ -18,18 +22,62 @@ const (
 	hdrReturnPath = "Return-Path"
 	hdrBcc        = "Bcc"
 	hdrCc         = "Cc"
+
+	dateLayout = "2006-01-02"
 )
 
+// Warmup represents an IP/domain warm-up plan that caps the number of messages
+// a server sends per day and grows it on a schedule. Schedule[n] is the cap on
+// the nth day since StartDate (YYYY-MM-DD). Once the schedule is exhausted, the
+// server is considered warmed up and there is no cap.
+type Warmup struct {
+	Enabled   bool   `json:"enabled"`
+	StartDate string `json:"start_date"`
+	Schedule  []int  `json:"schedule"`
+
+	start time.Time
+}
+
+// DKIM represents the DKIM keys that a server signs messages with. A message
+// is signed with the key of its From domain (or a parent domain), or else a key
+// of the same organizational domain. Messages from other domains aren't signed.
+type DKIM struct {
+	Enabled bool      `json:"enabled"`
+	Keys    []DKIMKey `json:"keys"`
+}
+
+// DKIMKey is a PEM encoded RSA or Ed25519 private key of a domain and selector.
+type DKIMKey struct {
+	Domain     string `json:"domain"`
+	Selector   string `json:"selector"`
+	PrivateKey string `json:"private_key"`
+}
+
+// Counter keeps track of the number of messages sent by servers on warm-up
+// plans. It's shared by all instances so that the cap applies across restarts.
+type Counter interface {
+	// IncrSMTPWarmupCount increments the server's send count for the day if it's
+	// below limit. It returns false if the limit has been reached.
+	IncrSMTPWarmupCount(server string, day time.Time, limit int) (bool, error)
+
+	// DecrSMTPWarmupCount decrements the server's send count for the day,
+	// releasing the slot of a message that couldn't be sent.
+	DecrSMTPWarmupCount(server string, day time.Time) error
+}
+
 // Server represents an SMTP server's credentials.
 type Server struct {
 	// Name is a unique identifier for the server.
 	Name          string            `json:"name"`
+	UUID          string            `json:"uuid"`
 	Username      string            `json:"username"`
 	Password      string            `json:"password"`
 	AuthProtocol  string            `json:"auth_protocol"`
 	TLSType       string            `json:"tls_type"`
 	TLSSkipVerify bool              `json:"tls_skip_verify"`
 	EmailHeaders  map[string]string `json:"email_headers"`
+	Warmup        Warmup            `json:"warmup"`
+	DKIM          DKIM              `json:"dkim"`
 
 	// Rest of the options are embedded directly from the smtppool lib.
 	// The JSON tag is for config unmarshal to work.
@@ -37,26 +85,50 @@ type Server struct {
 	smtppool.Opt `json:",squash"`
 
 	pool *smtppool.Pool
+
+	// Servers with DKIM keys send through raw instead of pool.
+	signers []*mailauth.Signer
+	raw     *rawPool
 }
 
 // Emailer is the SMTP e-mail messenger.
 type Emailer struct {
 	servers []*Server
 	name    string
+	counter Counter
 }
 
 // New returns an SMTP e-mail Messenger backend with the given SMTP servers.
 // Group indicates whether the messenger represents a group of SMTP servers (1 or more)
-// that are used as a round-robin pool, or a single server.
-func New(name string, servers ...Server) (*Emailer, error) {
+// that are used as a round-robin pool, or a single server. counter is used to
+// enforce the daily caps of servers that have a warm-up plan.
+func New(name string, counter Counter, servers ...Server) (*Emailer, error) {
 	e := &Emailer{
 		servers: make([]*Server, 0, len(servers)),
 		name:    name,
+		counter: counter,
 	}
 
 	for _, srv := range servers {
 		s := srv
 
+		if s.Warmup.Enabled {
+			if len(s.Warmup.Schedule) == 0 {
+				return nil, fmt.Errorf("SMTP warm-up plan on '%s' has no schedule", s.Host)
+			}
+			for _, n := range s.Warmup.Schedule {
+				if n < 1 {
+					return nil, fmt.Errorf("invalid SMTP warm-up daily limit on '%s': %d", s.Host, n)
+				}
+			}
+
+			t, err := time.ParseInLocation(dateLayout, s.Warmup.StartDate, time.Local)
+			if err != nil {
+				return nil, fmt.Errorf("invalid SMTP warm-up start date on '%s': %v", s.Host, err)
+			}
+			s.Warmup.start = t
+		}
+
 		var auth smtp.Auth
 		switch s.AuthProtocol {
 		case "cram":
@@ -90,12 +162,31 @@ func New(name string, servers ...Server) (*Emailer, error) {
 			}
 		}
 
-		pool, err := smtppool.New(s.Opt)
-		if err != nil {
-			return nil, err
+		// DKIM keys.
+		if s.DKIM.Enabled {
+			for _, k := range s.DKIM.Keys {
+				sg, err := mailauth.NewSigner(k.Domain, k.Selector, k.PrivateKey)
+				if err != nil {
+					return nil, fmt.Errorf("error loading DKIM key '%s' on '%s': %v", k.Selector, s.Host, err)
+				}
+				s.signers = append(s.signers, sg)
+			}
+		}
+
+		if len(s.signers) > 0 {
+			raw, err := newRawPool(s.Opt)
+			if err != nil {
+				return nil, err
+			}
+			s.raw = raw
+		} else {
+			pool, err := smtppool.New(s.Opt)
+			if err != nil {
+				return nil, err
+			}
+			s.pool = pool
 		}
 
-		s.pool = pool
 		e.servers = append(e.servers, &s)
 	}
 
@@ -111,16 +202,10 @@ func (e *Emailer) Name() string {
 func (e *Emailer) Push(m models.Message) error {
 	// If there are more than one SMTP servers, send to a random
 	// one from the list.
-	var (
-		ln  = len(e.servers)
-		srv *Server
-	)
-	if ln == 0 {
-		return fmt.Errorf("no SMTP servers configured for messenger '%s'", e.name)
-	} else if ln > 1 {
-		srv = e.servers[rand.Intn(ln)]
-	} else {
-		srv = e.servers[0]
+	now := time.Now()
+	srv, err := e.pickServer(now)
+	if err != nil {
+		return err
 	}
 
 	// Are there attachments?
@@ -191,7 +276,167 @@ func (e *Emailer) Push(m models.Message) error {
 		}
 	}
 
-	return srv.pool.Send(em)
+	if srv.raw != nil {
+		err = srv.sendSigned(em)
+	} else {
+		err = srv.pool.Send(em)
+	}
+
+	// A message that couldn't be sent doesn't count towards the warm-up cap.
+	if err != nil {
+		e.release(srv, now)
+	}
+
+	return err
+}
+
+// sendSigned renders a message, signs it with the DKIM key of its From domain
+// and sends it as-is.
+func (s *Server) sendSigned(em smtppool.Email) error {
+	from, err := mail.ParseAddress(em.From)
+	if err != nil {
+		return err
+	}
+
+	sender := from.Address
+	if em.Sender != "" {
+		a, err := mail.ParseAddress(em.Sender)
+		if err != nil {
+			return err
+		}
+		sender = a.Address
+	}
+
+	var rcpts []string
+	for _, l := range [][]string{em.To, em.Cc, em.Bcc} {
+		for _, r := range l {
+			a, err := mail.ParseAddress(r)
+			if err != nil {
+				return err
+			}
+			rcpts = append(rcpts, a.Address)
+		}
+	}
+
+	b, err := em.Bytes()
+	if err != nil {
+		return err
+	}
+
+	// Messages from domains without a key are sent unsigned as a signature
+	// that doesn't align with the From domain doesn't help them pass DMARC.
+	_, domain, _ := strings.Cut(from.Address, "@")
+	if sg := s.signer(domain); sg != nil {
+		if b, err = sg.Sign(b); err != nil {
+			return fmt.Errorf("error signing message with DKIM: %v", err)
+		}
+	}
+
+	return s.raw.Send(sender, rcpts, b)
+}
+
+// signer returns the DKIM key of the domain or its closest parent domain, or else
+// a key of the same organizational domain that aligns in DMARC's relaxed mode.
+// It returns nil if there's none.
+func (s *Server) signer(domain string) *mailauth.Signer {
+	domain = strings.ToLower(domain)
+	for d := domain; d != ""; {
+		for _, sg := range s.signers {
+			if sg.Domain == d {
+				return sg
+			}
+		}
+
+		_, d, _ = strings.Cut(d, ".")
+	}
+
+	org := mailauth.OrgDomain(domain)
+	for _, sg := range s.signers {
+		if mailauth.OrgDomain(sg.Domain) == org {
+			return sg
+		}
+	}
+
+	return nil
+}
+
+// pickServer picks a random server that hasn't reached its daily warm-up cap
+// for the day of now, counting the message against the cap. If every server has
+// reached its cap, models.ErrDailyLimit is returned.
+func (e *Emailer) pickServer(now time.Time) (*Server, error) {
+	ln := len(e.servers)
+	if ln == 0 {
+		return nil, fmt.Errorf("no SMTP servers configured for messenger '%s'", e.name)
+	}
+
+	start := 0
+	if ln > 1 {
+		start = rand.Intn(ln)
+	}
+
+	for i := 0; i < ln; i++ {
+		srv := e.servers[(start+i)%ln]
+
+		limit := srv.Warmup.limit(now)
+		if limit == 0 || e.counter == nil {
+			return srv, nil
+		}
+
+		ok, err := e.counter.IncrSMTPWarmupCount(srv.key(), now, limit)
+		if err != nil {
+			return nil, fmt.Errorf("error checking SMTP warm-up limit: %v", err)
+		}
+		if ok {
+			return srv, nil
+		}
+	}
+
+	return nil, models.ErrDailyLimit
+}
+
+// release releases a message's slot in the server's daily warm-up cap that
+// was counted by pickServer.
+func (e *Emailer) release(srv *Server, now time.Time) {
+	if e.counter == nil || srv.Warmup.limit(now) == 0 {
+		return
+	}
+
+	if err := e.counter.DecrSMTPWarmupCount(srv.key(), now); err != nil {
+		log.Printf("error releasing SMTP warm-up count of '%s': %v", srv.Host, err)
+	}
+}
+
+// key returns the identifier that the server's warm-up send counts are stored against.
+func (s *Server) key() string {
+	if s.UUID != "" {
+		return s.UUID
+	}
+
+	return s.Username + "@" + s.Host
+}
+
+// limit returns the warm-up plan's cap for the day of the given time.
+// 0 indicates that there's no cap.
+func (w Warmup) limit(t time.Time) int {
+	if !w.Enabled || len(w.Schedule) == 0 {
+		return 0
+	}
+
+	// Number of calendar days since the start date. Rounding absorbs DST shifts.
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.Local)
+	n := int(day.Sub(w.start).Round(24*time.Hour) / (24 * time.Hour))
+
+	// The plan hasn't started yet. Go with the first day's cap.
+	if n < 0 {
+		n = 0
+	}
+
+	// The plan is over and the server is warmed up.
+	if n >= len(w.Schedule) {
+		return 0
+	}
+
+	return w.Schedule[n]
 }
 
 // Flush flushes the message queue to the server.
@@ -202,6 +447,10 @@ func (e *Emailer) Flush() error {
 // Close closes the SMTP pools.
 func (e *Emailer) Close() error {
 	for _, s := range e.servers {
+		if s.raw != nil {
+			s.raw.Close()
+			continue
+		}
 		s.pool.Close()
 	}
 	return nil
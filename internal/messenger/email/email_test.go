@@ -0,0 +1,349 @@
+package email
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"net/textproto"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/knadh/listmonk/models"
+	"github.com/knadh/smtppool/v2"
+)
+
+// counter is an in-memory Counter of send counts by server and day.
+type counter struct {
+	mu     sync.Mutex
+	counts map[string]int
+	err    error
+}
+
+func newCounter() *counter {
+	return &counter{counts: map[string]int{}}
+}
+
+func (c *counter) IncrSMTPWarmupCount(server string, day time.Time, limit int) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.err != nil {
+		return false, c.err
+	}
+
+	k := server + "/" + day.Format(dateLayout)
+	if c.counts[k] >= limit {
+		return false, nil
+	}
+	c.counts[k]++
+	return true, nil
+}
+
+func (c *counter) DecrSMTPWarmupCount(server string, day time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if k := server + "/" + day.Format(dateLayout); c.counts[k] > 0 {
+		c.counts[k]--
+	}
+	return nil
+}
+
+func (c *counter) get(server string, day time.Time) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.counts[server+"/"+day.Format(dateLayout)]
+}
+
+func TestWarmupLimit(t *testing.T) {
+	w := Warmup{
+		Enabled:  true,
+		Schedule: []int{10, 20, 40},
+		start:    time.Date(2024, 3, 30, 0, 0, 0, 0, time.Local),
+	}
+
+	cases := []struct {
+		t     time.Time
+		limit int
+	}{
+		{time.Date(2024, 3, 1, 12, 0, 0, 0, time.Local), 10},
+		{time.Date(2024, 3, 30, 0, 0, 0, 0, time.Local), 10},
+		{time.Date(2024, 3, 30, 23, 59, 59, 0, time.Local), 10},
+		{time.Date(2024, 3, 31, 0, 0, 0, 0, time.Local), 20},
+		{time.Date(2024, 3, 31, 23, 0, 0, 0, time.Local), 20},
+		{time.Date(2024, 4, 1, 1, 0, 0, 0, time.Local), 40},
+		{time.Date(2024, 4, 2, 0, 0, 0, 0, time.Local), 0},
+		{time.Date(2025, 1, 1, 0, 0, 0, 0, time.Local), 0},
+	}
+
+	for _, c := range cases {
+		if l := w.limit(c.t); l != c.limit {
+			t.Errorf("%s: expected %d, got %d", c.t, c.limit, l)
+		}
+	}
+
+	w.Enabled = false
+	if l := w.limit(w.start); l != 0 {
+		t.Errorf("expected no limit when disabled, got %d", l)
+	}
+}
+
+func TestPickServer(t *testing.T) {
+	var (
+		now   = time.Now()
+		today = now.Format(dateLayout)
+	)
+	warm := func(uuid string, limit int) *Server {
+		s := &Server{UUID: uuid, Warmup: Warmup{Enabled: true, StartDate: today, Schedule: []int{limit, limit}}}
+		s.Warmup.start, _ = time.ParseInLocation(dateLayout, today, time.Local)
+		return s
+	}
+
+	t.Run("caps", func(t *testing.T) {
+		c := newCounter()
+		e := &Emailer{name: "email", counter: c, servers: []*Server{warm("a", 2), warm("b", 3)}}
+
+		picked := map[string]int{}
+		for i := 0; i < 5; i++ {
+			srv, err := e.pickServer(now)
+			if err != nil {
+				t.Fatalf("message %d: %v", i, err)
+			}
+			picked[srv.UUID]++
+		}
+		if picked["a"] != 2 || picked["b"] != 3 {
+			t.Errorf("expected 2 and 3 messages on the servers, got %v", picked)
+		}
+
+		if _, err := e.pickServer(now); !errors.Is(err, models.ErrDailyLimit) {
+			t.Errorf("expected daily limit error, got %v", err)
+		}
+
+		// The next day's cap is counted separately.
+		if _, err := e.pickServer(now.AddDate(0, 0, 1)); err != nil {
+			t.Errorf("expected next day's cap to be available: %v", err)
+		}
+	})
+
+	t.Run("uncapped servers", func(t *testing.T) {
+		c := newCounter()
+		e := &Emailer{name: "email", counter: c, servers: []*Server{warm("a", 1), {UUID: "b"}}}
+
+		for i := 0; i < 10; i++ {
+			if _, err := e.pickServer(now); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if n := c.get("a", now); n > 1 {
+			t.Errorf("expected at most 1 message counted, got %d", n)
+		}
+	})
+
+	t.Run("counter error", func(t *testing.T) {
+		c := newCounter()
+		c.err = errors.New("db error")
+		e := &Emailer{name: "email", counter: c, servers: []*Server{warm("a", 1)}}
+
+		if _, err := e.pickServer(now); err == nil || errors.Is(err, models.ErrDailyLimit) {
+			t.Errorf("expected counter error, got %v", err)
+		}
+	})
+
+	t.Run("no servers", func(t *testing.T) {
+		if _, err := (&Emailer{name: "email"}).pickServer(now); err == nil {
+			t.Error("expected error")
+		}
+	})
+}
+
+func TestPushWarmup(t *testing.T) {
+	s := newSMTPServer(t, 0)
+	c := newCounter()
+
+	e, err := New("email", c, Server{
+		UUID:    "a",
+		TLSType: "none",
+		Warmup:  Warmup{Enabled: true, StartDate: time.Now().Format(dateLayout), Schedule: []int{2}},
+		Opt:     smtppool.Opt{Host: "127.0.0.1", Port: s.port, MaxConns: 1},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer e.Close()
+
+	push := func(to string) error {
+		return e.Push(models.Message{
+			From:        "jane@example.com",
+			To:          []string{to},
+			Subject:     "Hello",
+			ContentType: "plain",
+			Body:        []byte("Hi"),
+		})
+	}
+
+	// Failed messages don't use up the cap.
+	for _, to := range []string{"reject@example.net", "john@example.net", "reject@example.net", "jim@example.net"} {
+		err := push(to)
+		if (err != nil) != (to == "reject@example.net") {
+			t.Fatalf("%s: unexpected result: %v", to, err)
+		}
+	}
+	if n := c.get("a", time.Now()); n != 2 {
+		t.Errorf("expected 2 messages counted, got %d", n)
+	}
+
+	if err := push("joe@example.net"); !errors.Is(err, models.ErrDailyLimit) {
+		t.Errorf("expected daily limit error, got %v", err)
+	}
+	if n := len(s.get().msgs); n != 2 {
+		t.Errorf("expected 2 messages sent, got %d", n)
+	}
+}
+
+// smtpServer is a minimal SMTP server on a loopback port that records the
+// messages it receives and its connections.
+type smtpServer struct {
+	port int
+
+	// dropAfter is the number of messages after which connections are
+	// dropped when the next transaction starts.
+	dropAfter int
+
+	mu      sync.Mutex
+	msgs    []smtpMsg
+	conns   int
+	open    int
+	maxOpen int
+	quits   int
+}
+
+type smtpMsg struct {
+	from string
+	to   []string
+	data string
+}
+
+func newSMTPServer(t *testing.T, dropAfter int) *smtpServer {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+
+	s := &smtpServer{port: l.Addr().(*net.TCPAddr).Port, dropAfter: dropAfter}
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(c)
+		}
+	}()
+
+	return s
+}
+
+func (s *smtpServer) serve(c net.Conn) {
+	defer c.Close()
+
+	s.mu.Lock()
+	s.conns++
+	s.open++
+	s.maxOpen = max(s.maxOpen, s.open)
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		s.open--
+		s.mu.Unlock()
+	}()
+
+	var (
+		tp = textproto.NewConn(c)
+		m  smtpMsg
+		n  int
+	)
+	tp.PrintfLine("220 localhost ESMTP")
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+
+		cmd, arg, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(cmd) {
+		case "EHLO", "HELO", "NOOP":
+			tp.PrintfLine("250 OK")
+		case "MAIL":
+			if s.dropAfter > 0 && n >= s.dropAfter {
+				return
+			}
+			m = smtpMsg{from: addrArg(arg)}
+			tp.PrintfLine("250 OK")
+		case "RCPT":
+			if a := addrArg(arg); strings.HasPrefix(a, "reject@") {
+				tp.PrintfLine("550 No such user")
+			} else {
+				m.to = append(m.to, a)
+				tp.PrintfLine("250 OK")
+			}
+		case "DATA":
+			tp.PrintfLine("354 Go ahead")
+			data, err := readData(tp.R)
+			if err != nil {
+				return
+			}
+			m.data = data
+			n++
+
+			s.mu.Lock()
+			s.msgs = append(s.msgs, m)
+			s.mu.Unlock()
+			tp.PrintfLine("250 OK")
+		case "RSET":
+			m = smtpMsg{}
+			tp.PrintfLine("250 OK")
+		case "QUIT":
+			s.mu.Lock()
+			s.quits++
+			s.mu.Unlock()
+			tp.PrintfLine("221 Bye")
+			return
+		default:
+			tp.PrintfLine("502 Unknown command")
+		}
+	}
+}
+
+// readData reads the DATA of a message as-is, undoing the dot-stuffing.
+func readData(r *bufio.Reader) (string, error) {
+	var b strings.Builder
+	for {
+		l, err := r.ReadString('\n')
+		if err != nil {
+			return "", err
+		}
+		if l == ".\r\n" {
+			return b.String(), nil
+		}
+		b.WriteString(strings.TrimPrefix(l, "."))
+	}
+}
+
+// addrArg returns the address in a MAIL FROM:<...> or RCPT TO:<...> argument.
+func addrArg(arg string) string {
+	_, a, _ := strings.Cut(arg, "<")
+	a, _, _ = strings.Cut(a, ">")
+	return a
+}
+
+// get returns a copy of the server's state.
+func (s *smtpServer) get() smtpServer {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return smtpServer{msgs: append([]smtpMsg{}, s.msgs...), conns: s.conns, open: s.open, maxOpen: s.maxOpen, quits: s.quits}
+}
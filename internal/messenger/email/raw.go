@@ -0,0 +1,361 @@
+package email
+
+import (
+	"crypto/tls"
+	"errors"
+	"io"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/knadh/smtppool/v2"
+)
+
+// rawPool is a pool of SMTP connections that sends pre-rendered messages as-is.
+// It's used for servers that sign messages with DKIM as the signature covers the
+// exact bytes of a message, which smtppool otherwise renders afresh on every send.
+// It takes the same options as smtppool.
+type rawPool struct {
+	opt   smtppool.Opt
+	conns chan *rawConn
+
+	// sem limits the number of open connections to MaxConns.
+	sem chan struct{}
+
+	// borrowed are the connections that are sending messages. wg tracks them
+	// for Close to wait on.
+	mu       sync.Mutex
+	borrowed map[*rawConn]struct{}
+	wg       sync.WaitGroup
+	closed   bool
+	stop     chan struct{}
+}
+
+type rawConn struct {
+	conn         *smtp.Client
+	lastActivity time.Time
+}
+
+func newRawPool(o smtppool.Opt) (*rawPool, error) {
+	if o.MaxConns < 1 {
+		return nil, errors.New("MaxConns should be >= 1")
+	}
+	if o.MaxMessageRetries == 0 {
+		o.MaxMessageRetries = 1
+	}
+	if o.PoolWaitTimeout.Seconds() < 1 {
+		o.PoolWaitTimeout = time.Second * 2
+	}
+
+	p := &rawPool{
+		opt:      o,
+		conns:    make(chan *rawConn, o.MaxConns),
+		sem:      make(chan struct{}, o.MaxConns),
+		borrowed: make(map[*rawConn]struct{}),
+		stop:     make(chan struct{}),
+	}
+
+	// Start the idle connection sweeper.
+	if o.IdleTimeout.Seconds() >= 1 {
+		go p.sweepConns(time.Second * 2)
+	}
+
+	return p, nil
+}
+
+// Send sends a raw message to the recipients. On error, the message is retried
+// on a new connection.
+func (p *rawPool) Send(from string, to []string, msg []byte) error {
+	var lastErr error
+	for range p.opt.MaxMessageRetries {
+		c, err := p.borrow()
+		if err != nil {
+			lastErr = err
+			if canRetry(err) {
+				continue
+			}
+
+			return err
+		}
+
+		retry, err := c.send(from, to, msg)
+		p.release(c, err)
+		if err == nil {
+			return nil
+		}
+
+		lastErr = err
+		if !retry {
+			return err
+		}
+	}
+
+	return lastErr
+}
+
+// Close closes the idle connections in the pool and waits for the borrowed
+// connections to finish sending and be closed. Borrowed connections that are
+// still busy after PoolWaitTimeout are closed right away.
+func (p *rawPool) Close() {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return
+	}
+	p.closed = true
+	close(p.stop)
+	idle := p.drain()
+	p.mu.Unlock()
+
+	for _, c := range idle {
+		c.conn.Quit()
+		<-p.sem
+	}
+
+	done := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(p.opt.PoolWaitTimeout):
+		p.mu.Lock()
+		for c := range p.borrowed {
+			c.conn.Close()
+		}
+		p.mu.Unlock()
+	}
+}
+
+// drain removes and returns the idle connections in the pool.
+func (p *rawPool) drain() []*rawConn {
+	var out []*rawConn
+	for {
+		select {
+		case c := <-p.conns:
+			out = append(out, c)
+		default:
+			return out
+		}
+	}
+}
+
+// sweepConns periodically closes the idle connections that haven't had any
+// activity in IdleTimeout, which the server has likely dropped. This is a
+// blocking function and should be run as a goroutine.
+func (p *rawPool) sweepConns(interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-p.stop:
+			return
+		case <-t.C:
+		}
+
+		p.sweep()
+	}
+}
+
+// sweep closes the idle connections that haven't had any activity in IdleTimeout.
+func (p *rawPool) sweep() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closed {
+		return
+	}
+	for _, c := range p.drain() {
+		if time.Since(c.lastActivity) > p.opt.IdleTimeout {
+			c.conn.Close()
+			<-p.sem
+			continue
+		}
+		p.conns <- c
+	}
+}
+
+// borrow returns an idle connection from the pool, or a new one if there's room.
+func (p *rawPool) borrow() (*rawConn, error) {
+	for {
+		p.mu.Lock()
+		closed := p.closed
+		p.mu.Unlock()
+		if closed {
+			return nil, smtppool.ErrPoolClosed
+		}
+
+		var c *rawConn
+		select {
+		case c = <-p.conns:
+		default:
+			select {
+			case c = <-p.conns:
+			case p.sem <- struct{}{}:
+				cn, err := p.dial()
+				if err != nil {
+					<-p.sem
+					return nil, err
+				}
+				c = cn
+			case <-time.After(p.opt.PoolWaitTimeout):
+				return nil, errors.New("timed out waiting for free conn in pool")
+			}
+		}
+
+		// Close connections that have been idle for too long. The server
+		// has likely dropped them.
+		if p.opt.IdleTimeout > 0 && time.Since(c.lastActivity) > p.opt.IdleTimeout {
+			c.conn.Close()
+			<-p.sem
+			continue
+		}
+
+		// The pool may have been closed in the meanwhile.
+		p.mu.Lock()
+		if p.closed {
+			p.mu.Unlock()
+			c.conn.Close()
+			<-p.sem
+			return nil, smtppool.ErrPoolClosed
+		}
+		p.borrowed[c] = struct{}{}
+		p.wg.Add(1)
+		p.mu.Unlock()
+
+		return c, nil
+	}
+}
+
+// release returns a connection to the pool, or closes it if the last
+// transaction on it failed or the pool is closed.
+func (p *rawPool) release(c *rawConn, lastErr error) {
+	defer func() {
+		p.mu.Lock()
+		delete(p.borrowed, c)
+		p.mu.Unlock()
+		p.wg.Done()
+	}()
+
+	// Any error except for an SMTP error response is a bad connection.
+	ok := true
+	if lastErr != nil {
+		_, ok = lastErr.(*textproto.Error)
+	}
+
+	// RSET the connection before reusing it.
+	if ok && c.conn.Reset() == nil {
+		p.mu.Lock()
+		if !p.closed {
+			p.conns <- c
+			p.mu.Unlock()
+			return
+		}
+		p.mu.Unlock()
+		c.conn.Quit()
+	} else {
+		c.conn.Close()
+	}
+
+	<-p.sem
+}
+
+// dial opens and authenticates a new SMTP connection.
+func (p *rawPool) dial() (cn *rawConn, err error) {
+	var (
+		netCon net.Conn
+		addr   = net.JoinHostPort(p.opt.Host, strconv.Itoa(p.opt.Port))
+	)
+
+	if p.opt.SSL == smtppool.SSLTLS {
+		netCon, err = tls.DialWithDialer(&net.Dialer{Timeout: p.opt.PoolWaitTimeout}, "tcp", addr, p.opt.TLSConfig)
+	} else {
+		netCon, err = net.DialTimeout("tcp", addr, p.opt.PoolWaitTimeout)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	sm, err := smtp.NewClient(netCon, p.opt.Host)
+	if err != nil {
+		netCon.Close()
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			sm.Close()
+		}
+	}()
+
+	if p.opt.HelloHostname != "" {
+		if err := sm.Hello(p.opt.HelloHostname); err != nil {
+			return nil, err
+		}
+	}
+
+	if p.opt.SSL == smtppool.SSLSTARTTLS {
+		if ok, _ := sm.Extension("STARTTLS"); !ok {
+			return nil, errors.New("SMTP STARTTLS extension not found")
+		}
+		if err := sm.StartTLS(p.opt.TLSConfig); err != nil {
+			return nil, err
+		}
+	}
+
+	if p.opt.Auth != nil {
+		if ok, _ := sm.Extension("AUTH"); !ok {
+			return nil, errors.New("SMTP AUTH extension not found")
+		}
+		if err := sm.Auth(p.opt.Auth); err != nil {
+			return nil, err
+		}
+	}
+
+	return &rawConn{conn: sm, lastActivity: time.Now()}, nil
+}
+
+// send sends a message on the connection. retry indicates whether
+// the error is a network error, which can be retried.
+func (c *rawConn) send(from string, to []string, msg []byte) (bool, error) {
+	c.lastActivity = time.Now()
+
+	if err := c.conn.Mail(from); err != nil {
+		return canRetry(err), err
+	}
+	for _, r := range to {
+		if err := c.conn.Rcpt(r); err != nil {
+			return canRetry(err), err
+		}
+	}
+
+	w, err := c.conn.Data()
+	if err != nil {
+		return canRetry(err), err
+	}
+	if _, err := w.Write(msg); err != nil {
+		w.Close()
+		return canRetry(err), err
+	}
+	if err := w.Close(); err != nil {
+		return false, err
+	}
+
+	return false, nil
+}
+
+// canRetry checks whether an error is a network error (TCP/DNS/timeout etc.)
+// and the message can be retried on a new connection.
+func canRetry(err error) bool {
+	var netErr net.Error
+	if errors.As(err, &netErr) || err == io.EOF {
+		return true
+	}
+
+	return false
+}
@@ -0,0 +1,292 @@
+package email
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"net/textproto"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/knadh/listmonk/models"
+	"github.com/knadh/smtppool/v2"
+)
+
+// waitOpen waits for the server to have n open connections.
+func (s *smtpServer) waitOpen(t *testing.T, n int) {
+	t.Helper()
+
+	for start := time.Now(); time.Since(start) < 2*time.Second; time.Sleep(10 * time.Millisecond) {
+		if s.get().open == n {
+			return
+		}
+	}
+	t.Fatalf("expected %d open connections, got %d", n, s.get().open)
+}
+
+func newTestPool(t *testing.T, s *smtpServer, maxConns int) *rawPool {
+	t.Helper()
+
+	p, err := newRawPool(smtppool.Opt{
+		Host:              "127.0.0.1",
+		Port:              s.port,
+		MaxConns:          maxConns,
+		MaxMessageRetries: 2,
+		PoolWaitTimeout:   time.Second,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+const testMsg = "From: jane@example.com\r\nSubject: Hi\r\n\r\nHello\r\n.hidden\r\n..\r\n"
+
+func TestRawPool(t *testing.T) {
+	t.Run("send", func(t *testing.T) {
+		s := newSMTPServer(t, 0)
+		p := newTestPool(t, s, 2)
+		defer p.Close()
+
+		for range 3 {
+			if err := p.Send("jane@example.com", []string{"a@example.net", "b@example.net"}, []byte(testMsg)); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		// The messages are sent as-is on the same connection.
+		st := s.get()
+		if len(st.msgs) != 3 || st.conns != 1 {
+			t.Fatalf("expected 3 messages on 1 connection, got %d on %d", len(st.msgs), st.conns)
+		}
+		if m := st.msgs[0]; m.from != "jane@example.com" || strings.Join(m.to, ",") != "a@example.net,b@example.net" || m.data != testMsg {
+			t.Errorf("unexpected message: %+v", m)
+		}
+
+		// SMTP errors aren't retried and the connection is reused.
+		err := p.Send("jane@example.com", []string{"reject@example.net"}, []byte(testMsg))
+		var tErr *textproto.Error
+		if !errors.As(err, &tErr) || tErr.Code != 550 {
+			t.Fatalf("expected SMTP error, got %v", err)
+		}
+		if err := p.Send("jane@example.com", []string{"a@example.net"}, []byte(testMsg)); err != nil {
+			t.Fatal(err)
+		}
+		if st := s.get(); len(st.msgs) != 4 || st.conns != 1 {
+			t.Errorf("expected 4 messages on 1 connection, got %d on %d", len(st.msgs), st.conns)
+		}
+	})
+
+	t.Run("max conns", func(t *testing.T) {
+		s := newSMTPServer(t, 0)
+		p := newTestPool(t, s, 2)
+		defer p.Close()
+
+		var wg sync.WaitGroup
+		for range 10 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if err := p.Send("jane@example.com", []string{"a@example.net"}, []byte(testMsg)); err != nil {
+					t.Error(err)
+				}
+			}()
+		}
+		wg.Wait()
+
+		if st := s.get(); len(st.msgs) != 10 || st.maxOpen > 2 {
+			t.Errorf("expected 10 messages on max. 2 connections, got %d on %d", len(st.msgs), st.maxOpen)
+		}
+	})
+
+	t.Run("retry", func(t *testing.T) {
+		s := newSMTPServer(t, 1)
+		p := newTestPool(t, s, 1)
+		defer p.Close()
+
+		// The server drops the pooled connection and the message is retried
+		// on a new one.
+		for range 2 {
+			if err := p.Send("jane@example.com", []string{"a@example.net"}, []byte(testMsg)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if st := s.get(); len(st.msgs) != 2 || st.conns != 2 {
+			t.Errorf("expected 2 messages on 2 connections, got %d on %d", len(st.msgs), st.conns)
+		}
+	})
+
+	t.Run("sweep", func(t *testing.T) {
+		s := newSMTPServer(t, 0)
+		p := newTestPool(t, s, 2)
+		p.opt.IdleTimeout = time.Minute
+		defer p.Close()
+
+		if err := p.Send("jane@example.com", []string{"a@example.net"}, []byte(testMsg)); err != nil {
+			t.Fatal(err)
+		}
+
+		// Active connections are kept.
+		p.sweep()
+		if len(p.conns) != 1 || len(p.sem) != 1 {
+			t.Fatalf("expected 1 idle connection, got %d", len(p.conns))
+		}
+
+		// Idle connections are closed, freeing their slot.
+		c := <-p.conns
+		c.lastActivity = time.Now().Add(-time.Hour)
+		p.conns <- c
+		p.sweep()
+		if len(p.conns) != 0 || len(p.sem) != 0 {
+			t.Fatalf("expected no connections, got %d", len(p.conns))
+		}
+		s.waitOpen(t, 0)
+
+		if err := p.Send("jane@example.com", []string{"a@example.net"}, []byte(testMsg)); err != nil {
+			t.Fatal(err)
+		}
+		if st := s.get(); st.conns != 2 {
+			t.Errorf("expected a new connection, got %d", st.conns)
+		}
+	})
+
+	t.Run("close", func(t *testing.T) {
+		s := newSMTPServer(t, 0)
+		p := newTestPool(t, s, 2)
+
+		// One idle and one borrowed connection.
+		var cs []*rawConn
+		for range 2 {
+			c, err := p.borrow()
+			if err != nil {
+				t.Fatal(err)
+			}
+			cs = append(cs, c)
+		}
+		p.release(cs[0], nil)
+		s.waitOpen(t, 2)
+
+		// The borrowed connection is closed once it's released.
+		c := cs[1]
+
+		done := make(chan struct{})
+		go func() {
+			p.Close()
+			close(done)
+		}()
+
+		select {
+		case <-done:
+			t.Fatal("Close returned with a borrowed connection")
+		case <-time.After(100 * time.Millisecond):
+		}
+		s.waitOpen(t, 1)
+
+		_, err := c.send("jane@example.com", []string{"a@example.net"}, []byte(testMsg))
+		p.release(c, err)
+		<-done
+
+		s.waitOpen(t, 0)
+		if st := s.get(); len(st.msgs) != 1 || st.quits != 2 {
+			t.Errorf("expected 1 message and 2 QUITs, got %d and %d", len(st.msgs), st.quits)
+		}
+		if err := p.Send("jane@example.com", []string{"a@example.net"}, []byte(testMsg)); err != smtppool.ErrPoolClosed {
+			t.Errorf("expected pool closed error, got %v", err)
+		}
+	})
+
+	t.Run("close busy", func(t *testing.T) {
+		s := newSMTPServer(t, 0)
+		p := newTestPool(t, s, 1)
+
+		// A connection that's still borrowed after the wait timeout is closed.
+		c, err := p.borrow()
+		if err != nil {
+			t.Fatal(err)
+		}
+		p.Close()
+		s.waitOpen(t, 0)
+
+		p.release(c, nil)
+		if len(p.conns) != 0 || len(p.sem) != 0 {
+			t.Errorf("expected no connections, got %d", len(p.conns))
+		}
+	})
+}
+
+// newDKIMKey returns a PEM encoded Ed25519 private key.
+func newDKIMKey(t *testing.T) string {
+	t.Helper()
+
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: b}))
+}
+
+func TestSendSigned(t *testing.T) {
+	s := newSMTPServer(t, 0)
+
+	var keys []DKIMKey
+	for _, d := range []string{"example.com", "mail.example.net"} {
+		keys = append(keys, DKIMKey{Domain: d, Selector: "sel", PrivateKey: newDKIMKey(t)})
+	}
+
+	e, err := New("email", nil, Server{
+		TLSType: "none",
+		DKIM:    DKIM{Enabled: true, Keys: keys},
+		Opt:     smtppool.Opt{Host: "127.0.0.1", Port: s.port, MaxConns: 1},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer e.Close()
+
+	cases := []struct {
+		from   string
+		domain string
+	}{
+		{`"Jane" <jane@example.com>`, "example.com"},
+		{"jane@news.example.com", "example.com"},
+		{"jane@news.example.net", "mail.example.net"},
+		{"jane@example.org", ""},
+	}
+
+	for i, c := range cases {
+		err := e.Push(models.Message{
+			From:        c.from,
+			To:          []string{"john@example.net"},
+			Subject:     "Hello",
+			ContentType: "plain",
+			Body:        []byte("Hi"),
+		})
+		if err != nil {
+			t.Fatalf("%s: %v", c.from, err)
+		}
+
+		m := s.get().msgs[i]
+		if m.to[0] != "john@example.net" {
+			t.Errorf("%s: unexpected recipients: %v", c.from, m.to)
+		}
+
+		hasSig := strings.HasPrefix(m.data, "DKIM-Signature:")
+		if c.domain == "" {
+			if hasSig {
+				t.Errorf("%s: expected no signature", c.from)
+			}
+			continue
+		}
+		if !hasSig || !strings.Contains(m.data, " d="+c.domain+";") {
+			t.Errorf("%s: expected signature of %s, got: %s", c.from, c.domain, m.data)
+		}
+	}
+}
@@ -0,0 +1,437 @@
+package migrations
+
+import (
+	"log"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/knadh/koanf/v2"
+	"github.com/knadh/stuffbin"
+)
+
+// V5_3_0 performs the DB migrations.
+func V5_3_0(db *sqlx.DB, fs stuffbin.FileSystem, ko *koanf.Koanf, lo *log.Logger) error {
+	// Digest (RSS-to-email) campaigns.
+	if _, err := db.Exec(`ALTER TYPE campaign_type ADD VALUE IF NOT EXISTS 'digest'`); err != nil {
+		return err
+	}
+
+	if _, err := db.Exec(`
+		ALTER TABLE campaigns ADD COLUMN IF NOT EXISTS feed_items JSONB NOT NULL DEFAULT '[]';
+		ALTER TABLE campaigns ADD COLUMN IF NOT EXISTS resume_at TIMESTAMP WITH TIME ZONE NULL;
+
+		CREATE TABLE IF NOT EXISTS campaign_feeds (
+			id               SERIAL PRIMARY KEY,
+			campaign_id      INTEGER NOT NULL UNIQUE REFERENCES campaigns(id) ON DELETE CASCADE ON UPDATE CASCADE,
+			url              TEXT NOT NULL,
+			poll_interval    TEXT NOT NULL DEFAULT '1h',
+			max_items        INT NOT NULL DEFAULT 10,
+			enabled          BOOLEAN NOT NULL DEFAULT true,
+			last_item_at     TIMESTAMP WITH TIME ZONE NULL,
+			last_checked_at  TIMESTAMP WITH TIME ZONE NULL,
+			last_campaign_id INTEGER NULL REFERENCES campaigns(id) ON DELETE SET NULL ON UPDATE CASCADE,
+			created_at       TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+			updated_at       TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+		);
+	`); err != nil {
+		return err
+	}
+
+	// Drip/autoresponder sequences.
+	if _, err := db.Exec(`
+		DO $$
+		BEGIN
+			IF NOT EXISTS (SELECT 1 FROM pg_type WHERE typname = 'sequence_sub_status') THEN
+				CREATE TYPE sequence_sub_status AS ENUM ('active', 'finished', 'exited');
+			END IF;
+		END$$;
+
+		CREATE TABLE IF NOT EXISTS sequences (
+			id               SERIAL PRIMARY KEY,
+			name             TEXT NOT NULL,
+			list_id          INTEGER NULL REFERENCES lists(id) ON DELETE SET NULL ON UPDATE CASCADE,
+			from_email       TEXT NOT NULL DEFAULT '',
+			messenger        TEXT NOT NULL DEFAULT 'email',
+			enabled          BOOLEAN NOT NULL DEFAULT true,
+			created_at       TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+			updated_at       TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+		);
+		CREATE INDEX IF NOT EXISTS idx_sequences_list_id ON sequences(list_id);
+
+		CREATE TABLE IF NOT EXISTS sequence_steps (
+			id               SERIAL PRIMARY KEY,
+			sequence_id      INTEGER NOT NULL REFERENCES sequences(id) ON DELETE CASCADE ON UPDATE CASCADE,
+			position         INT NOT NULL,
+			delay            INTERVAL NOT NULL DEFAULT '0',
+			template_id      INTEGER NULL REFERENCES templates(id) ON DELETE SET NULL ON UPDATE CASCADE,
+			subject          TEXT NOT NULL DEFAULT '',
+			sent             INT NOT NULL DEFAULT 0,
+			created_at       TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+			updated_at       TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+			UNIQUE(sequence_id, position)
+		);
+
+		CREATE TABLE IF NOT EXISTS sequence_subscribers (
+			sequence_id      INTEGER NOT NULL REFERENCES sequences(id) ON DELETE CASCADE ON UPDATE CASCADE,
+			subscriber_id    INTEGER NOT NULL REFERENCES subscribers(id) ON DELETE CASCADE ON UPDATE CASCADE,
+			status           sequence_sub_status NOT NULL DEFAULT 'active',
+			last_position    INT NOT NULL DEFAULT 0,
+			entered_at       TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+			updated_at       TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+			PRIMARY KEY(sequence_id, subscriber_id)
+		);
+		CREATE INDEX IF NOT EXISTS idx_seq_subs_sub_id ON sequence_subscribers(subscriber_id);
+		CREATE INDEX IF NOT EXISTS idx_seq_subs_status ON sequence_subscribers(status);
+	`); err != nil {
+		return err
+	}
+
+	// Subscriber events and event-triggered automations.
+	if _, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS subscriber_events (
+			id               BIGSERIAL PRIMARY KEY,
+			subscriber_id    INTEGER NOT NULL REFERENCES subscribers(id) ON DELETE CASCADE ON UPDATE CASCADE,
+			name             TEXT NOT NULL,
+			data             JSONB NOT NULL DEFAULT '{}',
+			created_at       TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+		);
+		CREATE INDEX IF NOT EXISTS idx_sub_events_sub_id ON subscriber_events(subscriber_id, name);
+		CREATE INDEX IF NOT EXISTS idx_sub_events_name ON subscriber_events(name, created_at);
+
+		CREATE TABLE IF NOT EXISTS automations (
+			id               SERIAL PRIMARY KEY,
+			name             TEXT NOT NULL,
+			event            TEXT NOT NULL,
+			filter           JSONB NOT NULL DEFAULT '{}',
+			delay            INTERVAL NOT NULL DEFAULT '0',
+			template_id      INTEGER NULL REFERENCES templates(id) ON DELETE SET NULL ON UPDATE CASCADE,
+			subject          TEXT NOT NULL DEFAULT '',
+			from_email       TEXT NOT NULL DEFAULT '',
+			messenger        TEXT NOT NULL DEFAULT 'email',
+			enabled          BOOLEAN NOT NULL DEFAULT true,
+			sent             INT NOT NULL DEFAULT 0,
+			created_at       TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+			updated_at       TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+		);
+		CREATE INDEX IF NOT EXISTS idx_automations_event ON automations(event);
+
+		CREATE TABLE IF NOT EXISTS automation_jobs (
+			id               BIGSERIAL PRIMARY KEY,
+			automation_id    INTEGER NOT NULL REFERENCES automations(id) ON DELETE CASCADE ON UPDATE CASCADE,
+			subscriber_id    INTEGER NOT NULL REFERENCES subscribers(id) ON DELETE CASCADE ON UPDATE CASCADE,
+			event_id         BIGINT NOT NULL REFERENCES subscriber_events(id) ON DELETE CASCADE ON UPDATE CASCADE,
+			send_at          TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+		);
+		CREATE INDEX IF NOT EXISTS idx_automation_jobs_send_at ON automation_jobs(send_at);
+
+		CREATE TABLE IF NOT EXISTS smtp_warmup (
+			server           TEXT NOT NULL,
+			day              DATE NOT NULL,
+			sent             INT NOT NULL DEFAULT 0,
+
+			PRIMARY KEY (server, day)
+		);
+
+		CREATE TABLE IF NOT EXISTS campaign_revisions (
+			id               SERIAL PRIMARY KEY,
+			campaign_id      INTEGER NOT NULL REFERENCES campaigns(id) ON DELETE CASCADE ON UPDATE CASCADE,
+			version          INT NOT NULL,
+			user_id          INTEGER NULL REFERENCES users(id) ON DELETE SET NULL ON UPDATE CASCADE,
+			user_name        TEXT NOT NULL DEFAULT '',
+			data             JSONB NOT NULL DEFAULT '{}',
+			changed          TEXT[] NOT NULL DEFAULT '{}',
+			sent_at          TIMESTAMP WITH TIME ZONE NULL,
+			created_at       TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+
+			UNIQUE (campaign_id, version)
+		);
+	`); err != nil {
+		return err
+	}
+
+	// Queued subscriber import jobs.
+	if _, err := db.Exec(`
+		DO $$
+		BEGIN
+			IF NOT EXISTS (SELECT 1 FROM pg_type WHERE typname = 'import_status') THEN
+				CREATE TYPE import_status AS ENUM ('queued', 'importing', 'stopping', 'stopped', 'finished', 'failed');
+			END IF;
+		END$$;
+
+		CREATE TABLE IF NOT EXISTS import_jobs (
+			id               SERIAL PRIMARY KEY,
+			user_id          INTEGER NULL REFERENCES users(id) ON DELETE SET NULL ON UPDATE CASCADE,
+			name             TEXT NOT NULL,
+			params           JSONB NOT NULL DEFAULT '{}',
+			file_path        TEXT NOT NULL DEFAULT '',
+			status           import_status NOT NULL DEFAULT 'queued',
+			total            INT NOT NULL DEFAULT 0,
+			imported         INT NOT NULL DEFAULT 0,
+			rejected         INT NOT NULL DEFAULT 0,
+			log              TEXT NOT NULL DEFAULT '',
+			errors           BYTEA NULL,
+			cleared          BOOLEAN NOT NULL DEFAULT false,
+			started_at       TIMESTAMP WITH TIME ZONE NULL,
+			finished_at      TIMESTAMP WITH TIME ZONE NULL,
+			created_at       TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+			updated_at       TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+		);
+		CREATE INDEX IF NOT EXISTS idx_import_jobs_status ON import_jobs(status);
+		CREATE INDEX IF NOT EXISTS idx_import_jobs_user_id ON import_jobs(user_id);
+
+		INSERT INTO settings (key, value) VALUES('app.import_concurrency', '2') ON CONFLICT DO NOTHING;
+
+		CREATE TABLE IF NOT EXISTS import_presets (
+			id               SERIAL PRIMARY KEY,
+			name             TEXT NOT NULL,
+			mapping          JSONB NOT NULL DEFAULT '[]',
+			created_at       TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+			updated_at       TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+		);
+	`); err != nil {
+		return err
+	}
+
+	// Scheduled subscriber sync from external sources.
+	if _, err := db.Exec(`
+		DO $$
+		BEGIN
+			IF NOT EXISTS (SELECT 1 FROM pg_type WHERE typname = 'sync_source_type') THEN
+				CREATE TYPE sync_source_type AS ENUM ('http', 'postgres');
+			END IF;
+			IF NOT EXISTS (SELECT 1 FROM pg_type WHERE typname = 'sync_run_status') THEN
+				CREATE TYPE sync_run_status AS ENUM ('running', 'finished', 'failed');
+			END IF;
+		END$$;
+
+		CREATE TABLE IF NOT EXISTS sync_sources (
+			id                  SERIAL PRIMARY KEY,
+			name                TEXT NOT NULL,
+			type                sync_source_type NOT NULL,
+			url                 TEXT NOT NULL,
+			query               TEXT NOT NULL DEFAULT '',
+			headers             JSONB NOT NULL DEFAULT '{}',
+			schedule            TEXT NOT NULL,
+			mapping             JSONB NOT NULL DEFAULT '[]',
+			list_id             INTEGER NOT NULL REFERENCES lists(id) ON DELETE CASCADE ON UPDATE CASCADE,
+			subscription_status subscription_status NOT NULL DEFAULT 'confirmed',
+			overwrite           BOOLEAN NOT NULL DEFAULT true,
+			unsubscribe_missing BOOLEAN NOT NULL DEFAULT false,
+			enabled             BOOLEAN NOT NULL DEFAULT true,
+			user_id             INTEGER NULL REFERENCES users(id) ON DELETE SET NULL ON UPDATE CASCADE,
+			last_run_at         TIMESTAMP WITH TIME ZONE NULL,
+			created_at          TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+			updated_at          TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+		);
+
+		CREATE TABLE IF NOT EXISTS sync_runs (
+			id                  SERIAL PRIMARY KEY,
+			source_id           INTEGER NOT NULL REFERENCES sync_sources(id) ON DELETE CASCADE ON UPDATE CASCADE,
+			status              sync_run_status NOT NULL DEFAULT 'running',
+			total               INT NOT NULL DEFAULT 0,
+			skipped             INT NOT NULL DEFAULT 0,
+			added               INT NOT NULL DEFAULT 0,
+			updated             INT NOT NULL DEFAULT 0,
+			removed             INT NOT NULL DEFAULT 0,
+			import_job_ids      INT[] NOT NULL DEFAULT '{}',
+			error               TEXT NOT NULL DEFAULT '',
+			started_at          TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+			finished_at         TIMESTAMP WITH TIME ZONE NULL
+		);
+		CREATE INDEX IF NOT EXISTS idx_sync_runs_source_id ON sync_runs(source_id);
+	`); err != nil {
+		return err
+	}
+
+	// Typed subscriber attribute schema.
+	if _, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS attrib_schema (
+			id               SERIAL PRIMARY KEY,
+			name             TEXT NOT NULL,
+			type             TEXT NOT NULL,
+			description      TEXT NOT NULL DEFAULT '',
+			enum_values      TEXT[] NOT NULL DEFAULT '{}',
+			required         BOOLEAN NOT NULL DEFAULT false,
+			public           BOOLEAN NOT NULL DEFAULT false,
+			created_at       TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+			updated_at       TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+		);
+		CREATE UNIQUE INDEX IF NOT EXISTS idx_attrib_schema_name ON attrib_schema(LOWER(name));
+	`); err != nil {
+		return err
+	}
+
+	// Subscriber activity timeline.
+	if _, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS subscriber_activity (
+			id               BIGSERIAL PRIMARY KEY,
+			subscriber_id    INTEGER NOT NULL REFERENCES subscribers(id) ON DELETE CASCADE ON UPDATE CASCADE,
+			type             TEXT NOT NULL,
+			data             JSONB NOT NULL DEFAULT '{}',
+			actor            TEXT NOT NULL DEFAULT 'system',
+			user_id          INTEGER NULL REFERENCES users(id) ON DELETE SET NULL ON UPDATE CASCADE,
+			created_at       TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+		);
+		CREATE INDEX IF NOT EXISTS idx_sub_activity_sub_id ON subscriber_activity(subscriber_id, created_at);
+	`); err != nil {
+		return err
+	}
+
+	// Subscriber merge records.
+	if _, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS subscriber_merges (
+			id               SERIAL PRIMARY KEY,
+			subscriber_id    INTEGER NULL REFERENCES subscribers(id) ON DELETE SET NULL ON UPDATE CASCADE,
+			email            TEXT NOT NULL,
+			merged           JSONB NOT NULL DEFAULT '[]',
+			user_id          INTEGER NULL REFERENCES users(id) ON DELETE SET NULL ON UPDATE CASCADE,
+			created_at       TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+		);
+		CREATE INDEX IF NOT EXISTS idx_sub_merges_sub_id ON subscriber_merges(subscriber_id);
+	`); err != nil {
+		return err
+	}
+
+	// Trash for subscribers, lists and campaigns.
+	if _, err := db.Exec(`
+		ALTER TABLE subscribers ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP WITH TIME ZONE NULL;
+		ALTER TABLE lists ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP WITH TIME ZONE NULL;
+		ALTER TABLE campaigns ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP WITH TIME ZONE NULL;
+		CREATE INDEX IF NOT EXISTS idx_subs_deleted_at ON subscribers(deleted_at) WHERE deleted_at IS NOT NULL;
+		CREATE INDEX IF NOT EXISTS idx_lists_deleted_at ON lists(deleted_at) WHERE deleted_at IS NOT NULL;
+		CREATE INDEX IF NOT EXISTS idx_camps_deleted_at ON campaigns(deleted_at) WHERE deleted_at IS NOT NULL;
+
+		INSERT INTO settings (key, value) VALUES('app.trash_retention_days', '30') ON CONFLICT DO NOTHING;
+	`); err != nil {
+		return err
+	}
+
+	// Exclude trashed items from the cached counts.
+	lo.Println("IMPORTANT: this upgrade might take a while if you have a large database. Please be patient ...")
+	if _, err := db.Exec(`
+		DROP MATERIALIZED VIEW IF EXISTS mat_dashboard_counts;
+		CREATE MATERIALIZED VIEW mat_dashboard_counts AS
+		WITH subs AS (
+			SELECT COUNT(*) AS num, status FROM subscribers WHERE deleted_at IS NULL GROUP BY status
+		)
+		SELECT NOW() AS updated_at,
+			JSON_BUILD_OBJECT(
+				'subscribers', JSON_BUILD_OBJECT(
+					'total', (SELECT SUM(num) FROM subs),
+					'blocklisted', (SELECT num FROM subs WHERE status='blocklisted'),
+					'orphans', (
+						SELECT COUNT(id) FROM subscribers
+						LEFT JOIN subscriber_lists ON (subscribers.id = subscriber_lists.subscriber_id)
+						WHERE subscriber_lists.subscriber_id IS NULL AND subscribers.deleted_at IS NULL
+					)
+				),
+				'lists', JSON_BUILD_OBJECT(
+					'total', (SELECT COUNT(*) FROM lists WHERE deleted_at IS NULL),
+					'private', (SELECT COUNT(*) FROM lists WHERE type='private' AND deleted_at IS NULL),
+					'public', (SELECT COUNT(*) FROM lists WHERE type='public' AND deleted_at IS NULL),
+					'optin_single', (SELECT COUNT(*) FROM lists WHERE optin='single' AND deleted_at IS NULL),
+					'optin_double', (SELECT COUNT(*) FROM lists WHERE optin='double' AND deleted_at IS NULL)
+				),
+				'campaigns', JSON_BUILD_OBJECT(
+					'total', (SELECT COUNT(*) FROM campaigns WHERE deleted_at IS NULL),
+					'by_status', (
+						SELECT JSON_OBJECT_AGG (status, num) FROM
+						(SELECT status, COUNT(*) AS num FROM campaigns WHERE deleted_at IS NULL GROUP BY status) r
+					)
+				),
+				'messages', (SELECT SUM(sent) AS messages FROM campaigns)
+			) AS data;
+		CREATE UNIQUE INDEX IF NOT EXISTS mat_dashboard_stats_idx ON mat_dashboard_counts (updated_at);
+
+		DROP MATERIALIZED VIEW IF EXISTS mat_list_subscriber_stats;
+		CREATE MATERIALIZED VIEW mat_list_subscriber_stats AS
+		SELECT NOW() AS updated_at, lists.id AS list_id, sl.status, COUNT(sl.status) AS subscriber_count FROM lists
+		LEFT JOIN subscriber_lists sl ON (
+			sl.list_id = lists.id
+			AND NOT EXISTS (SELECT 1 FROM subscribers s WHERE s.id = sl.subscriber_id AND s.deleted_at IS NOT NULL)
+		)
+		WHERE lists.deleted_at IS NULL
+		GROUP BY lists.id, sl.status
+		UNION ALL
+		SELECT NOW() AS updated_at, 0 AS list_id, NULL AS status, COUNT(id) AS subscriber_count FROM subscribers WHERE deleted_at IS NULL;
+		CREATE UNIQUE INDEX IF NOT EXISTS mat_list_subscriber_stats_idx ON mat_list_subscriber_stats (list_id, status);
+	`); err != nil {
+		return err
+	}
+
+	// Bounce webhook providers.
+	if _, err := db.Exec(`
+		INSERT INTO settings (key, value) VALUES
+			('bounce.mailgun', '{"enabled": false, "key": ""}'),
+			('bounce.sparkpost', '{"enabled": false, "username": "", "password": ""}'),
+			('bounce.brevo', '{"enabled": false, "username": "", "password": ""}'),
+			('bounce.mailjet', '{"enabled": false, "username": "", "password": ""}'),
+			('bounce.resend', '{"enabled": false, "key": ""}')
+		ON CONFLICT DO NOTHING;
+	`); err != nil {
+		return err
+	}
+
+	// Delivery, open and click events from bounce webhooks.
+	if _, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS deliveries (
+			id               BIGSERIAL PRIMARY KEY,
+			subscriber_id    INTEGER NOT NULL REFERENCES subscribers(id) ON DELETE CASCADE ON UPDATE CASCADE,
+			campaign_id      INTEGER NULL REFERENCES campaigns(id) ON DELETE SET NULL ON UPDATE CASCADE,
+			source           TEXT NOT NULL DEFAULT '',
+			meta             JSONB NOT NULL DEFAULT '{}',
+			created_at       TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+		);
+		CREATE INDEX IF NOT EXISTS idx_deliveries_sub_id ON deliveries(subscriber_id);
+		CREATE INDEX IF NOT EXISTS idx_deliveries_camp_id ON deliveries(campaign_id);
+
+		INSERT INTO settings (key, value) VALUES
+			('bounce.webhook_events', '{"delivered": true, "opens": false, "clicks": false}')
+		ON CONFLICT DO NOTHING;
+	`); err != nil {
+		return err
+	}
+
+	// Time-windowed bounce actions, soft bounce escalation and bounce decay.
+	if _, err := db.Exec(`
+		UPDATE settings SET value = (
+			SELECT JSONB_OBJECT_AGG(key, JSONB_BUILD_OBJECT('window_days', 0) || value)
+			FROM JSONB_EACH(settings.value)
+		) WHERE key = 'bounce.actions';
+
+		INSERT INTO settings (key, value) VALUES
+			('bounce.soft_escalation', '0'),
+			('bounce.decay_days', '0')
+		ON CONFLICT DO NOTHING;
+	`); err != nil {
+		return err
+	}
+
+	// Webhook HMAC verification, IP allowlist and replay protection.
+	if _, err := db.Exec(`
+		INSERT INTO settings (key, value) VALUES
+			('bounce.webhook_security', '{"hmac_secret": "", "max_age": 300, "allowlist": {}}')
+		ON CONFLICT DO NOTHING;
+	`); err != nil {
+		return err
+	}
+
+	// Inbound e-mail (reply) processing.
+	if _, err := db.Exec(`
+		INSERT INTO settings (key, value) VALUES
+			('inbound.enabled', 'false'),
+			('inbound.mailbox', '{"type": "imap", "host": "imap.yoursite.com", "port": 993, "auth_protocol": "login", "username": "username", "password": "password", "tls_enabled": true, "tls_skip_verify": false, "scan_interval": "5m", "folder": "INBOX", "mode": "poll", "processed_action": "flag", "processed_folder": ""}'),
+			('inbound.unsubscribe', 'true'),
+			('inbound.forward_email', '""'),
+			('inbound.forward_webhook', '""')
+		ON CONFLICT DO NOTHING;
+	`); err != nil {
+		return err
+	}
+
+	// List-Unsubscribe mailto address.
+	if _, err := db.Exec(`INSERT INTO settings (key, value) VALUES ('privacy.unsubscribe_mailto', '""') ON CONFLICT DO NOTHING;`); err != nil {
+		return err
+	}
+
+	return nil
+}
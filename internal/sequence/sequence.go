@@ -0,0 +1,87 @@
+// Package sequence runs drip/autoresponder sequences. Subscribers enter a
+// sequence when they subscribe to its list (or via the API) and are sent each
+// step's message once the step's delay since they entered has elapsed.
+package sequence
+
+import (
+	"log"
+	"time"
+
+	"github.com/knadh/listmonk/internal/batch"
+	"github.com/knadh/listmonk/models"
+)
+
+// Store represents the DB store for sequences.
+type Store interface {
+	SyncSequenceSubscribers() error
+	NextSequenceMessages(limit int) ([]models.SequenceMessage, error)
+	AdvanceSequenceSubscriber(seqID, subID, position int, sent bool) error
+}
+
+// Opt represents sequence runner options.
+type Opt struct {
+	// How often sequences are processed.
+	Interval time.Duration
+
+	// Max number of messages to process in one run.
+	BatchSize int
+
+	// SendCB sends a sequence message. It should return (false, nil) if the
+	// step was skipped (eg: missing template) and an error if sending failed,
+	// in which case the step is retried on the next run.
+	SendCB func(models.SequenceMessage) (bool, error)
+}
+
+// Runner processes sequences.
+type Runner struct {
+	opt   Opt
+	store Store
+	log   *log.Logger
+}
+
+// New returns a new instance of Runner.
+func New(opt Opt, s Store, lo *log.Logger) *Runner {
+	if opt.Interval == 0 {
+		opt.Interval = time.Minute
+	}
+	if opt.BatchSize < 1 {
+		opt.BatchSize = 1000
+	}
+
+	return &Runner{
+		opt:   opt,
+		store: s,
+		log:   lo,
+	}
+}
+
+// Run is a blocking function that periodically processes sequences.
+func (r *Runner) Run() {
+	t := time.NewTicker(r.opt.Interval)
+	defer t.Stop()
+
+	for range t.C {
+		if err := r.Process(); err != nil {
+			r.log.Printf("error processing sequences: %v", err)
+		}
+	}
+}
+
+// Process enrolls and exits subscribers and sends the steps that are due.
+func (r *Runner) Process() error {
+	if err := r.store.SyncSequenceSubscribers(); err != nil {
+		return err
+	}
+
+	return batch.Process(batch.Opt[models.SequenceMessage]{
+		Size: r.opt.BatchSize,
+		Next: r.store.NextSequenceMessages,
+		Send: r.opt.SendCB,
+		Done: func(m models.SequenceMessage, sent bool) error {
+			return r.store.AdvanceSequenceSubscriber(m.SequenceID, m.SubscriberID, m.Position, sent)
+		},
+		OnError: func(m models.SequenceMessage, err error) {
+			r.log.Printf("error sending sequence %d step %d to subscriber %d: %v", m.SequenceID, m.Position, m.SubscriberID, err)
+		},
+	})
+}
@@ -0,0 +1,83 @@
+package sequence
+
+import (
+	"errors"
+	"io"
+	"log"
+	"testing"
+
+	"github.com/knadh/listmonk/models"
+)
+
+// store is an in-memory Store with a queue of due messages. Advanced
+// messages are removed from the queue.
+type store struct {
+	queue    []models.SequenceMessage
+	advanced []models.SequenceMessage
+	syncErr  error
+	synced   int
+}
+
+func (s *store) SyncSequenceSubscribers() error {
+	s.synced++
+	return s.syncErr
+}
+
+func (s *store) NextSequenceMessages(limit int) ([]models.SequenceMessage, error) {
+	// Subscribers are synced before the due steps are fetched.
+	if s.synced == 0 {
+		return nil, errors.New("subscribers not synced")
+	}
+
+	if limit > len(s.queue) {
+		limit = len(s.queue)
+	}
+	return append([]models.SequenceMessage{}, s.queue[:limit]...), nil
+}
+
+func (s *store) AdvanceSequenceSubscriber(seqID, subID, position int, sent bool) error {
+	for i, m := range s.queue {
+		if m.SequenceID == seqID && m.SubscriberID == subID {
+			s.queue = append(s.queue[:i], s.queue[i+1:]...)
+			break
+		}
+	}
+	s.advanced = append(s.advanced, models.SequenceMessage{SequenceID: seqID, SubscriberID: subID, Position: position})
+	return nil
+}
+
+func TestProcess(t *testing.T) {
+	lo := log.New(io.Discard, "", 0)
+
+	msgs := []models.SequenceMessage{
+		{SequenceID: 1, SubscriberID: 1, Position: 1},
+		{SequenceID: 1, SubscriberID: 2, Position: 3},
+		{SequenceID: 2, SubscriberID: 1, Position: 2},
+	}
+	st := &store{queue: append([]models.SequenceMessage{}, msgs...)}
+	r := New(Opt{BatchSize: 2, SendCB: func(models.SequenceMessage) (bool, error) { return true, nil }}, st, lo)
+	if err := r.Process(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Subscribers are advanced past the step that was sent in their sequence.
+	if st.synced != 1 || len(st.queue) != 0 || len(st.advanced) != 3 {
+		t.Fatalf("expected 1 sync and 3 advanced, got %d and %d", st.synced, len(st.advanced))
+	}
+	for i, m := range msgs {
+		a := st.advanced[i]
+		if a.SequenceID != m.SequenceID || a.SubscriberID != m.SubscriberID || a.Position != m.Position {
+			t.Errorf("expected %+v to be advanced, got %+v", m, a)
+		}
+	}
+
+	// Nothing is sent if syncing fails.
+	st = &store{syncErr: errors.New("db error"), queue: []models.SequenceMessage{{SequenceID: 1, SubscriberID: 1, Position: 1}}}
+	r = New(Opt{SendCB: func(models.SequenceMessage) (bool, error) {
+		t.Error("unexpected send")
+		return true, nil
+	}}, st, lo)
+	if err := r.Process(); err == nil {
+		t.Error("expected sync error")
+	}
+}
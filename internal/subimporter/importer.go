@@ -1,29 +1,36 @@
-// Package subimporter implements a bulk ZIP/CSV importer of subscribers.
-// It implements a simple queue for buffering imports and committing records
-// to DB along with ZIP and CSV handling utilities. It is meant to be used as
-// a singleton as each Importer instance is stateful, where it keeps track of
-// an import in progress. Only one import should happen on a single importer
-// instance at a time.
+// Package subimporter implements a bulk ZIP/CSV/JSON importer of subscribers.
+// Imports are queued as jobs in the DB and are picked up by the Importer,
+// which runs up to a configured number of import sessions concurrently.
+// Each session buffers and commits records to the DB and keeps track of
+// its own stats and logs, which are periodically saved to the job.
+// It also implements ZIP, CSV and JSON handling utilities.
 package subimporter
 
 import (
 	"archive/zip"
+	"bufio"
 	"bytes"
 	"database/sql"
 	"encoding/csv"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"hash/maphash"
 	"io"
 	"log"
+	"net/http"
 	"net/mail"
 	"os"
+	"path/filepath"
 	"regexp"
+	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/gofrs/uuid/v5"
 	"github.com/knadh/listmonk/internal/i18n"
+	"github.com/knadh/listmonk/internal/utils"
 	"github.com/knadh/listmonk/models"
 	"github.com/lib/pq"
 	"golang.org/x/text/cases"
@@ -33,25 +40,70 @@ import (
 const (
 	// commitBatchSize is the number of inserts to commit in a single SQL transaction.
 	commitBatchSize = 10000
+
+	// maxLogSize is the maximum size of the logs recorded for an import session.
+	maxLogSize = 1 << 20
+
+	// pollInterval is how often the queue is checked for new jobs in the
+	// absence of an explicit signal.
+	pollInterval = time.Second * 5
+
+	// saveInterval is how often the stats and logs of a running session are
+	// saved to its job.
+	saveInterval = time.Second * 2
+
+	// fetchTimeout is the max time for downloading a file from a URL.
+	fetchTimeout = time.Hour
+
+	// maxFetchSize is the max size of a file downloaded from a URL or the media store.
+	maxFetchSize = 1 << 30
+
+	// maxSeen is the max number of e-mails in a file that are remembered to
+	// detect duplicates. Duplicates beyond it aren't rejected, but upserted.
+	maxSeen = 1000000
 )
 
 // Various import statuses.
 const (
 	StatusNone      = "none"
+	StatusQueued    = "queued"
 	StatusImporting = "importing"
 	StatusStopping  = "stopping"
+	StatusStopped   = "stopped"
 	StatusFinished  = "finished"
 	StatusFailed    = "failed"
 
-	ModeSubscribe = "subscribe"
-	ModeBlocklist = "blocklist"
+	ModeSubscribe   = "subscribe"
+	ModeBlocklist   = "blocklist"
+	ModeUnsubscribe = "unsubscribe"
+	ModeDelete      = "delete"
+	ModeUpdate      = "update"
+
+	FormatCSV   = "csv"
+	FormatJSON  = "json"
+	FormatJSONL = "jsonl"
 )
 
+// Store represents the DB store of import jobs.
+type Store interface {
+	// NextImportJob claims the oldest queued job. It returns nil if there
+	// are no queued jobs.
+	NextImportJob() (*models.ImportJob, error)
+	UpdateImportJob(id int, status string, total, imported, rejected int, log string) error
+	SaveImportJobErrors(id int, b []byte) error
+
+	// FailInterruptedImportJobs fails jobs that were running when the app was
+	// last stopped and returns the paths of their files.
+	FailInterruptedImportJobs() ([]string, error)
+}
+
 // Importer represents the bulk CSV subscriber import system.
 type Importer struct {
-	opt  Options
-	db   *sql.DB
-	i18n *i18n.I18n
+	opt   Options
+	store Store
+	db    *sql.DB
+	i18n  *i18n.I18n
+	log   *log.Logger
 
 	domainBlocklist       map[string]struct{}
 	hasBlocklistWildcards bool
@@ -61,8 +113,12 @@ type Importer struct {
 	hasAllowlistWildcards bool
 	hasAllowlist          bool
 
-	stop   chan bool
-	status Status
+	// Max size of files fetched from URLs or the media store.
+	maxFetchSize int64
+
+	// Running sessions by job ID.
+	sessions map[int]*Session
+	wake     chan bool
 	sync.RWMutex
 }
 
@@ -70,22 +126,53 @@ type Importer struct {
 type Options struct {
 	UpsertStmt         *sql.Stmt
 	BlocklistStmt      *sql.Stmt
+	UnsubscribeStmt    *sql.Stmt
+	DeleteStmt         *sql.Stmt
+	UpdateStmt         *sql.Stmt
 	UpdateListDateStmt *sql.Stmt
 	PostCB             func(subject string, data any) error
 
 	DomainBlocklist []string
 	DomainAllowlist []string
+
+	// OpenMedia opens an object in the media store (eg: S3) for importing.
+	OpenMedia func(key string) (io.ReadCloser, error)
+
+	// AttribSchema returns the subscriber attribute schema that imported
+	// attributes are validated against.
+	AttribSchema func() (models.AttribSchema, error)
+
+	// Concurrency is the max number of imports that run at the same time.
+	Concurrency int
 }
 
 // Session represents a single import session.
 type Session struct {
 	im       *Importer
-	subQueue chan SubReq
+	job      models.ImportJob
+	subQueue chan subRow
 	log      *log.Logger
+	logBuf   *logBuffer
+	stop     chan bool
+
+	// CSV of rejected rows with the reasons.
+	errFile *os.File
+	errW    *csv.Writer
+	errMut  sync.Mutex
+
+	status Status
+	mut    sync.RWMutex
 
 	opt SessionOpt
 }
 
+// subRow is a validated subscriber from a CSV row queued for import.
+type subRow struct {
+	sub  SubReq
+	line int
+	cols []string
+}
+
 // SessionOpt represents the options for an importer session.
 type SessionOpt struct {
 	Filename  string `json:"filename"`
@@ -94,15 +181,37 @@ type SessionOpt struct {
 	Overwrite bool   `json:"overwrite"`
 	Delim     string `json:"delim"`
 	ListIDs   []int  `json:"lists"`
+
+	// DryRun validates the file without importing anything.
+	DryRun bool `json:"dry_run"`
+
+	// Format is the file format, csv, json (an array of objects) or jsonl.
+	// If it's empty, it's derived from the file's extension.
+	Format string `json:"format"`
+
+	// URL is an HTTP(S) URL or MediaKey the key of an object in the media
+	// store to fetch the file from instead of an upload.
+	URL      string `json:"url"`
+	MediaKey string `json:"media_key"`
+
+	// Mapping maps arbitrary CSV columns to subscriber fields. If it's empty,
+	// the fixed email, name and attributes headers are used. PresetID is a
+	// saved mapping to use instead.
+	Mapping  models.ImportMapping `json:"mapping"`
+	PresetID int                  `json:"preset_id"`
 }
 
 // Status represents statistics from an ongoing import session.
 type Status struct {
-	Name     string `json:"name"`
-	Total    int    `json:"total"`
+	Name  string `json:"name"`
+	Total int    `json:"total"`
+
+	// Imported is the number of rows imported, or in a dry run, the number
+	// of valid rows that would be imported.
 	Imported int    `json:"imported"`
+	Rejected int    `json:"rejected"`
+	DryRun   bool   `json:"dry_run"`
 	Status   string `json:"status"`
-	logBuf   *bytes.Buffer
 }
 
 // SubReq is a wrapper over the Subscriber model.
@@ -117,32 +226,60 @@ type importStatusTpl struct {
 	Name     string
 	Status   string
 	Imported int
+	Rejected int
 	Total    int
 }
 
-var (
-	// ErrIsImporting is thrown when an import request is made while an
-	// import is already running.
-	ErrIsImporting = errors.New("import is already running")
+// logBuffer is a size capped buffer of session logs that's safe for
+// concurrent writes and reads.
+type logBuffer struct {
+	buf  bytes.Buffer
+	full bool
+	sync.Mutex
+}
 
+// seenEmails keeps track of the e-mails in a file to detect duplicates. To bound
+// the memory used by large files, only the hashes of up to max e-mails are kept.
+type seenEmails struct {
+	seed   maphash.Seed
+	hashes map[uint64]struct{}
+	max    int
+}
+
+var (
 	csvHeaders = map[string]bool{
 		"email":      true,
 		"name":       true,
 		"attributes": true}
 
+	// Extensions of the supported file formats.
+	formatExts = map[string]string{
+		".csv":    FormatCSV,
+		".json":   FormatJSON,
+		".jsonl":  FormatJSONL,
+		".ndjson": FormatJSONL,
+	}
+
 	regexCleanStr = regexp.MustCompile("[[:^ascii:]]")
 )
 
 // New returns a new instance of Importer.
-func New(opt Options, db *sql.DB, i *i18n.I18n) *Importer {
+func New(opt Options, store Store, db *sql.DB, i *i18n.I18n, lo *log.Logger) *Importer {
+	if opt.Concurrency < 1 {
+		opt.Concurrency = 1
+	}
+
 	im := Importer{
 		opt:             opt,
+		store:           store,
 		db:              db,
 		i18n:            i,
+		log:             lo,
 		domainBlocklist: make(map[string]struct{}, len(opt.DomainBlocklist)),
 		domainAllowlist: make(map[string]struct{}, len(opt.DomainAllowlist)),
-		status:          Status{Status: StatusNone, logBuf: bytes.NewBuffer(nil)},
-		stop:            make(chan bool, 1),
+		maxFetchSize:    maxFetchSize,
+		sessions:        make(map[int]*Session),
+		wake:            make(chan bool, 1),
 	}
 
 	// Domain blocklist.
@@ -160,219 +297,659 @@ func New(opt Options, db *sql.DB, i *i18n.I18n) *Importer {
 	return &im
 }
 
-// NewSession returns an new instance of Session. It takes the name
-// of the uploaded file, but doesn't do anything with it but retains it for stats.
-func (im *Importer) NewSession(opt SessionOpt) (*Session, error) {
-	if !im.isDone() {
-		return nil, errors.New("an import is already running")
+// Run is a blocking function that picks up queued import jobs and runs them,
+// up to the configured number of jobs at a time.
+func (im *Importer) Run() {
+	// Jobs that were running when the app was last stopped can't be resumed.
+	paths, err := im.store.FailInterruptedImportJobs()
+	if err != nil {
+		im.log.Printf("error updating interrupted import jobs: %v", err)
+	}
+	for _, p := range paths {
+		os.Remove(p)
+	}
+
+	t := time.NewTicker(pollInterval)
+	defer t.Stop()
+
+	for {
+		im.dispatch()
+
+		select {
+		case <-t.C:
+		case <-im.wake:
+		}
+	}
+}
+
+// Queue signals the importer to pick up newly queued jobs.
+func (im *Importer) Queue() {
+	select {
+	case im.wake <- true:
+	default:
 	}
+}
+
+// dispatch starts sessions for queued jobs while there are free slots.
+func (im *Importer) dispatch() {
+	for {
+		im.RLock()
+		n := len(im.sessions)
+		im.RUnlock()
 
-	im.Lock()
-	im.status = Status{Status: StatusImporting,
-		Name:   opt.Filename,
-		logBuf: bytes.NewBuffer(nil)}
-	im.Unlock()
+		if n >= im.opt.Concurrency {
+			return
+		}
 
+		job, err := im.store.NextImportJob()
+		if err != nil {
+			im.log.Printf("error fetching queued import job: %v", err)
+			return
+		}
+		if job == nil {
+			return
+		}
+
+		s, err := im.newSession(*job)
+		if err != nil {
+			im.log.Printf("error starting import job %d: %v", job.ID, err)
+			if err := im.store.UpdateImportJob(job.ID, StatusFailed, 0, 0, 0, err.Error()+"\n"); err != nil {
+				im.log.Printf("error updating import job %d: %v", job.ID, err)
+			}
+			os.Remove(job.FilePath)
+			continue
+		}
+
+		im.Lock()
+		im.sessions[job.ID] = s
+		im.Unlock()
+
+		go s.run()
+	}
+}
+
+// newSession returns an new instance of Session for the given job.
+func (im *Importer) newSession(job models.ImportJob) (*Session, error) {
+	var opt SessionOpt
+	if err := json.Unmarshal(job.Params, &opt); err != nil {
+		return nil, err
+	}
+	if len(opt.Delim) != 1 {
+		return nil, errors.New("invalid delimiter")
+	}
+
+	// Create the CSV file for the rejected rows.
+	f, err := os.CreateTemp("", "listmonk-import-errors")
+	if err != nil {
+		return nil, err
+	}
+
+	lb := &logBuffer{}
 	s := &Session{
 		im:       im,
-		log:      log.New(im.status.logBuf, "", log.Ldate|log.Ltime|log.Lmicroseconds|log.Lshortfile),
-		subQueue: make(chan SubReq, commitBatchSize),
-		opt:      opt,
+		job:      job,
+		log:      log.New(lb, "", log.Ldate|log.Ltime|log.Lmicroseconds|log.Lshortfile),
+		logBuf:   lb,
+		stop:     make(chan bool, 1),
+		subQueue: make(chan subRow, commitBatchSize),
+		errFile:  f,
+		errW:     csv.NewWriter(f),
+		status: Status{
+			Name:   opt.Filename,
+			DryRun: opt.DryRun,
+			Status: StatusImporting,
+		},
+		opt: opt,
 	}
 
-	s.log.Printf("processing '%s'", opt.Filename)
 	return s, nil
 }
 
-// GetStats returns the global Stats of the importer.
-func (im *Importer) GetStats() Status {
+// GetStats returns the live stats of a running import job.
+func (im *Importer) GetStats(jobID int) (Status, bool) {
 	im.RLock()
-	defer im.RUnlock()
+	s, ok := im.sessions[jobID]
+	im.RUnlock()
 
-	return Status{
-		Name:     im.status.Name,
-		Status:   im.status.Status,
-		Total:    im.status.Total,
-		Imported: im.status.Imported,
+	if !ok {
+		return Status{}, false
 	}
+
+	return s.getStats(), true
 }
 
-// GetLogs returns the log entries of the last import session.
-func (im *Importer) GetLogs() []byte {
+// GetLogs returns the live logs of a running import job.
+func (im *Importer) GetLogs(jobID int) (string, bool) {
 	im.RLock()
-	defer im.RUnlock()
+	s, ok := im.sessions[jobID]
+	im.RUnlock()
 
-	if im.status.logBuf == nil {
-		return []byte{}
+	if !ok {
+		return "", false
 	}
 
-	return im.status.logBuf.Bytes()
+	return s.logBuf.String(), true
 }
 
-// setStatus sets the Importer's status.
-func (im *Importer) setStatus(status string) {
-	im.Lock()
-	im.status.Status = status
-	im.Unlock()
+// HasRunningImports returns true if there are import jobs running.
+func (im *Importer) HasRunningImports() bool {
+	im.RLock()
+	defer im.RUnlock()
+
+	return len(im.sessions) > 0
 }
 
-// getStatus get's the Importer's status.
-func (im *Importer) getStatus() string {
+// Stop sends a signal to stop a running import job. It returns false
+// if the job isn't running.
+func (im *Importer) Stop(jobID int) bool {
 	im.RLock()
-	status := im.status.Status
+	s, ok := im.sessions[jobID]
 	im.RUnlock()
-	return status
-}
 
-// isDone returns true if the importer is working (importing|stopping).
-func (im *Importer) isDone() bool {
-	s := true
-	im.RLock()
-	if im.getStatus() == StatusImporting || im.getStatus() == StatusStopping {
-		s = false
+	if !ok {
+		return false
 	}
-	im.RUnlock()
 
-	return s
-}
+	select {
+	case s.stop <- true:
+		s.setStatus(StatusStopping)
+	default:
+	}
 
-// incrementImportCount sets the Importer's "imported" counter.
-func (im *Importer) incrementImportCount(n int) {
-	im.Lock()
-	im.status.Imported += n
-	im.Unlock()
+	return true
 }
 
 // sendNotif sends admin notifications for import completions.
-func (im *Importer) sendNotif(status string) error {
+func (im *Importer) sendNotif(s Status) error {
 	var (
-		s   = im.GetStats()
 		out = importStatusTpl{
 			Name:     s.Name,
-			Status:   status,
+			Status:   s.Status,
 			Imported: s.Imported,
+			Rejected: s.Rejected,
 			Total:    s.Total,
 		}
-		subject = fmt.Sprintf("%s: %s import", cases.Title(language.Und).String(status), s.Name)
+		subject = fmt.Sprintf("%s: %s import", cases.Title(language.Und).String(s.Status), s.Name)
 	)
 	return im.opt.PostCB(subject, out)
 }
 
-// Start is a blocking function that selects on a channel queue until all
-// subscriber entries in the import session are imported. It should be
+// run processes the session's job and records the outcome. It's meant to be
 // invoked as a goroutine.
-func (s *Session) Start() {
+func (s *Session) run() {
+	defer func() {
+		s.im.Lock()
+		delete(s.im.sessions, s.job.ID)
+		s.im.Unlock()
+
+		// A slot is free. Pick up the next job in the queue.
+		s.im.Queue()
+	}()
+	defer os.Remove(s.job.FilePath)
+
+	s.log.Printf("processing '%s'", s.opt.Filename)
+
+	// Periodically save the stats and logs to the job while it runs.
 	var (
-		tx    *sql.Tx
-		stmt  *sql.Stmt
-		err   error
-		total = 0
-		cur   = 0
+		done = make(chan bool)
+		wg   sync.WaitGroup
 	)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+
+		t := time.NewTicker(saveInterval)
+		defer t.Stop()
+
+		for {
+			select {
+			case <-t.C:
+				s.save()
+			case <-done:
+				return
+			}
+		}
+	}()
 
-	listIDs := make([]int, len(s.opt.ListIDs))
-	copy(listIDs, s.opt.ListIDs)
+	err := s.process()
+	close(done)
+	wg.Wait()
 
-	for sub := range s.subQueue {
-		if cur == 0 {
-			// New transaction batch.
-			tx, err = s.im.db.Begin()
-			if err != nil {
-				s.log.Printf("error creating DB transaction: %v", err)
-				continue
+	status := StatusFinished
+	if err != nil {
+		s.log.Printf("import failed: %v", err)
+		status = StatusFailed
+	} else if s.getStats().Status == StatusStopping {
+		s.log.Printf("import stopped")
+		status = StatusStopped
+	} else {
+		s.log.Printf("import finished")
+		if !s.opt.DryRun {
+			if _, err := s.im.opt.UpdateListDateStmt.Exec(pq.Array(s.opt.ListIDs)); err != nil {
+				s.log.Printf("error updating lists date: %v", err)
 			}
+		}
+	}
+	s.setStatus(status)
+
+	// Save the CSV of rejected rows to the job.
+	s.closeErrors()
+	if st := s.getStats(); st.Rejected > 0 {
+		if b, err := os.ReadFile(s.errFile.Name()); err != nil {
+			s.log.Printf("error reading rejected rows: %v", err)
+		} else if err := s.im.store.SaveImportJobErrors(s.job.ID, b); err != nil {
+			s.log.Printf("error saving rejected rows: %v", err)
+		}
+	}
+	os.Remove(s.errFile.Name())
 
-			if s.opt.Mode == ModeSubscribe {
-				stmt = tx.Stmt(s.im.opt.UpsertStmt)
-			} else {
-				stmt = tx.Stmt(s.im.opt.BlocklistStmt)
-			}
+	s.save()
+
+	if !s.opt.DryRun {
+		s.im.sendNotif(s.getStats())
+	}
+}
+
+// process extracts the CSV from the job's file if it's a ZIP and loads and
+// imports it.
+func (s *Session) process() error {
+	path := s.job.FilePath
+
+	// Download the file if it's a remote one.
+	if path == "" {
+		p, err := s.fetch()
+		if err != nil {
+			return err
 		}
+		defer os.Remove(p)
 
-		uu, err := uuid.NewV4()
+		path = p
+	}
+
+	name := s.opt.Filename
+	if _, ok := formatExts[strings.ToLower(filepath.Ext(name))]; !ok {
+		// Only 1 file from the ZIP is considered. If multiple files have
+		// to be processed, counting the net number of lines (to track progress),
+		// keeping the import state (failed / successful) etc. across
+		// multiple files becomes complex. Instead, it's just easier for the
+		// end user to concat multiple CSVs (if there are multiple in the first)
+		// place and upload as one in the first place.
+		dir, files, err := s.ExtractZIP(path, 1)
 		if err != nil {
-			s.log.Printf("error generating UUID: %v", err)
-			tx.Rollback()
-			break
+			return err
 		}
+		defer os.RemoveAll(dir)
 
-		if s.opt.Mode == ModeSubscribe {
-			_, err = stmt.Exec(uu, sub.Email, sub.Name, sub.Attribs, pq.Array(listIDs), s.opt.SubStatus, s.opt.Overwrite)
-		} else if s.opt.Mode == ModeBlocklist {
-			_, err = stmt.Exec(uu, sub.Email, sub.Name, sub.Attribs)
+		name = files[0]
+		path = filepath.Join(dir, files[0])
+	}
+
+	format := s.opt.Format
+	if format == "" {
+		format = formatExts[strings.ToLower(filepath.Ext(name))]
+	}
+
+	// The loaders close the queue when they return, which ends Start.
+	loadErr := make(chan error, 1)
+	go func() {
+		switch format {
+		case FormatJSON:
+			loadErr <- s.LoadJSON(path)
+		case FormatJSONL:
+			loadErr <- s.LoadJSONL(path)
+		default:
+			loadErr <- s.LoadCSV(path, rune(s.opt.Delim[0]))
 		}
-		if err != nil {
-			s.log.Printf("error executing insert: %v", err)
-			tx.Rollback()
-			break
+	}()
+
+	err := s.Start()
+	if lErr := <-loadErr; lErr != nil {
+		return lErr
+	}
+
+	return err
+}
+
+// fetch downloads the session's file from its URL or the media store to a
+// temporary file and returns its path.
+func (s *Session) fetch() (string, error) {
+	var (
+		src io.ReadCloser
+		err error
+	)
+	if s.opt.URL != "" {
+		s.log.Printf("downloading '%s'", s.opt.URL)
+		src, err = s.fetchURL(s.opt.URL)
+	} else if s.opt.MediaKey != "" && s.im.opt.OpenMedia != nil {
+		s.log.Printf("fetching '%s' from the media store", s.opt.MediaKey)
+		src, err = s.im.opt.OpenMedia(s.opt.MediaKey)
+	} else {
+		return "", errors.New("no file to import")
+	}
+	if err != nil {
+		return "", err
+	}
+	defer src.Close()
+
+	out, err := os.CreateTemp("", "listmonk")
+	if err != nil {
+		return "", err
+	}
+	defer out.Close()
+
+	n, err := io.Copy(out, io.LimitReader(src, s.im.maxFetchSize+1))
+	if err == nil && n > s.im.maxFetchSize {
+		err = fmt.Errorf("file exceeds the max size of %d bytes", s.im.maxFetchSize)
+	}
+	if err != nil {
+		os.Remove(out.Name())
+		return "", err
+	}
+	s.log.Printf("fetched %d bytes", n)
+
+	return out.Name(), nil
+}
+
+// fetchURL makes a GET request to an HTTP(S) URL and returns the response body.
+// Only public addresses can be fetched, including on redirects.
+func (s *Session) fetchURL(u string) (io.ReadCloser, error) {
+	if !strings.HasPrefix(u, "http://") && !strings.HasPrefix(u, "https://") {
+		return nil, errors.New("only http(s) URLs are supported")
+	}
+
+	resp, err := utils.NewPublicHTTPClient(fetchTimeout).Get(u)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+
+	return resp.Body, nil
+}
+
+// save saves the session's stats and logs to its job.
+func (s *Session) save() {
+	st := s.getStats()
+	if err := s.im.store.UpdateImportJob(s.job.ID, st.Status, st.Total, st.Imported, st.Rejected, s.logBuf.String()); err != nil {
+		s.im.log.Printf("error updating import job %d: %v", s.job.ID, err)
+	}
+}
+
+// getStats returns the session's stats.
+func (s *Session) getStats() Status {
+	s.mut.RLock()
+	defer s.mut.RUnlock()
+
+	return s.status
+}
+
+// setStatus sets the session's status.
+func (s *Session) setStatus(status string) {
+	s.mut.Lock()
+	s.status.Status = status
+	s.mut.Unlock()
+}
+
+// incrementImportCount increments the session's "imported" counter.
+func (s *Session) incrementImportCount(n int) {
+	s.mut.Lock()
+	s.status.Imported += n
+	s.mut.Unlock()
+}
+
+// Start is a blocking function that selects on a channel queue until all
+// subscriber entries in the import session are imported. Rows that fail to
+// import are skipped and recorded in the CSV of rejected rows. The queue is
+// always drained, and an error is returned if any batch couldn't be committed.
+func (s *Session) Start() error {
+	listIDs := make([]int, len(s.opt.ListIDs))
+	copy(listIDs, s.opt.ListIDs)
+
+	// In a dry run, the rows have already been validated while loading.
+	// Nothing is written to the DB.
+	if s.opt.DryRun {
+		n := 0
+		for range s.subQueue {
+			n++
 		}
-		cur++
-		total++
+		s.incrementImportCount(n)
+		s.log.Printf("dry run finished. %d valid, %d rejected", n, s.getStats().Rejected)
+		return nil
+	}
 
-		// Batch size is met. Commit.
-		if cur%commitBatchSize == 0 {
-			if err := tx.Commit(); err != nil {
-				tx.Rollback()
-				s.log.Printf("error committing to DB: %v", err)
-			} else {
-				s.im.incrementImportCount(cur)
-				s.log.Printf("imported %d", total)
+	var (
+		batch  = make([]subRow, 0, commitBatchSize)
+		total  = 0
+		failed = false
+	)
+	commit := func() {
+		n, err := s.commit(batch, listIDs)
+		if err != nil {
+			// The batch couldn't be committed at all (eg: DB connection errors).
+			s.log.Printf("error committing to DB: %v", err)
+			for _, r := range batch {
+				s.reject(r.line, r.cols, err.Error())
 			}
-
-			cur = 0
+			failed = true
+		} else {
+			total += n
+			s.incrementImportCount(n)
+			s.log.Printf("imported %d", total)
 		}
+		batch = batch[:0]
 	}
 
-	// Queue's closed and there's nothing left to commit.
-	if cur == 0 {
-		s.im.setStatus(StatusFinished)
-		s.log.Printf("imported finished")
-		if _, err := s.im.opt.UpdateListDateStmt.Exec(pq.Array(listIDs)); err != nil {
-			s.log.Printf("error updating lists date: %v", err)
+	for r := range s.subQueue {
+		batch = append(batch, r)
+
+		// Batch size is met. Commit.
+		if len(batch) >= commitBatchSize {
+			commit()
 		}
-		s.im.sendNotif(StatusFinished)
-		return
 	}
 
 	// Queue's closed and there are records left to commit.
-	if err := tx.Commit(); err != nil {
-		tx.Rollback()
-		s.im.setStatus(StatusFailed)
-		s.log.Printf("error committing to DB: %v", err)
-		s.im.sendNotif(StatusFailed)
-		return
+	if len(batch) > 0 {
+		commit()
 	}
 
-	s.im.incrementImportCount(cur)
-	s.im.setStatus(StatusFinished)
-	s.log.Printf("imported finished")
-	if _, err := s.im.opt.UpdateListDateStmt.Exec(pq.Array(listIDs)); err != nil {
-		s.log.Printf("error updating lists date: %v", err)
+	if failed {
+		return errors.New("error committing to DB")
 	}
 
-	s.im.sendNotif(StatusFinished)
+	return nil
 }
 
-// Stop stops an active import session.
-func (s *Session) Stop() {
-	close(s.subQueue)
+// commit inserts a batch of subscribers in a single transaction and returns the
+// number of rows inserted. If a row fails, the transaction is rolled back and the
+// batch is retried row by row with savepoints so that only the failing rows are
+// skipped and rejected.
+func (s *Session) commit(batch []subRow, listIDs []int) (int, error) {
+	n, err := s.insert(batch, listIDs, false)
+	if err == errRowFailed {
+		return s.insert(batch, listIDs, true)
+	}
+
+	return n, err
 }
 
-// ExtractZIP takes a ZIP file's path and extracts all .csv files in it to
-// a temporary directory, and returns the name of the temp directory and the
-// list of extracted .csv files.
-func (s *Session) ExtractZIP(srcPath string, maxCSVs int) (string, []string, error) {
-	if s.im.isDone() {
-		return "", nil, ErrIsImporting
+var (
+	// errRowFailed indicates that a row in a batch failed to insert.
+	errRowFailed = errors.New("row failed")
+
+	// errSubNotFound indicates that the subscriber to unsubscribe, delete, or
+	// update doesn't exist.
+	errSubNotFound = errors.New("subscriber not found")
+)
+
+// insert inserts a batch of subscribers in a transaction. If safe is false,
+// errRowFailed is returned on the first row that fails. Otherwise, every row
+// is inserted under a savepoint and failing rows are rejected.
+func (s *Session) insert(batch []subRow, listIDs []int, safe bool) (int, error) {
+	tx, err := s.im.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var stmt *sql.Stmt
+	switch s.opt.Mode {
+	case ModeSubscribe:
+		stmt = tx.Stmt(s.im.opt.UpsertStmt)
+	case ModeUnsubscribe:
+		stmt = tx.Stmt(s.im.opt.UnsubscribeStmt)
+	case ModeDelete:
+		stmt = tx.Stmt(s.im.opt.DeleteStmt)
+	case ModeUpdate:
+		stmt = tx.Stmt(s.im.opt.UpdateStmt)
+	default:
+		stmt = tx.Stmt(s.im.opt.BlocklistStmt)
 	}
 
-	failed := true
-	defer func() {
-		if failed {
-			s.im.setStatus(StatusFailed)
+	n := 0
+	for _, r := range batch {
+		if safe {
+			if _, err := tx.Exec("SAVEPOINT sub"); err != nil {
+				return 0, err
+			}
 		}
-	}()
 
+		if err := s.insertSub(stmt, r.sub, listIDs); err != nil {
+			// The subscriber to modify doesn't exist. Nothing failed in the DB,
+			// so the transaction can go on.
+			if err == errSubNotFound {
+				s.log.Printf("skipping line %d: subscriber '%s' not found", r.line, r.sub.Email)
+				s.reject(r.line, r.cols, err.Error())
+				continue
+			}
+
+			if !safe {
+				return 0, errRowFailed
+			}
+
+			if _, err := tx.Exec("ROLLBACK TO SAVEPOINT sub"); err != nil {
+				return 0, err
+			}
+
+			s.log.Printf("skipping line %d: error executing insert: %v", r.line, err)
+			s.reject(r.line, r.cols, err.Error())
+			continue
+		}
+		n++
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	return n, nil
+}
+
+// insertSub inserts a single subscriber using the given statement.
+func (s *Session) insertSub(stmt *sql.Stmt, sub SubReq, listIDs []int) error {
+	uu, err := uuid.NewV4()
+	if err != nil {
+		return err
+	}
+
+	var id int
+	switch s.opt.Mode {
+	case ModeSubscribe:
+		_, err = stmt.Exec(uu, sub.Email, sub.Name, sub.Attribs, pq.Array(listIDs), s.opt.SubStatus, s.opt.Overwrite)
+	case ModeUnsubscribe:
+		err = stmt.QueryRow(sub.Email, pq.Array(listIDs)).Scan(&id)
+	case ModeDelete:
+		err = stmt.QueryRow(sub.Email).Scan(&id)
+	case ModeUpdate:
+		// Without attributes in the row, the existing ones are left as-is.
+		var attribs any
+		if len(sub.Attribs) > 0 {
+			attribs = sub.Attribs
+		}
+		err = stmt.QueryRow(sub.Email, sub.Name, attribs, s.opt.Overwrite).Scan(&id)
+	default:
+		_, err = stmt.Exec(uu, sub.Email, sub.Name, sub.Attribs)
+	}
+
+	if err == sql.ErrNoRows {
+		return errSubNotFound
+	}
+
+	return err
+}
+
+// validate validates a subscriber's fields for the session's mode. Modes that
+// modify existing subscribers only need a valid e-mail to look them up, and
+// names aren't generated for them.
+func (s *Session) validate(sub SubReq) (SubReq, error) {
+	switch s.opt.Mode {
+	case ModeUnsubscribe, ModeDelete, ModeUpdate:
+		email := strings.ToLower(strings.TrimSpace(sub.Email))
+		if em, err := mail.ParseAddress(email); len(email) > 1000 || err != nil || em.Address != email {
+			return sub, errors.New(s.im.i18n.T("subscribers.invalidEmail"))
+		}
+		sub.Email = email
+		sub.Name = strings.TrimSpace(sub.Name)
+
+	default:
+		var err error
+		if sub, err = s.im.ValidateFields(sub); err != nil {
+			return sub, err
+		}
+	}
+
+	// Attributes aren't used while unsubscribing or deleting.
+	if s.opt.Mode == ModeUnsubscribe || s.opt.Mode == ModeDelete || s.im.opt.AttribSchema == nil {
+		return sub, nil
+	}
+
+	sc, err := s.im.opt.AttribSchema()
+	if err != nil {
+		return sub, err
+	}
+
+	// Attribute updates don't have to carry the required attributes.
+	attribs, err := sc.Validate(sub.Attribs, s.opt.Mode == ModeUpdate)
+	if err != nil {
+		return sub, errors.New(s.im.i18n.Ts("subscribers.invalidAttribs", "error", err.Error()))
+	}
+	if len(attribs) > 0 {
+		sub.Attribs = attribs
+	}
+
+	return sub, nil
+}
+
+// reject records a rejected row with the reason in the CSV of rejected rows.
+func (s *Session) reject(line int, cols []string, reason string) {
+	s.errMut.Lock()
+	_ = s.errW.Write(append([]string{strconv.Itoa(line), reason}, cols...))
+	s.errMut.Unlock()
+
+	s.mut.Lock()
+	s.status.Rejected++
+	s.mut.Unlock()
+}
+
+// closeErrors flushes and closes the CSV of rejected rows.
+func (s *Session) closeErrors() {
+	s.errMut.Lock()
+	defer s.errMut.Unlock()
+
+	s.errW.Flush()
+	if err := s.errW.Error(); err != nil {
+		s.log.Printf("error writing rejected rows: %v", err)
+	}
+	s.errFile.Close()
+}
+
+// ExtractZIP takes a ZIP file's path and extracts all .csv, .json and .jsonl files
+// in it to a temporary directory, and returns the name of the temp directory
+// and the list of extracted files.
+func (s *Session) ExtractZIP(srcPath string, maxCSVs int) (string, []string, error) {
 	z, err := zip.OpenReader(srcPath)
 	if err != nil {
 		return "", nil, err
@@ -386,6 +963,14 @@ func (s *Session) ExtractZIP(srcPath string, maxCSVs int) (string, []string, err
 		return "", nil, err
 	}
 
+	// Remove the directory if the extraction fails.
+	failed := true
+	defer func() {
+		if failed {
+			os.RemoveAll(dir)
+		}
+	}()
+
 	files := make([]string, 0, len(z.File))
 	for _, f := range z.File {
 		fName := f.FileInfo().Name()
@@ -396,9 +981,9 @@ func (s *Session) ExtractZIP(srcPath string, maxCSVs int) (string, []string, err
 			continue
 		}
 
-		// Skip files without the .csv extension.
-		if !strings.HasSuffix(strings.ToLower(fName), ".csv") {
-			s.log.Printf("skipping non .csv file '%s'", fName)
+		// Skip files that aren't in a supported format.
+		if _, ok := formatExts[strings.ToLower(filepath.Ext(fName))]; !ok {
+			s.log.Printf("skipping unsupported file '%s'", fName)
 			continue
 		}
 
@@ -431,33 +1016,24 @@ func (s *Session) ExtractZIP(srcPath string, maxCSVs int) (string, []string, err
 	}
 
 	if len(files) == 0 {
-		s.log.Println("no CSV files found in the ZIP")
-		return "", nil, errors.New("no CSV files found in the ZIP")
+		s.log.Println("no CSV or JSON files found in the ZIP")
+		return "", nil, errors.New("no CSV or JSON files found in the ZIP")
 	}
 
 	failed = false
 	return dir, files, nil
 }
 
-// LoadCSV loads a CSV file and validates and imports the subscriber entries in it.
+// LoadCSV loads a CSV file and validates and queues the subscriber entries in it
+// for import. The queue is closed when it returns.
 func (s *Session) LoadCSV(srcPath string, delim rune) error {
-	if s.im.isDone() {
-		return ErrIsImporting
-	}
-
-	// Default status is "failed" in case the function
-	// returns at one of the many possible errors.
-	failed := true
-	defer func() {
-		if failed {
-			s.im.setStatus(StatusFailed)
-		}
-	}()
+	defer close(s.subQueue)
 
 	f, err := os.Open(srcPath)
 	if err != nil {
 		return err
 	}
+	defer f.Close()
 
 	// Count the total number of lines in the file. This doesn't distinguish
 	// between "blank" and non "blank" lines, and is only used to derive
@@ -473,9 +1049,9 @@ func (s *Session) LoadCSV(srcPath string, delim rune) error {
 	}
 
 	// Exclude the header from count.
-	s.im.Lock()
-	s.im.status.Total = numLines - 1
-	s.im.Unlock()
+	s.mut.Lock()
+	s.status.Total = numLines - 1
+	s.mut.Unlock()
 
 	// Rewind, now that we've done a linecount on the same handler.
 	_, _ = f.Seek(0, 0)
@@ -489,25 +1065,45 @@ func (s *Session) LoadCSV(srcPath string, delim rune) error {
 		return err
 	}
 
-	hdrKeys := s.mapCSVHeaders(csvHdr, csvHeaders)
-	// email is a required header.
-	if _, ok := hdrKeys["email"]; !ok {
-		s.log.Printf("'email' column not found in '%s'", srcPath)
-		return errors.New("'email' column not found")
+	// Write the header of the CSV of rejected rows.
+	s.errMut.Lock()
+	_ = s.errW.Write(append([]string{"line", "reason"}, csvHdr...))
+	s.errMut.Unlock()
+
+	// Map the columns with the mapping if there's one, or the known headers.
+	var (
+		hdrKeys map[string]int
+		mapIdx  []int
+	)
+	if len(s.opt.Mapping) > 0 {
+		mapIdx, err = s.mapColumns(csvHdr)
+		if err != nil {
+			s.log.Printf("error mapping columns in '%s': %v", srcPath, err)
+			return err
+		}
+	} else {
+		hdrKeys = s.mapCSVHeaders(csvHdr, csvHeaders)
+
+		// email is a required header.
+		if _, ok := hdrKeys["email"]; !ok {
+			s.log.Printf("'email' column not found in '%s'", srcPath)
+			return errors.New("'email' column not found")
+		}
 	}
 
 	var (
 		lnHdr = len(hdrKeys)
 		i     = 0
+
+		// E-mails seen so far to detect duplicates in the file.
+		seen = newSeenEmails(maxSeen)
 	)
 	for {
 		i++
 
 		// Check for the stop signal.
 		select {
-		case <-s.im.stop:
-			failed = false
-			close(s.subQueue)
+		case <-s.stop:
 			s.log.Println("stop request received")
 			return nil
 		default:
@@ -519,6 +1115,7 @@ func (s *Session) LoadCSV(srcPath string, delim rune) error {
 		} else if err != nil {
 			if err, ok := err.(*csv.ParseError); ok && err.Err == csv.ErrFieldCount {
 				s.log.Printf("skipping line %d. %v", i, err)
+				s.reject(err.StartLine, cols, err.Error())
 				continue
 			} else {
 				s.log.Printf("error reading CSV '%s'", err)
@@ -526,70 +1123,347 @@ func (s *Session) LoadCSV(srcPath string, delim rune) error {
 			}
 		}
 
+		// Line number of the row in the file.
+		line, _ := rd.FieldPos(0)
+
 		lnCols := len(cols)
 		if lnCols < lnHdr {
 			s.log.Printf("skipping line %d. column count (%d) does not match minimum header count (%d)", i, lnCols, lnHdr)
+			s.reject(line, cols, fmt.Sprintf("column count (%d) does not match minimum header count (%d)", lnCols, lnHdr))
 			continue
 		}
 
-		// Iterate the key map and based on the indices mapped earlier,
-		// form a map of key: csv_value, eg: email: user@user.com.
-		row := make(map[string]string, lnCols)
-		for key := range hdrKeys {
-			row[key] = cols[hdrKeys[key]]
+		var sub SubReq
+		if mapIdx != nil {
+			// Map the columns and coerce the values with the mapping.
+			sub, err = s.applyMapping(mapIdx, cols)
+			if err == nil {
+				sub, err = s.validate(sub)
+			}
+			if err != nil {
+				s.log.Printf("skipping line %d: %v: %v", i, err, cols)
+				s.reject(line, cols, err.Error())
+				continue
+			}
+		} else {
+			// Iterate the key map and based on the indices mapped earlier,
+			// form a map of key: csv_value, eg: email: user@user.com.
+			row := make(map[string]string, lnCols)
+			for key := range hdrKeys {
+				row[key] = cols[hdrKeys[key]]
+			}
+
+			sub.Email = row["email"]
+			if v, ok := row["name"]; ok {
+				sub.Name = v
+			}
+
+			// JSON attributes. They're parsed before validation so that
+			// they're checked against the attribute schema.
+			if len(row["attributes"]) > 0 {
+				var (
+					attribs models.JSON
+					b       = []byte(row["attributes"])
+				)
+				if err := json.Unmarshal(b, &attribs); err != nil {
+					s.log.Printf("invalid attributes JSON on line %d for '%s'. importing without attributes: %v", i, sub.Email, err)
+				} else {
+					sub.Attribs = attribs
+				}
+			}
+
+			sub, err = s.validate(sub)
+			if err != nil {
+				s.log.Printf("skipping line %d: %v: %v", i, err, cols)
+				s.reject(line, cols, err.Error())
+				continue
+			}
 		}
 
-		sub := SubReq{}
-		sub.Email = row["email"]
+		// Skip duplicate e-mails in the file.
+		if !seen.add(sub.Email) {
+			s.log.Printf("skipping line %d: duplicate e-mail '%s'", i, sub.Email)
+			s.reject(line, cols, "duplicate e-mail")
+			continue
+		}
+
+		// Send the subscriber to the queue.
+		s.subQueue <- subRow{sub: sub, line: line, cols: cols}
+	}
+
+	return nil
+}
+
+// LoadJSONL loads a JSON Lines (NDJSON) file, where each line is a JSON object
+// with the subscriber's email, name and attribs, and validates and queues the
+// subscriber entries in it for import. Nested objects in attribs (or
+// attributes) are stored as-is, and any other keys in the object are also
+// stored in attribs. The queue is closed when it returns.
+func (s *Session) LoadJSONL(srcPath string) error {
+	defer close(s.subQueue)
+
+	f, err := os.Open(srcPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	// Count the total number of lines in the file. This is only used to derive
+	// the progress percentage for the frontend.
+	numLines, err := countLines(f)
+	if err != nil {
+		s.log.Printf("error counting lines in '%s': '%v'", srcPath, err)
+		return err
+	}
+
+	if numLines == 0 {
+		return errors.New("empty file")
+	}
+
+	s.mut.Lock()
+	s.status.Total = numLines
+	s.mut.Unlock()
 
-		if v, ok := row["name"]; ok {
-			sub.Name = v
+	// Rewind, now that we've done a linecount on the same handler.
+	_, _ = f.Seek(0, 0)
+	rd := bufio.NewReader(f)
+
+	// Write the header of the CSV of rejected rows.
+	s.errMut.Lock()
+	_ = s.errW.Write([]string{"line", "reason", "record"})
+	s.errMut.Unlock()
+
+	var (
+		line = 0
+
+		// E-mails seen so far to detect duplicates in the file.
+		seen = newSeenEmails(maxSeen)
+	)
+	for {
+		// Check for the stop signal.
+		select {
+		case <-s.stop:
+			s.log.Println("stop request received")
+			return nil
+		default:
 		}
 
-		sub, err = s.im.ValidateFields(sub)
-		if err != nil {
-			s.log.Printf("skipping line %d: %v: %v", i, err, cols)
+		b, err := rd.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			s.log.Printf("error reading '%s': %v", srcPath, err)
+			return err
+		}
+		if len(b) == 0 && err == io.EOF {
+			break
+		}
+		line++
+
+		// Skip blank lines.
+		rec := bytes.TrimSpace(b)
+		if len(rec) == 0 {
 			continue
 		}
 
-		// JSON attributes.
-		if len(row["attributes"]) > 0 {
-			var (
-				attribs models.JSON
-				b       = []byte(row["attributes"])
-			)
-			if err := json.Unmarshal(b, &attribs); err != nil {
-				s.log.Printf("skipping invalid attributes JSON on line %d for '%s': %v", i, sub.Email, err)
-			} else {
-				sub.Attribs = attribs
-			}
+		s.queueJSONRecord(line, rec, seen)
+	}
+
+	return nil
+}
+
+// LoadJSON loads a JSON file with an array of subscriber objects, which are
+// the same as the ones in JSON Lines files, and validates and queues the
+// subscriber entries in it for import. The queue is closed when it returns.
+func (s *Session) LoadJSON(srcPath string) error {
+	defer close(s.subQueue)
+
+	f, err := os.Open(srcPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	// Count the number of records. This is only used to derive the progress
+	// percentage for the frontend.
+	num := 0
+	if err := readJSONArray(f, func([]byte) bool { num++; return true }); err != nil {
+		s.log.Printf("error reading '%s': %v", srcPath, err)
+		return err
+	}
+
+	if num == 0 {
+		return errors.New("empty file")
+	}
+
+	s.mut.Lock()
+	s.status.Total = num
+	s.mut.Unlock()
+
+	_, _ = f.Seek(0, 0)
+
+	// Write the header of the CSV of rejected rows.
+	s.errMut.Lock()
+	_ = s.errW.Write([]string{"line", "reason", "record"})
+	s.errMut.Unlock()
+
+	var (
+		n = 0
+
+		// E-mails seen so far to detect duplicates in the file.
+		seen = newSeenEmails(maxSeen)
+	)
+
+	// The "line" of a record is its position in the array.
+	return readJSONArray(f, func(rec []byte) bool {
+		// Check for the stop signal.
+		select {
+		case <-s.stop:
+			s.log.Println("stop request received")
+			return false
+		default:
 		}
 
-		// Send the subscriber to the queue.
-		s.subQueue <- sub
+		n++
+		s.queueJSONRecord(n, rec, seen)
+		return true
+	})
+}
+
+// readJSONArray reads a JSON array and calls cb with each of its elements
+// until it returns false.
+func readJSONArray(r io.Reader, cb func(rec []byte) bool) error {
+	dec := json.NewDecoder(bufio.NewReader(r))
+	if t, err := dec.Token(); err != nil || t != json.Delim('[') {
+		return errors.New("JSON file should be an array of objects")
 	}
 
-	close(s.subQueue)
-	failed = false
+	for dec.More() {
+		var rec json.RawMessage
+		if err := dec.Decode(&rec); err != nil {
+			return fmt.Errorf("invalid JSON: %v", err)
+		}
+		if !cb(rec) {
+			return nil
+		}
+	}
+
+	if _, err := dec.Token(); err != nil {
+		return fmt.Errorf("invalid JSON: %v", err)
+	}
 
 	return nil
 }
 
-// Stop sends a signal to stop the existing import.
-func (im *Importer) Stop() {
-	if im.getStatus() != StatusImporting {
-		im.Lock()
-		im.status = Status{Status: StatusNone}
-		im.Unlock()
+// queueJSONRecord parses a subscriber JSON object and sends it to the queue,
+// or rejects it.
+func (s *Session) queueJSONRecord(line int, rec []byte, seen *seenEmails) {
+	sub, err := s.parseJSONRecord(rec)
+	if err != nil {
+		s.log.Printf("skipping line %d: %v", line, err)
+		s.reject(line, []string{string(rec)}, err.Error())
+		return
+	}
 
+	// Skip duplicate e-mails in the file.
+	if !seen.add(sub.Email) {
+		s.log.Printf("skipping line %d: duplicate e-mail '%s'", line, sub.Email)
+		s.reject(line, []string{string(rec)}, "duplicate e-mail")
 		return
 	}
 
-	select {
-	case im.stop <- true:
-		im.setStatus(StatusStopping)
-	default:
+	// Send the subscriber to the queue.
+	s.subQueue <- subRow{sub: sub, line: line, cols: []string{string(rec)}}
+}
+
+// parseJSONRecord parses and validates a subscriber from a JSON object.
+func (s *Session) parseJSONRecord(b []byte) (SubReq, error) {
+	var rec map[string]any
+
+	// Decode numbers as-is so that large integers in attribs retain their precision.
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	if err := dec.Decode(&rec); err != nil || dec.More() || rec == nil {
+		return SubReq{}, errors.New("invalid JSON object")
+	}
+
+	sub := SubReq{}
+	if v, ok := rec["email"].(string); ok {
+		sub.Email = v
+	}
+	if v, ok := rec["name"].(string); ok {
+		sub.Name = v
+	}
+
+	// Keys other than the known fields go into attribs. Explicit attribs take precedence.
+	attribs := models.JSON{}
+	for k, v := range rec {
+		switch k {
+		case "email", "name", "attribs", "attributes":
+			continue
+		}
+		attribs[k] = v
 	}
+	for _, k := range []string{"attributes", "attribs"} {
+		v, ok := rec[k]
+		if !ok || v == nil {
+			continue
+		}
+
+		mp, ok := v.(map[string]any)
+		if !ok {
+			return sub, fmt.Errorf("'%s' is not a JSON object", k)
+		}
+		for k, v := range mp {
+			attribs[k] = v
+		}
+	}
+	sub.Attribs = attribs
+
+	return s.validate(sub)
+}
+
+// Write writes p to the buffer. Once the buffer reaches its max size,
+// further writes are discarded.
+func (b *logBuffer) Write(p []byte) (int, error) {
+	b.Lock()
+	defer b.Unlock()
+
+	if b.full {
+		return len(p), nil
+	}
+
+	if b.buf.Len()+len(p) > maxLogSize {
+		b.full = true
+		b.buf.WriteString("log size limit reached. Further entries are not recorded.\n")
+		return len(p), nil
+	}
+
+	return b.buf.Write(p)
+}
+
+// String returns the logs sanitized for storing in the DB.
+func (b *logBuffer) String() string {
+	b.Lock()
+	defer b.Unlock()
+
+	return strings.ReplaceAll(strings.ToValidUTF8(b.buf.String(), ""), "\x00", "")
+}
+
+func newSeenEmails(max int) *seenEmails {
+	return &seenEmails{seed: maphash.MakeSeed(), hashes: make(map[uint64]struct{}), max: max}
+}
+
+// add records an e-mail and returns false if it has already been seen.
+func (s *seenEmails) add(email string) bool {
+	h := maphash.String(s.seed, email)
+	if _, ok := s.hashes[h]; ok {
+		return false
+	}
+
+	if len(s.hashes) < s.max {
+		s.hashes[h] = struct{}{}
+	}
+
+	return true
 }
 
 // SanitizeEmail validates and sanitizes an e-mail string and returns the lowercased,
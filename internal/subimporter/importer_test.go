@@ -0,0 +1,565 @@
+package subimporter
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/knadh/listmonk/internal/i18n"
+	"github.com/knadh/listmonk/internal/utils"
+	"github.com/knadh/listmonk/models"
+)
+
+// store is an in-memory Store of import jobs.
+type store struct {
+	mu     sync.Mutex
+	jobs   []*models.ImportJob
+	errors map[int][]byte
+}
+
+func newStore(jobs ...*models.ImportJob) *store {
+	return &store{jobs: jobs, errors: map[int][]byte{}}
+}
+
+func (s *store) NextImportJob() (*models.ImportJob, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, j := range s.jobs {
+		if j.Status == StatusQueued {
+			j.Status = StatusImporting
+			out := *j
+			return &out, nil
+		}
+	}
+	return nil, nil
+}
+
+func (s *store) UpdateImportJob(id int, status string, total, imported, rejected int, log string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, j := range s.jobs {
+		if j.ID == id {
+			j.Status, j.Total, j.Imported, j.Rejected, j.Log = status, total, imported, rejected, log
+		}
+	}
+	return nil
+}
+
+func (s *store) SaveImportJobErrors(id int, b []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.errors[id] = b
+	return nil
+}
+
+func (s *store) FailInterruptedImportJobs() ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var out []string
+	for _, j := range s.jobs {
+		if j.Status == StatusImporting || j.Status == StatusStopping {
+			j.Status = StatusFailed
+			out = append(out, j.FilePath)
+		}
+	}
+	return out, nil
+}
+
+// get returns a copy of a job.
+func (s *store) get(id int) models.ImportJob {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, j := range s.jobs {
+		if j.ID == id {
+			return *j
+		}
+	}
+	return models.ImportJob{}
+}
+
+// newJob writes a file and returns a queued job to import it with the given options.
+func newJob(t *testing.T, id int, opt SessionOpt, name, body string) *models.ImportJob {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(body), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if opt.Delim == "" {
+		opt.Delim = ","
+	}
+	opt.Filename = name
+	params, _ := json.Marshal(opt)
+
+	return &models.ImportJob{ID: id, Params: params, FilePath: path, DryRun: opt.DryRun, Status: StatusQueued}
+}
+
+func newTestImporter(t *testing.T, opt Options) *Importer {
+	t.Helper()
+
+	b, err := os.ReadFile("../../i18n/en.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	i, err := i18n.New(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return New(opt, newStore(), nil, i, log.New(io.Discard, "", 0))
+}
+
+// load writes a file and loads it with the loader into a new session with the
+// given options, returning the queued subscribers and the rejected rows.
+func load(t *testing.T, im *Importer, opt SessionOpt, name, body string,
+	loader func(*Session, string) error) ([]SubReq, [][]string) {
+	t.Helper()
+
+	if opt.Delim == "" {
+		opt.Delim = ","
+	}
+	params, _ := json.Marshal(opt)
+	s, err := im.newSession(models.ImportJob{Params: params})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(s.errFile.Name())
+
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(body), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	errCh := make(chan error, 1)
+	go func() { errCh <- loader(s, path) }()
+
+	var subs []SubReq
+	for r := range s.subQueue {
+		subs = append(subs, r.sub)
+	}
+	if err := <-errCh; err != nil {
+		t.Fatal(err)
+	}
+
+	s.errW.Flush()
+	_, _ = s.errFile.Seek(0, 0)
+	rd := csv.NewReader(s.errFile)
+	rd.FieldsPerRecord = -1
+	rows, err := rd.ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Skip the header.
+	if len(rows) > 0 {
+		rows = rows[1:]
+	}
+
+	return subs, rows
+}
+
+func loadJSON(s *Session, path string) error  { return s.LoadJSON(path) }
+func loadJSONL(s *Session, path string) error { return s.LoadJSONL(path) }
+func loadCSV(s *Session, path string) error   { return s.LoadCSV(path, ',') }
+
+func TestLoadJSON(t *testing.T) {
+	im := newTestImporter(t, Options{})
+
+	in := `[
+		{"email": "one@example.com", "name": "One", "attribs": {"plan": "pro"}},
+		{"email": "two@example.com", "name": "Two", "plan": "free", "address": {"city": "Berlin"}},
+		{"email": "invalid", "name": "Bad"},
+		{"email": "ONE@example.com", "name": "Dupe"},
+		"not an object"
+	]`
+	subs, rej := load(t, im, SessionOpt{Mode: ModeSubscribe}, "subs.json", in, loadJSON)
+
+	if len(subs) != 2 {
+		t.Fatalf("expected 2 subscribers, got %d", len(subs))
+	}
+	if subs[0].Email != "one@example.com" || subs[0].Attribs["plan"] != "pro" {
+		t.Errorf("unexpected subscriber: %+v", subs[0])
+	}
+	if subs[1].Attribs["plan"] != "free" || subs[1].Attribs["address"].(map[string]any)["city"] != "Berlin" {
+		t.Errorf("unexpected attribs: %+v", subs[1].Attribs)
+	}
+
+	// Lines are the positions in the array.
+	if len(rej) != 3 || rej[0][0] != "3" || rej[1][0] != "4" || rej[1][1] != "duplicate e-mail" || rej[2][0] != "5" {
+		t.Errorf("unexpected rejected rows: %q", rej)
+	}
+
+	// JSON that isn't an array is an error.
+	for _, in := range []string{`{"email": "one@example.com"}`, `[{"email": "one@example.com"}`, `[]`} {
+		params, _ := json.Marshal(SessionOpt{Mode: ModeSubscribe, Delim: ","})
+		s, _ := im.newSession(models.ImportJob{Params: params})
+		path := filepath.Join(t.TempDir(), "bad.json")
+		_ = os.WriteFile(path, []byte(in), 0600)
+
+		go func() {
+			for range s.subQueue {
+			}
+		}()
+		if err := s.LoadJSON(path); err == nil {
+			t.Errorf("expected error for %q", in)
+		}
+		os.Remove(s.errFile.Name())
+	}
+}
+
+func TestLoadJSONL(t *testing.T) {
+	im := newTestImporter(t, Options{})
+
+	in := `{"email": "one@example.com", "name": "One", "attributes": {"plan": "pro"}}
+
+{"email": "two@example.com", "name": "Two", "attribs": "bad"}
+{bad json
+{"email": "three@example.com", "name": "Three", "age": 30}
+`
+	subs, rej := load(t, im, SessionOpt{Mode: ModeSubscribe}, "subs.jsonl", in, loadJSONL)
+
+	if len(subs) != 2 || subs[1].Email != "three@example.com" {
+		t.Fatalf("unexpected subscribers: %+v", subs)
+	}
+	if n, _ := subs[1].Attribs["age"].(json.Number).Int64(); n != 30 {
+		t.Errorf("unexpected attribs: %+v", subs[1].Attribs)
+	}
+	if len(rej) != 2 || rej[0][0] != "3" || rej[1][0] != "4" {
+		t.Errorf("unexpected rejected rows: %q", rej)
+	}
+}
+
+func TestFetchURL(t *testing.T) {
+	im := newTestImporter(t, Options{})
+	params, _ := json.Marshal(SessionOpt{Mode: ModeSubscribe, Delim: ","})
+	s, err := im.newSession(models.ImportJob{Params: params})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(s.errFile.Name())
+
+	// Loopback addresses (httptest) can't be fetched, directly or on redirects.
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "email,name\n")
+	}))
+	defer srv.Close()
+
+	for _, u := range []string{srv.URL, "http://localhost:1/x.csv", "http://169.254.169.254/latest/meta-data"} {
+		if _, err := s.fetchURL(u); !errors.Is(err, utils.ErrPrivateAddr) {
+			t.Errorf("%s: expected private address error, got %v", u, err)
+		}
+	}
+
+	if _, err := s.fetchURL("file:///etc/passwd"); err == nil {
+		t.Error("expected error for a non-HTTP URL")
+	}
+}
+
+func TestLoadCSVModes(t *testing.T) {
+	im := newTestImporter(t, Options{})
+
+	in := "email,name\n Jane.Doe@Example.com ,\nbad,Bad\n"
+	cases := []struct {
+		mode string
+		name string
+	}{
+		// New subscribers get a name from the e-mail.
+		{ModeSubscribe, "Jane Doe"},
+
+		// Existing subscribers are only looked up by e-mail.
+		{ModeUnsubscribe, ""},
+		{ModeDelete, ""},
+		{ModeUpdate, ""},
+	}
+
+	for _, c := range cases {
+		t.Run(c.mode, func(t *testing.T) {
+			subs, rej := load(t, im, SessionOpt{Mode: c.mode}, "subs.csv", in, loadCSV)
+			if len(subs) != 1 || subs[0].Email != "jane.doe@example.com" || subs[0].Name != c.name {
+				t.Errorf("unexpected subscribers: %+v", subs)
+			}
+			if len(rej) != 1 || rej[0][0] != "3" {
+				t.Errorf("unexpected rejected rows: %q", rej)
+			}
+		})
+	}
+}
+
+func TestAttribSchema(t *testing.T) {
+	im := newTestImporter(t, Options{
+		AttribSchema: func() (models.AttribSchema, error) {
+			return models.AttribSchema{
+				{Name: "plan", Type: models.AttribTypeString, Enum: []string{"free", "pro"}, Required: true},
+				{Name: "age", Type: models.AttribTypeNumber},
+			}, nil
+		},
+	})
+
+	mapping := models.ImportMapping{
+		{Column: "E-mail", Field: "email"},
+		{Column: "Plan", Field: "attribs.plan"},
+		{Column: "Age", Field: "attribs.age", Type: TypeInt},
+	}
+
+	cases := []struct {
+		name   string
+		opt    SessionOpt
+		file   string
+		in     string
+		loader func(*Session, string) error
+		first  string
+	}{
+		{
+			name: "mapped CSV", opt: SessionOpt{Mode: ModeSubscribe, Mapping: mapping}, file: "subs.csv",
+			in:     "E-mail,Plan,Age\none@example.com,pro,30\ntwo@example.com,,\nthree@example.com,gold,1\n",
+			loader: loadCSV, first: "3",
+		},
+		{
+			name: "CSV", opt: SessionOpt{Mode: ModeSubscribe}, file: "subs.csv",
+			in: "email,name,attributes\n" +
+				`one@example.com,One,"{""Plan"": ""pro"", ""age"": 30}"` + "\n" +
+				`two@example.com,Two,"{""age"": 30}"` + "\n" +
+				`three@example.com,Three,"{""plan"": ""pro"", ""age"": ""old""}"` + "\n",
+			loader: loadCSV, first: "3",
+		},
+		{
+			name: "JSONL", opt: SessionOpt{Mode: ModeSubscribe}, file: "subs.jsonl",
+			in: `{"email": "one@example.com", "attribs": {"Plan": "pro", "age": 30}}` + "\n" +
+				`{"email": "two@example.com", "age": 30}` + "\n" +
+				`{"email": "three@example.com", "plan": "gold"}` + "\n",
+			loader: loadJSONL, first: "2",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			subs, rej := load(t, im, c.opt, c.file, c.in, c.loader)
+
+			// Attribute names are normalised to the schema's names.
+			if len(subs) != 1 || subs[0].Attribs["plan"] != "pro" {
+				t.Fatalf("unexpected subscribers: %+v", subs)
+			}
+			if _, ok := subs[0].Attribs["Plan"]; ok {
+				t.Errorf("attribute name not normalised: %+v", subs[0].Attribs)
+			}
+
+			// Missing required attributes and invalid values are rejected.
+			if len(rej) != 2 || rej[0][0] != c.first {
+				t.Errorf("unexpected rejected rows: %q", rej)
+			}
+		})
+	}
+
+	// Attribute updates don't need the required attributes, but the values
+	// are still checked.
+	in := "email,attributes\n" + `one@example.com,"{""age"": 30}"` + "\n" + `two@example.com,"{""plan"": ""gold""}"` + "\n"
+	subs, rej := load(t, im, SessionOpt{Mode: ModeUpdate}, "subs.csv", in, loadCSV)
+	if len(subs) != 1 || len(rej) != 1 {
+		t.Errorf("unexpected update results: %+v, %q", subs, rej)
+	}
+}
+
+func TestDryRun(t *testing.T) {
+	im := newTestImporter(t, Options{})
+
+	// Nothing is written to the DB (there's none) and the rejected rows
+	// are saved to the job.
+	in := "email,name,attributes\n" +
+		`one@example.com,One,"{""plan"": ""pro""}"` + "\n" +
+		"invalid,Bad,\n" +
+		`two@example.com,Two,{bad json` + "\n" +
+		"ONE@example.com,Dupe,\n" +
+		"three@example.com\n"
+	job := newJob(t, 1, SessionOpt{Mode: ModeSubscribe, DryRun: true}, "subs.csv", in)
+	st := newStore(job)
+	im.store = st
+
+	s, err := im.newSession(*job)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.run()
+
+	// Rows with invalid attributes JSON are imported without them.
+	j := st.get(1)
+	if j.Status != StatusFinished || j.Total != 5 || j.Imported != 2 || j.Rejected != 3 {
+		t.Errorf("unexpected job: %s, %d total, %d imported, %d rejected", j.Status, j.Total, j.Imported, j.Rejected)
+	}
+	if !strings.Contains(j.Log, "dry run finished. 2 valid, 3 rejected") || !strings.Contains(j.Log, "importing without attributes") {
+		t.Errorf("unexpected log: %s", j.Log)
+	}
+
+	rd := csv.NewReader(strings.NewReader(string(st.errors[1])))
+	rd.FieldsPerRecord = -1
+	rows, err := rd.ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := [][]string{
+		{"line", "reason", "email", "name", "attributes"},
+		{"3", "", "invalid", "Bad", ""},
+		{"5", "duplicate e-mail", "ONE@example.com", "Dupe", ""},
+		{"6", "", "three@example.com"},
+	}
+	if len(rows) != len(exp) {
+		t.Fatalf("unexpected rejected rows: %q", rows)
+	}
+	for i, r := range rows {
+		if len(r) != len(exp[i]) || r[0] != exp[i][0] || (exp[i][1] != "" && r[1] != exp[i][1]) || r[2] != exp[i][2] {
+			t.Errorf("unexpected rejected row %d: %q", i, r)
+		}
+	}
+
+	// The job's file and the temporary CSV are removed.
+	if _, err := os.Stat(job.FilePath); !os.IsNotExist(err) {
+		t.Errorf("expected the job's file to be removed: %v", err)
+	}
+	if _, err := os.Stat(s.errFile.Name()); !os.IsNotExist(err) {
+		t.Errorf("expected the rejected rows file to be removed: %v", err)
+	}
+}
+
+func TestSeenEmails(t *testing.T) {
+	s := newSeenEmails(2)
+	for _, c := range []struct {
+		email string
+		ok    bool
+	}{
+		{"one@example.com", true},
+		{"two@example.com", true},
+		{"one@example.com", false},
+
+		// Beyond the max, e-mails aren't remembered.
+		{"three@example.com", true},
+		{"three@example.com", true},
+		{"two@example.com", false},
+	} {
+		if ok := s.add(c.email); ok != c.ok {
+			t.Errorf("%s: expected %v, got %v", c.email, c.ok, ok)
+		}
+	}
+}
+
+func TestQueue(t *testing.T) {
+	var (
+		opened  = make(chan bool)
+		release = make(chan bool)
+	)
+	im := newTestImporter(t, Options{
+		Concurrency: 1,
+
+		// The media store blocks until it's released to hold up the running job.
+		OpenMedia: func(key string) (io.ReadCloser, error) {
+			if key == "big.csv" {
+				return io.NopCloser(strings.NewReader("email\n" + strings.Repeat("one@example.com\n", 10))), nil
+			}
+			opened <- true
+			<-release
+			return io.NopCloser(strings.NewReader("email\none@example.com\n")), nil
+		},
+	})
+
+	var (
+		in = "email\none@example.com\ntwo@example.com\n"
+
+		// A job that was running when the app was stopped.
+		interrupted = newJob(t, 1, SessionOpt{Mode: ModeSubscribe, DryRun: true}, "old.csv", in)
+
+		held    = &models.ImportJob{ID: 2, Params: []byte(`{"mode": "subscribe", "delim": ",", "media_key": "subs.csv", "filename": "subs.csv", "dry_run": true}`), Status: StatusQueued}
+		queued  = newJob(t, 3, SessionOpt{Mode: ModeSubscribe, DryRun: true}, "subs.csv", in)
+		invalid = newJob(t, 4, SessionOpt{Mode: ModeSubscribe, DryRun: true, Delim: ";;"}, "subs.csv", in)
+		big     = &models.ImportJob{ID: 5, Params: []byte(`{"mode": "subscribe", "delim": ",", "media_key": "big.csv", "filename": "big.csv", "dry_run": true}`), Status: StatusQueued}
+	)
+	interrupted.Status = StatusImporting
+	im.maxFetchSize = 100
+
+	st := newStore(interrupted, held, queued, invalid, big)
+	im.store = st
+	go im.Run()
+
+	wait := func(id int, status string) models.ImportJob {
+		t.Helper()
+		for start := time.Now(); time.Since(start) < 3*time.Second; time.Sleep(5 * time.Millisecond) {
+			if _, running := im.GetStats(id); !running && st.get(id).Status == status {
+				return st.get(id)
+			}
+		}
+		t.Fatalf("expected job %d to be %s, got %s", id, status, st.get(id).Status)
+		return models.ImportJob{}
+	}
+
+	// Interrupted jobs fail and their files are removed.
+	wait(1, StatusFailed)
+	if _, err := os.Stat(interrupted.FilePath); !os.IsNotExist(err) {
+		t.Errorf("expected the interrupted job's file to be removed: %v", err)
+	}
+
+	// Only one job runs at a time.
+	<-opened
+	if j := st.get(2); j.Status != StatusImporting || !im.HasRunningImports() {
+		t.Fatalf("expected job 2 to be importing, got %s", j.Status)
+	}
+	if _, ok := im.GetStats(2); !ok {
+		t.Error("expected stats of the running job")
+	}
+	if st.get(3).Status != StatusQueued {
+		t.Errorf("expected job 3 to be queued, got %s", st.get(3).Status)
+	}
+
+	// Only running jobs can be stopped.
+	if im.Stop(3) {
+		t.Error("expected queued job not to be stopped")
+	}
+	if !im.Stop(2) {
+		t.Error("expected running job to be stopped")
+	}
+	release <- true
+	wait(2, StatusStopped)
+
+	// The next jobs are picked up once the slot is free.
+	if j := wait(3, StatusFinished); j.Imported != 2 {
+		t.Errorf("expected 2 valid rows, got %d", j.Imported)
+	}
+	if _, err := os.Stat(queued.FilePath); !os.IsNotExist(err) {
+		t.Errorf("expected the finished job's file to be removed: %v", err)
+	}
+
+	// Jobs that can't start fail.
+	wait(4, StatusFailed)
+	if _, err := os.Stat(invalid.FilePath); !os.IsNotExist(err) {
+		t.Errorf("expected the failed job's file to be removed: %v", err)
+	}
+
+	// Files over the max size aren't imported.
+	if j := wait(5, StatusFailed); !strings.Contains(j.Log, "exceeds the max size") {
+		t.Errorf("expected size error, got: %s", j.Log)
+	}
+
+	// Newly queued jobs are picked up without waiting for the next poll.
+	st.mu.Lock()
+	st.jobs = append(st.jobs, newJob(t, 6, SessionOpt{Mode: ModeSubscribe, DryRun: true}, "subs.csv", in))
+	st.mu.Unlock()
+	im.Queue()
+
+	start := time.Now()
+	wait(6, StatusFinished)
+	if time.Since(start) > pollInterval/2 {
+		t.Errorf("expected the queued job to be picked up immediately")
+	}
+}
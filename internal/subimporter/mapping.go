@@ -0,0 +1,326 @@
+package subimporter
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/knadh/listmonk/models"
+)
+
+// Types that mapped attribute values are coerced to.
+const (
+	TypeString = "string"
+	TypeInt    = "int"
+	TypeFloat  = "float"
+	TypeBool   = "bool"
+	TypeDate   = "date"
+	TypeList   = "list"
+)
+
+// Fields that CSV columns can be mapped to, other than attribute paths.
+const (
+	fieldEmail      = "email"
+	fieldName       = "name"
+	fieldAttributes = "attributes"
+
+	// attribPrefix is the prefix of attribute paths, eg: attribs.address.city.
+	attribPrefix = "attribs."
+)
+
+// dateLayouts are the layouts tried for date values without an explicit format.
+var dateLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+}
+
+// ValidateMapping validates a CSV column mapping. Exactly one column should be
+// mapped to email.
+func ValidateMapping(m models.ImportMapping) error {
+	if len(m) == 0 {
+		return errors.New("empty mapping")
+	}
+
+	numEmail := 0
+	for _, c := range m {
+		if strings.TrimSpace(c.Column) == "" && c.Default == "" {
+			return fmt.Errorf("mapping for '%s' has neither a column nor a default", c.Field)
+		}
+
+		switch c.Field {
+		case fieldEmail, fieldName, fieldAttributes:
+			if c.Field == fieldEmail {
+				numEmail++
+			}
+			if c.Type != "" && c.Type != TypeString {
+				return fmt.Errorf("'%s' can't be coerced to %s", c.Field, c.Type)
+			}
+
+		default:
+			if !strings.HasPrefix(c.Field, attribPrefix) {
+				return fmt.Errorf("unknown field '%s'", c.Field)
+			}
+			for _, p := range strings.Split(strings.TrimPrefix(c.Field, attribPrefix), ".") {
+				if p == "" {
+					return fmt.Errorf("invalid attribute path '%s'", c.Field)
+				}
+			}
+
+			switch c.Type {
+			case "", TypeString, TypeInt, TypeFloat, TypeBool, TypeDate, TypeList:
+			default:
+				return fmt.Errorf("unknown type '%s' for '%s'", c.Type, c.Field)
+			}
+		}
+
+		if c.Default != "" {
+			if _, err := coerce(c.Default, c); err != nil {
+				return fmt.Errorf("invalid default for '%s': %v", c.Field, err)
+			}
+		}
+	}
+
+	if numEmail != 1 {
+		return errors.New("exactly one column should be mapped to email")
+	}
+
+	return nil
+}
+
+// mapColumns returns the index of the CSV column of each mapping in the
+// session's mapping, or -1 if the column isn't in the CSV.
+func (s *Session) mapColumns(csvHdr []string) ([]int, error) {
+	out := make([]int, len(s.opt.Mapping))
+	for n, c := range s.opt.Mapping {
+		out[n] = -1
+		if c.Column == "" {
+			continue
+		}
+
+		for i, h := range csvHdr {
+			// Strip the BOM.
+			h = strings.TrimSpace(strings.TrimPrefix(h, "\ufeff"))
+			if strings.EqualFold(h, strings.TrimSpace(c.Column)) {
+				out[n] = i
+				break
+			}
+		}
+
+		if out[n] >= 0 {
+			continue
+		}
+
+		if c.Default != "" {
+			s.log.Printf("column '%s' not found. Using the default for '%s'", c.Column, c.Field)
+			continue
+		}
+		if c.Field == fieldEmail {
+			return nil, fmt.Errorf("email column '%s' not found", c.Column)
+		}
+		s.log.Printf("column '%s' not found. Ignoring '%s'", c.Column, c.Field)
+	}
+
+	return out, nil
+}
+
+// applyMapping maps the columns in a CSV row to a subscriber using the
+// session's mapping and column indexes from mapColumns.
+func (s *Session) applyMapping(idx []int, cols []string) (SubReq, error) {
+	vals := make([]string, len(s.opt.Mapping))
+	for n := range s.opt.Mapping {
+		if i := idx[n]; i >= 0 && i < len(cols) {
+			vals[n] = cols[i]
+		}
+	}
+
+	return mapValues(s.opt.Mapping, vals)
+}
+
+// MapRecord maps the keys in a JSON object (record) to a subscriber using
+// a mapping where the column of each mapping is a key in the record.
+// Keys are matched case-insensitively if there's no exact match. Lists in the
+// record are joined with the mapping's separator.
+func MapRecord(m models.ImportMapping, rec map[string]any) (SubReq, error) {
+	vals := make([]string, len(m))
+	for n, c := range m {
+		if c.Column == "" {
+			continue
+		}
+
+		v, ok := rec[c.Column]
+		if !ok {
+			for k, kv := range rec {
+				if strings.EqualFold(k, c.Column) {
+					v = kv
+					break
+				}
+			}
+		}
+
+		switch t := v.(type) {
+		case nil:
+		case string:
+			vals[n] = t
+		case []any:
+			if c.Type == TypeList {
+				sep := c.Format
+				if sep == "" {
+					sep = ","
+				}
+				parts := make([]string, 0, len(t))
+				for _, p := range t {
+					parts = append(parts, fmt.Sprintf("%v", p))
+				}
+				vals[n] = strings.Join(parts, sep)
+				break
+			}
+			b, _ := json.Marshal(t)
+			vals[n] = string(b)
+		case map[string]any:
+			b, _ := json.Marshal(t)
+			vals[n] = string(b)
+		default:
+			vals[n] = fmt.Sprintf("%v", t)
+		}
+	}
+
+	return mapValues(m, vals)
+}
+
+// mapValues maps raw values, one for each mapping, to a subscriber,
+// coercing them to the mapping's types.
+func mapValues(m models.ImportMapping, vals []string) (SubReq, error) {
+	var (
+		sub     = SubReq{}
+		attribs = models.JSON{}
+		paths   = make([]int, 0, len(m))
+	)
+
+	// Pick the values for the fixed fields first so that the attribute paths
+	// can override keys in the attributes JSON.
+	for n, c := range m {
+		val := c.Default
+		if strings.TrimSpace(vals[n]) != "" {
+			val = vals[n]
+		}
+		if val == "" {
+			continue
+		}
+
+		switch c.Field {
+		case fieldEmail:
+			sub.Email = val
+		case fieldName:
+			sub.Name = val
+		case fieldAttributes:
+			var mp map[string]any
+			if err := json.Unmarshal([]byte(val), &mp); err != nil {
+				return sub, errors.New("invalid attributes JSON: " + err.Error())
+			}
+			for k, v := range mp {
+				attribs[k] = v
+			}
+		default:
+			paths = append(paths, n)
+		}
+	}
+
+	for _, n := range paths {
+		c := m[n]
+
+		val := c.Default
+		if strings.TrimSpace(vals[n]) != "" {
+			val = vals[n]
+		}
+
+		v, err := coerce(val, c)
+		if err != nil {
+			return sub, fmt.Errorf("column '%s': %v", c.Column, err)
+		}
+		setAttrib(attribs, strings.Split(strings.TrimPrefix(c.Field, attribPrefix), "."), v)
+	}
+
+	if len(attribs) > 0 {
+		sub.Attribs = attribs
+	}
+
+	return sub, nil
+}
+
+// coerce converts a value to the mapping's type.
+func coerce(val string, c models.ImportColumn) (any, error) {
+	switch c.Type {
+	case TypeInt:
+		n, err := strconv.ParseInt(strings.TrimSpace(val), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid int '%s'", val)
+		}
+		return n, nil
+
+	case TypeFloat:
+		f, err := strconv.ParseFloat(strings.TrimSpace(val), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid float '%s'", val)
+		}
+		return f, nil
+
+	case TypeBool:
+		switch strings.ToLower(strings.TrimSpace(val)) {
+		case "yes", "y", "on":
+			return true, nil
+		case "no", "n", "off":
+			return false, nil
+		}
+		b, err := strconv.ParseBool(strings.TrimSpace(val))
+		if err != nil {
+			return nil, fmt.Errorf("invalid bool '%s'", val)
+		}
+		return b, nil
+
+	case TypeDate:
+		layouts := dateLayouts
+		if c.Format != "" {
+			layouts = []string{c.Format}
+		}
+		for _, l := range layouts {
+			if t, err := time.Parse(l, strings.TrimSpace(val)); err == nil {
+				return t.Format(time.RFC3339), nil
+			}
+		}
+		return nil, fmt.Errorf("invalid date '%s'", val)
+
+	case TypeList:
+		sep := c.Format
+		if sep == "" {
+			sep = ","
+		}
+		out := []string{}
+		for _, v := range strings.Split(val, sep) {
+			if v = strings.TrimSpace(v); v != "" {
+				out = append(out, v)
+			}
+		}
+		return out, nil
+	}
+
+	return val, nil
+}
+
+// setAttrib sets a value at a nested path in attribs, creating (or replacing
+// non-object values with) objects along the path.
+func setAttrib(attribs map[string]any, path []string, v any) {
+	for _, p := range path[:len(path)-1] {
+		next, ok := attribs[p].(map[string]any)
+		if !ok {
+			next = map[string]any{}
+			attribs[p] = next
+		}
+		attribs = next
+	}
+	attribs[path[len(path)-1]] = v
+}
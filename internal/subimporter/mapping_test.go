@@ -0,0 +1,159 @@
+package subimporter
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/knadh/listmonk/models"
+)
+
+func TestValidateMapping(t *testing.T) {
+	email := models.ImportColumn{Column: "E-mail", Field: "email"}
+
+	cases := []struct {
+		name string
+		m    models.ImportMapping
+		ok   bool
+	}{
+		{"email only", models.ImportMapping{email}, true},
+		{"empty", models.ImportMapping{}, false},
+		{"no email", models.ImportMapping{{Column: "Name", Field: "name"}}, false},
+		{"two emails", models.ImportMapping{email, {Column: "Alt", Field: "email"}}, false},
+		{"no column or default", models.ImportMapping{email, {Field: "attribs.plan"}}, false},
+		{"default only", models.ImportMapping{email, {Field: "attribs.plan", Default: "free"}}, true},
+		{"unknown field", models.ImportMapping{email, {Column: "x", Field: "status"}}, false},
+		{"empty path part", models.ImportMapping{email, {Column: "x", Field: "attribs.a..b"}}, false},
+		{"nested path", models.ImportMapping{email, {Column: "x", Field: "attribs.address.city"}}, true},
+		{"unknown type", models.ImportMapping{email, {Column: "x", Field: "attribs.x", Type: "uuid"}}, false},
+		{"coerced name", models.ImportMapping{email, {Column: "x", Field: "name", Type: TypeInt}}, false},
+		{"invalid default", models.ImportMapping{email, {Field: "attribs.age", Type: TypeInt, Default: "ten"}}, false},
+		{"valid default", models.ImportMapping{email, {Field: "attribs.age", Type: TypeInt, Default: "10"}}, true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if err := ValidateMapping(c.m); (err == nil) != c.ok {
+				t.Errorf("expected ok=%v, got %v", c.ok, err)
+			}
+		})
+	}
+}
+
+func TestCoerce(t *testing.T) {
+	cases := []struct {
+		val  string
+		typ  string
+		fmt  string
+		want any
+		ok   bool
+	}{
+		{"abc", "", "", "abc", true},
+		{" 42 ", TypeInt, "", int64(42), true},
+		{"4.2", TypeInt, "", nil, false},
+		{"4.2", TypeFloat, "", 4.2, true},
+		{"x", TypeFloat, "", nil, false},
+		{"yes", TypeBool, "", true, true},
+		{"Off", TypeBool, "", false, true},
+		{"TRUE", TypeBool, "", true, true},
+		{"0", TypeBool, "", false, true},
+		{"maybe", TypeBool, "", nil, false},
+		{"2024-03-01", TypeDate, "", "2024-03-01T00:00:00Z", true},
+		{"2024-03-01 10:20:30", TypeDate, "", "2024-03-01T10:20:30Z", true},
+		{"01/03/2024", TypeDate, "02/01/2006", "2024-03-01T00:00:00Z", true},
+		{"01/03/2024", TypeDate, "", nil, false},
+		{"a, b,,c ", TypeList, "", []string{"a", "b", "c"}, true},
+		{"a|b", TypeList, "|", []string{"a", "b"}, true},
+		{"", TypeList, "", []string{}, true},
+	}
+
+	for _, c := range cases {
+		got, err := coerce(c.val, models.ImportColumn{Type: c.typ, Format: c.fmt})
+		if (err == nil) != c.ok {
+			t.Errorf("%q as %s: expected ok=%v, got %v", c.val, c.typ, c.ok, err)
+			continue
+		}
+		if c.ok && !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%q as %s: expected %#v, got %#v", c.val, c.typ, c.want, got)
+		}
+	}
+}
+
+func TestMapValues(t *testing.T) {
+	m := models.ImportMapping{
+		{Column: "E-mail", Field: "email"},
+		{Column: "Full name", Field: "name"},
+		{Column: "Attribs", Field: "attributes"},
+		{Column: "City", Field: "attribs.address.city"},
+		{Column: "Age", Field: "attribs.age", Type: TypeInt},
+		{Column: "Plan", Field: "attribs.plan", Default: "free"},
+	}
+
+	cases := []struct {
+		name    string
+		vals    []string
+		email   string
+		attribs models.JSON
+		ok      bool
+	}{
+		{
+			name:  "all columns",
+			vals:  []string{"a@example.com", "A", `{"x": 1}`, "Berlin", "30", "pro"},
+			email: "a@example.com",
+			attribs: models.JSON{
+				"x": float64(1), "address": map[string]any{"city": "Berlin"}, "age": int64(30), "plan": "pro",
+			},
+			ok: true,
+		},
+		{
+			// Paths override the attributes JSON and blank values use defaults.
+			name:  "paths override JSON",
+			vals:  []string{"a@example.com", "", `{"address": "old", "plan": "x"}`, "Pune", "", " "},
+			email: "a@example.com",
+			attribs: models.JSON{
+				"address": map[string]any{"city": "Pune"}, "plan": "free",
+			},
+			ok: true,
+		},
+		{name: "invalid attributes", vals: []string{"a@example.com", "", "{bad", "", "", ""}},
+		{name: "invalid int", vals: []string{"a@example.com", "", "", "", "old", ""}},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			sub, err := mapValues(m, c.vals)
+			if (err == nil) != c.ok {
+				t.Fatalf("expected ok=%v, got %v", c.ok, err)
+			}
+			if !c.ok {
+				return
+			}
+			if sub.Email != c.email || !reflect.DeepEqual(sub.Attribs, c.attribs) {
+				t.Errorf("expected %s %v, got %s %v", c.email, c.attribs, sub.Email, sub.Attribs)
+			}
+		})
+	}
+}
+
+func TestMapRecord(t *testing.T) {
+	m := models.ImportMapping{
+		{Column: "email", Field: "email"},
+		{Column: "tags", Field: "attribs.tags", Type: TypeList},
+		{Column: "score", Field: "attribs.score", Type: TypeFloat},
+		{Column: "meta", Field: "attribs.meta"},
+	}
+
+	sub, err := MapRecord(m, map[string]any{
+		"EMAIL": "a@example.com",
+		"tags":  []any{"x", "y"},
+		"score": 4.5,
+		"meta":  map[string]any{"k": "v"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := models.JSON{"tags": []string{"x", "y"}, "score": 4.5, "meta": `{"k":"v"}`}
+	if sub.Email != "a@example.com" || !reflect.DeepEqual(sub.Attribs, want) {
+		t.Errorf("expected %v, got %s %v", want, sub.Email, sub.Attribs)
+	}
+}
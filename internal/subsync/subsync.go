@@ -0,0 +1,590 @@
+// Package subsync periodically syncs subscribers from external sources, an
+// HTTP JSON endpoint or a Postgres query, into a list. Each run fetches all the
+// records from the source and queues them as an import job for the importer to
+// upsert. Optionally, subscribers in the list who are no longer in the source
+// are unsubscribed from it with another import job. A run finishes with the
+// outcome of its import jobs.
+package subsync
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gdgvda/cron"
+	"github.com/knadh/listmonk/internal/subimporter"
+	"github.com/knadh/listmonk/internal/utils"
+	"github.com/knadh/listmonk/models"
+	"github.com/lib/pq"
+)
+
+// Store represents the DB store for sync sources.
+type Store interface {
+	GetSyncSources() ([]models.SyncSource, error)
+	GetListSubscriberEmails(listID int) ([]string, error)
+	CreateSyncRun(sourceID int) (int, error)
+	UpdateSyncRun(r models.SyncRun) error
+	FailInterruptedSyncRuns() error
+	GetImportJob(id, userID int) (models.ImportJob, error)
+	GetImportJobErrors(id, userID int) ([]byte, error)
+}
+
+// Opt represents sync options.
+type Opt struct {
+	// How often sources are checked for being due for a run.
+	Interval time.Duration
+
+	// Timeout for fetching records from a source.
+	Timeout time.Duration
+
+	// How often queued import jobs are checked for completion.
+	PollInterval time.Duration
+
+	// QueueImport queues an import job for the file at path on behalf of
+	// the given user (or none if 0) and returns its ID.
+	QueueImport func(userID int, name string, opt subimporter.SessionOpt, path string) (int, error)
+}
+
+// Sync runs subscriber syncs from external sources.
+type Sync struct {
+	opt    Opt
+	store  Store
+	client *http.Client
+	log    *log.Logger
+
+	// IDs of the sources that are being synced.
+	running map[int]struct{}
+	sync.Mutex
+}
+
+// ErrRunning is returned when a sync is started for a source that's
+// already being synced.
+var ErrRunning = errors.New("sync already running")
+
+// record is a subscriber record written to the import file.
+type record struct {
+	Email   string      `json:"email"`
+	Name    string      `json:"name,omitempty"`
+	Attribs models.JSON `json:"attribs,omitempty"`
+}
+
+// New returns a new instance of Sync.
+func New(opt Opt, s Store, lo *log.Logger) *Sync {
+	if opt.Interval == 0 {
+		opt.Interval = time.Minute
+	}
+	if opt.Timeout == 0 {
+		opt.Timeout = 5 * time.Minute
+	}
+	if opt.PollInterval == 0 {
+		opt.PollInterval = 5 * time.Second
+	}
+
+	return &Sync{
+		opt:     opt,
+		store:   s,
+		client:  utils.NewPublicHTTPClient(opt.Timeout),
+		log:     lo,
+		running: make(map[int]struct{}),
+	}
+}
+
+// Run is a blocking function that periodically checks sync sources that are
+// due as per their schedules and syncs them.
+func (s *Sync) Run() {
+	// Runs that were interrupted by a restart will never finish.
+	if err := s.store.FailInterruptedSyncRuns(); err != nil {
+		s.log.Printf("error failing interrupted sync runs: %v", err)
+	}
+
+	t := time.NewTicker(s.opt.Interval)
+	defer t.Stop()
+
+	for range t.C {
+		srcs, err := s.store.GetSyncSources()
+		if err != nil {
+			s.log.Printf("error fetching sync sources: %v", err)
+			continue
+		}
+
+		now := time.Now()
+		for _, src := range srcs {
+			if !src.Enabled || !isDue(src, now) {
+				continue
+			}
+
+			if err := s.Start(src); err != nil && err != ErrRunning {
+				s.log.Printf("error starting sync '%s': %v", src.Name, err)
+			}
+		}
+	}
+}
+
+// Start syncs the given source in the background. ErrRunning is returned
+// if the source is already being synced.
+func (s *Sync) Start(src models.SyncSource) error {
+	s.Lock()
+	if _, ok := s.running[src.ID]; ok {
+		s.Unlock()
+		return ErrRunning
+	}
+	s.running[src.ID] = struct{}{}
+	s.Unlock()
+
+	runID, err := s.store.CreateSyncRun(src.ID)
+	if err != nil {
+		s.done(src.ID)
+		return err
+	}
+
+	go func() {
+		defer s.done(src.ID)
+
+		r := s.process(src)
+		r.ID = runID
+		if r.Error != "" {
+			s.log.Printf("error syncing '%s': %s", src.Name, r.Error)
+		}
+
+		if err := s.store.UpdateSyncRun(r); err != nil {
+			s.log.Printf("error updating sync run for '%s': %v", src.Name, err)
+		}
+	}()
+
+	return nil
+}
+
+// IsRunning returns true if the given source is being synced.
+func (s *Sync) IsRunning(sourceID int) bool {
+	s.Lock()
+	defer s.Unlock()
+
+	_, ok := s.running[sourceID]
+	return ok
+}
+
+func (s *Sync) done(sourceID int) {
+	s.Lock()
+	delete(s.running, sourceID)
+	s.Unlock()
+}
+
+// process fetches the records from the source, compares them with the
+// subscribers in the target list, and queues the import jobs.
+func (s *Sync) process(src models.SyncSource) models.SyncRun {
+	r := models.SyncRun{
+		SourceID:     src.ID,
+		Status:       models.SyncRunStatusFailed,
+		ImportJobIDs: pq.Int64Array{},
+	}
+
+	recs, err := s.fetch(src)
+	if err != nil {
+		r.Error = err.Error()
+		return r
+	}
+	r.Total = len(recs)
+
+	// Map the records to subscribers.
+	var (
+		subs = make([]record, 0, len(recs))
+		seen = make(map[string]struct{}, len(recs))
+	)
+	for _, rec := range recs {
+		sub, err := toRecord(src.Mapping, rec)
+		if err != nil {
+			r.Skipped++
+			continue
+		}
+
+		if _, ok := seen[sub.Email]; ok {
+			r.Skipped++
+			continue
+		}
+		seen[sub.Email] = struct{}{}
+		subs = append(subs, sub)
+	}
+
+	// Compare with the subscribers in the list.
+	emails, err := s.store.GetListSubscriberEmails(src.ListID)
+	if err != nil {
+		r.Error = fmt.Sprintf("error fetching list subscribers: %v", err)
+		return r
+	}
+
+	existing := make(map[string]struct{}, len(emails))
+	for _, e := range emails {
+		existing[e] = struct{}{}
+	}
+
+	// Upsert the subscribers and wait for the import to finish.
+	if len(subs) > 0 {
+		id, err := s.queue(src, src.Name, subimporter.SessionOpt{
+			Mode:      subimporter.ModeSubscribe,
+			SubStatus: src.SubStatus,
+			Overwrite: src.Overwrite,
+			ListIDs:   []int{src.ListID},
+		}, subs)
+		if err != nil {
+			r.Error = fmt.Sprintf("error queuing import: %v", err)
+			return r
+		}
+		r.ImportJobIDs = append(r.ImportJobIDs, int64(id))
+
+		job, err := s.wait(id)
+		if err != nil {
+			r.Error = err.Error()
+			return r
+		}
+
+		// Count the subscribers the importer rejected as skipped.
+		rejected, err := s.rejectedEmails(job)
+		if err != nil {
+			r.Error = fmt.Sprintf("error fetching rejected records: %v", err)
+			return r
+		}
+		r.Skipped += job.Rejected
+
+		for _, sub := range subs {
+			_, ok := existing[sub.Email]
+			delete(existing, sub.Email)
+
+			if _, rej := rejected[sub.Email]; rej {
+				continue
+			}
+			if ok {
+				r.Updated++
+			} else {
+				r.Added++
+			}
+		}
+	}
+
+	// Unsubscribe the subscribers who are no longer in the source. If nothing
+	// valid was fetched, it's more likely to be an issue with the source than
+	// everyone having left, so the list is left as-is.
+	if src.UnsubscribeMissing && len(existing) > 0 && len(subs) > 0 {
+		gone := make([]record, 0, len(existing))
+		for e := range existing {
+			gone = append(gone, record{Email: e})
+		}
+
+		id, err := s.queue(src, src.Name+" (unsubscribe)", subimporter.SessionOpt{
+			Mode:    subimporter.ModeUnsubscribe,
+			ListIDs: []int{src.ListID},
+		}, gone)
+		if err != nil {
+			r.Error = fmt.Sprintf("error queuing unsubscribe import: %v", err)
+			return r
+		}
+		r.ImportJobIDs = append(r.ImportJobIDs, int64(id))
+
+		job, err := s.wait(id)
+		if err != nil {
+			r.Error = err.Error()
+			return r
+		}
+		r.Removed = job.Imported
+	}
+
+	r.Status = models.SyncRunStatusFinished
+	return r
+}
+
+// wait waits for an import job to finish and returns it. An error is returned
+// if the job fails or is stopped.
+func (s *Sync) wait(id int) (models.ImportJob, error) {
+	t := time.NewTicker(s.opt.PollInterval)
+	defer t.Stop()
+
+	for {
+		job, err := s.store.GetImportJob(id, 0)
+		if err != nil {
+			return job, fmt.Errorf("error fetching import job %d: %v", id, err)
+		}
+
+		switch job.Status {
+		case subimporter.StatusFinished:
+			return job, nil
+		case subimporter.StatusFailed, subimporter.StatusStopped:
+			return job, fmt.Errorf("import job %d %s", id, job.Status)
+		}
+
+		<-t.C
+	}
+}
+
+// rejectedEmails returns the e-mails of the records rejected in an import
+// job from its CSV of rejected rows (line, reason, JSON record).
+func (s *Sync) rejectedEmails(job models.ImportJob) (map[string]struct{}, error) {
+	out := map[string]struct{}{}
+	if !job.HasErrors {
+		return out, nil
+	}
+
+	b, err := s.store.GetImportJobErrors(job.ID, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	rd := csv.NewReader(bytes.NewReader(b))
+	rd.FieldsPerRecord = -1
+	rows, err := rd.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, row := range rows {
+		if len(row) < 3 {
+			continue
+		}
+
+		var rec record
+		if err := json.Unmarshal([]byte(row[2]), &rec); err != nil {
+			continue
+		}
+		out[strings.ToLower(strings.TrimSpace(rec.Email))] = struct{}{}
+	}
+
+	return out, nil
+}
+
+// queue writes the records to a JSONL file and queues an import job for it.
+func (s *Sync) queue(src models.SyncSource, name string, opt subimporter.SessionOpt, recs []record) (int, error) {
+	f, err := os.CreateTemp("", "listmonk-sync")
+	if err != nil {
+		return 0, err
+	}
+
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	for _, r := range recs {
+		if err := enc.Encode(r); err != nil {
+			f.Close()
+			os.Remove(f.Name())
+			return 0, err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return 0, err
+	}
+	f.Close()
+
+	// The importer picks the format by the extension.
+	opt.Format = subimporter.FormatJSONL
+	opt.Filename = name + ".jsonl"
+	opt.Delim = ","
+
+	id, err := s.opt.QueueImport(src.UserID.Int, name, opt, f.Name())
+	if err != nil {
+		os.Remove(f.Name())
+		return 0, err
+	}
+
+	return id, nil
+}
+
+// fetch fetches all the records from a source.
+func (s *Sync) fetch(src models.SyncSource) ([]map[string]any, error) {
+	switch src.Type {
+	case models.SyncSourceTypeHTTP:
+		return s.fetchHTTP(src)
+	case models.SyncSourceTypePostgres:
+		return s.fetchPostgres(src)
+	}
+
+	return nil, fmt.Errorf("unknown source type '%s'", src.Type)
+}
+
+// fetchHTTP fetches records from an HTTP endpoint that returns a JSON array
+// of objects or JSON Lines (one object per line).
+func (s *Sync) fetchHTTP(src models.SyncSource) ([]map[string]any, error) {
+	req, err := http.NewRequest(http.MethodGet, src.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	for k, v := range src.Headers {
+		req.Header.Set(k, fmt.Sprintf("%v", v))
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("source returned status %d", resp.StatusCode)
+	}
+
+	return decodeRecords(resp.Body)
+}
+
+// decodeRecords decodes a JSON array of objects or a stream of objects.
+func decodeRecords(r io.Reader) ([]map[string]any, error) {
+	br := bufio.NewReader(r)
+
+	// Peek at the first non-space byte to see whether it's an array.
+	isArray := false
+	for {
+		b, err := br.Peek(1)
+		if err == io.EOF {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if c := b[0]; c == ' ' || c == '\t' || c == '\r' || c == '\n' {
+			br.Discard(1)
+			continue
+		}
+		isArray = b[0] == '['
+		break
+	}
+
+	dec := json.NewDecoder(br)
+	dec.UseNumber()
+
+	// Consume the opening bracket of the array.
+	if isArray {
+		if _, err := dec.Token(); err != nil {
+			return nil, err
+		}
+	}
+
+	out := []map[string]any{}
+	for dec.More() {
+		var rec map[string]any
+		if err := dec.Decode(&rec); err != nil {
+			return nil, fmt.Errorf("error decoding record %d: %v", len(out)+1, err)
+		}
+		out = append(out, rec)
+	}
+
+	return out, nil
+}
+
+// fetchPostgres fetches records by running the source's query on the Postgres
+// database in its DSN in a read-only transaction. Each row is a record with
+// the column names as keys.
+func (s *Sync) fetchPostgres(src models.SyncSource) ([]map[string]any, error) {
+	db, err := sql.Open("postgres", src.URL)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.opt.Timeout)
+	defer cancel()
+
+	tx, err := db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, src.Query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	cols, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		out  = []map[string]any{}
+		vals = make([]any, len(cols))
+		ptrs = make([]any, len(cols))
+	)
+	for i := range vals {
+		ptrs[i] = &vals[i]
+	}
+	for rows.Next() {
+		if err := rows.Scan(ptrs...); err != nil {
+			return nil, err
+		}
+
+		rec := make(map[string]any, len(cols))
+		for i, c := range cols {
+			switch v := vals[i].(type) {
+			case []byte:
+				// JSON columns are decoded so that they can be mapped to attributes.
+				var j any
+				if json.Valid(v) && (bytes.HasPrefix(v, []byte("{")) || bytes.HasPrefix(v, []byte("["))) && json.Unmarshal(v, &j) == nil {
+					rec[c] = j
+				} else {
+					rec[c] = string(v)
+				}
+			case time.Time:
+				rec[c] = v.Format(time.RFC3339)
+			default:
+				rec[c] = v
+			}
+		}
+		out = append(out, rec)
+	}
+
+	return out, rows.Err()
+}
+
+// toRecord converts a fetched record to a subscriber record using the mapping.
+// Without a mapping, the record should have the email, name, and attribs keys.
+func toRecord(m models.ImportMapping, rec map[string]any) (record, error) {
+	var out record
+	if len(m) > 0 {
+		sub, err := subimporter.MapRecord(m, rec)
+		if err != nil {
+			return out, err
+		}
+		out = record{Email: sub.Email, Name: sub.Name, Attribs: sub.Attribs}
+	} else {
+		out.Email, _ = rec["email"].(string)
+		out.Name, _ = rec["name"].(string)
+		if a, ok := rec["attribs"].(map[string]any); ok {
+			out.Attribs = a
+		}
+	}
+
+	// The importer validates the e-mail fully. The lowercased e-mail is needed
+	// here to compare it with the list's subscribers.
+	out.Email = strings.ToLower(strings.TrimSpace(out.Email))
+	if out.Email == "" || !strings.Contains(out.Email, "@") {
+		return out, errors.New("invalid e-mail")
+	}
+
+	return out, nil
+}
+
+// isDue checks whether a source is due for a run as per its cron schedule.
+func isDue(src models.SyncSource, now time.Time) bool {
+	sch, err := cron.ParseStandard(src.Schedule)
+	if err != nil {
+		return false
+	}
+
+	last := src.CreatedAt.Time
+	if src.LastRunAt.Valid {
+		last = src.LastRunAt.Time
+	}
+
+	return !sch.Next(last).After(now)
+}
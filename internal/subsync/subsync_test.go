@@ -0,0 +1,271 @@
+package subsync
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/knadh/listmonk/internal/subimporter"
+	"github.com/knadh/listmonk/internal/utils"
+	"github.com/knadh/listmonk/models"
+)
+
+// store is an in-memory Store. Queued import jobs are "imported" right away,
+// rejecting the records with e-mails in reject.
+type store struct {
+	list   []string
+	reject map[string]bool
+	status string
+
+	jobs   map[int]models.ImportJob
+	errs   map[int][]byte
+	unsubs []string
+}
+
+func (s *store) GetSyncSources() ([]models.SyncSource, error) { return nil, nil }
+func (s *store) GetListSubscriberEmails(listID int) ([]string, error) {
+	return s.list, nil
+}
+func (s *store) CreateSyncRun(sourceID int) (int, error)      { return 1, nil }
+func (s *store) UpdateSyncRun(r models.SyncRun) error         { return nil }
+func (s *store) FailInterruptedSyncRuns() error               { return nil }
+func (s *store) GetImportJobErrors(id, _ int) ([]byte, error) { return s.errs[id], nil }
+
+func (s *store) GetImportJob(id, _ int) (models.ImportJob, error) {
+	j, ok := s.jobs[id]
+	if !ok {
+		return j, fmt.Errorf("job %d not found", id)
+	}
+	return j, nil
+}
+
+func (s *store) queueImport(userID int, name string, opt subimporter.SessionOpt, path string) (int, error) {
+	defer os.Remove(path)
+
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	var (
+		id   = len(s.jobs) + 1
+		job  = models.ImportJob{ID: id, Status: subimporter.StatusFinished}
+		errs bytes.Buffer
+		w    = csv.NewWriter(&errs)
+	)
+	_ = w.Write([]string{"line", "reason", "record"})
+
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		var r record
+		if err := json.Unmarshal(sc.Bytes(), &r); err != nil {
+			return 0, err
+		}
+
+		job.Total++
+		if s.reject[r.Email] {
+			job.Rejected++
+			job.HasErrors = true
+			_ = w.Write([]string{fmt.Sprint(job.Total), "invalid", sc.Text()})
+			continue
+		}
+		job.Imported++
+
+		if opt.Mode == subimporter.ModeUnsubscribe {
+			s.unsubs = append(s.unsubs, r.Email)
+		}
+	}
+	w.Flush()
+
+	if s.status != "" {
+		job.Status = s.status
+	}
+	s.jobs[id] = job
+	s.errs[id] = errs.Bytes()
+
+	return id, nil
+}
+
+func TestProcess(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `[
+			{"email": "one@example.com", "name": "One"},
+			{"email": "Two@example.com", "name": "Two"},
+			{"email": "three@example.com", "name": "Three"},
+			{"email": "two@example.com", "name": "Dupe"},
+			{"email": "bad", "name": "Bad"},
+			{"email": "four@example.com", "name": "Four"}
+		]`)
+	}))
+	defer srv.Close()
+
+	// The default client refuses private and loopback addresses.
+	s := New(Opt{}, &store{}, log.New(io.Discard, "", 0))
+	if _, err := s.fetchHTTP(models.SyncSource{URL: srv.URL}); !errors.Is(err, utils.ErrPrivateAddr) {
+		t.Fatalf("expected private address error, got %v", err)
+	}
+
+	cases := []struct {
+		name    string
+		status  string
+		reject  map[string]bool
+		run     models.SyncRun
+		unsubs  []string
+		numJobs int
+	}{
+		{
+			name: "all imported",
+			run: models.SyncRun{
+				Status: models.SyncRunStatusFinished, Total: 6, Skipped: 2, Added: 2, Updated: 2, Removed: 2,
+			},
+			unsubs:  []string{"five@example.com", "six@example.com"},
+			numJobs: 2,
+		},
+		{
+			// Rejected records aren't counted as added or updated, and
+			// aren't unsubscribed.
+			name:   "rejected by the import",
+			reject: map[string]bool{"one@example.com": true, "four@example.com": true},
+			run: models.SyncRun{
+				Status: models.SyncRunStatusFinished, Total: 6, Skipped: 4, Added: 1, Updated: 1, Removed: 2,
+			},
+			unsubs:  []string{"five@example.com", "six@example.com"},
+			numJobs: 2,
+		},
+		{
+			// Nothing is unsubscribed if the upsert fails.
+			name:    "failed import",
+			status:  subimporter.StatusFailed,
+			run:     models.SyncRun{Status: models.SyncRunStatusFailed, Total: 6, Skipped: 2},
+			numJobs: 1,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			st := &store{
+				list:   []string{"one@example.com", "two@example.com", "five@example.com", "six@example.com"},
+				reject: c.reject,
+				status: c.status,
+				jobs:   map[int]models.ImportJob{},
+				errs:   map[int][]byte{},
+			}
+			s := New(Opt{PollInterval: time.Millisecond, QueueImport: st.queueImport}, st, log.New(io.Discard, "", 0))
+			s.client = srv.Client()
+
+			r := s.process(models.SyncSource{
+				Type:               models.SyncSourceTypeHTTP,
+				URL:                srv.URL,
+				ListID:             1,
+				UnsubscribeMissing: true,
+			})
+
+			if len(r.ImportJobIDs) != c.numJobs {
+				t.Errorf("expected %d import jobs, got %v", c.numJobs, r.ImportJobIDs)
+			}
+			if (r.Error != "") != (c.run.Status == models.SyncRunStatusFailed) {
+				t.Errorf("unexpected error: %q", r.Error)
+			}
+
+			r.ImportJobIDs, r.Error = nil, ""
+			if !reflect.DeepEqual(r, c.run) {
+				t.Errorf("expected %+v, got %+v", c.run, r)
+			}
+
+			sort.Strings(st.unsubs)
+			if !reflect.DeepEqual(st.unsubs, c.unsubs) {
+				t.Errorf("expected unsubscribes %v, got %v", c.unsubs, st.unsubs)
+			}
+		})
+	}
+}
+
+func TestDecodeRecords(t *testing.T) {
+	cases := []struct {
+		in  string
+		num int
+		ok  bool
+	}{
+		{`[{"email": "a@example.com"}, {"email": "b@example.com"}]`, 2, true},
+		{"  \n[]", 0, true},
+		{"{\"email\": \"a@example.com\"}\n{\"email\": \"b@example.com\"}\n", 2, true},
+		{"", 0, true},
+		{`[{"email": "a@example.com"}, "x"]`, 0, false},
+		{`{"email": }`, 0, false},
+	}
+
+	for _, c := range cases {
+		out, err := decodeRecords(bytes.NewReader([]byte(c.in)))
+		if (err == nil) != c.ok || len(out) != c.num {
+			t.Errorf("%q: expected %d records (ok=%v), got %d, %v", c.in, c.num, c.ok, len(out), err)
+		}
+	}
+}
+
+func TestToRecord(t *testing.T) {
+	m := models.ImportMapping{
+		{Column: "mail", Field: "email"},
+		{Column: "plan", Field: "attribs.plan"},
+	}
+
+	cases := []struct {
+		m    models.ImportMapping
+		rec  map[string]any
+		want record
+		ok   bool
+	}{
+		{
+			nil, map[string]any{"email": " A@Example.com ", "name": "A", "attribs": map[string]any{"x": "y"}},
+			record{Email: "a@example.com", Name: "A", Attribs: models.JSON{"x": "y"}}, true,
+		},
+		{m, map[string]any{"mail": "b@example.com", "plan": "pro"}, record{Email: "b@example.com", Attribs: models.JSON{"plan": "pro"}}, true},
+		{nil, map[string]any{"email": "bad"}, record{}, false},
+		{m, map[string]any{"email": "c@example.com"}, record{}, false},
+	}
+
+	for _, c := range cases {
+		got, err := toRecord(c.m, c.rec)
+		if (err == nil) != c.ok {
+			t.Errorf("%v: expected ok=%v, got %v", c.rec, c.ok, err)
+			continue
+		}
+		if c.ok && !reflect.DeepEqual(got, c.want) {
+			t.Errorf("expected %+v, got %+v", c.want, got)
+		}
+	}
+}
+
+func TestIsDue(t *testing.T) {
+	now := time.Date(2025, 1, 10, 12, 30, 0, 0, time.UTC)
+	created := models.SyncSource{Schedule: "0 * * * *"}
+	created.CreatedAt.Time = now.Add(-2 * time.Hour)
+
+	ran := created
+	ran.LastRunAt.Valid = true
+	ran.LastRunAt.Time = now.Add(-10 * time.Minute)
+
+	bad := created
+	bad.Schedule = "bad"
+
+	for _, c := range []struct {
+		src  models.SyncSource
+		want bool
+	}{{created, true}, {ran, false}, {bad, false}} {
+		if got := isDue(c.src, now); got != c.want {
+			t.Errorf("%q: expected %v, got %v", c.src.Schedule, c.want, got)
+		}
+	}
+}
@@ -2,12 +2,37 @@ package utils
 
 import (
 	"crypto/rand"
+	"errors"
+	"net"
+	"net/http"
 	"net/mail"
+	"net/netip"
 	"net/url"
 	"path"
+	"regexp"
 	"strings"
+	"syscall"
+	"time"
 )
 
+// ErrPrivateAddr is returned when a public HTTP client connects to a
+// non-public address.
+var ErrPrivateAddr = errors.New("connecting to private, loopback or link-local addresses is not allowed")
+
+// Non-public ranges that aren't covered by netip's Is*() checks.
+var privateRanges = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("64:ff9b::/96"),
+}
+
+// reInterval matches Postgres intervals in the "2 days 3 hours", "1 day 02:00:00"
+// (which intervals are output as) and "90" (seconds) forms.
+var reInterval = regexp.MustCompile(`(?i)^\s*(\d+(\.\d+)?\s*(microseconds?|us|milliseconds?|ms|seconds?|secs?|s|minutes?|mins?|m|hours?|hrs?|h|days?|d|weeks?|w|months?|mons?|years?|yrs?|y)\b\s*)*(\d+(:\d{1,2}){0,2}(\.\d+)?)?\s*$`)
+
 // ValidateEmail validates whether the given string is a correctly formed e-mail address.
 func ValidateEmail(email string) bool {
 	// Since `mail.ParseAddress` parses an email address which can also contain an optional name component,
@@ -21,6 +46,12 @@ func ValidateEmail(email string) bool {
 	return true
 }
 
+// ValidateInterval validates whether the given string is a non-negative Postgres
+// interval, eg: "0", "12 hours", "3 days", "1 day 02:00:00".
+func ValidateInterval(s string) bool {
+	return strings.TrimSpace(s) != "" && reInterval.MatchString(s)
+}
+
 // GenerateRandomString generates a cryptographically random, alphanumeric string of length n.
 func GenerateRandomString(n int) (string, error) {
 	const dictionary = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
@@ -51,3 +82,140 @@ func SanitizeURI(u string) string {
 
 	return path.Clean(p.Path)
 }
+
+// NewPublicHTTPClient returns an HTTP client that only connects to public IP
+// addresses, for fetching user supplied URLs without exposing internal
+// services (SSRF). The check is done on the resolved address of every
+// connection, so it applies to redirects and can't be bypassed with DNS
+// records pointing to internal addresses. Proxies are not used.
+func NewPublicHTTPClient(timeout time.Duration) *http.Client {
+	d := &net.Dialer{
+		Timeout: 10 * time.Second,
+		Control: publicDialControl,
+	}
+
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			Proxy:               nil,
+			DialContext:         d.DialContext,
+			TLSHandshakeTimeout: 10 * time.Second,
+		},
+	}
+}
+
+// IsPublicIP checks whether an IP address is a public unicast address.
+func IsPublicIP(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	if !ip.IsValid() || !ip.IsGlobalUnicast() || ip.IsPrivate() {
+		return false
+	}
+
+	for _, p := range privateRanges {
+		if p.Contains(ip) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// publicDialControl refuses connections to non-public addresses.
+func publicDialControl(network, address string, _ syscall.RawConn) error {
+	ap, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return err
+	}
+	if !IsPublicIP(ap.Addr()) {
+		return ErrPrivateAddr
+	}
+
+	return nil
+}
+
+// maxDiffCells is the max size of the LCS table DiffLines builds. Beyond this,
+// texts are diffed as a full replacement.
+const maxDiffCells = 4_000_000
+
+// DiffLines returns a line-by-line diff of two texts where every line is
+// prefixed with "  " (unchanged), "- " (removed from a) or "+ " (added in b).
+func DiffLines(a, b string) []string {
+	var al, bl []string
+	if a != "" {
+		al = strings.Split(a, "\n")
+	}
+	if b != "" {
+		bl = strings.Split(b, "\n")
+	}
+
+	// Trim the common prefix and suffix to keep the LCS table small.
+	pre := 0
+	for pre < len(al) && pre < len(bl) && al[pre] == bl[pre] {
+		pre++
+	}
+	suf := 0
+	for suf < len(al)-pre && suf < len(bl)-pre && al[len(al)-1-suf] == bl[len(bl)-1-suf] {
+		suf++
+	}
+
+	out := make([]string, 0, len(al)+len(bl))
+	for _, l := range al[:pre] {
+		out = append(out, "  "+l)
+	}
+
+	var (
+		x = al[pre : len(al)-suf]
+		y = bl[pre : len(bl)-suf]
+	)
+	if len(x)*len(y) > maxDiffCells {
+		for _, l := range x {
+			out = append(out, "- "+l)
+		}
+		for _, l := range y {
+			out = append(out, "+ "+l)
+		}
+	} else {
+		// lcs[i][j] is the length of the longest common subsequence of x[i:] and y[j:].
+		lcs := make([][]int, len(x)+1)
+		for i := range lcs {
+			lcs[i] = make([]int, len(y)+1)
+		}
+		for i := len(x) - 1; i >= 0; i-- {
+			for j := len(y) - 1; j >= 0; j-- {
+				if x[i] == y[j] {
+					lcs[i][j] = lcs[i+1][j+1] + 1
+				} else {
+					lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+				}
+			}
+		}
+
+		i, j := 0, 0
+		for i < len(x) && j < len(y) {
+			switch {
+			case x[i] == y[j]:
+				out = append(out, "  "+x[i])
+				i++
+				j++
+			case lcs[i+1][j] >= lcs[i][j+1]:
+				out = append(out, "- "+x[i])
+				i++
+			default:
+				out = append(out, "+ "+y[j])
+				j++
+			}
+		}
+		for ; i < len(x); i++ {
+			out = append(out, "- "+x[i])
+		}
+		for ; j < len(y); j++ {
+			out = append(out, "+ "+y[j])
+		}
+	}
+
+	for _, l := range al[len(al)-suf:] {
+		out = append(out, "  "+l)
+	}
+
+	return out
+}
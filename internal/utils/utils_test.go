@@ -0,0 +1,110 @@
+package utils
+
+import (
+	"net/netip"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDiffLines(t *testing.T) {
+	cases := []struct {
+		name string
+		a, b string
+		want []string
+	}{
+		{"equal", "a\nb", "a\nb", []string{"  a", "  b"}},
+		{"both empty", "", "", []string{}},
+		{"added to empty", "", "a\nb", []string{"+ a", "+ b"}},
+		{"removed all", "a\nb", "", []string{"- a", "- b"}},
+		{"changed middle", "a\nb\nc", "a\nx\nc", []string{"  a", "- b", "+ x", "  c"}},
+		{"inserted", "a\nc", "a\nb\nc", []string{"  a", "+ b", "  c"}},
+		{"removed", "a\nb\nc", "a\nc", []string{"  a", "- b", "  c"}},
+		{"appended", "a", "a\nb", []string{"  a", "+ b"}},
+		{
+			"moved", "a\nb\nc\nd", "b\nc\na\nd",
+			[]string{"- a", "  b", "  c", "+ a", "  d"},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := DiffLines(c.a, c.b); !reflect.DeepEqual(got, c.want) {
+				t.Errorf("expected %q, got %q", c.want, got)
+			}
+		})
+	}
+}
+
+func TestDiffLinesLarge(t *testing.T) {
+	// Texts beyond the LCS table limit are diffed as a full replacement of the
+	// changed part, keeping the common prefix and suffix.
+	var a, b []string
+	for i := range 3000 {
+		a = append(a, "a"+strings.Repeat("x", i%7))
+		b = append(b, "b"+strings.Repeat("x", i%7))
+	}
+
+	got := DiffLines("head\n"+strings.Join(a, "\n")+"\ntail", "head\n"+strings.Join(b, "\n")+"\ntail")
+	if len(got) != 6002 || got[0] != "  head" || got[1] != "- a" || got[3001] != "+ b" || got[6001] != "  tail" {
+		t.Errorf("unexpected diff: %d lines", len(got))
+	}
+}
+
+func TestIsPublicIP(t *testing.T) {
+	cases := map[string]bool{
+		"93.184.216.34":        true,
+		"2606:4700::6810:85e5": true,
+		"127.0.0.1":            false,
+		"::1":                  false,
+		"10.1.2.3":             false,
+		"172.16.0.1":           false,
+		"192.168.1.1":          false,
+		"169.254.169.254":      false,
+		"100.100.100.200":      false,
+		"0.0.0.0":              false,
+		"::":                   false,
+		"fe80::1":              false,
+		"fd00:ec2::254":        false,
+		"224.0.0.1":            false,
+		"::ffff:127.0.0.1":     false,
+		"::ffff:8.8.8.8":       true,
+	}
+
+	for ip, want := range cases {
+		if got := IsPublicIP(netip.MustParseAddr(ip)); got != want {
+			t.Errorf("%s: expected %v, got %v", ip, want, got)
+		}
+	}
+}
+
+func TestValidateInterval(t *testing.T) {
+	cases := map[string]bool{
+		"0":              true,
+		"90":             true,
+		"1 hour":         true,
+		"3 days":         true,
+		"3days":          true,
+		"1.5 hours":      true,
+		"2 Weeks 1 day":  true,
+		"1 mon 2 days":   true,
+		"12h 30m":        true,
+		"00:00:00":       true,
+		"01:30":          true,
+		"1 day 02:00:00": true,
+		"":               false,
+		" ":              false,
+		"-1 day":         false,
+		"1 day ago":      false,
+		"tomorrow":       false,
+		"3 dayz":         false,
+		"1 hour; DROP":   false,
+		"1:2:3:4":        false,
+	}
+
+	for s, want := range cases {
+		if got := ValidateInterval(s); got != want {
+			t.Errorf("%q: expected %v, got %v", s, want, got)
+		}
+	}
+}
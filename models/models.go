@@ -9,6 +9,7 @@ import (
 	"html/template"
 	"net/textproto"
 	"regexp"
+	"strconv"
 	"strings"
 	txttpl "text/template"
 	"time"
@@ -35,6 +36,30 @@ const (
 	SubscriptionStatusConfirmed    = "confirmed"
 	SubscriptionStatusUnsubscribed = "unsubscribed"
 
+	// Subscriber activity.
+	ActivityCreated             = "created"
+	ActivityProfileUpdated      = "profile_updated"
+	ActivityBlocklisted         = "blocklisted"
+	ActivitySubscribed          = "subscribed"
+	ActivityUnsubscribed        = "unsubscribed"
+	ActivitySubscriptionRemoved = "subscription_removed"
+	ActivityOptinConfirmed      = "optin_confirmed"
+	ActivityTxSent              = "tx_sent"
+	ActivityCampaignTargeted    = "campaign_targeted"
+	ActivityCampaignView        = "campaign_view"
+	ActivityLinkClick           = "link_click"
+	ActivityBounce              = "bounce"
+	ActivityMerged              = "merged"
+	ActivityReply               = "reply"
+	ActivityActorUser           = "user"
+	ActivityActorSubscriber     = "subscriber"
+	ActivityActorSystem         = "system"
+
+	// Sources of subscriber initiated unsubscriptions recorded in the activity timeline.
+	UnsubSourceHeader = "header"
+	UnsubSourcePage   = "page"
+	UnsubSourceReply  = "reply"
+
 	// Campaign.
 	CampaignStatusDraft         = "draft"
 	CampaignStatusScheduled     = "scheduled"
@@ -44,6 +69,7 @@ const (
 	CampaignStatusCancelled     = "cancelled"
 	CampaignTypeRegular         = "regular"
 	CampaignTypeOptin           = "optin"
+	CampaignTypeDigest          = "digest"
 	CampaignContentTypeRichtext = "richtext"
 	CampaignContentTypeHTML     = "html"
 	CampaignContentTypeMarkdown = "markdown"
@@ -56,6 +82,31 @@ const (
 	ListOptinSingle = "single"
 	ListOptinDouble = "double"
 
+	// Sequence subscriber.
+	SequenceSubStatusActive   = "active"
+	SequenceSubStatusFinished = "finished"
+	SequenceSubStatusExited   = "exited"
+
+	// Subscriber attribute types.
+	AttribTypeString = "string"
+	AttribTypeNumber = "number"
+	AttribTypeBool   = "bool"
+	AttribTypeDate   = "date"
+	AttribTypeList   = "list"
+	AttribTypeObject = "object"
+
+	// Subscriber sync.
+	SyncSourceTypeHTTP     = "http"
+	SyncSourceTypePostgres = "postgres"
+	SyncRunStatusRunning   = "running"
+	SyncRunStatusFinished  = "finished"
+	SyncRunStatusFailed    = "failed"
+
+	// Trash.
+	TrashSubscribers = "subscribers"
+	TrashLists       = "lists"
+	TrashCampaigns   = "campaigns"
+
 	// BaseTpl is the name of the base template.
 	BaseTpl = "base"
 
@@ -78,12 +129,26 @@ const (
 	BounceTypeSoft      = "soft"
 	BounceTypeComplaint = "complaint"
 
+	// Delivery events reported by bounce webhooks.
+	DeliveryEventDelivered = "delivered"
+	DeliveryEventOpen      = "open"
+	DeliveryEventClick     = "click"
+
+	// Inbound e-mails.
+	InboundTypeReply       = "reply"
+	InboundTypeUnsubscribe = "unsubscribe"
+
 	// Templates.
 	TemplateTypeCampaign       = "campaign"
 	TemplateTypeCampaignVisual = "campaign_visual"
 	TemplateTypeTx             = "tx"
 )
 
+// ErrDailyLimit is returned by a messenger when it has reached its daily
+// sending limit, for instance, an SMTP server on a warm-up plan. Campaigns that
+// run into it are paused and automatically resumed the next day.
+var ErrDailyLimit = errors.New("daily sending limit reached")
+
 // Headers represents an array of string maps used to represent SMTP, HTTP headers etc.
 // similar to url.Values{}
 type Headers []map[string]string
@@ -241,6 +306,14 @@ type Campaign struct {
 	ArchiveTemplateID null.Int        `db:"archive_template_id" json:"archive_template_id"`
 	ArchiveMeta       json.RawMessage `db:"archive_meta" json:"archive_meta"`
 
+	// FeedItems are the new feed items picked up by a digest campaign
+	// that this campaign was created from. Exposed in templates as .Items.
+	FeedItems FeedItems `db:"feed_items" json:"feed_items"`
+
+	// ResumeAt is the time at which a campaign that was paused on hitting
+	// a daily sending limit is automatically resumed.
+	ResumeAt null.Time `db:"resume_at" json:"resume_at"`
+
 	// TemplateBody is joined in from templates by the next-campaigns query.
 	TemplateBody        string             `db:"template_body" json:"-"`
 	ArchiveTemplateBody string             `db:"archive_template_body" json:"-"`
@@ -267,6 +340,10 @@ type CampaignMeta struct {
 	Clicks     int `db:"clicks" json:"clicks"`
 	Bounces    int `db:"bounces" json:"bounces"`
 
+	// Delivery confirmations and spam complaints reported by bounce webhooks.
+	Delivered  int `db:"delivered" json:"delivered"`
+	Complaints int `db:"complaints" json:"complaints"`
+
 	// This is a list of {list_id, name} pairs unlike Subscriber.Lists[]
 	// because lists can be deleted after a campaign is finished, resulting
 	// in null lists data to be returned. For that reason, campaign_lists maintains
@@ -302,6 +379,387 @@ type CampaignAnalyticsLink struct {
 	Count int    `db:"count" json:"count"`
 }
 
+// FeedItem represents a single item (post) picked up from an RSS, Atom or JSON feed.
+type FeedItem struct {
+	Title       string    `json:"title"`
+	URL         string    `json:"url"`
+	Description string    `json:"description"`
+	Content     string    `json:"content"`
+	Author      string    `json:"author"`
+	GUID        string    `json:"guid"`
+	PublishedAt time.Time `json:"published_at"`
+}
+
+// FeedItems represents a list of feed items stored as JSONB.
+type FeedItems []FeedItem
+
+// CampaignRevision represents a snapshot of a campaign's content fields
+// recorded when the campaign is saved.
+type CampaignRevision struct {
+	ID         int            `db:"id" json:"id"`
+	CampaignID int            `db:"campaign_id" json:"campaign_id"`
+	Version    int            `db:"version" json:"version"`
+	UserID     null.Int       `db:"user_id" json:"user_id"`
+	UserName   string         `db:"user_name" json:"user_name"`
+	Changed    pq.StringArray `db:"changed" json:"changed"`
+
+	// Data is the snapshot of the content fields. It's not loaded when
+	// listing revisions.
+	Data types.JSONText `db:"data" json:"data,omitempty"`
+
+	// SentAt is set on the revision that was frozen when the campaign started running.
+	SentAt    null.Time `db:"sent_at" json:"sent_at"`
+	CreatedAt null.Time `db:"created_at" json:"created_at"`
+}
+
+// ImportJob represents a queued or processed subscriber import.
+type ImportJob struct {
+	ID       int            `db:"id" json:"id"`
+	UserID   null.Int       `db:"user_id" json:"user_id"`
+	UserName string         `db:"user_name" json:"user_name"`
+	Name     string         `db:"name" json:"name"`
+	Params   types.JSONText `db:"params" json:"params"`
+	FilePath string         `db:"file_path" json:"-"`
+	DryRun   bool           `db:"dry_run" json:"dry_run"`
+	Status   string         `db:"status" json:"status"`
+	Total    int            `db:"total" json:"total"`
+	Imported int            `db:"imported" json:"imported"`
+	Rejected int            `db:"rejected" json:"rejected"`
+	Log      string         `db:"log" json:"-"`
+
+	// HasErrors indicates whether there's a CSV of rejected rows.
+	HasErrors  bool      `db:"has_errors" json:"has_errors"`
+	StartedAt  null.Time `db:"started_at" json:"started_at"`
+	FinishedAt null.Time `db:"finished_at" json:"finished_at"`
+	CreatedAt  null.Time `db:"created_at" json:"created_at"`
+	UpdatedAt  null.Time `db:"updated_at" json:"updated_at"`
+
+	// Pseudofield for getting the total number of jobs
+	// in paginated queries.
+	TotalJobs int `db:"total_jobs" json:"-"`
+}
+
+// ImportColumn maps a CSV column to a subscriber field in an import.
+type ImportColumn struct {
+	// Column is the CSV header of the column. It can be empty if there's
+	// a default, which is then used for all rows.
+	Column string `json:"column"`
+
+	// Field is email, name, attributes (a JSON object) or an attribute
+	// path prefixed with attribs., eg: attribs.address.city.
+	Field string `json:"field"`
+
+	// Type is the type an attribute value is coerced to: string (default),
+	// int, float, bool, date or list.
+	Type string `json:"type"`
+
+	// Default is used when the column is missing or its value is empty.
+	Default string `json:"default"`
+
+	// Format is the Go time layout of date values or the separator of
+	// list values.
+	Format string `json:"format"`
+}
+
+// ImportMapping represents a list of CSV column mappings stored as JSONB.
+type ImportMapping []ImportColumn
+
+// AttribField represents a typed subscriber attribute in the attribute schema.
+type AttribField struct {
+	ID          int            `db:"id" json:"id"`
+	Name        string         `db:"name" json:"name"`
+	Type        string         `db:"type" json:"type"`
+	Description string         `db:"description" json:"description"`
+	Enum        pq.StringArray `db:"enum_values" json:"enum"`
+	Required    bool           `db:"required" json:"required"`
+
+	// Public attributes can be set on public subscription forms.
+	Public    bool      `db:"public" json:"public"`
+	CreatedAt null.Time `db:"created_at" json:"created_at"`
+	UpdatedAt null.Time `db:"updated_at" json:"updated_at"`
+}
+
+// AttribSchema represents the schema of typed subscriber attributes.
+// Attributes that are not in the schema are free-form.
+type AttribSchema []AttribField
+
+// ImportPreset represents a saved, reusable CSV column mapping.
+type ImportPreset struct {
+	ID        int           `db:"id" json:"id"`
+	Name      string        `db:"name" json:"name"`
+	Mapping   ImportMapping `db:"mapping" json:"mapping"`
+	CreatedAt null.Time     `db:"created_at" json:"created_at"`
+	UpdatedAt null.Time     `db:"updated_at" json:"updated_at"`
+}
+
+// SyncSource represents an external source (HTTP JSON endpoint or a Postgres
+// query) that subscribers are periodically synced from into a list.
+type SyncSource struct {
+	Base
+
+	Name string `db:"name" json:"name"`
+	Type string `db:"type" json:"type"`
+
+	// URL is the HTTP(S) URL of the endpoint, or the Postgres DSN.
+	URL     string `db:"url" json:"url"`
+	Query   string `db:"query" json:"query"`
+	Headers JSON   `db:"headers" json:"headers"`
+
+	Schedule           string        `db:"schedule" json:"schedule"`
+	Mapping            ImportMapping `db:"mapping" json:"mapping"`
+	ListID             int           `db:"list_id" json:"list_id"`
+	SubStatus          string        `db:"subscription_status" json:"subscription_status"`
+	Overwrite          bool          `db:"overwrite" json:"overwrite"`
+	UnsubscribeMissing bool          `db:"unsubscribe_missing" json:"unsubscribe_missing"`
+	Enabled            bool          `db:"enabled" json:"enabled"`
+	UserID             null.Int      `db:"user_id" json:"user_id"`
+	LastRunAt          null.Time     `db:"last_run_at" json:"last_run_at"`
+}
+
+// SyncRun represents a single run of a sync source.
+type SyncRun struct {
+	ID       int    `db:"id" json:"id"`
+	SourceID int    `db:"source_id" json:"source_id"`
+	Status   string `db:"status" json:"status"`
+
+	// Total is the number of records fetched from the source, of which Skipped
+	// were invalid. Added is the number of subscribers that weren't in the
+	// list, Updated, those that were, and Removed, those that were unsubscribed
+	// from the list as they were no longer in the source.
+	Total   int `db:"total" json:"total"`
+	Skipped int `db:"skipped" json:"skipped"`
+	Added   int `db:"added" json:"added"`
+	Updated int `db:"updated" json:"updated"`
+	Removed int `db:"removed" json:"removed"`
+
+	// IDs of the import jobs queued by the run.
+	ImportJobIDs pq.Int64Array `db:"import_job_ids" json:"import_job_ids"`
+	Error        string        `db:"error" json:"error"`
+	StartedAt    null.Time     `db:"started_at" json:"started_at"`
+	FinishedAt   null.Time     `db:"finished_at" json:"finished_at"`
+
+	// Pseudofield for getting the total number of runs in paginated queries.
+	TotalRuns int `db:"total_runs" json:"-"`
+}
+
+// CampaignFeed represents the feed polled by a digest campaign.
+type CampaignFeed struct {
+	ID             int       `db:"id" json:"id"`
+	CampaignID     int       `db:"campaign_id" json:"campaign_id"`
+	URL            string    `db:"url" json:"url"`
+	PollInterval   string    `db:"poll_interval" json:"poll_interval"`
+	MaxItems       int       `db:"max_items" json:"max_items"`
+	Enabled        bool      `db:"enabled" json:"enabled"`
+	LastItemAt     null.Time `db:"last_item_at" json:"last_item_at"`
+	LastCheckedAt  null.Time `db:"last_checked_at" json:"last_checked_at"`
+	LastCampaignID null.Int  `db:"last_campaign_id" json:"last_campaign_id"`
+	CreatedAt      null.Time `db:"created_at" json:"created_at"`
+	UpdatedAt      null.Time `db:"updated_at" json:"updated_at"`
+}
+
+// Sequence represents a drip/autoresponder sequence of messages.
+type Sequence struct {
+	Base
+
+	Name      string   `db:"name" json:"name"`
+	ListID    null.Int `db:"list_id" json:"list_id"`
+	FromEmail string   `db:"from_email" json:"from_email"`
+	Messenger string   `db:"messenger" json:"messenger"`
+	Enabled   bool     `db:"enabled" json:"enabled"`
+
+	// Subscriber counts by status in the sequence.
+	Active   int `db:"active" json:"active"`
+	Finished int `db:"finished" json:"finished"`
+	Exited   int `db:"exited" json:"exited"`
+
+	Steps []SequenceStep `db:"-" json:"steps"`
+}
+
+// SequenceStep represents a single step (message) in a sequence.
+type SequenceStep struct {
+	ID         int      `db:"id" json:"id"`
+	SequenceID int      `db:"sequence_id" json:"sequence_id"`
+	Position   int      `db:"position" json:"position"`
+	Delay      string   `db:"delay" json:"delay"`
+	TemplateID null.Int `db:"template_id" json:"template_id"`
+	Subject    string   `db:"subject" json:"subject"`
+
+	// Stats: number of messages sent and the number of subscribers waiting for this step.
+	Sent    int `db:"sent" json:"sent"`
+	Waiting int `db:"waiting" json:"waiting"`
+}
+
+// SequenceMessage represents a sequence step that's due to be sent to a subscriber.
+type SequenceMessage struct {
+	SequenceID   int      `db:"sequence_id"`
+	SubscriberID int      `db:"subscriber_id"`
+	StepID       int      `db:"step_id"`
+	Position     int      `db:"position"`
+	TemplateID   null.Int `db:"template_id"`
+	Subject      string   `db:"subject"`
+	FromEmail    string   `db:"from_email"`
+	Messenger    string   `db:"messenger"`
+}
+
+// SubscriberEvent represents an arbitrary event recorded against a subscriber.
+type SubscriberEvent struct {
+	ID           int64          `db:"id" json:"id"`
+	SubscriberID int            `db:"subscriber_id" json:"subscriber_id"`
+	Name         string         `db:"name" json:"name"`
+	Data         types.JSONText `db:"data" json:"data"`
+	CreatedAt    null.Time      `db:"created_at" json:"created_at"`
+}
+
+// SubscriberEventReq represents a subscriber event to be recorded. The
+// subscriber is identified by ID or e-mail.
+type SubscriberEventReq struct {
+	SubscriberID    int             `json:"subscriber_id"`
+	SubscriberEmail string          `json:"subscriber_email"`
+	Event           string          `json:"event"`
+	Data            json.RawMessage `json:"data"`
+}
+
+// SubscriberActivity represents an entry in a subscriber's activity timeline.
+type SubscriberActivity struct {
+	Type      string      `db:"type" json:"type"`
+	Data      JSON        `db:"data" json:"data"`
+	Actor     string      `db:"actor" json:"actor"`
+	UserID    null.Int    `db:"user_id" json:"user_id"`
+	UserName  null.String `db:"user_name" json:"user_name"`
+	CreatedAt null.Time   `db:"created_at" json:"created_at"`
+
+	Total int `db:"total" json:"-"`
+}
+
+// Unsubscription represents an unsubscription made by a subscriber
+// (and its source) for compliance reporting.
+type Unsubscription struct {
+	SubscriberID   int            `db:"subscriber_id" json:"subscriber_id"`
+	SubscriberUUID string         `db:"subscriber_uuid" json:"subscriber_uuid"`
+	Email          string         `db:"email" json:"email"`
+	Type           string         `db:"type" json:"type"`
+	Source         string         `db:"source" json:"source"`
+	ListIDs        types.JSONText `db:"list_ids" json:"list_ids"`
+	CampaignUUID   string         `db:"campaign_uuid" json:"campaign_uuid"`
+	CampaignID     null.Int       `db:"campaign_id" json:"campaign_id"`
+	CampaignName   null.String    `db:"campaign_name" json:"campaign_name"`
+	CreatedAt      null.Time      `db:"created_at" json:"created_at"`
+
+	Total int `db:"total" json:"-"`
+}
+
+// DuplicateRules are the rules for normalizing subscriber e-mails to find
+// duplicate subscribers.
+type DuplicateRules struct {
+	// Compare e-mails case-insensitively.
+	IgnoreCase bool `json:"ignore_case"`
+
+	// Ignore +tags in the local part, eg: john+news@example.com.
+	PlusAddressing bool `json:"plus_addressing"`
+
+	// Ignore dots in the local part of Gmail addresses and treat googlemail.com
+	// as gmail.com.
+	GmailDots bool `json:"gmail_dots"`
+
+	// Domains that are aliases of other domains, eg: {"example.net": "example.com"}.
+	DomainAliases map[string]string `json:"domain_aliases"`
+}
+
+// Key returns an e-mail normalized by the rules, which is the same for the
+// e-mails of duplicate subscribers.
+func (r DuplicateRules) Key(email string) string {
+	if r.IgnoreCase {
+		email = strings.ToLower(email)
+	}
+
+	local, domain := email, ""
+	if i := strings.LastIndex(email, "@"); i >= 0 {
+		local, domain = email[:i], email[i+1:]
+	}
+
+	if d, ok := r.DomainAliases[strings.ToLower(domain)]; ok {
+		domain = d
+	}
+	if r.PlusAddressing {
+		local, _, _ = strings.Cut(local, "+")
+	}
+	if r.GmailDots {
+		switch strings.ToLower(domain) {
+		case "gmail.com", "googlemail.com":
+			local = strings.ReplaceAll(local, ".", "")
+			domain = "gmail.com"
+		}
+	}
+
+	return local + "@" + domain
+}
+
+// DuplicateGroup represents a set of subscribers whose e-mails are the same
+// after normalization.
+type DuplicateGroup struct {
+	Key         string         `db:"key" json:"key"`
+	Subscribers types.JSONText `db:"subscribers" json:"subscribers"`
+}
+
+// SubscriberMerge represents a record of subscribers merged into a subscriber.
+type SubscriberMerge struct {
+	ID           int            `db:"id" json:"id"`
+	SubscriberID null.Int       `db:"subscriber_id" json:"subscriber_id"`
+	Email        string         `db:"email" json:"email"`
+	Merged       types.JSONText `db:"merged" json:"merged"`
+	UserID       null.Int       `db:"user_id" json:"user_id"`
+	UserName     null.String    `db:"user_name" json:"user_name"`
+	CreatedAt    null.Time      `db:"created_at" json:"created_at"`
+
+	Total int `db:"total" json:"-"`
+}
+
+// TrashItem represents a trashed subscriber, list or campaign. Status is the
+// list type for lists.
+type TrashItem struct {
+	ID        int       `db:"id" json:"id"`
+	UUID      string    `db:"uuid" json:"uuid"`
+	Name      string    `db:"name" json:"name"`
+	Email     string    `db:"email" json:"email,omitempty"`
+	Status    string    `db:"status" json:"status"`
+	DeletedAt null.Time `db:"deleted_at" json:"deleted_at"`
+
+	Total int `db:"total" json:"-"`
+}
+
+// Automation represents a rule that sends a tx template to a subscriber
+// a given time after a matching event is recorded.
+type Automation struct {
+	Base
+
+	Name       string         `db:"name" json:"name"`
+	Event      string         `db:"event" json:"event"`
+	Filter     types.JSONText `db:"filter" json:"filter"`
+	Delay      string         `db:"delay" json:"delay"`
+	TemplateID null.Int       `db:"template_id" json:"template_id"`
+	Subject    string         `db:"subject" json:"subject"`
+	FromEmail  string         `db:"from_email" json:"from_email"`
+	Messenger  string         `db:"messenger" json:"messenger"`
+	Enabled    bool           `db:"enabled" json:"enabled"`
+
+	// Stats: messages sent and messages waiting to be sent.
+	Sent    int `db:"sent" json:"sent"`
+	Pending int `db:"pending" json:"pending"`
+}
+
+// AutomationJob represents an automation message that's due to be sent.
+type AutomationJob struct {
+	ID           int64          `db:"id"`
+	AutomationID int            `db:"automation_id"`
+	SubscriberID int            `db:"subscriber_id"`
+	Event        string         `db:"event"`
+	Data         types.JSONText `db:"data"`
+	TemplateID   null.Int       `db:"template_id"`
+	Subject      string         `db:"subject"`
+	FromEmail    string         `db:"from_email"`
+	Messenger    string         `db:"messenger"`
+}
+
 // Campaigns represents a slice of Campaigns.
 type Campaigns []Campaign
 
@@ -344,6 +802,64 @@ type Bounce struct {
 	Total int `db:"total" json:"-"`
 }
 
+// BounceStat represents the aggregated bounces of a recipient domain,
+// campaign or messenger for bounce analytics.
+type BounceStat struct {
+	Key         string `db:"key" json:"key"`
+	Name        string `db:"name" json:"name"`
+	Bounces     int    `db:"bounces" json:"bounces"`
+	Hard        int    `db:"hard" json:"hard"`
+	Soft        int    `db:"soft" json:"soft"`
+	Complaints  int    `db:"complaints" json:"complaints"`
+	Subscribers int    `db:"subscribers" json:"subscribers"`
+
+	// Total is the number of subscribers on a domain, or the number of
+	// messages sent by campaigns. Rate is the % of Total that bounced.
+	Total int     `db:"total" json:"total"`
+	Rate  float64 `db:"-" json:"rate"`
+}
+
+// InboundMessage represents an e-mail received on the inbound mailbox, eg: a reply
+// to a campaign or an unsubscribe request.
+type InboundMessage struct {
+	Type      string    `json:"type"`
+	Email     string    `json:"email"`
+	Name      string    `json:"name"`
+	Subject   string    `json:"subject"`
+	Body      string    `json:"body"`
+	MessageID string    `json:"message_id"`
+	Date      time.Time `json:"date"`
+
+	// Picked up from the headers of the e-mail or the e-mail that's replied to, if any.
+	SubscriberUUID string `json:"subscriber_uuid"`
+	CampaignUUID   string `json:"campaign_uuid"`
+
+	// ListUnsubscribe is set for unsubscribe requests sent to a List-Unsubscribe
+	// mailto address as opposed to "unsubscribe" replies.
+	ListUnsubscribe bool `json:"-"`
+
+	// Raw is the raw e-mail.
+	Raw []byte `json:"-"`
+}
+
+// DeliveryEvent represents a delivery, open or click event reported by
+// a provider's webhook.
+type DeliveryEvent struct {
+	Type      string
+	Source    string
+	Meta      json.RawMessage
+	CreatedAt time.Time
+
+	// One of these should be provided.
+	Email          string
+	SubscriberUUID string
+
+	CampaignUUID string
+
+	// URL is the clicked link in click events.
+	URL string
+}
+
 // Message is the message pushed to a Messenger.
 type Message struct {
 	From        string
@@ -405,6 +921,10 @@ type TxMessage struct {
 	// Channels is the new flexible multi-channel configuration
 	Channels []TxChannel `json:"channels,omitempty"`
 
+	// IgnoreSuppression sends the message even to subscribers who are blocklisted,
+	// or have hard bounced or complained. Meant for critical messages.
+	IgnoreSuppression bool `json:"ignore_suppression"`
+
 	// File attachments added from multi-part form data.
 	Attachments []Attachment `json:"-"`
 
@@ -525,6 +1045,8 @@ func (camps Campaigns) LoadStats(stmt *sqlx.Stmt) error {
 			camps[i].Views = c.Views
 			camps[i].Clicks = c.Clicks
 			camps[i].Bounces = c.Bounces
+			camps[i].Delivered = c.Delivered
+			camps[i].Complaints = c.Complaints
 			camps[i].Media = c.Media
 		}
 	}
@@ -768,3 +1290,237 @@ func (h Headers) Value() (driver.Value, error) {
 
 	return "[]", nil
 }
+
+// Scan implements the sql.Scanner interface.
+func (f *FeedItems) Scan(src any) error {
+	var b []byte
+	switch src := src.(type) {
+	case []byte:
+		b = src
+	case string:
+		b = []byte(src)
+	case nil:
+		return nil
+	}
+
+	return json.Unmarshal(b, f)
+}
+
+// Value implements the driver.Valuer interface.
+func (f FeedItems) Value() (driver.Value, error) {
+	if len(f) == 0 {
+		return "[]", nil
+	}
+
+	return json.Marshal(f)
+}
+
+// Scan implements the sql.Scanner interface.
+func (m *ImportMapping) Scan(src any) error {
+	var b []byte
+	switch src := src.(type) {
+	case []byte:
+		b = src
+	case string:
+		b = []byte(src)
+	case nil:
+		return nil
+	}
+
+	return json.Unmarshal(b, m)
+}
+
+// Value implements the driver.Valuer interface.
+func (m ImportMapping) Value() (driver.Value, error) {
+	if len(m) == 0 {
+		return "[]", nil
+	}
+
+	return json.Marshal(m)
+}
+
+// Validate validates subscriber attributes against the schema and returns
+// them with keys that match schema attributes case-insensitively (eg: Country
+// and country) renamed to the schema names. If partial is true, required
+// attributes are not checked.
+func (s AttribSchema) Validate(attribs JSON, partial bool) (JSON, error) {
+	if len(s) == 0 {
+		return attribs, nil
+	}
+
+	fields := make(map[string]AttribField, len(s))
+	for _, f := range s {
+		fields[strings.ToLower(f.Name)] = f
+	}
+
+	out := make(JSON, len(attribs))
+	for k, v := range attribs {
+		f, ok := fields[strings.ToLower(k)]
+		if !ok {
+			out[k] = v
+			continue
+		}
+
+		if _, ok := out[f.Name]; ok {
+			return nil, fmt.Errorf("duplicate attribute '%s'", f.Name)
+		}
+		if err := f.validate(v); err != nil {
+			return nil, err
+		}
+		out[f.Name] = v
+	}
+
+	if !partial {
+		for _, f := range s {
+			if !f.Required {
+				continue
+			}
+			if v, ok := out[f.Name]; !ok || v == nil || v == "" {
+				return nil, fmt.Errorf("attribute '%s' is required", f.Name)
+			}
+		}
+	}
+
+	return out, nil
+}
+
+// validate validates a value against the attribute's type and enum values.
+// nil is valid for all types.
+func (f AttribField) validate(v any) error {
+	if v == nil {
+		return nil
+	}
+
+	errType := fmt.Errorf("attribute '%s' should be of type %s", f.Name, f.Type)
+	switch f.Type {
+	case AttribTypeString:
+		s, ok := v.(string)
+		if !ok {
+			return errType
+		}
+		return f.checkEnum(s)
+
+	case AttribTypeNumber:
+		switch v.(type) {
+		case float64, float32, int, int64, int32, json.Number:
+		default:
+			return errType
+		}
+
+	case AttribTypeBool:
+		if _, ok := v.(bool); !ok {
+			return errType
+		}
+
+	case AttribTypeDate:
+		s, ok := v.(string)
+		if !ok {
+			return errType
+		}
+		if _, err := ParseAttribDate(s); err != nil {
+			return errType
+		}
+
+	case AttribTypeList:
+		switch l := v.(type) {
+		case []any:
+			for _, i := range l {
+				s, ok := i.(string)
+				if !ok && len(f.Enum) > 0 {
+					return errType
+				}
+				if err := f.checkEnum(s); err != nil {
+					return err
+				}
+			}
+		case []string:
+			for _, s := range l {
+				if err := f.checkEnum(s); err != nil {
+					return err
+				}
+			}
+		default:
+			return errType
+		}
+
+	case AttribTypeObject:
+		if _, ok := v.(map[string]any); !ok {
+			return errType
+		}
+	}
+
+	return nil
+}
+
+// checkEnum checks whether a value is one of the attribute's enum values, if any.
+func (f AttribField) checkEnum(s string) error {
+	if len(f.Enum) == 0 {
+		return nil
+	}
+
+	for _, e := range f.Enum {
+		if s == e {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("attribute '%s' should be one of: %s", f.Name, strings.Join(f.Enum, ", "))
+}
+
+// ParseValue parses a string value, eg: from an HTML form, to the attribute's type.
+// Empty strings are returned as nil.
+func (f AttribField) ParseValue(vals []string) (any, error) {
+	if f.Type == AttribTypeList {
+		out := make([]any, 0, len(vals))
+		for _, v := range vals {
+			if v = strings.TrimSpace(v); v != "" {
+				out = append(out, v)
+			}
+		}
+		return out, nil
+	}
+
+	if len(vals) == 0 {
+		return nil, nil
+	}
+	s := strings.TrimSpace(vals[0])
+	if s == "" {
+		return nil, nil
+	}
+
+	errType := fmt.Errorf("attribute '%s' should be of type %s", f.Name, f.Type)
+	switch f.Type {
+	case AttribTypeNumber:
+		n, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return nil, errType
+		}
+		return n, nil
+
+	case AttribTypeBool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return nil, errType
+		}
+		return b, nil
+
+	case AttribTypeObject:
+		var o map[string]any
+		if err := json.Unmarshal([]byte(s), &o); err != nil {
+			return nil, errType
+		}
+		return o, nil
+	}
+
+	return s, nil
+}
+
+// ParseAttribDate parses a date attribute, which is either a date (2006-01-02)
+// or an RFC3339 timestamp.
+func ParseAttribDate(s string) (time.Time, error) {
+	if t, err := time.Parse("2006-01-02", s); err == nil {
+		return t, nil
+	}
+
+	return time.Parse(time.RFC3339, s)
+}
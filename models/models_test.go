@@ -0,0 +1,197 @@
+package models
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+var testSchema = AttribSchema{
+	{Name: "country", Type: AttribTypeString, Enum: []string{"IN", "US"}, Required: true},
+	{Name: "age", Type: AttribTypeNumber},
+	{Name: "active", Type: AttribTypeBool},
+	{Name: "dob", Type: AttribTypeDate},
+	{Name: "tags", Type: AttribTypeList},
+	{Name: "interests", Type: AttribTypeList, Enum: []string{"tech", "music"}},
+	{Name: "address", Type: AttribTypeObject},
+}
+
+func TestAttribSchemaValidate(t *testing.T) {
+	cases := []struct {
+		name    string
+		attribs string
+		partial bool
+		out     string
+		err     bool
+	}{
+		{"valid", `{"country": "IN", "age": 30, "active": true, "dob": "2000-01-02", "tags": ["a", 1], "interests": ["tech"], "address": {"city": "x"}}`,
+			false, `{"country": "IN", "age": 30, "active": true, "dob": "2000-01-02", "tags": ["a", 1], "interests": ["tech"], "address": {"city": "x"}}`, false},
+		{"case folding", `{"Country": "US", "AGE": 1, "other": "x"}`, false, `{"country": "US", "age": 1, "other": "x"}`, false},
+		{"duplicate keys", `{"country": "US", "Country": "IN"}`, false, ``, true},
+		{"unknown attributes", `{"country": "US", "age2": "x"}`, false, `{"country": "US", "age2": "x"}`, false},
+		{"null values", `{"country": "US", "age": null, "dob": null}`, false, `{"country": "US", "age": null, "dob": null}`, false},
+		{"rfc3339 date", `{"country": "US", "dob": "2000-01-02T15:04:05Z"}`, false, `{"country": "US", "dob": "2000-01-02T15:04:05Z"}`, false},
+
+		{"string type", `{"country": 1}`, false, ``, true},
+		{"number type", `{"country": "US", "age": "30"}`, false, ``, true},
+		{"bool type", `{"country": "US", "active": "true"}`, false, ``, true},
+		{"date type", `{"country": "US", "dob": "02-01-2000"}`, false, ``, true},
+		{"list type", `{"country": "US", "tags": "a"}`, false, ``, true},
+		{"object type", `{"country": "US", "address": "x"}`, false, ``, true},
+
+		{"string enum", `{"country": "in"}`, false, ``, true},
+		{"list enum", `{"country": "US", "interests": ["tech", "art"]}`, false, ``, true},
+		{"list enum type", `{"country": "US", "interests": [1]}`, false, ``, true},
+
+		{"required", `{"age": 1}`, false, ``, true},
+		{"required null", `{"country": null}`, false, ``, true},
+		{"required empty", `{"country": ""}`, false, ``, true},
+		{"required case folded", `{"COUNTRY": "IN"}`, false, `{"country": "IN"}`, false},
+		{"partial", `{"age": 1}`, true, `{"age": 1}`, false},
+		{"partial type", `{"age": "1"}`, true, ``, true},
+	}
+
+	for _, c := range cases {
+		var attribs JSON
+		if err := json.Unmarshal([]byte(c.attribs), &attribs); err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+
+		out, err := testSchema.Validate(attribs, c.partial)
+		if c.err {
+			if err == nil {
+				t.Errorf("%s: expected error, got %v", c.name, out)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", c.name, err)
+			continue
+		}
+
+		var exp JSON
+		if err := json.Unmarshal([]byte(c.out), &exp); err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		if !reflect.DeepEqual(out, exp) {
+			t.Errorf("%s: expected %v, got %v", c.name, exp, out)
+		}
+	}
+
+	// Without a schema, attributes aren't validated.
+	a := JSON{"country": 1}
+	if out, err := (AttribSchema{}).Validate(a, false); err != nil || !reflect.DeepEqual(out, a) {
+		t.Errorf("expected attributes as-is without a schema, got %v: %v", out, err)
+	}
+}
+
+func TestAttribFieldValidate(t *testing.T) {
+	var (
+		num  = AttribField{Name: "n", Type: AttribTypeNumber}
+		list = AttribField{Name: "l", Type: AttribTypeList, Enum: []string{"a", "b"}}
+		str  = AttribField{Name: "s", Type: AttribTypeString, Enum: []string{"a", "b"}}
+	)
+
+	cases := []struct {
+		name string
+		f    AttribField
+		v    any
+		ok   bool
+	}{
+		{"int", num, 1, true},
+		{"float", num, 1.5, true},
+		{"json number", num, json.Number("1"), true},
+		{"number string", num, "1", false},
+		{"nil", num, nil, true},
+		{"string list", list, []string{"a", "b"}, true},
+		{"string list enum", list, []string{"a", "c"}, false},
+		{"empty list", list, []any{}, true},
+		{"string enum", str, "a", true},
+		{"string enum case", str, "A", false},
+		{"no enum", AttribField{Name: "s", Type: AttribTypeString}, "anything", true},
+	}
+
+	for _, c := range cases {
+		if err := c.f.validate(c.v); (err == nil) != c.ok {
+			t.Errorf("%s: expected ok=%v, got %v", c.name, c.ok, err)
+		}
+	}
+}
+
+func TestAttribFieldParseValue(t *testing.T) {
+	cases := []struct {
+		name string
+		typ  string
+		vals []string
+		out  any
+		err  bool
+	}{
+		{"string", AttribTypeString, []string{" IN "}, "IN", false},
+		{"date", AttribTypeDate, []string{"2000-01-02"}, "2000-01-02", false},
+		{"number", AttribTypeNumber, []string{"1.5"}, 1.5, false},
+		{"invalid number", AttribTypeNumber, []string{"x"}, nil, true},
+		{"bool", AttribTypeBool, []string{"true"}, true, false},
+		{"invalid bool", AttribTypeBool, []string{"yes"}, nil, true},
+		{"object", AttribTypeObject, []string{`{"a": 1}`}, map[string]any{"a": float64(1)}, false},
+		{"invalid object", AttribTypeObject, []string{`[1]`}, nil, true},
+		{"list", AttribTypeList, []string{"a", " ", " b "}, []any{"a", "b"}, false},
+		{"empty list", AttribTypeList, nil, []any{}, false},
+		{"empty", AttribTypeNumber, []string{" "}, nil, false},
+		{"no values", AttribTypeBool, nil, nil, false},
+	}
+
+	for _, c := range cases {
+		f := AttribField{Name: "a", Type: c.typ}
+		out, err := f.ParseValue(c.vals)
+		if (err != nil) != c.err {
+			t.Errorf("%s: unexpected error: %v", c.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(out, c.out) {
+			t.Errorf("%s: expected %#v, got %#v", c.name, c.out, out)
+		}
+	}
+}
+
+func TestDuplicateRulesKey(t *testing.T) {
+	all := DuplicateRules{
+		IgnoreCase:     true,
+		PlusAddressing: true,
+		GmailDots:      true,
+		DomainAliases:  map[string]string{"example.net": "example.com"},
+	}
+
+	cases := []struct {
+		name  string
+		rules DuplicateRules
+		email string
+		key   string
+	}{
+		{"case", all, "John@Example.com", "john@example.com"},
+		{"+tag", all, "john+news@example.com", "john@example.com"},
+		{"multiple +tags", all, "john+a+b@example.com", "john@example.com"},
+		{"gmail dots", all, "John.Doe+news@gmail.com", "johndoe@gmail.com"},
+		{"googlemail", all, "john.doe@GoogleMail.com", "johndoe@gmail.com"},
+		{"dots elsewhere", all, "john.doe@example.com", "john.doe@example.com"},
+		{"domain alias", all, "john@Example.NET", "john@example.com"},
+		{"alias and +tag", all, "john+x@example.net", "john@example.com"},
+
+		{"no rules", DuplicateRules{}, "John.Doe+x@GMail.com", "John.Doe+x@GMail.com"},
+		{"case sensitive gmail", DuplicateRules{GmailDots: true}, "John.Doe@GMail.com", "JohnDoe@gmail.com"},
+		{"case sensitive alias", DuplicateRules{DomainAliases: map[string]string{"example.net": "example.com"}}, "John@Example.net", "John@example.com"},
+		{"no +tags", DuplicateRules{IgnoreCase: true}, "john+x@example.com", "john+x@example.com"},
+		{"no gmail dots", DuplicateRules{IgnoreCase: true}, "j.d@googlemail.com", "j.d@googlemail.com"},
+		{"@ in local part", all, `"j@d"@example.com`, `"j@d"@example.com`},
+	}
+
+	for _, c := range cases {
+		if k := c.rules.Key(c.email); k != c.key {
+			t.Errorf("%s: expected %s, got %s", c.name, c.key, k)
+		}
+	}
+
+	// Duplicates have the same key.
+	if all.Key("John.Doe+a@gmail.com") != all.Key("johndoe@googlemail.com") {
+		t.Error("expected duplicates to have the same key")
+	}
+}
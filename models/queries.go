@@ -17,6 +17,9 @@ type Queries struct {
 	InsertSubscriber                *sqlx.Stmt `query:"insert-subscriber"`
 	UpsertSubscriber                *sqlx.Stmt `query:"upsert-subscriber"`
 	UpsertBlocklistSubscriber       *sqlx.Stmt `query:"upsert-blocklist-subscriber"`
+	ImportUnsubscribeSubscriber     *sqlx.Stmt `query:"import-unsubscribe-subscriber"`
+	ImportDeleteSubscriber          *sqlx.Stmt `query:"import-delete-subscriber"`
+	ImportUpdateSubscriber          *sqlx.Stmt `query:"import-update-subscriber"`
 	GetSubscriber                   *sqlx.Stmt `query:"get-subscriber"`
 	HasSubscriberLists              *sqlx.Stmt `query:"has-subscriber-list"`
 	GetSubscribersByEmails          *sqlx.Stmt `query:"get-subscribers-by-emails"`
@@ -35,6 +38,8 @@ type Queries struct {
 	DeleteBlocklistedSubscribers    *sqlx.Stmt `query:"delete-blocklisted-subscribers"`
 	DeleteOrphanSubscribers         *sqlx.Stmt `query:"delete-orphan-subscribers"`
 	UnsubscribeByCampaign           *sqlx.Stmt `query:"unsubscribe-by-campaign"`
+	UnsubscribeUnconfirmed          *sqlx.Stmt `query:"unsubscribe-unconfirmed"`
+	UnsubscribeByEmail              *sqlx.Stmt `query:"unsubscribe-by-email"`
 	ExportSubscriberData            *sqlx.Stmt `query:"export-subscriber-data"`
 
 	// Non-prepared arbitrary subscriber queries.
@@ -83,11 +88,25 @@ type Queries struct {
 	GetOneCampaignSubscriber *sqlx.Stmt `query:"get-one-campaign-subscriber"`
 	UpdateCampaign           *sqlx.Stmt `query:"update-campaign"`
 	UpdateCampaignStatus     *sqlx.Stmt `query:"update-campaign-status"`
+	PauseCampaignUntil       *sqlx.Stmt `query:"pause-campaign-until"`
+	ResumeCampaigns          *sqlx.Stmt `query:"resume-campaigns"`
 	UpdateCampaignCounts     *sqlx.Stmt `query:"update-campaign-counts"`
 	UpdateCampaignArchive    *sqlx.Stmt `query:"update-campaign-archive"`
+	InsertCampaignRevision   *sqlx.Stmt `query:"insert-campaign-revision"`
+	FreezeCampaignRevision   *sqlx.Stmt `query:"freeze-campaign-revision"`
+	GetCampaignRevisions     *sqlx.Stmt `query:"get-campaign-revisions"`
+	GetCampaignRevision      *sqlx.Stmt `query:"get-campaign-revision"`
+	RestoreCampaignRevision  *sqlx.Stmt `query:"restore-campaign-revision"`
 	RegisterCampaignView     *sqlx.Stmt `query:"register-campaign-view"`
 	DeleteCampaign           *sqlx.Stmt `query:"delete-campaign"`
 
+	GetCampaignFeed           *sqlx.Stmt `query:"get-campaign-feed"`
+	GetCampaignFeeds          *sqlx.Stmt `query:"get-campaign-feeds"`
+	UpsertCampaignFeed        *sqlx.Stmt `query:"upsert-campaign-feed"`
+	DeleteCampaignFeed        *sqlx.Stmt `query:"delete-campaign-feed"`
+	UpdateCampaignFeedChecked *sqlx.Stmt `query:"update-campaign-feed-checked"`
+	CreateFeedCampaign        *sqlx.Stmt `query:"create-feed-campaign"`
+
 	InsertMedia *sqlx.Stmt `query:"insert-media"`
 	GetMedia    *sqlx.Stmt `query:"get-media"`
 	QueryMedia  *sqlx.Stmt `query:"query-media"`
@@ -106,12 +125,21 @@ type Queries struct {
 	UpdateSettings *sqlx.Stmt `query:"update-settings"`
 
 	// GetStats *sqlx.Stmt `query:"get-stats"`
-	RecordBounce                *sqlx.Stmt `query:"record-bounce"`
-	QueryBounces                string     `query:"query-bounces"`
-	BlocklistBouncedSubscribers *sqlx.Stmt `query:"blocklist-bounced-subscribers"`
-	DeleteBounces               *sqlx.Stmt `query:"delete-bounces"`
-	DeleteBouncesBySubscriber   *sqlx.Stmt `query:"delete-bounces-by-subscriber"`
-	GetDBInfo                   string     `query:"get-db-info"`
+	RecordBounce                 *sqlx.Stmt `query:"record-bounce"`
+	GetSoftBounceStreak          *sqlx.Stmt `query:"get-soft-bounce-streak"`
+	GetSuppressedSubscribers     *sqlx.Stmt `query:"get-suppressed-subscribers"`
+	GetBounceAnalyticsDomains    *sqlx.Stmt `query:"get-bounce-analytics-domains"`
+	GetBounceAnalyticsCampaigns  *sqlx.Stmt `query:"get-bounce-analytics-campaigns"`
+	GetBounceAnalyticsMessengers *sqlx.Stmt `query:"get-bounce-analytics-messengers"`
+	DecayBounces                 *sqlx.Stmt `query:"decay-bounces"`
+	RecordDelivery               *sqlx.Stmt `query:"record-delivery"`
+	RecordWebhookView            *sqlx.Stmt `query:"record-webhook-view"`
+	RecordWebhookClick           *sqlx.Stmt `query:"record-webhook-click"`
+	QueryBounces                 string     `query:"query-bounces"`
+	BlocklistBouncedSubscribers  *sqlx.Stmt `query:"blocklist-bounced-subscribers"`
+	DeleteBounces                *sqlx.Stmt `query:"delete-bounces"`
+	DeleteBouncesBySubscriber    *sqlx.Stmt `query:"delete-bounces-by-subscriber"`
+	GetDBInfo                    string     `query:"get-db-info"`
 
 	CreateUser        *sqlx.Stmt `query:"create-user"`
 	UpdateUser        *sqlx.Stmt `query:"update-user"`
@@ -130,6 +158,82 @@ type Queries struct {
 	DeleteRole            *sqlx.Stmt `query:"delete-role"`
 	UpsertListPermissions *sqlx.Stmt `query:"upsert-list-permissions"`
 	DeleteListPermission  *sqlx.Stmt `query:"delete-list-permission"`
+
+	GetSequences                        *sqlx.Stmt `query:"get-sequences"`
+	GetSequenceSteps                    *sqlx.Stmt `query:"get-sequence-steps"`
+	CreateSequence                      *sqlx.Stmt `query:"create-sequence"`
+	UpdateSequence                      *sqlx.Stmt `query:"update-sequence"`
+	DeleteSequence                      *sqlx.Stmt `query:"delete-sequence"`
+	UpsertSequenceStep                  *sqlx.Stmt `query:"upsert-sequence-step"`
+	DeleteSequenceStepsAfter            *sqlx.Stmt `query:"delete-sequence-steps-after"`
+	AddSequenceSubscribers              *sqlx.Stmt `query:"add-sequence-subscribers"`
+	ExitSequenceSubscribers             *sqlx.Stmt `query:"exit-sequence-subscribers"`
+	EnrollSequenceSubscribers           *sqlx.Stmt `query:"enroll-sequence-subscribers"`
+	ExitUnsubscribedSequenceSubscribers *sqlx.Stmt `query:"exit-unsubscribed-sequence-subscribers"`
+	NextSequenceMessages                *sqlx.Stmt `query:"next-sequence-messages"`
+	AdvanceSequenceSubscriber           *sqlx.Stmt `query:"advance-sequence-subscriber"`
+	FinishSequenceSubscribers           *sqlx.Stmt `query:"finish-sequence-subscribers"`
+
+	InsertSubscriberEvent *sqlx.Stmt `query:"insert-subscriber-event"`
+	GetSubscriberEvents   *sqlx.Stmt `query:"get-subscriber-events"`
+	GetAutomations        *sqlx.Stmt `query:"get-automations"`
+	CreateAutomation      *sqlx.Stmt `query:"create-automation"`
+	UpdateAutomation      *sqlx.Stmt `query:"update-automation"`
+	DeleteAutomation      *sqlx.Stmt `query:"delete-automation"`
+	NextAutomationJobs    *sqlx.Stmt `query:"next-automation-jobs"`
+	DeleteAutomationJob   *sqlx.Stmt `query:"delete-automation-job"`
+
+	RecordSubscriberActivity        *sqlx.Stmt `query:"record-subscriber-activity"`
+	RecordSubscriberActivityByQuery string     `query:"record-subscriber-activity-by-query"`
+	GetSubscriberActivity           *sqlx.Stmt `query:"get-subscriber-activity"`
+	QueryUnsubscriptions            *sqlx.Stmt `query:"query-unsubscriptions"`
+	RecordReply                     *sqlx.Stmt `query:"record-reply"`
+
+	GetSubscriberEmails     *sqlx.Stmt `query:"get-subscriber-emails"`
+	GetDuplicateSubscribers *sqlx.Stmt `query:"get-duplicate-subscribers"`
+	MergeSubscribers        *sqlx.Stmt `query:"merge-subscribers"`
+	InsertSubscriberMerge   *sqlx.Stmt `query:"insert-subscriber-merge"`
+	QuerySubscriberMerges   *sqlx.Stmt `query:"query-subscriber-merges"`
+
+	IncrSMTPWarmupCount *sqlx.Stmt `query:"incr-smtp-warmup-count"`
+	DecrSMTPWarmupCount *sqlx.Stmt `query:"decr-smtp-warmup-count"`
+
+	CreateImportJob           *sqlx.Stmt `query:"create-import-job"`
+	QueryImportJobs           *sqlx.Stmt `query:"query-import-jobs"`
+	GetImportJob              *sqlx.Stmt `query:"get-import-job"`
+	GetImportJobErrors        *sqlx.Stmt `query:"get-import-job-errors"`
+	NextImportJob             *sqlx.Stmt `query:"next-import-job"`
+	UpdateImportJob           *sqlx.Stmt `query:"update-import-job"`
+	UpdateImportJobErrors     *sqlx.Stmt `query:"update-import-job-errors"`
+	CancelImportJob           *sqlx.Stmt `query:"cancel-import-job"`
+	ClearImportJobs           *sqlx.Stmt `query:"clear-import-jobs"`
+	DeleteImportJob           *sqlx.Stmt `query:"delete-import-job"`
+	FailInterruptedImportJobs *sqlx.Stmt `query:"fail-interrupted-import-jobs"`
+	GetImportPresets          *sqlx.Stmt `query:"get-import-presets"`
+	CreateImportPreset        *sqlx.Stmt `query:"create-import-preset"`
+	UpdateImportPreset        *sqlx.Stmt `query:"update-import-preset"`
+	DeleteImportPreset        *sqlx.Stmt `query:"delete-import-preset"`
+	GetAttribSchema           *sqlx.Stmt `query:"get-attrib-schema"`
+	CreateAttribField         *sqlx.Stmt `query:"create-attrib-field"`
+	UpdateAttribField         *sqlx.Stmt `query:"update-attrib-field"`
+	DeleteAttribField         *sqlx.Stmt `query:"delete-attrib-field"`
+	GetSyncSources            *sqlx.Stmt `query:"get-sync-sources"`
+	CreateSyncSource          *sqlx.Stmt `query:"create-sync-source"`
+	UpdateSyncSource          *sqlx.Stmt `query:"update-sync-source"`
+	DeleteSyncSource          *sqlx.Stmt `query:"delete-sync-source"`
+	GetListSubscriberEmails   *sqlx.Stmt `query:"get-list-subscriber-emails"`
+	CreateSyncRun             *sqlx.Stmt `query:"create-sync-run"`
+	UpdateSyncRun             *sqlx.Stmt `query:"update-sync-run"`
+	QuerySyncRuns             *sqlx.Stmt `query:"query-sync-runs"`
+	FailInterruptedSyncRuns   *sqlx.Stmt `query:"fail-interrupted-sync-runs"`
+
+	TrashSubscribers        *sqlx.Stmt `query:"trash-subscribers"`
+	TrashSubscribersByQuery string     `query:"trash-subscribers-by-query"`
+	TrashLists              *sqlx.Stmt `query:"trash-lists"`
+	TrashCampaign           *sqlx.Stmt `query:"trash-campaign"`
+	QueryTrash              *sqlx.Stmt `query:"query-trash"`
+	RestoreTrash            *sqlx.Stmt `query:"restore-trash"`
+	PurgeTrash              *sqlx.Stmt `query:"purge-trash"`
 }
 
 // compileSubscriberQueryTpl takes an arbitrary WHERE expressions
@@ -19,6 +19,8 @@ type Settings struct {
 
 	AppBatchSize             int    `json:"app.batch_size"`
 	AppConcurrency           int    `json:"app.concurrency"`
+	AppImportConcurrency     int    `json:"app.import_concurrency"`
+	AppTrashRetentionDays    int    `json:"app.trash_retention_days"`
 	AppMaxSendErrors         int    `json:"app.max_send_errors"`
 	AppMessageRate           int    `json:"app.message_rate"`
 	CacheSlowQueries         bool   `json:"app.cache_slow_queries"`
@@ -30,6 +32,7 @@ type Settings struct {
 
 	PrivacyIndividualTracking bool     `json:"privacy.individual_tracking"`
 	PrivacyUnsubHeader        bool     `json:"privacy.unsubscribe_header"`
+	PrivacyUnsubMailto        string   `json:"privacy.unsubscribe_mailto"`
 	PrivacyAllowBlocklist     bool     `json:"privacy.allow_blocklist"`
 	PrivacyAllowPreferences   bool     `json:"privacy.allow_preferences"`
 	PrivacyAllowExport        bool     `json:"privacy.allow_export"`
@@ -96,6 +99,24 @@ type Settings struct {
 		WaitTimeout   string              `json:"wait_timeout"`
 		TLSType       string              `json:"tls_type"`
 		TLSSkipVerify bool                `json:"tls_skip_verify"`
+
+		// Warm-up plan that caps the server's daily sends. Schedule[n] is the
+		// cap on the nth day since StartDate (YYYY-MM-DD).
+		Warmup struct {
+			Enabled   bool   `json:"enabled"`
+			StartDate string `json:"start_date"`
+			Schedule  []int  `json:"schedule"`
+		} `json:"warmup"`
+
+		// DKIM keys that messages are signed with, by their From domain.
+		DKIM struct {
+			Enabled bool `json:"enabled"`
+			Keys    []struct {
+				Domain     string `json:"domain"`
+				Selector   string `json:"selector"`
+				PrivateKey string `json:"private_key,omitempty"`
+			} `json:"keys"`
+		} `json:"dkim"`
 	} `json:"smtp"`
 
 	Messengers []struct {
@@ -113,9 +134,22 @@ type Settings struct {
 	BounceEnabled        bool `json:"bounce.enabled"`
 	BounceEnableWebhooks bool `json:"bounce.webhooks_enabled"`
 	BounceActions        map[string]struct {
-		Count  int    `json:"count"`
-		Action string `json:"action"`
+		Count      int    `json:"count"`
+		Action     string `json:"action"`
+		WindowDays int    `json:"window_days"`
 	} `json:"bounce.actions"`
+	BounceSoftEscalation int `json:"bounce.soft_escalation"`
+	BounceDecayDays      int `json:"bounce.decay_days"`
+	BounceWebhookEvents  struct {
+		Delivered bool `json:"delivered"`
+		Opens     bool `json:"opens"`
+		Clicks    bool `json:"clicks"`
+	} `json:"bounce.webhook_events"`
+	BounceWebhookSecurity struct {
+		HMACSecret string            `json:"hmac_secret"`
+		MaxAge     int               `json:"max_age"`
+		Allowlist  map[string]string `json:"allowlist"`
+	} `json:"bounce.webhook_security"`
 	SESEnabled      bool   `json:"bounce.ses_enabled"`
 	SendgridEnabled bool   `json:"bounce.sendgrid_enabled"`
 	SendgridKey     string `json:"bounce.sendgrid_key"`
@@ -128,6 +162,29 @@ type Settings struct {
 		Enabled bool   `json:"enabled"`
 		Key     string `json:"key"`
 	} `json:"bounce.forwardemail"`
+	BounceMailgun struct {
+		Enabled bool   `json:"enabled"`
+		Key     string `json:"key"`
+	} `json:"bounce.mailgun"`
+	BounceSparkPost struct {
+		Enabled  bool   `json:"enabled"`
+		Username string `json:"username"`
+		Password string `json:"password"`
+	} `json:"bounce.sparkpost"`
+	BounceBrevo struct {
+		Enabled  bool   `json:"enabled"`
+		Username string `json:"username"`
+		Password string `json:"password"`
+	} `json:"bounce.brevo"`
+	BounceMailjet struct {
+		Enabled  bool   `json:"enabled"`
+		Username string `json:"username"`
+		Password string `json:"password"`
+	} `json:"bounce.mailjet"`
+	BounceResend struct {
+		Enabled bool   `json:"enabled"`
+		Key     string `json:"key"`
+	} `json:"bounce.resend"`
 	BounceBoxes []struct {
 		UUID          string `json:"uuid"`
 		Enabled       bool   `json:"enabled"`
@@ -139,10 +196,40 @@ type Settings struct {
 		Username      string `json:"username"`
 		Password      string `json:"password,omitempty"`
 		TLSEnabled    bool   `json:"tls_enabled"`
+		StartTLS      bool   `json:"start_tls"`
 		TLSSkipVerify bool   `json:"tls_skip_verify"`
 		ScanInterval  string `json:"scan_interval"`
+
+		// IMAP.
+		Folder          string `json:"folder"`
+		Mode            string `json:"mode"`
+		ProcessedAction string `json:"processed_action"`
+		ProcessedFolder string `json:"processed_folder"`
 	} `json:"bounce.mailboxes"`
 
+	InboundEnabled bool `json:"inbound.enabled"`
+	InboundMailbox struct {
+		Type          string `json:"type"`
+		Host          string `json:"host"`
+		Port          int    `json:"port"`
+		AuthProtocol  string `json:"auth_protocol"`
+		Username      string `json:"username"`
+		Password      string `json:"password,omitempty"`
+		TLSEnabled    bool   `json:"tls_enabled"`
+		StartTLS      bool   `json:"start_tls"`
+		TLSSkipVerify bool   `json:"tls_skip_verify"`
+		ScanInterval  string `json:"scan_interval"`
+
+		// IMAP.
+		Folder          string `json:"folder"`
+		Mode            string `json:"mode"`
+		ProcessedAction string `json:"processed_action"`
+		ProcessedFolder string `json:"processed_folder"`
+	} `json:"inbound.mailbox"`
+	InboundUnsubscribe    bool   `json:"inbound.unsubscribe"`
+	InboundForwardEmail   string `json:"inbound.forward_email"`
+	InboundForwardWebhook string `json:"inbound.forward_webhook"`
+
 	AdminCustomCSS  string `json:"appearance.admin.custom_css"`
 	AdminCustomJS   string `json:"appearance.admin.custom_js"`
 	PublicCustomCSS string `json:"appearance.public.custom_css"`
@@ -1,12 +1,13 @@
 -- subscribers
 -- name: get-subscriber
--- Get a single subscriber by id or UUID or email.
+-- Get a single subscriber by id or UUID or email. Trashed subscribers are excluded.
 SELECT * FROM subscribers WHERE
     CASE
         WHEN $1 > 0 THEN id = $1
         WHEN $2 != '' THEN uuid = $2::UUID
         WHEN $3 != '' THEN email = $3
-    END;
+    END
+    AND deleted_at IS NULL;
 
 -- name: has-subscriber-list
 -- Used for checking access permission by list.
@@ -20,7 +21,7 @@ FROM subscribers s WHERE s.id = ANY($1);
 
 -- name: get-subscribers-by-emails
 -- Get subscribers by emails.
-SELECT * FROM subscribers WHERE email=ANY($1);
+SELECT * FROM subscribers WHERE email=ANY($1) AND deleted_at IS NULL;
 
 -- name: get-subscriber-lists
 WITH sub AS (
@@ -28,7 +29,7 @@ WITH sub AS (
 )
 SELECT * FROM lists
     LEFT JOIN subscriber_lists ON (lists.id = subscriber_lists.list_id)
-    WHERE subscriber_id = (SELECT id FROM sub)
+    WHERE subscriber_id = (SELECT id FROM sub) AND lists.deleted_at IS NULL
     -- Optional list IDs or UUIDs to filter.
     AND (CASE WHEN CARDINALITY($3::INT[]) > 0 THEN id = ANY($3::INT[])
           WHEN CARDINALITY($4::UUID[]) > 0 THEN uuid = ANY($4::UUID[])
@@ -58,7 +59,7 @@ WITH subs AS (
         )
     ) AS lists FROM lists
     LEFT JOIN subscriber_lists ON (subscriber_lists.list_id = lists.id)
-    WHERE subscriber_lists.subscriber_id = ANY($1)
+    WHERE subscriber_lists.subscriber_id = ANY($1) AND lists.deleted_at IS NULL
     GROUP BY subscriber_id
 )
 SELECT id as subscriber_id,
@@ -81,26 +82,31 @@ SELECT lists.*,
     FROM lists LEFT JOIN subscriber_lists
     ON (subscriber_lists.list_id = lists.id AND subscriber_lists.subscriber_id = (SELECT id FROM sub))
     WHERE CASE WHEN $3 = TRUE THEN TRUE ELSE subscriber_lists.status IS NOT NULL END
+    AND lists.deleted_at IS NULL
     ORDER BY subscriber_lists.status;
 
 -- name: insert-subscriber
+-- A trashed subscriber with the same e-mail is restored and updated. No rows are
+-- returned if a subscriber with the e-mail already exists.
 WITH sub AS (
-    INSERT INTO subscribers (uuid, email, name, status, attribs)
+    INSERT INTO subscribers AS s (uuid, email, name, status, attribs)
     VALUES($1, $2, $3, $4, $5)
+    ON CONFLICT (email) DO UPDATE
+        SET name=$3, status=$4, attribs=$5, deleted_at=NULL, updated_at=NOW()
+        WHERE s.deleted_at IS NOT NULL
     RETURNING id, status
 ),
 listIDs AS (
     SELECT id FROM lists WHERE
         (CASE WHEN CARDINALITY($6::INT[]) > 0 THEN id=ANY($6)
               ELSE uuid=ANY($7::UUID[]) END)
+        AND deleted_at IS NULL
 ),
 subs AS (
     INSERT INTO subscriber_lists (subscriber_id, list_id, status)
-    VALUES(
-        (SELECT id FROM sub),
-        UNNEST(ARRAY(SELECT id FROM listIDs)),
+    SELECT sub.id, listIDs.id,
         (CASE WHEN $4='blocklisted' THEN 'unsubscribed'::subscription_status ELSE $8::subscription_status END)
-    )
+    FROM sub, listIDs
     ON CONFLICT (subscriber_id, list_id) DO UPDATE
         SET updated_at=NOW(),
             status=(
@@ -113,14 +119,15 @@ SELECT id from sub;
 
 -- name: upsert-subscriber
 -- Upserts a subscriber where existing subscribers get their names and attributes overwritten.
--- If $7 = true, update values, otherwise, skip.
+-- If $7 = true, update values, otherwise, skip. Trashed subscribers are restored.
 WITH sub AS (
     INSERT INTO subscribers as s (uuid, email, name, attribs, status)
     VALUES($1, $2, $3, $4, 'enabled')
     ON CONFLICT (email)
     DO UPDATE SET
-        name=(CASE WHEN $7 THEN $3 ELSE s.name END),
-        attribs=(CASE WHEN $7 THEN $4 ELSE s.attribs END),
+        name=(CASE WHEN $7 OR s.deleted_at IS NOT NULL THEN $3 ELSE s.name END),
+        attribs=(CASE WHEN $7 OR s.deleted_at IS NOT NULL THEN $4 ELSE s.attribs END),
+        deleted_at=NULL,
         updated_at=NOW()
     RETURNING uuid, id, status
 ),
@@ -137,17 +144,43 @@ SELECT uuid, id from sub;
 -- name: upsert-blocklist-subscriber
 -- Upserts a subscriber where the update will only set the status to blocklisted
 -- unlike upsert-subscribers where name and attributes are updated. In addition, all
--- existing subscriptions are marked as 'unsubscribed'.
+-- existing subscriptions are marked as 'unsubscribed'. Trashed subscribers are restored.
 -- This is used in the bulk importer.
 WITH sub AS (
     INSERT INTO subscribers (uuid, email, name, attribs, status)
     VALUES($1, $2, $3, $4, 'blocklisted')
-    ON CONFLICT (email) DO UPDATE SET status='blocklisted', updated_at=NOW()
+    ON CONFLICT (email) DO UPDATE SET status='blocklisted', deleted_at=NULL, updated_at=NOW()
     RETURNING id
 )
 UPDATE subscriber_lists SET status='unsubscribed', updated_at=NOW()
     WHERE subscriber_id = (SELECT id FROM sub);
 
+-- name: import-unsubscribe-subscriber
+-- Unsubscribes an existing subscriber ($1 = e-mail) from the given lists.
+-- This is used in the bulk importer. No rows are returned if the subscriber doesn't exist.
+WITH sub AS (
+    SELECT id FROM subscribers WHERE LOWER(email) = $1 AND deleted_at IS NULL
+),
+u AS (
+    UPDATE subscriber_lists SET status='unsubscribed', updated_at=NOW()
+    WHERE subscriber_id = (SELECT id FROM sub) AND list_id = ANY($2::INT[])
+)
+SELECT id FROM sub;
+
+-- name: import-delete-subscriber
+-- Deletes an existing subscriber ($1 = e-mail). This is used in the bulk importer.
+DELETE FROM subscribers WHERE LOWER(email) = $1 RETURNING id;
+
+-- name: import-update-subscriber
+-- Updates the name and attributes of an existing subscriber ($1 = e-mail) without touching
+-- the status or subscriptions. If $4 = true, attributes are replaced, otherwise, merged.
+-- This is used in the bulk importer.
+UPDATE subscribers SET
+    name=(CASE WHEN $2 != '' THEN $2 ELSE name END),
+    attribs=(CASE WHEN $3::JSONB IS NULL THEN attribs WHEN $4 THEN $3::JSONB ELSE attribs || $3::JSONB END),
+    updated_at=NOW()
+WHERE LOWER(email) = $1 AND deleted_at IS NULL RETURNING id;
+
 -- name: update-subscriber
 UPDATE subscribers SET
     email=(CASE WHEN $2 != '' THEN $2 ELSE email END),
@@ -173,6 +206,7 @@ listIDs AS (
     SELECT id FROM lists WHERE
         (CASE WHEN CARDINALITY($6::INT[]) > 0 THEN id=ANY($6)
               ELSE uuid=ANY($7::UUID[]) END)
+        AND deleted_at IS NULL
 ),
 d AS (
     DELETE FROM subscriber_lists WHERE $9 = TRUE AND subscriber_id = $1 AND list_id != ALL(SELECT id FROM listIDs)
@@ -247,6 +281,7 @@ UPDATE subscriber_lists SET status='unsubscribed', updated_at=NOW()
 -- Unsubscribes a subscriber given a campaign UUID (from all the lists in the campaign) and the subscriber UUID.
 -- If $3 is TRUE, then all subscriptions of the subscriber is blocklisted
 -- and all existing subscriptions, irrespective of lists, unsubscribed.
+-- Returns the IDs of the lists that were unsubscribed from.
 WITH lists AS (
     SELECT list_id FROM campaign_lists
     LEFT JOIN campaigns ON (campaign_lists.campaign_id = campaigns.id)
@@ -259,7 +294,35 @@ sub AS (
 UPDATE subscriber_lists SET status = 'unsubscribed', updated_at=NOW() WHERE
     subscriber_id = (SELECT id FROM sub) AND status != 'unsubscribed' AND
     -- If $3 is false, unsubscribe from the campaign's lists, otherwise all lists.
-    CASE WHEN $3 IS FALSE THEN list_id = ANY(SELECT list_id FROM lists) ELSE list_id != 0 END;
+    CASE WHEN $3 IS FALSE THEN list_id = ANY(SELECT list_id FROM lists) ELSE list_id != 0 END
+    RETURNING list_id;
+
+-- name: unsubscribe-unconfirmed
+-- Unsubscribes a subscriber from the lists whose (double opt-in) subscriptions are
+-- still unconfirmed, eg: on a one-click unsubscribe from the opt-in e-mail.
+-- Returns the IDs of the lists that were unsubscribed from.
+UPDATE subscriber_lists SET status = 'unsubscribed', updated_at=NOW() WHERE
+    subscriber_id = (SELECT id FROM subscribers WHERE uuid = $1) AND status = 'unconfirmed'
+    RETURNING list_id;
+
+-- name: unsubscribe-by-email
+-- Unsubscribes a subscriber by e-mail (unsubscribe replies) from all the lists in a campaign ($3),
+-- or from all lists if there's no campaign. The opt-in e-mail's mailto unsubscribe has the nil UUID
+-- for the campaign and unsubscribes from the unconfirmed lists. If the subscriber UUID ($2) is given,
+-- it should belong to the e-mail.
+WITH sub AS (
+    SELECT id FROM subscribers WHERE LOWER(email) = LOWER($1) AND ($2 = '' OR uuid::TEXT = $2)
+),
+lists AS (
+    SELECT list_id FROM campaign_lists
+    JOIN campaigns ON (campaigns.id = campaign_lists.campaign_id)
+    WHERE campaigns.uuid::TEXT = $3
+)
+UPDATE subscriber_lists SET status = 'unsubscribed', updated_at=NOW() WHERE
+    subscriber_id = (SELECT id FROM sub) AND status != 'unsubscribed' AND
+    ($3 = '' OR list_id = ANY(SELECT list_id FROM lists) OR
+        ($3 = '00000000-0000-0000-0000-000000000000' AND status = 'unconfirmed'))
+    RETURNING subscriber_id, list_id;
 
 -- name: delete-unconfirmed-subscriptions
 WITH optins AS (
@@ -319,6 +382,7 @@ SELECT subscribers.* FROM subscribers
         AND ($2 = '' OR subscriber_lists.status = $2::subscription_status)
     )
     WHERE (CARDINALITY($1) = 0 OR subscriber_lists.list_id = ANY($1::INT[]))
+    AND subscribers.deleted_at IS NULL
     AND (CASE WHEN $3 != '' THEN name ~* $3 OR email ~* $3 ELSE TRUE END)
     AND %query%
     ORDER BY %order% OFFSET $4 LIMIT (CASE WHEN $5 < 1 THEN NULL ELSE $5 END);
@@ -334,6 +398,7 @@ SELECT COUNT(*) AS total FROM subscribers
         AND ($2 = '' OR subscriber_lists.status = $2::subscription_status)
     )
     WHERE (CARDINALITY($1) = 0 OR subscriber_lists.list_id = ANY($1::INT[]))
+    AND subscribers.deleted_at IS NULL
     AND (CASE WHEN $3 != '' THEN name ~* $3 OR email ~* $3 ELSE TRUE END)
     AND %query%;
 
@@ -363,7 +428,7 @@ SELECT subscribers.id,
         AND subscriber_lists.subscriber_id = subscribers.id
         AND ($4 = '' OR subscriber_lists.status = $4::subscription_status)
     )
-    WHERE subscriber_lists.list_id = ALL($1::INT[]) AND id > $2
+    WHERE subscriber_lists.list_id = ALL($1::INT[]) AND id > $2 AND subscribers.deleted_at IS NULL
     AND (CASE WHEN CARDINALITY($3::INT[]) > 0 THEN id=ANY($3) ELSE true END)
     AND (CASE WHEN $5 != '' THEN name ~* $5 OR email ~* $5 ELSE TRUE END)
     AND %query%
@@ -387,6 +452,7 @@ ON (
     AND ($3 = '' OR subscriber_lists.status = $3::subscription_status)
 )
 WHERE subscriber_lists.list_id = ALL($2::INT[])
+    AND subscribers.deleted_at IS NULL
     AND (CASE WHEN $4 != '' THEN name ~* $4 OR email ~* $4 ELSE TRUE END)
     AND %query%
 LIMIT (CASE WHEN $1 THEN 1 END)
@@ -428,7 +494,7 @@ UPDATE subscriber_lists SET status='unsubscribed', updated_at=NOW()
 
 -- lists
 -- name: get-lists
-SELECT * FROM lists WHERE (CASE WHEN $1 = '' THEN 1=1 ELSE type=$1::list_type END)
+SELECT * FROM lists WHERE deleted_at IS NULL AND (CASE WHEN $1 = '' THEN 1=1 ELSE type=$1::list_type END)
     AND CASE
         -- Optional list IDs based on user permission.
         WHEN $3 = TRUE THEN TRUE ELSE id = ANY($4::INT[])
@@ -444,6 +510,7 @@ WITH ls AS (
         WHEN $3 != '' THEN to_tsvector(name) @@ to_tsquery ($3)
         ELSE TRUE
     END
+    AND deleted_at IS NULL
     AND ($4 = '' OR type = $4::list_type)
     AND ($5 = '' OR optin = $5::list_optin)
     AND (CARDINALITY($6::VARCHAR(100)[]) = 0 OR $6 <@ tags)
@@ -466,14 +533,14 @@ SELECT ls.*, COALESCE(ss.subscriber_statuses, '{}') AS subscriber_statuses, COAL
 
 -- name: get-lists-by-optin
 -- Can have a list of IDs or a list of UUIDs.
-SELECT * FROM lists WHERE (CASE WHEN $1 != '' THEN optin=$1::list_optin ELSE TRUE END) AND
+SELECT * FROM lists WHERE deleted_at IS NULL AND (CASE WHEN $1 != '' THEN optin=$1::list_optin ELSE TRUE END) AND
     (CASE WHEN $2::INT[] IS NOT NULL THEN id = ANY($2::INT[])
           WHEN $3::UUID[] IS NOT NULL THEN uuid = ANY($3::UUID[])
     END) ORDER BY name;
 
 -- name: get-list-types
 -- Retrieves the private|public type of lists by ID or uuid. Used for filtering.
-SELECT id, uuid, type FROM lists WHERE
+SELECT id, uuid, type FROM lists WHERE deleted_at IS NULL AND
     (CASE WHEN $1::INT[] IS NOT NULL THEN id = ANY($1::INT[])
           WHEN $2::UUID[] IS NOT NULL THEN uuid = ANY($2::UUID[])
     END);
@@ -530,7 +597,7 @@ counts AS (
         JOIN lists l ON sl.list_id = l.id
         JOIN subscribers s ON sl.subscriber_id = s.id
     WHERE sl.list_id = ANY($14::INT[])
-      AND s.status != 'blocklisted'
+      AND s.status != 'blocklisted' AND s.deleted_at IS NULL AND l.deleted_at IS NULL
       AND (
         (l.optin = 'double' AND sl.status = 'confirmed') OR
         (l.optin != 'double' AND sl.status != 'unsubscribed')
@@ -563,7 +630,7 @@ med AS (
 ),
 insLists AS (
     INSERT INTO campaign_lists (campaign_id, list_id, list_name)
-        SELECT (SELECT id FROM camp), id, name FROM lists WHERE id=ANY($14::INT[])
+        SELECT (SELECT id FROM camp), id, name FROM lists WHERE id=ANY($14::INT[]) AND deleted_at IS NULL
 )
 SELECT id FROM camp;
 
@@ -585,6 +652,7 @@ SELECT  c.*,
     ) AS lists
 FROM campaigns c
 WHERE ($1 = 0 OR id = $1)
+    AND c.deleted_at IS NULL
     AND (CARDINALITY($2::campaign_status[]) = 0 OR status = ANY($2))
     AND (CARDINALITY($3::VARCHAR(100)[]) = 0 OR $3 <@ tags)
     AND ($4 = '' OR TO_TSVECTOR(CONCAT(name, ' ', subject)) @@ TO_TSQUERY($4) OR CONCAT(c.name, ' ', c.subject) ILIKE $4)
@@ -608,7 +676,8 @@ SELECT campaigns.*,
             WHEN $1 > 0 THEN campaigns.id = $1
             WHEN $3 != '' THEN campaigns.archive_slug = $3
             ELSE uuid = $2
-          END;
+          END
+    AND campaigns.deleted_at IS NULL;
 
 -- name: get-archived-campaigns
 SELECT COUNT(*) OVER () AS total, campaigns.*,
@@ -619,6 +688,7 @@ SELECT COUNT(*) OVER () AS total, campaigns.*,
         ELSE templates.id = campaigns.archive_template_id END
     )
     WHERE campaigns.archive=true AND campaigns.type='regular' AND campaigns.status=ANY('{running, paused, finished}')
+    AND campaigns.deleted_at IS NULL
     ORDER by campaigns.created_at DESC OFFSET $1 LIMIT $2;
 
 -- name: get-campaign-stats
@@ -645,7 +715,15 @@ clicks AS (
     GROUP BY campaign_id
 ),
 bounces AS (
-    SELECT campaign_id, COUNT(campaign_id) as num FROM bounces
+    SELECT campaign_id,
+        COUNT(campaign_id) FILTER (WHERE type != 'complaint') as num,
+        COUNT(campaign_id) FILTER (WHERE type = 'complaint') as complaints
+    FROM bounces
+    WHERE campaign_id = ANY($1)
+    GROUP BY campaign_id
+),
+deliveries AS (
+    SELECT campaign_id, COUNT(campaign_id) as num FROM deliveries
     WHERE campaign_id = ANY($1)
     GROUP BY campaign_id
 )
@@ -653,6 +731,8 @@ SELECT id as campaign_id,
     COALESCE(v.num, 0) AS views,
     COALESCE(c.num, 0) AS clicks,
     COALESCE(b.num, 0) AS bounces,
+    COALESCE(b.complaints, 0) AS complaints,
+    COALESCE(d.num, 0) AS delivered,
     COALESCE(l.lists, '[]') AS lists,
     COALESCE(m.media, '[]') AS media
 FROM (SELECT id FROM UNNEST($1) AS id) x
@@ -661,6 +741,7 @@ LEFT JOIN media AS m ON (m.campaign_id = id)
 LEFT JOIN views AS v ON (v.campaign_id = id)
 LEFT JOIN clicks AS c ON (c.campaign_id = id)
 LEFT JOIN bounces AS b ON (b.campaign_id = id)
+LEFT JOIN deliveries AS d ON (d.campaign_id = id)
 ORDER BY ARRAY_POSITION($1, id);
 
 -- name: get-campaign-for-preview
@@ -674,10 +755,10 @@ SELECT campaigns.*, COALESCE(templates.body, '') AS template_body,
 ) AS lists
 FROM campaigns
 LEFT JOIN templates ON (templates.id = (CASE WHEN $2=0 THEN campaigns.template_id ELSE $2 END))
-WHERE campaigns.id = $1;
+WHERE campaigns.id = $1 AND campaigns.deleted_at IS NULL;
 
 -- name: get-campaign-status
-SELECT id, status, to_send, sent, started_at, updated_at FROM campaigns WHERE status=$1;
+SELECT id, status, to_send, sent, started_at, updated_at FROM campaigns WHERE status=$1 AND deleted_at IS NULL;
 
 -- name: campaign-has-lists
 -- Returns TRUE if the campaign $1 has any of the lists given in $2.
@@ -698,13 +779,14 @@ WITH camps AS (
     FROM campaigns
     LEFT JOIN templates ON (templates.id = campaigns.template_id)
     WHERE (status='running' OR (status='scheduled' AND NOW() >= campaigns.send_at))
+    AND campaigns.deleted_at IS NULL
     AND NOT(campaigns.id = ANY($1::INT[]))
 ),
 campLists AS (
     -- Get the list_ids and their optin statuses for the campaigns found in the previous step.
     SELECT lists.id AS list_id, campaign_id, optin FROM lists
     INNER JOIN campaign_lists ON (campaign_lists.list_id = lists.id)
-    WHERE campaign_lists.campaign_id = ANY(SELECT id FROM camps)
+    WHERE campaign_lists.campaign_id = ANY(SELECT id FROM camps) AND lists.deleted_at IS NULL
 ),
 campMedia AS (
     -- Get the list_ids and their optin statuses for the campaigns found in the previous step.
@@ -724,7 +806,7 @@ counts AS (
                 ELSE sl.status != 'unsubscribed'
             END
         )
-    JOIN subscribers s ON (s.id = sl.subscriber_id AND s.status != 'blocklisted')
+    JOIN subscribers s ON (s.id = sl.subscriber_id AND s.status != 'blocklisted' AND s.deleted_at IS NULL)
     GROUP BY camps.id
 ),
 updateCounts AS (
@@ -780,6 +862,68 @@ SELECT campaign_id, COUNT(*) AS "count", DATE_TRUNC((SELECT * FROM intval), crea
     WHERE campaign_id=ANY($1) AND created_at >= $2 AND created_at <= $3
     GROUP BY campaign_id, "timestamp" ORDER BY "timestamp" ASC;
 
+-- name: get-bounce-analytics-domains
+-- Bounces recorded in the date range by recipient domain. total is the number of subscribers on the domain.
+WITH agg AS (
+    SELECT LOWER(SPLIT_PART(s.email, '@', 2)) AS domain,
+        COUNT(*) AS bounces,
+        COUNT(*) FILTER (WHERE b.type = 'hard') AS hard,
+        COUNT(*) FILTER (WHERE b.type = 'soft') AS soft,
+        COUNT(*) FILTER (WHERE b.type = 'complaint') AS complaints,
+        COUNT(DISTINCT b.subscriber_id) AS subscribers
+    FROM bounces b
+    JOIN subscribers s ON (s.id = b.subscriber_id)
+    WHERE b.created_at >= $1 AND b.created_at <= $2
+    GROUP BY domain ORDER BY bounces DESC LIMIT $3
+),
+totals AS (
+    SELECT LOWER(SPLIT_PART(email, '@', 2)) AS domain, COUNT(*) AS total FROM subscribers
+    WHERE LOWER(SPLIT_PART(email, '@', 2)) IN (SELECT domain FROM agg)
+    GROUP BY domain
+)
+SELECT agg.domain AS key, agg.domain AS name, agg.bounces, agg.hard, agg.soft, agg.complaints, agg.subscribers,
+    COALESCE(t.total, 0) AS total
+    FROM agg LEFT JOIN totals t ON (t.domain = agg.domain)
+    ORDER BY agg.bounces DESC;
+
+-- name: get-bounce-analytics-campaigns
+-- Bounces of the campaigns started in the date range. total is the number of messages sent by the campaign.
+SELECT c.id::TEXT AS key, c.name,
+    COUNT(b.id) AS bounces,
+    COUNT(b.id) FILTER (WHERE b.type = 'hard') AS hard,
+    COUNT(b.id) FILTER (WHERE b.type = 'soft') AS soft,
+    COUNT(b.id) FILTER (WHERE b.type = 'complaint') AS complaints,
+    COUNT(DISTINCT b.subscriber_id) AS subscribers,
+    c.sent AS total
+    FROM campaigns c
+    JOIN bounces b ON (b.campaign_id = c.id)
+    WHERE c.started_at >= $1 AND c.started_at <= $2
+    GROUP BY c.id ORDER BY bounces DESC LIMIT $3;
+
+-- name: get-bounce-analytics-messengers
+-- Bounces of the campaigns started in the date range by the messenger they were sent with, eg: "email"
+-- for the SMTP pool. total is the number of messages sent by the campaigns.
+WITH camps AS (
+    SELECT id, messenger, sent FROM campaigns WHERE started_at >= $1 AND started_at <= $2
+),
+agg AS (
+    SELECT c.messenger,
+        COUNT(*) AS bounces,
+        COUNT(*) FILTER (WHERE b.type = 'hard') AS hard,
+        COUNT(*) FILTER (WHERE b.type = 'soft') AS soft,
+        COUNT(*) FILTER (WHERE b.type = 'complaint') AS complaints,
+        COUNT(DISTINCT b.subscriber_id) AS subscribers
+    FROM bounces b JOIN camps c ON (c.id = b.campaign_id)
+    GROUP BY c.messenger
+),
+totals AS (
+    SELECT messenger, SUM(sent) AS total FROM camps GROUP BY messenger
+)
+SELECT agg.messenger AS key, agg.messenger AS name, agg.bounces, agg.hard, agg.soft, agg.complaints, agg.subscribers,
+    COALESCE(t.total, 0) AS total
+    FROM agg LEFT JOIN totals t ON (t.messenger = agg.messenger)
+    ORDER BY agg.bounces DESC LIMIT $3;
+
 -- name: get-campaign-link-counts
 -- raw: true
 -- %s = * or DISTINCT subscriber_id (prepared based on based on individual tracking=on/off). Prepared on boot.
@@ -796,7 +940,7 @@ SELECT campaigns.id AS campaign_id, campaigns.type as campaign_type, last_subscr
     FROM campaigns
     LEFT JOIN campaign_lists ON (campaign_lists.campaign_id = campaigns.id)
     LEFT JOIN lists ON (lists.id = campaign_lists.list_id)
-    WHERE campaigns.id = $1 AND status='running';
+    WHERE campaigns.id = $1 AND status='running' AND campaigns.deleted_at IS NULL;
 
 -- name: next-campaign-subscribers
 -- Returns a batch of subscribers in a given campaign starting from the last checkpoint
@@ -813,7 +957,7 @@ SELECT campaigns.id AS campaign_id, campaigns.type as campaign_type, last_subscr
 WITH campLists AS (
     SELECT lists.id AS list_id, optin FROM lists
     LEFT JOIN campaign_lists ON campaign_lists.list_id = lists.id
-    WHERE campaign_lists.campaign_id = $1
+    WHERE campaign_lists.campaign_id = $1 AND lists.deleted_at IS NULL
 ),
 subs AS (
     SELECT s.*
@@ -828,8 +972,8 @@ subs AS (
             AND s.id > $3
              -- max_subscriber_id
             AND s.id <= $4
-             -- Subscriber should not be blacklisted.
-            AND s.status != 'blocklisted'
+             -- Subscriber should not be blacklisted or trashed.
+            AND s.status != 'blocklisted' AND s.deleted_at IS NULL
             AND (
                 -- If it's an optin campaign and the list is double-optin, only pick unconfirmed subscribers.
                 ($2 = 'optin' AND sl.status = 'unconfirmed' AND campLists.optin = 'double')
@@ -863,7 +1007,7 @@ DELETE FROM link_clicks WHERE created_at < $1;
 -- name: get-one-campaign-subscriber
 SELECT * FROM subscribers
 LEFT JOIN subscriber_lists ON (subscribers.id = subscriber_lists.subscriber_id AND subscriber_lists.status != 'unsubscribed')
-WHERE subscriber_lists.list_id=ANY(
+WHERE subscribers.deleted_at IS NULL AND subscriber_lists.list_id=ANY(
     SELECT list_id FROM campaign_lists where campaign_id=$1 AND list_id IS NOT NULL
 )
 ORDER BY RANDOM() LIMIT 1;
@@ -918,7 +1062,7 @@ INSERT INTO campaign_lists (campaign_id, list_id, list_name)
 UPDATE campaigns SET
     to_send=(CASE WHEN $2 != 0 THEN $2 ELSE to_send END),
     sent=sent+$3,
-    last_subscriber_id=(CASE WHEN $4 > 0 THEN $4 ELSE to_send END),
+    last_subscriber_id=(CASE WHEN $4 > 0 THEN $4 WHEN $4 < 0 THEN last_subscriber_id ELSE to_send END),
     updated_at=NOW()
 WHERE id=$1;
 
@@ -930,9 +1074,82 @@ UPDATE campaigns SET
             ELSE $2::campaign_status
         END
     ),
+    resume_at=NULL,
+    updated_at=NOW()
+WHERE id = $1;
+
+-- name: pause-campaign-until
+-- Pauses a running campaign (eg: on hitting a daily sending limit) that's
+-- automatically resumed at the given time by resume-campaigns. The checkpoint is
+-- rewound to $3 so that the subscribers who weren't sent to are picked up then.
+UPDATE campaigns SET
+    last_subscriber_id=$3,
+    status=(CASE WHEN status IN ('running', 'scheduled') THEN 'paused' ELSE status END),
+    resume_at=(CASE WHEN status IN ('running', 'scheduled') THEN $2 ELSE resume_at END),
     updated_at=NOW()
 WHERE id = $1;
 
+-- name: resume-campaigns
+-- Resumes campaigns paused by pause-campaign-until whose resume time is up.
+UPDATE campaigns SET status='running', resume_at=NULL, updated_at=NOW()
+    WHERE status='paused' AND resume_at IS NOT NULL AND resume_at <= NOW();
+
+-- name: insert-campaign-revision
+-- Records a snapshot of a campaign's content fields as a new revision along with
+-- the fields that changed from the previous revision. Nothing is recorded if
+-- the content is unchanged.
+WITH snap AS (
+    SELECT JSONB_BUILD_OBJECT(
+        'name', name, 'subject', subject, 'from_email', from_email, 'body', body,
+        'body_source', body_source, 'altbody', altbody, 'content_type', content_type,
+        'template_id', template_id, 'messenger', messenger, 'headers', headers, 'tags', tags
+    ) AS data FROM campaigns WHERE id = $1
+),
+last AS (
+    SELECT version, data FROM campaign_revisions WHERE campaign_id = $1 ORDER BY version DESC LIMIT 1
+)
+INSERT INTO campaign_revisions (campaign_id, version, user_id, user_name, data, changed)
+    SELECT $1, COALESCE((SELECT version FROM last), 0) + 1, NULLIF($2, 0), $3, snap.data,
+        ARRAY(
+            SELECT s.key FROM JSONB_EACH(snap.data) s
+            WHERE s.value IS DISTINCT FROM (SELECT data->s.key FROM last)
+        )
+    FROM snap WHERE NOT EXISTS (SELECT 1 FROM last WHERE last.data = snap.data)
+    RETURNING id;
+
+-- name: freeze-campaign-revision
+-- Marks the latest revision of a campaign as the one that was sent.
+UPDATE campaign_revisions SET sent_at = NOW()
+    WHERE sent_at IS NULL AND id = (
+        SELECT id FROM campaign_revisions WHERE campaign_id = $1 ORDER BY version DESC LIMIT 1
+    );
+
+-- name: get-campaign-revisions
+SELECT id, campaign_id, version, user_id, user_name, changed, sent_at, created_at
+    FROM campaign_revisions WHERE campaign_id = $1 ORDER BY version DESC;
+
+-- name: get-campaign-revision
+SELECT * FROM campaign_revisions WHERE campaign_id = $1 AND version = $2;
+
+-- name: restore-campaign-revision
+-- Overwrites a campaign's content fields with the snapshot in a revision.
+-- If the template in the snapshot no longer exists, the template is unset.
+UPDATE campaigns c SET
+    name=r.data->>'name',
+    subject=r.data->>'subject',
+    from_email=r.data->>'from_email',
+    body=r.data->>'body',
+    body_source=r.data->>'body_source',
+    altbody=r.data->>'altbody',
+    content_type=(r.data->>'content_type')::content_type,
+    template_id=(SELECT id FROM templates WHERE id = (r.data->>'template_id')::INT),
+    messenger=r.data->>'messenger',
+    headers=COALESCE(NULLIF(r.data->'headers', 'null'), '[]'),
+    tags=(CASE WHEN JSONB_TYPEOF(r.data->'tags') = 'array' THEN ARRAY(SELECT JSONB_ARRAY_ELEMENTS_TEXT(r.data->'tags')) END),
+    updated_at=NOW()
+FROM campaign_revisions r
+WHERE c.id = $1 AND r.campaign_id = $1 AND r.version = $2;
+
 -- name: update-campaign-archive
 UPDATE campaigns SET
     archive=$2,
@@ -945,6 +1162,58 @@ UPDATE campaigns SET
 -- name: delete-campaign
 DELETE FROM campaigns WHERE id=$1;
 
+-- name: get-campaign-feed
+SELECT * FROM campaign_feeds WHERE campaign_id = $1;
+
+-- name: get-campaign-feeds
+-- Returns all enabled feeds of digest campaigns that aren't in the trash.
+SELECT campaign_feeds.* FROM campaign_feeds
+    JOIN campaigns ON (campaigns.id = campaign_feeds.campaign_id)
+    WHERE campaign_feeds.enabled = true AND campaigns.type = 'digest' AND campaigns.deleted_at IS NULL
+    ORDER BY campaign_feeds.id;
+
+-- name: upsert-campaign-feed
+INSERT INTO campaign_feeds (campaign_id, url, poll_interval, max_items, enabled)
+    VALUES($1, $2, $3, $4, $5)
+    ON CONFLICT (campaign_id) DO UPDATE SET
+        url=$2, poll_interval=$3, max_items=$4, enabled=$5, updated_at=NOW()
+    RETURNING *;
+
+-- name: delete-campaign-feed
+DELETE FROM campaign_feeds WHERE campaign_id = $1;
+
+-- name: update-campaign-feed-checked
+-- Records a feed check that picked up no new items.
+UPDATE campaign_feeds SET last_checked_at=NOW() WHERE campaign_id = $1;
+
+-- name: create-feed-campaign
+-- Creates a regular campaign from digest campaign $1 with the given feed items
+-- and starts it. The feed check and its newest item ($5) are recorded in the
+-- same statement so that the items aren't picked up again.
+WITH camp AS (
+    INSERT INTO campaigns (uuid, type, name, subject, from_email, body, body_source, altbody,
+        content_type, headers, tags, messenger, template_id, archive, archive_template_id, archive_meta,
+        feed_items, status)
+        SELECT $2, 'regular', $3, subject, from_email, body, body_source, altbody,
+            content_type, headers, tags, messenger, template_id, archive, archive_template_id, archive_meta,
+            $4, 'running'
+        FROM campaigns WHERE id = $1 AND type = 'digest' AND deleted_at IS NULL
+        RETURNING id
+),
+med AS (
+    INSERT INTO campaign_media (campaign_id, media_id, filename)
+        (SELECT (SELECT id FROM camp), media_id, filename FROM campaign_media WHERE campaign_id = $1)
+),
+insLists AS (
+    INSERT INTO campaign_lists (campaign_id, list_id, list_name)
+        SELECT (SELECT id FROM camp), list_id, list_name FROM campaign_lists WHERE campaign_id = $1 AND list_id IS NOT NULL
+),
+feed AS (
+    UPDATE campaign_feeds SET last_checked_at=NOW(), last_item_at=$5, last_campaign_id=(SELECT id FROM camp)
+        WHERE campaign_id = $1 AND EXISTS (SELECT 1 FROM camp)
+)
+SELECT id FROM camp;
+
 -- name: register-campaign-view
 WITH view AS (
     SELECT campaigns.id as campaign_id, subscribers.id AS subscriber_id FROM campaigns
@@ -1049,6 +1318,7 @@ UPDATE settings AS s SET value = c.value
 
 -- name: record-bounce
 -- Insert a bounce and count the bounces for the subscriber and either unsubscribe them,
+-- If $10 (window in days) > 0, only the bounces within the window are counted.
 WITH sub AS (
     SELECT id, status FROM subscribers WHERE CASE WHEN $1 != '' THEN uuid = $1::UUID ELSE email = $2 END
 ),
@@ -1058,6 +1328,7 @@ camp AS (
 num AS (
     -- Add a +1 to include the current insertion that is happening.
     SELECT COUNT(*) + 1 AS num FROM bounces WHERE subscriber_id = (SELECT id FROM sub) AND type = $4
+        AND ($10 = 0 OR created_at >= $7::TIMESTAMP WITH TIME ZONE - MAKE_INTERVAL(days => $10))
 ),
 -- block1 and block2 will run when $8 = 'blocklist' and the number of bounces exceed $8.
 block1 AS (
@@ -1078,6 +1349,79 @@ bounce AS (
 DELETE FROM subscribers
     WHERE $9 = 'delete' AND (SELECT num FROM num) >= $8 AND id = (SELECT id FROM sub);
 
+-- name: get-soft-bounce-streak
+-- Checks if the subscriber has soft bounced on each of the last $4 campaigns sent to them,
+-- counting the campaign ($3) that is being bounced. Campaigns are picked up from the subscriber's lists.
+WITH sub AS (
+    SELECT id FROM subscribers WHERE CASE WHEN $1 != '' THEN uuid = $1::UUID ELSE email = $2 END
+),
+camp AS (
+    SELECT id FROM campaigns WHERE $3 != '' AND uuid = $3::UUID
+),
+camps AS (
+    SELECT DISTINCT c.id, c.started_at FROM campaigns c
+    JOIN campaign_lists cl ON (cl.campaign_id = c.id)
+    JOIN subscriber_lists sl ON (sl.list_id = cl.list_id AND sl.subscriber_id = (SELECT id FROM sub) AND sl.status != 'unsubscribed')
+    WHERE c.status IN ('running', 'finished') AND c.started_at IS NOT NULL
+    ORDER BY c.started_at DESC LIMIT $4
+)
+SELECT COUNT(*) >= $4 FROM camps
+    WHERE camps.id = (SELECT id FROM camp) OR EXISTS (
+        SELECT 1 FROM bounces WHERE subscriber_id = (SELECT id FROM sub) AND campaign_id = camps.id AND type = 'soft'
+    );
+
+-- name: get-suppressed-subscribers
+-- Returns the IDs of the given subscribers that are blocklisted, or have hard bounced or complained.
+SELECT id FROM subscribers WHERE id = ANY($1::INT[]) AND (
+    status = 'blocklisted' OR
+    EXISTS (SELECT 1 FROM bounces WHERE subscriber_id = subscribers.id AND type IN ('hard', 'complaint'))
+);
+
+-- name: decay-bounces
+-- Deletes soft bounce records that are older than the given timestamp. Hard bounces
+-- and complaints are kept.
+DELETE FROM bounces WHERE created_at < $1 AND type = 'soft';
+
+-- name: record-delivery
+-- Records a delivery confirmation reported by a bounce webhook.
+WITH sub AS (
+    SELECT id FROM subscribers WHERE CASE WHEN $1 != '' THEN uuid = $1::UUID ELSE email = $2 END
+)
+INSERT INTO deliveries (subscriber_id, campaign_id, source, meta, created_at)
+    VALUES((SELECT id FROM sub), (SELECT id FROM campaigns WHERE $3 != '' AND uuid = $3::UUID), $4, $5, $6);
+
+-- name: record-webhook-view
+-- Records a campaign view (open) reported by a bounce webhook. The view is skipped
+-- if the subscriber already has a view on the campaign within an hour of it, eg: from
+-- the tracking pixel, so that the same open isn't counted twice.
+WITH sub AS (
+    SELECT id FROM subscribers WHERE CASE WHEN $1 != '' THEN uuid = $1::UUID ELSE email = $2 END
+),
+camp AS (
+    SELECT id FROM campaigns WHERE $3 != '' AND uuid = $3::UUID
+)
+INSERT INTO campaign_views (campaign_id, subscriber_id, created_at)
+    SELECT camp.id, sub.id, $4 FROM camp, sub
+    WHERE NOT EXISTS (
+        SELECT 1 FROM campaign_views v WHERE v.campaign_id = camp.id AND v.subscriber_id = sub.id
+        AND v.created_at BETWEEN $4::TIMESTAMP WITH TIME ZONE - INTERVAL '1 hour' AND $4::TIMESTAMP WITH TIME ZONE + INTERVAL '1 hour'
+    );
+
+-- name: record-webhook-click
+-- Records a link click reported by a bounce webhook. The link is created if it doesn't exist.
+-- The subscriber is only linked to the click if individual tracking ($4) is enabled.
+WITH link AS (
+    INSERT INTO links (uuid, url) VALUES($7, $6) ON CONFLICT (url) DO UPDATE SET url=EXCLUDED.url RETURNING id
+)
+INSERT INTO link_clicks (campaign_id, subscriber_id, link_id, created_at)
+    SELECT id,
+    (CASE WHEN $4 THEN
+        (SELECT id FROM subscribers WHERE CASE WHEN $1 != '' THEN uuid = $1::UUID ELSE email = $2 END)
+    END),
+    (SELECT id FROM link),
+    $5
+    FROM campaigns WHERE $3 != '' AND uuid = $3::UUID;
+
 -- name: query-bounces
 SELECT COUNT(*) OVER () AS total,
     bounces.id,
@@ -1329,3 +1673,617 @@ UPDATE roles SET name=$2, permissions=$3 WHERE id=$1 and parent_id IS NULL RETUR
 
 -- name: delete-role
 DELETE FROM roles WHERE id=$1;
+
+-- sequences
+-- name: get-sequences
+SELECT s.*,
+    COUNT(*) FILTER (WHERE ss.status = 'active') AS active,
+    COUNT(*) FILTER (WHERE ss.status = 'finished') AS finished,
+    COUNT(*) FILTER (WHERE ss.status = 'exited') AS exited
+    FROM sequences s
+    LEFT JOIN sequence_subscribers ss ON (ss.sequence_id = s.id)
+    WHERE ($1 = 0 OR s.id = $1)
+    GROUP BY s.id ORDER BY s.id;
+
+-- name: get-sequence-steps
+-- Subscribers waiting for a step are the active ones whose last sent step is the previous one.
+SELECT st.id, st.sequence_id, st.position, st.delay::TEXT AS delay, st.template_id, st.subject, st.sent,
+    (SELECT COUNT(*) FROM sequence_subscribers ss
+        WHERE ss.sequence_id = st.sequence_id AND ss.status = 'active' AND ss.last_position = st.position - 1
+    ) AS waiting
+    FROM sequence_steps st WHERE st.sequence_id = $1 ORDER BY st.position;
+
+-- name: create-sequence
+INSERT INTO sequences (name, list_id, from_email, messenger, enabled) VALUES($1, $2, $3, $4, $5) RETURNING id;
+
+-- name: update-sequence
+UPDATE sequences SET name=$2, list_id=$3, from_email=$4, messenger=$5, enabled=$6, updated_at=NOW() WHERE id=$1;
+
+-- name: delete-sequence
+DELETE FROM sequences WHERE id=$1;
+
+-- name: upsert-sequence-step
+INSERT INTO sequence_steps (sequence_id, position, delay, template_id, subject)
+    VALUES($1, $2, $3::INTERVAL, $4, $5)
+    ON CONFLICT (sequence_id, position) DO UPDATE
+    SET delay=$3::INTERVAL, template_id=$4, subject=$5, updated_at=NOW();
+
+-- name: delete-sequence-steps-after
+DELETE FROM sequence_steps WHERE sequence_id=$1 AND position > $2;
+
+-- name: add-sequence-subscribers
+-- Adds subscribers to a sequence. Subscribers who have already been in the sequence are skipped.
+INSERT INTO sequence_subscribers (sequence_id, subscriber_id)
+    (SELECT $1, id FROM subscribers WHERE id = ANY($2::INT[]) AND status != 'blocklisted' AND deleted_at IS NULL)
+    ON CONFLICT DO NOTHING;
+
+-- name: exit-sequence-subscribers
+UPDATE sequence_subscribers SET status='exited', updated_at=NOW()
+    WHERE sequence_id=$1 AND subscriber_id = ANY($2::INT[]) AND status='active';
+
+-- name: enroll-sequence-subscribers
+-- Adds subscribers who subscribed to a sequence's list after the sequence was created.
+-- As with campaigns, subscriptions to double opt-in lists have to be confirmed.
+-- The subscription's created_at is used as updated_at changes on every status
+-- change or re-import of existing subscribers.
+INSERT INTO sequence_subscribers (sequence_id, subscriber_id)
+    SELECT seq.id, sl.subscriber_id FROM sequences seq
+    JOIN lists l ON (l.id = seq.list_id AND l.deleted_at IS NULL)
+    JOIN subscriber_lists sl ON (
+        sl.list_id = seq.list_id AND sl.created_at >= seq.created_at
+        AND (CASE WHEN l.optin = 'double' THEN sl.status = 'confirmed' ELSE sl.status != 'unsubscribed' END)
+    )
+    JOIN subscribers s ON (s.id = sl.subscriber_id AND s.status != 'blocklisted' AND s.deleted_at IS NULL)
+    WHERE seq.enabled = true
+    ON CONFLICT DO NOTHING;
+
+-- name: exit-unsubscribed-sequence-subscribers
+-- Exits active subscribers who are blocklisted or have unsubscribed from the sequence's list.
+UPDATE sequence_subscribers ss SET status='exited', updated_at=NOW()
+    FROM sequences seq, subscribers s
+    WHERE ss.sequence_id = seq.id AND ss.subscriber_id = s.id AND ss.status = 'active'
+    AND (
+        s.status = 'blocklisted' OR
+        EXISTS (
+            SELECT 1 FROM subscriber_lists sl
+            WHERE sl.subscriber_id = ss.subscriber_id AND sl.list_id = seq.list_id AND sl.status = 'unsubscribed'
+        )
+    );
+
+-- name: next-sequence-messages
+-- Returns the next steps that are due for active subscribers in enabled sequences.
+SELECT ss.sequence_id, ss.subscriber_id, st.id AS step_id, st.position, st.template_id, st.subject,
+    seq.from_email, seq.messenger
+    FROM sequence_subscribers ss
+    JOIN sequences seq ON (seq.id = ss.sequence_id AND seq.enabled = true)
+    JOIN subscribers s ON (s.id = ss.subscriber_id AND s.deleted_at IS NULL)
+    JOIN LATERAL (
+        SELECT * FROM sequence_steps WHERE sequence_id = ss.sequence_id AND position > ss.last_position
+        ORDER BY position LIMIT 1
+    ) st ON TRUE
+    WHERE ss.status = 'active' AND ss.entered_at + st.delay <= NOW()
+    ORDER BY ss.entered_at LIMIT $1;
+
+-- name: advance-sequence-subscriber
+-- Records that step $3 was sent (or skipped if $4 is false) to a subscriber. If there are
+-- no more steps, the subscriber finishes the sequence.
+WITH st AS (
+    UPDATE sequence_steps SET sent = sent + (CASE WHEN $4 THEN 1 ELSE 0 END)
+    WHERE sequence_id = $1 AND position = $3
+)
+UPDATE sequence_subscribers SET last_position=$3,
+    status=(CASE WHEN EXISTS (SELECT 1 FROM sequence_steps WHERE sequence_id = $1 AND position > $3)
+        THEN status ELSE 'finished' END),
+    updated_at=NOW()
+    WHERE sequence_id=$1 AND subscriber_id=$2;
+
+-- name: finish-sequence-subscribers
+-- Marks active subscribers who have been sent all the steps of a sequence as finished.
+-- This happens when steps are removed from a sequence.
+UPDATE sequence_subscribers ss SET status='finished', updated_at=NOW()
+    WHERE ss.status = 'active' AND NOT EXISTS (
+        SELECT 1 FROM sequence_steps st WHERE st.sequence_id = ss.sequence_id AND st.position > ss.last_position
+    );
+
+-- subscriber events and automations
+-- name: insert-subscriber-event
+-- Records an event against a subscriber (by ID or e-mail) and queues the enabled automations
+-- whose event name matches and whose filter is contained in the event data.
+WITH sub AS (
+    SELECT id FROM subscribers WHERE
+    CASE
+        WHEN $1 > 0 THEN id = $1
+        ELSE LOWER(email) = LOWER($2)
+    END
+    AND deleted_at IS NULL
+),
+ev AS (
+    INSERT INTO subscriber_events (subscriber_id, name, data)
+        SELECT id, $3, $4 FROM sub
+        RETURNING id, subscriber_id, data
+),
+jobs AS (
+    INSERT INTO automation_jobs (automation_id, subscriber_id, event_id, send_at)
+        SELECT a.id, ev.subscriber_id, ev.id, NOW() + a.delay FROM automations a, ev
+        WHERE a.enabled = true AND a.event = $3 AND ev.data @> a.filter
+)
+SELECT id FROM ev;
+
+-- name: get-subscriber-events
+SELECT * FROM subscriber_events WHERE subscriber_id = $1
+    AND ($2 = '' OR name = $2)
+    ORDER BY created_at DESC OFFSET $3 LIMIT (CASE WHEN $4 < 1 THEN NULL ELSE $4 END);
+
+-- name: record-subscriber-activity
+-- Records an entry in the activity timeline of the given subscribers (by ID or UUID).
+-- list_ids in the data, if any, are replaced with the IDs and names of the lists
+-- so that they remain readable after the lists are deleted.
+WITH lists AS (
+    SELECT COALESCE(JSONB_AGG(JSONB_BUILD_OBJECT('id', id, 'name', name)), '[]'::JSONB) AS lists FROM lists
+    WHERE id = ANY(SELECT JSONB_ARRAY_ELEMENTS_TEXT(COALESCE($4::JSONB->'list_ids', '[]'))::INT)
+)
+INSERT INTO subscriber_activity (subscriber_id, type, data, actor, user_id)
+    SELECT subscribers.id, $3,
+        (CASE WHEN $4::JSONB->'list_ids' IS NULL THEN $4::JSONB ELSE ($4::JSONB - 'list_ids') || JSONB_BUILD_OBJECT('lists', lists.lists) END),
+        $5, NULLIF($6, 0)
+    FROM subscribers, lists WHERE subscribers.id = ANY($1::INT[]) OR subscribers.uuid = ANY($2::UUID[]);
+
+-- name: record-subscriber-activity-by-query
+-- raw: true
+WITH subs AS (%query%),
+lists AS (
+    SELECT COALESCE(JSONB_AGG(JSONB_BUILD_OBJECT('id', id, 'name', name)), '[]'::JSONB) AS lists FROM lists
+    WHERE id = ANY(SELECT JSONB_ARRAY_ELEMENTS_TEXT(COALESCE($6::JSONB->'list_ids', '[]'))::INT)
+)
+INSERT INTO subscriber_activity (subscriber_id, type, data, actor, user_id)
+    SELECT subs.id, $5,
+        (CASE WHEN $6::JSONB->'list_ids' IS NULL THEN $6::JSONB ELSE ($6::JSONB - 'list_ids') || JSONB_BUILD_OBJECT('lists', lists.lists) END),
+        $7, NULLIF($8, 0)
+    FROM subs, lists;
+
+-- name: get-subscriber-activity
+-- Retrieves a subscriber's activity timeline. Along with the entries recorded in subscriber_activity,
+-- campaign views, link clicks and bounces are picked up from their tables. Individual campaign
+-- messages aren't recorded, so campaigns that targeted the subscriber are derived from the campaigns'
+-- lists and progress. They don't imply that a message was delivered.
+-- $2 = types to filter by (empty for all), $5 = order ('asc' or 'desc').
+WITH sub AS (
+    SELECT id, created_at FROM subscribers WHERE id = $1
+),
+act AS (
+    SELECT a.type, a.data, a.actor, a.user_id, a.created_at FROM subscriber_activity a WHERE a.subscriber_id = $1
+
+    UNION ALL
+    -- Subscribers created without a recorded entry, eg: by imports.
+    SELECT 'created', '{}'::JSONB, 'system', NULL, sub.created_at FROM sub
+        WHERE NOT EXISTS (SELECT 1 FROM subscriber_activity WHERE subscriber_id = $1 AND type = 'created')
+
+    UNION ALL
+    SELECT 'campaign_targeted', JSONB_BUILD_OBJECT('campaign_id', c.id, 'campaign_name', c.name, 'subject', c.subject),
+        'system', NULL, COALESCE(c.started_at, c.updated_at)
+        FROM campaigns c, sub
+        WHERE c.type != 'optin' AND c.status IN ('running', 'paused', 'cancelled', 'finished')
+        AND c.last_subscriber_id >= sub.id
+        AND EXISTS (
+            SELECT 1 FROM campaign_lists cl
+            JOIN subscriber_lists sl ON (sl.list_id = cl.list_id AND sl.subscriber_id = sub.id)
+            WHERE cl.campaign_id = c.id
+            AND sl.created_at <= COALESCE(c.started_at, c.updated_at)
+            AND (sl.status != 'unsubscribed' OR sl.updated_at > COALESCE(c.started_at, c.updated_at))
+        )
+
+    UNION ALL
+    SELECT 'campaign_view', JSONB_BUILD_OBJECT('campaign_id', v.campaign_id, 'campaign_name', c.name),
+        'subscriber', NULL, v.created_at
+        FROM campaign_views v
+        LEFT JOIN campaigns c ON (c.id = v.campaign_id)
+        WHERE v.subscriber_id = $1
+
+    UNION ALL
+    SELECT 'link_click', JSONB_BUILD_OBJECT('campaign_id', k.campaign_id, 'campaign_name', c.name, 'url', l.url),
+        'subscriber', NULL, k.created_at
+        FROM link_clicks k
+        JOIN links l ON (l.id = k.link_id)
+        LEFT JOIN campaigns c ON (c.id = k.campaign_id)
+        WHERE k.subscriber_id = $1
+
+    UNION ALL
+    SELECT 'bounce', JSONB_BUILD_OBJECT('bounce_id', b.id, 'bounce_type', b.type, 'source', b.source,
+        'campaign_id', b.campaign_id, 'campaign_name', c.name, 'meta', b.meta),
+        'system', NULL, b.created_at
+        FROM bounces b
+        LEFT JOIN campaigns c ON (c.id = b.campaign_id)
+        WHERE b.subscriber_id = $1
+)
+SELECT COUNT(*) OVER () AS total, act.*, u.username AS user_name FROM act
+    LEFT JOIN users u ON (u.id = act.user_id)
+    WHERE (CARDINALITY($2::TEXT[]) = 0 OR act.type = ANY($2::TEXT[]))
+    ORDER BY (CASE WHEN $5 = 'asc' THEN act.created_at END) ASC, act.created_at DESC
+    OFFSET $3 LIMIT (CASE WHEN $4 < 1 THEN NULL ELSE $4 END);
+
+-- name: query-unsubscriptions
+-- Retrieves the unsubscriptions made by subscribers, recorded in their activity timelines with
+-- the source (List-Unsubscribe 'header', unsubscribe 'page' or 'reply'), latest first.
+-- $1 = source ('' for all), $2 = campaign ID (0 for all), $3, $4 = date range ('' for none).
+SELECT COUNT(*) OVER () AS total, a.subscriber_id, s.uuid AS subscriber_uuid, s.email, a.type,
+    a.data->>'source' AS source, COALESCE(a.data->'list_ids', '[]') AS list_ids,
+    COALESCE(a.data->>'campaign_uuid', '') AS campaign_uuid, c.id AS campaign_id, c.name AS campaign_name,
+    a.created_at
+    FROM subscriber_activity a
+    JOIN subscribers s ON (s.id = a.subscriber_id)
+    LEFT JOIN campaigns c ON (c.uuid::TEXT = a.data->>'campaign_uuid')
+    WHERE a.type IN ('unsubscribed', 'blocklisted') AND a.data->>'source' IS NOT NULL
+    AND ($1 = '' OR a.data->>'source' = $1)
+    AND ($2 = 0 OR c.id = $2)
+    AND ($3 = '' OR a.created_at >= $3::TIMESTAMP WITH TIME ZONE)
+    AND ($4 = '' OR a.created_at <= $4::TIMESTAMP WITH TIME ZONE)
+    ORDER BY a.created_at DESC
+    OFFSET $5 LIMIT (CASE WHEN $6 < 1 THEN NULL ELSE $6 END);
+
+-- name: record-reply
+-- Records a reply e-mail in the activity timeline of a subscriber by UUID ($1) or e-mail ($2),
+-- along with the campaign ($4) that's replied to, if any.
+INSERT INTO subscriber_activity (subscriber_id, type, data, actor)
+    SELECT s.id, 'reply', $3::JSONB || JSONB_BUILD_OBJECT('campaign_id', c.id, 'campaign_name', c.name), 'subscriber'
+    FROM subscribers s
+    LEFT JOIN campaigns c ON (c.uuid::TEXT = $4)
+    WHERE CASE WHEN $1 != '' THEN s.uuid = $1::UUID ELSE LOWER(s.email) = LOWER($2) END;
+
+-- name: get-subscriber-emails
+-- Retrieves the IDs and e-mails of all subscribers for finding duplicates.
+SELECT id, email FROM subscribers WHERE deleted_at IS NULL ORDER BY id;
+
+-- name: get-duplicate-subscribers
+-- Retrieves the subscribers ($1) in groups of duplicates.
+SELECT id, JSON_BUILD_OBJECT('id', id, 'uuid', uuid, 'email', email, 'name', name,
+    'status', status, 'created_at', created_at, 'updated_at', updated_at) AS data
+    FROM subscribers WHERE id = ANY($1::INT[]) AND deleted_at IS NULL;
+
+-- name: merge-subscribers
+-- Merges the data of subscribers ($2) into a subscriber ($1) and returns a snapshot of
+-- the merged subscribers. The merged subscribers should be deleted after this.
+-- Attributes are combined with the subscriber's own values taking precedence, followed by
+-- those of the most recently updated subscribers. For subscriptions to the same list,
+-- the most recently updated subscription status is retained. If any of the subscribers
+-- are blocklisted, the subscriber is blocklisted. Sequence enrolments and pending
+-- automation jobs are moved to the subscriber.
+WITH dups AS (
+    SELECT * FROM subscribers WHERE id = ANY($2::INT[]) AND id != $1 AND deleted_at IS NULL
+),
+snap AS (
+    SELECT COALESCE(JSONB_AGG(JSONB_BUILD_OBJECT('id', d.id, 'uuid', d.uuid, 'email', d.email, 'name', d.name,
+        'attribs', d.attribs, 'status', d.status, 'created_at', d.created_at,
+        'lists', (SELECT COALESCE(JSONB_AGG(JSONB_BUILD_OBJECT('id', sl.list_id, 'status', sl.status, 'created_at', sl.created_at)), '[]')
+            FROM subscriber_lists sl WHERE sl.subscriber_id = d.id)
+    ) ORDER BY d.id), '[]') AS data FROM dups d
+),
+subs AS (
+    INSERT INTO subscriber_lists (subscriber_id, list_id, status, meta, created_at, updated_at)
+        SELECT DISTINCT ON (list_id) $1, list_id, status, meta, created_at, updated_at FROM subscriber_lists
+        WHERE subscriber_id = ANY(SELECT id FROM dups)
+        ORDER BY list_id, updated_at DESC
+    ON CONFLICT (subscriber_id, list_id) DO UPDATE SET
+        status = (CASE WHEN EXCLUDED.updated_at > subscriber_lists.updated_at THEN EXCLUDED.status ELSE subscriber_lists.status END),
+        meta = EXCLUDED.meta || subscriber_lists.meta,
+        created_at = LEAST(subscriber_lists.created_at, EXCLUDED.created_at),
+        updated_at = GREATEST(subscriber_lists.updated_at, EXCLUDED.updated_at)
+),
+views AS (
+    UPDATE campaign_views SET subscriber_id = $1 WHERE subscriber_id = ANY(SELECT id FROM dups)
+),
+clicks AS (
+    UPDATE link_clicks SET subscriber_id = $1 WHERE subscriber_id = ANY(SELECT id FROM dups)
+),
+bounces AS (
+    UPDATE bounces SET subscriber_id = $1 WHERE subscriber_id = ANY(SELECT id FROM dups)
+),
+events AS (
+    UPDATE subscriber_events SET subscriber_id = $1 WHERE subscriber_id = ANY(SELECT id FROM dups)
+),
+activity AS (
+    UPDATE subscriber_activity SET subscriber_id = $1 WHERE subscriber_id = ANY(SELECT id FROM dups)
+),
+seqs AS (
+    -- For sequences that more than one of them are in, the furthest position is retained
+    -- so that steps aren't sent again.
+    INSERT INTO sequence_subscribers (sequence_id, subscriber_id, status, last_position, entered_at, updated_at)
+        SELECT DISTINCT ON (sequence_id) sequence_id, $1, status, last_position, entered_at, updated_at
+        FROM sequence_subscribers WHERE subscriber_id = ANY(SELECT id FROM dups)
+        ORDER BY sequence_id, last_position DESC
+    ON CONFLICT (sequence_id, subscriber_id) DO UPDATE SET
+        last_position = GREATEST(sequence_subscribers.last_position, EXCLUDED.last_position),
+        entered_at = LEAST(sequence_subscribers.entered_at, EXCLUDED.entered_at)
+),
+jobs AS (
+    UPDATE automation_jobs SET subscriber_id = $1 WHERE subscriber_id = ANY(SELECT id FROM dups)
+),
+merged_attribs AS (
+    SELECT COALESCE(JSONB_OBJECT_AGG(a.key, a.value ORDER BY a.prio, a.updated_at), '{}') AS attribs FROM (
+        SELECT e.key, e.value, 0 AS prio, d.updated_at FROM dups d, JSONB_EACH(d.attribs) e
+        UNION ALL
+        SELECT e.key, e.value, 1 AS prio, s.updated_at FROM subscribers s, JSONB_EACH(s.attribs) e WHERE s.id = $1
+    ) a
+)
+UPDATE subscribers SET
+    attribs = (SELECT attribs FROM merged_attribs),
+    status = (CASE WHEN EXISTS (SELECT 1 FROM dups WHERE status = 'blocklisted') THEN 'blocklisted' ELSE subscribers.status END),
+    created_at = LEAST(subscribers.created_at, (SELECT MIN(created_at) FROM dups)),
+    updated_at = NOW()
+WHERE id = $1 AND deleted_at IS NULL
+RETURNING (SELECT data FROM snap);
+
+-- name: insert-subscriber-merge
+INSERT INTO subscriber_merges (subscriber_id, email, merged, user_id)
+    VALUES($1, (SELECT email FROM subscribers WHERE id = $1), $2, NULLIF($3, 0)) RETURNING id;
+
+-- name: query-subscriber-merges
+-- Retrieves the merge records of a subscriber ($1), or all of them if $1 is 0, latest first.
+SELECT COUNT(*) OVER () AS total, m.*, u.username AS user_name FROM subscriber_merges m
+    LEFT JOIN users u ON (u.id = m.user_id)
+    WHERE ($1 = 0 OR m.subscriber_id = $1)
+    ORDER BY m.created_at DESC
+    OFFSET $2 LIMIT (CASE WHEN $3 < 1 THEN NULL ELSE $3 END);
+
+-- name: get-automations
+SELECT a.id, a.name, a.event, a.filter, a.delay::TEXT AS delay, a.template_id, a.subject,
+    a.from_email, a.messenger, a.enabled, a.sent, a.created_at, a.updated_at,
+    (SELECT COUNT(*) FROM automation_jobs WHERE automation_id = a.id) AS pending
+    FROM automations a WHERE ($1 = 0 OR a.id = $1) ORDER BY a.id;
+
+-- name: create-automation
+INSERT INTO automations (name, event, filter, delay, template_id, subject, from_email, messenger, enabled)
+    VALUES($1, $2, $3, $4::INTERVAL, $5, $6, $7, $8, $9) RETURNING id;
+
+-- name: update-automation
+UPDATE automations SET name=$2, event=$3, filter=$4, delay=$5::INTERVAL, template_id=$6, subject=$7,
+    from_email=$8, messenger=$9, enabled=$10, updated_at=NOW()
+    WHERE id=$1;
+
+-- name: delete-automation
+DELETE FROM automations WHERE id=$1;
+
+-- name: next-automation-jobs
+-- Returns automation messages that are due for enabled automations.
+SELECT j.id, j.automation_id, j.subscriber_id, e.name AS event, e.data, a.template_id, a.subject,
+    a.from_email, a.messenger
+    FROM automation_jobs j
+    JOIN automations a ON (a.id = j.automation_id AND a.enabled = true)
+    JOIN subscriber_events e ON (e.id = j.event_id)
+    JOIN subscribers s ON (s.id = j.subscriber_id AND s.deleted_at IS NULL)
+    WHERE j.send_at <= NOW()
+    ORDER BY j.send_at LIMIT $1;
+
+-- name: delete-automation-job
+-- Deletes a processed job and if a message was sent ($2), increments the automation's sent count.
+WITH j AS (
+    DELETE FROM automation_jobs WHERE id = $1 RETURNING automation_id
+)
+UPDATE automations SET sent = sent + 1 WHERE $2 AND id = (SELECT automation_id FROM j);
+
+-- name: incr-smtp-warmup-count
+-- Increments an SMTP server's send count for the day if it's below the given
+-- limit. No row is returned if the limit has been reached.
+INSERT INTO smtp_warmup (server, day, sent) VALUES($1, $2::DATE, 1)
+    ON CONFLICT (server, day) DO UPDATE SET sent = smtp_warmup.sent + 1
+    WHERE smtp_warmup.sent < $3
+    RETURNING sent;
+
+-- name: decr-smtp-warmup-count
+-- Decrements an SMTP server's send count for the day, eg: when a message failed to send.
+UPDATE smtp_warmup SET sent = sent - 1 WHERE server = $1 AND day = $2::DATE AND sent > 0;
+
+-- name: create-import-job
+INSERT INTO import_jobs (user_id, name, params, file_path) VALUES(NULLIF($1, 0), $2, $3, $4) RETURNING id;
+
+-- name: query-import-jobs
+-- Retrieves import jobs (without logs), latest first. $1 is an optional user ID
+-- to restrict the jobs to, and $2 an optional status.
+SELECT COUNT(*) OVER () AS total_jobs, j.id, j.user_id, COALESCE(u.username, '') AS user_name,
+    j.name, j.params, COALESCE((j.params->>'dry_run')::BOOLEAN, false) AS dry_run,
+    j.status, j.total, j.imported, j.rejected, (j.errors IS NOT NULL) AS has_errors,
+    j.started_at, j.finished_at, j.created_at, j.updated_at
+FROM import_jobs j
+LEFT JOIN users u ON (u.id = j.user_id)
+WHERE ($1 = 0 OR j.user_id = $1)
+    AND ($2 = '' OR j.status::TEXT = $2)
+ORDER BY j.id DESC OFFSET $3 LIMIT (CASE WHEN $4 < 1 THEN NULL ELSE $4 END);
+
+-- name: get-import-job
+-- Retrieves an import job (without the CSV of rejected rows). If $1 is 0, the
+-- latest job that hasn't been cleared is returned. $2 is an optional user ID
+-- to restrict the job to.
+SELECT j.id, j.user_id, COALESCE(u.username, '') AS user_name, j.name, j.params, j.file_path,
+    COALESCE((j.params->>'dry_run')::BOOLEAN, false) AS dry_run,
+    j.status, j.total, j.imported, j.rejected, j.log, (j.errors IS NOT NULL) AS has_errors,
+    j.started_at, j.finished_at, j.created_at, j.updated_at
+FROM import_jobs j
+LEFT JOIN users u ON (u.id = j.user_id)
+WHERE (CASE WHEN $1 = 0 THEN NOT j.cleared ELSE j.id = $1 END)
+    AND ($2 = 0 OR j.user_id = $2)
+ORDER BY j.id DESC LIMIT 1;
+
+-- name: get-import-job-errors
+SELECT errors FROM import_jobs WHERE id = $1 AND ($2 = 0 OR user_id = $2) AND errors IS NOT NULL;
+
+-- name: next-import-job
+-- Claims the oldest queued import job and marks it as importing.
+UPDATE import_jobs SET status='importing', started_at=NOW(), updated_at=NOW()
+    WHERE id = (
+        SELECT id FROM import_jobs WHERE status='queued' ORDER BY id LIMIT 1 FOR UPDATE SKIP LOCKED
+    )
+    RETURNING id, user_id, name, params, file_path, status, total, imported, rejected,
+        started_at, finished_at, created_at, updated_at;
+
+-- name: update-import-job
+UPDATE import_jobs SET status=$2::import_status, total=$3, imported=$4, rejected=$5, log=$6,
+    finished_at=(CASE WHEN $2 IN ('stopped', 'finished', 'failed') THEN NOW() ELSE NULL END),
+    updated_at=NOW()
+    WHERE id = $1;
+
+-- name: update-import-job-errors
+UPDATE import_jobs SET errors=$2 WHERE id = $1;
+
+-- name: cancel-import-job
+-- Cancels a queued import job that hasn't started yet.
+UPDATE import_jobs SET status='stopped', finished_at=NOW(), updated_at=NOW(),
+    log=log || 'cancelled before starting' || E'\n'
+    WHERE id = $1 AND ($2 = 0 OR user_id = $2) AND status='queued'
+    RETURNING file_path;
+
+-- name: clear-import-jobs
+-- Dismisses all finished jobs of a user (or all users if $1 is 0) so that the
+-- import page doesn't show them.
+UPDATE import_jobs SET cleared=true
+    WHERE ($1 = 0 OR user_id = $1) AND NOT cleared AND status IN ('stopped', 'finished', 'failed');
+
+-- name: delete-import-job
+DELETE FROM import_jobs WHERE id = $1 AND ($2 = 0 OR user_id = $2)
+    AND status IN ('stopped', 'finished', 'failed');
+
+-- name: fail-interrupted-import-jobs
+-- Marks jobs that were running when the app was stopped as failed.
+UPDATE import_jobs SET status='failed', finished_at=NOW(), updated_at=NOW(),
+    log=log || 'import interrupted by an app restart' || E'\n'
+    WHERE status IN ('importing', 'stopping')
+    RETURNING file_path;
+
+-- name: get-import-presets
+-- Retrieves all import presets, or a single one if $1 is > 0.
+SELECT * FROM import_presets WHERE ($1 = 0 OR id = $1) ORDER BY name;
+
+-- name: create-import-preset
+INSERT INTO import_presets (name, mapping) VALUES($1, $2) RETURNING id;
+
+-- name: update-import-preset
+UPDATE import_presets SET name=$2, mapping=$3, updated_at=NOW() WHERE id = $1;
+
+-- name: delete-import-preset
+DELETE FROM import_presets WHERE id = $1;
+
+-- name: get-attrib-schema
+-- Retrieves all attributes in the subscriber attribute schema, or a single one if $1 is > 0.
+SELECT * FROM attrib_schema WHERE ($1 = 0 OR id = $1) ORDER BY name;
+
+-- name: create-attrib-field
+INSERT INTO attrib_schema (name, type, description, enum_values, required, public)
+    VALUES($1, $2, $3, $4, $5, $6) RETURNING id;
+
+-- name: update-attrib-field
+UPDATE attrib_schema SET name=$2, type=$3, description=$4, enum_values=$5, required=$6, public=$7, updated_at=NOW()
+    WHERE id = $1;
+
+-- name: delete-attrib-field
+DELETE FROM attrib_schema WHERE id = $1;
+
+-- name: get-sync-sources
+-- Retrieves all sync sources, or a single one if $1 is > 0.
+SELECT * FROM sync_sources WHERE ($1 = 0 OR id = $1) ORDER BY name;
+
+-- name: create-sync-source
+INSERT INTO sync_sources (name, type, url, query, headers, schedule, mapping, list_id, subscription_status,
+    overwrite, unsubscribe_missing, enabled, user_id)
+    VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, NULLIF($13, 0)) RETURNING id;
+
+-- name: update-sync-source
+UPDATE sync_sources SET name=$2, type=$3, url=$4, query=$5, headers=$6, schedule=$7, mapping=$8, list_id=$9,
+    subscription_status=$10, overwrite=$11, unsubscribe_missing=$12, enabled=$13, updated_at=NOW()
+    WHERE id = $1;
+
+-- name: delete-sync-source
+DELETE FROM sync_sources WHERE id = $1;
+
+-- name: get-list-subscriber-emails
+-- Retrieves the e-mails of all subscribers in a list who haven't unsubscribed.
+SELECT LOWER(s.email) FROM subscriber_lists sl
+    JOIN subscribers s ON (s.id = sl.subscriber_id AND s.deleted_at IS NULL)
+    WHERE sl.list_id = $1 AND sl.status != 'unsubscribed';
+
+-- name: create-sync-run
+-- Creates a run for a sync source and records it as the source's last run.
+WITH s AS (
+    UPDATE sync_sources SET last_run_at=NOW() WHERE id = $1
+)
+INSERT INTO sync_runs (source_id, status, started_at) VALUES($1, 'running', NOW()) RETURNING id;
+
+-- name: update-sync-run
+UPDATE sync_runs SET status=$2, total=$3, skipped=$4, added=$5, updated=$6, removed=$7,
+    import_job_ids=$8, error=$9, finished_at=NOW()
+    WHERE id = $1;
+
+-- name: query-sync-runs
+-- Retrieves the runs of a sync source, latest first.
+SELECT COUNT(*) OVER () AS total_runs, * FROM sync_runs WHERE source_id = $1
+    ORDER BY id DESC OFFSET $2 LIMIT (CASE WHEN $3 < 1 THEN NULL ELSE $3 END);
+
+-- name: fail-interrupted-sync-runs
+-- Marks sync runs that were running when the app was stopped as failed.
+UPDATE sync_runs SET status='failed', error='interrupted', finished_at=NOW() WHERE status = 'running';
+
+-- trash
+-- name: trash-subscribers
+-- Moves subscribers (by ID or UUID) to the trash.
+UPDATE subscribers SET deleted_at=NOW()
+    WHERE (CASE WHEN ARRAY_LENGTH($1::INT[], 1) > 0 THEN id = ANY($1) ELSE uuid = ANY($2::UUID[]) END)
+    AND deleted_at IS NULL;
+
+-- name: trash-subscribers-by-query
+-- raw: true
+WITH subs AS (%query%)
+UPDATE subscribers SET deleted_at=NOW() WHERE id=ANY(SELECT id FROM subs);
+
+-- name: trash-lists
+UPDATE lists SET deleted_at=NOW() WHERE id = ANY($1::INT[]) AND deleted_at IS NULL;
+
+-- name: trash-campaign
+-- Moves a campaign to the trash. Running and scheduled campaigns are paused.
+UPDATE campaigns SET deleted_at=NOW(),
+    status=(CASE WHEN status IN ('running', 'scheduled') THEN 'paused' ELSE status END)
+    WHERE id = $1 AND deleted_at IS NULL;
+
+-- name: query-trash
+-- Retrieves trashed subscribers, lists or campaigns ($1), latest first.
+SELECT COUNT(*) OVER () AS total, t.* FROM (
+    SELECT id, uuid, name, email, status::TEXT AS status, deleted_at FROM subscribers
+        WHERE $1 = 'subscribers' AND deleted_at IS NOT NULL
+    UNION ALL
+    SELECT id, uuid, name, '' AS email, type::TEXT AS status, deleted_at FROM lists
+        WHERE $1 = 'lists' AND deleted_at IS NOT NULL
+    UNION ALL
+    SELECT id, uuid, name, '' AS email, status::TEXT AS status, deleted_at FROM campaigns
+        WHERE $1 = 'campaigns' AND deleted_at IS NOT NULL
+) t
+ORDER BY t.deleted_at DESC
+OFFSET $2 LIMIT (CASE WHEN $3 < 1 THEN NULL ELSE $3 END);
+
+-- name: restore-trash
+-- Restores trashed subscribers, lists or campaigns ($1) by ID ($2) and returns the number restored.
+WITH subs AS (
+    UPDATE subscribers SET deleted_at=NULL, updated_at=NOW()
+    WHERE $1 = 'subscribers' AND id = ANY($2::INT[]) AND deleted_at IS NOT NULL RETURNING id
+),
+ls AS (
+    UPDATE lists SET deleted_at=NULL, updated_at=NOW()
+    WHERE $1 = 'lists' AND id = ANY($2::INT[]) AND deleted_at IS NOT NULL RETURNING id
+),
+camps AS (
+    UPDATE campaigns SET deleted_at=NULL, updated_at=NOW()
+    WHERE $1 = 'campaigns' AND id = ANY($2::INT[]) AND deleted_at IS NOT NULL RETURNING id
+)
+SELECT (SELECT COUNT(*) FROM subs) + (SELECT COUNT(*) FROM ls) + (SELECT COUNT(*) FROM camps);
+
+-- name: purge-trash
+-- Permanently deletes trashed subscribers, lists or campaigns ($1) by ID ($2), or all of them if $3 is true,
+-- that were trashed before $4, and returns the number deleted.
+WITH subs AS (
+    DELETE FROM subscribers WHERE $1 = 'subscribers' AND deleted_at < $4
+    AND ($3 OR id = ANY($2::INT[])) RETURNING id
+),
+ls AS (
+    DELETE FROM lists WHERE $1 = 'lists' AND deleted_at < $4
+    AND ($3 OR id = ANY($2::INT[])) RETURNING id
+),
+camps AS (
+    DELETE FROM campaigns WHERE $1 = 'campaigns' AND deleted_at < $4
+    AND ($3 OR id = ANY($2::INT[])) RETURNING id
+)
+SELECT (SELECT COUNT(*) FROM subs) + (SELECT COUNT(*) FROM ls) + (SELECT COUNT(*) FROM camps);
@@ -3,13 +3,17 @@ DROP TYPE IF EXISTS list_optin CASCADE; CREATE TYPE list_optin AS ENUM ('single'
 DROP TYPE IF EXISTS subscriber_status CASCADE; CREATE TYPE subscriber_status AS ENUM ('enabled', 'disabled', 'blocklisted');
 DROP TYPE IF EXISTS subscription_status CASCADE; CREATE TYPE subscription_status AS ENUM ('unconfirmed', 'confirmed', 'unsubscribed');
 DROP TYPE IF EXISTS campaign_status CASCADE; CREATE TYPE campaign_status AS ENUM ('draft', 'running', 'scheduled', 'paused', 'cancelled', 'finished');
-DROP TYPE IF EXISTS campaign_type CASCADE; CREATE TYPE campaign_type AS ENUM ('regular', 'optin');
+DROP TYPE IF EXISTS campaign_type CASCADE; CREATE TYPE campaign_type AS ENUM ('regular', 'optin', 'digest');
 DROP TYPE IF EXISTS content_type CASCADE; CREATE TYPE content_type AS ENUM ('richtext', 'html', 'plain', 'markdown', 'visual');
 DROP TYPE IF EXISTS bounce_type CASCADE; CREATE TYPE bounce_type AS ENUM ('soft', 'hard', 'complaint');
 DROP TYPE IF EXISTS template_type CASCADE; CREATE TYPE template_type AS ENUM ('campaign', 'campaign_visual', 'tx');
 DROP TYPE IF EXISTS user_type CASCADE; CREATE TYPE user_type AS ENUM ('user', 'api');
 DROP TYPE IF EXISTS user_status CASCADE; CREATE TYPE user_status AS ENUM ('enabled', 'disabled');
 DROP TYPE IF EXISTS role_type CASCADE; CREATE TYPE role_type AS ENUM ('user', 'list');
+DROP TYPE IF EXISTS sequence_sub_status CASCADE; CREATE TYPE sequence_sub_status AS ENUM ('active', 'finished', 'exited');
+DROP TYPE IF EXISTS import_status CASCADE; CREATE TYPE import_status AS ENUM ('queued', 'importing', 'stopping', 'stopped', 'finished', 'failed');
+DROP TYPE IF EXISTS sync_source_type CASCADE; CREATE TYPE sync_source_type AS ENUM ('http', 'postgres');
+DROP TYPE IF EXISTS sync_run_status CASCADE; CREATE TYPE sync_run_status AS ENUM ('running', 'finished', 'failed');
 
 CREATE EXTENSION IF NOT EXISTS pgcrypto;
 
@@ -24,13 +28,17 @@ CREATE TABLE subscribers (
     status          subscriber_status NOT NULL DEFAULT 'enabled',
 
     created_at      TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
-    updated_at      TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+    updated_at      TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+
+    -- Time at which the subscriber was moved to the trash.
+    deleted_at      TIMESTAMP WITH TIME ZONE NULL
 );
 DROP INDEX IF EXISTS idx_subs_email; CREATE UNIQUE INDEX idx_subs_email ON subscribers(LOWER(email));
 DROP INDEX IF EXISTS idx_subs_status; CREATE INDEX idx_subs_status ON subscribers(status);
 DROP INDEX IF EXISTS idx_subs_id_status; CREATE INDEX idx_subs_id_status ON subscribers(id, status);
 DROP INDEX IF EXISTS idx_subs_created_at; CREATE INDEX idx_subs_created_at ON subscribers(created_at);
 DROP INDEX IF EXISTS idx_subs_updated_at; CREATE INDEX idx_subs_updated_at ON subscribers(updated_at);
+DROP INDEX IF EXISTS idx_subs_deleted_at; CREATE INDEX idx_subs_deleted_at ON subscribers(deleted_at) WHERE deleted_at IS NOT NULL;
 
 -- lists
 DROP TABLE IF EXISTS lists CASCADE;
@@ -44,13 +52,17 @@ CREATE TABLE lists (
     description     TEXT NOT NULL DEFAULT '',
 
     created_at      TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
-    updated_at      TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+    updated_at      TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+
+    -- Time at which the list was moved to the trash.
+    deleted_at      TIMESTAMP WITH TIME ZONE NULL
 );
 DROP INDEX IF EXISTS idx_lists_type; CREATE INDEX idx_lists_type ON lists(type);
 DROP INDEX IF EXISTS idx_lists_optin; CREATE INDEX idx_lists_optin ON lists(optin);
 DROP INDEX IF EXISTS idx_lists_name; CREATE INDEX idx_lists_name ON lists(name);
 DROP INDEX IF EXISTS idx_lists_created_at; CREATE INDEX idx_lists_created_at ON lists(created_at);
 DROP INDEX IF EXISTS idx_lists_updated_at; CREATE INDEX idx_lists_updated_at ON lists(updated_at);
+DROP INDEX IF EXISTS idx_lists_deleted_at; CREATE INDEX idx_lists_deleted_at ON lists(deleted_at) WHERE deleted_at IS NOT NULL;
 
 
 DROP TABLE IF EXISTS subscriber_lists CASCADE;
@@ -123,14 +135,24 @@ CREATE TABLE campaigns (
     archive_template_id INTEGER REFERENCES templates(id) ON DELETE SET NULL,
     archive_meta        JSONB NOT NULL DEFAULT '{}',
 
+    -- New feed items picked up by a digest campaign that this campaign was created from.
+    feed_items          JSONB NOT NULL DEFAULT '[]',
+
+    -- Time at which a campaign that was paused on hitting a daily sending limit is resumed.
+    resume_at        TIMESTAMP WITH TIME ZONE NULL,
+
     started_at       TIMESTAMP WITH TIME ZONE,
     created_at       TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
-    updated_at       TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+    updated_at       TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+
+    -- Time at which the campaign was moved to the trash.
+    deleted_at       TIMESTAMP WITH TIME ZONE NULL
 );
 DROP INDEX IF EXISTS idx_camps_status; CREATE INDEX idx_camps_status ON campaigns(status);
 DROP INDEX IF EXISTS idx_camps_name; CREATE INDEX idx_camps_name ON campaigns(name);
 DROP INDEX IF EXISTS idx_camps_created_at; CREATE INDEX idx_camps_created_at ON campaigns(created_at);
 DROP INDEX IF EXISTS idx_camps_updated_at; CREATE INDEX idx_camps_updated_at ON campaigns(updated_at);
+DROP INDEX IF EXISTS idx_camps_deleted_at; CREATE INDEX idx_camps_deleted_at ON campaigns(deleted_at) WHERE deleted_at IS NOT NULL;
 
 
 DROP TABLE IF EXISTS campaign_lists CASCADE;
@@ -188,6 +210,27 @@ CREATE TABLE campaign_media (
 DROP INDEX IF EXISTS idx_camp_media_id; CREATE UNIQUE INDEX idx_camp_media_id ON campaign_media (campaign_id, media_id);
 DROP INDEX IF EXISTS idx_camp_media_camp_id; CREATE INDEX idx_camp_media_camp_id ON campaign_media(campaign_id);
 
+-- campaign_feeds
+-- RSS/Atom/JSON feeds polled by 'digest' campaigns. When a feed has new items,
+-- a regular campaign is created from the digest campaign and started.
+DROP TABLE IF EXISTS campaign_feeds CASCADE;
+CREATE TABLE campaign_feeds (
+    id               SERIAL PRIMARY KEY,
+    campaign_id      INTEGER NOT NULL UNIQUE REFERENCES campaigns(id) ON DELETE CASCADE ON UPDATE CASCADE,
+    url              TEXT NOT NULL,
+    poll_interval    TEXT NOT NULL DEFAULT '1h',
+    max_items        INT NOT NULL DEFAULT 10,
+    enabled          BOOLEAN NOT NULL DEFAULT true,
+
+    -- Publish date of the newest item that was sent out. Only items newer than this are picked up.
+    last_item_at     TIMESTAMP WITH TIME ZONE NULL,
+    last_checked_at  TIMESTAMP WITH TIME ZONE NULL,
+    last_campaign_id INTEGER NULL REFERENCES campaigns(id) ON DELETE SET NULL ON UPDATE CASCADE,
+
+    created_at       TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at       TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
 
 -- links
 DROP TABLE IF EXISTS links CASCADE;
@@ -228,6 +271,8 @@ INSERT INTO settings (key, value) VALUES
     ('app.from_email', '"listmonk <noreply@listmonk.yoursite.com>"'),
     ('app.logo_url', '""'),
     ('app.concurrency', '10'),
+    ('app.import_concurrency', '2'),
+    ('app.trash_retention_days', '30'),
     ('app.message_rate', '10'),
     ('app.batch_size', '1000'),
     ('app.max_send_errors', '1000'),
@@ -245,6 +290,7 @@ INSERT INTO settings (key, value) VALUES
     ('app.lang', '"en"'),
     ('privacy.individual_tracking', 'false'),
     ('privacy.unsubscribe_header', 'true'),
+    ('privacy.unsubscribe_mailto', '""'),
     ('privacy.allow_blocklist', 'true'),
     ('privacy.allow_export', 'true'),
     ('privacy.allow_wipe', 'true'),
@@ -277,14 +323,29 @@ INSERT INTO settings (key, value) VALUES
     ('messengers', '[]'),
     ('bounce.enabled', 'false'),
     ('bounce.webhooks_enabled', 'false'),
-    ('bounce.actions', '{"soft": {"count": 2, "action": "none"}, "hard": {"count": 1, "action": "blocklist"}, "complaint" : {"count": 1, "action": "blocklist"}}'),
+    ('bounce.webhook_events', '{"delivered": true, "opens": false, "clicks": false}'),
+    ('bounce.webhook_security', '{"hmac_secret": "", "max_age": 300, "allowlist": {}}'),
+    ('bounce.actions', '{"soft": {"count": 2, "action": "none", "window_days": 0}, "hard": {"count": 1, "action": "blocklist", "window_days": 0}, "complaint" : {"count": 1, "action": "blocklist", "window_days": 0}}'),
+    ('bounce.soft_escalation', '0'),
+    ('bounce.decay_days', '0'),
     ('bounce.ses_enabled', 'false'),
     ('bounce.sendgrid_enabled', 'false'),
     ('bounce.sendgrid_key', '""'),
     ('bounce.postmark', '{"enabled": false, "username": "", "password": ""}'),
     ('bounce.forwardemail', '{"enabled": false, "key": ""}'),
+    ('bounce.mailgun', '{"enabled": false, "key": ""}'),
+    ('bounce.sparkpost', '{"enabled": false, "username": "", "password": ""}'),
+    ('bounce.brevo', '{"enabled": false, "username": "", "password": ""}'),
+    ('bounce.mailjet', '{"enabled": false, "username": "", "password": ""}'),
+    ('bounce.resend', '{"enabled": false, "key": ""}'),
     ('bounce.mailboxes',
         '[{"enabled":false, "type": "pop", "host":"pop.yoursite.com","port":995,"auth_protocol":"userpass","username":"username","password":"password","return_path": "bounce@listmonk.yoursite.com","scan_interval":"15m","tls_enabled":true,"tls_skip_verify":false}]'),
+    ('inbound.enabled', 'false'),
+    ('inbound.mailbox',
+        '{"type": "imap", "host": "imap.yoursite.com", "port": 993, "auth_protocol": "login", "username": "username", "password": "password", "tls_enabled": true, "tls_skip_verify": false, "scan_interval": "5m", "folder": "INBOX", "mode": "poll", "processed_action": "flag", "processed_folder": ""}'),
+    ('inbound.unsubscribe', 'true'),
+    ('inbound.forward_email', '""'),
+    ('inbound.forward_webhook', '""'),
     ('appearance.admin.custom_css', '""'),
     ('appearance.admin.custom_js', '""'),
     ('appearance.public.custom_css', '""'),
@@ -306,6 +367,20 @@ DROP INDEX IF EXISTS idx_bounces_camp_id; CREATE INDEX idx_bounces_camp_id ON bo
 DROP INDEX IF EXISTS idx_bounces_source; CREATE INDEX idx_bounces_source ON bounces(source);
 DROP INDEX IF EXISTS idx_bounces_date; CREATE INDEX idx_bounces_date ON bounces((TIMEZONE('UTC', created_at)::DATE));
 
+-- deliveries
+-- Delivery confirmations reported by bounce webhooks.
+DROP TABLE IF EXISTS deliveries CASCADE;
+CREATE TABLE deliveries (
+    id               BIGSERIAL PRIMARY KEY,
+    subscriber_id    INTEGER NOT NULL REFERENCES subscribers(id) ON DELETE CASCADE ON UPDATE CASCADE,
+    campaign_id      INTEGER NULL REFERENCES campaigns(id) ON DELETE SET NULL ON UPDATE CASCADE,
+    source           TEXT NOT NULL DEFAULT '',
+    meta             JSONB NOT NULL DEFAULT '{}',
+    created_at       TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+DROP INDEX IF EXISTS idx_deliveries_sub_id; CREATE INDEX idx_deliveries_sub_id ON deliveries(subscriber_id);
+DROP INDEX IF EXISTS idx_deliveries_camp_id; CREATE INDEX idx_deliveries_camp_id ON deliveries(campaign_id);
+
 -- roles
 DROP TABLE IF EXISTS roles CASCADE;
 CREATE TABLE roles (
@@ -349,13 +424,263 @@ CREATE TABLE sessions (
 );
 DROP INDEX IF EXISTS idx_sessions; CREATE INDEX idx_sessions ON sessions (id, created_at);
 
+-- sequences
+-- Drip/autoresponder sequences. Subscribers enter a sequence when they subscribe to its list
+-- (or via the API) and are sent each step's template once the step's delay has elapsed.
+DROP TABLE IF EXISTS sequences CASCADE;
+CREATE TABLE sequences (
+    id               SERIAL PRIMARY KEY,
+    name             TEXT NOT NULL,
+
+    -- Subscribers who subscribe to this list enter the sequence and exit it when they unsubscribe.
+    list_id          INTEGER NULL REFERENCES lists(id) ON DELETE SET NULL ON UPDATE CASCADE,
+    from_email       TEXT NOT NULL DEFAULT '',
+    messenger        TEXT NOT NULL DEFAULT 'email',
+    enabled          BOOLEAN NOT NULL DEFAULT true,
+    created_at       TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at       TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+DROP INDEX IF EXISTS idx_sequences_list_id; CREATE INDEX idx_sequences_list_id ON sequences(list_id);
+
+DROP TABLE IF EXISTS sequence_steps CASCADE;
+CREATE TABLE sequence_steps (
+    id               SERIAL PRIMARY KEY,
+    sequence_id      INTEGER NOT NULL REFERENCES sequences(id) ON DELETE CASCADE ON UPDATE CASCADE,
+    position         INT NOT NULL,
+
+    -- Time after the subscriber entered the sequence after which the step is sent.
+    delay            INTERVAL NOT NULL DEFAULT '0',
+
+    -- tx template that's sent. Steps whose templates are deleted are skipped.
+    template_id      INTEGER NULL REFERENCES templates(id) ON DELETE SET NULL ON UPDATE CASCADE,
+    subject          TEXT NOT NULL DEFAULT '',
+    sent             INT NOT NULL DEFAULT 0,
+    created_at       TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at       TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+
+    UNIQUE(sequence_id, position)
+);
+
+DROP TABLE IF EXISTS sequence_subscribers CASCADE;
+CREATE TABLE sequence_subscribers (
+    sequence_id      INTEGER NOT NULL REFERENCES sequences(id) ON DELETE CASCADE ON UPDATE CASCADE,
+    subscriber_id    INTEGER NOT NULL REFERENCES subscribers(id) ON DELETE CASCADE ON UPDATE CASCADE,
+    status           sequence_sub_status NOT NULL DEFAULT 'active',
+
+    -- Position of the last step that was sent to the subscriber.
+    last_position    INT NOT NULL DEFAULT 0,
+    entered_at       TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at       TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+
+    PRIMARY KEY(sequence_id, subscriber_id)
+);
+DROP INDEX IF EXISTS idx_seq_subs_sub_id; CREATE INDEX idx_seq_subs_sub_id ON sequence_subscribers(subscriber_id);
+DROP INDEX IF EXISTS idx_seq_subs_status; CREATE INDEX idx_seq_subs_status ON sequence_subscribers(status);
+
+-- subscriber_events
+-- Arbitrary events (eg: trial_started, payment_failed) recorded against subscribers via the ingest API.
+DROP TABLE IF EXISTS subscriber_events CASCADE;
+CREATE TABLE subscriber_events (
+    id               BIGSERIAL PRIMARY KEY,
+    subscriber_id    INTEGER NOT NULL REFERENCES subscribers(id) ON DELETE CASCADE ON UPDATE CASCADE,
+    name             TEXT NOT NULL,
+    data             JSONB NOT NULL DEFAULT '{}',
+    created_at       TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+DROP INDEX IF EXISTS idx_sub_events_sub_id; CREATE INDEX idx_sub_events_sub_id ON subscriber_events(subscriber_id, name);
+DROP INDEX IF EXISTS idx_sub_events_name; CREATE INDEX idx_sub_events_name ON subscriber_events(name, created_at);
+
+-- subscriber_activity
+-- Changes to subscribers and their subscriptions, opt-ins and tx messages, and who made them, for the
+-- subscriber activity timeline. Views, clicks, bounces and campaigns sent are picked up from their own tables.
+DROP TABLE IF EXISTS subscriber_activity CASCADE;
+CREATE TABLE subscriber_activity (
+    id               BIGSERIAL PRIMARY KEY,
+    subscriber_id    INTEGER NOT NULL REFERENCES subscribers(id) ON DELETE CASCADE ON UPDATE CASCADE,
+    type             TEXT NOT NULL,
+    data             JSONB NOT NULL DEFAULT '{}',
+    actor            TEXT NOT NULL DEFAULT 'system',
+    user_id          INTEGER NULL REFERENCES users(id) ON DELETE SET NULL ON UPDATE CASCADE,
+    created_at       TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+DROP INDEX IF EXISTS idx_sub_activity_sub_id; CREATE INDEX idx_sub_activity_sub_id ON subscriber_activity(subscriber_id, created_at);
+
+-- subscriber_merges
+-- Records of duplicate subscribers merged into a subscriber with a snapshot of the merged subscribers.
+-- The records are retained even if the subscriber is deleted.
+DROP TABLE IF EXISTS subscriber_merges CASCADE;
+CREATE TABLE subscriber_merges (
+    id               SERIAL PRIMARY KEY,
+    subscriber_id    INTEGER NULL REFERENCES subscribers(id) ON DELETE SET NULL ON UPDATE CASCADE,
+    email            TEXT NOT NULL,
+    merged           JSONB NOT NULL DEFAULT '[]',
+    user_id          INTEGER NULL REFERENCES users(id) ON DELETE SET NULL ON UPDATE CASCADE,
+    created_at       TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+DROP INDEX IF EXISTS idx_sub_merges_sub_id; CREATE INDEX idx_sub_merges_sub_id ON subscriber_merges(subscriber_id);
+
+-- automations
+-- Rules that send a tx template to a subscriber a given time after an event matching
+-- the rule's name and filter (JSONB containment on the event data) is ingested.
+DROP TABLE IF EXISTS automations CASCADE;
+CREATE TABLE automations (
+    id               SERIAL PRIMARY KEY,
+    name             TEXT NOT NULL,
+    event            TEXT NOT NULL,
+    filter           JSONB NOT NULL DEFAULT '{}',
+    delay            INTERVAL NOT NULL DEFAULT '0',
+    template_id      INTEGER NULL REFERENCES templates(id) ON DELETE SET NULL ON UPDATE CASCADE,
+    subject          TEXT NOT NULL DEFAULT '',
+    from_email       TEXT NOT NULL DEFAULT '',
+    messenger        TEXT NOT NULL DEFAULT 'email',
+    enabled          BOOLEAN NOT NULL DEFAULT true,
+    sent             INT NOT NULL DEFAULT 0,
+    created_at       TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at       TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+DROP INDEX IF EXISTS idx_automations_event; CREATE INDEX idx_automations_event ON automations(event);
+
+-- Messages queued by automations, waiting for their delay to elapse.
+DROP TABLE IF EXISTS automation_jobs CASCADE;
+CREATE TABLE automation_jobs (
+    id               BIGSERIAL PRIMARY KEY,
+    automation_id    INTEGER NOT NULL REFERENCES automations(id) ON DELETE CASCADE ON UPDATE CASCADE,
+    subscriber_id    INTEGER NOT NULL REFERENCES subscribers(id) ON DELETE CASCADE ON UPDATE CASCADE,
+    event_id         BIGINT NOT NULL REFERENCES subscriber_events(id) ON DELETE CASCADE ON UPDATE CASCADE,
+    send_at          TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+DROP INDEX IF EXISTS idx_automation_jobs_send_at; CREATE INDEX idx_automation_jobs_send_at ON automation_jobs(send_at);
+
+-- Daily send counts of SMTP servers on a warm-up plan.
+DROP TABLE IF EXISTS smtp_warmup CASCADE;
+CREATE TABLE smtp_warmup (
+    server           TEXT NOT NULL,
+    day              DATE NOT NULL,
+    sent             INT NOT NULL DEFAULT 0,
+
+    PRIMARY KEY (server, day)
+);
+
+-- Revisions of campaign content, recorded on every save that changes it.
+DROP TABLE IF EXISTS campaign_revisions CASCADE;
+CREATE TABLE campaign_revisions (
+    id               SERIAL PRIMARY KEY,
+    campaign_id      INTEGER NOT NULL REFERENCES campaigns(id) ON DELETE CASCADE ON UPDATE CASCADE,
+    version          INT NOT NULL,
+    user_id          INTEGER NULL REFERENCES users(id) ON DELETE SET NULL ON UPDATE CASCADE,
+    user_name        TEXT NOT NULL DEFAULT '',
+
+    -- Snapshot of the content fields and the fields that changed from the previous revision.
+    data             JSONB NOT NULL DEFAULT '{}',
+    changed          TEXT[] NOT NULL DEFAULT '{}',
+
+    -- Set when the campaign starts running with this revision.
+    sent_at          TIMESTAMP WITH TIME ZONE NULL,
+    created_at       TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+
+    UNIQUE (campaign_id, version)
+);
+
+-- Subscriber import jobs, queued and processed in the background.
+DROP TABLE IF EXISTS import_jobs CASCADE;
+CREATE TABLE import_jobs (
+    id               SERIAL PRIMARY KEY,
+    user_id          INTEGER NULL REFERENCES users(id) ON DELETE SET NULL ON UPDATE CASCADE,
+    name             TEXT NOT NULL,
+    params           JSONB NOT NULL DEFAULT '{}',
+
+    -- Uploaded file, removed once the job is done.
+    file_path        TEXT NOT NULL DEFAULT '',
+    status           import_status NOT NULL DEFAULT 'queued',
+    total            INT NOT NULL DEFAULT 0,
+    imported         INT NOT NULL DEFAULT 0,
+    rejected         INT NOT NULL DEFAULT 0,
+    log              TEXT NOT NULL DEFAULT '',
+
+    -- CSV of rejected rows with the reasons.
+    errors           BYTEA NULL,
+
+    -- Set when a finished job is dismissed on the import page.
+    cleared          BOOLEAN NOT NULL DEFAULT false,
+    started_at       TIMESTAMP WITH TIME ZONE NULL,
+    finished_at      TIMESTAMP WITH TIME ZONE NULL,
+    created_at       TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at       TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+DROP INDEX IF EXISTS idx_import_jobs_status; CREATE INDEX idx_import_jobs_status ON import_jobs(status);
+DROP INDEX IF EXISTS idx_import_jobs_user_id; CREATE INDEX idx_import_jobs_user_id ON import_jobs(user_id);
+
+-- Saved CSV column mappings for imports.
+DROP TABLE IF EXISTS import_presets CASCADE;
+CREATE TABLE import_presets (
+    id               SERIAL PRIMARY KEY,
+    name             TEXT NOT NULL,
+    mapping          JSONB NOT NULL DEFAULT '[]',
+    created_at       TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at       TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+-- subscriber attribute schema
+DROP TABLE IF EXISTS attrib_schema CASCADE;
+CREATE TABLE attrib_schema (
+    id               SERIAL PRIMARY KEY,
+    name             TEXT NOT NULL,
+    type             TEXT NOT NULL,
+    description      TEXT NOT NULL DEFAULT '',
+    enum_values      TEXT[] NOT NULL DEFAULT '{}',
+    required         BOOLEAN NOT NULL DEFAULT false,
+    public           BOOLEAN NOT NULL DEFAULT false,
+    created_at       TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at       TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+DROP INDEX IF EXISTS idx_attrib_schema_name; CREATE UNIQUE INDEX idx_attrib_schema_name ON attrib_schema(LOWER(name));
+
+-- subscriber sync
+DROP TABLE IF EXISTS sync_sources CASCADE;
+CREATE TABLE sync_sources (
+    id                  SERIAL PRIMARY KEY,
+    name                TEXT NOT NULL,
+    type                sync_source_type NOT NULL,
+    url                 TEXT NOT NULL,
+    query               TEXT NOT NULL DEFAULT '',
+    headers             JSONB NOT NULL DEFAULT '{}',
+    schedule            TEXT NOT NULL,
+    mapping             JSONB NOT NULL DEFAULT '[]',
+    list_id             INTEGER NOT NULL REFERENCES lists(id) ON DELETE CASCADE ON UPDATE CASCADE,
+    subscription_status subscription_status NOT NULL DEFAULT 'confirmed',
+    overwrite           BOOLEAN NOT NULL DEFAULT true,
+    unsubscribe_missing BOOLEAN NOT NULL DEFAULT false,
+    enabled             BOOLEAN NOT NULL DEFAULT true,
+    user_id             INTEGER NULL REFERENCES users(id) ON DELETE SET NULL ON UPDATE CASCADE,
+    last_run_at         TIMESTAMP WITH TIME ZONE NULL,
+    created_at          TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at          TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+DROP TABLE IF EXISTS sync_runs CASCADE;
+CREATE TABLE sync_runs (
+    id                  SERIAL PRIMARY KEY,
+    source_id           INTEGER NOT NULL REFERENCES sync_sources(id) ON DELETE CASCADE ON UPDATE CASCADE,
+    status              sync_run_status NOT NULL DEFAULT 'running',
+    total               INT NOT NULL DEFAULT 0,
+    skipped             INT NOT NULL DEFAULT 0,
+    added               INT NOT NULL DEFAULT 0,
+    updated             INT NOT NULL DEFAULT 0,
+    removed             INT NOT NULL DEFAULT 0,
+    import_job_ids      INT[] NOT NULL DEFAULT '{}',
+    error               TEXT NOT NULL DEFAULT '',
+    started_at          TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    finished_at         TIMESTAMP WITH TIME ZONE NULL
+);
+DROP INDEX IF EXISTS idx_sync_runs_source_id; CREATE INDEX idx_sync_runs_source_id ON sync_runs(source_id);
+
 -- materialized views
 
 -- dashboard stats
 DROP MATERIALIZED VIEW IF EXISTS mat_dashboard_counts;
 CREATE MATERIALIZED VIEW mat_dashboard_counts AS
     WITH subs AS (
-        SELECT COUNT(*) AS num, status FROM subscribers GROUP BY status
+        SELECT COUNT(*) AS num, status FROM subscribers WHERE deleted_at IS NULL GROUP BY status
     )
     SELECT NOW() AS updated_at,
         JSON_BUILD_OBJECT(
@@ -365,21 +690,21 @@ CREATE MATERIALIZED VIEW mat_dashboard_counts AS
                 'orphans', (
                     SELECT COUNT(id) FROM subscribers
                     LEFT JOIN subscriber_lists ON (subscribers.id = subscriber_lists.subscriber_id)
-                    WHERE subscriber_lists.subscriber_id IS NULL
+                    WHERE subscriber_lists.subscriber_id IS NULL AND subscribers.deleted_at IS NULL
                 )
             ),
             'lists', JSON_BUILD_OBJECT(
-                'total', (SELECT COUNT(*) FROM lists),
-                'private', (SELECT COUNT(*) FROM lists WHERE type='private'),
-                'public', (SELECT COUNT(*) FROM lists WHERE type='public'),
-                'optin_single', (SELECT COUNT(*) FROM lists WHERE optin='single'),
-                'optin_double', (SELECT COUNT(*) FROM lists WHERE optin='double')
+                'total', (SELECT COUNT(*) FROM lists WHERE deleted_at IS NULL),
+                'private', (SELECT COUNT(*) FROM lists WHERE type='private' AND deleted_at IS NULL),
+                'public', (SELECT COUNT(*) FROM lists WHERE type='public' AND deleted_at IS NULL),
+                'optin_single', (SELECT COUNT(*) FROM lists WHERE optin='single' AND deleted_at IS NULL),
+                'optin_double', (SELECT COUNT(*) FROM lists WHERE optin='double' AND deleted_at IS NULL)
             ),
             'campaigns', JSON_BUILD_OBJECT(
-                'total', (SELECT COUNT(*) FROM campaigns),
+                'total', (SELECT COUNT(*) FROM campaigns WHERE deleted_at IS NULL),
                 'by_status', (
                     SELECT JSON_OBJECT_AGG (status, num) FROM
-                    (SELECT status, COUNT(*) AS num FROM campaigns GROUP BY status) r
+                    (SELECT status, COUNT(*) AS num FROM campaigns WHERE deleted_at IS NULL GROUP BY status) r
                 )
             ),
             'messages', (SELECT SUM(sent) AS messages FROM campaigns)
@@ -425,9 +750,14 @@ DROP INDEX IF EXISTS mat_dashboard_charts_idx; CREATE UNIQUE INDEX mat_dashboard
 -- subscriber counts stats for lists
 DROP MATERIALIZED VIEW IF EXISTS mat_list_subscriber_stats;
 CREATE MATERIALIZED VIEW mat_list_subscriber_stats AS
-    SELECT NOW() AS updated_at, lists.id AS list_id, subscriber_lists.status, COUNT(subscriber_lists.status) AS subscriber_count FROM lists
-    LEFT JOIN subscriber_lists ON (subscriber_lists.list_id = lists.id)
-    GROUP BY lists.id, subscriber_lists.status
+    SELECT NOW() AS updated_at, lists.id AS list_id, sl.status, COUNT(sl.status) AS subscriber_count FROM lists
+    LEFT JOIN subscriber_lists sl ON (
+        sl.list_id = lists.id
+        -- Exclude trashed subscribers.
+        AND NOT EXISTS (SELECT 1 FROM subscribers s WHERE s.id = sl.subscriber_id AND s.deleted_at IS NOT NULL)
+    )
+    WHERE lists.deleted_at IS NULL
+    GROUP BY lists.id, sl.status
     UNION ALL
-    SELECT NOW() AS updated_at, 0 AS list_id, NULL AS status, COUNT(id) AS subscriber_count FROM subscribers;
+    SELECT NOW() AS updated_at, 0 AS list_id, NULL AS status, COUNT(id) AS subscriber_count FROM subscribers WHERE deleted_at IS NULL;
 DROP INDEX IF EXISTS mat_list_subscriber_stats_idx; CREATE UNIQUE INDEX mat_list_subscriber_stats_idx ON mat_list_subscriber_stats (list_id, status);